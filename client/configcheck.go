@@ -55,6 +55,10 @@ var ConfigcheckCmd = &cli.Subcommand{
 			return fmt.Errorf("unsupported --format %q", configcheckArgs.format)
 		}
 
+		for _, w := range subcommand.Config().Warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+
 		var hadErr bool
 		// further: try to build jobs
 		confJobs, err := job.JobsFromConfig(subcommand.Config())
@@ -70,7 +74,7 @@ var ConfigcheckCmd = &cli.Subcommand{
 		}
 
 		// further: try to build logging outlets
-		outlets, err := logging.OutletsFromConfig(*subcommand.Config().Global.Logging)
+		outlets, err := logging.OutletsFromConfig(*subcommand.Config().Global.Logging, subcommand.Config().Global.LoggingDedup)
 		if err != nil {
 			err := errors.Wrap(err, "cannot build logging from config")
 			if configcheckArgs.what == "logging" {
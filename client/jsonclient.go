@@ -7,28 +7,60 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/daemon"
 )
 
+// controlTimeout is the --timeout flag of the subcommands that talk to the daemon.
+// 0 means no timeout.
+var controlTimeout time.Duration
+
+func addControlTimeoutFlag(f *pflag.FlagSet) {
+	f.DurationVar(&controlTimeout, "timeout", 0, "fail if the daemon does not respond within this duration, e.g. 10s (0 waits indefinitely)")
+}
+
+// withControlTimeout applies controlTimeout to a request to the daemon.
+func withControlTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if controlTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, controlTimeout)
+}
+
 func controlHttpClient(sockpath string) (client http.Client, err error) {
 	return http.Client{
 		Transport: &http.Transport{
-			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-				return net.Dial("unix", sockpath)
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", sockpath)
 			},
 		},
 	}, nil
 }
 
-func jsonRequestResponse(c http.Client, endpoint string, req interface{}, res interface{}) error {
+func jsonRequestResponse(ctx context.Context, c http.Client, endpoint string, req interface{}, res interface{}) (err error) {
+	defer func() {
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			err = errors.New("daemon not responding: timeout exceeded")
+		}
+	}()
+
 	var buf bytes.Buffer
 	encodeErr := json.NewEncoder(&buf).Encode(req)
 	if encodeErr != nil {
 		return encodeErr
 	}
 
-	resp, err := c.Post("http://unix"+endpoint, "application/json", &buf)
+	httpReq, err := http.NewRequest("POST", "http://unix"+endpoint, &buf)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := c.Do(httpReq.WithContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -40,10 +72,15 @@ func jsonRequestResponse(c http.Client, endpoint string, req interface{}, res in
 		return errors.Errorf("%s", msg.String())
 	}
 
-	decodeError := json.NewDecoder(resp.Body).Decode(&res)
+	var raw json.RawMessage
+	decodeError := json.NewDecoder(resp.Body).Decode(&raw)
 	if decodeError != nil {
 		return decodeError
 	}
-
-	return nil
+	var v daemon.ControlSchemaVersioned
+	_ = json.Unmarshal(raw, &v) // responses of daemons that predate schema versioning may not be objects
+	if err := daemon.CheckControlSchemaVersion(v.SchemaVersion); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, &res)
 }
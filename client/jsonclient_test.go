@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONRequestResponseTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-jsonclient")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sockpath := filepath.Join(dir, "control")
+
+	l, err := net.Listen("unix", sockpath)
+	require.NoError(t, err)
+	unblock := make(chan struct{})
+	srv := http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock // wedged daemon
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+	defer close(unblock)
+
+	httpc, err := controlHttpClient(sockpath)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	err = jsonRequestResponse(ctx, httpc, "/status", struct{}{}, &struct{}{})
+	assert.EqualError(t, err, "daemon not responding: timeout exceeded")
+	assert.True(t, time.Since(begin) < 5*time.Second)
+}
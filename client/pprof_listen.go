@@ -16,8 +16,9 @@ var pprofListenCmd struct {
 }
 
 var PprofListenCmd = &cli.Subcommand{
-	Use:   "listen off | [on TCP_LISTEN_ADDRESS]",
-	Short: "start a http server exposing go-tool-compatible profiling endpoints at TCP_LISTEN_ADDRESS",
+	Use:        "listen off | [on TCP_LISTEN_ADDRESS]",
+	Short:      "start a http server exposing go-tool-compatible profiling endpoints at TCP_LISTEN_ADDRESS",
+	SetupFlags: addControlTimeoutFlag,
 	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
 		if len(args) < 1 {
 			goto enargs
@@ -36,7 +37,7 @@ var PprofListenCmd = &cli.Subcommand{
 			pprofListenCmd.Run = false
 		}
 
-		RunPProf(subcommand.Config())
+		RunPProf(ctx, subcommand.Config())
 		return nil
 	enargs:
 		return errors.New("invalid number of positional arguments")
@@ -44,7 +45,7 @@ var PprofListenCmd = &cli.Subcommand{
 	},
 }
 
-func RunPProf(conf *config.Config) {
+func RunPProf(ctx context.Context, conf *config.Config) {
 	log := log.New(os.Stderr, "", 0)
 
 	die := func() {
@@ -59,7 +60,9 @@ func RunPProf(conf *config.Config) {
 		log.Printf("error creating http client: %s", err)
 		die()
 	}
-	err = jsonRequestResponse(httpc, daemon.ControlJobEndpointPProf, pprofListenCmd.PprofServerControlMsg, struct{}{})
+	ctx, cancel := withControlTimeout(ctx)
+	defer cancel()
+	err = jsonRequestResponse(ctx, httpc, daemon.ControlJobEndpointPProf, pprofListenCmd.PprofServerControlMsg, struct{}{})
 	if err != nil {
 		log.Printf("error sending control message: %s", err)
 		die()
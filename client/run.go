@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/job/runnow"
+)
+
+var runArgs struct {
+	wait bool
+	only string
+}
+
+var RunCmd = &cli.Subcommand{
+	Use:   "run JOB",
+	Short: "run a job's invocation (snapshot, replicate, prune) now instead of waiting for its schedule",
+	Example: `
+	run prod-push
+	run --wait prod-push  # print the progress and exit non-zero if the invocation had errors
+	run --only=prune --wait prod-push  # only prune, without snapshotting or replicating`,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVar(&runArgs.wait, "wait", false, "wait for the invocation to finish and print its progress")
+		f.StringVar(&runArgs.only, "only", "", "only run one phase of the invocation: snapshot|replicate|prune")
+		addControlTimeoutFlag(f)
+	},
+	Run: runRunCmd,
+}
+
+func runRunCmd(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.New("must specify exactly one job name or pattern")
+	}
+	if _, err := runnow.ParseScope(runArgs.only); err != nil {
+		return errors.Wrap(err, "--only")
+	}
+	jobs, err := subcommand.Config().JobsMatching(args[0])
+	if err != nil {
+		return err
+	}
+	httpc, err := controlHttpClient(subcommand.Config().Global.Control.SockPath)
+	if err != nil {
+		return err
+	}
+
+	started := make(map[string]uint64, len(jobs))
+	var failed []string
+	for _, j := range jobs {
+		var res daemon.ControlRunResponse
+		reqCtx, cancel := withControlTimeout(ctx)
+		err := jsonRequestResponse(reqCtx, httpc, daemon.ControlJobEndpointRun, daemon.ControlRunRequest{Name: j.Name(), Only: runArgs.only}, &res)
+		cancel()
+		if err != nil {
+			if len(jobs) == 1 {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "job %q: %s\n", j.Name(), err)
+			failed = append(failed, j.Name())
+			continue
+		}
+		fmt.Printf("job %q: started invocation #%d\n", j.Name(), res.ID)
+		started[j.Name()] = res.ID
+	}
+
+	if runArgs.wait {
+		failed = append(failed, waitManualRuns(ctx, httpc, started)...)
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("%d of %d jobs failed: %s", len(failed), len(jobs), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// waitManualRuns polls the daemon's status until the invocations in pending have finished
+// and returns the names of the jobs whose invocation failed.
+func waitManualRuns(ctx context.Context, httpc http.Client, pending map[string]uint64) (failed []string) {
+	phases := make(map[string]string, len(pending))
+	t := time.NewTicker(1 * time.Second)
+	defer t.Stop()
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			for name := range pending {
+				failed = append(failed, name)
+			}
+			return failed
+		case <-t.C:
+		}
+
+		var s daemon.Status
+		reqCtx, cancel := withControlTimeout(ctx)
+		err := jsonRequestResponse(reqCtx, httpc, daemon.ControlJobEndpointStatus, struct{}{}, &s)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot get status: %s\n", err)
+			continue
+		}
+		for name, id := range pending {
+			js, ok := s.Jobs[name]
+			if !ok {
+				fmt.Printf("job %q: no longer reported by the daemon\n", name)
+				failed = append(failed, name)
+				delete(pending, name)
+				continue
+			}
+			mr := js.ManualRun()
+			if mr == nil || mr.ID != id {
+				continue // not started yet
+			}
+			if mr.FinishedAt == nil {
+				if mr.Phase != phases[name] {
+					fmt.Printf("job %q: %s\n", name, mr.Phase)
+					phases[name] = mr.Phase
+				}
+				continue
+			}
+			delete(pending, name)
+			if len(mr.Skipped) > 0 {
+				fmt.Printf("job %q: skipped %s (--only=%s)\n", name, strings.Join(mr.Skipped, ", "), mr.Scope)
+			}
+			if len(mr.Errors) == 0 {
+				fmt.Printf("job %q: invocation #%d finished after %s\n", name, id, mr.FinishedAt.Sub(mr.StartedAt).Round(time.Second))
+				continue
+			}
+			fmt.Printf("job %q: invocation #%d finished with %d error(s):\n", name, id, len(mr.Errors))
+			for _, e := range mr.Errors {
+				if e.Filesystem != "" {
+					fmt.Printf("\t%s\t%s: %s\n", e.Phase, e.Filesystem, e.Err)
+				} else {
+					fmt.Printf("\t%s\t%s\n", e.Phase, e.Err)
+				}
+			}
+			failed = append(failed, name)
+		}
+	}
+	return failed
+}
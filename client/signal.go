@@ -2,6 +2,9 @@ package client
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -11,16 +14,33 @@ import (
 )
 
 var SignalCmd = &cli.Subcommand{
-	Use:   "signal [wakeup|reset] JOB",
-	Short: "wake up a job from wait state or abort its current invocation",
+	Use:   "signal [wakeup|reset|pause|resume] JOB | signal forget JOB FILESYSTEM",
+	Short: "wake up a job from wait state, abort its current invocation, pause / resume it, or make it forget a missing filesystem",
+	Example: `
+	signal wakeup prod-push
+	signal reset 'push-*'   # all jobs whose name matches the shell pattern
+	signal pause prod-push  # no new invocations until 'signal resume', also across daemon restarts
+	signal forget prod-push pool/old  # acknowledge that pool/old is gone, see on_missing_filesystem`,
+	SetupFlags: addControlTimeoutFlag,
 	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
-		return runSignalCmd(subcommand.Config(), args)
+		return runSignalCmd(ctx, subcommand.Config(), args)
 	},
 }
 
-func runSignalCmd(config *config.Config, args []string) error {
-	if len(args) != 2 {
-		return errors.Errorf("Expected 2 arguments: [wakeup|reset] JOB")
+func runSignalCmd(ctx context.Context, config *config.Config, args []string) error {
+	var fs string
+	if len(args) > 0 && args[0] == "forget" {
+		if len(args) != 3 {
+			return errors.Errorf("Expected 3 arguments: forget JOB FILESYSTEM")
+		}
+		fs = args[2]
+	} else if len(args) != 2 {
+		return errors.Errorf("Expected 2 arguments: [wakeup|reset|pause|resume] JOB")
+	}
+
+	jobs, err := config.JobsMatching(args[1])
+	if err != nil {
+		return err
 	}
 
 	httpc, err := controlHttpClient(config.Global.Control.SockPath)
@@ -28,15 +48,32 @@ func runSignalCmd(config *config.Config, args []string) error {
 		return err
 	}
 
-	err = jsonRequestResponse(httpc, daemon.ControlJobEndpointSignal,
-		struct {
-			Name string
-			Op   string
-		}{
-			Name: args[1],
-			Op:   args[0],
-		},
-		struct{}{},
-	)
-	return err
+	var failed []string
+	for _, job := range jobs {
+		reqCtx, cancel := withControlTimeout(ctx)
+		err = jsonRequestResponse(reqCtx, httpc, daemon.ControlJobEndpointSignal,
+			struct {
+				Name       string
+				Op         string
+				Filesystem string `json:",omitempty"`
+			}{
+				Name:       job.Name(),
+				Op:         args[0],
+				Filesystem: fs,
+			},
+			struct{}{},
+		)
+		cancel()
+		if err != nil {
+			if len(jobs) == 1 {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "job %q: %s\n", job.Name(), err)
+			failed = append(failed, job.Name())
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("signalling %d of %d jobs failed: %s", len(failed), len(jobs), strings.Join(failed, ", "))
+	}
+	return nil
 }
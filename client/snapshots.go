@@ -0,0 +1,20 @@
+package client
+
+import (
+	"github.com/zrepl/zrepl/cli"
+)
+
+var SnapshotsCmd = &cli.Subcommand{
+	Use:   "snapshots",
+	Short: "inspect and pin the snapshots managed by zrepl jobs",
+	SetupSubcommands: func() []*cli.Subcommand {
+		return []*cli.Subcommand{
+			snapshotsCmdHolds,
+			snapshotsCmdGaps,
+			snapshotsCmdEmpty,
+			snapshotsCmdRename,
+			snapshotsCmdPin,
+			snapshotsCmdUnpin,
+		}
+	},
+}
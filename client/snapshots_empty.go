@@ -0,0 +1,249 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var snapshotsEmptyFlags struct {
+	MinRun int
+	Json   bool
+}
+
+var snapshotsCmdEmpty = &cli.Subcommand{
+	Use: "empty JOB",
+	Example: `
+	empty prod-push
+	empty --min-run 10 'push-*'           # all jobs whose name matches the shell pattern`,
+	Short: "find runs of consecutive snapshots of a push, source or snap job's filesystems that contain no changes",
+	Run:   doSnapshotsEmpty,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.IntVar(&snapshotsEmptyFlags.MinRun, "min-run", 3, "report runs of at least this many consecutive snapshots with `written` = 0")
+		f.BoolVar(&snapshotsEmptyFlags.Json, "json", false, "emit JSON")
+	},
+}
+
+// SnapshotsEmptyRun is a run of consecutive snapshots that contain no changes
+// compared to the snapshot before the run.
+type SnapshotsEmptyRun struct {
+	// the first and last snapshot of the run
+	From, To string
+	Count    int
+}
+
+type SnapshotsEmptyFilesystem struct {
+	Job        string
+	Filesystem string
+	Error      string `json:",omitempty"`
+	Snapshots  int
+	Runs       []SnapshotsEmptyRun
+	// the number of snapshots in Runs
+	Empty int
+}
+
+func doSnapshotsEmpty(ctx context.Context, sc *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expecting exactly one positional argument: the job name or a shell pattern matching job names")
+	}
+	if snapshotsEmptyFlags.MinRun < 1 {
+		return errors.New("--min-run must be at least 1")
+	}
+
+	jobConfs, err := sc.Config().JobsMatching(args[0])
+	if err != nil {
+		return err
+	}
+
+	out := []SnapshotsEmptyFilesystem{}
+	// jobs with periodic snapshotting that do not skip unchanged filesystems
+	var notSkipping []string
+	var failed []string
+	for _, jobConf := range jobConfs {
+		fss, skipsUnchanged, err := snapshotsEmptyJob(ctx, jobConf)
+		if err != nil {
+			if len(jobConfs) == 1 {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "job %q: %s\n", jobConf.Name(), err)
+			failed = append(failed, jobConf.Name())
+			continue
+		}
+		out = append(out, fss...)
+		for _, fs := range fss {
+			if len(fs.Runs) > 0 && !skipsUnchanged {
+				notSkipping = append(notSkipping, jobConf.Name())
+				break
+			}
+		}
+	}
+
+	if snapshotsEmptyFlags.Json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	} else {
+		lastJob := ""
+		for _, fs := range out {
+			if len(jobConfs) > 1 && fs.Job != lastJob {
+				fmt.Printf("job %q:\n", fs.Job)
+				lastJob = fs.Job
+			}
+			if fs.Error != "" {
+				fmt.Printf("%s: error: %s\n", fs.Filesystem, fs.Error)
+				continue
+			}
+			if len(fs.Runs) == 0 {
+				continue
+			}
+			fmt.Printf("%s: %d of %d snapshots contain no changes\n", fs.Filesystem, fs.Empty, fs.Snapshots)
+			for _, r := range fs.Runs {
+				fmt.Printf("  %d snapshots from @%s to @%s\n", r.Count, r.From, r.To)
+			}
+		}
+		for _, job := range notSkipping {
+			fmt.Printf("hint: set `skip_if_unchanged: true` in the snapshotting of job %q to not take snapshots of unchanged filesystems\n", job)
+		}
+	}
+
+	runs, hadErr := 0, false
+	for _, fs := range out {
+		runs += len(fs.Runs)
+		hadErr = hadErr || fs.Error != ""
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("cannot check %d of %d jobs: %s", len(failed), len(jobConfs), strings.Join(failed, ", "))
+	}
+	if hadErr {
+		return errors.New("there were errors listing the snapshots of some filesystems")
+	}
+	if runs > 0 {
+		return errors.Errorf("found %d runs of snapshots without changes", runs)
+	}
+	return nil
+}
+
+// snapshotsEmptyJob returns skipsUnchanged = true unless the job uses periodic snapshotting
+// without skip_if_unchanged, i.e. unless enabling it would help.
+func snapshotsEmptyJob(ctx context.Context, jobConf *config.JobEnum) (_ []SnapshotsEmptyFilesystem, skipsUnchanged bool, _ error) {
+	var fsfConf config.FilesystemsFilter
+	var snapshotting config.SnapshottingEnum
+	switch c := jobConf.Ret.(type) {
+	case *config.PushJob:
+		fsfConf, snapshotting = c.Filesystems, c.Snapshotting
+	case *config.SourceJob:
+		fsfConf, snapshotting = c.Filesystems, c.Snapshotting
+	case *config.SnapJob:
+		fsfConf, snapshotting = c.Filesystems, c.Snapshotting
+	default:
+		return nil, false, fmt.Errorf("job type %T does not take snapshots, use a push, source or snap job", c)
+	}
+	skipsUnchanged = true
+	if periodic, ok := snapshotting.Ret.(*config.SnapshottingPeriodic); ok {
+		skipsUnchanged = periodic.SkipIfUnchanged
+	}
+
+	fsf, err := filters.DatasetMapFilterFromConfig(fsfConf)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "cannot build filesystem filter")
+	}
+	fss, err := zfs.ZFSListMapping(ctx, fsf)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "cannot list filesystems")
+	}
+
+	out := make([]SnapshotsEmptyFilesystem, 0, len(fss))
+	for _, dp := range fss {
+		fs := SnapshotsEmptyFilesystem{
+			Job:        jobConf.Name(),
+			Filesystem: dp.ToString(),
+		}
+		snaps, err := snapshotsEmptyList(ctx, dp.ToString())
+		if err != nil {
+			fs.Error = err.Error()
+		} else {
+			fs.Snapshots = len(snaps)
+			fs.Runs = findEmptySnapshotRuns(snaps, snapshotsEmptyFlags.MinRun)
+			for _, r := range fs.Runs {
+				fs.Empty += r.Count
+			}
+		}
+		out = append(out, fs)
+	}
+	return out, skipsUnchanged, nil
+}
+
+type snapshotsEmptyEntry struct {
+	Name      string // without the filesystem
+	CreateTXG uint64
+	Written   uint64
+}
+
+// snapshotsEmptyList lists the snapshots of fs with their `written` property,
+// i.e. the amount of data written between the previous snapshot (of any name) and the snapshot.
+func snapshotsEmptyList(ctx context.Context, fs string) ([]snapshotsEmptyEntry, error) {
+	lines, err := zfs.ZFSList(ctx, []string{"name", "createtxg", "written"}, "-t", "snapshot", "-d", "1", fs)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list snapshots")
+	}
+	snaps := make([]snapshotsEmptyEntry, 0, len(lines))
+	for _, l := range lines {
+		i := strings.IndexByte(l[0], '@')
+		if i < 0 {
+			return nil, fmt.Errorf("unexpected snapshot name %q", l[0])
+		}
+		e := snapshotsEmptyEntry{Name: l[0][i+1:]}
+		if e.CreateTXG, err = strconv.ParseUint(l[1], 10, 64); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse createtxg of %q", l[0])
+		}
+		if e.Written, err = strconv.ParseUint(l[2], 10, 64); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse written of %q", l[0])
+		}
+		snaps = append(snaps, e)
+	}
+	return snaps, nil
+}
+
+// findEmptySnapshotRuns returns the runs of at least minRun consecutive snapshots,
+// ordered by createtxg, that have no data written since their predecessor.
+// The oldest snapshot is never part of a run because its `written` is relative to the filesystem's creation.
+func findEmptySnapshotRuns(snaps []snapshotsEmptyEntry, minRun int) []SnapshotsEmptyRun {
+	sorted := make([]snapshotsEmptyEntry, len(snaps))
+	copy(sorted, snaps)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreateTXG < sorted[j].CreateTXG
+	})
+	var runs []SnapshotsEmptyRun
+	start := -1
+	endRun := func(end int) {
+		if start >= 0 && end-start >= minRun {
+			runs = append(runs, SnapshotsEmptyRun{From: sorted[start].Name, To: sorted[end-1].Name, Count: end - start})
+		}
+		start = -1
+	}
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Written != 0 {
+			endRun(i)
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	endRun(len(sorted))
+	return runs
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindEmptySnapshotRuns(t *testing.T) {
+	snap := func(name string, txg, written uint64) snapshotsEmptyEntry {
+		return snapshotsEmptyEntry{Name: name, CreateTXG: txg, Written: written}
+	}
+	// unordered input, the oldest snapshot's written = 0 must not count
+	snaps := []snapshotsEmptyEntry{
+		snap("c", 3, 0), snap("a", 1, 0), snap("b", 2, 0), snap("d", 4, 0),
+		snap("e", 5, 4096),
+		snap("f", 6, 0), snap("g", 7, 0),
+		snap("h", 8, 4096),
+		snap("i", 9, 0), snap("j", 10, 0), snap("k", 11, 0), snap("l", 12, 0),
+	}
+
+	runs := findEmptySnapshotRuns(snaps, 3)
+	assert.Equal(t, []SnapshotsEmptyRun{
+		{From: "b", To: "d", Count: 3},
+		{From: "i", To: "l", Count: 4},
+	}, runs)
+	assert.Equal(t, "a", snaps[1].Name, "input must not be modified")
+
+	assert.Len(t, findEmptySnapshotRuns(snaps, 2), 3)
+	assert.Len(t, findEmptySnapshotRuns(snaps, 5), 0)
+	assert.Empty(t, findEmptySnapshotRuns(snaps[:1], 1))
+	assert.Empty(t, findEmptySnapshotRuns(nil, 1))
+}
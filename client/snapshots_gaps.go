@@ -0,0 +1,391 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/client/status/viewmodel"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var snapshotsGapsFlags struct {
+	Prefix   string
+	Interval time.Duration
+	Factor   float64
+	Since    time.Duration
+	All      bool
+	Sizes    bool
+	Json     bool
+}
+
+var snapshotsCmdGaps = &cli.Subcommand{
+	Use: "gaps JOB",
+	Example: `
+	gaps prod-push
+	gaps --factor 3 'push-*'              # all jobs whose name matches the shell pattern
+	gaps --prefix zrepl_ --interval 1h manual-push
+	gaps --since 72h prod-push             # only the snapshots of the last 3 days`,
+	Short: "find gaps between the snapshots of a push, source or snap job that exceed a multiple of the snapshotting interval",
+	Run:   doSnapshotsGaps,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringVar(&snapshotsGapsFlags.Prefix, "prefix", "", "only consider snapshots with this prefix (default: the prefix of the job's periodic snapshotting)")
+		f.DurationVar(&snapshotsGapsFlags.Interval, "interval", 0, "expected interval between snapshots (default: the interval of the job's periodic snapshotting)")
+		f.Float64Var(&snapshotsGapsFlags.Factor, "factor", 2, "report spacings between consecutive snapshots that exceed factor * interval")
+		f.DurationVar(&snapshotsGapsFlags.Since, "since", 0, "only check the snapshots taken within this duration before now (default: the time span in which the job's local keep rules keep every snapshot)")
+		f.BoolVar(&snapshotsGapsFlags.All, "all", false, "check all snapshots, including those that the job's grid keep rules have thinned out")
+		f.BoolVar(&snapshotsGapsFlags.Sizes, "sizes", false, "also get the space accounting properties of the oldest and latest snapshot and of the filesystem (one zfs get per snapshot and filesystem)")
+		f.BoolVar(&snapshotsGapsFlags.Json, "json", false, "emit JSON")
+	},
+}
+
+type SnapshotsGap struct {
+	// the snapshots before and after the gap
+	From, To         string
+	FromDate, ToDate time.Time
+	Duration         time.Duration
+	// the job uses skip_if_unchanged, so the gap may consist of intervals in which the filesystem was unchanged,
+	// such gaps don't make the command fail
+	MaybeSkipped bool `json:",omitempty"`
+}
+
+type SnapshotsGapsSnapshot struct {
+	Name string
+	Date time.Time
+	// only with --sizes
+	Sizes *SnapshotsGapsSnapshotSizes `json:",omitempty"`
+}
+
+// SnapshotsGapsSnapshotSizes are the values of the snapshot's properties of the same name, in bytes.
+type SnapshotsGapsSnapshotSizes struct {
+	Used, Referenced, Written, LogicalReferenced uint64
+}
+
+type SnapshotsGapsFilesystem struct {
+	Job        string
+	Filesystem string
+	Prefix     string
+	Interval   time.Duration
+	// only snapshots taken after Since are checked, zero if all snapshots are checked
+	Since time.Time
+	Error string `json:",omitempty"`
+	Gaps  []SnapshotsGap
+	// nil if there are no snapshots with Prefix
+	Oldest, Latest *SnapshotsGapsSnapshot `json:",omitempty"`
+	// the logicalused property of the filesystem in bytes, only with --sizes
+	LogicalUsed *uint64 `json:",omitempty"`
+}
+
+func doSnapshotsGaps(ctx context.Context, sc *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expecting exactly one positional argument: the job name or a shell pattern matching job names")
+	}
+	if snapshotsGapsFlags.Factor <= 1 {
+		return errors.New("--factor must be greater than 1")
+	}
+	if snapshotsGapsFlags.Interval < 0 {
+		return errors.New("--interval must be positive")
+	}
+	if snapshotsGapsFlags.Since < 0 {
+		return errors.New("--since must be positive")
+	}
+	if snapshotsGapsFlags.All && snapshotsGapsFlags.Since != 0 {
+		return errors.New("--all and --since are mutually exclusive")
+	}
+
+	jobConfs, err := sc.Config().JobsMatching(args[0])
+	if err != nil {
+		return err
+	}
+
+	out := []SnapshotsGapsFilesystem{}
+	var failed []string
+	for _, jobConf := range jobConfs {
+		fss, err := snapshotsGapsJob(ctx, jobConf)
+		if err != nil {
+			if len(jobConfs) == 1 {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "job %q: %s\n", jobConf.Name(), err)
+			failed = append(failed, jobConf.Name())
+			continue
+		}
+		out = append(out, fss...)
+	}
+
+	if snapshotsGapsFlags.Json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	} else {
+		lastJob := ""
+		for _, fs := range out {
+			if len(jobConfs) > 1 && fs.Job != lastJob {
+				fmt.Printf("job %q:\n", fs.Job)
+				lastJob = fs.Job
+			}
+			if fs.Error != "" {
+				fmt.Printf("%s: error: %s\n", fs.Filesystem, fs.Error)
+				continue
+			}
+			if len(fs.Gaps) == 0 && !snapshotsGapsFlags.Sizes {
+				continue
+			}
+			fmt.Printf("%s\n", fs.Filesystem)
+			for _, g := range fs.Gaps {
+				note := ""
+				if g.MaybeSkipped {
+					note = " (may be skipped by skip_if_unchanged)"
+				}
+				fmt.Printf("  %s between @%s (%s) and @%s (%s)%s\n",
+					g.Duration, g.From, g.FromDate.Format(time.RFC3339), g.To, g.ToDate.Format(time.RFC3339), note)
+			}
+			if snapshotsGapsFlags.Sizes {
+				if fs.LogicalUsed != nil {
+					fmt.Printf("  logicalused %s\n", viewmodel.ByteCountBinary(int64(*fs.LogicalUsed)))
+				}
+				printSnapshotsGapsSnapshot("oldest", fs.Oldest)
+				printSnapshotsGapsSnapshot("latest", fs.Latest)
+			}
+		}
+	}
+
+	gaps := countFailingSnapshotsGaps(out)
+	hadErr := false
+	for _, fs := range out {
+		hadErr = hadErr || fs.Error != ""
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("cannot check %d of %d jobs: %s", len(failed), len(jobConfs), strings.Join(failed, ", "))
+	}
+	if hadErr {
+		return errors.New("there were errors listing the snapshots of some filesystems")
+	}
+	if gaps > 0 {
+		return errors.Errorf("found %d gaps", gaps)
+	}
+	return nil
+}
+
+// countFailingSnapshotsGaps returns the number of gaps that make the command fail, i.e., that are not MaybeSkipped.
+func countFailingSnapshotsGaps(fss []SnapshotsGapsFilesystem) (n int) {
+	for _, fs := range fss {
+		for _, g := range fs.Gaps {
+			if !g.MaybeSkipped {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func printSnapshotsGapsSnapshot(what string, s *SnapshotsGapsSnapshot) {
+	if s == nil {
+		return
+	}
+	fmt.Printf("  %s @%s (%s)", what, s.Name, s.Date.Format(time.RFC3339))
+	if s.Sizes != nil {
+		fmt.Printf(" used %s, referenced %s, written %s, logicalreferenced %s",
+			viewmodel.ByteCountBinary(int64(s.Sizes.Used)),
+			viewmodel.ByteCountBinary(int64(s.Sizes.Referenced)),
+			viewmodel.ByteCountBinary(int64(s.Sizes.Written)),
+			viewmodel.ByteCountBinary(int64(s.Sizes.LogicalReferenced)))
+	}
+	fmt.Println()
+}
+
+func snapshotsGapsJob(ctx context.Context, jobConf *config.JobEnum) ([]SnapshotsGapsFilesystem, error) {
+	var fsfConf config.FilesystemsFilter
+	var snapshotting config.SnapshottingEnum
+	var keepRules []config.PruningEnum // nil for source jobs, their snapshots are pruned by the pull job
+	switch c := jobConf.Ret.(type) {
+	case *config.PushJob:
+		fsfConf, snapshotting, keepRules = c.Filesystems, c.Snapshotting, c.Pruning.KeepSender
+	case *config.SourceJob:
+		fsfConf, snapshotting = c.Filesystems, c.Snapshotting
+	case *config.SnapJob:
+		fsfConf, snapshotting, keepRules = c.Filesystems, c.Snapshotting, c.Pruning.Keep
+	default:
+		return nil, fmt.Errorf("job type %T does not take snapshots, use a push, source or snap job", c)
+	}
+
+	prefix, interval := snapshotsGapsFlags.Prefix, snapshotsGapsFlags.Interval
+	skipIfUnchanged := false
+	if periodic, ok := snapshotting.Ret.(*config.SnapshottingPeriodic); ok {
+		skipIfUnchanged = periodic.SkipIfUnchanged
+		if prefix == "" {
+			prefix = periodic.Prefix
+		}
+		if interval == 0 {
+			interval = periodic.Interval
+		}
+	}
+	if prefix == "" || interval == 0 {
+		return nil, errors.New("job does not use periodic snapshotting, specify --prefix and --interval")
+	}
+	maxSpacing := time.Duration(float64(interval) * snapshotsGapsFlags.Factor)
+
+	var since time.Time
+	if snapshotsGapsFlags.Since != 0 {
+		since = time.Now().Add(-snapshotsGapsFlags.Since)
+	} else if window, thinned := unthinnedWindow(keepRules, interval); thinned && !snapshotsGapsFlags.All {
+		since = time.Now().Add(-window)
+	}
+
+	fsf, err := filters.DatasetMapFilterFromConfig(fsfConf)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot build filesystem filter")
+	}
+	fss, err := zfs.ZFSListMapping(ctx, fsf)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list filesystems")
+	}
+
+	out := make([]SnapshotsGapsFilesystem, 0, len(fss))
+	for _, dp := range fss {
+		fs := SnapshotsGapsFilesystem{
+			Job:        jobConf.Name(),
+			Filesystem: dp.ToString(),
+			Prefix:     prefix,
+			Interval:   interval,
+			Since:      since,
+		}
+		versions, err := zfs.ZFSListFilesystemVersions(ctx, dp, zfs.ListFilesystemVersionsOptions{
+			Types:           zfs.Snapshots,
+			ShortnamePrefix: prefix,
+		})
+		if err != nil {
+			fs.Error = err.Error()
+		} else {
+			fs.Gaps = findSnapshotsGaps(versions, maxSpacing, since, skipIfUnchanged)
+			fs.Oldest, fs.Latest = oldestAndLatestSnapshot(versions)
+			if snapshotsGapsFlags.Sizes {
+				if err := snapshotsGapsGetSizes(ctx, &fs); err != nil {
+					fs.Error = err.Error()
+				}
+			}
+		}
+		out = append(out, fs)
+	}
+	return out, nil
+}
+
+// unthinnedWindow returns the time span before now in which keepRules keep every snapshot of a job
+// that snapshots every interval. thinned is false if no grid rule thins out older snapshots,
+// i.e., if all remaining snapshots can be checked for gaps.
+//
+// A grid keeps every snapshot in its leading intervals that keep all or at least as many snapshots as are taken in them.
+// Since a snapshot is kept if any rule keeps it, a last_n rule extends the window.
+func unthinnedWindow(keepRules []config.PruningEnum, interval time.Duration) (window time.Duration, thinned bool) {
+	for _, r := range keepRules {
+		switch v := r.Ret.(type) {
+		case *config.PruneGrid:
+			thinned = true
+			var w time.Duration
+			for i := range v.Grid {
+				gi := &v.Grid[i]
+				if gi.KeepCount() != config.RetentionGridKeepCountAll && time.Duration(gi.KeepCount())*interval < gi.Length() {
+					break
+				}
+				w += gi.Length()
+			}
+			if w > window {
+				window = w
+			}
+		case *config.PruneKeepLastN:
+			if w := time.Duration(v.Count) * interval; w > window {
+				window = w
+			}
+		}
+	}
+	return window, thinned
+}
+
+// findSnapshotsGaps returns the gaps between consecutive versions, ordered by creation time,
+// that are longer than maxSpacing. If since is not zero, only gaps that start after since are returned.
+// maybeSkipped is copied to the returned gaps.
+func findSnapshotsGaps(versions []zfs.FilesystemVersion, maxSpacing time.Duration, since time.Time, maybeSkipped bool) []SnapshotsGap {
+	sorted := make([]zfs.FilesystemVersion, len(versions))
+	copy(sorted, versions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Creation.Before(sorted[j].Creation)
+	})
+	var gaps []SnapshotsGap
+	for i := 1; i < len(sorted); i++ {
+		from, to := sorted[i-1], sorted[i]
+		if from.Creation.Before(since) {
+			continue
+		}
+		if d := to.Creation.Sub(from.Creation); d > maxSpacing {
+			gaps = append(gaps, SnapshotsGap{
+				From:         from.Name,
+				To:           to.Name,
+				FromDate:     from.Creation,
+				ToDate:       to.Creation,
+				Duration:     d,
+				MaybeSkipped: maybeSkipped,
+			})
+		}
+	}
+	return gaps
+}
+
+func oldestAndLatestSnapshot(versions []zfs.FilesystemVersion) (oldest, latest *SnapshotsGapsSnapshot) {
+	for _, v := range versions {
+		s := &SnapshotsGapsSnapshot{Name: v.Name, Date: v.Creation}
+		if oldest == nil || v.Creation.Before(oldest.Date) {
+			oldest = s
+		}
+		if latest == nil || !v.Creation.Before(latest.Date) {
+			latest = s
+		}
+	}
+	return oldest, latest
+}
+
+func snapshotsGapsGetSizes(ctx context.Context, fs *SnapshotsGapsFilesystem) error {
+	logicalUsed, err := zfsGetUint64Props(ctx, fs.Filesystem, []string{"logicalused"})
+	if err != nil {
+		return err
+	}
+	fs.LogicalUsed = &logicalUsed[0]
+	for _, s := range []*SnapshotsGapsSnapshot{fs.Oldest, fs.Latest} {
+		if s == nil || s.Sizes != nil { // Oldest == Latest if there is only one snapshot
+			continue
+		}
+		v, err := zfsGetUint64Props(ctx, fs.Filesystem+"@"+s.Name, []string{"used", "referenced", "written", "logicalreferenced"})
+		if err != nil {
+			return err
+		}
+		s.Sizes = &SnapshotsGapsSnapshotSizes{Used: v[0], Referenced: v[1], Written: v[2], LogicalReferenced: v[3]}
+	}
+	return nil
+}
+
+func zfsGetUint64Props(ctx context.Context, path string, props []string) ([]uint64, error) {
+	res, err := zfs.ZFSGetRawAnySource(ctx, path, props)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get properties of %q", path)
+	}
+	values := make([]uint64, len(props))
+	for i, p := range props {
+		values[i], err = strconv.ParseUint(res.Get(p), 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse property %q of %q", p, path)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestFindSnapshotsGaps(t *testing.T) {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	snap := func(name string, h int) zfs.FilesystemVersion {
+		return zfs.FilesystemVersion{Type: zfs.Snapshot, Name: name, Creation: t0.Add(time.Duration(h) * time.Hour)}
+	}
+	// hourly with the daemon down between 2h and 8h, unordered input
+	versions := []zfs.FilesystemVersion{snap("c", 2), snap("a", 0), snap("b", 1), snap("d", 8), snap("e", 9), snap("f", 11)}
+
+	gaps := findSnapshotsGaps(versions, 2*time.Hour, time.Time{}, false)
+	assert.Equal(t, []SnapshotsGap{
+		{From: "c", To: "d", FromDate: t0.Add(2 * time.Hour), ToDate: t0.Add(8 * time.Hour), Duration: 6 * time.Hour},
+	}, gaps)
+	assert.Equal(t, "a", versions[1].Name, "input must not be modified")
+
+	assert.Len(t, findSnapshotsGaps(versions, 90*time.Minute, time.Time{}, false), 2)
+	assert.Empty(t, findSnapshotsGaps(versions[:1], time.Minute, time.Time{}, false))
+	assert.Empty(t, findSnapshotsGaps(nil, time.Minute, time.Time{}, false))
+
+	// the gap between c and d is older than since, e.g., thinned out by a grid keep rule
+	gaps = findSnapshotsGaps(versions, 90*time.Minute, t0.Add(3*time.Hour), false)
+	require.Len(t, gaps, 1)
+	assert.Equal(t, "e", gaps[0].From)
+
+	// skip_if_unchanged: gaps are reported but don't make the command fail
+	gaps = findSnapshotsGaps(versions, 2*time.Hour, time.Time{}, true)
+	require.Len(t, gaps, 1)
+	assert.True(t, gaps[0].MaybeSkipped)
+	fss := []SnapshotsGapsFilesystem{
+		{Gaps: gaps},
+		{Gaps: findSnapshotsGaps(versions, 90*time.Minute, time.Time{}, false)},
+	}
+	assert.Equal(t, 2, countFailingSnapshotsGaps(fss))
+}
+
+func TestUnthinnedWindow(t *testing.T) {
+	grid := func(spec string) config.PruningEnum {
+		intervals, err := config.ParseRetentionIntervalSpec(spec)
+		require.NoError(t, err)
+		return config.PruningEnum{Ret: &config.PruneGrid{Type: "grid", Grid: intervals}}
+	}
+	lastN := func(n int) config.PruningEnum {
+		return config.PruningEnum{Ret: &config.PruneKeepLastN{Type: "last_n", Count: n}}
+	}
+
+	_, thinned := unthinnedWindow(nil, time.Hour)
+	assert.False(t, thinned)
+	_, thinned = unthinnedWindow([]config.PruningEnum{lastN(10)}, time.Hour)
+	assert.False(t, thinned, "last_n does not thin out")
+
+	// with hourly snapshots, 24x1h keeps every snapshot, 14x1d does not
+	w, thinned := unthinnedWindow([]config.PruningEnum{grid("1x1h(keep=all) | 24x1h | 14x1d")}, time.Hour)
+	assert.True(t, thinned)
+	assert.Equal(t, 25*time.Hour, w)
+	w, _ = unthinnedWindow([]config.PruningEnum{grid("1x1h(keep=all) | 24x1h | 14x1d")}, 10*time.Minute)
+	assert.Equal(t, time.Hour, w)
+	w, _ = unthinnedWindow([]config.PruningEnum{grid("2x1d(keep=all) | 14x1d")}, time.Hour)
+	assert.Equal(t, 48*time.Hour, w)
+
+	// a snapshot is kept if any rule keeps it
+	w, _ = unthinnedWindow([]config.PruningEnum{grid("1x1h(keep=all) | 14x1d"), lastN(72)}, time.Hour)
+	assert.Equal(t, 72*time.Hour, w)
+}
+
+func TestOldestAndLatestSnapshot(t *testing.T) {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	snap := func(name string, h int) zfs.FilesystemVersion {
+		return zfs.FilesystemVersion{Type: zfs.Snapshot, Name: name, Creation: t0.Add(time.Duration(h) * time.Hour)}
+	}
+
+	oldest, latest := oldestAndLatestSnapshot([]zfs.FilesystemVersion{snap("b", 1), snap("a", 0), snap("c", 2)})
+	assert.Equal(t, &SnapshotsGapsSnapshot{Name: "a", Date: t0}, oldest)
+	assert.Equal(t, &SnapshotsGapsSnapshot{Name: "c", Date: t0.Add(2 * time.Hour)}, latest)
+
+	oldest, latest = oldestAndLatestSnapshot([]zfs.FilesystemVersion{snap("a", 0)})
+	assert.True(t, oldest == latest, "sizes must only be fetched once")
+
+	oldest, latest = oldestAndLatestSnapshot(nil)
+	assert.Nil(t, oldest)
+	assert.Nil(t, latest)
+}
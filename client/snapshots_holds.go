@@ -0,0 +1,335 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var snapshotsHoldsFlags struct {
+	Json bool
+}
+
+var snapshotsCmdHolds = &cli.Subcommand{
+	Use: "holds JOB",
+	Example: `
+	holds prod-push
+	holds --json 'push-*'   # all jobs whose name matches the shell pattern`,
+	Short: "list the local snapshots of a push or snap job that survive pruning, and why",
+	Run:   doSnapshotsHolds,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVar(&snapshotsHoldsFlags.Json, "json", false, "emit JSON")
+	},
+}
+
+type SnapshotsHoldsReasonKind string
+
+const (
+	SnapshotsHoldsReasonKeepRule          SnapshotsHoldsReasonKind = "keep_rule"
+	SnapshotsHoldsReasonStepHold          SnapshotsHoldsReasonKind = "step_hold"
+	SnapshotsHoldsReasonLastReceivedHold  SnapshotsHoldsReasonKind = "last_received_hold"
+	SnapshotsHoldsReasonPin               SnapshotsHoldsReasonKind = "pin"
+	SnapshotsHoldsReasonUserHold          SnapshotsHoldsReasonKind = "user_hold"
+	SnapshotsHoldsReasonReplicationCursor SnapshotsHoldsReasonKind = "replication_cursor"
+	SnapshotsHoldsReasonLastSnapshot      SnapshotsHoldsReasonKind = "last_snapshot"
+)
+
+type SnapshotsHoldsReason struct {
+	Kind   SnapshotsHoldsReasonKind
+	Detail string
+}
+
+func (r SnapshotsHoldsReason) String() string {
+	switch r.Kind {
+	case SnapshotsHoldsReasonKeepRule:
+		return fmt.Sprintf("keep rule %s", r.Detail)
+	case SnapshotsHoldsReasonStepHold:
+		return fmt.Sprintf("step hold of job %q", r.Detail)
+	case SnapshotsHoldsReasonLastReceivedHold:
+		return fmt.Sprintf("last-received-hold of job %q", r.Detail)
+	case SnapshotsHoldsReasonPin:
+		return fmt.Sprintf("pinned by job %q", r.Detail)
+	case SnapshotsHoldsReasonUserHold:
+		return fmt.Sprintf("user hold %q", r.Detail)
+	case SnapshotsHoldsReasonReplicationCursor:
+		return fmt.Sprintf("replication cursor of job %q", r.Detail)
+	case SnapshotsHoldsReasonLastSnapshot:
+		return "last snapshot of the filesystem (allow_destroy_last is not set)"
+	default:
+		return fmt.Sprintf("%s %s", r.Kind, r.Detail)
+	}
+}
+
+type SnapshotsHoldsSnapshot struct {
+	Name       string
+	Date       time.Time
+	Replicated bool
+	// PlannedDestroy is true if no keep rule keeps the snapshot,
+	// i.e., the remaining Reasons prevent the pruner from destroying it.
+	PlannedDestroy bool
+	Reasons        []SnapshotsHoldsReason
+}
+
+type SnapshotsHoldsFilesystem struct {
+	Job        string
+	Filesystem string
+	SkipReason string `json:",omitempty"`
+	Error      string `json:",omitempty"`
+	Snapshots  []SnapshotsHoldsSnapshot
+}
+
+func doSnapshotsHolds(ctx context.Context, sc *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expecting exactly one positional argument: the job name or a shell pattern matching job names")
+	}
+
+	conf := sc.Config()
+	jobConfs, err := conf.JobsMatching(args[0])
+	if err != nil {
+		return err
+	}
+
+	jobs, err := job.JobsFromConfig(conf)
+	if err != nil {
+		return errors.Wrap(err, "cannot build jobs from config")
+	}
+	jobsByName := make(map[string]job.Job, len(jobs))
+	for _, j := range jobs {
+		jobsByName[j.Name()] = j
+	}
+
+	out := []SnapshotsHoldsFilesystem{}
+	var failed []string
+	for _, jobConf := range jobConfs {
+		fss, err := snapshotsHoldsJob(ctx, jobsByName[jobConf.Name()], jobConf)
+		if err != nil {
+			if len(jobConfs) == 1 {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "job %q: %s\n", jobConf.Name(), err)
+			failed = append(failed, jobConf.Name())
+			continue
+		}
+		out = append(out, fss...)
+	}
+
+	if snapshotsHoldsFlags.Json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	} else {
+		lastJob := ""
+		for _, fs := range out {
+			if len(jobConfs) > 1 && fs.Job != lastJob {
+				fmt.Printf("job %q:\n", fs.Job)
+				lastJob = fs.Job
+			}
+			switch {
+			case fs.SkipReason != "":
+				fmt.Printf("%s: skipped: %s\n", fs.Filesystem, fs.SkipReason)
+				continue
+			case fs.Error != "":
+				fmt.Printf("%s: error: %s\n", fs.Filesystem, fs.Error)
+				continue
+			}
+			fmt.Printf("%s\n", fs.Filesystem)
+			for _, s := range fs.Snapshots {
+				reasons := make([]string, len(s.Reasons))
+				for i, r := range s.Reasons {
+					reasons[i] = r.String()
+				}
+				verb := "kept by"
+				if s.PlannedDestroy {
+					verb = "destroy prevented by"
+				}
+				fmt.Printf("  @%s\t%s %s\n", s.Name, verb, strings.Join(reasons, ", "))
+			}
+		}
+	}
+
+	hadErr := false
+	for _, fs := range out {
+		hadErr = hadErr || fs.Error != ""
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("cannot plan pruning of %d of %d jobs: %s", len(failed), len(jobConfs), strings.Join(failed, ", "))
+	}
+	if hadErr {
+		return errors.New("there were errors in planning the pruning of some filesystems")
+	}
+	return nil
+}
+
+func snapshotsHoldsJob(ctx context.Context, j job.Job, jobConf *config.JobEnum) ([]SnapshotsHoldsFilesystem, error) {
+	var keepRules []config.PruningEnum
+	switch c := jobConf.Ret.(type) {
+	case *config.PushJob:
+		keepRules = c.Pruning.KeepSender
+	case *config.SnapJob:
+		keepRules = c.Pruning.Keep
+	default:
+		return nil, fmt.Errorf("job type %T does not prune local snapshots based on local information, use a push or snap job", c)
+	}
+	if j == nil {
+		panic("job in config but not built by job.JobsFromConfig")
+	}
+	jobID, err := endpoint.MakeJobID(jobConf.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := job.LocalPruner(ctx, j)
+	if err != nil {
+		return nil, err
+	}
+	plans, err := p.Plan()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot plan pruning")
+	}
+
+	out := make([]SnapshotsHoldsFilesystem, 0, len(plans))
+	for _, plan := range plans {
+		fs := SnapshotsHoldsFilesystem{
+			Job:        jobConf.Name(),
+			Filesystem: plan.Filesystem,
+			SkipReason: string(plan.SkipReason),
+			Error:      plan.Error,
+		}
+		if fs.SkipReason == "" && fs.Error == "" {
+			fs.Snapshots, err = snapshotsHoldsExplain(ctx, plan, keepRules, jobID)
+			if err != nil {
+				fs.Error = err.Error()
+			}
+		}
+		out = append(out, fs)
+	}
+	return out, nil
+}
+
+// snapshotsHoldsExplain returns the snapshots of plan that survive pruning,
+// together with the reasons why.
+func snapshotsHoldsExplain(ctx context.Context, plan pruner.FSPlan, keepRules []config.PruningEnum, jobID endpoint.JobID) ([]SnapshotsHoldsSnapshot, error) {
+	dp, err := zfs.NewDatasetPath(plan.Filesystem)
+	if err != nil {
+		return nil, err
+	}
+
+	// only invoke `zfs holds` for snapshots that actually have holds
+	versions, err := zfs.ZFSListFilesystemVersions(ctx, dp, zfs.ListFilesystemVersionsOptions{Types: zfs.Snapshots})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list snapshots")
+	}
+	userRefs := make(map[uint64]uint64, len(versions))
+	for _, v := range versions {
+		if v.UserRefs.Valid {
+			userRefs[v.Guid] = v.UserRefs.Value
+		}
+	}
+
+	cursors, err := endpoint.GetReplicationCursors(ctx, dp, jobID)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get replication cursors")
+	}
+	cursorGuids := make(map[uint64]bool, len(cursors))
+	for _, c := range cursors {
+		cursorGuids[c.Guid] = true
+	}
+
+	var snaps []SnapshotsHoldsSnapshot
+	for _, sp := range plan.Snapshots {
+		s := SnapshotsHoldsSnapshot{
+			Name:           sp.Name,
+			Date:           sp.Date,
+			Replicated:     sp.Replicated,
+			PlannedDestroy: sp.Destroy,
+		}
+		for _, r := range sp.KeptBy {
+			s.Reasons = append(s.Reasons, SnapshotsHoldsReason{
+				Kind:   SnapshotsHoldsReasonKeepRule,
+				Detail: fmt.Sprintf("#%d (%s)", r+1, describeKeepRule(keepRules[r])),
+			})
+		}
+		if userRefs[sp.Guid] > 0 {
+			tags, err := zfs.ZFSHolds(ctx, plan.Filesystem, sp.Name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot list holds of snapshot %q", sp.Name)
+			}
+			for _, tag := range tags {
+				s.Reasons = append(s.Reasons, classifyHoldTag(tag))
+			}
+		}
+		if sp.KeptAsLast {
+			s.Reasons = append(s.Reasons, SnapshotsHoldsReason{Kind: SnapshotsHoldsReasonLastSnapshot})
+		}
+		if cursorGuids[sp.Guid] {
+			s.Reasons = append(s.Reasons, SnapshotsHoldsReason{
+				Kind:   SnapshotsHoldsReasonReplicationCursor,
+				Detail: jobID.String(),
+			})
+		}
+		if s.PlannedDestroy && !snapshotsHoldsHasHold(s.Reasons) {
+			continue // will be destroyed by the pruner
+		}
+		snaps = append(snaps, s)
+	}
+	return snaps, nil
+}
+
+func snapshotsHoldsHasHold(reasons []SnapshotsHoldsReason) bool {
+	for _, r := range reasons {
+		switch r.Kind {
+		case SnapshotsHoldsReasonStepHold, SnapshotsHoldsReasonLastReceivedHold, SnapshotsHoldsReasonPin, SnapshotsHoldsReasonUserHold:
+			return true
+		}
+	}
+	return false
+}
+
+func classifyHoldTag(tag string) SnapshotsHoldsReason {
+	if jobID, err := endpoint.ParseStepHoldTag(tag); err == nil {
+		return SnapshotsHoldsReason{Kind: SnapshotsHoldsReasonStepHold, Detail: jobID.String()}
+	}
+	if jobID, err := endpoint.ParseLastReceivedHoldTag(tag); err == nil {
+		return SnapshotsHoldsReason{Kind: SnapshotsHoldsReasonLastReceivedHold, Detail: jobID.String()}
+	}
+	if jobID, err := endpoint.ParsePinHoldTag(tag); err == nil {
+		return SnapshotsHoldsReason{Kind: SnapshotsHoldsReasonPin, Detail: jobID.String()}
+	}
+	return SnapshotsHoldsReason{Kind: SnapshotsHoldsReasonUserHold, Detail: tag}
+}
+
+func describeKeepRule(r config.PruningEnum) string {
+	switch v := r.Ret.(type) {
+	case *config.PruneKeepNotReplicated:
+		return "not_replicated"
+	case *config.PruneKeepLastN:
+		if v.Regex != "" {
+			return fmt.Sprintf("last_n count=%d regex=%q", v.Count, v.Regex)
+		}
+		return fmt.Sprintf("last_n count=%d", v.Count)
+	case *config.PruneKeepRegex:
+		if v.Negate {
+			return fmt.Sprintf("regex negate=true regex=%q", v.Regex)
+		}
+		return fmt.Sprintf("regex regex=%q", v.Regex)
+	case *config.PruneGrid:
+		return fmt.Sprintf("grid regex=%q", v.Regex)
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,30 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/endpoint"
+)
+
+func TestClassifyHoldTag(t *testing.T) {
+	jobID := endpoint.MustMakeJobID("foo")
+
+	stepTag, err := endpoint.StepHoldTag(jobID)
+	require.NoError(t, err)
+	lrhTag, err := endpoint.LastReceivedHoldTag(jobID)
+	require.NoError(t, err)
+	pinTag, err := endpoint.PinHoldTag(jobID)
+	require.NoError(t, err)
+
+	assert.Equal(t, SnapshotsHoldsReason{Kind: SnapshotsHoldsReasonStepHold, Detail: "foo"}, classifyHoldTag(stepTag))
+	assert.Equal(t, SnapshotsHoldsReason{Kind: SnapshotsHoldsReasonLastReceivedHold, Detail: "foo"}, classifyHoldTag(lrhTag))
+	assert.Equal(t, SnapshotsHoldsReason{Kind: SnapshotsHoldsReasonPin, Detail: "foo"}, classifyHoldTag(pinTag))
+	assert.Equal(t, SnapshotsHoldsReason{Kind: SnapshotsHoldsReasonUserHold, Detail: "backup"}, classifyHoldTag("backup"))
+
+	assert.True(t, snapshotsHoldsHasHold([]SnapshotsHoldsReason{classifyHoldTag("backup")}))
+	assert.True(t, snapshotsHoldsHasHold([]SnapshotsHoldsReason{classifyHoldTag(pinTag)}))
+	assert.False(t, snapshotsHoldsHasHold([]SnapshotsHoldsReason{{Kind: SnapshotsHoldsReasonReplicationCursor, Detail: "foo"}}))
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var snapshotsPinFlags struct {
+	Name string
+}
+
+var snapshotsCmdPin = &cli.Subcommand{
+	Use: "pin JOB FILESYSTEM[@SNAPSHOT]",
+	Example: `
+	pin prod-push pool/db                      # take a new snapshot pool/db@pinned_... and pin it
+	pin prod-push pool/db --name before_upgrade
+	pin prod-push pool/db@zrepl_20200101_000000_000   # pin an existing snapshot`,
+	Short: "protect a snapshot from pruning by placing a hold on it",
+	Run:   doSnapshotsPin,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringVar(&snapshotsPinFlags.Name, "name", "", "name of the new snapshot (default: pinned_ followed by the current UTC time)")
+	},
+}
+
+var snapshotsCmdUnpin = &cli.Subcommand{
+	Use:   "unpin JOB FILESYSTEM@SNAPSHOT",
+	Short: "release the hold placed by `zrepl snapshots pin`",
+	Run:   doSnapshotsUnpin,
+}
+
+// snapshotsPinTarget checks that the dataset fs belongs to the job named jobName
+// and returns the hold tag of the job's pins.
+func snapshotsPinTarget(conf *config.Config, jobName string, fs *zfs.DatasetPath) (tag string, _ error) {
+	jobConf, err := conf.Job(jobName)
+	if err != nil {
+		return "", err
+	}
+	var belongs bool
+	switch c := jobConf.Ret.(type) {
+	case *config.PushJob:
+		belongs, err = snapshotsPinFilterPasses(c.Filesystems, fs)
+	case *config.SourceJob:
+		belongs, err = snapshotsPinFilterPasses(c.Filesystems, fs)
+	case *config.SnapJob:
+		belongs, err = snapshotsPinFilterPasses(c.Filesystems, fs)
+	case *config.PullJob:
+		belongs, err = snapshotsPinBelowRootFS(c.RootFS, fs)
+	case *config.SinkJob:
+		belongs, err = snapshotsPinBelowRootFS(c.RootFS, fs)
+	default:
+		return "", fmt.Errorf("job type %T does not manage snapshots", c)
+	}
+	if err != nil {
+		return "", err
+	}
+	if !belongs {
+		return "", fmt.Errorf("filesystem %q is not managed by job %q", fs.ToString(), jobName)
+	}
+	jobID, err := endpoint.MakeJobID(jobName)
+	if err != nil {
+		return "", err
+	}
+	return endpoint.PinHoldTag(jobID)
+}
+
+func snapshotsPinFilterPasses(in config.FilesystemsFilter, fs *zfs.DatasetPath) (bool, error) {
+	f, err := filters.DatasetMapFilterFromConfig(in)
+	if err != nil {
+		return false, errors.Wrap(err, "cannot build filesystems filter")
+	}
+	return f.Filter(fs)
+}
+
+func snapshotsPinBelowRootFS(rootFS string, fs *zfs.DatasetPath) (bool, error) {
+	var root *zfs.DatasetPath
+	if endpoint.IsClientRootTemplate(rootFS) {
+		t, err := endpoint.ParseClientRootTemplate(rootFS)
+		if err != nil {
+			return false, err
+		}
+		root = t.Prefix()
+	} else {
+		var err error
+		root, err = zfs.NewDatasetPath(rootFS)
+		if err != nil {
+			return false, err
+		}
+	}
+	return fs.HasPrefix(root) && fs.Length() > root.Length(), nil
+}
+
+func doSnapshotsPin(ctx context.Context, sc *cli.Subcommand, args []string) error {
+	if len(args) != 2 {
+		return errors.New("expecting exactly two positional arguments: the job name and FILESYSTEM[@SNAPSHOT]")
+	}
+
+	fsName, snapName := args[1], ""
+	if i := strings.IndexByte(args[1], '@'); i >= 0 {
+		fsName, snapName = args[1][:i], args[1][i+1:]
+		if snapshotsPinFlags.Name != "" {
+			return errors.New("--name cannot be used when pinning an existing snapshot")
+		}
+	}
+	fs, err := zfs.NewDatasetPath(fsName)
+	if err != nil {
+		return errors.Wrapf(err, "invalid filesystem %q", fsName)
+	}
+	tag, err := snapshotsPinTarget(sc.Config(), args[0], fs)
+	if err != nil {
+		return err
+	}
+
+	if snapName == "" {
+		snapName = snapshotsPinFlags.Name
+		if snapName == "" {
+			snapName = "pinned_" + time.Now().UTC().Format("20060102_150405_000")
+		}
+		if err := zfs.ZFSSnapshot(ctx, fs, snapName, false, nil); err != nil {
+			return errors.Wrap(err, "cannot create snapshot")
+		}
+	}
+
+	v, err := zfs.ZFSGetFilesystemVersion(ctx, fs.ToString()+"@"+snapName)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get snapshot %q", snapName)
+	}
+	if err := zfs.ZFSHold(ctx, fs.ToString(), v, tag); err != nil {
+		return errors.Wrap(err, "cannot place hold")
+	}
+	fmt.Printf("pinned %s with hold %q\n", v.ToAbsPath(fs), tag)
+	return nil
+}
+
+func doSnapshotsUnpin(ctx context.Context, sc *cli.Subcommand, args []string) error {
+	if len(args) != 2 {
+		return errors.New("expecting exactly two positional arguments: the job name and FILESYSTEM@SNAPSHOT")
+	}
+
+	fsName, vt, _, err := zfs.DecomposeVersionString(args[1])
+	if err != nil {
+		return errors.Wrapf(err, "invalid snapshot %q", args[1])
+	}
+	if vt != zfs.Snapshot {
+		return errors.Errorf("%q is not a snapshot", args[1])
+	}
+	fs, err := zfs.NewDatasetPath(fsName)
+	if err != nil {
+		return err
+	}
+	tag, err := snapshotsPinTarget(sc.Config(), args[0], fs)
+	if err != nil {
+		return err
+	}
+	if err := zfs.ZFSRelease(ctx, tag, args[1]); err != nil {
+		return errors.Wrap(err, "cannot release hold")
+	}
+	fmt.Printf("unpinned %s\n", args[1])
+	return nil
+}
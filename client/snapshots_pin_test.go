@@ -0,0 +1,30 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestSnapshotsPinBelowRootFS(t *testing.T) {
+	tcs := []struct {
+		rootFS, fs string
+		belongs    bool
+	}{
+		{"pool/sink", "pool/sink/client/data", true},
+		{"pool/sink", "pool/sink", false},
+		{"pool/sink", "pool/other/data", false},
+		{"pool/sink/{client_identity}", "pool/sink/client/data", true},
+		{"pool/sink/{client_identity}", "pool/sink", false},
+	}
+	for _, tc := range tcs {
+		fs, err := zfs.NewDatasetPath(tc.fs)
+		require.NoError(t, err)
+		belongs, err := snapshotsPinBelowRootFS(tc.rootFS, fs)
+		require.NoError(t, err)
+		assert.Equal(t, tc.belongs, belongs, "%s below %s", tc.fs, tc.rootFS)
+	}
+}
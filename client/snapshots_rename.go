@@ -0,0 +1,204 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var snapshotsRenameFlags struct {
+	Apply bool
+	Force bool
+}
+
+var snapshotsCmdRename = &cli.Subcommand{
+	Use: "rename JOB OLD_PREFIX NEW_PREFIX",
+	Example: `
+	rename prod-push old_ new_           # show what would be renamed
+	rename --apply prod-push old_ new_`,
+	Short: "rename the local snapshots of a push, source or snap job's filesystems from one prefix to another",
+	Run:   doSnapshotsRename,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVar(&snapshotsRenameFlags.Apply, "apply", false, "rename the snapshots (default: only show what would be renamed)")
+		f.BoolVar(&snapshotsRenameFlags.Force, "force", false, "also rename snapshots that are the base of a replication (step hold, last-received-hold or replication cursor)")
+	},
+}
+
+type SnapshotsRename struct {
+	Filesystem string
+	From, To   string
+	// why the snapshot is not renamed, empty if it is
+	Refused string `json:",omitempty"`
+}
+
+type snapshotsRenameCandidate struct {
+	Name    string
+	Reasons []SnapshotsHoldsReason
+}
+
+func doSnapshotsRename(ctx context.Context, sc *cli.Subcommand, args []string) error {
+	if len(args) != 3 {
+		return errors.New("expecting exactly three positional arguments: the job name, the old and the new prefix")
+	}
+	jobName, oldPrefix, newPrefix := args[0], args[1], args[2]
+	if oldPrefix == "" || newPrefix == "" {
+		return errors.New("prefixes must not be empty")
+	}
+	if oldPrefix == newPrefix {
+		return errors.New("old and new prefix must differ")
+	}
+
+	jobConf, err := sc.Config().Job(jobName)
+	if err != nil {
+		return err
+	}
+	var fsfConf config.FilesystemsFilter
+	var snapshotting config.SnapshottingEnum
+	switch c := jobConf.Ret.(type) {
+	case *config.PushJob:
+		fsfConf, snapshotting = c.Filesystems, c.Snapshotting
+	case *config.SourceJob:
+		fsfConf, snapshotting = c.Filesystems, c.Snapshotting
+	case *config.SnapJob:
+		fsfConf, snapshotting = c.Filesystems, c.Snapshotting
+	default:
+		return fmt.Errorf("job type %T does not take snapshots, use a push, source or snap job", c)
+	}
+	jobID, err := endpoint.MakeJobID(jobName)
+	if err != nil {
+		return err
+	}
+
+	fsf, err := filters.DatasetMapFilterFromConfig(fsfConf)
+	if err != nil {
+		return errors.Wrap(err, "cannot build filesystem filter")
+	}
+	fss, err := zfs.ZFSListMapping(ctx, fsf)
+	if err != nil {
+		return errors.Wrap(err, "cannot list filesystems")
+	}
+
+	var renamed, refused, failed int
+	for _, dp := range fss {
+		plan, err := snapshotsRenamePlanFilesystem(ctx, dp, jobID, oldPrefix, newPrefix)
+		if err != nil {
+			fmt.Printf("%s: error: %s\n", dp.ToString(), err)
+			failed++
+			continue
+		}
+		for _, r := range plan {
+			if r.Refused != "" {
+				fmt.Printf("%s: not renaming @%s: %s\n", r.Filesystem, r.From, r.Refused)
+				refused++
+				continue
+			}
+			if !snapshotsRenameFlags.Apply {
+				fmt.Printf("%s: would rename @%s to @%s\n", r.Filesystem, r.From, r.To)
+				continue
+			}
+			if err := zfs.ZFSRenameSnapshot(ctx, dp, r.From, r.To); err != nil {
+				fmt.Printf("%s: cannot rename @%s to @%s: %s\n", r.Filesystem, r.From, r.To, err)
+				failed++
+				continue
+			}
+			fmt.Printf("%s: renamed @%s to @%s\n", r.Filesystem, r.From, r.To)
+			renamed++
+		}
+	}
+
+	if periodic, ok := snapshotting.Ret.(*config.SnapshottingPeriodic); ok && periodic.Prefix == oldPrefix {
+		fmt.Printf("hint: job %q still takes snapshots with prefix %q, change `snapshotting.prefix` and the pruning rules' regexes to %q\n", jobName, oldPrefix, newPrefix)
+	}
+	if !snapshotsRenameFlags.Apply {
+		fmt.Println("dry run, pass --apply to rename the snapshots")
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d renames or filesystems failed", failed)
+	}
+	if refused > 0 {
+		return errors.Errorf("refused to rename %d snapshots", refused)
+	}
+	return nil
+}
+
+// snapshotsRenamePlanFilesystem determines the snapshots of dp to rename and whether they are the base of a replication.
+func snapshotsRenamePlanFilesystem(ctx context.Context, dp *zfs.DatasetPath, jobID endpoint.JobID, oldPrefix, newPrefix string) ([]SnapshotsRename, error) {
+	versions, err := zfs.ZFSListFilesystemVersions(ctx, dp, zfs.ListFilesystemVersionsOptions{Types: zfs.Snapshots})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list snapshots")
+	}
+	cursors, err := endpoint.GetReplicationCursors(ctx, dp, jobID)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get replication cursors")
+	}
+	cursorGuids := make(map[uint64]bool, len(cursors))
+	for _, c := range cursors {
+		cursorGuids[c.Guid] = true
+	}
+
+	existing := make(map[string]bool, len(versions))
+	var candidates []snapshotsRenameCandidate
+	for _, v := range versions {
+		existing[v.Name] = true
+		if !strings.HasPrefix(v.Name, oldPrefix) {
+			continue
+		}
+		c := snapshotsRenameCandidate{Name: v.Name}
+		if v.UserRefs.Valid && v.UserRefs.Value > 0 {
+			tags, err := zfs.ZFSHolds(ctx, dp.ToString(), v.Name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot list holds of snapshot %q", v.Name)
+			}
+			for _, tag := range tags {
+				c.Reasons = append(c.Reasons, classifyHoldTag(tag))
+			}
+		}
+		if cursorGuids[v.Guid] {
+			c.Reasons = append(c.Reasons, SnapshotsHoldsReason{Kind: SnapshotsHoldsReasonReplicationCursor, Detail: jobID.String()})
+		}
+		candidates = append(candidates, c)
+	}
+	return planSnapshotRenames(dp.ToString(), candidates, existing, oldPrefix, newPrefix, snapshotsRenameFlags.Force), nil
+}
+
+// planSnapshotRenames replaces oldPrefix with newPrefix in the names of candidates.
+// It refuses to rename a snapshot if the new name exists in existing or, unless force is set,
+// if the snapshot is the base of a replication: the daemon tracks step holds and
+// last-received-holds by snapshot name, and the replication cursor's snapshot is the
+// base of the next incremental send.
+// Replication itself matches snapshots by guid, and holds and cursors stay with the renamed snapshot.
+func planSnapshotRenames(fs string, candidates []snapshotsRenameCandidate, existing map[string]bool, oldPrefix, newPrefix string, force bool) []SnapshotsRename {
+	renames := make([]SnapshotsRename, 0, len(candidates))
+	for _, c := range candidates {
+		r := SnapshotsRename{
+			Filesystem: fs,
+			From:       c.Name,
+			To:         newPrefix + strings.TrimPrefix(c.Name, oldPrefix),
+		}
+		var bases []string
+		for _, reason := range c.Reasons {
+			switch reason.Kind {
+			case SnapshotsHoldsReasonStepHold, SnapshotsHoldsReasonLastReceivedHold, SnapshotsHoldsReasonReplicationCursor:
+				bases = append(bases, reason.String())
+			}
+		}
+		switch {
+		case existing[r.To]:
+			r.Refused = fmt.Sprintf("snapshot @%s already exists", r.To)
+		case len(bases) > 0 && !force:
+			r.Refused = fmt.Sprintf("base of a replication (%s), use --force", strings.Join(bases, ", "))
+		}
+		renames = append(renames, r)
+	}
+	return renames
+}
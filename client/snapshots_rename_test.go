@@ -0,0 +1,32 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanSnapshotRenames(t *testing.T) {
+	candidates := []snapshotsRenameCandidate{
+		{Name: "old_1"},
+		{Name: "old_2", Reasons: []SnapshotsHoldsReason{{Kind: SnapshotsHoldsReasonPin, Detail: "j"}}},
+		{Name: "old_3", Reasons: []SnapshotsHoldsReason{{Kind: SnapshotsHoldsReasonStepHold, Detail: "j"}}},
+		{Name: "old_4", Reasons: []SnapshotsHoldsReason{{Kind: SnapshotsHoldsReasonReplicationCursor, Detail: "j"}}},
+		{Name: "old_5"},
+	}
+	existing := map[string]bool{"old_1": true, "old_2": true, "old_3": true, "old_4": true, "old_5": true, "new_5": true}
+
+	renames := planSnapshotRenames("pool/fs", candidates, existing, "old_", "new_", false)
+	assert.Equal(t, []SnapshotsRename{
+		{Filesystem: "pool/fs", From: "old_1", To: "new_1"},
+		{Filesystem: "pool/fs", From: "old_2", To: "new_2"}, // pins move with the snapshot
+		{Filesystem: "pool/fs", From: "old_3", To: "new_3", Refused: `base of a replication (step hold of job "j"), use --force`},
+		{Filesystem: "pool/fs", From: "old_4", To: "new_4", Refused: `base of a replication (replication cursor of job "j"), use --force`},
+		{Filesystem: "pool/fs", From: "old_5", To: "new_5", Refused: "snapshot @new_5 already exists"},
+	}, renames)
+
+	renames = planSnapshotRenames("pool/fs", candidates, existing, "old_", "new_", true)
+	assert.Empty(t, renames[2].Refused)
+	assert.Empty(t, renames[3].Refused)
+	assert.NotEmpty(t, renames[4].Refused, "--force does not overwrite existing snapshots")
+}
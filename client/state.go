@@ -0,0 +1,101 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var StateCmd = &cli.Subcommand{
+	Use:   "state",
+	Short: "export and import the replication state of a job, e.g. to migrate it to a new host",
+	SetupSubcommands: func() []*cli.Subcommand {
+		return []*cli.Subcommand{
+			stateCmdExport,
+			stateCmdImport,
+		}
+	},
+}
+
+// JobState is the output of `zrepl state export` and the input of `zrepl state import`.
+type JobState struct {
+	Job        string
+	Host       string
+	ExportedAt time.Time
+	// the abstractions of the job, see stateExportTypes
+	Abstractions []JobStateAbstraction
+}
+
+type JobStateAbstraction struct {
+	Type       endpoint.AbstractionType
+	Filesystem string
+	// the guid of the snapshot that the abstraction refers to
+	GUID uint64
+	// for user output only, empty if the snapshot was already destroyed at export time
+	Snapshot string `json:",omitempty"`
+}
+
+func (a JobStateAbstraction) String() string {
+	snap := a.Snapshot
+	if snap == "" {
+		snap = fmt.Sprintf("guid %d", a.GUID)
+	}
+	return fmt.Sprintf("%s %s@%s", a.Type, a.Filesystem, snap)
+}
+
+// the abstractions that survive across invocations and are worth migrating
+// (tentative cursors are only used during a replication, v1 cursors are migrated by `zrepl migrate`)
+var stateExportTypes = endpoint.AbstractionTypeSet{
+	endpoint.AbstractionReplicationCursorBookmarkV2: true,
+	endpoint.AbstractionStepHold:                    true,
+	endpoint.AbstractionLastReceivedHold:            true,
+}
+
+// stateResolveVersion finds the local snapshot that a re-created abstraction a can refer to.
+// Replication cursors can also be created from a bookmark if ZFS supports bookmark cloning.
+func stateResolveVersion(a JobStateAbstraction, versions []zfs.FilesystemVersion) (zfs.FilesystemVersion, error) {
+	var bookmark *zfs.FilesystemVersion
+	for i, v := range versions {
+		if v.Guid != a.GUID {
+			continue
+		}
+		if v.IsSnapshot() {
+			return v, nil
+		}
+		if bookmark == nil {
+			bookmark = &versions[i]
+		}
+	}
+	if bookmark != nil && a.Type == endpoint.AbstractionReplicationCursorBookmarkV2 {
+		return *bookmark, nil
+	}
+	return zfs.FilesystemVersion{}, errors.Errorf("%s: snapshot does not exist", a)
+}
+
+// stateJobPeer returns the connect config of an active job
+// and a function that maps local filesystems to the filesystem names presented by the peer.
+func stateJobPeer(jobConf *config.JobEnum) (connect config.ConnectEnum, peerPath func(fs string) (string, error), ok bool) {
+	switch v := jobConf.Ret.(type) {
+	case *config.PushJob:
+		// the receiver presents the sender's filesystem names
+		return v.Connect, func(fs string) (string, error) { return fs, nil }, true
+	case *config.PullJob:
+		// the local filesystems are below root_fs, the sender's are not
+		return v.Connect, func(fs string) (string, error) {
+			root := strings.TrimSuffix(v.RootFS, "/") + "/"
+			if !strings.HasPrefix(fs, root) {
+				return "", errors.Errorf("filesystem %q is not below root_fs %q", fs, v.RootFS)
+			}
+			return strings.TrimPrefix(fs, root), nil
+		}, true
+	default:
+		return config.ConnectEnum{}, nil, false
+	}
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var stateCmdExport = &cli.Subcommand{
+	Use:   "export JOB",
+	Short: "print the replication cursors and holds of JOB as JSON",
+	Run:   doStateExport,
+}
+
+func doStateExport(ctx context.Context, sc *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expecting exactly one positional argument: the job name")
+	}
+	jobConf, err := sc.Config().Job(args[0])
+	if err != nil {
+		return err
+	}
+	jobID, err := endpoint.MakeJobID(jobConf.Name())
+	if err != nil {
+		return err
+	}
+
+	q := endpoint.ListZFSHoldsAndBookmarksQuery{
+		FS:          endpoint.ListZFSHoldsAndBookmarksQueryFilesystemFilter{Filter: zfs.NoFilter()},
+		What:        stateExportTypes,
+		JobID:       &jobID,
+		Concurrency: 1,
+	}
+	abs, listErrs, err := endpoint.ListAbstractions(ctx, q)
+	if err != nil {
+		return err
+	}
+	if len(listErrs) > 0 {
+		return errors.Wrap(endpoint.ListAbstractionsErrors(listErrs), "cannot list abstractions")
+	}
+
+	state := JobState{
+		Job:          jobConf.Name(),
+		ExportedAt:   time.Now(),
+		Abstractions: make([]JobStateAbstraction, 0, len(abs)),
+	}
+	state.Host, _ = os.Hostname()
+
+	snapNames := make(map[string]map[uint64]string) // fs => guid => snapshot name
+	for _, a := range abs {
+		fs := a.GetFS()
+		if _, ok := snapNames[fs]; !ok {
+			snapNames[fs], err = stateExportSnapshotNames(ctx, fs)
+			if err != nil {
+				return err
+			}
+		}
+		guid := a.GetFilesystemVersion().Guid
+		state.Abstractions = append(state.Abstractions, JobStateAbstraction{
+			Type:       a.GetType(),
+			Filesystem: fs,
+			GUID:       guid,
+			Snapshot:   snapNames[fs][guid],
+		})
+	}
+	sort.SliceStable(state.Abstractions, func(i, j int) bool {
+		return state.Abstractions[i].Filesystem < state.Abstractions[j].Filesystem
+	})
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(state); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d abstractions of job %q\n", len(state.Abstractions), state.Job)
+	return nil
+}
+
+func stateExportSnapshotNames(ctx context.Context, fs string) (map[uint64]string, error) {
+	dp, err := zfs.NewDatasetPath(fs)
+	if err != nil {
+		return nil, err
+	}
+	snaps, err := zfs.ZFSListFilesystemVersions(ctx, dp, zfs.ListFilesystemVersionsOptions{Types: zfs.Snapshots})
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list snapshots of %q", fs)
+	}
+	names := make(map[uint64]string, len(snaps))
+	for _, s := range snaps {
+		names[s.Guid] = s.Name
+	}
+	return names, nil
+}
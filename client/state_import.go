@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/rpc"
+	"github.com/zrepl/zrepl/transport/fromconfig"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var stateImportFlags struct {
+	DryRun        bool
+	SkipPeerCheck bool
+	Timeout       time.Duration
+}
+
+var stateCmdImport = &cli.Subcommand{
+	Use:   "import JOB FILE",
+	Short: "re-create the replication cursors and holds of JOB from the output of `zrepl state export` (FILE - reads stdin)",
+	Run:   doStateImport,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVar(&stateImportFlags.DryRun, "dry-run", false, "only validate FILE against the local snapshots and the peer")
+		f.BoolVar(&stateImportFlags.SkipPeerCheck, "skip-peer-check", false, "do not check that the peer of a push or pull job has the snapshots")
+		f.DurationVar(&stateImportFlags.Timeout, "timeout", 30*time.Second, "timeout for connecting to and querying the peer")
+	},
+}
+
+func doStateImport(ctx context.Context, sc *cli.Subcommand, args []string) error {
+	if len(args) != 2 {
+		return errors.New("expecting exactly two positional arguments: the job name and the file written by `zrepl state export`")
+	}
+	jobConf, err := sc.Config().Job(args[0])
+	if err != nil {
+		return err
+	}
+	jobID, err := endpoint.MakeJobID(jobConf.Name())
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if args[1] != "-" {
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	var state JobState
+	if err := json.NewDecoder(in).Decode(&state); err != nil {
+		return errors.Wrap(err, "cannot decode exported state")
+	}
+	if state.Job != jobConf.Name() {
+		return errors.Errorf("state was exported for job %q, not %q", state.Job, jobConf.Name())
+	}
+	for _, a := range state.Abstractions {
+		if !stateExportTypes[a.Type] {
+			return errors.Errorf("%s: unsupported abstraction type", a)
+		}
+	}
+
+	// validate everything before creating anything
+	var problems []string
+	versions, err := stateImportLocalVersions(ctx, state.Abstractions)
+	if err != nil {
+		return err
+	}
+	resolved := make([]zfs.FilesystemVersion, len(state.Abstractions))
+	for i, a := range state.Abstractions {
+		resolved[i], err = stateResolveVersion(a, versions[a.Filesystem])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("local: %s", err))
+		}
+	}
+	if !stateImportFlags.SkipPeerCheck {
+		peerProblems, err := stateImportCheckPeer(ctx, sc.Config().Global, jobConf, state.Abstractions)
+		if err != nil {
+			return errors.Wrap(err, "cannot check peer (use --skip-peer-check to skip)")
+		}
+		problems = append(problems, peerProblems...)
+	}
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		return errors.Errorf("%d of %d abstractions cannot be imported, nothing was changed", len(problems), len(state.Abstractions))
+	}
+
+	for i, a := range state.Abstractions {
+		if stateImportFlags.DryRun {
+			fmt.Printf("would create %s from %s\n", a, resolved[i].FullPath(a.Filesystem))
+			continue
+		}
+		var created endpoint.Abstraction
+		switch a.Type {
+		case endpoint.AbstractionReplicationCursorBookmarkV2:
+			created, err = endpoint.CreateReplicationCursor(ctx, a.Filesystem, resolved[i], jobID)
+		case endpoint.AbstractionStepHold:
+			created, err = endpoint.HoldStep(ctx, a.Filesystem, resolved[i], jobID)
+		case endpoint.AbstractionLastReceivedHold:
+			created, err = endpoint.CreateLastReceivedHold(ctx, a.Filesystem, resolved[i], jobID)
+		default:
+			panic(a.Type) // checked above
+		}
+		if err != nil {
+			return errors.Wrapf(err, "%s", a)
+		}
+		fmt.Printf("created %s\n", created)
+	}
+	return nil
+}
+
+func stateImportLocalVersions(ctx context.Context, abs []JobStateAbstraction) (map[string][]zfs.FilesystemVersion, error) {
+	versions := make(map[string][]zfs.FilesystemVersion)
+	for _, a := range abs {
+		if _, ok := versions[a.Filesystem]; ok {
+			continue
+		}
+		dp, err := zfs.NewDatasetPath(a.Filesystem)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid filesystem %q", a.Filesystem)
+		}
+		vs, err := zfs.ZFSListFilesystemVersions(ctx, dp, zfs.ListFilesystemVersionsOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot list versions of %q", a.Filesystem)
+		}
+		versions[a.Filesystem] = vs
+	}
+	return versions, nil
+}
+
+// stateImportCheckPeer checks that the peer of a push or pull job has the snapshots that the
+// replication cursors and last-received holds refer to, i.e., that incremental replication
+// can continue from them.
+// Passive jobs and jobs with the local transport cannot connect to their peer and are not checked.
+func stateImportCheckPeer(ctx context.Context, g *config.Global, jobConf *config.JobEnum, abs []JobStateAbstraction) (problems []string, err error) {
+	connect, peerPath, ok := stateJobPeer(jobConf)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "job %q is not a push or pull job, not checking its peer\n", jobConf.Name())
+		return nil, nil
+	}
+	if _, ok := connect.Ret.(*config.LocalConnect); ok {
+		fmt.Fprintf(os.Stderr, "job %q uses the local transport, which only works within the daemon, not checking its peer\n", jobConf.Name())
+		return nil, nil
+	}
+	connecter, err := fromconfig.ConnecterFromConfig(g, connect)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot build connecter")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, stateImportFlags.Timeout)
+	defer cancel()
+	client := rpc.NewClient(connecter, rpc.GetLoggersOrPanic(ctx))
+	defer client.Close()
+
+	peerGUIDs := make(map[string]map[uint64]bool)
+	for _, a := range abs {
+		if a.Type == endpoint.AbstractionStepHold {
+			continue // only relevant for a replication step in progress
+		}
+		pfs, err := peerPath(a.Filesystem)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("peer: %s: %s", a, err))
+			continue
+		}
+		if _, ok := peerGUIDs[pfs]; !ok {
+			res, err := client.ListFilesystemVersions(ctx, &pdu.ListFilesystemVersionsReq{Filesystem: pfs})
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot list versions of %q on peer", pfs)
+			}
+			peerGUIDs[pfs] = make(map[uint64]bool)
+			for _, v := range res.GetVersions() {
+				peerGUIDs[pfs][v.GetGuid()] = true
+			}
+		}
+		if !peerGUIDs[pfs][a.GUID] {
+			problems = append(problems, fmt.Sprintf("peer: %s: %q has no snapshot or bookmark with this guid", a, pfs))
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestStateResolveVersion(t *testing.T) {
+	versions := []zfs.FilesystemVersion{
+		{Type: zfs.Bookmark, Name: "zrepl_CURSOR_G_0000000000000001_J_foo", Guid: 1},
+		{Type: zfs.Snapshot, Name: "a", Guid: 1},
+		{Type: zfs.Bookmark, Name: "b", Guid: 2},
+	}
+	cursor := func(guid uint64) JobStateAbstraction {
+		return JobStateAbstraction{Type: endpoint.AbstractionReplicationCursorBookmarkV2, Filesystem: "pool/fs", GUID: guid}
+	}
+	hold := func(guid uint64) JobStateAbstraction {
+		return JobStateAbstraction{Type: endpoint.AbstractionLastReceivedHold, Filesystem: "pool/fs", GUID: guid}
+	}
+
+	// snapshots are preferred over bookmarks
+	v, err := stateResolveVersion(cursor(1), versions)
+	require.NoError(t, err)
+	assert.Equal(t, "a", v.Name)
+
+	// cursors can be created from bookmarks, holds cannot
+	v, err = stateResolveVersion(cursor(2), versions)
+	require.NoError(t, err)
+	assert.Equal(t, "b", v.Name)
+	_, err = stateResolveVersion(hold(2), versions)
+	assert.Error(t, err)
+
+	_, err = stateResolveVersion(cursor(3), versions)
+	assert.Error(t, err)
+}
+
+func TestStateJobPeer(t *testing.T) {
+	pull := &config.JobEnum{Ret: &config.PullJob{RootFS: "pool/backups"}}
+	_, peerPath, ok := stateJobPeer(pull)
+	require.True(t, ok)
+	p, err := peerPath("pool/backups/tank/data")
+	require.NoError(t, err)
+	assert.Equal(t, "tank/data", p)
+	_, err = peerPath("pool/backupsfoo/data")
+	assert.Error(t, err)
+
+	push := &config.JobEnum{Ret: &config.PushJob{}}
+	_, peerPath, ok = stateJobPeer(push)
+	require.True(t, ok)
+	p, err = peerPath("tank/data")
+	require.NoError(t, err)
+	assert.Equal(t, "tank/data", p)
+
+	_, _, ok = stateJobPeer(&config.JobEnum{Ret: &config.SinkJob{}})
+	assert.False(t, ok)
+}
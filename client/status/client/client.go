@@ -7,26 +7,41 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
 type Client struct {
-	h http.Client
+	h       http.Client
+	timeout time.Duration // per request, 0 means no timeout
 }
 
-func New(network, addr string) (*Client, error) {
-	httpc, err := controlHttpClient(func(_ context.Context) (net.Conn, error) { return net.Dial(network, addr) })
+func New(network, addr string, timeout time.Duration) (*Client, error) {
+	httpc, err := controlHttpClient(func(ctx context.Context) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	})
 	if err != nil {
 		return nil, err
 	}
-	return &Client{httpc}, nil
+	return &Client{httpc, timeout}, nil
+}
+
+func (c *Client) jsonRequestResponse(endpoint string, req interface{}, res interface{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	if c.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), c.timeout)
+	}
+	defer cancel()
+	return jsonRequestResponse(ctx, c.h, endpoint, req, res)
 }
 
 func (c *Client) Status() (s daemon.Status, _ error) {
-	err := jsonRequestResponse(c.h, daemon.ControlJobEndpointStatus,
+	err := c.jsonRequestResponse(daemon.ControlJobEndpointStatus,
 		struct{}{},
 		&s,
 	)
@@ -35,15 +50,20 @@ func (c *Client) Status() (s daemon.Status, _ error) {
 
 func (c *Client) StatusRaw() ([]byte, error) {
 	var r json.RawMessage
-	err := jsonRequestResponse(c.h, daemon.ControlJobEndpointStatus, struct{}{}, &r)
+	err := c.jsonRequestResponse(daemon.ControlJobEndpointStatus, struct{}{}, &r)
 	if err != nil {
 		return nil, err
 	}
 	return r, nil
 }
 
+func (c *Client) ZFSCmds() (r zfscmd.Report, _ error) {
+	err := c.jsonRequestResponse(daemon.ControlJobEndpointZFSCmds, struct{}{}, &r)
+	return r, err
+}
+
 func (c *Client) signal(job, sig string) error {
-	return jsonRequestResponse(c.h, daemon.ControlJobEndpointSignal,
+	return c.jsonRequestResponse(daemon.ControlJobEndpointSignal,
 		struct {
 			Name string
 			Op   string
@@ -63,6 +83,14 @@ func (c *Client) SignalReset(job string) error {
 	return c.signal(job, "reset")
 }
 
+func (c *Client) SignalPause(job string) error {
+	return c.signal(job, "pause")
+}
+
+func (c *Client) SignalResume(job string) error {
+	return c.signal(job, "resume")
+}
+
 func controlHttpClient(dialfunc func(context.Context) (net.Conn, error)) (client http.Client, err error) {
 	return http.Client{
 		Transport: &http.Transport{
@@ -73,14 +101,25 @@ func controlHttpClient(dialfunc func(context.Context) (net.Conn, error)) (client
 	}, nil
 }
 
-func jsonRequestResponse(c http.Client, endpoint string, req interface{}, res interface{}) error {
+func jsonRequestResponse(ctx context.Context, c http.Client, endpoint string, req interface{}, res interface{}) (err error) {
+	defer func() {
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			err = errors.New("daemon not responding: timeout exceeded")
+		}
+	}()
+
 	var buf bytes.Buffer
 	encodeErr := json.NewEncoder(&buf).Encode(req)
 	if encodeErr != nil {
 		return encodeErr
 	}
 
-	resp, err := c.Post("http://unix"+endpoint, "application/json", &buf)
+	httpReq, err := http.NewRequest("POST", "http://unix"+endpoint, &buf)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := c.Do(httpReq.WithContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -92,10 +131,15 @@ func jsonRequestResponse(c http.Client, endpoint string, req interface{}, res in
 		return errors.Errorf("%s", msg.String())
 	}
 
-	decodeError := json.NewDecoder(resp.Body).Decode(&res)
+	var raw json.RawMessage
+	decodeError := json.NewDecoder(resp.Body).Decode(&raw)
 	if decodeError != nil {
 		return decodeError
 	}
-
-	return nil
+	var v daemon.ControlSchemaVersioned
+	_ = json.Unmarshal(raw, &v) // responses of daemons that predate schema versioning may not be objects
+	if err := daemon.CheckControlSchemaVersion(v.SchemaVersion); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, &res)
 }
@@ -21,12 +21,17 @@ type Client interface {
 	StatusRaw() ([]byte, error)
 	SignalWakeup(job string) error
 	SignalReset(job string) error
+	SignalPause(job string) error
+	SignalResume(job string) error
 }
 
 type statusFlags struct {
-	Mode  choices.Choices
-	Job   string
-	Delay time.Duration
+	Mode     choices.Choices
+	Job      string
+	Delay    time.Duration
+	Commands bool
+	Watch    bool
+	Timeout  time.Duration
 }
 
 var statusv2Flags statusFlags
@@ -55,6 +60,9 @@ var Subcommand = &cli.Subcommand{
 		f.Var(&statusv2Flags.Mode, "mode", statusv2Flags.Mode.Usage())
 		f.StringVar(&statusv2Flags.Job, "job", "", "only show specified job (works in \"dump\" and \"interactive\" mode)")
 		f.DurationVarP(&statusv2Flags.Delay, "delay", "d", 1*time.Second, "use -d 3s for 3 seconds delay (minimum delay is 1s)")
+		f.BoolVar(&statusv2Flags.Commands, "commands", false, "list the zfs commands that are currently running, then exit (JSON in \"raw\" mode)")
+		f.BoolVar(&statusv2Flags.Watch, "watch", false, "continuously print a one-line summary per job every --delay, redrawing the screen if stdout is a tty (ignores --mode)")
+		f.DurationVar(&statusv2Flags.Timeout, "timeout", 0, "fail if the daemon does not respond to a request within this duration, e.g. 10s (0 waits indefinitely)")
 	},
 	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
 		return runStatusV2Command(ctx, subcommand.Config(), args)
@@ -63,13 +71,24 @@ var Subcommand = &cli.Subcommand{
 
 func runStatusV2Command(ctx context.Context, config *config.Config, args []string) error {
 
-	c, err := client.New("unix", config.Global.Control.SockPath)
+	c, err := client.New("unix", config.Global.Control.SockPath, statusv2Flags.Timeout)
 	if err != nil {
 		return errors.Wrapf(err, "connect to daemon socket at %q", config.Global.Control.SockPath)
 	}
 
 	mode := statusv2Flags.Mode.Value().(statusv2Mode)
 
+	if statusv2Flags.Commands {
+		if statusv2Flags.Watch {
+			return errors.New("--commands and --watch are mutually exclusive")
+		}
+		return zfsCmds(c, mode == StatusV2ModeRaw)
+	}
+
+	if statusv2Flags.Watch {
+		return watch(c, statusv2Flags)
+	}
+
 	if !isatty.IsTerminal(os.Stdout.Fd()) && mode != StatusV2ModeDump && mode != StatusV2ModeRaw {
 		dumpmode, err := statusv2Flags.Mode.InputForChoice(StatusV2ModeDump)
 		if err != nil {
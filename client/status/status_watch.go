@@ -0,0 +1,223 @@
+package status
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mattn/go-isatty"
+
+	"github.com/zrepl/zrepl/client/status/viewmodel"
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/replication/report"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// ANSI escape sequence that moves the cursor to the top left corner and clears the screen
+const watchClearScreen = "\x1b[H\x1b[2J"
+
+// watch polls the daemon status every flag.Delay and prints a one-line summary per job.
+// If stdout is a terminal, the screen is cleared before each redraw.
+// Otherwise, the summaries are appended to the output, separated by an empty line,
+// so that the output can be piped into a file or a pager.
+func watch(c Client, flag statusFlags) error {
+	delay := flag.Delay
+	if delay < 1*time.Second {
+		delay = 1 * time.Second
+	}
+	tty := isatty.IsTerminal(os.Stdout.Fd())
+	for {
+		var buf bytes.Buffer
+		if tty {
+			buf.WriteString(watchClearScreen)
+		}
+		s, err := c.Status()
+		now := time.Now()
+		fmt.Fprintf(&buf, "%s, refreshing every %s\n\n", now.Format(time.RFC3339), delay)
+		if err != nil {
+			// the daemon might be restarting, keep polling
+			fmt.Fprintf(&buf, "error fetching status: %s\n", err)
+		} else if err := renderWatch(&buf, s, flag.Job, now); err != nil {
+			return err
+		}
+		if !tty {
+			buf.WriteString("\n")
+		}
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		time.Sleep(delay)
+	}
+}
+
+type watchRow struct {
+	state, lastRun, nextRun, bytes, command string
+}
+
+// renderWatch writes a table with one row per job in s to w, or only the row of job if it is not empty.
+func renderWatch(w io.Writer, s daemon.Status, onlyJob string, now time.Time) error {
+	if onlyJob != "" {
+		if _, ok := s.Jobs[onlyJob]; !ok {
+			return fmt.Errorf("job %q not found", onlyJob)
+		}
+	}
+	names := make([]string, 0, len(s.Jobs))
+	for name := range s.Jobs {
+		if onlyJob != "" && name != onlyJob {
+			continue
+		}
+		if daemon.IsInternalJobName(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var cmds []zfscmd.ActiveCommand
+	if s.Global.ZFSCmds != nil {
+		cmds = s.Global.ZFSCmds.Active
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "JOB\tTYPE\tSTATE\tLAST RUN\tNEXT RUN\tBYTES\tCOMMAND\n")
+	for _, name := range names {
+		r := watchJobRow(s.Jobs[name], now)
+		r.command = watchJobCommand(name, cmds)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", name, s.Jobs[name].Type, r.state, r.lastRun, r.nextRun, r.bytes, r.command)
+	}
+	return tw.Flush()
+}
+
+func watchJobRow(st *job.Status, now time.Time) watchRow {
+	r := watchRow{state: "idle", lastRun: "-", nextRun: "-", bytes: "-"}
+
+	var snap *snapper.Report
+	var prunings []*pruner.Report
+	switch js := st.JobSpecific.(type) {
+	case *job.ActiveSideStatus:
+		snap = js.Snapshotting
+		prunings = []*pruner.Report{js.PruningSender, js.PruningReceiver}
+		if rep := js.Replication; rep != nil && len(rep.Attempts) > 0 {
+			r.lastRun = watchReplicationResult(rep, now)
+			latest := rep.Attempts[len(rep.Attempts)-1]
+			if rep.FinishAt.IsZero() {
+				r.state = "replicating"
+				expected, replicated, invalidSizeEstimates := latest.BytesSum()
+				if invalidSizeEstimates {
+					r.bytes = viewmodel.ByteCountBinary(replicated)
+				} else {
+					r.bytes = fmt.Sprintf("%s / %s", viewmodel.ByteCountBinary(replicated), viewmodel.ByteCountBinary(expected))
+					if eta, ok := latest.ETA(now); ok {
+						r.bytes += fmt.Sprintf(" (ETA %s)", eta.Round(time.Second))
+					}
+				}
+			}
+		}
+		if until := js.WaitingForReplicationWindowUntil; until != nil {
+			r.state = "waiting for replication window"
+			r.nextRun = watchRelTime(*until, now)
+		}
+		if js.QueuedSince != nil {
+			r.state = "queued"
+		}
+	case *job.SnapJobStatus:
+		snap = js.Snapshotting
+		prunings = []*pruner.Report{js.Pruning}
+		if js.QueuedSince != nil {
+			r.state = "queued"
+		}
+	case *job.PassiveStatus:
+		snap = js.Snapper
+	}
+
+	if r.state == "idle" {
+		for _, p := range prunings {
+			if p != nil && (p.State == pruner.Plan.String() || p.State == pruner.Exec.String()) {
+				r.state = "pruning"
+			}
+		}
+	}
+	if snap != nil {
+		switch snap.State {
+		case snapper.Snapshotting:
+			if r.state == "idle" {
+				r.state = "snapshotting"
+			}
+		case snapper.SyncUp, snapper.Waiting:
+			if r.nextRun == "-" && !snap.SleepUntil.IsZero() {
+				r.nextRun = watchRelTime(snap.SleepUntil, now)
+			}
+		case snapper.ErrorWait, snapper.SyncUpErrWait:
+			if r.state == "idle" {
+				r.state = "snapshotting error"
+			}
+		}
+		if r.lastRun == "-" {
+			var last time.Time
+			for _, fs := range snap.Progress {
+				if fs.StartAt.After(last) {
+					last = fs.StartAt
+				}
+			}
+			if !last.IsZero() {
+				r.lastRun = watchRelTime(last, now)
+			}
+		}
+	}
+
+	if st.Paused {
+		r.state = "paused"
+	}
+	return r
+}
+
+func watchReplicationResult(rep *report.Report, now time.Time) string {
+	started := watchRelTime(rep.StartAt, now)
+	if rep.FinishAt.IsZero() {
+		return started
+	}
+	switch failed := rep.GetFailedFilesystemsCountInLatestAttempt(); {
+	case failed < 0:
+		return started + " (failed)"
+	case failed > 0:
+		return fmt.Sprintf("%s (%d filesystems failed)", started, failed)
+	default:
+		return started + " (ok)"
+	}
+}
+
+// watchJobCommand returns the longest-running zfs command of the job, or "-" if there is none.
+func watchJobCommand(name string, cmds []zfscmd.ActiveCommand) string {
+	const maxLen = 80
+	for _, cmd := range cmds { // sorted by start time
+		if cmd.JobID != name {
+			continue
+		}
+		str := cmd.String
+		if len(str) > maxLen {
+			str = str[:maxLen-3] + "..."
+		}
+		return fmt.Sprintf("%s (%s)", str, cmd.Runtime.Round(time.Second))
+	}
+	return "-"
+}
+
+func watchRelTime(t, now time.Time) string {
+	d := t.Sub(now).Round(time.Second)
+	switch {
+	case d > 0:
+		return "in " + d.String()
+	case d < 0:
+		return strings.TrimPrefix(d.String(), "-") + " ago"
+	default:
+		return "now"
+	}
+}
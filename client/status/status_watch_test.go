@@ -0,0 +1,76 @@
+package status
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/replication/report"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+func TestRenderWatch(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	replicating := &report.Report{
+		StartAt: now.Add(-2 * time.Minute),
+		Attempts: []*report.AttemptReport{{
+			State: report.AttemptFanOutFSs,
+			Filesystems: []*report.FilesystemReport{{
+				State: report.FilesystemStepping,
+				Steps: []*report.StepReport{{Info: &report.StepInfo{BytesExpected: 2048, BytesReplicated: 1024}}},
+			}},
+		}},
+	}
+	failed := &report.Report{
+		StartAt:  now.Add(-time.Hour),
+		FinishAt: now.Add(-59 * time.Minute),
+		Attempts: []*report.AttemptReport{{State: report.AttemptPlanningError}},
+	}
+
+	s := daemon.Status{
+		Jobs: map[string]*job.Status{
+			"push": {Type: job.TypePush, JobSpecific: &job.ActiveSideStatus{
+				Replication:  replicating,
+				Snapshotting: &snapper.Report{State: snapper.Waiting, SleepUntil: now.Add(8 * time.Minute)},
+			}},
+			"pull": {Type: job.TypePull, JobSpecific: &job.ActiveSideStatus{
+				Replication:   failed,
+				PruningSender: &pruner.Report{State: pruner.Exec.String()},
+			}},
+			"snap": {Type: job.TypeSnap, Paused: true, JobSpecific: &job.SnapJobStatus{
+				Snapshotting: &snapper.Report{State: snapper.Waiting, SleepUntil: now.Add(time.Minute)},
+			}},
+			"_control": {Type: job.TypeInternal},
+		},
+		Global: daemon.GlobalStatus{
+			ZFSCmds: &zfscmd.Report{Active: []zfscmd.ActiveCommand{
+				{String: "zfs send pool/a@1", Runtime: 90 * time.Second, JobID: "push"},
+				{String: "zfs list", Runtime: time.Second},
+			}},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, renderWatch(&buf, s, "", now))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Regexp(t, `^JOB\s+TYPE\s+STATE\s+LAST RUN\s+NEXT RUN\s+BYTES\s+COMMAND$`, lines[0])
+	assert.Regexp(t, `^pull\s+pull\s+pruning\s+1h0m0s ago \(failed\)\s+-\s+-\s+-$`, lines[1])
+	assert.Regexp(t, `^push\s+push\s+replicating\s+2m0s ago\s+in 8m0s\s+1.0 KiB / 2.0 KiB\s+zfs send pool/a@1 \(1m30s\)$`, lines[2])
+	assert.Regexp(t, `^snap\s+snap\s+paused\s+-\s+in 1m0s\s+-\s+-$`, lines[3])
+
+	buf.Reset()
+	require.NoError(t, renderWatch(&buf, s, "snap", now))
+	assert.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), 2)
+
+	assert.Error(t, renderWatch(&buf, s, "nonexistent", now))
+}
@@ -0,0 +1,31 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zrepl/zrepl/client/status/client"
+)
+
+func zfsCmds(c *client.Client, asJSON bool) error {
+	r, err := c.ZFSCmds()
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return json.NewEncoder(os.Stdout).Encode(r)
+	}
+	if len(r.Active) == 0 {
+		fmt.Println("no zfs commands are running")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "STARTED\tRUNTIME\tCOMMAND\n")
+	for _, cmd := range r.Active {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", cmd.StartedAt.Format(time.RFC3339), cmd.Runtime.Round(time.Second), cmd.String)
+	}
+	return w.Flush()
+}
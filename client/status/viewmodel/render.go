@@ -133,6 +133,9 @@ func (j *Job) updateFullDescription(p Params) {
 }
 
 func (j *Job) JobTreeTitle() string {
+	if j.lastStatus != nil && j.lastStatus.Paused {
+		return j.name + " (paused)"
+	}
 	return j.name
 }
 
@@ -147,7 +150,11 @@ func (j *Job) Name() string {
 func drawJob(t *stringbuilder.B, name string, v *job.Status, history *bytesProgressHistory, fsfilter FilterFunc) {
 
 	t.Printf("Job: %s\n", name)
-	t.Printf("Type: %s\n\n", v.Type)
+	t.Printf("Type: %s\n", v.Type)
+	if v.Paused {
+		t.Printf("Paused: yes, no new invocations until `zrepl signal resume %s`\n", name)
+	}
+	t.Printf("\n")
 
 	if v.Type == job.TypePush || v.Type == job.TypePull {
 		activeStatus, ok := v.JobSpecific.(*job.ActiveSideStatus)
@@ -159,7 +166,41 @@ func drawJob(t *stringbuilder.B, name string, v *job.Status, history *bytesProgr
 
 		t.Printf("Replication:")
 		t.AddIndentAndNewline(1)
-		renderReplicationReport(t, activeStatus.Replication, history, fsfilter)
+		if until := activeStatus.WaitingForReplicationWindowUntil; until != nil {
+			t.Printf("Waiting for replication window, opens at %s (in %s)", until.Format(time.RFC3339), time.Until(*until).Round(time.Second))
+			t.Newline()
+		} else if since := activeStatus.QueuedSince; since != nil {
+			t.Printf("Queued: waiting for other jobs (global.max_concurrent_jobs) since %s (%s)", since.Format(time.RFC3339), time.Since(*since).Round(time.Second))
+			t.Newline()
+		} else {
+			renderReplicationReport(t, activeStatus.Replication, history, fsfilter)
+		}
+		if r := activeStatus.Retry; r != nil {
+			scope := "replication and pruning"
+			if r.Scope != "" {
+				scope = string(r.Scope)
+			}
+			t.Printf("Last invocation failed, retry %d/%d of %s at %s (in %s)",
+				r.Attempt, r.MaxAttempts, scope, r.At.Format(time.RFC3339), time.Until(r.At).Round(time.Second))
+			t.Newline()
+		}
+		if cs := activeStatus.ClockSkew; cs != nil && cs.Exceeded {
+			t.Printf("WARNING: the peer's clock differs from ours by %s (±%s, threshold %s, measured at %s)",
+				cs.Skew.Round(time.Millisecond), cs.Uncertainty.Round(time.Millisecond), cs.Threshold, cs.MeasuredAt.Format(time.RFC3339))
+			t.Newline()
+		}
+		for _, rt := range activeStatus.ResumeTokens {
+			if !rt.Stale {
+				continue
+			}
+			snapshot := rt.Snapshot
+			if snapshot == "" {
+				snapshot = "unknown snapshot"
+			}
+			t.Printf("WARNING: the resume token of %s (%s) has not changed since %s (%s, threshold %s)",
+				rt.Filesystem, snapshot, rt.Since.Format(time.RFC3339), time.Since(rt.Since).Round(time.Second), rt.Threshold)
+			t.Newline()
+		}
 		t.AddIndentAndNewline(-1)
 
 		t.Printf("Pruning Sender:")
@@ -188,7 +229,12 @@ func drawJob(t *stringbuilder.B, name string, v *job.Status, history *bytesProgr
 		}
 		t.Printf("Pruning snapshots:")
 		t.AddIndentAndNewline(1)
-		renderPrunerReport(t, snapStatus.Pruning, fsfilter)
+		if since := snapStatus.QueuedSince; since != nil {
+			t.Printf("Queued: waiting for other jobs (global.max_concurrent_jobs) since %s (%s)", since.Format(time.RFC3339), time.Since(*since).Round(time.Second))
+			t.Newline()
+		} else {
+			renderPrunerReport(t, snapStatus.Pruning, fsfilter)
+		}
 		t.AddIndentAndNewline(-1)
 		t.Printf("Snapshotting:")
 		t.AddIndentAndNewline(1)
@@ -227,10 +273,18 @@ func printFilesystemStatus(t *stringbuilder.B, rep *report.FilesystemReport, act
 		sizeEstimationImpreciseNotice = " (step lacks size estimation)"
 	}
 
-	status := fmt.Sprintf("%s (step %d/%d, %s/%s)%s",
+	etaNotice := ""
+	if rep.State == report.FilesystemStepping {
+		if eta, ok := rep.ETA(time.Now()); ok {
+			etaNotice = fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+		}
+	}
+
+	status := fmt.Sprintf("%s (step %d/%d, %s/%s%s)%s",
 		strings.ToUpper(string(rep.State)),
 		rep.CurrentStep, len(rep.Steps),
 		ByteCountBinary(replicated), ByteCountBinary(expected),
+		etaNotice,
 		sizeEstimationImpreciseNotice,
 	)
 
@@ -359,6 +413,9 @@ func renderReplicationReport(t *stringbuilder.B, rep *report.Report, history *by
 		t.Write("Progress: ")
 		t.DrawBar(50, replicated, expected, changeCount)
 		t.Write(fmt.Sprintf(" %s / %s @ %s/s", ByteCountBinary(replicated), ByteCountBinary(expected), ByteCountBinary(rate)))
+		if eta, ok := latest.ETA(time.Now()); ok && latest.FinishAt.IsZero() {
+			t.Write(fmt.Sprintf(", ETA %s", eta.Round(time.Second)))
+		}
 		t.Newline()
 		if containsInvalidSizeEstimates {
 			t.Write("NOTE: not all steps could be size-estimated, total estimate is likely imprecise!")
@@ -483,6 +540,10 @@ func renderPrunerReport(t *stringbuilder.B, r *pruner.Report, fsfilter FilterFun
 
 		pruneRuleActionStr := fmt.Sprintf("(destroy %d of %d snapshots)",
 			len(fs.DestroyList), len(fs.SnapshotList))
+		if len(fs.ConvertList) > 0 {
+			pruneRuleActionStr = fmt.Sprintf("(destroy %d and convert %d to bookmarks of %d snapshots)",
+				len(fs.DestroyList), len(fs.ConvertList), len(fs.SnapshotList))
+		}
 
 		if fs.completed {
 			t.Printf("Completed  %s\n", pruneRuleActionStr)
@@ -554,6 +615,11 @@ func renderSnapperReport(t *stringbuilder.B, r *snapper.Report, fsfilter FilterF
 		case snapper.SnapError:
 			r.duration = dur(fs.DoneAt.Sub(fs.StartAt))
 			r.remainder = fmt.Sprintf("snap name: %q", fs.SnapName)
+		case snapper.SnapSkipped:
+			r.remainder = fmt.Sprintf("unchanged since %q", fs.SnapName)
+		case snapper.SnapDestroyed:
+			r.duration = dur(fs.DoneAt.Sub(fs.StartAt))
+			r.remainder = "filesystem was destroyed during the run"
 		}
 		rows = append(rows, r)
 		if len(r.path) > widths.path {
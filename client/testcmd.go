@@ -18,7 +18,7 @@ import (
 var TestCmd = &cli.Subcommand{
 	Use: "test",
 	SetupSubcommands: func() []*cli.Subcommand {
-		return []*cli.Subcommand{testFilter, testPlaceholder, testDecodeResumeToken}
+		return []*cli.Subcommand{testFilter, testPlaceholder, testDecodeResumeToken, testSnapshotName, testConnect, testSchedule, testReplicationPlan, testGrid}
 	},
 }
 
@@ -30,9 +30,9 @@ var testFilterArgs struct {
 
 var testFilter = &cli.Subcommand{
 	Use:   "filesystems --job JOB [--all | --input INPUT]",
-	Short: "test filesystems filter specified in push or source job",
+	Short: "test filesystems filter specified in push, source or snap job",
 	SetupFlags: func(f *pflag.FlagSet) {
-		f.StringVar(&testFilterArgs.job, "job", "", "the name of the push or source job")
+		f.StringVar(&testFilterArgs.job, "job", "", "the name of the push, source or snap job, or a shell pattern matching several jobs")
 		f.StringVar(&testFilterArgs.input, "input", "", "a filesystem name to test against the job's filters")
 		f.BoolVar(&testFilterArgs.all, "all", false, "test all local filesystems")
 	},
@@ -50,25 +50,28 @@ func runTestFilterCmd(ctx context.Context, subcommand *cli.Subcommand, args []st
 
 	conf := subcommand.Config()
 
-	var confFilter config.FilesystemsFilter
-	job, err := conf.Job(testFilterArgs.job)
+	jobs, err := conf.JobsMatching(testFilterArgs.job)
 	if err != nil {
 		return err
 	}
-	switch j := job.Ret.(type) {
-	case *config.SourceJob:
-		confFilter = j.Filesystems
-	case *config.PushJob:
-		confFilter = j.Filesystems
-	case *config.SnapJob:
-		confFilter = j.Filesystems
-	default:
-		return fmt.Errorf("job type %T does not have filesystems filter", j)
-	}
+	fsfs := make([]zfs.DatasetFilter, len(jobs))
+	for i, job := range jobs {
+		var confFilter config.FilesystemsFilter
+		switch j := job.Ret.(type) {
+		case *config.SourceJob:
+			confFilter = j.Filesystems
+		case *config.PushJob:
+			confFilter = j.Filesystems
+		case *config.SnapJob:
+			confFilter = j.Filesystems
+		default:
+			return fmt.Errorf("job %q: job type %T does not have filesystems filter", job.Name(), j)
+		}
 
-	f, err := filters.DatasetMapFilterFromConfig(confFilter)
-	if err != nil {
-		return fmt.Errorf("filter invalid: %s", err)
+		fsfs[i], err = filters.DatasetMapFilterFromConfig(confFilter)
+		if err != nil {
+			return fmt.Errorf("job %q: filter invalid: %s", job.Name(), err)
+		}
 	}
 
 	var fsnames []string
@@ -95,20 +98,25 @@ func runTestFilterCmd(ctx context.Context, subcommand *cli.Subcommand, args []st
 	}
 
 	hadFilterErr := false
-	for _, in := range fspaths {
-		var res string
-		var errStr string
-		pass, err := f.Filter(in)
-		if err != nil {
-			res = "ERROR"
-			errStr = err.Error()
-			hadFilterErr = true
-		} else if pass {
-			res = "ACCEPT"
-		} else {
-			res = "REJECT"
+	for i, f := range fsfs {
+		if len(jobs) > 1 {
+			fmt.Printf("job %q:\n", jobs[i].Name())
+		}
+		for _, in := range fspaths {
+			var res string
+			var errStr string
+			pass, err := f.Filter(in)
+			if err != nil {
+				res = "ERROR"
+				errStr = err.Error()
+				hadFilterErr = true
+			} else if pass {
+				res = "ACCEPT"
+			} else {
+				res = "REJECT"
+			}
+			fmt.Printf("%s\t%s\t%s\n", res, in.ToString(), errStr)
 		}
-		fmt.Printf("%s\t%s\t%s\n", res, in.ToString(), errStr)
 	}
 
 	if hadFilterErr {
@@ -210,3 +218,31 @@ func runTestDecodeResumeTokenCmd(ctx context.Context, subcommand *cli.Subcommand
 	}
 	return nil
 }
+
+var testSnapshotName = &cli.Subcommand{
+	Use:   "snapshotname NAME...",
+	Short: "check whether names are valid for snapshots created by zrepl (the part after '@')",
+	Example: `
+	snapshotname zrepl_20200101_120000_000`,
+	NoRequireConfig: true,
+	Run:             runTestSnapshotName,
+}
+
+func runTestSnapshotName(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("must specify at least one snapshot name")
+	}
+	hadInvalid := false
+	for _, name := range args {
+		if err := zfs.SnapshotNameCheck(name); err != nil {
+			hadInvalid = true
+			fmt.Printf("INVALID\t%s\t%s\n", name, err)
+		} else {
+			fmt.Printf("VALID\t%s\t\n", name)
+		}
+	}
+	if hadInvalid {
+		return fmt.Errorf("invalid snapshot names")
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/rpc"
+	"github.com/zrepl/zrepl/transport"
+	"github.com/zrepl/zrepl/transport/fromconfig"
+)
+
+var testConnectArgs struct {
+	timeout time.Duration
+}
+
+var testConnect = &cli.Subcommand{
+	Use:   "connect JOB",
+	Short: "test connectivity of a push or pull job to its peer without replicating",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.DurationVar(&testConnectArgs.timeout, "timeout", 30*time.Second, "timeout for each step")
+	},
+	Run: runTestConnect,
+}
+
+func runTestConnect(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.New("must specify exactly one job name or pattern")
+	}
+	jobs, err := subcommand.Config().JobsMatching(args[0])
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, job := range jobs {
+		if len(jobs) > 1 {
+			fmt.Printf("job %q:\n", job.Name())
+		}
+		if err := testConnectJob(ctx, subcommand.Config().Global, job); err != nil {
+			fmt.Printf("FAIL\t%s\n", err)
+			failed = append(failed, job.Name())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("connectivity test failed for job(s) %q", failed)
+	}
+	return nil
+}
+
+func testConnectJob(ctx context.Context, g *config.Global, job *config.JobEnum) error {
+	var connect config.ConnectEnum
+	switch v := job.Ret.(type) {
+	case *config.PushJob:
+		connect = v.Connect
+	case *config.PullJob:
+		connect = v.Connect
+	default:
+		return fmt.Errorf("job %q is not a push or pull job", job.Name())
+	}
+	if _, ok := connect.Ret.(*config.LocalConnect); ok {
+		return errors.New("the local transport only works within the daemon and cannot be tested")
+	}
+
+	connecter, err := fromconfig.ConnecterFromConfig(g, connect)
+	if err != nil {
+		return errors.Wrap(err, "cannot build connecter")
+	}
+
+	// Step 1: dial (and for TLS, handshake) on a raw connection for precise errors.
+	if err := testConnectDial(ctx, connecter); err != nil {
+		return err
+	}
+
+	// Step 2: ping the peer through the RPC layer, like the job does before replication.
+	// This verifies the protocol version handshake and that the peer accepts our client identity.
+	ctx, cancel := context.WithTimeout(ctx, testConnectArgs.timeout)
+	defer cancel()
+	start := time.Now()
+	client := rpc.NewClient(connecter, rpc.GetLoggersOrPanic(ctx))
+	defer client.Close()
+	if err := client.WaitForConnectivity(ctx); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("rpc ping: timed out after %s", testConnectArgs.timeout)
+		}
+		return errors.Wrap(err, "rpc ping")
+	}
+	fmt.Printf("OK\trpc ping\t%s\n", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func testConnectDial(ctx context.Context, connecter transport.Connecter) error {
+	ctx, cancel := context.WithTimeout(ctx, testConnectArgs.timeout)
+	defer cancel()
+
+	start := time.Now()
+	wire, err := connecter.Connect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "dial")
+	}
+	defer wire.Close()
+
+	tlsConn, isTLS := wire.(interface {
+		Handshake() error
+		ConnectionState() tls.ConnectionState
+	})
+	if !isTLS {
+		fmt.Printf("OK\tdial\t%s\n", time.Since(start).Round(time.Millisecond))
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := wire.SetDeadline(deadline); err != nil {
+			return errors.Wrap(err, "set deadline")
+		}
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return errors.Wrap(err, "tls handshake")
+	}
+	var peer string
+	if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+		peer = certs[0].Subject.String()
+	}
+	fmt.Printf("OK\tdial + tls handshake\t%s\tpeer certificate subject: %s\n", time.Since(start).Round(time.Millisecond), peer)
+	return nil
+}
@@ -0,0 +1,256 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/pruning"
+	"github.com/zrepl/zrepl/pruning/retentiongrid"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var testGridArgs struct {
+	job     string
+	dataset string
+	side    string
+	rule    int
+	json    bool
+}
+
+var testGrid = &cli.Subcommand{
+	Use:   "grid --job JOB --dataset DATASET [--side sender|receiver] [--rule N] [--json]",
+	Short: "show how a grid keep rule fits the snapshots of a local dataset into its buckets",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringVar(&testGridArgs.job, "job", "", "the name of the push, pull or snap job")
+		f.StringVar(&testGridArgs.dataset, "dataset", "", "the local dataset whose snapshots are evaluated")
+		f.StringVar(&testGridArgs.side, "side", "", "evaluate keep_sender or keep_receiver (default: the side that is pruned on this host, i.e. sender for push and receiver for pull jobs)")
+		f.IntVar(&testGridArgs.rule, "rule", 0, "the number of the grid rule in the keep list, starting at 1 (default: the first grid rule)")
+		f.BoolVar(&testGridArgs.json, "json", false, "emit json")
+	},
+	Run: runTestGrid,
+}
+
+type TestGridReport struct {
+	Job, Filesystem string
+	// number of the rule in the keep list, starting at 1
+	Rule        int
+	Regex, Grid string
+	// the date of the youngest matching snapshot, the first bucket ends at it
+	Now     time.Time
+	Buckets []TestGridBucket
+	// older than the oldest bucket, not kept
+	TooOld []TestGridSnapshot `json:",omitempty"`
+	// names of the snapshots that do not match Regex, not kept
+	NotMatching []string `json:",omitempty"`
+}
+
+type TestGridBucket struct {
+	// the bucket contains snapshots with YoungerThan < date <= OlderThanOrEq
+	YoungerThan, OlderThanOrEq time.Time
+	// -1 means all
+	KeepCount int
+	// youngest to oldest, the bucket keeps the oldest KeepCount snapshots
+	Keep, Remove []TestGridSnapshot `json:",omitempty"`
+}
+
+type TestGridSnapshot struct {
+	Name string
+	Date time.Time
+}
+
+func runTestGrid(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if testGridArgs.job == "" || testGridArgs.dataset == "" {
+		return errors.New("must specify --job and --dataset")
+	}
+	jobConf, err := subcommand.Config().Job(testGridArgs.job)
+	if err != nil {
+		return err
+	}
+	keepRules, err := testGridKeepRules(jobConf, testGridArgs.side)
+	if err != nil {
+		return err
+	}
+	ruleNum, gridConf, err := testGridSelectRule(keepRules, testGridArgs.rule)
+	if err != nil {
+		return err
+	}
+	rule, err := pruning.NewKeepGrid(gridConf)
+	if err != nil {
+		return errors.Wrapf(err, "cannot build rule #%d", ruleNum)
+	}
+
+	dp, err := zfs.NewDatasetPath(testGridArgs.dataset)
+	if err != nil {
+		return err
+	}
+	versions, err := zfs.ZFSListFilesystemVersions(ctx, dp, zfs.ListFilesystemVersionsOptions{Types: zfs.Snapshots})
+	if err != nil {
+		return errors.Wrap(err, "cannot list snapshots")
+	}
+	snaps := make([]pruning.Snapshot, len(versions))
+	for i, v := range versions {
+		snaps[i] = testGridSnap{name: v.Name, date: v.Creation}
+	}
+
+	notMatching, x := rule.Explain(snaps)
+	report := makeTestGridReport(x, notMatching)
+	report.Job = jobConf.Name()
+	report.Filesystem = dp.ToString()
+	report.Rule = ruleNum
+	report.Regex = gridConf.Regex
+	report.Grid = testGridSpec(gridConf.Grid)
+
+	if testGridArgs.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	printTestGridReport(report)
+	return nil
+}
+
+// testGridKeepRules returns the keep rules of jobConf for side ("sender", "receiver" or "" for the locally pruned side).
+func testGridKeepRules(jobConf *config.JobEnum, side string) ([]config.PruningEnum, error) {
+	switch c := jobConf.Ret.(type) {
+	case *config.PushJob:
+		switch side {
+		case "", "sender":
+			return c.Pruning.KeepSender, nil
+		case "receiver":
+			return c.Pruning.KeepReceiver, nil
+		}
+	case *config.PullJob:
+		switch side {
+		case "", "receiver":
+			return c.Pruning.KeepReceiver, nil
+		case "sender":
+			return c.Pruning.KeepSender, nil
+		}
+	case *config.SnapJob:
+		if side == "" {
+			return c.Pruning.Keep, nil
+		}
+		return nil, errors.New("snap jobs have a single keep list, --side is not applicable")
+	default:
+		return nil, fmt.Errorf("job type %T has no keep rules, use a push, pull or snap job", c)
+	}
+	return nil, fmt.Errorf("invalid --side %q, must be sender or receiver", side)
+}
+
+// testGridSelectRule returns the grid rule with number ruleNum (starting at 1), or the first grid rule if ruleNum is 0.
+func testGridSelectRule(keepRules []config.PruningEnum, ruleNum int) (int, *config.PruneGrid, error) {
+	if ruleNum == 0 {
+		for i, r := range keepRules {
+			if g, ok := r.Ret.(*config.PruneGrid); ok {
+				return i + 1, g, nil
+			}
+		}
+		return 0, nil, errors.New("the keep list has no grid rule")
+	}
+	if ruleNum < 0 || ruleNum > len(keepRules) {
+		return 0, nil, fmt.Errorf("--rule must be between 1 and %d", len(keepRules))
+	}
+	g, ok := keepRules[ruleNum-1].Ret.(*config.PruneGrid)
+	if !ok {
+		return 0, nil, fmt.Errorf("rule #%d is not a grid rule but %s", ruleNum, describeKeepRule(keepRules[ruleNum-1]))
+	}
+	return ruleNum, g, nil
+}
+
+func testGridSpec(intervals config.RetentionIntervalList) string {
+	spec := ""
+	for i := range intervals {
+		if i > 0 {
+			spec += " | "
+		}
+		spec += intervals[i].Length().String()
+		switch kc := intervals[i].KeepCount(); kc {
+		case config.RetentionGridKeepCountAll:
+			spec += "(keep=all)"
+		case 1:
+		default:
+			spec += fmt.Sprintf("(keep=%d)", kc)
+		}
+	}
+	return spec
+}
+
+type testGridSnap struct {
+	name string
+	date time.Time
+}
+
+func (s testGridSnap) Name() string     { return s.name }
+func (s testGridSnap) Replicated() bool { return false } // not relevant to the grid
+func (s testGridSnap) Date() time.Time  { return s.date }
+
+func makeTestGridReport(x retentiongrid.Explanation, notMatching []pruning.Snapshot) TestGridReport {
+	conv := func(es []retentiongrid.Entry) []TestGridSnapshot {
+		if len(es) == 0 {
+			return nil
+		}
+		out := make([]TestGridSnapshot, len(es))
+		for i, e := range es {
+			s := e.(pruning.Snapshot)
+			out[i] = TestGridSnapshot{Name: s.Name(), Date: s.Date()}
+		}
+		return out
+	}
+	r := TestGridReport{
+		Now:    x.Now,
+		TooOld: conv(x.TooOld),
+	}
+	for _, b := range x.Buckets {
+		r.Buckets = append(r.Buckets, TestGridBucket{
+			YoungerThan:   b.YoungerThan,
+			OlderThanOrEq: b.OlderThanOrEq,
+			KeepCount:     b.KeepCount,
+			Keep:          conv(b.Keep),
+			Remove:        conv(b.Remove),
+		})
+	}
+	for _, s := range notMatching {
+		r.NotMatching = append(r.NotMatching, s.Name())
+	}
+	return r
+}
+
+func printTestGridReport(r TestGridReport) {
+	fmt.Printf("job %q, filesystem %s, rule #%d: grid %q, regex %q\n", r.Job, r.Filesystem, r.Rule, r.Grid, r.Regex)
+	if len(r.Buckets) == 0 {
+		fmt.Printf("no snapshots match the regex, the rule keeps none\n")
+	} else {
+		fmt.Printf("buckets end at the youngest matching snapshot, %s\n", r.Now.Format(time.RFC3339))
+	}
+	for i, b := range r.Buckets {
+		keep := "all"
+		if b.KeepCount != config.RetentionGridKeepCountAll {
+			keep = fmt.Sprintf("the oldest %d", b.KeepCount)
+		}
+		fmt.Printf("bucket %d: (%s, %s], keeps %s\n", i+1, b.YoungerThan.Format(time.RFC3339), b.OlderThanOrEq.Format(time.RFC3339), keep)
+		if len(b.Keep) == 0 && len(b.Remove) == 0 {
+			fmt.Printf("\t(empty)\n")
+		}
+		for _, s := range b.Remove {
+			fmt.Printf("\tdrop @%s\t%s\tthe bucket keeps only %d older snapshots\n", s.Name, s.Date.Format(time.RFC3339), b.KeepCount)
+		}
+		for _, s := range b.Keep {
+			fmt.Printf("\tkeep @%s\t%s\n", s.Name, s.Date.Format(time.RFC3339))
+		}
+	}
+	for _, s := range r.TooOld {
+		fmt.Printf("drop @%s\t%s\tolder than the oldest bucket\n", s.Name, s.Date.Format(time.RFC3339))
+	}
+	for _, n := range r.NotMatching {
+		fmt.Printf("drop @%s\tdoes not match the regex\n", n)
+	}
+	fmt.Printf("note: other keep rules of the job may still keep the snapshots that this rule drops\n")
+}
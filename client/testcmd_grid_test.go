@@ -0,0 +1,62 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/pruning"
+)
+
+func TestTestGridReport(t *testing.T) {
+	intervals, err := config.ParseRetentionIntervalSpec("1x1h(keep=all) | 2x1h")
+	require.NoError(t, err)
+	gridConf := &config.PruneGrid{Regex: "^zrepl_", Grid: intervals}
+	keepRules := []config.PruningEnum{
+		{Ret: &config.PruneKeepNotReplicated{}},
+		{Ret: gridConf},
+	}
+
+	num, g, err := testGridSelectRule(keepRules, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, num)
+	assert.Same(t, gridConf, g)
+	_, _, err = testGridSelectRule(keepRules, 1)
+	assert.Error(t, err, "not a grid rule")
+	_, _, err = testGridSelectRule(keepRules, 3)
+	assert.Error(t, err)
+	assert.Equal(t, "1h0m0s(keep=all) | 1h0m0s | 1h0m0s", testGridSpec(intervals))
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	snap := func(name string, ago time.Duration) pruning.Snapshot {
+		return testGridSnap{name: name, date: now.Add(-ago)}
+	}
+	rule, err := pruning.NewKeepGrid(gridConf)
+	require.NoError(t, err)
+	notMatching, x := rule.Explain([]pruning.Snapshot{
+		snap("zrepl_a", 0),
+		snap("zrepl_b", 30*time.Minute),
+		snap("zrepl_c", 70*time.Minute),
+		snap("zrepl_d", 80*time.Minute),
+		snap("zrepl_e", 5*time.Hour),
+		snap("manual", time.Hour),
+	})
+	r := makeTestGridReport(x, notMatching)
+
+	gs := func(name string, ago time.Duration) []TestGridSnapshot {
+		return []TestGridSnapshot{{Name: name, Date: now.Add(-ago)}}
+	}
+	assert.Equal(t, now, r.Now)
+	assert.Equal(t, []TestGridBucket{
+		{YoungerThan: now.Add(-time.Hour), OlderThanOrEq: now, KeepCount: -1,
+			Keep: append(gs("zrepl_a", 0), gs("zrepl_b", 30*time.Minute)...)},
+		{YoungerThan: now.Add(-2 * time.Hour), OlderThanOrEq: now.Add(-time.Hour), KeepCount: 1,
+			Keep: gs("zrepl_d", 80*time.Minute), Remove: gs("zrepl_c", 70*time.Minute)},
+		{YoungerThan: now.Add(-3 * time.Hour), OlderThanOrEq: now.Add(-2 * time.Hour), KeepCount: 1},
+	}, r.Buckets)
+	assert.Equal(t, gs("zrepl_e", 5*time.Hour), r.TooOld)
+	assert.Equal(t, []string{"manual"}, r.NotMatching)
+}
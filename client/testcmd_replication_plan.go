@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/client/status/viewmodel"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+var testReplicationPlanArgs struct {
+	timeout time.Duration
+}
+
+var testReplicationPlan = &cli.Subcommand{
+	Use:   "replication-plan JOB",
+	Short: "compute the replication plan of a push or pull job without replicating and print it in Graphviz DOT format",
+	Example: `
+	replication-plan prod-push | dot -Tsvg > plan.svg`,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.DurationVar(&testReplicationPlanArgs.timeout, "timeout", 10*time.Minute, "timeout for planning each job")
+	},
+	Run: runTestReplicationPlan,
+}
+
+type replicationPlanJob struct {
+	Job         string
+	Filesystems []job.ReplicationPlanFilesystem
+}
+
+func runTestReplicationPlan(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.New("must specify exactly one job name or pattern")
+	}
+	conf := subcommand.Config()
+	jobConfs, err := conf.JobsMatching(args[0])
+	if err != nil {
+		return err
+	}
+	for _, jobConf := range jobConfs {
+		var connect config.ConnectEnum
+		switch v := jobConf.Ret.(type) {
+		case *config.PushJob:
+			connect = v.Connect
+		case *config.PullJob:
+			connect = v.Connect
+		default:
+			return fmt.Errorf("job %q is not a push or pull job", jobConf.Name())
+		}
+		if _, ok := connect.Ret.(*config.LocalConnect); ok {
+			return fmt.Errorf("job %q: the local transport only works within the daemon", jobConf.Name())
+		}
+	}
+
+	jobs, err := job.JobsFromConfig(conf)
+	if err != nil {
+		return errors.Wrap(err, "cannot build jobs from config")
+	}
+	jobsByName := make(map[string]job.Job, len(jobs))
+	for _, j := range jobs {
+		jobsByName[j.Name()] = j
+	}
+
+	plans := make([]replicationPlanJob, 0, len(jobConfs))
+	for _, jobConf := range jobConfs {
+		j := jobsByName[jobConf.Name()]
+		if j == nil {
+			panic("job in config but not built by job.JobsFromConfig")
+		}
+		planCtx, cancel := context.WithTimeout(ctx, testReplicationPlanArgs.timeout)
+		fss, err := job.PlanReplication(planCtx, j)
+		cancel()
+		if err != nil {
+			return errors.Wrapf(err, "job %q", jobConf.Name())
+		}
+		plans = append(plans, replicationPlanJob{Job: jobConf.Name(), Filesystems: fss})
+	}
+	return writeReplicationPlanDOT(os.Stdout, plans)
+}
+
+// writeReplicationPlanDOT renders plans as a directed graph in which nodes are
+// snapshots or bookmarks and edges are the planned sends, one cluster per filesystem.
+func writeReplicationPlanDOT(w io.Writer, plans []replicationPlanJob) error {
+	var b strings.Builder
+	b.WriteString("digraph \"zrepl replication plan\" {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box];\n")
+	cluster := 0
+	for _, p := range plans {
+		for _, fs := range p.Filesystems {
+			label := fs.Filesystem
+			if len(plans) > 1 {
+				label = fmt.Sprintf("%s: %s", p.Job, fs.Filesystem)
+			}
+			nodeID := func(version string) string {
+				return dotQuote(fmt.Sprintf("%d:%s", cluster, version))
+			}
+			fmt.Fprintf(&b, "\tsubgraph %s {\n", dotQuote(fmt.Sprintf("cluster_%d", cluster)))
+			fmt.Fprintf(&b, "\t\tlabel=%s;\n", dotQuote(label))
+			switch {
+			case fs.Error != "":
+				fmt.Fprintf(&b, "\t\t%s [label=%s, shape=plaintext, fontcolor=red];\n", nodeID(""), dotQuote("planning error: "+fs.Error))
+			case len(fs.Steps) == 0:
+				fmt.Fprintf(&b, "\t\t%s [label=\"up to date\", shape=plaintext];\n", nodeID(""))
+			}
+			declared := make(map[string]bool)
+			declare := func(version string) {
+				if declared[version] {
+					return
+				}
+				declared[version] = true
+				if version == "" {
+					fmt.Fprintf(&b, "\t\t%s [label=\"(full send)\", shape=plaintext];\n", nodeID(version))
+				} else {
+					fmt.Fprintf(&b, "\t\t%s [label=%s];\n", nodeID(version), dotQuote(version))
+				}
+			}
+			for _, s := range fs.Steps {
+				declare(s.From)
+				declare(s.To)
+			}
+			for _, s := range fs.Steps {
+				fmt.Fprintf(&b, "\t\t%s -> %s [%s];\n", nodeID(s.From), nodeID(s.To), replicationPlanEdgeAttrs(s))
+			}
+			b.WriteString("\t}\n")
+			cluster++
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func replicationPlanEdgeAttrs(s *report.StepInfo) string {
+	size := "size unknown"
+	if s.BytesExpected > 0 {
+		size = viewmodel.ByteCountBinary(s.BytesExpected)
+	}
+	notes := []string{size}
+	if s.Encrypted == report.EncryptedTrue {
+		notes = append(notes, "encrypted")
+	}
+	attrs := ""
+	if s.Resumed {
+		notes = append(notes, "resumed")
+		attrs = ", style=dashed"
+	}
+	return fmt.Sprintf("label=%s%s", dotQuote(strings.Join(notes, ", ")), attrs)
+}
+
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
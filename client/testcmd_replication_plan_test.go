@@ -0,0 +1,86 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+func TestWriteReplicationPlanDOT(t *testing.T) {
+	plans := []replicationPlanJob{
+		{
+			Job: "prod-push",
+			Filesystems: []job.ReplicationPlanFilesystem{
+				{
+					Filesystem: "pool/a",
+					Steps: []*report.StepInfo{
+						{From: "#zrepl_1", To: "@zrepl_2", BytesExpected: 2048, Encrypted: report.EncryptedSenderDependent},
+						{From: "@zrepl_2", To: "@zrepl_3", Encrypted: report.EncryptedTrue, Resumed: true},
+					},
+				},
+				{
+					Filesystem: "pool/b",
+					Steps: []*report.StepInfo{
+						{From: "", To: "@zrepl_1", BytesExpected: 100, Encrypted: report.EncryptedSenderDependent},
+					},
+				},
+				{
+					Filesystem: "pool/c",
+					Steps:      []*report.StepInfo{},
+				},
+				{
+					Filesystem: "pool/d",
+					Error:      `conflict: "no common snapshot"`,
+					Steps:      []*report.StepInfo{},
+				},
+			},
+		},
+	}
+
+	var out strings.Builder
+	require.NoError(t, writeReplicationPlanDOT(&out, plans))
+	expect := `digraph "zrepl replication plan" {
+	rankdir=LR;
+	node [shape=box];
+	subgraph "cluster_0" {
+		label="pool/a";
+		"0:#zrepl_1" [label="#zrepl_1"];
+		"0:@zrepl_2" [label="@zrepl_2"];
+		"0:@zrepl_3" [label="@zrepl_3"];
+		"0:#zrepl_1" -> "0:@zrepl_2" [label="2.0 KiB"];
+		"0:@zrepl_2" -> "0:@zrepl_3" [label="size unknown, encrypted, resumed", style=dashed];
+	}
+	subgraph "cluster_1" {
+		label="pool/b";
+		"1:" [label="(full send)", shape=plaintext];
+		"1:@zrepl_1" [label="@zrepl_1"];
+		"1:" -> "1:@zrepl_1" [label="100 B"];
+	}
+	subgraph "cluster_2" {
+		label="pool/c";
+		"2:" [label="up to date", shape=plaintext];
+	}
+	subgraph "cluster_3" {
+		label="pool/d";
+		"3:" [label="planning error: conflict: \"no common snapshot\"", shape=plaintext, fontcolor=red];
+	}
+}
+`
+	assert.Equal(t, expect, out.String())
+
+	// job names are only part of the label if there are several jobs
+	out.Reset()
+	plans = append(plans, replicationPlanJob{Job: "other-pull"})
+	require.NoError(t, writeReplicationPlanDOT(&out, plans))
+	assert.Contains(t, out.String(), `label="prod-push: pool/a";`)
+}
+
+func TestDOTQuote(t *testing.T) {
+	assert.Equal(t, `"a b"`, dotQuote("a b"))
+	assert.Equal(t, `"a\"b\\c\nd"`, dotQuote("a\"b\\c\nd"))
+}
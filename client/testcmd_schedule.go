@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+)
+
+var testScheduleArgs struct {
+	count int
+}
+
+var testSchedule = &cli.Subcommand{
+	Use:   "schedule [JOB]",
+	Short: "print when jobs snapshot and replicate, including the next openings of replication windows",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.IntVar(&testScheduleArgs.count, "count", 5, "number of replication window openings to print")
+	},
+	Run: runTestSchedule,
+}
+
+func runTestSchedule(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) > 1 {
+		return errors.New("must specify at most one job name or pattern")
+	}
+	if testScheduleArgs.count < 1 {
+		return errors.New("--count must be positive")
+	}
+	conf := subcommand.Config()
+	jobs := make([]*config.JobEnum, 0, len(conf.Jobs))
+	if len(args) == 1 {
+		var err error
+		if jobs, err = conf.JobsMatching(args[0]); err != nil {
+			return err
+		}
+	} else {
+		for i := range conf.Jobs {
+			jobs = append(jobs, &conf.Jobs[i])
+		}
+	}
+
+	now := time.Now()
+	for _, j := range jobs {
+		fmt.Printf("job %q:\n", j.Name())
+		if err := printJobSchedule(j, now); err != nil {
+			return errors.Wrapf(err, "job %q", j.Name())
+		}
+	}
+	return nil
+}
+
+func printJobSchedule(j *config.JobEnum, now time.Time) error {
+	switch v := j.Ret.(type) {
+	case *config.PushJob:
+		printSnapshottingSchedule(v.Snapshotting)
+		if v.ReplicationInterval > 0 {
+			fmt.Printf("\treplication: after each snapshotting round and every %s, counted from daemon start\n", v.ReplicationInterval)
+		} else {
+			fmt.Printf("\treplication: after each snapshotting round\n")
+		}
+		return printReplicationWindow(v.ReplicationWindow, now)
+	case *config.PullJob:
+		if v.Interval.Manual {
+			fmt.Printf("\treplication: manual, only on `zrepl signal wakeup` or `zrepl run`\n")
+		} else {
+			fmt.Printf("\treplication: every %s, counted from daemon start\n", v.Interval.Interval)
+		}
+		return printReplicationWindow(v.ReplicationWindow, now)
+	case *config.SourceJob:
+		printSnapshottingSchedule(v.Snapshotting)
+		fmt.Printf("\treplication: when the pull job on the other side connects\n")
+	case *config.SnapJob:
+		printSnapshottingSchedule(v.Snapshotting)
+		fmt.Printf("\tpruning: after each snapshotting round\n")
+	case *config.SinkJob:
+		fmt.Printf("\treplication: when the push job on the other side connects\n")
+	default:
+		return fmt.Errorf("unknown job type %T", v)
+	}
+	return nil
+}
+
+func printSnapshottingSchedule(s config.SnapshottingEnum) {
+	switch v := s.Ret.(type) {
+	case *config.SnapshottingPeriodic:
+		fmt.Printf("\tsnapshotting: every %s with prefix %q, counted from the latest such snapshot\n", v.Interval, v.Prefix)
+	case *config.SnapshottingManual:
+		fmt.Printf("\tsnapshotting: manual\n")
+	default:
+		fmt.Printf("\tsnapshotting: unknown type %T\n", v)
+	}
+}
+
+func printReplicationWindow(in *config.ReplicationWindow, now time.Time) error {
+	if in == nil {
+		fmt.Printf("\treplication window: none, replication is always allowed\n")
+		return nil
+	}
+	openings, err := job.NextReplicationWindowOpenings(in, now, testScheduleArgs.count)
+	if err != nil {
+		return errors.Wrap(err, "replication_window")
+	}
+	fmt.Printf("\treplication window (on_close: %s), next openings:\n", in.OnClose)
+	for _, o := range openings {
+		if o.Close.IsZero() {
+			fmt.Printf("\t\t%s - never closes\n", o.Open.Format(time.RFC3339))
+			continue
+		}
+		fmt.Printf("\t\t%s - %s\n", o.Open.Format(time.RFC3339), o.Close.Format(time.RFC3339))
+	}
+	return nil
+}
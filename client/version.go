@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/pflag"
 
@@ -25,15 +26,16 @@ var VersionCmd = &cli.Subcommand{
 	NoRequireConfig: true,
 	SetupFlags: func(f *pflag.FlagSet) {
 		f.StringVar(&versionArgs.Show, "show", "", "version info to show (client|daemon)")
+		addControlTimeoutFlag(f)
 	},
 	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
 		versionArgs.Config = subcommand.Config()
 		versionArgs.ConfigErr = subcommand.ConfigParsingError()
-		return runVersionCmd()
+		return runVersionCmd(ctx)
 	},
 }
 
-func runVersionCmd() error {
+func runVersionCmd(ctx context.Context) error {
 	args := versionArgs
 
 	if args.Show != "daemon" && args.Show != "client" && args.Show != "" {
@@ -57,12 +59,25 @@ func runVersionCmd() error {
 		}
 
 		var info version.ZreplVersionInformation
-		err = jsonRequestResponse(httpc, daemon.ControlJobEndpointVersion, "", &info)
+		reqCtx, cancel := withControlTimeout(ctx)
+		defer cancel()
+		err = jsonRequestResponse(reqCtx, httpc, daemon.ControlJobEndpointVersion, "", &info)
 		if err != nil {
 			return fmt.Errorf("server: error: %s\n", err)
 		}
 		daemonVersion = &info
 		fmt.Printf("server: %s\n", daemonVersion.String())
+
+		var configInfo daemon.ControlConfigInfo
+		reqCtx, cancel = withControlTimeout(ctx)
+		defer cancel()
+		err = jsonRequestResponse(reqCtx, httpc, daemon.ControlJobEndpointConfig, "", &configInfo)
+		if err != nil {
+			// daemons that predate the endpoint
+			fmt.Fprintf(os.Stderr, "server config: error: %s\n", err)
+		} else {
+			printDaemonConfigInfo(configInfo, args.Config)
+		}
 	}
 
 	if args.Show == "" {
@@ -73,3 +88,20 @@ func runVersionCmd() error {
 
 	return nil
 }
+
+func printDaemonConfigInfo(info daemon.ControlConfigInfo, local *config.Config) {
+	fmt.Printf("server config: hash=%s loaded_at=%s", info.Hash, info.LoadedAt.Format(time.RFC3339))
+	if info.Path != "" {
+		fmt.Printf(" path=%s mtime=%s", info.Path, info.ModTime.Format(time.RFC3339))
+	}
+	fmt.Println()
+
+	localHash, err := local.Hash()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot hash local config: %s\n", err)
+		return
+	}
+	if localHash != info.Hash {
+		fmt.Fprintf(os.Stderr, "WARNING: local config (hash=%s) != daemon's active config, restart zrepl daemon to apply it\n", localHash)
+	}
+}
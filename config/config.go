@@ -1,12 +1,17 @@
 package config
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io/ioutil"
 	"log/syslog"
 	"os"
+	"path"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"time"
 
@@ -19,6 +24,21 @@ import (
 type Config struct {
 	Jobs   []JobEnum `yaml:"jobs"`
 	Global *Global   `yaml:"global,optional,fromdefaults"`
+
+	// named filters that jobs reference from their `filesystems` through `use: NAME`,
+	// already resolved into the jobs' filters when the config is parsed
+	FilterSets map[string]FilesystemsFilter `yaml:"filter_sets,optional"`
+
+	// Warnings about the config that do not prevent its use, e.g., world-readable secret files.
+	Warnings []string `yaml:"-" json:"-"`
+
+	// The file the config was parsed from, nil if it was parsed by ParseConfigBytes.
+	Source *ConfigSource `yaml:"-" json:"-"`
+}
+
+type ConfigSource struct {
+	Path    string
+	ModTime time.Time
 }
 
 func (c *Config) Job(name string) (*JobEnum, error) {
@@ -30,6 +50,26 @@ func (c *Config) Job(name string) (*JobEnum, error) {
 	return nil, fmt.Errorf("job %q not defined in config", name)
 }
 
+// JobsMatching returns the jobs whose names match the shell pattern (see path.Match),
+// in the order in which they are defined in the config.
+// It is an error if no job matches.
+func (c *Config) JobsMatching(pattern string) ([]*JobEnum, error) {
+	var jobs []*JobEnum
+	for i := range c.Jobs {
+		match, err := path.Match(pattern, c.Jobs[i].Name())
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid job name pattern %q", pattern)
+		}
+		if match {
+			jobs = append(jobs, &c.Jobs[i])
+		}
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no job in config matches %q", pattern)
+	}
+	return jobs, nil
+}
+
 type JobEnum struct {
 	Ret interface{}
 }
@@ -60,6 +100,70 @@ type ActiveJob struct {
 	Pruning     PruningSenderReceiver `yaml:"pruning"`
 	Debug       JobDebugSettings      `yaml:"debug,optional"`
 	Replication *Replication          `yaml:"replication,optional,fromdefaults"`
+
+	ReplicationWindow *ReplicationWindow `yaml:"replication_window,optional"`
+
+	MinFreeSpace *MinFreeSpace `yaml:"min_free_space,optional"`
+
+	OnMissingFilesystem string `yaml:"on_missing_filesystem,optional,default=warn"`
+
+	Hooks *ActiveJobHooks `yaml:"hooks,optional,fromdefaults"`
+
+	// labels added to the job's Prometheus metrics
+	MetricLabels map[string]string `yaml:"metric_labels,optional"`
+
+	// overrides global.zfs_priority
+	ZFSPriority *ZFSPriority `yaml:"zfs_priority,optional"`
+
+	// warn if the peer's clock differs by more than this, 0 disables the warning
+	ClockSkewThreshold time.Duration `yaml:"clock_skew_threshold,optional,zeropositive,default=1m"`
+
+	// re-run failed invocations before the next scheduled one, nil disables retries
+	Retry *JobRetry `yaml:"retry,optional"`
+
+	// warn if a receive_resume_token does not change for longer than this, 0 disables the warning
+	StaleResumeTokenThreshold time.Duration `yaml:"stale_resume_token_threshold,optional,zeropositive,default=6h"`
+
+	// names of the jobs whose running invocations an invocation of this job waits for
+	DependsOn []string `yaml:"depends_on,optional"`
+
+	// order of the phases of an invocation, empty means snapshot, replicate, prune
+	PhaseOrder []string `yaml:"phase_order,optional"`
+
+	// path of the JSON report written after each invocation, may contain {job} and {timestamp}
+	ReportFile string `yaml:"report_file,optional"`
+}
+
+type JobRetry struct {
+	MaxAttempts int           `yaml:"max_attempts"`
+	Backoff     time.Duration `yaml:"backoff,optional,positive,default=1m"`
+	MaxBackoff  time.Duration `yaml:"max_backoff,optional,positive,default=30m"`
+}
+
+type ActiveJobHooks struct {
+	// run once per invocation if replication or pruning failed
+	OnError HookList `yaml:"on_error,optional"`
+	// checkpoint pools before replication and pruning, discard the checkpoints if the invocation succeeds
+	PoolCheckpoint *PoolCheckpointHook `yaml:"pool_checkpoint,optional"`
+}
+
+type PoolCheckpointHook struct {
+	Pools []string `yaml:"pools"`
+}
+
+func (j *ActiveJob) GetMinFreeSpace() *MinFreeSpace     { return j.MinFreeSpace }
+func (j *ActiveJob) GetMetricLabels() map[string]string { return j.MetricLabels }
+
+type ReplicationWindow struct {
+	Ranges []ReplicationWindowRange `yaml:"ranges"`
+	// what to do with an in-progress replication when the window closes: continue or pause
+	OnClose string `yaml:"on_close,optional,default=continue"`
+}
+
+type ReplicationWindowRange struct {
+	Start    string   `yaml:"start"` // HH:MM, local time
+	End      string   `yaml:"end"`   // HH:MM, local time, a range with End <= Start extends into the next day
+	Weekdays []string `yaml:"weekdays,optional"`
 }
 
 type PassiveJob struct {
@@ -67,8 +171,19 @@ type PassiveJob struct {
 	Name  string           `yaml:"name"`
 	Serve ServeEnum        `yaml:"serve"`
 	Debug JobDebugSettings `yaml:"debug,optional"`
+
+	MinFreeSpace *MinFreeSpace `yaml:"min_free_space,optional"`
+
+	// labels added to the job's Prometheus metrics
+	MetricLabels map[string]string `yaml:"metric_labels,optional"`
+
+	// overrides global.zfs_priority
+	ZFSPriority *ZFSPriority `yaml:"zfs_priority,optional"`
 }
 
+func (j *PassiveJob) GetMinFreeSpace() *MinFreeSpace     { return j.MinFreeSpace }
+func (j *PassiveJob) GetMetricLabels() map[string]string { return j.MetricLabels }
+
 type SnapJob struct {
 	Type         string            `yaml:"type"`
 	Name         string            `yaml:"name"`
@@ -76,8 +191,17 @@ type SnapJob struct {
 	Debug        JobDebugSettings  `yaml:"debug,optional"`
 	Snapshotting SnapshottingEnum  `yaml:"snapshotting"`
 	Filesystems  FilesystemsFilter `yaml:"filesystems"`
+	MinFreeSpace *MinFreeSpace     `yaml:"min_free_space,optional"`
+	// labels added to the job's Prometheus metrics
+	MetricLabels map[string]string `yaml:"metric_labels,optional"`
+	// overrides global.zfs_priority
+	ZFSPriority *ZFSPriority `yaml:"zfs_priority,optional"`
+	// names of the jobs whose running invocations an invocation of this job waits for
+	DependsOn []string `yaml:"depends_on,optional"`
 }
 
+func (j *SnapJob) GetMetricLabels() map[string]string { return j.MetricLabels }
+
 type SendOptions struct {
 	Encrypted        bool `yaml:"encrypted,optional,default=false"`
 	Raw              bool `yaml:"raw,optional,default=false"`
@@ -87,6 +211,17 @@ type SendOptions struct {
 	Compressed       bool `yaml:"compressed,optional,default=false"`
 	EmbeddedData     bool `yaml:"embbeded_data,optional,default=false"`
 	Saved            bool `yaml:"saved,optional,default=false"`
+
+	EncryptionRootChange string `yaml:"encryption_root_change,optional,default=fail"`
+
+	NegotiateFeatures bool `yaml:"negotiate_features,optional,default=false"`
+}
+
+// SendOptionsOverride replaces the send options of a job for the filesystems it matches.
+// Fields that are not set in Send take their default values, not the values of the job's send options.
+type SendOptionsOverride struct {
+	Filesystems FilesystemsFilter `yaml:"filesystems"`
+	Send        *SendOptions      `yaml:"send,optional,fromdefaults"`
 }
 
 type RecvOptions struct {
@@ -95,17 +230,39 @@ type RecvOptions struct {
 	// Future:
 	// Reencrypt bool `yaml:"reencrypt"`
 
-	Properties *PropertyRecvOptions `yaml:"properties,fromdefaults"`
+	Properties    *PropertyRecvOptions `yaml:"properties,fromdefaults"`
+	ForceRollback *RecvForceRollback   `yaml:"force_rollback,optional,fromdefaults"`
+
+	CreateParents bool `yaml:"create_parents,optional,default=true"`
+	RequireExists bool `yaml:"require_exists,optional,default=false"`
+
+	Placeholder *PlaceholderRecvOptions `yaml:"placeholder,optional,fromdefaults"`
+
+	// keep | noauto | never
+	Mount string `yaml:"mount,optional,default=noauto"`
+}
+
+type PlaceholderRecvOptions struct {
+	Encryption string `yaml:"encryption,optional,default=off"`
+}
+
+type RecvForceRollback struct {
+	Enabled         bool `yaml:"enabled,optional,default=false"`
+	AllowDivergence bool `yaml:"allow_divergence,optional,default=false"`
 }
 
 type Replication struct {
 	Protection  *ReplicationOptionsProtection  `yaml:"protection,optional,fromdefaults"`
 	Concurrency *ReplicationOptionsConcurrency `yaml:"concurrency,optional,fromdefaults"`
+	// steps: zfs send -i per pair of snapshots, single_stream: zfs send -I
+	Intermediates string `yaml:"intermediates,optional,default=steps"`
 }
 
 type ReplicationOptionsProtection struct {
 	Initial     string `yaml:"initial,optional,default=guarantee_resumability"`
 	Incremental string `yaml:"incremental,optional,default=guarantee_resumability"`
+	// abandon the resumable state of transfers that were interrupted longer ago, 0 to keep it
+	ResumableStateMaxAge time.Duration `yaml:"resumable_state_max_age,optional,zeropositive,default=0s"`
 }
 
 type ReplicationOptionsConcurrency struct {
@@ -114,12 +271,40 @@ type ReplicationOptionsConcurrency struct {
 }
 
 func (l *RecvOptions) SetDefault() {
-	*l = RecvOptions{Properties: &PropertyRecvOptions{}}
+	*l = RecvOptions{Properties: &PropertyRecvOptions{}, ForceRollback: &RecvForceRollback{}, CreateParents: true, Placeholder: &PlaceholderRecvOptions{Encryption: "off"}, Mount: "noauto"}
 }
 
 type PropertyRecvOptions struct {
 	Inherit  []zfsprop.Property          `yaml:"inherit,optional"`
-	Override map[zfsprop.Property]string `yaml:"override,optional"`
+	Override PropertyOverrides `yaml:"override,optional"`
+	// exclude properties that zfs recv cannot set from subsequent receives
+	ExcludeRejected bool `yaml:"exclude_rejected,optional,default=false"`
+}
+
+// PropertyOverrides are the property values that zfs recv sets.
+// The values of well-known native properties are validated when the config is parsed,
+// see zfsprop.Property.ValidateValue.
+type PropertyOverrides map[zfsprop.Property]string
+
+var _ yaml.Unmarshaler = (*PropertyOverrides)(nil)
+
+func (o *PropertyOverrides) UnmarshalYAML(u func(interface{}, bool) error) error {
+	var m map[zfsprop.Property]string
+	if err := u(&m, true); err != nil {
+		return err
+	}
+	props := make([]string, 0, len(m))
+	for p := range m {
+		props = append(props, string(p))
+	}
+	sort.Strings(props)
+	for _, p := range props {
+		if err := zfsprop.Property(p).ValidateValue(m[zfsprop.Property(p)]); err != nil {
+			return err
+		}
+	}
+	*o = m
+	return nil
 }
 
 type PushJob struct {
@@ -127,10 +312,16 @@ type PushJob struct {
 	Snapshotting SnapshottingEnum  `yaml:"snapshotting"`
 	Filesystems  FilesystemsFilter `yaml:"filesystems"`
 	Send         *SendOptions      `yaml:"send,fromdefaults,optional"`
+	// the first override whose filesystems match a filesystem replaces Send for that filesystem
+	SendOverrides []*SendOptionsOverride `yaml:"send_overrides,optional"`
+
+	// 0 means that replication only runs after snapshotting
+	ReplicationInterval time.Duration `yaml:"replication_interval,optional,zeropositive,default=0s"`
 }
 
-func (j *PushJob) GetFilesystems() FilesystemsFilter { return j.Filesystems }
-func (j *PushJob) GetSendOptions() *SendOptions      { return j.Send }
+func (j *PushJob) GetFilesystems() FilesystemsFilter        { return j.Filesystems }
+func (j *PushJob) GetSendOptions() *SendOptions             { return j.Send }
+func (j *PushJob) GetSendOverrides() []*SendOptionsOverride { return j.SendOverrides }
 
 type PullJob struct {
 	ActiveJob `yaml:",inline"`
@@ -174,6 +365,64 @@ func (i *PositiveDurationOrManual) UnmarshalYAML(u func(interface{}, bool) error
 	return nil
 }
 
+// MinFreeSpace is either an absolute size (e.g. `10G`) or a percentage (e.g. `5%`).
+// Size suffixes K, M, G, T, P are powers of 1024 and may be followed by `iB` or `B`.
+type MinFreeSpace struct {
+	Bytes   uint64
+	Percent float64
+}
+
+var _ yaml.Unmarshaler = (*MinFreeSpace)(nil)
+
+var (
+	minFreeSpacePercentRegex = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)\s*%\s*$`)
+	minFreeSpaceBytesRegex   = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)\s*(?:([KMGTP])(?:i?B)?|B)?\s*$`)
+)
+
+var minFreeSpaceUnits = map[string]float64{
+	"":  1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+	"P": 1 << 50,
+}
+
+func (m *MinFreeSpace) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
+	var s string
+	if err := u(&s, true); err != nil {
+		return err
+	}
+	*m, err = parseMinFreeSpace(s)
+	return err
+}
+
+func parseMinFreeSpace(s string) (MinFreeSpace, error) {
+	if match := minFreeSpacePercentRegex.FindStringSubmatch(s); match != nil {
+		p, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return MinFreeSpace{}, err
+		}
+		if p <= 0 || p >= 100 {
+			return MinFreeSpace{}, fmt.Errorf("percentage must be in (0%%, 100%%), got %q", s)
+		}
+		return MinFreeSpace{Percent: p}, nil
+	}
+	match := minFreeSpaceBytesRegex.FindStringSubmatch(s)
+	if match == nil {
+		return MinFreeSpace{}, fmt.Errorf("must be a size like `10G` or a percentage like `5%%`, got %q", s)
+	}
+	n, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return MinFreeSpace{}, err
+	}
+	n *= minFreeSpaceUnits[match[2]]
+	if n <= 0 {
+		return MinFreeSpace{}, fmt.Errorf("size must be positive, got %q", s)
+	}
+	return MinFreeSpace{Bytes: uint64(n)}, nil
+}
+
 type SinkJob struct {
 	PassiveJob `yaml:",inline"`
 	RootFS     string       `yaml:"root_fs"`
@@ -189,10 +438,13 @@ type SourceJob struct {
 	Snapshotting SnapshottingEnum  `yaml:"snapshotting"`
 	Filesystems  FilesystemsFilter `yaml:"filesystems"`
 	Send         *SendOptions      `yaml:"send,optional,fromdefaults"`
+	// the first override whose filesystems match a filesystem replaces Send for that filesystem
+	SendOverrides []*SendOptionsOverride `yaml:"send_overrides,optional"`
 }
 
-func (j *SourceJob) GetFilesystems() FilesystemsFilter { return j.Filesystems }
-func (j *SourceJob) GetSendOptions() *SendOptions      { return j.Send }
+func (j *SourceJob) GetFilesystems() FilesystemsFilter        { return j.Filesystems }
+func (j *SourceJob) GetSendOptions() *SendOptions             { return j.Send }
+func (j *SourceJob) GetSendOverrides() []*SendOptionsOverride { return j.SendOverrides }
 
 type FilesystemsFilter map[string]bool
 
@@ -201,10 +453,21 @@ type SnapshottingEnum struct {
 }
 
 type SnapshottingPeriodic struct {
-	Type     string        `yaml:"type"`
-	Prefix   string        `yaml:"prefix"`
-	Interval time.Duration `yaml:"interval,positive"`
-	Hooks    HookList      `yaml:"hooks,optional"`
+	Type            string        `yaml:"type"`
+	Prefix          string        `yaml:"prefix"`
+	Interval        time.Duration `yaml:"interval,positive"`
+	TimestampFormat string        `yaml:"timestamp_format,optional,default=dense"`
+	Hooks           HookList      `yaml:"hooks,optional"`
+	SkipIfUnchanged bool          `yaml:"skip_if_unchanged,optional,default=false"`
+	// 0 means all filesystems are snapshotted without pause
+	BatchSize  int           `yaml:"batch_size,optional,default=0"`
+	BatchDelay time.Duration `yaml:"batch_delay,optional,zeropositive,default=1s"`
+	// do not fail the run if a filesystem is destroyed before it could be snapshotted
+	SkipDestroyedFilesystems bool `yaml:"skip_destroyed_filesystems,optional,default=true"`
+	// take overdue snapshots immediately when the job starts, instead of waiting for the next interval
+	CatchUp bool `yaml:"catch_up,optional,default=true"`
+
+	UserProperties map[zfsprop.Property]string `yaml:"user_properties,optional"`
 }
 
 type SnapshottingManual struct {
@@ -214,27 +477,55 @@ type SnapshottingManual struct {
 type PruningSenderReceiver struct {
 	KeepSender   []PruningEnum `yaml:"keep_sender"`
 	KeepReceiver []PruningEnum `yaml:"keep_receiver"`
+	// allow the keep rules to destroy all snapshots of a filesystem
+	AllowDestroyLast bool `yaml:"allow_destroy_last,optional,default=false"`
+	// number of filesystems whose snapshots are destroyed in parallel, per side
+	Concurrency int `yaml:"concurrency,optional,default=1"`
+	// keep_receiver only considers snapshots whose name starts with a managed prefix
+	ProtectForeignSnapshots bool `yaml:"protect_foreign_snapshots,optional,default=false"`
+	// in addition to the prefix of a push job's periodic snapshotting
+	ManagedPrefixes []string `yaml:"managed_prefixes,optional"`
 }
 
 type PruningLocal struct {
 	Keep []PruningEnum `yaml:"keep"`
+	// allow the keep rules to destroy all snapshots of a filesystem
+	AllowDestroyLast bool `yaml:"allow_destroy_last,optional,default=false"`
+	// number of filesystems whose snapshots are destroyed in parallel
+	Concurrency int `yaml:"concurrency,optional,default=1"`
 }
 
 type LoggingOutletEnumList []LoggingOutletEnum
 
-func (l *LoggingOutletEnumList) SetDefault() {
-	def := `
+// DefaultLoggingOutletEnvVar overrides the logging outlet that is used if the config has no `logging` section.
+// Its value is a single outlet in YAML (flow) syntax, e.g. `{type: syslog, level: info, format: logfmt}`.
+const DefaultLoggingOutletEnvVar = "ZREPL_DEFAULT_LOGGING_OUTLET"
+
+const defaultLoggingOutlet = `
 type: "stdout"
 time: true
 level: "warn"
 format: "human"
 `
-	s := &StdoutLoggingOutlet{}
-	err := yaml.UnmarshalStrict([]byte(def), &s)
+
+func defaultLoggingOutletFromEnv() (LoggingOutletEnum, error) {
+	def := os.Getenv(DefaultLoggingOutletEnvVar)
+	if def == "" {
+		def = defaultLoggingOutlet
+	}
+	var o LoggingOutletEnum
+	if err := yaml.UnmarshalStrict([]byte(def), &o); err != nil {
+		return LoggingOutletEnum{}, errors.Wrapf(err, "invalid logging outlet in environment variable %s", DefaultLoggingOutletEnvVar)
+	}
+	return o, nil
+}
+
+func (l *LoggingOutletEnumList) SetDefault() {
+	o, err := defaultLoggingOutletFromEnv()
 	if err != nil {
-		panic(err)
+		panic(err) // checked by ParseConfigBytes
 	}
-	*l = []LoggingOutletEnum{LoggingOutletEnum{Ret: s}}
+	*l = []LoggingOutletEnum{o}
 }
 
 var _ yaml.Defaulter = &LoggingOutletEnumList{}
@@ -244,6 +535,40 @@ type Global struct {
 	Monitoring []MonitoringEnum       `yaml:"monitoring,optional"`
 	Control    *GlobalControl         `yaml:"control,optional,fromdefaults"`
 	Serve      *GlobalServe           `yaml:"serve,optional,fromdefaults"`
+
+	// collapse repeated identical log entries, applies to all logging outlets
+	LoggingDedup *LoggingDedup `yaml:"logging_dedup,optional,fromdefaults"`
+
+	// record the zfs commands that create or destroy data, independent of logging
+	Audit *AuditLog `yaml:"audit,optional"`
+
+	// 0 means unlimited
+	MaxConcurrentJobs int `yaml:"max_concurrent_jobs,optional,default=0"`
+
+	// directory for state that the daemon persists across restarts, e.g. paused jobs
+	StateDir string `yaml:"state_dir,optional,default=/var/lib/zrepl"`
+
+	// skip the checks of the zfs binary and of the daemon user's permissions on startup
+	SkipStartupSelfTest bool `yaml:"skip_startup_self_test,optional,default=false"`
+
+	// CPU and I/O priority of the zfs commands of jobs that don't set zfs_priority
+	ZFSPriority *ZFSPriority `yaml:"zfs_priority,optional"`
+
+	// minimum time between daemon start and the first periodic snapshots of any job
+	StartupDelay time.Duration `yaml:"startup_delay,optional,zeropositive,default=0s"`
+}
+
+// AuditLog appends a line of JSON to Path for each snapshot, destroy, rollback and forced receive.
+type AuditLog struct {
+	Path string `yaml:"path"`
+}
+
+// ZFSPriority runs zfs send, or all zfs commands, under nice(1) and ionice(1).
+type ZFSPriority struct {
+	Nice        int    `yaml:"nice,optional,default=0"`
+	IONiceClass string `yaml:"ionice_class,optional"`
+	IONiceLevel int    `yaml:"ionice_level,optional,default=4"`
+	AllCommands bool   `yaml:"all_commands,optional,default=false"`
 }
 
 func Default(i interface{}) {
@@ -270,16 +595,49 @@ type TCPConnect struct {
 	ConnectCommon `yaml:",inline"`
 	Address       string        `yaml:"address,hostport"`
 	DialTimeout   time.Duration `yaml:"dial_timeout,zeropositive,default=10s"`
+	Compression   string        `yaml:"compression,optional,default=none"`
+	DNSCacheTTL   time.Duration `yaml:"dns_cache_ttl,optional,zeropositive,default=0s"`
 }
 
 type TLSConnect struct {
 	ConnectCommon `yaml:",inline"`
 	Address       string        `yaml:"address,hostport"`
-	Ca            string        `yaml:"ca"`
+	Ca            TLSCAPaths    `yaml:"ca"`
 	Cert          string        `yaml:"cert"`
 	Key           string        `yaml:"key"`
 	ServerCN      string        `yaml:"server_cn"`
 	DialTimeout   time.Duration `yaml:"dial_timeout,zeropositive,default=10s"`
+	Compression   string        `yaml:"compression,optional,default=none"`
+	DNSCacheTTL   time.Duration `yaml:"dns_cache_ttl,optional,zeropositive,default=0s"`
+}
+
+// TLSCAPaths are the files and directories with the PEM-encoded CA certificates
+// that a TLS transport trusts.
+// In YAML, it is either a single path or a list of paths.
+type TLSCAPaths []string
+
+var _ yaml.Unmarshaler = (*TLSCAPaths)(nil)
+
+func (p *TLSCAPaths) UnmarshalYAML(u func(interface{}, bool) error) error {
+	var single string
+	if err := u(&single, true); err == nil {
+		*p = TLSCAPaths{single}
+	} else {
+		var list []string
+		if err := u(&list, true); err != nil {
+			return fmt.Errorf("must be a path or a list of paths")
+		}
+		*p = TLSCAPaths(list)
+	}
+	if len(*p) == 0 {
+		return fmt.Errorf("must not be empty")
+	}
+	for _, path := range *p {
+		if path == "" {
+			return fmt.Errorf("paths must not be empty")
+		}
+	}
+	return nil
 }
 
 type SSHStdinserverConnect struct {
@@ -299,6 +657,14 @@ type LocalConnect struct {
 	ListenerName   string        `yaml:"listener_name"`
 	ClientIdentity string        `yaml:"client_identity"`
 	DialTimeout    time.Duration `yaml:"dial_timeout,zeropositive,default=2s"`
+	Direct         bool          `yaml:"direct,optional"`
+}
+
+type ArchiveConnect struct {
+	ConnectCommon `yaml:",inline"`
+	Command       string        `yaml:"command"`
+	Manifest      string        `yaml:"manifest"`
+	Timeout       time.Duration `yaml:"timeout,optional,zeropositive,default=0s"`
 }
 
 type ServeEnum struct {
@@ -314,17 +680,23 @@ type TCPServe struct {
 	Listen         string            `yaml:"listen,hostport"`
 	ListenFreeBind bool              `yaml:"listen_freebind,default=false"`
 	Clients        map[string]string `yaml:"clients"`
+	Compression    string            `yaml:"compression,optional,default=none"`
+	AllowCIDRs     []string          `yaml:"allow_cidrs,optional"`
+	DenyCIDRs      []string          `yaml:"deny_cidrs,optional"`
 }
 
 type TLSServe struct {
 	ServeCommon      `yaml:",inline"`
 	Listen           string        `yaml:"listen,hostport"`
 	ListenFreeBind   bool          `yaml:"listen_freebind,default=false"`
-	Ca               string        `yaml:"ca"`
+	Ca               TLSCAPaths    `yaml:"ca"`
 	Cert             string        `yaml:"cert"`
 	Key              string        `yaml:"key"`
 	ClientCNs        []string      `yaml:"client_cns"`
 	HandshakeTimeout time.Duration `yaml:"handshake_timeout,zeropositive,default=10s"`
+	Compression      string        `yaml:"compression,optional,default=none"`
+	AllowCIDRs       []string      `yaml:"allow_cidrs,optional"`
+	DenyCIDRs        []string      `yaml:"deny_cidrs,optional"`
 }
 
 type StdinserverServer struct {
@@ -347,15 +719,35 @@ type PruneKeepNotReplicated struct {
 }
 
 type PruneKeepLastN struct {
-	Type  string `yaml:"type"`
-	Count int    `yaml:"count"`
-	Regex string `yaml:"regex,optional"`
+	Type              string `yaml:"type"`
+	Count             int    `yaml:"count"`
+	Regex             string `yaml:"regex,optional"`
+	ConvertToBookmark bool   `yaml:"convert_to_bookmark,optional,default=false"`
+}
+
+type PruneKeepAnchored struct {
+	Type     string `yaml:"type"`
+	Period   string `yaml:"period"`
+	Month    int    `yaml:"month,optional,default=1"`
+	Day      int    `yaml:"day,optional,default=1"`
+	Hour     int    `yaml:"hour,optional,default=0"`
+	TimeZone string `yaml:"time_zone,optional,default=UTC"`
+	Count    int    `yaml:"count"`
+	Regex    string `yaml:"regex,optional"`
+
+	ConvertToBookmark bool `yaml:"convert_to_bookmark,optional,default=false"`
 }
 
 type PruneKeepRegex struct { // FIXME rename to KeepRegex
-	Type   string `yaml:"type"`
-	Regex  string `yaml:"regex"`
-	Negate bool   `yaml:"negate,optional,default=false"`
+	Type              string `yaml:"type"`
+	Regex             string `yaml:"regex"`
+	Negate            bool   `yaml:"negate,optional,default=false"`
+	ConvertToBookmark bool   `yaml:"convert_to_bookmark,optional,default=false"`
+}
+
+type LoggingDedup struct {
+	Enabled  bool          `yaml:"enabled,optional,default=false"`
+	Interval time.Duration `yaml:"interval,optional,positive,default=1m"`
 }
 
 type LoggingOutletEnum struct {
@@ -385,6 +777,7 @@ type TCPLoggingOutlet struct {
 	Address             string               `yaml:"address,hostport"`
 	Net                 string               `yaml:"net,default=tcp"`
 	RetryInterval       time.Duration        `yaml:"retry_interval,positive,default=10s"`
+	BufferSize          int                  `yaml:"buffer_size,optional,default=0"` // number of log lines retained while disconnected, 0 to drop them
 	TLS                 *TCPLoggingOutletTLS `yaml:"tls,optional"`
 }
 
@@ -399,9 +792,23 @@ type MonitoringEnum struct {
 }
 
 type PrometheusMonitoring struct {
-	Type           string `yaml:"type"`
-	Listen         string `yaml:"listen,hostport"`
-	ListenFreeBind bool   `yaml:"listen_freebind,default=false"`
+	Type           string               `yaml:"type"`
+	Listen         string               `yaml:"listen,hostport"`
+	ListenFreeBind bool                 `yaml:"listen_freebind,default=false"`
+	BasicAuth      *PrometheusBasicAuth `yaml:"basic_auth,optional"`
+	TLS            *PrometheusTLS       `yaml:"tls,optional"`
+}
+
+type PrometheusBasicAuth struct {
+	User         string `yaml:"user"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+type PrometheusTLS struct {
+	Cert      string   `yaml:"cert"`
+	Key       string   `yaml:"key"`
+	Ca        string   `yaml:"ca,optional"`
+	ClientCNs []string `yaml:"client_cns,optional"`
 }
 
 type SyslogFacility syslog.Priority
@@ -442,6 +849,7 @@ type HookCommand struct {
 	Path               string            `yaml:"path"`
 	Timeout            time.Duration     `yaml:"timeout,optional,positive,default=30s"`
 	Filesystems        FilesystemsFilter `yaml:"filesystems,optional,default={'<': true}"`
+	When               string            `yaml:"when,optional,default=both"`
 	HookSettingsCommon `yaml:",inline"`
 }
 
@@ -502,6 +910,7 @@ func (t *ConnectEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error)
 		"tls":             &TLSConnect{},
 		"ssh+stdinserver": &SSHStdinserverConnect{},
 		"local":           &LocalConnect{},
+		"archive":         &ArchiveConnect{},
 	})
 	return
 }
@@ -522,6 +931,7 @@ func (t *PruningEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error)
 		"last_n":         &PruneKeepLastN{},
 		"grid":           &PruneGrid{},
 		"regex":          &PruneKeepRegex{},
+		"anchored":       &PruneKeepAnchored{},
 	})
 	return
 }
@@ -641,18 +1051,81 @@ func ParseConfig(path string) (i *Config, err error) {
 	if bytes, err = ioutil.ReadFile(path); err != nil {
 		return
 	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes, err = maybeDecompressConfig(bytes); err != nil {
+		return nil, errors.Wrapf(err, "cannot decompress config file %q", path)
+	}
 
-	return ParseConfigBytes(bytes)
+	c, err := ParseConfigBytes(bytes)
+	if err != nil {
+		return nil, err
+	}
+	c.Source = &ConfigSource{Path: path, ModTime: stat.ModTime()}
+	if abs, err := filepath.Abs(path); err == nil {
+		c.Source.Path = abs
+	}
+	return c, nil
+}
+
+// maybeDecompressConfig returns the decompressed content if in is gzip-compressed
+// (detected by the gzip magic bytes) and in otherwise.
+func maybeDecompressConfig(in []byte) ([]byte, error) {
+	if len(in) < 2 || in[0] != 0x1f || in[1] != 0x8b {
+		return in, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(in))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
 }
 
 func ParseConfigBytes(bytes []byte) (*Config, error) {
+	if _, err := defaultLoggingOutletFromEnv(); err != nil {
+		return nil, err
+	}
+	expandedMergeKeys := false
+	if yamlMergeKeyRegex.Match(bytes) {
+		expanded, err := expandYAMLMergeKeys(bytes)
+		if err != nil {
+			return nil, err
+		}
+		bytes, expandedMergeKeys = expanded, true
+	}
+	resolvedFilterSets := false
+	if filterSetsRegex.Match(bytes) {
+		resolved, err := resolveFilterSets(bytes)
+		if err != nil {
+			return nil, err
+		}
+		bytes, resolvedFilterSets = resolved, true
+	}
 	var c *Config
 	if err := yaml.UnmarshalStrict(bytes, &c); err != nil {
+		switch {
+		case expandedMergeKeys && resolvedFilterSets:
+			return nil, errors.Wrap(err, "line numbers refer to the config with YAML merge keys expanded and filter sets resolved")
+		case expandedMergeKeys:
+			return nil, errors.Wrap(err, "line numbers refer to the config with YAML merge keys expanded")
+		case resolvedFilterSets:
+			return nil, errors.Wrap(err, "line numbers refer to the config with filter sets resolved")
+		}
 		return nil, err
 	}
 	if c == nil {
 		return nil, fmt.Errorf("config is empty or only consists of comments")
 	}
+	if err := c.resolveSecrets(); err != nil {
+		return nil, err
+	}
+	if err := c.validateJobDependencies(); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
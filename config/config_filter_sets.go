@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"github.com/zrepl/yaml-config"
+)
+
+// filterSetsRegex matches documents that might define or reference filter sets.
+// References must be resolved even without filter_sets to report undefined filter sets.
+// False positives, e.g. in comments, only cost an unnecessary resolveFilterSets.
+var filterSetsRegex = regexp.MustCompile(`(?m)^filter_sets\s*:|[\s{,]["']?use["']?\s*:`)
+
+// filterSetUseKey is the key in a job's `filesystems` that references a filter set.
+const filterSetUseKey = "use"
+
+// resolveFilterSets returns a document that is equivalent to in but in which the
+// `filesystems` of each job that references a filter set through `use: NAME`
+// contain the filter set's entries instead.
+// Entries spelled out in the job's `filesystems` take precedence over the filter set's,
+// which allows jobs to add filesystems to or exclude filesystems from a shared filter set.
+// Like any other filter entry, `use: true` matches the pool `use`.
+func resolveFilterSets(in []byte) ([]byte, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(in, &doc); err != nil {
+		return nil, err
+	}
+
+	sets := make(map[string]yaml.MapSlice)
+	for _, item := range doc {
+		if item.Key != "filter_sets" {
+			continue
+		}
+		defs, ok := item.Value.(yaml.MapSlice)
+		if !ok {
+			return nil, fmt.Errorf("filter_sets: must be a mapping from names to filters")
+		}
+		for _, def := range defs {
+			name, ok := def.Key.(string)
+			if !ok || name == "" {
+				return nil, fmt.Errorf("filter_sets: names must be non-empty strings")
+			}
+			set, ok := def.Value.(yaml.MapSlice)
+			if !ok {
+				return nil, fmt.Errorf("filter_sets: %q: must be a filter", name)
+			}
+			if _, ok := filterSetUse(set); ok {
+				return nil, fmt.Errorf("filter_sets: %q: filter sets cannot use other filter sets", name)
+			}
+			sets[name] = set
+		}
+	}
+
+	for _, item := range doc {
+		if item.Key != "jobs" {
+			continue
+		}
+		jobs, ok := item.Value.([]interface{})
+		if !ok {
+			continue // reported by the strict decoder
+		}
+		for _, j := range jobs {
+			job, ok := j.(yaml.MapSlice)
+			if !ok {
+				continue
+			}
+			for i := range job {
+				if job[i].Key != "filesystems" {
+					continue
+				}
+				filter, ok := job[i].Value.(yaml.MapSlice)
+				if !ok {
+					continue
+				}
+				use, ok := filterSetUse(filter)
+				if !ok {
+					continue
+				}
+				set, ok := sets[use]
+				if !ok {
+					return nil, fmt.Errorf("job %q: filesystems: filter set %q not defined in filter_sets", yamlJobName(job), use)
+				}
+				job[i].Value = overlayFilter(set, filter)
+			}
+		}
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal document with resolved filter sets")
+	}
+	return out, nil
+}
+
+// filterSetUse returns the name of the filter set that filter references.
+func filterSetUse(filter yaml.MapSlice) (name string, ok bool) {
+	for _, e := range filter {
+		if e.Key == filterSetUseKey {
+			name, ok = e.Value.(string)
+			return name, ok
+		}
+	}
+	return "", false
+}
+
+// overlayFilter returns the entries of set, overridden and extended by those of local,
+// without local's reference to set.
+func overlayFilter(set, local yaml.MapSlice) yaml.MapSlice {
+	out := make(yaml.MapSlice, 0, len(set)+len(local))
+	idx := make(map[interface{}]int, len(set)+len(local))
+	for _, e := range set {
+		idx[e.Key] = len(out)
+		out = append(out, e)
+	}
+	for _, e := range local {
+		if e.Key == filterSetUseKey {
+			if _, isRef := e.Value.(string); isRef {
+				continue
+			}
+		}
+		if i, ok := idx[e.Key]; ok {
+			out[i] = e
+			continue
+		}
+		idx[e.Key] = len(out)
+		out = append(out, e)
+	}
+	return out
+}
+
+func yamlJobName(job yaml.MapSlice) string {
+	for _, e := range job {
+		if e.Key == "name" {
+			if name, ok := e.Value.(string); ok {
+				return name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterSets(t *testing.T) {
+	snapJob := func(name, filesystems string) string {
+		return fmt.Sprintf(`
+- name: %s
+  type: snap
+  filesystems: %s
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 1
+`, name, filesystems)
+	}
+	filterSets := `
+filter_sets:
+  prod:
+    "pool/prod<": true
+    "pool/prod/tmp<": false
+`
+
+	conf := testValidConfig(t, filterSets+"jobs:"+
+		snapJob("plain", `{use: prod}`)+
+		snapJob("extended", `{use: prod, "pool/extra": true, "pool/prod/tmp<": true, "pool/prod/secret<": false}`)+
+		snapJob("unrelated", `{"pool/other<": true, "use": true}`))
+
+	assert.Equal(t, FilesystemsFilter{"pool/prod<": true, "pool/prod/tmp<": false}, conf.FilterSets["prod"])
+	assert.Equal(t, FilesystemsFilter{
+		"pool/prod<":     true,
+		"pool/prod/tmp<": false,
+	}, conf.Jobs[0].Ret.(*SnapJob).Filesystems)
+	assert.Equal(t, FilesystemsFilter{
+		"pool/prod<":        true,
+		"pool/prod/tmp<":    true,
+		"pool/extra":        true,
+		"pool/prod/secret<": false,
+	}, conf.Jobs[1].Ret.(*SnapJob).Filesystems)
+	assert.Equal(t, FilesystemsFilter{"pool/other<": true, "use": true}, conf.Jobs[2].Ret.(*SnapJob).Filesystems,
+		"`use: true` is the pool named use")
+}
+
+func TestFilterSetsErrors(t *testing.T) {
+	tcs := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			"unknown",
+			"filter_sets:\n  prod: {\"pool<\": true}\njobs:\n- name: a\n  type: snap\n  filesystems: {use: dev}\n",
+			`job "a": filesystems: filter set "dev" not defined in filter_sets`,
+		},
+		{
+			"no filter_sets",
+			"jobs:\n- name: a\n  type: snap\n  filesystems: {use: prod}\n",
+			`job "a": filesystems: filter set "prod" not defined in filter_sets`,
+		},
+		{
+			"no filter_sets block style",
+			"jobs:\n- name: a\n  type: snap\n  filesystems:\n    use: prod\n",
+			`job "a": filesystems: filter set "prod" not defined in filter_sets`,
+		},
+		{
+			"nested",
+			"filter_sets:\n  prod: {\"pool<\": true}\n  dev: {use: prod}\njobs: []\n",
+			`filter_sets: "dev": filter sets cannot use other filter sets`,
+		},
+		{
+			"not a filter",
+			"filter_sets:\n  prod: [\"pool<\"]\njobs: []\n",
+			`filter_sets: "prod": must be a filter`,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseConfigBytes([]byte(tc.config))
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestFilterSetsInvalidValueIsRejected(t *testing.T) {
+	_, err := ParseConfigBytes([]byte(`
+filter_sets:
+  prod:
+    "pool<": "yes please"
+jobs: []
+`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "filter sets resolved")
+}
@@ -2,8 +2,11 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log/syslog"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -75,6 +78,39 @@ global:
 	assert.Equal(t, ":9091", conf.Global.Monitoring[0].Ret.(*PrometheusMonitoring).Listen)
 }
 
+func TestPrometheusMonitoringAuth(t *testing.T) {
+	conf := testValidGlobalSection(t, `
+global:
+  monitoring:
+    - type: prometheus
+      listen: ':9091'
+      basic_auth:
+        user: prometheus
+        password_file: /etc/zrepl/prometheus.password
+      tls:
+        cert: /etc/zrepl/prometheus.crt
+        key: /etc/zrepl/prometheus.key
+        ca: /etc/zrepl/ca.crt
+        client_cns:
+          - "scraper"
+`)
+	p := conf.Global.Monitoring[0].Ret.(*PrometheusMonitoring)
+	assert.Equal(t, "prometheus", p.BasicAuth.User)
+	assert.Equal(t, "/etc/zrepl/prometheus.password", p.BasicAuth.PasswordFile)
+	assert.Equal(t, "/etc/zrepl/ca.crt", p.TLS.Ca)
+	assert.Equal(t, []string{"scraper"}, p.TLS.ClientCNs)
+
+	conf = testValidGlobalSection(t, `
+global:
+  monitoring:
+    - type: prometheus
+      listen: '127.0.0.1:9091'
+`)
+	p = conf.Global.Monitoring[0].Ret.(*PrometheusMonitoring)
+	assert.Nil(t, p.BasicAuth)
+	assert.Nil(t, p.TLS)
+}
+
 func TestSyslogLoggingOutletFacility(t *testing.T) {
 	type SyslogFacilityPriority struct {
 		Facility string
@@ -113,3 +149,61 @@ func TestLoggingOutletEnumList_SetDefaults(t *testing.T) {
 		assert.Equal(t, "warn", (*e)[0].Ret.(*StdoutLoggingOutlet).Level)
 	})
 }
+
+func TestDefaultLoggingOutletEnv(t *testing.T) {
+	defer os.Unsetenv(DefaultLoggingOutletEnvVar)
+
+	require.NoError(t, os.Setenv(DefaultLoggingOutletEnvVar, "{type: syslog, level: info, format: logfmt}"))
+	conf := testValidGlobalSection(t, "")
+	require.Equal(t, 1, len(*conf.Global.Logging))
+	o := (*conf.Global.Logging)[0].Ret.(*SyslogLoggingOutlet)
+	assert.Equal(t, "info", o.Level)
+	assert.Equal(t, "logfmt", o.Format)
+	assert.Equal(t, 10*time.Second, o.RetryInterval)
+
+	// an explicit logging section takes precedence
+	conf = testValidGlobalSection(t, `
+global:
+  logging:
+  - type: stdout
+    level: debug
+    format: human
+`)
+	assert.Equal(t, "debug", (*conf.Global.Logging)[0].Ret.(*StdoutLoggingOutlet).Level)
+
+	valid, err := ioutil.ReadFile("samples/snap.yml")
+	require.NoError(t, err)
+	for _, invalid := range []string{"{type: journald, level: info, format: human}", "{type: stdout", "{type: stdout, level: info, format: human, foo: bar}"} {
+		require.NoError(t, os.Setenv(DefaultLoggingOutletEnvVar, invalid))
+		_, err := ParseConfigBytes(valid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestLoggingDedup(t *testing.T) {
+	conf := testValidGlobalSection(t, "")
+	assert.False(t, conf.Global.LoggingDedup.Enabled)
+	assert.Equal(t, time.Minute, conf.Global.LoggingDedup.Interval)
+
+	conf = testValidGlobalSection(t, `
+global:
+  logging_dedup:
+    enabled: true
+    interval: 10m
+`)
+	assert.True(t, conf.Global.LoggingDedup.Enabled)
+	assert.Equal(t, 10*time.Minute, conf.Global.LoggingDedup.Interval)
+}
+
+func TestAuditLog(t *testing.T) {
+	conf := testValidGlobalSection(t, "")
+	assert.Nil(t, conf.Global.Audit)
+
+	conf = testValidGlobalSection(t, `
+global:
+  audit:
+    path: /var/log/zrepl/audit.log
+`)
+	require.NotNil(t, conf.Global.Audit)
+	assert.Equal(t, "/var/log/zrepl/audit.log", conf.Global.Audit.Path)
+}
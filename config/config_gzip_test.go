@@ -0,0 +1,45 @@
+package config
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigGzip(t *testing.T) {
+	plain, err := ioutil.ReadFile("samples/push.yml")
+	require.NoError(t, err)
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, err = w.Write(plain)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	dir, err := ioutil.TempDir("", "zrepl-config-gzip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	expect, err := ParseConfigBytes(plain)
+	require.NoError(t, err)
+
+	// detection is based on the content, not the file extension
+	for _, name := range []string{"zrepl.yml.gz", "zrepl.yml"} {
+		p := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(p, compressed.Bytes(), 0600))
+		c, err := ParseConfig(p)
+		require.NoError(t, err, name)
+		assert.Equal(t, expect.Jobs[0].Name(), c.Jobs[0].Name())
+	}
+
+	// truncated gzip stream
+	p := filepath.Join(dir, "truncated.yml.gz")
+	require.NoError(t, ioutil.WriteFile(p, compressed.Bytes()[:compressed.Len()/2], 0600))
+	_, err = ParseConfig(p)
+	assert.Error(t, err)
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Hash returns a hex-encoded SHA-256 hash of the parsed config, including defaults and resolved secrets.
+//
+// Since it is computed over the parsed config, it does not change with formatting, comments, key order,
+// compression or YAML merge keys, but it does change if a secret file referenced by the config changes.
+func (c *Config) Hash() (string, error) {
+	// encoding/json sorts map keys and marshals the concrete types in the enums,
+	// so the encoding is deterministic
+	normalized, err := json.Marshal(c)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot normalize config")
+	}
+	sum := sha256.Sum256(normalized)
+	return hex.EncodeToString(sum[:]), nil
+}
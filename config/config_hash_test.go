@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigHash(t *testing.T) {
+	hash := func(s string) string {
+		c, err := ParseConfigBytes([]byte(s))
+		require.NoError(t, err)
+		h, err := c.Hash()
+		require.NoError(t, err)
+		return h
+	}
+
+	base := hash(`
+jobs:
+- name: snapjob
+  type: snap
+  filesystems: {
+    "tank/frequently_changed<": true,
+  }
+  snapshotting:
+    type: periodic
+    interval: 2m
+    prefix: zrepl_snapjob_
+  pruning:
+    keep:
+      - type: last_n
+        count: 60
+`)
+
+	// formatting, comments, key order and explicit defaults do not matter
+	assert.Equal(t, base, hash(`
+# a comment
+jobs:
+  - type: snap
+    name: snapjob
+    pruning: {keep: [{type: last_n, count: 60}]}
+    snapshotting: {type: periodic, prefix: zrepl_snapjob_, interval: 2m, timestamp_format: dense}
+    filesystems: {"tank/frequently_changed<": true}
+`))
+
+	assert.NotEqual(t, base, hash(`
+jobs:
+- name: snapjob
+  type: snap
+  filesystems: {
+    "tank/frequently_changed<": true,
+  }
+  snapshotting:
+    type: periodic
+    interval: 2m
+    prefix: zrepl_snapjob_
+  pruning:
+    keep:
+      - type: last_n
+        count: 61
+`))
+}
+
+func TestConfigHashGrid(t *testing.T) {
+	hash := func(grid string) string {
+		c, err := ParseConfigBytes([]byte(fmt.Sprintf(`
+jobs:
+- name: snapjob
+  type: snap
+  filesystems: {"tank<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+      - type: grid
+        grid: %s
+        regex: "^zrepl_"
+`, grid)))
+		require.NoError(t, err)
+		h, err := c.Hash()
+		require.NoError(t, err)
+		return h
+	}
+	base := hash("24x1h")
+	assert.Equal(t, base, hash("24x1h(keep=1)"))
+	assert.NotEqual(t, base, hash("24x1d"))
+	assert.NotEqual(t, base, hash("23x1h"))
+	assert.NotEqual(t, base, hash("24x1h(keep=all)"))
+}
+
+func TestParseConfigSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-config-source")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	plain, err := ioutil.ReadFile("samples/snap.yml")
+	require.NoError(t, err)
+	p := filepath.Join(dir, "zrepl.yml")
+	require.NoError(t, ioutil.WriteFile(p, plain, 0600))
+	stat, err := os.Stat(p)
+	require.NoError(t, err)
+
+	c, err := ParseConfig(p)
+	require.NoError(t, err)
+	require.NotNil(t, c.Source)
+	assert.Equal(t, p, c.Source.Path)
+	assert.True(t, stat.ModTime().Equal(c.Source.ModTime))
+
+	c, err = ParseConfigBytes(plain)
+	require.NoError(t, err)
+	assert.Nil(t, c.Source)
+}
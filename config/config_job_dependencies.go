@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DependsOn returns the names of the jobs listed in the job's depends_on, nil for passive jobs.
+func (j JobEnum) DependsOn() []string {
+	switch v := j.Ret.(type) {
+	case *PushJob:
+		return v.DependsOn
+	case *PullJob:
+		return v.DependsOn
+	case *SnapJob:
+		return v.DependsOn
+	default:
+		return nil
+	}
+}
+
+// validateJobDependencies checks that the depends_on of each job only lists
+// existing jobs that have invocations, and that the dependencies have no cycles.
+func (c *Config) validateJobDependencies() error {
+	byName := make(map[string]JobEnum, len(c.Jobs))
+	for _, j := range c.Jobs {
+		byName[j.Name()] = j
+	}
+	for _, j := range c.Jobs {
+		for _, dep := range j.DependsOn() {
+			d, ok := byName[dep]
+			if !ok {
+				return fmt.Errorf("job %q: depends_on: job %q not defined in config", j.Name(), dep)
+			}
+			switch d.Ret.(type) {
+			case *SinkJob, *SourceJob:
+				return fmt.Errorf("job %q: depends_on: job %q is a passive job, it has no invocations to wait for", j.Name(), dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(c.Jobs))
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i := range path {
+				if path[i] == name {
+					cycle := append(path[i:len(path):len(path)], name)
+					return fmt.Errorf("job dependency cycle: %s", strings.Join(cycle, " -> "))
+				}
+			}
+			panic("implementation error: visiting job not on path")
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range byName[name].DependsOn() {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, j := range c.Jobs {
+		if err := visit(j.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
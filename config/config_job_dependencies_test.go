@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobDependencies(t *testing.T) {
+	snapJob := func(name, dependsOn string) string {
+		return fmt.Sprintf(`
+- name: %s
+  type: snap
+  filesystems: {"pool<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 1
+  depends_on: %s
+`, name, dependsOn)
+	}
+	sinkJob := `
+- name: sink
+  type: sink
+  root_fs: "pool/sink"
+  serve:
+    type: local
+    listener_name: sink
+`
+
+	tcs := []struct {
+		name string
+		jobs string
+		err  string
+	}{
+		{"none", snapJob("a", "[]") + snapJob("b", "[]"), ""},
+		{"chain", snapJob("a", "[b]") + snapJob("b", "[c]") + snapJob("c", "[]"), ""},
+		{"diamond", snapJob("a", "[b, c]") + snapJob("b", "[d]") + snapJob("c", "[d]") + snapJob("d", "[]"), ""},
+		{"undefined", snapJob("a", "[x]"), `job "a": depends_on: job "x" not defined in config`},
+		{"passive", snapJob("a", "[sink]") + sinkJob, `job "a": depends_on: job "sink" is a passive job, it has no invocations to wait for`},
+		{"self", snapJob("a", "[a]"), "job dependency cycle: a -> a"},
+		{"cycle", snapJob("a", "[b]") + snapJob("b", "[c]") + snapJob("c", "[b]"), "job dependency cycle: b -> c -> b"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			conf, err := ParseConfigBytes([]byte("jobs:" + tc.jobs))
+			if tc.err == "" {
+				require.NoError(t, err)
+				assert.NotNil(t, conf)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestJobDependsOnNotSupportedByPassiveJobs(t *testing.T) {
+	_, err := ParseConfigBytes([]byte(`
+jobs:
+- name: sink
+  type: sink
+  root_fs: "pool/sink"
+  serve:
+    type: local
+    listener_name: sink
+  depends_on: []
+`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobsMatching(t *testing.T) {
+	jobTmpl := `
+- name: %s
+  type: snap
+  filesystems: {"pool<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 1
+`
+	conf := testValidConfig(t, "jobs:"+
+		fmt.Sprintf(jobTmpl, "push-a")+
+		fmt.Sprintf(jobTmpl, "push-b")+
+		fmt.Sprintf(jobTmpl, "snap"))
+
+	names := func(jobs []*JobEnum) (ns []string) {
+		for _, j := range jobs {
+			ns = append(ns, j.Name())
+		}
+		return ns
+	}
+
+	jobs, err := conf.JobsMatching("push-*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"push-a", "push-b"}, names(jobs))
+
+	jobs, err = conf.JobsMatching("snap")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"snap"}, names(jobs))
+
+	jobs, err = conf.JobsMatching("*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"push-a", "push-b", "snap"}, names(jobs))
+
+	_, err = conf.JobsMatching("pull-*")
+	assert.Error(t, err)
+
+	_, err = conf.JobsMatching("[")
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMinFreeSpace(t *testing.T) {
+	tcs := []struct {
+		in  string
+		exp MinFreeSpace
+		err bool
+	}{
+		{in: "1024", exp: MinFreeSpace{Bytes: 1024}},
+		{in: "1024B", exp: MinFreeSpace{Bytes: 1024}},
+		{in: "10K", exp: MinFreeSpace{Bytes: 10 << 10}},
+		{in: "10 G", exp: MinFreeSpace{Bytes: 10 << 30}},
+		{in: "1.5GiB", exp: MinFreeSpace{Bytes: 3 << 29}},
+		{in: "2TB", exp: MinFreeSpace{Bytes: 2 << 40}},
+		{in: "5%", exp: MinFreeSpace{Percent: 5}},
+		{in: " 12.5 % ", exp: MinFreeSpace{Percent: 12.5}},
+		{in: "0", err: true},
+		{in: "0%", err: true},
+		{in: "100%", err: true},
+		{in: "-5G", err: true},
+		{in: "10X", err: true},
+		{in: "", err: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.in, func(t *testing.T) {
+			m, err := parseMinFreeSpace(tc.in)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.exp, m)
+		})
+	}
+}
+
+func TestMinFreeSpaceJobConfig(t *testing.T) {
+	c := testValidConfig(t, `
+jobs:
+- name: foo
+  type: sink
+  root_fs: "pool/sink"
+  min_free_space: 10%
+  serve:
+    type: local
+    listener_name: foo
+`)
+	assert.Equal(t, &MinFreeSpace{Percent: 10}, c.Jobs[0].Ret.(*SinkJob).MinFreeSpace)
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneKeepAnchored(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    %s
+`
+	t.Run("defaults", func(t *testing.T) {
+		c := testValidConfig(t, fmt.Sprintf(tmpl, `- type: anchored
+      period: monthly
+      count: 84`))
+		r := c.Jobs[0].Ret.(*SnapJob).Pruning.Keep[0].Ret.(*PruneKeepAnchored)
+		assert.Equal(t, "monthly", r.Period)
+		assert.Equal(t, 84, r.Count)
+		assert.Equal(t, 1, r.Month)
+		assert.Equal(t, 1, r.Day)
+		assert.Equal(t, 0, r.Hour)
+		assert.Equal(t, "UTC", r.TimeZone)
+		assert.Equal(t, "", r.Regex)
+	})
+
+	t.Run("all_fields", func(t *testing.T) {
+		c := testValidConfig(t, fmt.Sprintf(tmpl, `- type: anchored
+      period: yearly
+      month: 12
+      day: 31
+      hour: 23
+      time_zone: Europe/Zurich
+      count: 7
+      regex: "^zrepl_"`))
+		r := c.Jobs[0].Ret.(*SnapJob).Pruning.Keep[0].Ret.(*PruneKeepAnchored)
+		assert.Equal(t, "yearly", r.Period)
+		assert.Equal(t, 12, r.Month)
+		assert.Equal(t, 31, r.Day)
+		assert.Equal(t, 23, r.Hour)
+		assert.Equal(t, "Europe/Zurich", r.TimeZone)
+		assert.Equal(t, 7, r.Count)
+		assert.Equal(t, "^zrepl_", r.Regex)
+	})
+}
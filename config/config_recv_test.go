@@ -72,6 +72,12 @@ jobs:
         testprop2: "test123"
 `
 
+	recv_exclude_rejected := `
+  recv:
+    properties:
+      exclude_rejected: true
+`
+
 	recv_empty := `
   recv: {}
 `
@@ -79,6 +85,31 @@ jobs:
 	recv_not_specified := `
 `
 
+	recv_force_rollback := `
+  recv:
+    force_rollback:
+      enabled: true
+`
+
+	recv_force_rollback_allow_divergence := `
+  recv:
+    force_rollback:
+      enabled: true
+      allow_divergence: true
+`
+
+	recv_require_exists := `
+  recv:
+    create_parents: false
+    require_exists: true
+`
+
+	recv_placeholder_encryption_inherit := `
+  recv:
+    placeholder:
+      encryption: inherit
+`
+
 	fill := func(s string) string { return fmt.Sprintf(tmpl, s) }
 
 	t.Run("recv_inherit_empty", func(t *testing.T) {
@@ -106,6 +137,19 @@ jobs:
 
 	})
 
+	t.Run("recv_override_values", func(t *testing.T) {
+		override := func(v string) string {
+			return fill(fmt.Sprintf("\n  recv:\n    properties:\n      override:\n        %s\n", v))
+		}
+		for _, v := range []string{`recordsize: "128k"`, `recordsize: "1MiB"`, `compression: "zstd-3"`, `"com.example:note": "anything"`} {
+			testValidConfig(t, override(v))
+		}
+		for _, v := range []string{`recordsize: "100K"`, `compression: "nope"`, `canmount: "auto"`} {
+			_, err := testConfig(t, override(v))
+			assert.Error(t, err, v)
+		}
+	})
+
 	t.Run("recv_override_and_inherit", func(t *testing.T) {
 		c := testValidConfig(t, fill(recv_override_and_inherit))
 		inherit := c.Jobs[0].Ret.(*PullJob).Recv.Properties.Inherit
@@ -116,6 +160,14 @@ jobs:
 		assert.Equal(t, "test123", override["testprop2"])
 	})
 
+	t.Run("recv_exclude_rejected", func(t *testing.T) {
+		c := testValidConfig(t, fill(recv_exclude_rejected))
+		assert.True(t, c.Jobs[0].Ret.(*PullJob).Recv.Properties.ExcludeRejected)
+
+		c = testValidConfig(t, fill(recv_properties_empty))
+		assert.False(t, c.Jobs[0].Ret.(*PullJob).Recv.Properties.ExcludeRejected)
+	})
+
 	t.Run("recv_properties_empty", func(t *testing.T) {
 		c := testValidConfig(t, fill(recv_properties_empty))
 		assert.NotNil(t, c)
@@ -129,6 +181,59 @@ jobs:
 	t.Run("send_not_specified", func(t *testing.T) {
 		c := testValidConfig(t, fill(recv_not_specified))
 		assert.NotNil(t, c)
+		fr := c.Jobs[0].Ret.(*PullJob).Recv.ForceRollback
+		require.NotNil(t, fr)
+		assert.False(t, fr.Enabled)
+		assert.False(t, fr.AllowDivergence)
+		assert.True(t, c.Jobs[0].Ret.(*PullJob).Recv.CreateParents)
+		assert.False(t, c.Jobs[0].Ret.(*PullJob).Recv.RequireExists)
+	})
+
+	t.Run("recv_empty_create_parents", func(t *testing.T) {
+		c := testValidConfig(t, fill(recv_empty))
+		assert.True(t, c.Jobs[0].Ret.(*PullJob).Recv.CreateParents)
+	})
+
+	t.Run("recv_placeholder_encryption_default", func(t *testing.T) {
+		for _, r := range []string{recv_empty, recv_not_specified} {
+			c := testValidConfig(t, fill(r))
+			ph := c.Jobs[0].Ret.(*PullJob).Recv.Placeholder
+			require.NotNil(t, ph)
+			assert.Equal(t, "off", ph.Encryption)
+		}
+	})
+
+	t.Run("recv_mount_default", func(t *testing.T) {
+		for _, r := range []string{recv_empty, recv_not_specified} {
+			c := testValidConfig(t, fill(r))
+			assert.Equal(t, "noauto", c.Jobs[0].Ret.(*PullJob).Recv.Mount)
+		}
+	})
+
+	t.Run("recv_placeholder_encryption_inherit", func(t *testing.T) {
+		c := testValidConfig(t, fill(recv_placeholder_encryption_inherit))
+		assert.Equal(t, "inherit", c.Jobs[0].Ret.(*PullJob).Recv.Placeholder.Encryption)
+	})
+
+	t.Run("recv_require_exists", func(t *testing.T) {
+		c := testValidConfig(t, fill(recv_require_exists))
+		recv := c.Jobs[0].Ret.(*PullJob).Recv
+		assert.False(t, recv.CreateParents)
+		assert.True(t, recv.RequireExists)
+	})
+
+	t.Run("recv_force_rollback", func(t *testing.T) {
+		c := testValidConfig(t, fill(recv_force_rollback))
+		fr := c.Jobs[0].Ret.(*PullJob).Recv.ForceRollback
+		assert.True(t, fr.Enabled)
+		assert.False(t, fr.AllowDivergence)
+	})
+
+	t.Run("recv_force_rollback_allow_divergence", func(t *testing.T) {
+		c := testValidConfig(t, fill(recv_force_rollback_allow_divergence))
+		fr := c.Jobs[0].Ret.(*PullJob).Recv.ForceRollback
+		assert.True(t, fr.Enabled)
+		assert.True(t, fr.AllowDivergence)
 	})
 
 }
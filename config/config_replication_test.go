@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicationProtectionResumableStateMaxAge(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: push
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  filesystems: {"<": true}
+  replication:
+    protection:
+      %s
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: last_n
+      count: 10
+`
+	maxAge := func(c *Config) time.Duration {
+		return c.Jobs[0].Ret.(*PushJob).Replication.Protection.ResumableStateMaxAge
+	}
+
+	c := testValidConfig(t, fmt.Sprintf(tmpl, "initial: guarantee_resumability"))
+	assert.Equal(t, time.Duration(0), maxAge(c), "disabled by default")
+
+	c = testValidConfig(t, fmt.Sprintf(tmpl, "resumable_state_max_age: 72h"))
+	assert.Equal(t, 72*time.Hour, maxAge(c))
+
+	_, err := testConfig(t, fmt.Sprintf(tmpl, "resumable_state_max_age: -1h"))
+	require.Error(t, err)
+}
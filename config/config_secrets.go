@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Sensitive string fields may reference a file instead of containing the secret inline:
+//
+//	key: ${file:/etc/zrepl/secrets/host.key}
+//
+// The reference is replaced by the file's contents, with surrounding whitespace trimmed.
+var secretFileRefRegex = regexp.MustCompile(`^\$\{file:(.+)\}$`)
+
+// resolveSecretFileRef returns in unmodified unless it is a ${file:/path} reference.
+// warning is non-empty if the referenced file is readable by others.
+func resolveSecretFileRef(in string) (value string, warning string, err error) {
+	m := secretFileRefRegex.FindStringSubmatch(strings.TrimSpace(in))
+	if m == nil {
+		return in, "", nil
+	}
+	path := m[1]
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "cannot stat secret file %q", path)
+	}
+	if !stat.Mode().IsRegular() {
+		return "", "", errors.Errorf("secret file %q is not a regular file", path)
+	}
+	if stat.Mode().Perm()&0004 != 0 {
+		warning = fmt.Sprintf("secret file %q is world-readable (mode %s)", path, stat.Mode().Perm())
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "cannot read secret file %q", path)
+	}
+	return strings.TrimSpace(string(contents)), warning, nil
+}
+
+// resolveSecrets resolves ${file:/path} references in all sensitive fields of c.
+func (c *Config) resolveSecrets() error {
+	resolve := func(field string, v *string) error {
+		resolved, warning, err := resolveSecretFileRef(*v)
+		if err != nil {
+			return errors.Wrap(err, field)
+		}
+		if warning != "" {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("%s: %s", field, warning))
+		}
+		*v = resolved
+		return nil
+	}
+
+	for _, j := range c.Jobs {
+		var connect *ConnectEnum
+		var serve *ServeEnum
+		switch v := j.Ret.(type) {
+		case *PushJob:
+			connect = &v.Connect
+		case *PullJob:
+			connect = &v.Connect
+		case *SinkJob:
+			serve = &v.Serve
+		case *SourceJob:
+			serve = &v.Serve
+		}
+		if connect != nil {
+			if v, ok := connect.Ret.(*TLSConnect); ok {
+				if err := resolve(fmt.Sprintf("job %q: connect.key", j.Name()), &v.Key); err != nil {
+					return err
+				}
+			}
+		}
+		if serve != nil {
+			if v, ok := serve.Ret.(*TLSServe); ok {
+				if err := resolve(fmt.Sprintf("job %q: serve.key", j.Name()), &v.Key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for i, m := range c.Global.Monitoring {
+		if v, ok := m.Ret.(*PrometheusMonitoring); ok && v.TLS != nil {
+			if err := resolve(fmt.Sprintf("global.monitoring #%d: tls.key", i+1), &v.TLS.Key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
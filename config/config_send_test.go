@@ -72,6 +72,12 @@ jobs:
     send_properties: false
 `
 
+	negotiate_features := `
+  send:
+    large_blocks: true
+    negotiate_features: true
+`
+
 	send_empty := `
   send: {}
 `
@@ -141,6 +147,52 @@ jobs:
 	t.Run("send_not_specified", func(t *testing.T) {
 		c := testValidConfig(t, fill(send_not_specified))
 		assert.NotNil(t, c)
+		assert.False(t, c.Jobs[0].Ret.(*PushJob).Send.NegotiateFeatures)
 	})
 
+	t.Run("negotiate_features", func(t *testing.T) {
+		c := testValidConfig(t, fill(negotiate_features))
+		assert.True(t, c.Jobs[0].Ret.(*PushJob).Send.NegotiateFeatures)
+	})
+
+}
+
+func TestSendOverrides(t *testing.T) {
+	c := testValidConfig(t, `
+jobs:
+- name: foo
+  type: push
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  filesystems: {"<": true}
+  send:
+    compressed: true
+    large_blocks: true
+  send_overrides:
+  - filesystems: {"pool/media<": true}
+    send:
+      large_blocks: true
+  - filesystems: {"pool/secret<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: last_n
+      count: 10
+`)
+	j := c.Jobs[0].Ret.(*PushJob)
+	assert.True(t, j.Send.Compressed)
+	if assert.Len(t, j.SendOverrides, 2) {
+		assert.Equal(t, FilesystemsFilter{"pool/media<": true}, j.SendOverrides[0].Filesystems)
+		assert.False(t, j.SendOverrides[0].Send.Compressed)
+		assert.True(t, j.SendOverrides[0].Send.LargeBlocks)
+		// defaults apply, not the job's send options
+		assert.False(t, j.SendOverrides[1].Send.Compressed)
+		assert.Equal(t, "fail", j.SendOverrides[1].Send.EncryptionRootChange)
+	}
 }
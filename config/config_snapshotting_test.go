@@ -38,6 +38,14 @@ jobs:
     interval: 10m
 `
 
+	skipIfUnchanged := `
+  snapshotting:
+    type: periodic
+    prefix: zrepl_
+    interval: 10m
+    skip_if_unchanged: true
+`
+
 	hooks := `
   snapshotting:
     type: periodic
@@ -76,6 +84,13 @@ jobs:
 		assert.Equal(t, "periodic", snp.Type)
 		assert.Equal(t, 10*time.Minute, snp.Interval)
 		assert.Equal(t, "zrepl_", snp.Prefix)
+		assert.False(t, snp.SkipIfUnchanged)
+	})
+
+	t.Run("skip_if_unchanged", func(t *testing.T) {
+		c = testValidConfig(t, fillSnapshotting(skipIfUnchanged))
+		snp := c.Jobs[0].Ret.(*PushJob).Snapshotting.Ret.(*SnapshottingPeriodic)
+		assert.True(t, snp.SkipIfUnchanged)
 	})
 
 	t.Run("hooks", func(t *testing.T) {
@@ -55,6 +55,33 @@ jobs:
 			server_cn: "server1"
 			`,
 		},
+		{
+			Name:        "tcp_with_compression",
+			ExpectError: false,
+			Connect: `
+			type: tcp
+			address: 10.0.0.23:42
+			compression: zstd
+			`,
+		},
+		{
+			Name:        "tcp_with_dns_cache_ttl",
+			ExpectError: false,
+			Connect: `
+			type: tcp
+			address: backup.example.com:42
+			dns_cache_ttl: 1h
+			`,
+		},
+		{
+			Name:        "tcp_with_negative_dns_cache_ttl",
+			ExpectError: true,
+			Connect: `
+			type: tcp
+			address: backup.example.com:42
+			dns_cache_ttl: -1s
+			`,
+		},
 		{
 			Name:        "tcp_without_port",
 			ExpectError: true,
@@ -97,3 +124,50 @@ jobs:
 	}
 
 }
+
+func TestTLSCAPaths(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: push
+  connect:
+    type: tls
+    address: "server1.foo.bar:8888"
+    ca: %s
+    cert: /etc/zrepl/backupserver.fullchain
+    key:  /etc/zrepl/backupserver.key
+    server_cn: "server1"
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: last_n
+      count: 10
+`
+	parse := func(ca string) (TLSCAPaths, error) {
+		c, err := ParseConfigBytes([]byte(fmt.Sprintf(tmpl, ca)))
+		if err != nil {
+			return nil, err
+		}
+		return c.Jobs[0].Ret.(*PushJob).Connect.Ret.(*TLSConnect).Ca, nil
+	}
+
+	p, err := parse("/etc/zrepl/ca.crt")
+	require.NoError(t, err)
+	require.Equal(t, TLSCAPaths{"/etc/zrepl/ca.crt"}, p)
+
+	p, err = parse("[/etc/zrepl/old-ca.crt, /etc/zrepl/cas.d]")
+	require.NoError(t, err)
+	require.Equal(t, TLSCAPaths{"/etc/zrepl/old-ca.crt", "/etc/zrepl/cas.d"}, p)
+
+	_, err = parse("[]")
+	require.Error(t, err)
+	_, err = parse(`[""]`)
+	require.Error(t, err)
+	_, err = parse("{a: b}")
+	require.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+	"github.com/zrepl/yaml-config"
+)
+
+// yamlMergeKeyRegex matches documents that might use YAML merge keys.
+// False positives, e.g. in quoted strings, only cost an unnecessary expandYAMLMergeKeys.
+var yamlMergeKeyRegex = regexp.MustCompile(`<<\s*:`)
+
+// expandYAMLMergeKeys returns a document that is equivalent to in but contains no merge keys (`<<: *anchor`).
+//
+// yaml-config decodes the value of a merge key into the destination struct as if it were
+// the complete mapping: required fields and defaults are checked and applied before the
+// mapping's own keys are decoded, and the strict decoder rejects keys that override merged keys.
+// Decoding the expanded document instead makes merged mappings behave exactly like
+// mappings that spell out all their keys.
+func expandYAMLMergeKeys(in []byte) ([]byte, error) {
+	// yaml-config resolves merge keys when decoding into generic maps,
+	// but merged keys win over the mapping's own keys if the merge key comes last.
+	var merged interface{}
+	if err := yaml.Unmarshal(in, &merged); err != nil {
+		return nil, err
+	}
+	// Decoding into yaml.MapSlice drops merge keys, leaving only the keys that are spelled out.
+	var explicit yaml.MapSlice
+	if err := yaml.Unmarshal(in, &explicit); err != nil {
+		return nil, err
+	}
+	out, err := yaml.Marshal(overlayExplicitYAML(merged, explicit))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal expanded document")
+	}
+	return out, nil
+}
+
+// overlayExplicitYAML overrides the values in merged with the ones that were spelled out in explicit,
+// giving a mapping's own keys precedence over merged keys as required by the YAML merge key spec.
+func overlayExplicitYAML(merged, explicit interface{}) interface{} {
+	switch e := explicit.(type) {
+	case yaml.MapSlice:
+		m, ok := merged.(map[interface{}]interface{})
+		if !ok {
+			m = make(map[interface{}]interface{}, len(e))
+		}
+		for _, item := range e {
+			m[item.Key] = overlayExplicitYAML(m[item.Key], item.Value)
+		}
+		return m
+	case []interface{}:
+		s, ok := merged.([]interface{})
+		if !ok || len(s) != len(e) {
+			s = make([]interface{}, len(e))
+		}
+		for i := range e {
+			s[i] = overlayExplicitYAML(s[i], e[i])
+		}
+		return s
+	default:
+		return explicit
+	}
+}
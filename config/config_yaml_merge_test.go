@@ -0,0 +1,118 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const yamlMergeTestJobs = `
+jobs:
+- &push_common
+  name: a
+  type: push
+  connect: &connect
+    type: tcp
+    address: "10.0.0.1:8888"
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  send: &send_opts
+    encrypted: true
+    large_blocks: true
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 1
+`
+
+func TestYAMLAliasOfEnumAndOptions(t *testing.T) {
+	c := testValidConfig(t, yamlMergeTestJobs+`
+- name: b
+  type: push
+  connect: *connect
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  send: *send_opts
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 1
+`)
+	require.Len(t, c.Jobs, 2)
+	b := c.Jobs[1].Ret.(*PushJob)
+	assert.True(t, b.Send.Encrypted)
+	assert.True(t, b.Send.LargeBlocks)
+	assert.Equal(t, "fail", b.Send.EncryptionRootChange) // default applied
+	conn := b.Connect.Ret.(*TCPConnect)
+	assert.Equal(t, "10.0.0.1:8888", conn.Address)
+	assert.Equal(t, 10*time.Second, conn.DialTimeout)
+	// aliases must not share the decoded values
+	assert.False(t, b.Send == c.Jobs[0].Ret.(*PushJob).Send)
+}
+
+func TestYAMLMergeKeys(t *testing.T) {
+	c := testValidConfig(t, yamlMergeTestJobs+`
+- <<: *push_common
+  name: b
+  send:
+    <<: *send_opts
+    raw: true
+    large_blocks: false
+  connect:
+    dial_timeout: 5s
+    <<: *connect
+`)
+	require.Len(t, c.Jobs, 2)
+	a := c.Jobs[0].Ret.(*PushJob)
+	b := c.Jobs[1].Ret.(*PushJob)
+	assert.Equal(t, "a", a.Name)
+	assert.Equal(t, "b", b.Name)
+
+	// merged keys, keys of the mapping itself, and defaults
+	assert.True(t, b.Send.Encrypted)
+	assert.True(t, b.Send.Raw)
+	assert.False(t, b.Send.LargeBlocks)
+	assert.Equal(t, "fail", b.Send.EncryptionRootChange)
+	assert.False(t, a.Send.Raw)
+	assert.True(t, a.Send.LargeBlocks)
+
+	// the mapping's own keys win even if the merge key comes last
+	conn := b.Connect.Ret.(*TCPConnect)
+	assert.Equal(t, "10.0.0.1:8888", conn.Address)
+	assert.Equal(t, 5*time.Second, conn.DialTimeout)
+	assert.Equal(t, "none", conn.Compression)
+	assert.Equal(t, 10*time.Second, a.Connect.Ret.(*TCPConnect).DialTimeout)
+
+	assert.Len(t, b.Pruning.KeepReceiver, 1)
+	assert.NotNil(t, b.Snapshotting.Ret.(*SnapshottingManual))
+}
+
+func TestYAMLMergeKeysValidationAfterMerge(t *testing.T) {
+	// unknown fields are rejected regardless of whether they come from a merged mapping
+	_, err := testConfig(t, yamlMergeTestJobs+`
+- <<: *push_common
+  name: b
+  send:
+    <<: *send_opts
+    nonexistent: true
+`)
+	assert.Error(t, err)
+
+	// enum types are validated after the mapping's own keys override the merged ones
+	_, err = testConfig(t, yamlMergeTestJobs+`
+- <<: *push_common
+  name: b
+  connect:
+    <<: *connect
+    type: nonexistent
+`)
+	assert.Error(t, err)
+}
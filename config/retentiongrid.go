@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -11,9 +12,10 @@ import (
 type RetentionIntervalList []RetentionInterval
 
 type PruneGrid struct {
-	Type  string                `yaml:"type"`
-	Grid  RetentionIntervalList `yaml:"grid"`
-	Regex string                `yaml:"regex"`
+	Type              string                `yaml:"type"`
+	Grid              RetentionIntervalList `yaml:"grid"`
+	Regex             string                `yaml:"regex"`
+	ConvertToBookmark bool                  `yaml:"convert_to_bookmark,optional,default=false"`
 }
 
 type RetentionInterval struct {
@@ -29,6 +31,14 @@ func (i *RetentionInterval) KeepCount() int {
 	return i.keepCount
 }
 
+// MarshalJSON includes the unexported fields, e.g., for Config.Hash.
+func (i RetentionInterval) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Length    time.Duration
+		KeepCount int
+	}{i.length, i.keepCount})
+}
+
 const RetentionGridKeepCountAll int = -1
 
 func (t *RetentionIntervalList) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
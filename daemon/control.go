@@ -13,7 +13,9 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/runnow"
 	"github.com/zrepl/zrepl/daemon/nethelpers"
 	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/logger"
@@ -24,12 +26,13 @@ import (
 )
 
 type controlJob struct {
-	sockaddr *net.UnixAddr
-	jobs     *jobs
+	sockaddr   *net.UnixAddr
+	jobs       *jobs
+	configInfo ControlConfigInfo
 }
 
-func newControlJob(sockpath string, jobs *jobs) (j *controlJob, err error) {
-	j = &controlJob{jobs: jobs}
+func newControlJob(sockpath string, jobs *jobs, configInfo ControlConfigInfo) (j *controlJob, err error) {
+	j = &controlJob{jobs: jobs, configInfo: configInfo}
 
 	j.sockaddr, err = net.ResolveUnixAddr("unix", sockpath)
 	if err != nil {
@@ -77,8 +80,47 @@ const (
 	ControlJobEndpointVersion string = "/version"
 	ControlJobEndpointStatus  string = "/status"
 	ControlJobEndpointSignal  string = "/signal"
+	ControlJobEndpointZFSCmds string = "/zfscmds"
+	ControlJobEndpointRun     string = "/run"
+	ControlJobEndpointConfig  string = "/config"
 )
 
+// ControlConfigInfo describes the config the daemon is running with.
+type ControlConfigInfo struct {
+	// see config.Config.Hash
+	Hash string
+	// empty if the daemon was not started from a config file
+	Path    string    `json:",omitempty"`
+	ModTime time.Time `json:",omitempty"`
+	// the time at which the daemon loaded the config
+	LoadedAt time.Time
+}
+
+func newControlConfigInfo(conf *config.Config, loadedAt time.Time) (ControlConfigInfo, error) {
+	hash, err := conf.Hash()
+	if err != nil {
+		return ControlConfigInfo{}, err
+	}
+	info := ControlConfigInfo{Hash: hash, LoadedAt: loadedAt}
+	if conf.Source != nil {
+		info.Path = conf.Source.Path
+		info.ModTime = conf.Source.ModTime
+	}
+	return info, nil
+}
+
+type ControlRunRequest struct {
+	Name string
+	// restricts the invocation to a single phase, see runnow.ParseScope
+	Only string `json:",omitempty"`
+}
+
+type ControlRunResponse struct {
+	ControlSchemaVersioned
+	// matches job.ManualRunStatus.ID in the job's status once the invocation has started
+	ID uint64
+}
+
 func (j *controlJob) Run(ctx context.Context) {
 
 	log := job.GetLogger(ctx)
@@ -107,12 +149,23 @@ func (j *controlJob) Run(ctx context.Context) {
 				return nil, errors.Errorf("decode failed")
 			}
 			pprofServer.Control(msg)
-			return struct{}{}, nil
+			return controlSchemaVersioned(), nil
 		}}})
 
 	mux.Handle(ControlJobEndpointVersion,
 		requestLogger{log: log, handler: jsonResponder{log, func() (interface{}, error) {
-			return version.NewZreplVersionInformation(), nil
+			return struct {
+				ControlSchemaVersioned
+				*version.ZreplVersionInformation
+			}{controlSchemaVersioned(), version.NewZreplVersionInformation()}, nil
+		}}})
+
+	mux.Handle(ControlJobEndpointConfig,
+		requestLogger{log: log, handler: jsonResponder{log, func() (interface{}, error) {
+			return struct {
+				ControlSchemaVersioned
+				ControlConfigInfo
+			}{controlSchemaVersioned(), j.configInfo}, nil
 		}}})
 
 	mux.Handle(ControlJobEndpointStatus,
@@ -122,7 +175,8 @@ func (j *controlJob) Run(ctx context.Context) {
 			globalZFS := zfscmd.GetReport()
 			envconstReport := envconst.GetReport()
 			s := Status{
-				Jobs: jobs,
+				ControlSchemaVersioned: controlSchemaVersioned(),
+				Jobs:                   jobs,
 				Global: GlobalStatus{
 					ZFSCmds:  globalZFS,
 					Envconst: envconstReport,
@@ -130,11 +184,22 @@ func (j *controlJob) Run(ctx context.Context) {
 			return s, nil
 		}})
 
+	mux.Handle(ControlJobEndpointZFSCmds,
+		// don't log requests to this endpoint, it is polled like the status endpoint
+		jsonResponder{log, func() (interface{}, error) {
+			return struct {
+				ControlSchemaVersioned
+				*zfscmd.Report
+			}{controlSchemaVersioned(), zfscmd.GetReport()}, nil
+		}})
+
 	mux.Handle(ControlJobEndpointSignal,
 		requestLogger{log: log, handler: jsonRequestResponder{log, func(decoder jsonDecoder) (interface{}, error) {
 			type reqT struct {
 				Name string
 				Op   string
+				// only for Op "forget"
+				Filesystem string
 			}
 			var req reqT
 			if decoder(&req) != nil {
@@ -147,11 +212,34 @@ func (j *controlJob) Run(ctx context.Context) {
 				err = j.jobs.wakeup(req.Name)
 			case "reset":
 				err = j.jobs.reset(req.Name)
+			case "pause":
+				err = j.jobs.setPaused(req.Name, true)
+			case "resume":
+				err = j.jobs.setPaused(req.Name, false)
+			case "forget":
+				err = j.jobs.forgetFilesystem(req.Name, req.Filesystem)
 			default:
 				err = fmt.Errorf("operation %q is invalid", req.Op)
 			}
 
-			return struct{}{}, err
+			return controlSchemaVersioned(), err
+		}}})
+
+	mux.Handle(ControlJobEndpointRun,
+		requestLogger{log: log, handler: jsonRequestResponder{log, func(decoder jsonDecoder) (interface{}, error) {
+			var req ControlRunRequest
+			if decoder(&req) != nil {
+				return nil, errors.Errorf("decode failed")
+			}
+			scope, err := runnow.ParseScope(req.Only)
+			if err != nil {
+				return nil, err
+			}
+			id, err := j.jobs.runNow(req.Name, scope)
+			if err != nil {
+				return nil, err
+			}
+			return ControlRunResponse{controlSchemaVersioned(), id}, nil
 		}}})
 	server := http.Server{
 		Handler: mux,
@@ -0,0 +1,50 @@
+package daemon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The version of the schema of the control endpoints' JSON responses.
+//
+// The major version is incremented on breaking changes, e.g. when fields are removed or renamed
+// or their semantics change. The minor version is incremented on backwards-compatible changes
+// such as added fields.
+// Clients must accept responses with the major version they were built for and any minor version.
+const (
+	ControlSchemaVersionMajor = 1
+	ControlSchemaVersionMinor = 8
+)
+
+var ControlSchemaVersion = fmt.Sprintf("%d.%d", ControlSchemaVersionMajor, ControlSchemaVersionMinor)
+
+// ControlSchemaVersioned is embedded into all responses of the control endpoints.
+type ControlSchemaVersioned struct {
+	SchemaVersion string
+}
+
+func controlSchemaVersioned() ControlSchemaVersioned {
+	return ControlSchemaVersioned{SchemaVersion: ControlSchemaVersion}
+}
+
+// CheckControlSchemaVersion returns an error if a response with schema version v
+// cannot be interpreted by this build.
+// An empty v is accepted for compatibility with daemons that predate schema versioning.
+func CheckControlSchemaVersion(v string) error {
+	if v == "" {
+		return nil
+	}
+	comps := strings.SplitN(v, ".", 2)
+	major, err := strconv.Atoi(comps[0])
+	if err != nil || len(comps) != 2 {
+		return fmt.Errorf("invalid control schema version %q", v)
+	}
+	if _, err := strconv.Atoi(comps[1]); err != nil {
+		return fmt.Errorf("invalid control schema version %q", v)
+	}
+	if major != ControlSchemaVersionMajor {
+		return fmt.Errorf("daemon uses control schema version %s, this client supports %d.x: client and daemon must be the same zrepl version", v, ControlSchemaVersionMajor)
+	}
+	return nil
+}
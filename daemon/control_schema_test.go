@@ -0,0 +1,28 @@
+package daemon
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckControlSchemaVersion(t *testing.T) {
+	assert.NoError(t, CheckControlSchemaVersion(""))
+	assert.NoError(t, CheckControlSchemaVersion(ControlSchemaVersion))
+	assert.NoError(t, CheckControlSchemaVersion("1.23"))
+	assert.Error(t, CheckControlSchemaVersion("2.0"))
+	assert.Error(t, CheckControlSchemaVersion("0.9"))
+	assert.Error(t, CheckControlSchemaVersion("1"))
+	assert.Error(t, CheckControlSchemaVersion("1.x"))
+}
+
+func TestStatusHasSchemaVersion(t *testing.T) {
+	s := Status{ControlSchemaVersioned: controlSchemaVersioned()}
+	buf, err := json.Marshal(s)
+	require.NoError(t, err)
+	var v ControlSchemaVersioned
+	require.NoError(t, json.Unmarshal(buf, &v))
+	assert.Equal(t, ControlSchemaVersion, v.SchemaVersion)
+}
@@ -20,10 +20,14 @@ import (
 
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/jobstate"
+	"github.com/zrepl/zrepl/daemon/job/pause"
 	"github.com/zrepl/zrepl/daemon/job/reset"
+	"github.com/zrepl/zrepl/daemon/job/runnow"
 	"github.com/zrepl/zrepl/daemon/job/wakeup"
 	"github.com/zrepl/zrepl/daemon/logging"
 	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/tlsconf"
 	"github.com/zrepl/zrepl/version"
 	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
@@ -38,6 +42,20 @@ func Run(ctx context.Context, conf *config.Config) error {
 		<-sigChan
 		cancel()
 	}()
+	// reload the TLS certificates of the tls transports for new connections
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	defer signal.Stop(hupChan)
+	go func() {
+		for {
+			select {
+			case <-hupChan:
+				tlsconf.RequestReload()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
 	// The math/rand package is used presently for generating trace IDs, we
 	// seed it with the current time and pid so that the IDs are mostly
@@ -45,19 +63,28 @@ func Run(ctx context.Context, conf *config.Config) error {
 	rand.Seed(time.Now().UnixNano())
 	rand.Seed(int64(os.Getpid()))
 
-	outlets, err := logging.OutletsFromConfig(*conf.Global.Logging)
+	outlets, err := logging.OutletsFromConfig(*conf.Global.Logging, conf.Global.LoggingDedup)
 	if err != nil {
 		return errors.Wrap(err, "cannot build logging from config")
 	}
+	defer logging.CloseOutlets(outlets)
 	outlets.Add(newPrometheusLogOutlet(), logger.Debug)
 
 	confJobs, err := job.JobsFromConfig(conf)
 	if err != nil {
 		return errors.Wrap(err, "cannot build jobs from config")
 	}
+	configInfo, err := newControlConfigInfo(conf, time.Now())
+	if err != nil {
+		return err
+	}
+	prometheusConfigLoaded(conf, configInfo)
 
 	log := logger.NewLogger(outlets, 1*time.Second)
 	log.Info(version.NewZreplVersionInformation().String())
+	for _, w := range conf.Warnings {
+		log.Warn(w)
+	}
 
 	ctx = logging.WithLoggers(ctx, logging.SubsystemLoggersWithUniversalLogger(log))
 	trace.RegisterCallback(trace.Callback{
@@ -76,10 +103,27 @@ func Run(ctx context.Context, conf *config.Config) error {
 		}
 	}
 
-	jobs := newJobs()
+	if conf.Global.SkipStartupSelfTest {
+		log.Warn("skipping startup self-test")
+	} else if err := selfTest(ctx, conf, log); err != nil {
+		return errors.Wrap(err, "startup self-test failed (set global.skip_startup_self_test to skip it)")
+	}
+
+	if a := conf.Global.Audit; a != nil {
+		f, err := os.OpenFile(a.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return errors.Wrap(err, "cannot open audit log")
+		}
+		defer f.Close()
+		zfscmd.SetAuditWriter(f)
+		defer zfscmd.SetAuditWriter(nil)
+		log.WithField("path", a.Path).Info("writing audit log")
+	}
+
+	jobs := newJobs(conf.Global.StateDir)
 
 	// start control socket
-	controlJob, err := newControlJob(conf.Global.Control.SockPath, jobs)
+	controlJob, err := newControlJob(conf.Global.Control.SockPath, jobs, configInfo)
 	if err != nil {
 		panic(err) // FIXME
 	}
@@ -104,6 +148,11 @@ func Run(ctx context.Context, conf *config.Config) error {
 
 	// register global (=non job-local) metrics
 	version.PrometheusRegister(prometheus.DefaultRegisterer)
+	metricLabels, err := job.MetricLabelsFromConfig(conf)
+	if err != nil {
+		return err // validated by job.JobsFromConfig
+	}
+	zfscmd.SetJobLabels(metricLabels.Names, metricLabels.ValuesByJob())
 	zfscmd.RegisterMetrics(prometheus.DefaultRegisterer)
 	trace.RegisterMetrics(prometheus.DefaultRegisterer)
 	endpoint.RegisterMetrics(prometheus.DefaultRegisterer)
@@ -134,14 +183,23 @@ type jobs struct {
 	m       sync.RWMutex
 	wakeups map[string]wakeup.Func // by Job.Name
 	resets  map[string]reset.Func  // by Job.Name
+	pauses  map[string]pause.Func  // by Job.Name
+	runNows map[string]runnow.Func // by Job.Name
 	jobs    map[string]job.Job
+
+	stateDir string
+	paused   map[string]bool // by Job.Name, persisted by the pause.Func in pauses
 }
 
-func newJobs() *jobs {
+func newJobs(stateDir string) *jobs {
 	return &jobs{
-		wakeups: make(map[string]wakeup.Func),
-		resets:  make(map[string]reset.Func),
-		jobs:    make(map[string]job.Job),
+		stateDir: stateDir,
+		wakeups:  make(map[string]wakeup.Func),
+		resets:   make(map[string]reset.Func),
+		pauses:   make(map[string]pause.Func),
+		runNows:  make(map[string]runnow.Func),
+		jobs:     make(map[string]job.Job),
+		paused:   make(map[string]bool),
 	}
 }
 
@@ -155,6 +213,7 @@ func (s *jobs) wait() <-chan struct{} {
 }
 
 type Status struct {
+	ControlSchemaVersioned
 	Jobs   map[string]*job.Status
 	Global GlobalStatus
 }
@@ -185,6 +244,7 @@ func (s *jobs) status() map[string]*job.Status {
 	close(c)
 	ret := make(map[string]*job.Status, len(s.jobs))
 	for res := range c {
+		res.status.Paused = s.paused[res.name]
 		ret[res.name] = res.status
 	}
 	return ret
@@ -212,6 +272,66 @@ func (s *jobs) reset(job string) error {
 	return wu()
 }
 
+// forgetFilesystem makes job name forget the sender filesystem fs, see job.ActiveSide.ForgetFilesystem.
+func (s *jobs) forgetFilesystem(name, fs string) error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	j, ok := s.jobs[name]
+	if !ok {
+		return errors.Errorf("Job %s does not exist", name)
+	}
+	a, ok := j.(*job.ActiveSide)
+	if !ok {
+		return errors.Errorf("Job %s does not track sender filesystems, only push and pull jobs do", name)
+	}
+	return a.ForgetFilesystem(fs)
+}
+
+// runNow requests an immediate invocation of job name, restricted to scope, and returns its id.
+func (s *jobs) runNow(name string, scope runnow.Scope) (uint64, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	rn, ok := s.runNows[name]
+	if !ok {
+		if _, exists := s.jobs[name]; exists {
+			return 0, errors.Errorf("Job %s does not support manual invocation", name)
+		}
+		return 0, errors.Errorf("Job %s does not exist", name)
+	}
+	if s.paused[name] {
+		return 0, errors.Errorf("Job %s is paused, resume it first", name)
+	}
+	if err := job.CheckRunNowScope(s.jobs[name], scope); err != nil {
+		return 0, errors.Wrapf(err, "Job %s cannot run only %s", name, scope)
+	}
+	return rn(scope)
+}
+
+// setPaused pauses or resumes job and persists the new state.
+func (s *jobs) setPaused(job string, paused bool) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	pf, ok := s.pauses[job]
+	if !ok {
+		return errors.Errorf("Job %s does not exist", job)
+	}
+	if s.paused[job] == paused {
+		return nil
+	}
+	if err := pf(paused); err != nil {
+		return errors.Wrap(err, "cannot persist paused state")
+	}
+	if paused {
+		s.paused[job] = true
+	} else {
+		delete(s.paused, job)
+	}
+	return nil
+}
+
 const (
 	jobNamePrometheus = "_prometheus"
 	jobNameControl    = "_control"
@@ -246,6 +366,29 @@ func (s *jobs) start(ctx context.Context, j job.Job, internal bool) {
 	ctx, resetFunc := reset.Context(ctx)
 	s.wakeups[jobName] = wakeup
 	s.resets[jobName] = resetFunc
+	if !internal {
+		stateStore := jobstate.New(s.stateDir, jobName)
+		if err := stateStore.RemoveStaleTempFiles(); err != nil {
+			job.GetLogger(ctx).WithError(err).Warn("cannot remove stale temporary files from job state directory")
+		}
+		ctx = jobstate.Context(ctx, stateStore)
+		var pauseFunc pause.Func
+		var err error
+		ctx, pauseFunc, err = pause.Context(ctx)
+		if err != nil {
+			job.GetLogger(ctx).WithError(err).Error("cannot load pause state, starting the job paused")
+		}
+		s.pauses[jobName] = pauseFunc
+		if pause.IsPaused(ctx) {
+			s.paused[jobName] = true
+			job.GetLogger(ctx).Info("job is paused, use `zrepl signal resume` to resume it")
+		}
+		if supportsRunNow(j) {
+			var runNowFunc runnow.Func
+			ctx, runNowFunc = runnow.Context(ctx)
+			s.runNows[jobName] = runNowFunc
+		}
+	}
 
 	s.wg.Add(1)
 	go func() {
@@ -255,3 +398,13 @@ func (s *jobs) start(ctx context.Context, j job.Job, internal bool) {
 		j.Run(ctx)
 	}()
 }
+
+// supportsRunNow reports whether j handles invocations requested through runnow.
+func supportsRunNow(j job.Job) bool {
+	switch j.(type) {
+	case *job.ActiveSide, *job.SnapJob:
+		return true
+	default:
+		return false
+	}
+}
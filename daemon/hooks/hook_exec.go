@@ -28,6 +28,19 @@ type Hook interface {
 	String() string
 }
 
+// EdgeSelector is implemented by hooks that only participate in a subset of the Pre and Post edges.
+// Hooks that do not implement EdgeSelector participate in both.
+type EdgeSelector interface {
+	Edges() Edge
+}
+
+func hookEdges(h Hook) Edge {
+	if s, ok := h.(EdgeSelector); ok {
+		return s.Edges()
+	}
+	return Pre | Post
+}
+
 type Phase string
 
 const (
@@ -98,9 +111,9 @@ type Plan struct {
 	mtx sync.RWMutex
 
 	steps []*Step
-	pre   []*Step // protected by mtx
+	pre   []*Step // protected by mtx, nil entry if the hook does not participate in the Pre edge
 	cb    *Step
-	post  []*Step // not reversed, i.e. entry at index i corresponds to pre-edge in pre[i]
+	post  []*Step // not reversed, i.e. entry at index i corresponds to pre-edge in pre[i], nil entry if the hook does not participate in the Post edge
 
 	phase Phase
 	env   Env
@@ -111,19 +124,28 @@ func NewPlan(hooks *List, phase Phase, cb *CallbackHook, extra Env) (*Plan, erro
 	var pre, post []*Step
 	// TODO sanity check unique name of hook?
 	for _, hook := range *hooks {
+		edges := hookEdges(hook)
+		if edges&(Pre|Post) == 0 {
+			return nil, fmt.Errorf("hook %s participates in neither pre nor post edge", hook)
+		}
 		state := make(map[interface{}]interface{})
-		preE := &Step{
-			Hook:   hook,
-			Edge:   Pre,
-			Status: StepPending,
-			state:  state,
+		var preE, postE *Step
+		if edges&Pre != 0 {
+			preE = &Step{
+				Hook:   hook,
+				Edge:   Pre,
+				Status: StepPending,
+				state:  state,
+			}
 		}
 		pre = append(pre, preE)
-		postE := &Step{
-			Hook:   hook,
-			Edge:   Post,
-			Status: StepPending,
-			state:  state,
+		if edges&Post != 0 {
+			postE = &Step{
+				Hook:   hook,
+				Edge:   Post,
+				Status: StepPending,
+				state:  state,
+			}
 		}
 		post = append(post, postE)
 	}
@@ -135,10 +157,16 @@ func NewPlan(hooks *List, phase Phase, cb *CallbackHook, extra Env) (*Plan, erro
 	}
 
 	steps := make([]*Step, 0, len(pre)+len(post)+1)
-	steps = append(steps, pre...)
+	for _, e := range pre {
+		if e != nil {
+			steps = append(steps, e)
+		}
+	}
 	steps = append(steps, cbE)
 	for i := len(post) - 1; i >= 0; i-- {
-		steps = append(steps, post[i])
+		if post[i] != nil {
+			steps = append(steps, post[i])
+		}
 	}
 
 	plan := &Plan{
@@ -225,6 +253,9 @@ func (p *Plan) Run(ctx context.Context, dryRun bool) {
 	next := 0
 	for ; next < len(p.pre); next++ {
 		e := p.pre[next]
+		if e == nil {
+			continue // post-only hook
+		}
 		l := l.WithField("hook", e.Hook)
 		r := runHook(e, ctx, Pre)
 		if r.HadError() {
@@ -242,10 +273,21 @@ func (p *Plan) Run(ctx context.Context, dryRun bool) {
 		l.Error("no snapshot will be taken")
 		l.Error("only running post-edges for successful pre-edges")
 		w(func() {
-			p.post[next].Status = StepSkippedDueToFatalErr
+			for i := 0; i < next; i++ {
+				if p.pre[i] == nil {
+					p.post[i].Status = StepSkippedDueToFatalErr // post-only hook, there will be no snapshot
+				}
+			}
+			if p.post[next] != nil {
+				p.post[next].Status = StepSkippedDueToFatalErr
+			}
 			for i := next + 1; i < len(p.pre); i++ {
-				p.pre[i].Status = StepSkippedDueToFatalErr
-				p.post[i].Status = StepSkippedDueToFatalErr
+				if p.pre[i] != nil {
+					p.pre[i].Status = StepSkippedDueToFatalErr
+				}
+				if p.post[i] != nil {
+					p.post[i].Status = StepSkippedDueToFatalErr
+				}
 			}
 			p.cb.Status = StepSkippedDueToFatalErr
 		})
@@ -265,9 +307,12 @@ func (p *Plan) Run(ctx context.Context, dryRun bool) {
 	next-- // now at index of last executed pre-edge
 	for ; next >= 0; next-- {
 		e := p.post[next]
+		if e == nil {
+			continue // pre-only hook
+		}
 		l := l.WithField("hook", e.Hook)
 
-		if p.pre[next].Status != StepOk {
+		if p.pre[next] != nil && p.pre[next].Status != StepOk {
 			if p.pre[next].Status != StepErr {
 				panic(fmt.Sprintf("expecting a pre-edge hook report to be either Ok or Err, got %s", p.pre[next].Status))
 			}
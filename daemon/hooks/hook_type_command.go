@@ -113,16 +113,37 @@ func NewCommandHook(in *config.HookCommand) (r *CommandHook, err error) {
 		timeout:    in.Timeout,
 	}
 
-	r.filter, err = filters.DatasetMapFilterFromConfig(in.Filesystems)
+	// An omitted or empty filter means all filesystems of the job.
+	filesystems := in.Filesystems
+	if len(filesystems) == 0 {
+		filesystems = config.FilesystemsFilter{"<": true}
+	}
+	r.filter, err = filters.DatasetMapFilterFromConfig(filesystems)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse filesystem filter: %s", err)
 	}
 
-	r.edge = Pre | Post
+	switch in.When {
+	case "pre":
+		r.edge = Pre
+	case "post":
+		r.edge = Post
+		if r.errIsFatal {
+			return nil, fmt.Errorf("err_is_fatal has no effect for hooks that only run post-snapshot (when: post)")
+		}
+	case "both":
+		r.edge = Pre | Post
+	default:
+		return nil, fmt.Errorf("invalid value for when: %q, must be one of pre, post, both", in.When)
+	}
 
 	return r, nil
 }
 
+func (h *CommandHook) Edges() Edge {
+	return h.edge
+}
+
 func (h *CommandHook) Filesystems() Filter {
 	return h.filter
 }
@@ -136,19 +157,26 @@ func (h *CommandHook) String() string {
 }
 
 func (h *CommandHook) Run(ctx context.Context, edge Edge, phase Phase, dryRun bool, extra Env, state map[interface{}]interface{}) HookReport {
-	l := getLogger(ctx).WithField("command", h.command)
+	hookEnv := NewHookEnv(edge, phase, dryRun, h.timeout, extra)
+	return runCommand(ctx, h.command, h.timeout, hookEnv, nil)
+}
 
-	cmdCtx, cancel := context.WithTimeout(ctx, h.timeout)
+// runCommand runs command with hookEnv added to the daemon's environment
+// and stdin (may be nil) connected to its standard input.
+func runCommand(ctx context.Context, command string, timeout time.Duration, hookEnv Env, stdin io.Reader) *CommandHookReport {
+	l := getLogger(ctx).WithField("command", command)
+
+	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmdExec := exec.CommandContext(cmdCtx, h.command)
+	cmdExec := exec.CommandContext(cmdCtx, command)
 
-	hookEnv := NewHookEnv(edge, phase, dryRun, h.timeout, extra)
 	cmdEnv := os.Environ()
 	for k, v := range hookEnv {
 		cmdEnv = append(cmdEnv, fmt.Sprintf("%s=%s", k, v))
 	}
 	cmdExec.Env = cmdEnv
+	cmdExec.Stdin = stdin
 
 	var scanMutex sync.Mutex
 	combinedOutput, err := circlog.NewCircularLog(envconst.Int("ZREPL_MAX_HOOK_LOG_SIZE", MAX_HOOK_LOG_SIZE_DEFAULT))
@@ -164,7 +192,7 @@ func (h *CommandHook) Run(ctx context.Context, edge Edge, phase Phase, dryRun bo
 	cmdExec.Stdout = io.MultiWriter(logOutWriter, combinedOutput)
 
 	report := &CommandHookReport{
-		Command: h.command,
+		Command: command,
 		Env:     hookEnv,
 		// no report.Args
 	}
@@ -181,7 +209,7 @@ func (h *CommandHook) Run(ctx context.Context, edge Edge, phase Phase, dryRun bo
 	copy(report.CapturedStdoutStderrCombined, combinedOutputBytes)
 	if err != nil {
 		if cmdCtx.Err() == context.DeadlineExceeded {
-			report.Err = fmt.Errorf("timed out after %s: %s", h.timeout, err)
+			report.Err = fmt.Errorf("timed out after %s: %s", timeout, err)
 			return report
 		}
 		report.Err = err
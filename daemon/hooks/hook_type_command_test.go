@@ -0,0 +1,51 @@
+package hooks_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestCommandHookFilesystems(t *testing.T) {
+	fs, err := zfs.NewDatasetPath("pool/any/fs")
+	require.NoError(t, err)
+
+	hookConf := func(filesystems config.FilesystemsFilter) *config.HookCommand {
+		return &config.HookCommand{
+			Path:               "/bin/true",
+			Timeout:            10 * time.Second,
+			Filesystems:        filesystems,
+			When:               "both",
+			HookSettingsCommon: config.HookSettingsCommon{Type: "command"},
+		}
+	}
+
+	// the config default if the field is omitted: all filesystems of the job
+	h, err := hooks.NewCommandHook(hookConf(config.FilesystemsFilter{"<": true}))
+	require.NoError(t, err)
+	pass, err := h.Filesystems().Filter(fs)
+	require.NoError(t, err)
+	assert.True(t, pass)
+
+	// explicitly empty: all filesystems of the job as well
+	for _, empty := range []config.FilesystemsFilter{{}, nil} {
+		h, err = hooks.NewCommandHook(hookConf(empty))
+		require.NoError(t, err)
+		pass, err = h.Filesystems().Filter(fs)
+		require.NoError(t, err)
+		assert.True(t, pass)
+	}
+
+	// a filter that matches nothing is accepted, it is not empty
+	h, err = hooks.NewCommandHook(hookConf(config.FilesystemsFilter{"<": false}))
+	require.NoError(t, err)
+	pass, err = h.Filesystems().Filter(fs)
+	require.NoError(t, err)
+	assert.False(t, pass)
+}
@@ -0,0 +1,115 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+const (
+	EnvJob         HookEnvVar = "ZREPL_JOB"
+	EnvErrorCount  HookEnvVar = "ZREPL_ERROR_COUNT"
+	EnvErrorPhases HookEnvVar = "ZREPL_ERROR_PHASES"
+)
+
+// JobError is an error that occurred during a phase of a job invocation.
+type JobError struct {
+	Phase      string // e.g. replication, prune_sender
+	Filesystem string // empty if the error is not specific to a filesystem
+	Err        string
+}
+
+// OnErrorHook runs a command once per failed job invocation.
+// The errors of the invocation are passed on stdin, one per line,
+// as tab-separated phase, filesystem (may be empty) and error message.
+type OnErrorHook struct {
+	command string
+	timeout time.Duration
+}
+
+func OnErrorHookFromConfig(in config.HookEnum) (*OnErrorHook, error) {
+	v, ok := in.Ret.(*config.HookCommand)
+	if !ok {
+		return nil, fmt.Errorf("only hooks of type `command` are supported, got %T", in.Ret)
+	}
+	if v.When != "both" {
+		return nil, fmt.Errorf("`when` has no effect for on_error hooks")
+	}
+	if v.ErrIsFatal {
+		return nil, fmt.Errorf("`err_is_fatal` has no effect for on_error hooks")
+	}
+	if len(v.Filesystems) != 1 || !v.Filesystems["<"] {
+		return nil, fmt.Errorf("`filesystems` has no effect for on_error hooks")
+	}
+	return &OnErrorHook{command: v.Path, timeout: v.Timeout}, nil
+}
+
+func OnErrorHooksFromConfig(in config.HookList) ([]*OnErrorHook, error) {
+	hl := make([]*OnErrorHook, len(in))
+	for i, h := range in {
+		var err error
+		hl[i], err = OnErrorHookFromConfig(h)
+		if err != nil {
+			return nil, fmt.Errorf("create on_error hook #%d: %s", i+1, err)
+		}
+	}
+	return hl, nil
+}
+
+func (h *OnErrorHook) String() string { return h.command }
+
+func onErrorHookEnv(job string, timeout time.Duration, errs []JobError) Env {
+	phases := make(map[string]bool)
+	for _, e := range errs {
+		phases[e.Phase] = true
+	}
+	phaseList := make([]string, 0, len(phases))
+	for p := range phases {
+		phaseList = append(phaseList, p)
+	}
+	sort.Strings(phaseList)
+	return Env{
+		EnvType:        "on_error",
+		EnvTimeout:     fmt.Sprintf("%.f", math.Floor(timeout.Seconds())),
+		EnvJob:         job,
+		EnvErrorCount:  strconv.Itoa(len(errs)),
+		EnvErrorPhases: strings.Join(phaseList, ","),
+	}
+}
+
+func onErrorHookStdin(errs []JobError) string {
+	oneLine := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+	var b strings.Builder
+	for _, e := range errs {
+		fmt.Fprintf(&b, "%s\t%s\t%s\n", e.Phase, e.Filesystem, oneLine.Replace(e.Err))
+	}
+	return b.String()
+}
+
+func (h *OnErrorHook) Run(ctx context.Context, job string, errs []JobError) *CommandHookReport {
+	env := onErrorHookEnv(job, h.timeout, errs)
+	return runCommand(ctx, h.command, h.timeout, env, strings.NewReader(onErrorHookStdin(errs)))
+}
+
+// RunOnErrorHooks runs each hook in hooks once with all errs.
+// Failures of the hooks are logged.
+func RunOnErrorHooks(ctx context.Context, hooks []*OnErrorHook, job string, errs []JobError) {
+	if len(errs) == 0 {
+		return
+	}
+	for _, h := range hooks {
+		l := getLogger(ctx).WithField("hook", h.String())
+		report := h.Run(ctx, job, errs)
+		if report.HadError() {
+			l.WithField("report", report.String()).WithError(report.Err).Error("on_error hook failed")
+		} else {
+			l.WithField("report", report.String()).Info("on_error hook completed")
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package hooks_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+)
+
+func TestOnErrorHook(t *testing.T) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	hookConf := func(when string, errIsFatal bool, filesystems config.FilesystemsFilter) config.HookEnum {
+		return config.HookEnum{Ret: &config.HookCommand{
+			Path:               filepath.Join(cwd, "test", "test-on-error.sh"),
+			Timeout:            10 * time.Second,
+			Filesystems:        filesystems,
+			When:               when,
+			HookSettingsCommon: config.HookSettingsCommon{Type: "command", ErrIsFatal: errIsFatal},
+		}}
+	}
+	all := config.FilesystemsFilter{"<": true}
+
+	_, err = hooks.OnErrorHookFromConfig(hookConf("pre", false, all))
+	assert.Error(t, err)
+	_, err = hooks.OnErrorHookFromConfig(hookConf("both", true, all))
+	assert.Error(t, err)
+	_, err = hooks.OnErrorHookFromConfig(hookConf("both", false, config.FilesystemsFilter{"pool<": true}))
+	assert.Error(t, err)
+	_, err = hooks.OnErrorHookFromConfig(config.HookEnum{Ret: &config.HookPostgresCheckpoint{}})
+	assert.Error(t, err)
+
+	h, err := hooks.OnErrorHookFromConfig(hookConf("both", false, all))
+	require.NoError(t, err)
+
+	errs := []hooks.JobError{
+		{Phase: "replication", Filesystem: "pool/a", Err: "step failed:\nconnection reset"},
+		{Phase: "replication", Filesystem: "pool/b", Err: "step failed"},
+		{Phase: "prune_sender", Err: "cannot list filesystems"},
+	}
+	report := h.Run(context.Background(), "push", errs)
+	require.NoError(t, report.Err)
+	assert.Equal(t, "TEST on_error push 3 prune_sender,replication\n"+
+		"replication\tpool/a\tstep failed: connection reset\n"+
+		"replication\tpool/b\tstep failed\n"+
+		"prune_sender\t\tcannot list filesystems\n",
+		string(report.CapturedStdoutStderrCombined))
+}
@@ -334,6 +334,65 @@ jobs:
 			},
 		},
 
+		testCase{
+			Name:   "when_pre_runs_only_pre_edge",
+			Config: []string{`{type: command, path: {{.WorkDir}}/test/test-report-env.sh, when: pre}`},
+			ExpectStepReports: []expectStep{
+				expectStep{
+					ExpectedEdge: hooks.Pre,
+					ExpectStatus: hooks.StepOk,
+					OutputTest:   containsTest(fmt.Sprintf("TEST pre_testing %s@%s", testFSName, testSnapshotName)),
+				},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepOk},
+			},
+		},
+
+		testCase{
+			Name:   "when_post_runs_only_post_edge",
+			Config: []string{`{type: command, path: {{.WorkDir}}/test/test-report-env.sh, when: post}`},
+			ExpectStepReports: []expectStep{
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepOk},
+				expectStep{
+					ExpectedEdge: hooks.Post,
+					ExpectStatus: hooks.StepOk,
+					OutputTest:   containsTest(fmt.Sprintf("TEST post_testing %s@%s", testFSName, testSnapshotName)),
+				},
+			},
+		},
+
+		testCase{
+			Name: "when_post_error_does_not_affect_other_hooks",
+			Config: []string{
+				`{type: command, path: {{.WorkDir}}/test/test-report-env.sh}`,
+				`{type: command, path: {{.WorkDir}}/test/test-error.sh, when: post}`,
+			},
+			ExpectHadError: true,
+			ExpectStepReports: []expectStep{
+				expectStep{ExpectedEdge: hooks.Pre, ExpectStatus: hooks.StepOk},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepOk},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepErr},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepOk},
+			},
+		},
+
+		testCase{
+			Name: "when_pre_fatal_error_skips_post_only_hooks",
+			Config: []string{
+				`{type: command, path: {{.WorkDir}}/test/test-report-env.sh, when: post}`,
+				`{type: command, path: {{.WorkDir}}/test/test-error.sh, when: pre, err_is_fatal: true}`,
+				`{type: command, path: {{.WorkDir}}/test/test-report-env.sh, when: post}`,
+			},
+			ExpectCallbackSkipped: true,
+			ExpectHadError:        true,
+			ExpectHadFatalErr:     true,
+			ExpectStepReports: []expectStep{
+				expectStep{ExpectedEdge: hooks.Pre, ExpectStatus: hooks.StepErr},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+			},
+		},
+
 		/*
 			Following not intended to test functionality of
 			filter package. Only to demonstrate that hook
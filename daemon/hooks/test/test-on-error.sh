@@ -0,0 +1,4 @@
+#!/bin/sh -eu
+
+echo "TEST $ZREPL_HOOKTYPE $ZREPL_JOB $ZREPL_ERROR_COUNT $ZREPL_ERROR_PHASES"
+cat
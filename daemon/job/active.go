@@ -3,7 +3,9 @@ package job
 import (
 	"context"
 	"fmt"
+	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -12,9 +14,14 @@ import (
 
 	"github.com/zrepl/zrepl/daemon/logging/trace"
 	"github.com/zrepl/zrepl/util/envconst"
+	"github.com/zrepl/zrepl/util/semaphore"
 
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/jobstate"
+	"github.com/zrepl/zrepl/daemon/job/pause"
 	"github.com/zrepl/zrepl/daemon/job/reset"
+	"github.com/zrepl/zrepl/daemon/job/runnow"
 	"github.com/zrepl/zrepl/daemon/job/wakeup"
 	"github.com/zrepl/zrepl/daemon/pruner"
 	"github.com/zrepl/zrepl/daemon/snapper"
@@ -22,11 +29,13 @@ import (
 	"github.com/zrepl/zrepl/replication"
 	"github.com/zrepl/zrepl/replication/driver"
 	"github.com/zrepl/zrepl/replication/logic"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
 	"github.com/zrepl/zrepl/replication/report"
 	"github.com/zrepl/zrepl/rpc"
 	"github.com/zrepl/zrepl/transport"
 	"github.com/zrepl/zrepl/transport/fromconfig"
 	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
 type ActiveSide struct {
@@ -35,13 +44,44 @@ type ActiveSide struct {
 	connecter transport.Connecter
 
 	replicationDriverConfig driver.Config
+	replicationWindow       *replicationWindow // nil if replication is always allowed
 
 	prunerFactory *pruner.PrunerFactory
 
+	concurrentJobs *concurrentJobsLimiter
+	dependencies   *jobDependencies
+
+	zfsPriority *zfscmd.Priority // nil means unchanged
+
+	onErrorHooks []*hooks.OnErrorHook
+	// nil unless hooks.pool_checkpoint is configured
+	poolCheckpoints *poolCheckpoints
+
+	manualRun manualRunTracker
+
+	knownFilesystems *knownFilesystems
+
+	clockSkew *clockSkewTracker
+
+	resumeTokens *resumeTokenTracker
+	// nil unless replication.protection.resumable_state_max_age is set
+	resumableStateExpiry *resumableStateExpiry
+
+	phaseOrder phaseOrder
+
+	// nil unless report_file is set
+	reportFile *reportFile
+
+	retry activeSideRetry
+
 	promRepStateSecs      *prometheus.HistogramVec // labels: state
 	promPruneSecs         *prometheus.HistogramVec // labels: prune_side
 	promBytesReplicated   *prometheus.CounterVec   // labels: filesystem
 	promReplicationErrors prometheus.Gauge
+	// not reset between invocations, see recordReplicationOutcome
+	promLastSuccessfulReplication prometheus.Gauge
+	promFailuresSinceLastSuccess  prometheus.Gauge
+	replicationOutcome            replicationOutcomeState // only accessed by the Run loop
 
 	tasksMtx sync.Mutex
 	tasks    activeSideTasks
@@ -60,6 +100,12 @@ const (
 type activeSideTasks struct {
 	state ActiveSideState
 
+	// non-zero while the invocation waits for the replication window to open
+	waitingForReplicationWindowUntil time.Time
+
+	// non-zero while the invocation waits for other jobs, see concurrentJobsLimiter
+	queuedSince time.Time
+
 	// valid for state ActiveSideReplicating, ActiveSidePruneSender, ActiveSidePruneReceiver, ActiveSideDone
 	replicationReport driver.ReportFunc
 	replicationCancel context.CancelFunc
@@ -90,40 +136,82 @@ type activeMode interface {
 	Type() Type
 	PlannerPolicy() logic.PlannerPolicy
 	RunPeriodic(ctx context.Context, wakeUpCommon chan<- struct{})
+	// SnapshotNow takes snapshots out of band, no-op if the mode does not snapshot.
+	SnapshotNow(ctx context.Context) error
+	// SnapshotDue takes the snapshots deferred to the job by phase_order, no-op if there are none.
+	SnapshotDue(ctx context.Context) error
 	SnapperReport() *snapper.Report
 	ResetConnectBackoff()
 }
 
+// rpcClient is the peer of an active job.
+type rpcClient interface {
+	logic.Sender
+	// logic.Receiver, spelled out because it overlaps with logic.Sender
+	Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.ReadCloser) (*pdu.ReceiveRes, error)
+	Close()
+	ResetConnectBackoff()
+}
+
+// newRPCClient returns an *rpc.DirectClient for local connects with `direct` enabled,
+// and an *rpc.Client that connects through connecter otherwise.
+func newRPCClient(ctx context.Context, connecter transport.Connecter) rpcClient {
+	if c, ok := connecter.(activeSideConnecter); ok {
+		if dc := c.directClient(); dc != nil {
+			return dc
+		}
+	}
+	return rpc.NewClient(connecter, rpc.GetLoggersOrPanic(ctx))
+}
+
 type modePush struct {
 	setupMtx      sync.Mutex
 	sender        *endpoint.Sender
-	receiver      *rpc.Client
+	receiver      rpcClient
 	senderConfig  *endpoint.SenderConfig
 	plannerPolicy *logic.PlannerPolicy
 	snapper       *snapper.PeriodicOrManual
+
+	// non-nil if the streams are archived instead of sent to a sink (connect type `archive`),
+	// receiver is nil then
+	archiveConfig *endpoint.ArchiveReceiverConfig
+	archive       *endpoint.ArchiveReceiver
+
+	// 0 if replication only runs after snapshotting
+	replicationInterval time.Duration
 }
 
 func (m *modePush) ConnectEndpoints(ctx context.Context, connecter transport.Connecter) {
 	m.setupMtx.Lock()
 	defer m.setupMtx.Unlock()
-	if m.receiver != nil || m.sender != nil {
+	if m.receiver != nil || m.archive != nil || m.sender != nil {
 		panic("inconsistent use of ConnectEndpoints and DisconnectEndpoints")
 	}
 	m.sender = endpoint.NewSender(*m.senderConfig)
-	m.receiver = rpc.NewClient(connecter, rpc.GetLoggersOrPanic(ctx))
+	if m.archiveConfig != nil {
+		m.archive = endpoint.NewArchiveReceiver(*m.archiveConfig)
+		return
+	}
+	m.receiver = newRPCClient(ctx, connecter)
 }
 
 func (m *modePush) DisconnectEndpoints() {
 	m.setupMtx.Lock()
 	defer m.setupMtx.Unlock()
-	m.receiver.Close()
+	if m.receiver != nil {
+		m.receiver.Close()
+	}
 	m.sender = nil
 	m.receiver = nil
+	m.archive = nil
 }
 
 func (m *modePush) SenderReceiver() (logic.Sender, logic.Receiver) {
 	m.setupMtx.Lock()
 	defer m.setupMtx.Unlock()
+	if m.archive != nil {
+		return m.sender, m.archive
+	}
 	return m.sender, m.receiver
 }
 
@@ -132,9 +220,43 @@ func (m *modePush) Type() Type { return TypePush }
 func (m *modePush) PlannerPolicy() logic.PlannerPolicy { return *m.plannerPolicy }
 
 func (m *modePush) RunPeriodic(ctx context.Context, wakeUpCommon chan<- struct{}) {
+	if m.replicationInterval > 0 {
+		go runReplicationInterval(ctx, m.replicationInterval, wakeUpCommon)
+	}
 	m.snapper.Run(ctx, wakeUpCommon)
 }
 
+// runReplicationInterval wakes up the job every interval, independently of snapshotting.
+// Like the snapper, it never blocks on wakeUpCommon: if an invocation is in progress,
+// the wakeup is dropped because that invocation already replicates all existing snapshots
+// or is followed by the wakeup of the next snapshotting round.
+func runReplicationInterval(ctx context.Context, interval time.Duration, wakeUpCommon chan<- struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			select {
+			case wakeUpCommon <- struct{}{}:
+			default:
+				GetLogger(ctx).
+					WithField("replication_interval", interval).
+					Debug("invocation in progress, skipping replication_interval wakeup")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *modePush) SnapshotNow(ctx context.Context) error {
+	return m.snapper.SnapshotNow(ctx)
+}
+
+func (m *modePush) SnapshotDue(ctx context.Context) error {
+	return m.snapper.SnapshotDue(ctx)
+}
+
 func (m *modePush) SnapperReport() *snapper.Report {
 	return m.snapper.Report()
 }
@@ -160,20 +282,46 @@ func modePushFromConfig(g *config.Global, in *config.PushJob, jobID endpoint.Job
 	if err != nil {
 		return nil, errors.Wrap(err, "field `replication`")
 	}
+	sendIntermediates, err := logic.SendIntermediatesFromConfig(in.Replication)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `replication`")
+	}
 
+	encryptedSend := logic.TriFromBool(in.Send.Encrypted)
+	for _, o := range in.SendOverrides {
+		if o.Send.Encrypted != in.Send.Encrypted {
+			// the sender enforces `encrypted` per filesystem
+			encryptedSend = logic.DontCare
+		}
+	}
 	m.plannerPolicy = &logic.PlannerPolicy{
-		EncryptedSend:             logic.TriFromBool(in.Send.Encrypted),
+		EncryptedSend:             encryptedSend,
 		ReplicationConfig:         replicationConfig,
 		SizeEstimationConcurrency: in.Replication.Concurrency.SizeEstimates,
+		SendIntermediates:         sendIntermediates,
 	}
 	if err := m.plannerPolicy.Validate(); err != nil {
 		return nil, errors.Wrap(err, "cannot build planner policy")
 	}
 
-	if m.snapper, err = snapper.FromConfig(g, m.senderConfig.FSF, in.Snapshotting); err != nil {
+	if m.snapper, err = snapper.FromConfig(g, jobID.String(), m.senderConfig.FSF, in.Snapshotting, minFreeSpaceFromConfig(in.MinFreeSpace)); err != nil {
 		return nil, errors.Wrap(err, "cannot build snapper")
 	}
 
+	m.replicationInterval = in.ReplicationInterval
+
+	if a, ok := in.Connect.Ret.(*config.ArchiveConnect); ok {
+		m.archiveConfig = &endpoint.ArchiveReceiverConfig{
+			JobID:    jobID,
+			Command:  a.Command,
+			Manifest: a.Manifest,
+			Timeout:  a.Timeout,
+		}
+		if err := m.archiveConfig.Validate(); err != nil {
+			return nil, errors.Wrap(err, "field `connect`")
+		}
+	}
+
 	return m, nil
 }
 
@@ -181,7 +329,7 @@ type modePull struct {
 	setupMtx       sync.Mutex
 	receiver       *endpoint.Receiver
 	receiverConfig endpoint.ReceiverConfig
-	sender         *rpc.Client
+	sender         rpcClient
 	plannerPolicy  *logic.PlannerPolicy
 	interval       config.PositiveDurationOrManual
 }
@@ -193,7 +341,7 @@ func (m *modePull) ConnectEndpoints(ctx context.Context, connecter transport.Con
 		panic("inconsistent use of ConnectEndpoints and DisconnectEndpoints")
 	}
 	m.receiver = endpoint.NewReceiver(m.receiverConfig)
-	m.sender = rpc.NewClient(connecter, rpc.GetLoggersOrPanic(ctx))
+	m.sender = newRPCClient(ctx, connecter)
 }
 
 func (m *modePull) DisconnectEndpoints() {
@@ -239,6 +387,10 @@ func (m *modePull) RunPeriodic(ctx context.Context, wakeUpCommon chan<- struct{}
 	}
 }
 
+func (m *modePull) SnapshotNow(ctx context.Context) error { return nil }
+
+func (m *modePull) SnapshotDue(ctx context.Context) error { return nil }
+
 func (m *modePull) SnapperReport() *snapper.Report {
 	return nil
 }
@@ -259,11 +411,16 @@ func modePullFromConfig(g *config.Global, in *config.PullJob, jobID endpoint.Job
 	if err != nil {
 		return nil, errors.Wrap(err, "field `replication`")
 	}
+	sendIntermediates, err := logic.SendIntermediatesFromConfig(in.Replication)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `replication`")
+	}
 
 	m.plannerPolicy = &logic.PlannerPolicy{
 		EncryptedSend:             logic.DontCare,
 		ReplicationConfig:         replicationConfig,
 		SizeEstimationConcurrency: in.Replication.Concurrency.SizeEstimates,
+		SendIntermediates:         sendIntermediates,
 	}
 	if err := m.plannerPolicy.Validate(); err != nil {
 		return nil, errors.Wrap(err, "cannot build planner policy")
@@ -287,14 +444,19 @@ func replicationDriverConfigFromConfig(in *config.Replication) (c driver.Config,
 	return c, err
 }
 
-func activeSide(g *config.Global, in *config.ActiveJob, configJob interface{}) (j *ActiveSide, err error) {
+func activeSide(g *config.Global, in *config.ActiveJob, configJob interface{}, concurrentJobs *concurrentJobsLimiter, dependencies *jobDependencies, metricLabels *MetricLabels) (j *ActiveSide, err error) {
 
-	j = &ActiveSide{}
+	j = &ActiveSide{concurrentJobs: concurrentJobs, dependencies: dependencies}
 	j.name, err = endpoint.MakeJobID(in.Name)
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid job name")
 	}
 
+	j.zfsPriority, err = zfsPriorityFromConfig(g, in.ZFSPriority)
+	if err != nil {
+		return nil, err
+	}
+
 	switch v := configJob.(type) {
 	case *config.PushJob:
 		j.mode, err = modePushFromConfig(g, v, j.name) // shadow
@@ -306,20 +468,40 @@ func activeSide(g *config.Global, in *config.ActiveJob, configJob interface{}) (
 	if err != nil {
 		return nil, err // no wrapping required
 	}
+	if m, ok := j.mode.(*modePush); ok {
+		m.snapper.Limit(concurrentJobs.snapperLimiter())
+	}
+
+	j.phaseOrder, err = phaseOrderFromConfig(in.PhaseOrder)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `phase_order`")
+	}
+	if j.phaseOrder == phaseOrderReplicateFirst {
+		m, ok := j.mode.(*modePush)
+		if !ok {
+			return nil, errors.New("field `phase_order`: replicating before snapshotting is only supported by push jobs")
+		}
+		m.snapper.Defer()
+	}
+
+	j.reportFile, err = reportFileFromConfig(j.name.String(), in.ReportFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `report_file`")
+	}
 
 	j.promRepStateSecs = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace:   "zrepl",
 		Subsystem:   "replication",
 		Name:        "state_time",
 		Help:        "seconds spent during replication",
-		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
+		ConstLabels: metricLabels.ConstLabels(j.name.String()),
 	}, []string{"state"})
 	j.promBytesReplicated = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace:   "zrepl",
 		Subsystem:   "replication",
 		Name:        "bytes_replicated",
 		Help:        "number of bytes replicated from sender to receiver per filesystem",
-		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
+		ConstLabels: metricLabels.ConstLabels(j.name.String()),
 	}, []string{"filesystem"})
 
 	j.promReplicationErrors = prometheus.NewGauge(prometheus.GaugeOpts{
@@ -327,12 +509,55 @@ func activeSide(g *config.Global, in *config.ActiveJob, configJob interface{}) (
 		Subsystem:   "replication",
 		Name:        "filesystem_errors",
 		Help:        "number of filesystems that failed replication in the latest replication attempt, or -1 if the job failed before enumerating the filesystems",
-		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
+		ConstLabels: metricLabels.ConstLabels(j.name.String()),
 	})
-
-	j.connecter, err = fromconfig.ConnecterFromConfig(g, in.Connect)
-	if err != nil {
-		return nil, errors.Wrap(err, "cannot build client")
+	j.promLastSuccessfulReplication = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   "zrepl",
+		Name:        "last_successful_replication_timestamp_seconds",
+		Help:        "time at which the job last completed replication without errors, 0 if it has not yet done so",
+		ConstLabels: metricLabels.ConstLabels(j.name.String()),
+	})
+	j.promFailuresSinceLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   "zrepl",
+		Name:        "failed_replications_since_last_success",
+		Help:        "number of replication attempts that failed since the job last completed replication without errors",
+		ConstLabels: metricLabels.ConstLabels(j.name.String()),
+	})
+	j.clockSkew = &clockSkewTracker{
+		threshold: in.ClockSkewThreshold,
+		gauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   "zrepl",
+			Name:        "peer_clock_skew_seconds",
+			Help:        "difference between the peer's wall clock and ours, positive if the peer's clock is ahead, measured when connecting to the peer",
+			ConstLabels: metricLabels.ConstLabels(j.name.String()),
+		}),
+	}
+	j.resumeTokens = newResumeTokenTracker(in.StaleResumeTokenThreshold, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "replication",
+		Name:        "resume_token_unchanged_seconds",
+		Help:        "time for which the receive_resume_token of a receiving filesystem has not changed, as observed after each replication",
+		ConstLabels: metricLabels.ConstLabels(j.name.String()),
+	}, []string{"filesystem"}))
+	j.resumableStateExpiry = newResumableStateExpiry(in.Replication.Protection.ResumableStateMaxAge, j.resumeTokens)
+
+	if _, ok := in.Connect.Ret.(*config.ArchiveConnect); !ok {
+		j.connecter, err = fromconfig.ConnecterFromConfig(g, in.Connect)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot build client")
+		}
+		c := activeSideConnecter{Connecter: j.connecter, clockSkew: j.clockSkew}
+		switch m := j.mode.(type) {
+		case *modePush:
+			m.senderConfig.PeerRecvFeatures = endpoint.NewPeerRecvFeatures()
+			c.peerRecvFeatures = m.senderConfig.PeerRecvFeatures
+			c.clientIdentity = FakeActiveSideDirectMethodInvocationClientIdentity(j.name)
+		case *modePull:
+			c.recvFeatures = endpoint.NewRecvFeaturesAdvertiser(m.receiverConfig.RootWithoutClientComponent)
+		}
+		j.connecter = c
+	} else if _, ok := configJob.(*config.PushJob); !ok {
+		return nil, errors.New("connect type `archive` is only supported by push jobs")
 	}
 
 	j.promPruneSecs = prometheus.NewHistogramVec(prometheus.HistogramOpts{
@@ -340,18 +565,56 @@ func activeSide(g *config.Global, in *config.ActiveJob, configJob interface{}) (
 		Subsystem:   "pruning",
 		Name:        "time",
 		Help:        "seconds spent in pruner",
-		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
+		ConstLabels: metricLabels.ConstLabels(j.name.String()),
 	}, []string{"prune_side"})
-	j.prunerFactory, err = pruner.NewPrunerFactory(in.Pruning, j.promPruneSecs)
+	pruningConfig := in.Pruning
+	if push, ok := configJob.(*config.PushJob); ok {
+		if periodic, ok := push.Snapshotting.Ret.(*config.SnapshottingPeriodic); ok {
+			// the snapshots that this job creates are managed by it
+			pruningConfig.ManagedPrefixes = append([]string{periodic.Prefix}, in.Pruning.ManagedPrefixes...)
+		}
+	}
+	j.prunerFactory, err = pruner.NewPrunerFactory(pruningConfig, j.promPruneSecs)
 	if err != nil {
 		return nil, err
 	}
+	if _, ok := j.mode.(*modePull); ok && j.prunerFactory.SenderConvertsToBookmarks() {
+		// the sender is only reachable through the RPC layer which cannot create bookmarks
+		return nil, errors.New("field `pruning`: `convert_to_bookmark` in `keep_sender` is only supported by push jobs")
+	}
 
 	j.replicationDriverConfig, err = replicationDriverConfigFromConfig(in.Replication)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build replication driver config")
 	}
 
+	j.replicationWindow, err = replicationWindowFromConfig(in.ReplicationWindow)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `replication_window`")
+	}
+
+	j.retry.policy, err = retryPolicyFromConfig(in.Retry)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `retry`")
+	}
+
+	missingFSPolicy, err := MissingFilesystemPolicyFromString(in.OnMissingFilesystem)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `on_missing_filesystem`")
+	}
+	j.knownFilesystems = newKnownFilesystems(missingFSPolicy)
+
+	if in.Hooks != nil {
+		j.onErrorHooks, err = hooks.OnErrorHooksFromConfig(in.Hooks.OnError)
+		if err != nil {
+			return nil, errors.Wrap(err, "field `hooks`")
+		}
+		j.poolCheckpoints, err = poolCheckpointsFromConfig(in.Hooks.PoolCheckpoint)
+		if err != nil {
+			return nil, errors.Wrap(err, "field `hooks.pool_checkpoint`")
+		}
+	}
+
 	return j, nil
 }
 
@@ -360,6 +623,10 @@ func (j *ActiveSide) RegisterMetrics(registerer prometheus.Registerer) {
 	registerer.MustRegister(j.promPruneSecs)
 	registerer.MustRegister(j.promBytesReplicated)
 	registerer.MustRegister(j.promReplicationErrors)
+	registerer.MustRegister(j.promLastSuccessfulReplication)
+	registerer.MustRegister(j.promFailuresSinceLastSuccess)
+	registerer.MustRegister(j.clockSkew.gauge)
+	registerer.MustRegister(j.resumeTokens.gauge)
 }
 
 func (j *ActiveSide) Name() string { return j.name.String() }
@@ -368,6 +635,18 @@ type ActiveSideStatus struct {
 	Replication                    *report.Report
 	PruningSender, PruningReceiver *pruner.Report
 	Snapshotting                   *snapper.Report
+	// non-nil while replication is deferred until the replication window opens
+	WaitingForReplicationWindowUntil *time.Time `json:",omitempty"`
+	// non-nil while the invocation waits for other jobs because global.max_concurrent_jobs is reached
+	QueuedSince *time.Time `json:",omitempty"`
+	// the latest invocation requested through `zrepl run`, nil if there was none
+	ManualRun *ManualRunStatus `json:",omitempty"`
+	// nil until the job has connected to a peer that sends its time
+	ClockSkew *ClockSkewStatus `json:",omitempty"`
+	// the pending retry of a failed invocation, nil if there is none
+	Retry *RetryStatus `json:",omitempty"`
+	// the receive_resume_tokens observed after the latest replication, nil if there were none
+	ResumeTokens []*ResumeTokenStatus `json:",omitempty"`
 }
 
 func (j *ActiveSide) Status() *Status {
@@ -385,6 +664,18 @@ func (j *ActiveSide) Status() *Status {
 		s.PruningReceiver = tasks.prunerReceiver.Report()
 	}
 	s.Snapshotting = j.mode.SnapperReport()
+	s.ManualRun = j.manualRun.report()
+	s.ClockSkew = j.clockSkew.status()
+	s.Retry = j.retry.status()
+	s.ResumeTokens = j.resumeTokens.status()
+	if !tasks.waitingForReplicationWindowUntil.IsZero() {
+		until := tasks.waitingForReplicationWindowUntil
+		s.WaitingForReplicationWindowUntil = &until
+	}
+	if !tasks.queuedSince.IsZero() {
+		queuedSince := tasks.queuedSince
+		s.QueuedSince = &queuedSince
+	}
 	return &Status{Type: t, JobSpecific: s}
 }
 
@@ -418,6 +709,7 @@ func FakeActiveSideDirectMethodInvocationClientIdentity(jobId endpoint.JobID) st
 func (j *ActiveSide) Run(ctx context.Context) {
 	ctx, endTask := trace.WithTaskAndSpan(ctx, "active-side-job", j.Name())
 	defer endTask()
+	ctx = zfscmd.WithPriority(ctx, j.zfsPriority)
 
 	ctx = context.WithValue(ctx, endpoint.ClientIdentityKey, FakeActiveSideDirectMethodInvocationClientIdentity(j.name))
 
@@ -425,6 +717,14 @@ func (j *ActiveSide) Run(ctx context.Context) {
 
 	defer log.Info("job exiting")
 
+	j.restoreReplicationOutcome(ctx)
+	if err := j.knownFilesystems.restore(ctx); err != nil {
+		log.WithError(err).Warn("cannot load known filesystems, starting with an empty set")
+	}
+	if err := j.resumeTokens.restore(ctx); err != nil {
+		log.WithError(err).Warn("cannot load resume tokens, starting with an empty set")
+	}
+
 	periodicDone := make(chan struct{})
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -444,15 +744,76 @@ outer:
 		case <-wakeup.Wait(ctx):
 			j.mode.ResetConnectBackoff()
 		case <-periodicDone:
+		case req := <-runnow.Wait(ctx):
+			j.mode.ResetConnectBackoff()
+			invocationCount++
+			invocationCtx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("invocation-%d", invocationCount))
+			j.runNow(invocationCtx, req)
+			endSpan()
+			continue
+		case <-j.retry.wait():
+			retry := j.retry.fired()
+			if pause.IsPaused(ctx) {
+				log.Info("job is paused, dropping retry")
+				continue
+			}
+			j.mode.ResetConnectBackoff()
+			invocationCount++
+			invocationCtx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("invocation-%d-retry-%d", invocationCount, retry.Attempt))
+			GetLogger(invocationCtx).WithField("attempt", retry.Attempt).WithField("scope", retry.Scope).Info("retrying failed invocation")
+			errs := j.do(invocationCtx, retry.Scope, j.mode.SnapshotDue)
+			j.retry.afterInvocation(GetLogger(invocationCtx), retry.Attempt, errs)
+			endSpan()
+			continue
+		}
+		if pause.IsPaused(ctx) {
+			log.Info("job is paused, skipping invocation")
+			continue
 		}
 		invocationCount++
 		invocationCtx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("invocation-%d", invocationCount))
-		j.do(invocationCtx)
+		// a regular invocation supersedes a pending retry
+		j.retry.cancel()
+		errs := j.do(invocationCtx, runnow.ScopeAll, j.mode.SnapshotDue)
+		j.retry.afterInvocation(GetLogger(invocationCtx), 0, errs)
 		endSpan()
 	}
+	j.retry.cancel()
 }
 
-func (j *ActiveSide) do(ctx context.Context) {
+// do runs one invocation of the job and returns the errors of all phases.
+// Replication and pruning are skipped unless included in scope.
+// With phase_order replicate, snapshot, prune, snapshot takes the snapshots after replication.
+func (j *ActiveSide) do(ctx context.Context, scope runnow.Scope, snapshot func(context.Context) error) (invocationErrs []hooks.JobError) {
+
+	endInvocation := j.dependencies.begin()
+	defer func() { endInvocation(invocationErrs) }()
+
+	if j.reportFile != nil {
+		startedAt := time.Now()
+		defer func() {
+			j.reportFile.write(ctx, startedAt, j.updateTasks(nil), j.mode.SnapperReport(), invocationErrs)
+		}()
+	}
+
+	// With phase_order replicate, snapshot, prune, the snapshots are also taken if the invocation
+	// ends before the snapshot phase, e.g., because a dependency failed or the invocation was reset.
+	snapshotTaken := false
+	if j.phaseOrder == phaseOrderReplicateFirst && scope.Includes(runnow.ScopeSnapshot) {
+		invocationCtx := ctx // not cancelled by reset
+		defer func() {
+			if snapshotTaken || invocationCtx.Err() != nil {
+				return
+			}
+			j.manualRun.setPhase("snapshot")
+			if err := snapshot(invocationCtx); err != nil {
+				GetLogger(invocationCtx).WithError(err).Error("snapshotting failed")
+				snapErr := hooks.JobError{Phase: "snapshot", Err: err.Error()}
+				j.runOnErrorHooks(invocationCtx, []hooks.JobError{snapErr})
+				invocationErrs = append(invocationErrs, snapErr)
+			}
+		}()
+	}
 
 	j.mode.ConnectEndpoints(ctx, j.connecter)
 	defer j.mode.DisconnectEndpoints()
@@ -471,38 +832,150 @@ func (j *ActiveSide) do(ctx context.Context) {
 
 	sender, receiver := j.mode.SenderReceiver()
 
-	{
+	if errs := waitForDependencies(ctx, j.dependencies, func(dep string) {
+		GetLogger(ctx).WithField("depends_on", dep).Info("waiting for the running invocation of a job this job depends on")
+	}); len(errs) > 0 {
+		// the tasks of the previous invocation must not end up in the report of this one
+		j.updateTasks(func(tasks *activeSideTasks) {
+			*tasks = activeSideTasks{state: ActiveSideDone}
+		})
+		if ctx.Err() == nil {
+			GetLogger(ctx).WithField("reason", errs[0].Err).Error("skipping replication and pruning")
+			j.runOnErrorHooks(ctx, errs)
+		}
+		return errs
+	}
+
+	guard, ok := j.waitForReplicationWindowAndOtherJobs(ctx)
+	if !ok {
+		j.updateTasks(func(tasks *activeSideTasks) {
+			*tasks = activeSideTasks{state: ActiveSideDone}
+		})
+		return []hooks.JobError{cancelledError(ctx)}
+	}
+	defer guard.Release()
+
+	if errs := j.poolCheckpoints.create(ctx); len(errs) > 0 {
+		GetLogger(ctx).WithField("reason", errs[0].Err).Error("skipping replication and pruning")
+		j.updateTasks(func(tasks *activeSideTasks) {
+			*tasks = activeSideTasks{state: ActiveSideDone}
+		})
+		j.runOnErrorHooks(ctx, errs)
+		return errs
+	}
+	defer func() { j.poolCheckpoints.finish(ctx, invocationErrs) }()
+
+	// errors of all phases, passed to the on_error hooks at the end of the invocation
+	var errs []hooks.JobError
+
+	if abort := j.checkMissingFilesystems(ctx, sender); len(abort) > 0 {
+		j.updateTasks(func(tasks *activeSideTasks) {
+			*tasks = activeSideTasks{state: ActiveSideDone}
+		})
+		j.recordReplicationOutcome(ctx, false)
+		j.runOnErrorHooks(ctx, abort)
+		return abort
+	}
+
+	// false if replication was paused, see below
+	prune := scope.Includes(runnow.ScopePrune)
+
+	if !scope.Includes(runnow.ScopeReplicate) {
+		j.updateTasks(func(tasks *activeSideTasks) {
+			*tasks = activeSideTasks{}
+		})
+		j.manualRun.skip("replication")
+	} else {
 		select {
 		case <-ctx.Done():
-			return
+			j.updateTasks(func(tasks *activeSideTasks) {
+				*tasks = activeSideTasks{state: ActiveSideDone}
+			})
+			return append(errs, cancelledError(ctx))
 		default:
 		}
 		ctx, endSpan := trace.WithSpan(ctx, "replication")
 		ctx, repCancel := context.WithCancel(ctx)
+		// set to 1 if the replication window closes during replication (on_close: pause)
+		var paused int32
+		stopPauseTimer := func() {}
+		if j.replicationWindow != nil && j.replicationWindow.pauseOnClose {
+			if closes := j.replicationWindow.NextClose(time.Now()); !closes.IsZero() {
+				pauseTimer := time.AfterFunc(time.Until(closes), func() {
+					GetLogger(ctx).WithField("closed_at", closes).Info("replication window closed, pausing replication until the window opens again")
+					atomic.StoreInt32(&paused, 1)
+					repCancel()
+				})
+				stopPauseTimer = func() { pauseTimer.Stop() }
+			}
+		}
+		if j.resumableStateExpiry != nil {
+			j.resumableStateExpiry.releaseStepHolds(ctx, j.SenderConfig())
+		}
 		var repWait driver.WaitFunc
 		j.updateTasks(func(tasks *activeSideTasks) {
 			// reset it
 			*tasks = activeSideTasks{}
 			tasks.replicationCancel = func() { repCancel(); endSpan() }
 			tasks.replicationReport, repWait = replication.Do(
-				ctx, j.replicationDriverConfig, logic.NewPlanner(j.promRepStateSecs, j.promBytesReplicated, sender, receiver, j.mode.PlannerPolicy()),
+				ctx, j.replicationDriverConfig, logic.NewPlanner(j.promRepStateSecs, j.promBytesReplicated, sender, j.resumableStateExpiry.receiver(receiver), j.mode.PlannerPolicy()),
 			)
 			tasks.state = ActiveSideReplicating
 		})
+		j.manualRun.setPhase("replication")
 		GetLogger(ctx).Info("start replication")
 		repWait(true) // wait blocking
-		repCancel()   // always cancel to free up context resources
-
-		replicationReport := j.tasks.replicationReport()
-		j.promReplicationErrors.Set(float64(replicationReport.GetFailedFilesystemsCountInLatestAttempt()))
+		stopPauseTimer()
+		if ctx.Err() == nil {
+			if err := j.resumeTokens.observe(ctx, receiver); err != nil {
+				GetLogger(ctx).WithError(err).Warn("cannot list receiving filesystems to check their resume tokens")
+			}
+		}
+		repCancel() // always cancel to free up context resources
+
+		if atomic.LoadInt32(&paused) == 1 {
+			// The filesystems cancelled by the pause did not fail, replication continues
+			// in the next invocation. Pruning must wait for the replication to complete.
+			GetLogger(ctx).Info("replication paused, skipping pruning")
+			prune = false
+			j.manualRun.skip("prune_sender", "prune_receiver")
+		} else {
+			replicationReport := j.tasks.replicationReport()
+			j.promReplicationErrors.Set(float64(replicationReport.GetFailedFilesystemsCountInLatestAttempt()))
+			repErrs := replicationErrors(replicationReport)
+			j.recordReplicationOutcome(ctx, len(repErrs) == 0)
+			errs = append(errs, repErrs...)
+		}
+		j.clockSkew.logIfExceeded(GetLogger(ctx))
+		j.resumeTokens.logIfStale(GetLogger(ctx))
 
 		endSpan()
 	}
 
-	{
+	if j.phaseOrder == phaseOrderReplicateFirst {
+		if !scope.Includes(runnow.ScopeSnapshot) {
+			j.manualRun.skip("snapshot")
+		} else {
+			select {
+			case <-ctx.Done():
+				return append(errs, cancelledError(ctx))
+			default:
+			}
+			j.manualRun.setPhase("snapshot")
+			snapshotTaken = true
+			if err := snapshot(ctx); err != nil {
+				GetLogger(ctx).WithError(err).Error("snapshotting failed, continuing with pruning")
+				errs = append(errs, hooks.JobError{Phase: "snapshot", Err: err.Error()})
+			}
+		}
+	}
+
+	if !scope.Includes(runnow.ScopePrune) {
+		j.manualRun.skip("prune_sender", "prune_receiver")
+	} else if prune {
 		select {
 		case <-ctx.Done():
-			return
+			return append(errs, cancelledError(ctx))
 		default:
 		}
 		ctx, endSpan := trace.WithSpan(ctx, "prune_sender")
@@ -512,16 +985,18 @@ func (j *ActiveSide) do(ctx context.Context) {
 			tasks.prunerSenderCancel = func() { senderCancel(); endSpan() }
 			tasks.state = ActiveSidePruneSender
 		})
+		j.manualRun.setPhase("prune_sender")
 		GetLogger(ctx).Info("start pruning sender")
 		tasks.prunerSender.Prune()
 		GetLogger(ctx).Info("finished pruning sender")
+		errs = append(errs, prunerErrors("prune_sender", tasks.prunerSender.Report())...)
 		senderCancel()
 		endSpan()
 	}
-	{
+	if prune {
 		select {
 		case <-ctx.Done():
-			return
+			return append(errs, cancelledError(ctx))
 		default:
 		}
 		ctx, endSpan := trace.WithSpan(ctx, "prune_recever")
@@ -531,9 +1006,11 @@ func (j *ActiveSide) do(ctx context.Context) {
 			tasks.prunerReceiverCancel = func() { receiverCancel(); endSpan() }
 			tasks.state = ActiveSidePruneReceiver
 		})
+		j.manualRun.setPhase("prune_receiver")
 		GetLogger(ctx).Info("start pruning receiver")
 		tasks.prunerReceiver.Prune()
 		GetLogger(ctx).Info("finished pruning receiver")
+		errs = append(errs, prunerErrors("prune_receiver", tasks.prunerReceiver.Report())...)
 		receiverCancel()
 		endSpan()
 	}
@@ -542,4 +1019,152 @@ func (j *ActiveSide) do(ctx context.Context) {
 		tasks.state = ActiveSideDone
 	})
 
+	j.runOnErrorHooks(ctx, errs)
+	return errs
+}
+
+// runNow runs the invocation that was requested through `zrepl run`:
+// snapshots are taken out of band before replication and pruning, unless the request's scope excludes them.
+// With phase_order replicate, snapshot, prune, they are taken after replication instead.
+func (j *ActiveSide) runNow(ctx context.Context, req runnow.Request) {
+	log := GetLogger(ctx).WithField("manual_run", req.ID).WithField("scope", req.Scope)
+	log.Info("start manual invocation")
+	j.manualRun.start(req)
+	var errs []hooks.JobError
+	if j.phaseOrder == phaseOrderReplicateFirst && req.Scope != runnow.ScopeSnapshot {
+		// do takes the snapshots
+	} else if req.Scope.Includes(runnow.ScopeSnapshot) {
+		j.manualRun.setPhase("snapshot")
+		if err := j.mode.SnapshotNow(ctx); err != nil {
+			GetLogger(ctx).WithError(err).Error("snapshotting failed, continuing with replication")
+			errs = append(errs, hooks.JobError{Phase: "snapshot", Err: err.Error()})
+		}
+	} else {
+		j.manualRun.skip("snapshot")
+	}
+	if req.Scope == runnow.ScopeSnapshot {
+		j.manualRun.skip("replication", "prune_sender", "prune_receiver")
+	} else {
+		errs = append(errs, j.do(ctx, req.Scope, j.mode.SnapshotNow)...)
+	}
+	j.manualRun.finish(errs)
+	log.WithField("error_count", len(errs)).Info("finished manual invocation")
+}
+
+func (j *ActiveSide) runOnErrorHooks(ctx context.Context, errs []hooks.JobError) {
+	if len(errs) > 0 && len(j.onErrorHooks) > 0 {
+		GetLogger(ctx).WithField("error_count", len(errs)).Info("running on_error hooks")
+		hooks.RunOnErrorHooks(ctx, j.onErrorHooks, j.name.String(), errs)
+	}
+}
+
+// waitForReplicationWindowAndOtherJobs returns once the replication window is open
+// and no more than global.max_concurrent_jobs other jobs are running.
+// The returned guard must be released at the end of the invocation.
+// ok is false if ctx is done before.
+func (j *ActiveSide) waitForReplicationWindowAndOtherJobs(ctx context.Context) (guard *semaphore.AcquireGuard, ok bool) {
+	for {
+		if !j.waitForReplicationWindow(ctx) {
+			return nil, false
+		}
+		guard, err := j.concurrentJobs.acquire(ctx, func() {
+			GetLogger(ctx).Info("max_concurrent_jobs reached, waiting for other jobs before replication")
+			j.updateTasks(func(tasks *activeSideTasks) {
+				*tasks = activeSideTasks{}
+				tasks.queuedSince = time.Now()
+			})
+		})
+		j.updateTasks(func(tasks *activeSideTasks) {
+			tasks.queuedSince = time.Time{}
+		})
+		if err != nil {
+			return nil, false
+		}
+		if j.replicationWindow == nil || j.replicationWindow.Contains(time.Now()) {
+			return guard, true
+		}
+		// the window closed while waiting for other jobs
+		guard.Release()
+	}
+}
+
+// waitForReplicationWindow blocks until the replication window is open.
+// It returns false if ctx is done before that.
+func (j *ActiveSide) waitForReplicationWindow(ctx context.Context) bool {
+	if j.replicationWindow == nil {
+		return true
+	}
+	defer j.updateTasks(func(tasks *activeSideTasks) {
+		tasks.waitingForReplicationWindowUntil = time.Time{}
+	})
+	for {
+		now := time.Now()
+		opens := j.replicationWindow.NextOpen(now)
+		if !opens.After(now) {
+			return true
+		}
+		GetLogger(ctx).WithField("opens_at", opens).Info("outside of replication window, deferring replication")
+		j.updateTasks(func(tasks *activeSideTasks) {
+			*tasks = activeSideTasks{}
+			tasks.waitingForReplicationWindowUntil = opens
+		})
+		t := time.NewTimer(time.Until(opens))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return false
+		case <-t.C:
+			// re-check, the system clock might have changed in the meantime
+		}
+	}
+}
+
+// replicationOutcomeStateKey is the jobstate key under which recordReplicationOutcome persists its metrics.
+const replicationOutcomeStateKey = "replication_outcome"
+
+type replicationOutcomeState struct {
+	LastSuccess              time.Time
+	FailuresSinceLastSuccess int
+}
+
+func (s replicationOutcomeState) setMetrics(j *ActiveSide) {
+	if !s.LastSuccess.IsZero() {
+		j.promLastSuccessfulReplication.Set(float64(s.LastSuccess.UnixNano()) / 1e9)
+	}
+	j.promFailuresSinceLastSuccess.Set(float64(s.FailuresSinceLastSuccess))
+}
+
+// restoreReplicationOutcome loads the metrics persisted by recordReplicationOutcome
+// from the job's state store, if any (see jobstate.Context).
+func (j *ActiveSide) restoreReplicationOutcome(ctx context.Context) {
+	store := jobstate.FromContext(ctx)
+	if store == nil {
+		return
+	}
+	var s replicationOutcomeState
+	if found, err := store.Load(replicationOutcomeStateKey, &s); err != nil {
+		GetLogger(ctx).WithError(err).Warn("cannot load replication outcome, metrics start from scratch")
+		return
+	} else if !found {
+		return
+	}
+	j.replicationOutcome = s
+	s.setMetrics(j)
+}
+
+// recordReplicationOutcome updates the metrics that track the freshness of the job's replication.
+// Unlike the other replication metrics, they accumulate across invocations
+// and are persisted in the job's state store, if any, to survive daemon restarts.
+func (j *ActiveSide) recordReplicationOutcome(ctx context.Context, success bool) {
+	if success {
+		j.replicationOutcome = replicationOutcomeState{LastSuccess: time.Now()}
+	} else {
+		j.replicationOutcome.FailuresSinceLastSuccess++
+	}
+	j.replicationOutcome.setMetrics(j)
+	if store := jobstate.FromContext(ctx); store != nil {
+		if err := store.Store(replicationOutcomeStateKey, j.replicationOutcome); err != nil {
+			GetLogger(ctx).WithError(err).Warn("cannot persist replication outcome")
+		}
+	}
 }
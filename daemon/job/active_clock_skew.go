@@ -0,0 +1,59 @@
+package job
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+)
+
+// clockSkewTracker records the clock skew to the peer of an active job,
+// measured during the handshake of each connection (see versionhandshake.ClockSkew).
+type clockSkewTracker struct {
+	threshold time.Duration // 0 disables the check
+	gauge     prometheus.Gauge
+
+	mtx    sync.Mutex
+	latest *versionhandshake.ClockSkew
+}
+
+type ClockSkewStatus struct {
+	versionhandshake.ClockSkew
+	Threshold time.Duration
+	// whether Skew exceeds Threshold by more than the Uncertainty of the measurement
+	Exceeded bool
+}
+
+func (t *clockSkewTracker) ObserveClockSkew(s versionhandshake.ClockSkew) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.latest = &s
+	t.gauge.Set(s.Skew.Seconds())
+}
+
+// status returns nil if the skew has not been measured yet.
+func (t *clockSkewTracker) status() *ClockSkewStatus {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.latest == nil {
+		return nil
+	}
+	return &ClockSkewStatus{
+		ClockSkew: *t.latest,
+		Threshold: t.threshold,
+		Exceeded:  t.threshold > 0 && t.latest.Exceeds(t.threshold),
+	}
+}
+
+func (t *clockSkewTracker) logIfExceeded(log Logger) {
+	s := t.status()
+	if s == nil || !s.Exceeded {
+		return
+	}
+	log.WithField("skew", s.Skew).
+		WithField("uncertainty", s.Uncertainty).
+		WithField("threshold", s.Threshold).
+		Warn("clock skew to the peer exceeds clock_skew_threshold, snapshot times and the ages derived from them are misleading")
+}
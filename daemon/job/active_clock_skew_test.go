@@ -0,0 +1,41 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+	"github.com/zrepl/zrepl/transport"
+)
+
+func TestClockSkewTracker(t *testing.T) {
+	tr := &clockSkewTracker{
+		threshold: time.Minute,
+		gauge:     prometheus.NewGauge(prometheus.GaugeOpts{Name: "test"}),
+	}
+	assert.Nil(t, tr.status())
+
+	var c transport.Connecter = activeSideConnecter{clockSkew: tr}
+	obs, ok := c.(versionhandshake.ClockSkewObserver)
+	if !ok {
+		t.Fatal("connecter must observe the clock skew")
+	}
+	assert.Equal(t, transport.CompressionNone, c.(transport.CompressionRequester).RequestedCompression())
+
+	obs.ObserveClockSkew(versionhandshake.ClockSkew{Skew: -2 * time.Minute, Uncertainty: time.Second})
+	s := tr.status()
+	assert.Equal(t, -2*time.Minute, s.Skew)
+	assert.Equal(t, time.Minute, s.Threshold)
+	assert.True(t, s.Exceeded)
+
+	// within the uncertainty of the measurement
+	obs.ObserveClockSkew(versionhandshake.ClockSkew{Skew: time.Minute + time.Second, Uncertainty: 2 * time.Second})
+	assert.False(t, tr.status().Exceeded)
+
+	tr.threshold = 0
+	obs.ObserveClockSkew(versionhandshake.ClockSkew{Skew: time.Hour})
+	assert.False(t, tr.status().Exceeded, "threshold 0 disables the check")
+}
@@ -0,0 +1,51 @@
+package job
+
+import (
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+	"github.com/zrepl/zrepl/transport"
+)
+
+// activeSideConnecter hooks an active job into the version handshake of its connections:
+// it reports the measured clock skew to clockSkew, advertises the receiving pool's features
+// if the job receives (pull), and records the peer's if the job sends (push).
+type activeSideConnecter struct {
+	transport.Connecter
+	clockSkew *clockSkewTracker
+
+	recvFeatures *endpoint.RecvFeaturesAdvertiser // nil unless pull
+
+	peerRecvFeatures *endpoint.PeerRecvFeatures // nil unless push
+	// the client identity under which the local sender looks up peerRecvFeatures
+	clientIdentity string
+}
+
+var _ versionhandshake.ClockSkewObserver = activeSideConnecter{}
+var _ versionhandshake.RecvFeaturesAdvertiser = activeSideConnecter{}
+var _ versionhandshake.RecvFeaturesObserver = activeSideConnecter{}
+var _ transport.CompressionRequester = activeSideConnecter{}
+
+func (c activeSideConnecter) ObserveClockSkew(s versionhandshake.ClockSkew) {
+	c.clockSkew.ObserveClockSkew(s)
+}
+
+func (c activeSideConnecter) AdvertisedRecvFeatures() ([]string, bool) {
+	if c.recvFeatures == nil {
+		return nil, false
+	}
+	return c.recvFeatures.AdvertisedRecvFeatures()
+}
+
+func (c activeSideConnecter) ObservePeerRecvFeatures(_ string, features []string) {
+	if c.peerRecvFeatures != nil {
+		c.peerRecvFeatures.Observe(c.clientIdentity, features)
+	}
+}
+
+// RequestedCompression forwards to the wrapped Connecter, the handshake negotiates compression based on it.
+func (c activeSideConnecter) RequestedCompression() transport.Compression {
+	if cr, ok := c.Connecter.(transport.CompressionRequester); ok {
+		return cr.RequestedCompression()
+	}
+	return transport.CompressionNone
+}
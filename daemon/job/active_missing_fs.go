@@ -0,0 +1,165 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/jobstate"
+	"github.com/zrepl/zrepl/replication/logic"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+// MissingFilesystemPolicy determines how an active job handles sender filesystems
+// that were replicated by a previous invocation but are no longer listed by the sender,
+// e.g. because they were destroyed or no longer match the filesystems filter.
+type MissingFilesystemPolicy int
+
+const (
+	MissingFilesystemFail MissingFilesystemPolicy = iota
+	MissingFilesystemWarn
+	MissingFilesystemIgnore
+)
+
+func MissingFilesystemPolicyFromString(s string) (MissingFilesystemPolicy, error) {
+	switch s {
+	case "fail":
+		return MissingFilesystemFail, nil
+	case "warn":
+		return MissingFilesystemWarn, nil
+	case "ignore":
+		return MissingFilesystemIgnore, nil
+	default:
+		return 0, fmt.Errorf("invalid missing filesystem policy %q, must be one of 'fail', 'warn', 'ignore'", s)
+	}
+}
+
+// knownFilesystems tracks the sender filesystems seen by previous invocations of a job.
+// The set is persisted in the job's state store, if any, see restore.
+type knownFilesystems struct {
+	policy MissingFilesystemPolicy
+
+	mtx   sync.Mutex
+	known map[string]bool
+	store *jobstate.Store // nil until restore, or if the job has no state store
+}
+
+// knownFilesystemsStateKey is the jobstate key under which the known filesystems are persisted.
+const knownFilesystemsStateKey = "known_filesystems"
+
+func newKnownFilesystems(policy MissingFilesystemPolicy) *knownFilesystems {
+	return &knownFilesystems{policy: policy, known: make(map[string]bool)}
+}
+
+// restore loads the known set persisted by a previous run of the job
+// from the state store of ctx (see jobstate.Context) and persists subsequent changes there.
+func (k *knownFilesystems) restore(ctx context.Context) error {
+	store := jobstate.FromContext(ctx)
+	if store == nil {
+		return nil
+	}
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	k.store = store
+	var known []string
+	if _, err := store.Load(knownFilesystemsStateKey, &known); err != nil {
+		return err
+	}
+	for _, fs := range known {
+		k.known[fs] = true
+	}
+	return nil
+}
+
+// persist must be called with k.mtx held.
+func (k *knownFilesystems) persist() error {
+	if k.store == nil {
+		return nil
+	}
+	known := make([]string, 0, len(k.known))
+	for fs := range k.known {
+		known = append(known, fs)
+	}
+	sort.Strings(known)
+	return k.store.Store(knownFilesystemsStateKey, known)
+}
+
+// update returns the known filesystems missing in current, sorted by name.
+// Unless the policy is fail and filesystems are missing, the known set is replaced by current.
+// With policy fail, the known set is kept so that subsequent invocations fail as well
+// until the filesystems reappear or are forgotten.
+// persistErr is non-nil if the new known set could not be persisted.
+func (k *knownFilesystems) update(current []string) (missing []string, persistErr error) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	cur := make(map[string]bool, len(current))
+	for _, fs := range current {
+		cur[fs] = true
+	}
+	for fs := range k.known {
+		if !cur[fs] {
+			missing = append(missing, fs)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 && k.policy == MissingFilesystemFail {
+		return missing, nil
+	}
+	k.known = cur
+	return missing, k.persist()
+}
+
+// forget removes fs from the known set, i.e., acknowledges that fs is gone.
+func (k *knownFilesystems) forget(fs string) error {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	if !k.known[fs] {
+		return fmt.Errorf("filesystem %q is not known to the job", fs)
+	}
+	delete(k.known, fs)
+	return k.persist()
+}
+
+// ForgetFilesystem makes the job forget that it replicated fs in a previous invocation,
+// so that subsequent invocations no longer report fs as missing (see on_missing_filesystem).
+func (j *ActiveSide) ForgetFilesystem(fs string) error {
+	return j.knownFilesystems.forget(fs)
+}
+
+// checkMissingFilesystems lists the sender's filesystems and compares them to those
+// seen by previous invocations.
+// It returns errors if the invocation must be aborted due to the policy.
+// Listing errors are logged and otherwise ignored: they are not evidence of a missing filesystem,
+// and replication reports them anyway.
+func (j *ActiveSide) checkMissingFilesystems(ctx context.Context, sender logic.Sender) (abort []hooks.JobError) {
+	res, err := sender.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
+	if err != nil {
+		GetLogger(ctx).WithError(err).Warn("cannot list sender filesystems to check for missing filesystems")
+		return nil
+	}
+	current := make([]string, 0, len(res.GetFilesystems()))
+	for _, fs := range res.GetFilesystems() {
+		current = append(current, fs.GetPath())
+	}
+	missing, err := j.knownFilesystems.update(current)
+	if err != nil {
+		GetLogger(ctx).WithError(err).Warn("cannot persist known filesystems")
+	}
+	for _, fs := range missing {
+		l := GetLogger(ctx).WithField("filesystem", fs)
+		switch j.knownFilesystems.policy {
+		case MissingFilesystemFail:
+			l.Error("filesystem replicated by previous invocation is missing on sender, aborting (on_missing_filesystem: fail)")
+			abort = append(abort, hooks.JobError{
+				Phase:      "missing_filesystem",
+				Filesystem: fs,
+				Err:        "filesystem replicated by previous invocation is missing on sender",
+			})
+		case MissingFilesystemWarn:
+			l.Warn("filesystem replicated by previous invocation is missing on sender (on_missing_filesystem: warn)")
+		}
+	}
+	return abort
+}
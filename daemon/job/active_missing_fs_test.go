@@ -0,0 +1,80 @@
+package job
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/job/jobstate"
+)
+
+func updateKnownFilesystems(t *testing.T, k *knownFilesystems, current ...string) []string {
+	missing, err := k.update(current)
+	require.NoError(t, err)
+	return missing
+}
+
+func TestKnownFilesystemsUpdate(t *testing.T) {
+	k := newKnownFilesystems(MissingFilesystemWarn)
+	assert.Empty(t, updateKnownFilesystems(t, k, "pool/a", "pool/b", "pool/c"))
+	assert.Equal(t, []string{"pool/a", "pool/c"}, updateKnownFilesystems(t, k, "pool/b", "pool/d"))
+	// the disappearance is only reported once
+	assert.Empty(t, updateKnownFilesystems(t, k, "pool/b", "pool/d"))
+}
+
+func TestKnownFilesystemsUpdateFailKeepsKnown(t *testing.T) {
+	k := newKnownFilesystems(MissingFilesystemFail)
+	assert.Empty(t, updateKnownFilesystems(t, k, "pool/a", "pool/b"))
+	assert.Equal(t, []string{"pool/a"}, updateKnownFilesystems(t, k, "pool/b"))
+	assert.Equal(t, []string{"pool/a"}, updateKnownFilesystems(t, k, "pool/b", "pool/c"))
+	// reappeared
+	assert.Empty(t, updateKnownFilesystems(t, k, "pool/a", "pool/b", "pool/c"))
+}
+
+func TestKnownFilesystemsForget(t *testing.T) {
+	k := newKnownFilesystems(MissingFilesystemFail)
+	assert.Empty(t, updateKnownFilesystems(t, k, "pool/a", "pool/b"))
+	assert.Equal(t, []string{"pool/a"}, updateKnownFilesystems(t, k, "pool/b"))
+	require.NoError(t, k.forget("pool/a"))
+	assert.Empty(t, updateKnownFilesystems(t, k, "pool/b"))
+	assert.Error(t, k.forget("pool/a"))
+}
+
+func TestKnownFilesystemsPersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-known-filesystems")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ctx := jobstate.Context(context.Background(), jobstate.New(dir, "foo"))
+
+	k := newKnownFilesystems(MissingFilesystemFail)
+	require.NoError(t, k.restore(ctx))
+	assert.Empty(t, updateKnownFilesystems(t, k, "pool/a", "pool/b", "pool/c"))
+
+	// the job after a daemon restart, pool/a was destroyed in the meantime
+	k = newKnownFilesystems(MissingFilesystemFail)
+	require.NoError(t, k.restore(ctx))
+	assert.Equal(t, []string{"pool/a"}, updateKnownFilesystems(t, k, "pool/b", "pool/c"))
+	require.NoError(t, k.forget("pool/a"))
+
+	k = newKnownFilesystems(MissingFilesystemFail)
+	require.NoError(t, k.restore(ctx))
+	assert.Empty(t, updateKnownFilesystems(t, k, "pool/b", "pool/c"))
+}
+
+func TestMissingFilesystemPolicyFromString(t *testing.T) {
+	for s, exp := range map[string]MissingFilesystemPolicy{
+		"fail":   MissingFilesystemFail,
+		"warn":   MissingFilesystemWarn,
+		"ignore": MissingFilesystemIgnore,
+	} {
+		p, err := MissingFilesystemPolicyFromString(s)
+		require.NoError(t, err)
+		assert.Equal(t, exp, p)
+	}
+	_, err := MissingFilesystemPolicyFromString("abort")
+	assert.Error(t, err)
+}
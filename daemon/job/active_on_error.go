@@ -0,0 +1,42 @@
+package job
+
+import (
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+// replicationErrors returns the errors of the latest attempt in r.
+func replicationErrors(r *report.Report) []hooks.JobError {
+	const phase = "replication"
+	var errs []hooks.JobError
+	if r.WaitReconnectError != nil {
+		errs = append(errs, hooks.JobError{Phase: phase, Err: r.WaitReconnectError.Error()})
+	}
+	if len(r.Attempts) == 0 {
+		return errs
+	}
+	a := r.Attempts[len(r.Attempts)-1]
+	if a.PlanError != nil {
+		errs = append(errs, hooks.JobError{Phase: phase, Err: a.PlanError.Error()})
+	}
+	for _, fs := range a.Filesystems {
+		if err := fs.Error(); err != nil {
+			errs = append(errs, hooks.JobError{Phase: phase, Filesystem: fs.Info.Name, Err: err.Error()})
+		}
+	}
+	return errs
+}
+
+func prunerErrors(phase string, r *pruner.Report) []hooks.JobError {
+	var errs []hooks.JobError
+	if r.Error != "" {
+		errs = append(errs, hooks.JobError{Phase: phase, Err: r.Error})
+	}
+	for _, fs := range r.Completed {
+		if fs.SkipReason.NotSkipped() && fs.LastError != "" {
+			errs = append(errs, hooks.JobError{Phase: phase, Filesystem: fs.Filesystem, Err: fs.LastError})
+		}
+	}
+	return errs
+}
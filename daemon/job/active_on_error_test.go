@@ -0,0 +1,52 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+func TestReplicationErrors(t *testing.T) {
+	now := time.Now()
+	r := &report.Report{
+		Attempts: []*report.AttemptReport{
+			{
+				// earlier attempts are ignored
+				PlanError: report.NewTimedError("old", now),
+			},
+			{
+				Filesystems: []*report.FilesystemReport{
+					{Info: &report.FilesystemInfo{Name: "pool/a"}, State: report.FilesystemDone},
+					{Info: &report.FilesystemInfo{Name: "pool/b"}, State: report.FilesystemSteppingErrored, StepError: report.NewTimedError("step", now)},
+					{Info: &report.FilesystemInfo{Name: "pool/c"}, State: report.FilesystemPlanningErrored, PlanError: report.NewTimedError("plan", now)},
+				},
+			},
+		},
+	}
+	assert.Equal(t, []hooks.JobError{
+		{Phase: "replication", Filesystem: "pool/b", Err: "step"},
+		{Phase: "replication", Filesystem: "pool/c", Err: "plan"},
+	}, replicationErrors(r))
+
+	assert.Empty(t, replicationErrors(&report.Report{}))
+}
+
+func TestPrunerErrors(t *testing.T) {
+	r := &pruner.Report{
+		Error: "cannot list",
+		Completed: []pruner.FSReport{
+			{Filesystem: "pool/a"},
+			{Filesystem: "pool/b", LastError: "destroy failed"},
+			{Filesystem: "pool/c", LastError: "skipped", SkipReason: pruner.SkipPlaceholder},
+		},
+	}
+	assert.Equal(t, []hooks.JobError{
+		{Phase: "prune_sender", Err: "cannot list"},
+		{Phase: "prune_sender", Filesystem: "pool/b", Err: "destroy failed"},
+	}, prunerErrors("prune_sender", r))
+}
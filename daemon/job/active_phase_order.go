@@ -0,0 +1,51 @@
+package job
+
+import (
+	"fmt"
+	"strings"
+)
+
+// phaseOrder is the order in which an invocation of an active job runs its phases,
+// configured through phase_order.
+type phaseOrder int
+
+const (
+	// snapshots are taken before the job is woken up, i.e., replication includes them
+	phaseOrderSnapshotFirst phaseOrder = iota
+	// existing snapshots are replicated before the due snapshots are taken
+	phaseOrderReplicateFirst
+)
+
+var phaseOrderPhases = []string{"snapshot", "replicate", "prune"}
+
+// phaseOrderFromConfig returns phaseOrderSnapshotFirst if in is empty.
+// in must be a permutation of snapshot, replicate, prune in which prune comes last.
+func phaseOrderFromConfig(in []string) (phaseOrder, error) {
+	if len(in) == 0 {
+		return phaseOrderSnapshotFirst, nil
+	}
+	seen := make(map[string]bool, len(in))
+	for _, p := range in {
+		valid := false
+		for _, v := range phaseOrderPhases {
+			valid = valid || p == v
+		}
+		if !valid {
+			return 0, fmt.Errorf("invalid phase %q, must be one of %s", p, strings.Join(phaseOrderPhases, ", "))
+		}
+		if seen[p] {
+			return 0, fmt.Errorf("phase %q is listed more than once", p)
+		}
+		seen[p] = true
+	}
+	if len(seen) != len(phaseOrderPhases) {
+		return 0, fmt.Errorf("must list each of %s exactly once", strings.Join(phaseOrderPhases, ", "))
+	}
+	if in[len(in)-1] != "prune" {
+		return 0, fmt.Errorf("prune must be the last phase")
+	}
+	if in[0] == "replicate" {
+		return phaseOrderReplicateFirst, nil
+	}
+	return phaseOrderSnapshotFirst, nil
+}
@@ -0,0 +1,35 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhaseOrderFromConfig(t *testing.T) {
+	for _, c := range []struct {
+		in  []string
+		exp phaseOrder
+	}{
+		{nil, phaseOrderSnapshotFirst},
+		{[]string{"snapshot", "replicate", "prune"}, phaseOrderSnapshotFirst},
+		{[]string{"replicate", "snapshot", "prune"}, phaseOrderReplicateFirst},
+	} {
+		o, err := phaseOrderFromConfig(c.in)
+		require.NoError(t, err, "%v", c.in)
+		assert.Equal(t, c.exp, o, "%v", c.in)
+	}
+
+	for _, in := range [][]string{
+		{"snapshot", "replicate"},
+		{"snapshot", "replicate", "replicate"},
+		{"snapshot", "replicate", "prune", "prune"},
+		{"snapshot", "replicate", "cleanup"},
+		{"prune", "snapshot", "replicate"},
+		{"snapshot", "prune", "replicate"},
+	} {
+		_, err := phaseOrderFromConfig(in)
+		assert.Error(t, err, "%v", in)
+	}
+}
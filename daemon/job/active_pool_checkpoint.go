@@ -0,0 +1,90 @@
+package job
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// poolCheckpoints implements the pool_checkpoint hook: it checkpoints pools before an invocation
+// replicates and prunes, and discards the checkpoints if the invocation succeeds.
+// The checkpoints of a failed invocation are kept so that the administrator can rewind to them.
+//
+// A nil *poolCheckpoints does nothing.
+type poolCheckpoints struct {
+	pools []string
+	// zfs.ZPoolCheckpoint and zfs.ZPoolCheckpointDiscard, replaced in tests
+	checkpoint, discard func(ctx context.Context, pool string) error
+}
+
+// poolCheckpointsFromConfig returns nil if in is nil.
+func poolCheckpointsFromConfig(in *config.PoolCheckpointHook) (*poolCheckpoints, error) {
+	if in == nil {
+		return nil, nil
+	}
+	if len(in.Pools) == 0 {
+		return nil, fmt.Errorf("`pools` must not be empty")
+	}
+	seen := make(map[string]bool, len(in.Pools))
+	for _, p := range in.Pools {
+		dp, err := zfs.NewDatasetPath(p)
+		if err != nil || dp.Length() != 1 {
+			return nil, fmt.Errorf("invalid pool name %q", p)
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("pool %q listed more than once", p)
+		}
+		seen[p] = true
+	}
+	return &poolCheckpoints{
+		pools:      in.Pools,
+		checkpoint: zfs.ZPoolCheckpoint,
+		discard:    zfs.ZPoolCheckpointDiscard,
+	}, nil
+}
+
+// create checkpoints all pools.
+// If a pool cannot be checkpointed, e.g. because it still has a checkpoint from a failed invocation,
+// the checkpoints created so far are discarded and an error in phase pool_checkpoint is returned.
+func (c *poolCheckpoints) create(ctx context.Context) []hooks.JobError {
+	if c == nil {
+		return nil
+	}
+	log := GetLogger(ctx)
+	for i, pool := range c.pools {
+		log.WithField("pool", pool).Info("checkpointing pool")
+		if err := c.checkpoint(ctx, pool); err != nil {
+			for _, created := range c.pools[:i] {
+				if err := c.discard(ctx, created); err != nil {
+					log.WithField("pool", created).WithError(err).Error("cannot discard checkpoint after failing to checkpoint another pool, discard it manually")
+				}
+			}
+			return []hooks.JobError{{Phase: "pool_checkpoint", Err: err.Error()}}
+		}
+	}
+	return nil
+}
+
+// finish discards the checkpoints if the invocation had no errors, and keeps them otherwise.
+func (c *poolCheckpoints) finish(ctx context.Context, errs []hooks.JobError) {
+	if c == nil {
+		return
+	}
+	log := GetLogger(ctx)
+	if len(errs) > 0 {
+		for _, pool := range c.pools {
+			log.WithField("pool", pool).
+				Warn("invocation failed, keeping pool checkpoint: rewind with `zpool import --rewind-to-checkpoint` or discard it with `zpool checkpoint -d`, the job does not replicate or prune until then")
+		}
+		return
+	}
+	for _, pool := range c.pools {
+		log.WithField("pool", pool).Info("invocation succeeded, discarding pool checkpoint")
+		if err := c.discard(ctx, pool); err != nil {
+			log.WithField("pool", pool).WithError(err).Error("cannot discard pool checkpoint, discard it manually")
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+)
+
+func TestPoolCheckpointsFromConfig(t *testing.T) {
+	c, err := poolCheckpointsFromConfig(nil)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	for _, pools := range [][]string{nil, {"pool/fs"}, {""}, {"pool", "pool"}} {
+		_, err := poolCheckpointsFromConfig(&config.PoolCheckpointHook{Pools: pools})
+		assert.Error(t, err, "%v", pools)
+	}
+
+	c, err = poolCheckpointsFromConfig(&config.PoolCheckpointHook{Pools: []string{"a", "b"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, c.pools)
+}
+
+func TestPoolCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+	failCheckpoint := ""
+	c := &poolCheckpoints{
+		pools: []string{"a", "b"},
+		checkpoint: func(ctx context.Context, pool string) error {
+			calls = append(calls, "checkpoint "+pool)
+			if pool == failCheckpoint {
+				return fmt.Errorf("pool %q already has a checkpoint", pool)
+			}
+			return nil
+		},
+		discard: func(ctx context.Context, pool string) error {
+			calls = append(calls, "discard "+pool)
+			return nil
+		},
+	}
+
+	// success
+	require.Empty(t, c.create(ctx))
+	c.finish(ctx, nil)
+	assert.Equal(t, []string{"checkpoint a", "checkpoint b", "discard a", "discard b"}, calls)
+
+	// failed invocation keeps the checkpoints
+	calls = nil
+	require.Empty(t, c.create(ctx))
+	c.finish(ctx, []hooks.JobError{{Phase: "replication", Err: "some error"}})
+	assert.Equal(t, []string{"checkpoint a", "checkpoint b"}, calls)
+
+	// the checkpoints created before the failing one are discarded
+	calls = nil
+	failCheckpoint = "b"
+	errs := c.create(ctx)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "pool_checkpoint", errs[0].Phase)
+	assert.Equal(t, []string{"checkpoint a", "checkpoint b", "discard a"}, calls)
+
+	var none *poolCheckpoints
+	assert.Empty(t, none.create(ctx))
+	none.finish(ctx, nil)
+}
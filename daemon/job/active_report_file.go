@@ -0,0 +1,185 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/replication/report"
+	"github.com/zrepl/zrepl/util/atomicfile"
+)
+
+// reportFile writes a JSON summary of each invocation of an active job to a file, see report_file.
+type reportFile struct {
+	pathTemplate string
+	jobName      string
+	// the DoneAt of the latest snapshot included in a report, only accessed by the invocation
+	lastSnapshotAt time.Time
+}
+
+// InvocationReport is the content of a report_file.
+type InvocationReport struct {
+	Job                   string
+	StartedAt, FinishedAt time.Time
+	// whether the invocation had no errors
+	Success               bool
+	SnapshotsCreated      int
+	FilesystemsReplicated int
+	BytesReplicated       int64
+	SnapshotsPruned       int
+	// sorted by name, only filesystems that the invocation did something with or failed on
+	Filesystems []*InvocationReportFilesystem
+	Errors      []hooks.JobError
+}
+
+type InvocationReportFilesystem struct {
+	Filesystem string
+	// snapshots taken by the periodic snapshotter since the previous report
+	SnapshotsCreated []string `json:",omitempty"`
+	// the snapshots that were sent, in the order in which they were sent
+	SnapshotsReplicated []string `json:",omitempty"`
+	BytesReplicated     int64
+	// the snapshots destroyed by pruning
+	SenderSnapshotsPruned   []string `json:",omitempty"`
+	ReceiverSnapshotsPruned []string `json:",omitempty"`
+	Errors                  []string `json:",omitempty"`
+}
+
+var reportFileTemplateVarRE = regexp.MustCompile(`\{[^{}]*\}`)
+
+// reportFileTimestampFormat is the format of {timestamp}, the invocation's start time in UTC.
+const reportFileTimestampFormat = "20060102T150405Z"
+
+// reportFileFromConfig returns nil if path is empty, i.e., no report file is written.
+func reportFileFromConfig(jobName, path string) (*reportFile, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("path %q must be absolute", path)
+	}
+	for _, tv := range reportFileTemplateVarRE.FindAllString(path, -1) {
+		if tv != "{job}" && tv != "{timestamp}" {
+			return nil, fmt.Errorf("unknown template variable %s, must be one of {job}, {timestamp}", tv)
+		}
+	}
+	return &reportFile{pathTemplate: path, jobName: jobName}, nil
+}
+
+func (f *reportFile) path(startedAt time.Time) string {
+	r := strings.NewReplacer("{job}", f.jobName, "{timestamp}", startedAt.UTC().Format(reportFileTimestampFormat))
+	return r.Replace(f.pathTemplate)
+}
+
+// write writes the report of an invocation, errors are logged.
+func (f *reportFile) write(ctx context.Context, startedAt time.Time, tasks activeSideTasks, snapperReport *snapper.Report, errs []hooks.JobError) {
+	r := f.build(startedAt, time.Now(), tasks, snapperReport, errs)
+	path := f.path(startedAt)
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err == nil {
+		err = atomicfile.WriteFile(path, content, 0644)
+	}
+	if err != nil {
+		GetLogger(ctx).WithError(err).WithField("path", path).Error("cannot write report_file")
+		return
+	}
+	GetLogger(ctx).WithField("path", path).Debug("wrote report_file")
+}
+
+func (f *reportFile) build(startedAt, finishedAt time.Time, tasks activeSideTasks, snapperReport *snapper.Report, errs []hooks.JobError) *InvocationReport {
+	r := &InvocationReport{
+		Job:        f.jobName,
+		StartedAt:  startedAt,
+		FinishedAt: finishedAt,
+		Success:    len(errs) == 0,
+		Errors:     errs,
+	}
+	byFS := make(map[string]*InvocationReportFilesystem)
+	fs := func(name string) *InvocationReportFilesystem {
+		if byFS[name] == nil {
+			byFS[name] = &InvocationReportFilesystem{Filesystem: name}
+		}
+		return byFS[name]
+	}
+
+	if snapperReport != nil {
+		lastSnapshotAt := f.lastSnapshotAt
+		for _, p := range snapperReport.Progress {
+			if p.State != snapper.SnapDone || !p.DoneAt.After(f.lastSnapshotAt) {
+				continue
+			}
+			fs(p.Path).SnapshotsCreated = append(fs(p.Path).SnapshotsCreated, p.SnapName)
+			r.SnapshotsCreated++
+			if p.DoneAt.After(lastSnapshotAt) {
+				lastSnapshotAt = p.DoneAt
+			}
+		}
+		f.lastSnapshotAt = lastSnapshotAt
+	}
+
+	if tasks.replicationReport != nil {
+		if rep := tasks.replicationReport(); len(rep.Attempts) > 0 {
+			for _, rfs := range rep.Attempts[len(rep.Attempts)-1].Filesystems {
+				sent := rfs.Steps
+				if rfs.State != report.FilesystemDone && rfs.CurrentStep < len(sent) {
+					sent = sent[:rfs.CurrentStep]
+				}
+				if len(sent) == 0 && rfs.Error() == nil {
+					continue
+				}
+				ifs := fs(rfs.Info.Name)
+				for _, s := range sent {
+					ifs.SnapshotsReplicated = append(ifs.SnapshotsReplicated, s.Info.To)
+					ifs.BytesReplicated += s.Info.BytesReplicated
+				}
+				if len(sent) > 0 {
+					r.FilesystemsReplicated++
+				}
+				r.BytesReplicated += ifs.BytesReplicated
+			}
+		}
+	}
+
+	pruned := func(p *pruner.Pruner, list func(*InvocationReportFilesystem) *[]string) {
+		if p == nil {
+			return
+		}
+		for _, pfs := range p.Report().Completed {
+			if !pfs.SkipReason.NotSkipped() || pfs.LastError != "" {
+				continue
+			}
+			for _, destroyed := range [][]pruner.SnapshotReport{pfs.DestroyList, pfs.ConvertList} {
+				for _, s := range destroyed {
+					l := list(fs(pfs.Filesystem))
+					*l = append(*l, s.Name)
+					r.SnapshotsPruned++
+				}
+			}
+		}
+	}
+	pruned(tasks.prunerSender, func(ifs *InvocationReportFilesystem) *[]string { return &ifs.SenderSnapshotsPruned })
+	pruned(tasks.prunerReceiver, func(ifs *InvocationReportFilesystem) *[]string { return &ifs.ReceiverSnapshotsPruned })
+
+	for _, e := range errs {
+		if e.Filesystem != "" {
+			fs(e.Filesystem).Errors = append(fs(e.Filesystem).Errors, fmt.Sprintf("%s: %s", e.Phase, e.Err))
+		}
+	}
+
+	r.Filesystems = make([]*InvocationReportFilesystem, 0, len(byFS))
+	for _, ifs := range byFS {
+		r.Filesystems = append(r.Filesystems, ifs)
+	}
+	sort.Slice(r.Filesystems, func(i, j int) bool {
+		return r.Filesystems[i].Filesystem < r.Filesystems[j].Filesystem
+	})
+	return r
+}
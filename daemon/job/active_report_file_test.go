@@ -0,0 +1,115 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+func TestReportFileFromConfig(t *testing.T) {
+	f, err := reportFileFromConfig("prod", "")
+	require.NoError(t, err)
+	assert.Nil(t, f)
+
+	f, err = reportFileFromConfig("prod", "/var/lib/zrepl/reports/{job}-{timestamp}.json")
+	require.NoError(t, err)
+	startedAt := time.Date(2020, 3, 4, 5, 6, 7, 0, time.FixedZone("CET", 3600))
+	assert.Equal(t, "/var/lib/zrepl/reports/prod-20200304T040607Z.json", f.path(startedAt))
+
+	_, err = reportFileFromConfig("prod", "reports/{job}.json")
+	assert.Error(t, err, "relative path")
+	_, err = reportFileFromConfig("prod", "/reports/{name}.json")
+	assert.Error(t, err, "unknown template variable")
+}
+
+func TestReportFileBuild(t *testing.T) {
+	t0 := time.Now()
+	f := &reportFile{jobName: "prod"}
+	snapperReport := &snapper.Report{Progress: []*snapper.ReportFilesystem{
+		{Path: "pool/a", State: snapper.SnapDone, SnapName: "zrepl_1", DoneAt: t0},
+		{Path: "pool/b", State: snapper.SnapError, SnapName: "zrepl_1", DoneAt: t0},
+	}}
+	rep := &report.Report{Attempts: []*report.AttemptReport{{
+		Filesystems: []*report.FilesystemReport{
+			{
+				Info:  &report.FilesystemInfo{Name: "pool/a"},
+				State: report.FilesystemDone,
+				Steps: []*report.StepReport{
+					{Info: &report.StepInfo{From: "zrepl_0", To: "zrepl_1", BytesReplicated: 100}},
+				},
+			},
+			{
+				Info:        &report.FilesystemInfo{Name: "pool/b"},
+				State:       report.FilesystemSteppingErrored,
+				StepError:   report.NewTimedError("broken pipe", t0),
+				CurrentStep: 1,
+				Steps: []*report.StepReport{
+					{Info: &report.StepInfo{To: "zrepl_0", BytesReplicated: 10}},
+					{Info: &report.StepInfo{From: "zrepl_0", To: "zrepl_1", BytesReplicated: 5}},
+				},
+			},
+			{Info: &report.FilesystemInfo{Name: "pool/c"}, State: report.FilesystemDone},
+		},
+	}}}
+	tasks := activeSideTasks{replicationReport: func() *report.Report { return rep }}
+	errs := []hooks.JobError{{Phase: "replication", Filesystem: "pool/b", Err: "broken pipe"}}
+
+	r := f.build(t0, t0.Add(time.Minute), tasks, snapperReport, errs)
+	assert.Equal(t, "prod", r.Job)
+	assert.False(t, r.Success)
+	assert.Equal(t, 1, r.SnapshotsCreated)
+	assert.Equal(t, 2, r.FilesystemsReplicated)
+	assert.Equal(t, int64(110), r.BytesReplicated)
+	assert.Equal(t, errs, r.Errors)
+	require.Len(t, r.Filesystems, 2, "pool/c had nothing to replicate")
+	assert.Equal(t, &InvocationReportFilesystem{
+		Filesystem:          "pool/a",
+		SnapshotsCreated:    []string{"zrepl_1"},
+		SnapshotsReplicated: []string{"zrepl_1"},
+		BytesReplicated:     100,
+	}, r.Filesystems[0])
+	assert.Equal(t, &InvocationReportFilesystem{
+		Filesystem:          "pool/b",
+		SnapshotsReplicated: []string{"zrepl_0"},
+		BytesReplicated:     10,
+		Errors:              []string{"replication: broken pipe"},
+	}, r.Filesystems[1])
+
+	// the snapshots were included in the previous report
+	r = f.build(t0, t0.Add(time.Minute), activeSideTasks{}, snapperReport, nil)
+	assert.True(t, r.Success)
+	assert.Zero(t, r.SnapshotsCreated)
+	assert.Empty(t, r.Filesystems)
+}
+
+func TestReportFileWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-report-file")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	f, err := reportFileFromConfig("prod", filepath.Join(dir, "reports", "{job}.json"))
+	require.NoError(t, err)
+
+	ctx := logging.WithLoggers(context.Background(), logging.SubsystemLoggersWithUniversalLogger(logger.NewNullLogger()))
+	f.write(ctx, time.Now(), activeSideTasks{}, nil, []hooks.JobError{{Phase: "replication", Err: "no route to host"}})
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "reports", "prod.json"))
+	require.NoError(t, err)
+	var r InvocationReport
+	require.NoError(t, json.Unmarshal(content, &r))
+	assert.Equal(t, "prod", r.Job)
+	assert.False(t, r.Success)
+	assert.Len(t, r.Errors, 1)
+}
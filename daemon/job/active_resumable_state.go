@@ -0,0 +1,81 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/replication/logic"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+// resumableStateExpiry implements replication.protection.resumable_state_max_age:
+// it abandons the resumable state of transfers that were interrupted longer ago than maxAge.
+// On the receiving side, resume tokens that have not changed for longer than maxAge are hidden
+// from the planner, so the next replication does not resume and the receiver clears the token.
+// On the sending side of push jobs, step holds placed longer ago than maxAge are released.
+//
+// A nil *resumableStateExpiry does nothing.
+type resumableStateExpiry struct {
+	maxAge time.Duration
+	tokens *resumeTokenTracker
+}
+
+// newResumableStateExpiry returns nil if maxAge is 0.
+func newResumableStateExpiry(maxAge time.Duration, tokens *resumeTokenTracker) *resumableStateExpiry {
+	if maxAge == 0 {
+		return nil
+	}
+	return &resumableStateExpiry{maxAge: maxAge, tokens: tokens}
+}
+
+// releaseStepHolds releases the job's step holds on the local sending side that are older than maxAge.
+// Holds that a replication still needs are placed again by the replication.
+func (e *resumableStateExpiry) releaseStepHolds(ctx context.Context, senderConfig *endpoint.SenderConfig) {
+	if e == nil || senderConfig == nil {
+		return
+	}
+	log := GetLogger(ctx)
+	released, err := endpoint.ReleaseStepHoldsPlacedBefore(ctx, senderConfig.FSF, senderConfig.JobID, time.Now().Add(-e.maxAge))
+	for _, a := range released {
+		log.WithField("hold", a.String()).WithField("max_age", e.maxAge).
+			Warn("abandoning resumability: released step hold older than resumable_state_max_age")
+	}
+	if err != nil {
+		log.WithError(err).Error("cannot release step holds older than resumable_state_max_age")
+	}
+}
+
+// receiver returns receiver with the expired resume tokens hidden from ListFilesystems.
+func (e *resumableStateExpiry) receiver(receiver logic.Receiver) logic.Receiver {
+	if e == nil {
+		return receiver
+	}
+	return &expiredResumeTokensReceiver{Receiver: receiver, expiry: e}
+}
+
+type expiredResumeTokensReceiver struct {
+	logic.Receiver
+	expiry *resumableStateExpiry
+}
+
+func (r *expiredResumeTokensReceiver) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error) {
+	res, err := r.Receiver.ListFilesystems(ctx, req)
+	if err != nil {
+		return res, err
+	}
+	now := time.Now()
+	for _, fs := range res.GetFilesystems() {
+		if fs.GetResumeToken() == "" {
+			continue
+		}
+		if age := r.expiry.tokens.unchangedFor(fs.GetPath(), fs.GetResumeToken(), now); age > r.expiry.maxAge {
+			GetLogger(ctx).WithField("filesystem", fs.GetPath()).
+				WithField("unchanged_for", age).
+				WithField("max_age", r.expiry.maxAge).
+				Warn("abandoning resumability: not resuming the interrupted receive, the receiving side clears its resume token")
+			fs.ResumeToken = ""
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,48 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestResumableStateExpiryReceiver(t *testing.T) {
+	ctx := context.Background()
+	tr := newResumeTokenTracker(0, prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test"}, []string{"filesystem"}))
+	tr.parse = func(ctx context.Context, token string) (*zfs.ResumeToken, error) {
+		return &zfs.ResumeToken{}, nil
+	}
+	tokens := map[string]string{"pool/a": "t1", "pool/b": "t1", "pool/c": ""}
+	list := func() []*pdu.Filesystem {
+		return []*pdu.Filesystem{
+			{Path: "pool/a", ResumeToken: tokens["pool/a"]},
+			{Path: "pool/b", ResumeToken: tokens["pool/b"]},
+			{Path: "pool/c", ResumeToken: tokens["pool/c"]},
+		}
+	}
+
+	assert.Nil(t, newResumableStateExpiry(0, tr), "0 disables the expiry")
+	var disabled *resumableStateExpiry
+	rcv := &resumeTokenTestReceiver{fss: list()}
+	assert.Same(t, rcv, disabled.receiver(rcv))
+
+	require.NoError(t, tr.observe(ctx, rcv))
+	tr.byFS["pool/a"].since = time.Now().Add(-2 * time.Hour)
+	tr.byFS["pool/b"].since = time.Now().Add(-2 * time.Hour)
+	tokens["pool/b"] = "t2" // progress since the last observation
+
+	wrapped := newResumableStateExpiry(time.Hour, tr).receiver(&resumeTokenTestReceiver{fss: list()})
+	res, err := wrapped.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
+	require.NoError(t, err)
+	require.Len(t, res.Filesystems, 3)
+	assert.Empty(t, res.Filesystems[0].ResumeToken, "unchanged for longer than max age")
+	assert.Equal(t, "t2", res.Filesystems[1].ResumeToken)
+	assert.Empty(t, res.Filesystems[2].ResumeToken)
+}
@@ -0,0 +1,198 @@
+package job
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/daemon/job/jobstate"
+	"github.com/zrepl/zrepl/replication/logic"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// resumeTokenTracker records for how long the receive_resume_token of each receiving filesystem
+// of an active job has not changed.
+// The token encodes the position of the interrupted stream, so a resumed receive that makes
+// progress before failing again changes it, while a resume that fails right away leaves it unchanged.
+// The observed tokens are persisted in the job's state store, if any, see restore.
+type resumeTokenTracker struct {
+	threshold time.Duration        // 0 disables the check
+	gauge     *prometheus.GaugeVec // labels: filesystem
+	// zfs.ParseResumeToken, replaced in tests
+	parse func(ctx context.Context, token string) (*zfs.ResumeToken, error)
+
+	mtx   sync.Mutex
+	byFS  map[string]*resumeTokenObservation
+	store *jobstate.Store // nil until restore, or if the job has no state store
+}
+
+// resumeTokensStateKey is the jobstate key under which the observed resume tokens are persisted.
+const resumeTokensStateKey = "resume_tokens"
+
+// resumeTokenState is the persisted form of a resumeTokenObservation.
+// The snapshot is not persisted, it is decoded again once the token is stale.
+type resumeTokenState struct {
+	Token string
+	Since time.Time
+}
+
+type resumeTokenObservation struct {
+	token    string
+	since    time.Time
+	snapshot string // resolved once the token is stale
+}
+
+type ResumeTokenStatus struct {
+	Filesystem string
+	// the time at which the token was first observed unchanged
+	Since time.Time
+	// the snapshot that the interrupted stream sends, only present if Stale and the token could be decoded
+	Snapshot  string `json:",omitempty"`
+	Threshold time.Duration
+	// whether the token has not changed for longer than Threshold
+	Stale bool
+}
+
+func newResumeTokenTracker(threshold time.Duration, gauge *prometheus.GaugeVec) *resumeTokenTracker {
+	return &resumeTokenTracker{
+		threshold: threshold,
+		gauge:     gauge,
+		parse:     zfs.ParseResumeToken,
+		byFS:      make(map[string]*resumeTokenObservation),
+	}
+}
+
+// restore loads the tokens persisted by a previous run of the job
+// from the state store of ctx (see jobstate.Context) and persists subsequent changes there,
+// so that a restart does not reset the time since which a token has not changed.
+func (t *resumeTokenTracker) restore(ctx context.Context) error {
+	store := jobstate.FromContext(ctx)
+	if store == nil {
+		return nil
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.store = store
+	var byFS map[string]resumeTokenState
+	if _, err := store.Load(resumeTokensStateKey, &byFS); err != nil {
+		return err
+	}
+	for fs, st := range byFS {
+		t.byFS[fs] = &resumeTokenObservation{token: st.Token, since: st.Since}
+	}
+	return nil
+}
+
+// persist must be called with t.mtx held.
+func (t *resumeTokenTracker) persist() error {
+	if t.store == nil {
+		return nil
+	}
+	byFS := make(map[string]resumeTokenState, len(t.byFS))
+	for fs, o := range t.byFS {
+		byFS[fs] = resumeTokenState{Token: o.token, Since: o.since}
+	}
+	return t.store.Store(resumeTokensStateKey, byFS)
+}
+
+func (t *resumeTokenTracker) isStale(o *resumeTokenObservation, now time.Time) bool {
+	return t.threshold > 0 && now.Sub(o.since) > t.threshold
+}
+
+// observe lists the receiver's filesystems and updates the observed resume tokens.
+func (t *resumeTokenTracker) observe(ctx context.Context, receiver logic.Receiver) error {
+	res, err := receiver.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	present := make(map[string]bool)
+	changed := false
+	for _, fs := range res.GetFilesystems() {
+		if fs.GetResumeToken() == "" {
+			continue
+		}
+		present[fs.GetPath()] = true
+		o, ok := t.byFS[fs.GetPath()]
+		if !ok || o.token != fs.GetResumeToken() {
+			o = &resumeTokenObservation{token: fs.GetResumeToken(), since: now}
+			t.byFS[fs.GetPath()] = o
+			changed = true
+		}
+		if t.isStale(o, now) && o.snapshot == "" {
+			if decoded, err := t.parse(ctx, o.token); err == nil {
+				o.snapshot = decoded.ToName
+			} else {
+				GetLogger(ctx).WithField("filesystem", fs.GetPath()).WithError(err).Debug("cannot decode resume token")
+			}
+		}
+		t.gauge.WithLabelValues(fs.GetPath()).Set(now.Sub(o.since).Seconds())
+	}
+	for fs := range t.byFS {
+		if !present[fs] {
+			delete(t.byFS, fs)
+			t.gauge.DeleteLabelValues(fs)
+			changed = true
+		}
+	}
+	if changed {
+		if err := t.persist(); err != nil {
+			GetLogger(ctx).WithError(err).Warn("cannot persist resume tokens")
+		}
+	}
+	return nil
+}
+
+// unchangedFor returns for how long fs has been observed with the resume token token,
+// 0 if it has not been observed with that token.
+func (t *resumeTokenTracker) unchangedFor(fs, token string, now time.Time) time.Duration {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	o, ok := t.byFS[fs]
+	if !ok || o.token != token {
+		return 0
+	}
+	return now.Sub(o.since)
+}
+
+// status returns the observed resume tokens sorted by filesystem, nil if there are none.
+func (t *resumeTokenTracker) status() []*ResumeTokenStatus {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if len(t.byFS) == 0 {
+		return nil
+	}
+	now := time.Now()
+	s := make([]*ResumeTokenStatus, 0, len(t.byFS))
+	for fs, o := range t.byFS {
+		s = append(s, &ResumeTokenStatus{
+			Filesystem: fs,
+			Since:      o.since,
+			Snapshot:   o.snapshot,
+			Threshold:  t.threshold,
+			Stale:      t.isStale(o, now),
+		})
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Filesystem < s[j].Filesystem })
+	return s
+}
+
+func (t *resumeTokenTracker) logIfStale(log Logger) {
+	for _, s := range t.status() {
+		if !s.Stale {
+			continue
+		}
+		log.WithField("filesystem", s.Filesystem).
+			WithField("snapshot", s.Snapshot).
+			WithField("since", s.Since).
+			WithField("threshold", s.Threshold).
+			Warn("resume token has not changed for longer than stale_resume_token_threshold, resuming the interrupted receive keeps failing")
+	}
+}
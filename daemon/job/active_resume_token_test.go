@@ -0,0 +1,101 @@
+package job
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/job/jobstate"
+	"github.com/zrepl/zrepl/replication/logic"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+type resumeTokenTestReceiver struct {
+	logic.Receiver
+	fss []*pdu.Filesystem
+}
+
+func (r *resumeTokenTestReceiver) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error) {
+	return &pdu.ListFilesystemRes{Filesystems: r.fss}, nil
+}
+
+func TestResumeTokenTracker(t *testing.T) {
+	ctx := context.Background()
+	tr := newResumeTokenTracker(time.Hour, prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test"}, []string{"filesystem"}))
+	tr.parse = func(ctx context.Context, token string) (*zfs.ResumeToken, error) {
+		return &zfs.ResumeToken{ToName: "pool/a@" + token}, nil
+	}
+	rcv := &resumeTokenTestReceiver{fss: []*pdu.Filesystem{
+		{Path: "pool/a", ResumeToken: "t1"},
+		{Path: "pool/b"},
+	}}
+
+	require.NoError(t, tr.observe(ctx, rcv))
+	s := tr.status()
+	require.Len(t, s, 1)
+	assert.Equal(t, "pool/a", s[0].Filesystem)
+	assert.False(t, s[0].Stale)
+	assert.Empty(t, s[0].Snapshot)
+
+	// unchanged for longer than the threshold
+	tr.byFS["pool/a"].since = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, tr.observe(ctx, rcv))
+	s = tr.status()
+	require.Len(t, s, 1)
+	assert.True(t, s[0].Stale)
+	assert.Equal(t, "pool/a@t1", s[0].Snapshot)
+
+	// progress
+	rcv.fss[0].ResumeToken = "t2"
+	require.NoError(t, tr.observe(ctx, rcv))
+	s = tr.status()
+	require.Len(t, s, 1)
+	assert.False(t, s[0].Stale)
+	assert.Empty(t, s[0].Snapshot)
+	assert.WithinDuration(t, time.Now(), s[0].Since, time.Minute)
+
+	tr.threshold = 0
+	tr.byFS["pool/a"].since = time.Now().Add(-2 * time.Hour)
+	assert.False(t, tr.status()[0].Stale, "threshold 0 disables the check")
+
+	// the resumed receive completed
+	rcv.fss[0].ResumeToken = ""
+	require.NoError(t, tr.observe(ctx, rcv))
+	assert.Nil(t, tr.status())
+}
+
+func TestResumeTokenTrackerPersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-resume-tokens")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ctx := jobstate.Context(context.Background(), jobstate.New(dir, "foo"))
+	newTracker := func() *resumeTokenTracker {
+		tr := newResumeTokenTracker(time.Hour, prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test"}, []string{"filesystem"}))
+		require.NoError(t, tr.restore(ctx))
+		return tr
+	}
+	rcv := &resumeTokenTestReceiver{fss: []*pdu.Filesystem{{Path: "pool/a", ResumeToken: "t1"}}}
+
+	tr := newTracker()
+	require.NoError(t, tr.observe(ctx, rcv))
+	since := tr.status()[0].Since
+
+	// the job after a daemon restart
+	tr = newTracker()
+	require.Len(t, tr.status(), 1)
+	assert.True(t, since.Equal(tr.status()[0].Since))
+	require.NoError(t, tr.observe(ctx, rcv))
+	assert.True(t, since.Equal(tr.status()[0].Since), "an unchanged token must keep the time it was first observed")
+
+	// the resumed receive completed
+	rcv.fss[0].ResumeToken = ""
+	require.NoError(t, tr.observe(ctx, rcv))
+	assert.Nil(t, newTracker().status())
+}
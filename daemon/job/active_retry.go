@@ -0,0 +1,158 @@
+package job
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/runnow"
+)
+
+// retryPolicy re-runs the failed phases of an invocation with exponential backoff.
+type retryPolicy struct {
+	maxAttempts         int
+	backoff, maxBackoff time.Duration
+}
+
+// retryPolicyFromConfig returns nil if in is nil, i.e., failed invocations are not retried.
+func retryPolicyFromConfig(in *config.JobRetry) (*retryPolicy, error) {
+	if in == nil {
+		return nil, nil
+	}
+	if in.MaxAttempts < 1 {
+		return nil, fmt.Errorf("max_attempts must be positive, got %d", in.MaxAttempts)
+	}
+	if in.MaxBackoff < in.Backoff {
+		return nil, fmt.Errorf("max_backoff (%s) must not be less than backoff (%s)", in.MaxBackoff, in.Backoff)
+	}
+	return &retryPolicy{
+		maxAttempts: in.MaxAttempts,
+		backoff:     in.Backoff,
+		maxBackoff:  in.MaxBackoff,
+	}, nil
+}
+
+// delay returns the wait before retry number attempt (1-based).
+func (p *retryPolicy) delay(attempt int) time.Duration {
+	d := p.backoff
+	for i := 1; i < attempt && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	return d
+}
+
+// retryScope returns the scope of an invocation that re-runs the failed phases in errs.
+// Pruning is re-run after replication because it depends on the replication cursor.
+// ok is false if there is nothing to retry, in particular if the invocation was cancelled.
+func retryScope(errs []hooks.JobError) (scope runnow.Scope, ok bool) {
+	if len(errs) == 0 {
+		return runnow.ScopeAll, false
+	}
+	scope = runnow.ScopePrune
+	for _, e := range errs {
+		switch e.Phase {
+		case "invocation":
+			return runnow.ScopeAll, false
+		case "prune_sender", "prune_receiver":
+		default:
+			scope = runnow.ScopeAll
+		}
+	}
+	return scope, true
+}
+
+type RetryStatus struct {
+	// the number of the pending retry, starting at 1
+	Attempt     int
+	MaxAttempts int
+	// the phases that are retried, empty for replication and pruning
+	Scope runnow.Scope `json:",omitempty"`
+	At    time.Time
+}
+
+// activeSideRetry tracks the pending retry of an ActiveSide.
+// Only the job's Run loop calls the methods except status.
+type activeSideRetry struct {
+	policy *retryPolicy // nil disables retries
+
+	timer *time.Timer
+
+	mtx     sync.Mutex
+	pending *RetryStatus
+}
+
+// wait returns a channel that fires when the pending retry is due,
+// or nil if no retry is pending.
+func (r *activeSideRetry) wait() <-chan time.Time {
+	if r.timer == nil {
+		return nil
+	}
+	return r.timer.C
+}
+
+// fired must be called after the channel returned by wait fired.
+func (r *activeSideRetry) fired() RetryStatus {
+	r.timer = nil
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	p := *r.pending
+	r.pending = nil
+	return p
+}
+
+// cancel drops the pending retry, e.g. because a regular invocation supersedes it.
+func (r *activeSideRetry) cancel() {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.pending = nil
+}
+
+// afterInvocation schedules a retry if errs warrant one.
+// attempt is the number of the retry that produced errs, 0 for a regular invocation.
+func (r *activeSideRetry) afterInvocation(log Logger, attempt int, errs []hooks.JobError) {
+	r.cancel()
+	if r.policy == nil {
+		return
+	}
+	scope, ok := retryScope(errs)
+	if !ok {
+		return
+	}
+	if attempt >= r.policy.maxAttempts {
+		log.WithField("attempts", attempt).WithField("error_count", len(errs)).
+			Error("invocation failed, giving up retrying until the next scheduled invocation")
+		return
+	}
+	next := RetryStatus{
+		Attempt:     attempt + 1,
+		MaxAttempts: r.policy.maxAttempts,
+		Scope:       scope,
+		At:          time.Now().Add(r.policy.delay(attempt + 1)),
+	}
+	log.WithField("attempt", next.Attempt).WithField("max_attempts", next.MaxAttempts).
+		WithField("scope", next.Scope).WithField("retry_at", next.At).
+		Warn("invocation failed, scheduling retry")
+	r.timer = time.NewTimer(time.Until(next.At))
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.pending = &next
+}
+
+func (r *activeSideRetry) status() *RetryStatus {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.pending == nil {
+		return nil
+	}
+	s := *r.pending
+	return &s
+}
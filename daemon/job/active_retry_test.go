@@ -0,0 +1,94 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/runnow"
+	"github.com/zrepl/zrepl/logger"
+)
+
+func TestRetryPolicyFromConfig(t *testing.T) {
+	p, err := retryPolicyFromConfig(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	_, err = retryPolicyFromConfig(&config.JobRetry{MaxAttempts: 0, Backoff: time.Minute, MaxBackoff: time.Hour})
+	assert.Error(t, err)
+	_, err = retryPolicyFromConfig(&config.JobRetry{MaxAttempts: 3, Backoff: time.Hour, MaxBackoff: time.Minute})
+	assert.Error(t, err)
+
+	p, err = retryPolicyFromConfig(&config.JobRetry{MaxAttempts: 5, Backoff: time.Minute, MaxBackoff: 5 * time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, p.delay(1))
+	assert.Equal(t, 2*time.Minute, p.delay(2))
+	assert.Equal(t, 4*time.Minute, p.delay(3))
+	assert.Equal(t, 5*time.Minute, p.delay(4))
+	assert.Equal(t, 5*time.Minute, p.delay(100))
+}
+
+func TestRetryScope(t *testing.T) {
+	_, ok := retryScope(nil)
+	assert.False(t, ok)
+
+	scope, ok := retryScope([]hooks.JobError{{Phase: "prune_receiver"}, {Phase: "prune_sender"}})
+	assert.True(t, ok)
+	assert.Equal(t, runnow.ScopePrune, scope)
+
+	scope, ok = retryScope([]hooks.JobError{{Phase: "replication"}, {Phase: "prune_sender"}})
+	assert.True(t, ok)
+	assert.Equal(t, runnow.ScopeAll, scope)
+
+	scope, ok = retryScope([]hooks.JobError{{Phase: "missing_filesystem"}})
+	assert.True(t, ok)
+	assert.Equal(t, runnow.ScopeAll, scope)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, ok = retryScope([]hooks.JobError{{Phase: "replication"}, cancelledError(ctx)})
+	assert.False(t, ok, "cancelled invocations are not retried")
+}
+
+func TestActiveSideRetry(t *testing.T) {
+	log := logger.NewNullLogger()
+	failed := []hooks.JobError{{Phase: "replication", Err: "connection reset"}}
+
+	r := activeSideRetry{}
+	r.afterInvocation(log, 0, failed)
+	assert.Nil(t, r.wait(), "retries are disabled without policy")
+
+	r = activeSideRetry{policy: &retryPolicy{maxAttempts: 2, backoff: time.Millisecond, maxBackoff: time.Millisecond}}
+	r.afterInvocation(log, 0, nil)
+	assert.Nil(t, r.wait())
+	assert.Nil(t, r.status())
+
+	r.afterInvocation(log, 0, failed)
+	require.NotNil(t, r.status())
+	assert.Equal(t, 1, r.status().Attempt)
+	<-r.wait()
+	next := r.fired()
+	assert.Equal(t, 1, next.Attempt)
+	assert.Equal(t, runnow.ScopeAll, next.Scope)
+	assert.Nil(t, r.status())
+
+	r.afterInvocation(log, next.Attempt, failed)
+	<-r.wait()
+	next = r.fired()
+	assert.Equal(t, 2, next.Attempt)
+
+	r.afterInvocation(log, next.Attempt, failed)
+	assert.Nil(t, r.wait(), "gives up after max_attempts")
+	assert.Nil(t, r.status())
+
+	// a regular invocation supersedes the pending retry
+	r.afterInvocation(log, 0, failed)
+	r.cancel()
+	assert.Nil(t, r.wait())
+	assert.Nil(t, r.status())
+}
@@ -1,12 +1,26 @@
 package job
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/jobstate"
+	"github.com/zrepl/zrepl/daemon/job/runnow"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
 	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/replication/driver"
+	"github.com/zrepl/zrepl/replication/logic"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
 	"github.com/zrepl/zrepl/transport"
 )
 
@@ -18,3 +32,193 @@ func TestFakeActiveSideDirectMethodInvocationClientIdentityDoesNotPassValidityTe
 	err = transport.ValidateClientIdentity(clientIdentity)
 	assert.Error(t, err)
 }
+
+func TestRunReplicationIntervalDoesNotBlock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wakeUpCommon := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		runReplicationInterval(ctx, time.Millisecond, wakeUpCommon)
+		close(done)
+	}()
+
+	<-wakeUpCommon
+	time.Sleep(10 * time.Millisecond) // nobody receives, ticks are dropped
+	<-wakeUpCommon
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runReplicationInterval did not return after cancellation")
+	}
+}
+
+func newReplicationOutcomeTestActiveSide() *ActiveSide {
+	return &ActiveSide{
+		promLastSuccessfulReplication: prometheus.NewGauge(prometheus.GaugeOpts{Name: "last_success"}),
+		promFailuresSinceLastSuccess:  prometheus.NewGauge(prometheus.GaugeOpts{Name: "failures"}),
+	}
+}
+
+func TestRecordReplicationOutcome(t *testing.T) {
+	ctx := context.Background()
+	j := newReplicationOutcomeTestActiveSide()
+	j.recordReplicationOutcome(ctx, false)
+	j.recordReplicationOutcome(ctx, false)
+	assert.Equal(t, float64(0), testutil.ToFloat64(j.promLastSuccessfulReplication))
+	assert.Equal(t, float64(2), testutil.ToFloat64(j.promFailuresSinceLastSuccess))
+
+	before := time.Now()
+	j.recordReplicationOutcome(ctx, true)
+	assert.GreaterOrEqual(t, testutil.ToFloat64(j.promLastSuccessfulReplication), float64(before.Unix()))
+	assert.Equal(t, float64(0), testutil.ToFloat64(j.promFailuresSinceLastSuccess))
+
+	j.recordReplicationOutcome(ctx, false)
+	assert.Equal(t, float64(1), testutil.ToFloat64(j.promFailuresSinceLastSuccess))
+	assert.GreaterOrEqual(t, testutil.ToFloat64(j.promLastSuccessfulReplication), float64(before.Unix()))
+}
+
+func TestReplicationOutcomePersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-replication-outcome")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ctx := jobstate.Context(context.Background(), jobstate.New(dir, "foo"))
+
+	j := newReplicationOutcomeTestActiveSide()
+	j.restoreReplicationOutcome(ctx)
+	assert.Equal(t, float64(0), testutil.ToFloat64(j.promLastSuccessfulReplication))
+
+	before := time.Now()
+	j.recordReplicationOutcome(ctx, true)
+	j.recordReplicationOutcome(ctx, false)
+	last := testutil.ToFloat64(j.promLastSuccessfulReplication)
+	assert.GreaterOrEqual(t, last, float64(before.Unix()))
+
+	// the job after a daemon restart
+	j = newReplicationOutcomeTestActiveSide()
+	j.restoreReplicationOutcome(ctx)
+	assert.Equal(t, last, testutil.ToFloat64(j.promLastSuccessfulReplication))
+	assert.Equal(t, float64(1), testutil.ToFloat64(j.promFailuresSinceLastSuccess))
+	j.recordReplicationOutcome(ctx, false)
+	assert.Equal(t, float64(2), testutil.ToFloat64(j.promFailuresSinceLastSuccess))
+}
+
+// doTestMode replicates a single filesystem whose planning blocks until replication is cancelled.
+type doTestMode struct {
+	activeMode
+	sender doTestSender
+}
+
+func (m *doTestMode) ConnectEndpoints(ctx context.Context, connecter transport.Connecter) {}
+func (m *doTestMode) DisconnectEndpoints()                                                {}
+func (m *doTestMode) PlannerPolicy() logic.PlannerPolicy {
+	return logic.PlannerPolicy{SizeEstimationConcurrency: 1}
+}
+func (m *doTestMode) SenderReceiver() (logic.Sender, logic.Receiver) {
+	return m.sender, doTestReceiver{}
+}
+
+type doTestSender struct {
+	logic.Sender
+}
+
+func (doTestSender) WaitForConnectivity(ctx context.Context) error { return nil }
+
+func (doTestSender) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error) {
+	return &pdu.ListFilesystemRes{Filesystems: []*pdu.Filesystem{{Path: "pool/a"}}}, nil
+}
+
+func (doTestSender) ListFilesystemVersions(ctx context.Context, req *pdu.ListFilesystemVersionsReq) (*pdu.ListFilesystemVersionsRes, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+type doTestReceiver struct {
+	logic.Receiver
+}
+
+func (doTestReceiver) WaitForConnectivity(ctx context.Context) error { return nil }
+
+func (doTestReceiver) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error) {
+	return &pdu.ListFilesystemRes{}, nil
+}
+
+func (doTestReceiver) ListFilesystemVersions(ctx context.Context, req *pdu.ListFilesystemVersionsReq) (*pdu.ListFilesystemVersionsRes, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// newDoTestActiveSide returns an ActiveSide whose do method replicates from a doTestMode.
+// Its prunerFactory is nil, i.e., the tests must not prune.
+func newDoTestActiveSide() *ActiveSide {
+	return &ActiveSide{
+		mode: &doTestMode{},
+		replicationDriverConfig: driver.Config{
+			StepQueueConcurrency:     1,
+			MaxAttempts:              1,
+			ReconnectHardFailTimeout: time.Minute,
+		},
+		knownFilesystems:              newKnownFilesystems(MissingFilesystemIgnore),
+		clockSkew:                     &clockSkewTracker{gauge: prometheus.NewGauge(prometheus.GaugeOpts{Name: "skew"})},
+		resumeTokens:                  newResumeTokenTracker(0, prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tokens"}, []string{"filesystem"})),
+		promRepStateSecs:              prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "rep_state_secs"}, []string{"state"}),
+		promBytesReplicated:           prometheus.NewCounterVec(prometheus.CounterOpts{Name: "bytes"}, []string{"filesystem"}),
+		promReplicationErrors:         prometheus.NewGauge(prometheus.GaugeOpts{Name: "errors"}),
+		promLastSuccessfulReplication: prometheus.NewGauge(prometheus.GaugeOpts{Name: "last_success"}),
+		promFailuresSinceLastSuccess:  prometheus.NewGauge(prometheus.GaugeOpts{Name: "failures"}),
+	}
+}
+
+func TestReplicationWindowPauseOnClose(t *testing.T) {
+	now := time.Now()
+	sinceMidnight := now.Sub(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+	if sinceMidnight < time.Minute || sinceMidnight > 24*time.Hour-time.Minute {
+		t.Skip("the test window must not span midnight")
+	}
+
+	j := newDoTestActiveSide()
+	j.replicationWindow = &replicationWindow{
+		ranges:       []replicationWindowRange{{start: sinceMidnight - time.Minute, end: sinceMidnight + 200*time.Millisecond}},
+		pauseOnClose: true,
+	}
+
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	errs := j.do(ctx, runnow.ScopeAll, func(context.Context) error { return nil })
+	require.NoError(t, ctx.Err(), "replication was not paused")
+
+	assert.Empty(t, errs)
+	assert.Equal(t, float64(0), testutil.ToFloat64(j.promFailuresSinceLastSuccess))
+	tasks := j.updateTasks(nil)
+	assert.Nil(t, tasks.prunerSender)
+	assert.Nil(t, tasks.prunerReceiver)
+}
+
+func TestReplicateFirstSnapshotsOnEarlyReturn(t *testing.T) {
+	j := newDoTestActiveSide()
+	j.phaseOrder = phaseOrderReplicateFirst
+	// doTestSender does not list pool/gone, the invocation is aborted before replication
+	j.knownFilesystems = newKnownFilesystems(MissingFilesystemFail)
+	j.knownFilesystems.known["pool/gone"] = true
+
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+	snapshots := 0
+	snapshot := func(context.Context) error {
+		snapshots++
+		return fmt.Errorf("no space left")
+	}
+
+	errs := j.do(ctx, runnow.ScopeAll, snapshot)
+	assert.Equal(t, 1, snapshots)
+	require.Len(t, errs, 2)
+	assert.Equal(t, "missing_filesystem", errs[0].Phase)
+	assert.Equal(t, hooks.JobError{Phase: "snapshot", Err: "no space left"}, errs[1])
+
+	errs = j.do(ctx, runnow.ScopeReplicate, snapshot)
+	assert.Equal(t, 1, snapshots, "snapshot is not in scope")
+	require.Len(t, errs, 1)
+}
@@ -11,9 +11,19 @@ import (
 )
 
 func JobsFromConfig(c *config.Config) ([]Job, error) {
+	if c.Global.MaxConcurrentJobs < 0 {
+		return nil, fmt.Errorf("global.max_concurrent_jobs must not be negative, got %d", c.Global.MaxConcurrentJobs)
+	}
+	concurrentJobs := newConcurrentJobsLimiter(c.Global.MaxConcurrentJobs)
+	metricLabels, err := MetricLabelsFromConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	dependencies := jobDependenciesFromConfig(c)
+
 	js := make([]Job, len(c.Jobs))
 	for i := range c.Jobs {
-		j, err := buildJob(c.Global, c.Jobs[i])
+		j, err := buildJob(c.Global, c.Jobs[i], concurrentJobs, dependencies[c.Jobs[i].Name()], metricLabels)
 		if err != nil {
 			return nil, err
 		}
@@ -41,7 +51,7 @@ func JobsFromConfig(c *config.Config) ([]Job, error) {
 	return js, nil
 }
 
-func buildJob(c *config.Global, in config.JobEnum) (j Job, err error) {
+func buildJob(c *config.Global, in config.JobEnum, concurrentJobs *concurrentJobsLimiter, dependencies *jobDependencies, metricLabels *MetricLabels) (j Job, err error) {
 	cannotBuildJob := func(e error, name string) (Job, error) {
 		return nil, errors.Wrapf(e, "cannot build job %q", name)
 	}
@@ -58,17 +68,17 @@ func buildJob(c *config.Global, in config.JobEnum) (j Job, err error) {
 			return cannotBuildJob(err, v.Name)
 		}
 	case *config.SnapJob:
-		j, err = snapJobFromConfig(c, v)
+		j, err = snapJobFromConfig(c, v, concurrentJobs, dependencies, metricLabels)
 		if err != nil {
 			return cannotBuildJob(err, v.Name)
 		}
 	case *config.PushJob:
-		j, err = activeSide(c, &v.ActiveJob, v)
+		j, err = activeSide(c, &v.ActiveJob, v, concurrentJobs, dependencies, metricLabels)
 		if err != nil {
 			return cannotBuildJob(err, v.Name)
 		}
 	case *config.PullJob:
-		j, err = activeSide(c, &v.ActiveJob, v)
+		j, err = activeSide(c, &v.ActiveJob, v, concurrentJobs, dependencies, metricLabels)
 		if err != nil {
 			return cannotBuildJob(err, v.Name)
 		}
@@ -0,0 +1,118 @@
+package job
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+// MetricLabels are the labels from the metric_labels fields of all jobs.
+//
+// Metrics with the same name must have the same label names,
+// so every job's metrics get all label names, with an empty value
+// (which Prometheus treats like a missing label) for those the job doesn't set.
+type MetricLabels struct {
+	// sorted
+	Names []string
+	// by job name
+	values map[string]map[string]string
+}
+
+const (
+	metricLabelsMaxPerJob      = 10
+	metricLabelsMaxValueLength = 128
+)
+
+var metricLabelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// label names used by zrepl's metrics or attached by Prometheus when scraping
+var metricLabelsReserved = map[string]bool{
+	"zrepl_job":  true,
+	"state":      true,
+	"filesystem": true,
+	"prune_side": true,
+	"jobid":      true,
+	"zfsbinary":  true,
+	"zfsverb":    true,
+	"job":        true,
+	"instance":   true,
+}
+
+type metricLabelsJob interface {
+	GetMetricLabels() map[string]string
+}
+
+func MetricLabelsFromConfig(c *config.Config) (*MetricLabels, error) {
+	l := &MetricLabels{values: make(map[string]map[string]string)}
+	names := make(map[string]bool)
+	for _, j := range c.Jobs {
+		mlj, ok := j.Ret.(metricLabelsJob)
+		if !ok {
+			continue
+		}
+		labels := mlj.GetMetricLabels()
+		if err := validateMetricLabels(labels); err != nil {
+			return nil, errors.Wrapf(err, "job %q: field `metric_labels`", j.Name())
+		}
+		l.values[j.Name()] = labels
+		for name := range labels {
+			names[name] = true
+		}
+	}
+	for name := range names {
+		l.Names = append(l.Names, name)
+	}
+	sort.Strings(l.Names)
+	return l, nil
+}
+
+func validateMetricLabels(labels map[string]string) error {
+	if len(labels) > metricLabelsMaxPerJob {
+		return fmt.Errorf("at most %d labels are allowed, got %d", metricLabelsMaxPerJob, len(labels))
+	}
+	for name, value := range labels {
+		if !metricLabelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name %q: must match %s and must not start with __", name, metricLabelNameRE)
+		}
+		if metricLabelsReserved[name] {
+			return fmt.Errorf("label name %q is reserved", name)
+		}
+		if value == "" || len(value) > metricLabelsMaxValueLength {
+			return fmt.Errorf("value of label %q must not be empty or longer than %d characters", name, metricLabelsMaxValueLength)
+		}
+	}
+	return nil
+}
+
+// Values returns the values of Names for job.
+func (l *MetricLabels) Values(job string) []string {
+	values := make([]string, len(l.Names))
+	for i, name := range l.Names {
+		values[i] = l.values[job][name]
+	}
+	return values
+}
+
+// ValuesByJob returns the values of Names for every job, see Values.
+func (l *MetricLabels) ValuesByJob() map[string][]string {
+	m := make(map[string][]string, len(l.values))
+	for job := range l.values {
+		m[job] = l.Values(job)
+	}
+	return m
+}
+
+// ConstLabels returns the constant labels of the metrics of job, including zrepl_job.
+func (l *MetricLabels) ConstLabels(job string) prometheus.Labels {
+	labels := prometheus.Labels{"zrepl_job": job}
+	for i, v := range l.Values(job) {
+		labels[l.Names[i]] = v
+	}
+	return labels
+}
@@ -0,0 +1,117 @@
+package job
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestMetricLabels(t *testing.T) {
+	conf, err := config.ParseConfigBytes([]byte(`
+jobs:
+- name: push
+  type: push
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 10
+  metric_labels:
+    team: storage
+    env: prod
+- name: pull
+  type: pull
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  root_fs: zreplplatformtest
+  interval: manual
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 10
+  metric_labels:
+    team: backup
+- name: snap
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 10
+`))
+	require.NoError(t, err)
+
+	labels, err := MetricLabelsFromConfig(conf)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"env", "team"}, labels.Names)
+	assert.Equal(t, []string{"", "backup"}, labels.Values("pull"))
+	assert.Equal(t, prometheus.Labels{"zrepl_job": "snap", "env": "", "team": ""}, labels.ConstLabels("snap"))
+
+	// metrics of the same name must have the same label names in all jobs
+	jobs, err := JobsFromConfig(conf)
+	require.NoError(t, err)
+	registry := prometheus.NewRegistry()
+	for _, j := range jobs {
+		j.RegisterMetrics(registry)
+	}
+	_, err = registry.Gather()
+	assert.NoError(t, err)
+}
+
+func TestMetricLabelsValidation(t *testing.T) {
+	tmpl := `
+jobs:
+- name: snap
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 10
+  metric_labels:
+    %s
+`
+	cases := map[string]bool{
+		`team: storage`:   true,
+		`Team_2: storage`: true,
+		`__team: storage`: false,
+		`2team: storage`:  false,
+		`team-name: foo`:  false,
+		`zrepl_job: foo`:  false,
+		`instance: foo`:   false,
+		`team: ""`:        false,
+		`{a: 1, b: 2, c: 3, d: 4, e: 5, f: 6, g: 7, h: 8, i: 9, j: 10, k: 11}`: false,
+	}
+	for labels, valid := range cases {
+		conf, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, labels)))
+		require.NoError(t, err, labels)
+		_, err = JobsFromConfig(conf)
+		if valid {
+			assert.NoError(t, err, labels)
+		} else {
+			t.Logf("error: %s", err)
+			assert.Error(t, err, labels)
+		}
+	}
+}
@@ -8,11 +8,13 @@ import (
 	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/util/nodefault"
 	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
 type SendingJobConfig interface {
 	GetFilesystems() config.FilesystemsFilter
 	GetSendOptions() *config.SendOptions // must not be nil
+	GetSendOverrides() []*config.SendOptionsOverride
 }
 
 func buildSenderConfig(in SendingJobConfig, jobID endpoint.JobID) (*endpoint.SenderConfig, error) {
@@ -21,11 +23,40 @@ func buildSenderConfig(in SendingJobConfig, jobID endpoint.JobID) (*endpoint.Sen
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build filesystem filter")
 	}
-	sendOpts := in.GetSendOptions()
-	return &endpoint.SenderConfig{
+	sendOpts, err := buildSendOptions(in.GetSendOptions())
+	if err != nil {
+		return nil, err
+	}
+	overrides := make([]endpoint.SendOptionsOverride, len(in.GetSendOverrides()))
+	for i, o := range in.GetSendOverrides() {
+		overrides[i].FSF, err = filters.DatasetMapFilterFromConfig(o.Filesystems)
+		if err != nil {
+			return nil, errors.Wrapf(err, "field `send_overrides[%d].filesystems`", i)
+		}
+		overrides[i].SendOptions, err = buildSendOptions(o.Send)
+		if err != nil {
+			return nil, errors.Wrapf(err, "field `send_overrides[%d].send`", i)
+		}
+	}
+	c := &endpoint.SenderConfig{
 		FSF:   fsf,
 		JobID: jobID,
 
+		SendOptions: sendOpts,
+		Overrides:   overrides,
+	}
+	if err := c.Validate(); err != nil {
+		return nil, errors.Wrap(err, "cannot build sender config")
+	}
+	return c, nil
+}
+
+func buildSendOptions(sendOpts *config.SendOptions) (o endpoint.SendOptions, err error) {
+	encryptionRootChange, err := endpoint.EncryptionRootChangeFromString(sendOpts.EncryptionRootChange)
+	if err != nil {
+		return o, errors.Wrap(err, "field `encryption_root_change`")
+	}
+	return endpoint.SendOptions{
 		Encrypt:              &nodefault.Bool{B: sendOpts.Encrypted},
 		SendRaw:              sendOpts.Raw,
 		SendProperties:       sendOpts.SendProperties,
@@ -34,6 +65,9 @@ func buildSenderConfig(in SendingJobConfig, jobID endpoint.JobID) (*endpoint.Sen
 		SendCompressed:       sendOpts.Compressed,
 		SendEmbeddedData:     sendOpts.EmbeddedData,
 		SendSaved:            sendOpts.Saved,
+
+		EncryptionRootChange: encryptionRootChange,
+		NegotiateFeatures:    sendOpts.NegotiateFeatures,
 	}, nil
 }
 
@@ -41,25 +75,62 @@ type ReceivingJobConfig interface {
 	GetRootFS() string
 	GetAppendClientIdentity() bool
 	GetRecvOptions() *config.RecvOptions
+	GetMinFreeSpace() *config.MinFreeSpace
 }
 
 func buildReceiverConfig(in ReceivingJobConfig, jobID endpoint.JobID) (rc endpoint.ReceiverConfig, err error) {
-	rootFs, err := zfs.NewDatasetPath(in.GetRootFS())
-	if err != nil {
-		return rc, errors.New("root_fs is not a valid zfs filesystem path")
+	var rootTemplate *endpoint.ClientRootTemplate
+	var rootFs *zfs.DatasetPath
+	if endpoint.IsClientRootTemplate(in.GetRootFS()) {
+		if !in.GetAppendClientIdentity() {
+			return rc, errors.New("root_fs templates are only supported by sink jobs")
+		}
+		rootTemplate, err = endpoint.ParseClientRootTemplate(in.GetRootFS())
+		if err != nil {
+			return rc, errors.Wrap(err, "invalid root_fs template")
+		}
+		rootFs = rootTemplate.Prefix()
+	} else {
+		rootFs, err = zfs.NewDatasetPath(in.GetRootFS())
+		if err != nil {
+			return rc, errors.New("root_fs is not a valid zfs filesystem path")
+		}
 	}
 	if rootFs.Length() <= 0 {
 		return rc, errors.New("root_fs must not be empty") // duplicates error check of receiver
 	}
 
 	recvOpts := in.GetRecvOptions()
+	placeholderEncryption, err := zfs.PlaceholderCreationEncryptionPropertyFromString(recvOpts.Placeholder.Encryption)
+	if err != nil {
+		return rc, errors.Wrap(err, "field `recv.placeholder.encryption`")
+	}
+	mount, err := zfs.RecvMountBehaviorFromString(recvOpts.Mount)
+	if err != nil {
+		return rc, errors.Wrap(err, "field `recv.mount`")
+	}
 	rc = endpoint.ReceiverConfig{
 		JobID:                      jobID,
 		RootWithoutClientComponent: rootFs,
 		AppendClientIdentity:       in.GetAppendClientIdentity(),
+		ClientRootTemplate:         rootTemplate,
 
 		InheritProperties:  recvOpts.Properties.Inherit,
 		OverrideProperties: recvOpts.Properties.Override,
+
+		ExcludeRejectedProperties: recvOpts.Properties.ExcludeRejected,
+
+		ForceRollback:                recvOpts.ForceRollback.Enabled,
+		ForceRollbackAllowDivergence: recvOpts.ForceRollback.AllowDivergence,
+
+		CreateParents: recvOpts.CreateParents,
+		RequireExists: recvOpts.RequireExists,
+
+		MinFreeSpace: minFreeSpaceFromConfig(in.GetMinFreeSpace()),
+
+		PlaceholderEncryption: placeholderEncryption,
+
+		Mount: mount,
 	}
 	if err := rc.Validate(); err != nil {
 		return rc, errors.Wrap(err, "cannot build receiver config")
@@ -67,3 +138,31 @@ func buildReceiverConfig(in ReceivingJobConfig, jobID endpoint.JobID) (rc endpoi
 
 	return rc, nil
 }
+
+func minFreeSpaceFromConfig(in *config.MinFreeSpace) *zfs.MinFreeSpace {
+	if in == nil {
+		return nil
+	}
+	return &zfs.MinFreeSpace{Bytes: in.Bytes, Percent: in.Percent}
+}
+
+// zfsPriorityFromConfig returns the priority of a job with zfs_priority in,
+// falling back to global.zfs_priority. It returns nil if neither is set.
+func zfsPriorityFromConfig(g *config.Global, in *config.ZFSPriority) (*zfscmd.Priority, error) {
+	if in == nil {
+		in = g.ZFSPriority
+	}
+	if in == nil {
+		return nil, nil
+	}
+	p := &zfscmd.Priority{
+		Nice:        in.Nice,
+		IONiceClass: in.IONiceClass,
+		IONiceLevel: in.IONiceLevel,
+		AllCommands: in.AllCommands,
+	}
+	if err := p.Validate(); err != nil {
+		return nil, errors.Wrap(err, "field `zfs_priority`")
+	}
+	return p, nil
+}
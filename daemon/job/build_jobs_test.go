@@ -5,6 +5,7 @@ import (
 	"path"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/kr/pretty"
 	"github.com/stretchr/testify/assert"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/transport/tls"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
 func TestValidateReceivingSidesDoNotOverlap(t *testing.T) {
@@ -213,6 +215,57 @@ jobs:
 				assert.Equal(t, 42, m.plannerPolicy.SizeEstimationConcurrency)
 			},
 		},
+		{
+			name: "replication_interval",
+			input: `
+  replication_interval: 5m
+`,
+			expectOk: func(t *testing.T, a *ActiveSide, m *modePush) {
+				assert.Equal(t, 5*time.Minute, m.replicationInterval)
+			},
+		},
+		{
+			name:  "replication_interval_default",
+			input: ``,
+			expectOk: func(t *testing.T, a *ActiveSide, m *modePush) {
+				assert.Equal(t, time.Duration(0), m.replicationInterval)
+			},
+		},
+		{
+			name:  "intermediates_default",
+			input: ``,
+			expectOk: func(t *testing.T, a *ActiveSide, m *modePush) {
+				assert.False(t, m.plannerPolicy.SendIntermediates)
+			},
+		},
+		{
+			name: "intermediates_single_stream",
+			input: `
+  replication:
+    intermediates: single_stream
+    protection:
+      incremental: guarantee_incremental
+`,
+			expectOk: func(t *testing.T, a *ActiveSide, m *modePush) {
+				assert.True(t, m.plannerPolicy.SendIntermediates)
+			},
+		},
+		{
+			name: "intermediates_single_stream_guarantee_resumability",
+			input: `
+  replication:
+    intermediates: single_stream
+`,
+			expectError: true,
+		},
+		{
+			name: "intermediates_invalid",
+			input: `
+  replication:
+    intermediates: foo
+`,
+			expectError: true,
+		},
 		{
 			name: "negative_values_forbidden",
 			input: `
@@ -254,3 +307,391 @@ jobs:
 	}
 
 }
+
+func TestRecvOverridePropertyValues(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: pull
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  root_fs: zreplplatformtest
+  interval: manual
+  recv:
+    properties:
+      override:
+        %s
+  pruning:
+    keep_sender:
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: last_n
+      count: 10
+`
+	type Case struct {
+		override string
+		valid    bool
+	}
+	cases := []Case{
+		{`compression: "lz4"`, true},
+		{`"com.example:note": "nope"`, true},
+		{`compression: "nope"`, false},
+		{`canmount: "auto"`, false},
+	}
+	for _, c := range cases {
+		// invalid values are rejected when the config is parsed
+		conf, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, c.override)))
+		if !c.valid {
+			t.Logf("error: %s", err)
+			assert.Error(t, err, c.override)
+			continue
+		}
+		require.NoError(t, err)
+		_, err = JobsFromConfig(conf)
+		assert.NoError(t, err, c.override)
+	}
+}
+
+func TestRecvMount(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: pull
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  root_fs: zreplplatformtest
+  interval: manual
+  recv:
+    %s
+  pruning:
+    keep_sender:
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: last_n
+      count: 10
+`
+	type Case struct {
+		recv  string
+		valid bool
+	}
+	cases := []Case{
+		{`mount: keep`, true},
+		{`mount: noauto`, true},
+		{`mount: never`, true},
+		{`mount: nope`, false},
+		{"mount: noauto\n    properties:\n      override:\n        canmount: \"on\"", true},
+		{"mount: never\n    properties:\n      inherit:\n      - canmount", true},
+		{"properties:\n      override:\n        canmount: \"off\"", true},
+		{"mount: keep\n    properties:\n      override:\n        canmount: \"noauto\"", true},
+	}
+	for _, c := range cases {
+		conf, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, c.recv)))
+		require.NoError(t, err, c.recv)
+		_, err = JobsFromConfig(conf)
+		if c.valid {
+			assert.NoError(t, err, c.recv)
+		} else {
+			t.Logf("error: %s", err)
+			assert.Error(t, err, c.recv)
+		}
+	}
+}
+
+func TestRecvOptionsContradictions(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: pull
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  root_fs: zreplplatformtest
+  interval: manual
+  recv:
+    %s
+  pruning:
+    keep_sender:
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: last_n
+      count: 10
+`
+	type Case struct {
+		recv string
+		err  string // empty if valid
+	}
+	cases := []Case{
+		{"properties:\n      inherit:\n      - mountpoint\n      override:\n        mountpoint: none", `property "mountpoint" is both inherited and overridden`},
+		{"properties:\n      inherit:\n      - mountpoint\n      override:\n        atime: \"off\"", ""},
+		{"properties:\n      override:\n        \"zrepl:placeholder\": \"on\"", `override property "zrepl:placeholder" conflicts with zrepl's placeholder management`},
+		{"properties:\n      inherit:\n      - \"zrepl:placeholder\"", ""},
+		{"placeholder:\n      encryption: inherit\n    properties:\n      override:\n        encryption: \"off\"", `override property "encryption" = "off" conflicts with placeholder encryption "inherit"`},
+		{"placeholder:\n      encryption: \"off\"\n    properties:\n      override:\n        encryption: \"off\"", ""},
+		{"placeholder:\n      encryption: inherit", ""},
+	}
+	for _, c := range cases {
+		conf, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, c.recv)))
+		require.NoError(t, err, c.recv)
+		_, err = JobsFromConfig(conf)
+		if c.err == "" {
+			assert.NoError(t, err, c.recv)
+		} else if assert.Error(t, err, c.recv) {
+			assert.Contains(t, err.Error(), c.err, c.recv)
+		}
+	}
+}
+
+func TestPruningConvertToBookmark(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: %s
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  %s
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    - type: last_n
+      count: 10
+      convert_to_bookmark: %t
+    keep_receiver:
+    - type: regex
+      regex: ".*"
+      convert_to_bookmark: %t
+`
+	type Case struct {
+		jobType          string
+		sender, receiver bool
+		valid            bool
+	}
+	cases := []Case{
+		{"push", false, false, true},
+		{"push", true, false, true},
+		{"push", false, true, false},
+		{"pull", false, false, true},
+		{"pull", true, false, false},
+	}
+	for _, c := range cases {
+		specific := `filesystems: {"<": true}
+  snapshotting:
+    type: manual`
+		if c.jobType == "pull" {
+			specific = "root_fs: zreplplatformtest\n  interval: manual"
+		}
+		conf, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, c.jobType, specific, c.sender, c.receiver)))
+		require.NoError(t, err, "%#v", c)
+		_, err = JobsFromConfig(conf)
+		if c.valid {
+			assert.NoError(t, err, "%#v", c)
+		} else {
+			assert.Error(t, err, "%#v", c)
+		}
+	}
+}
+
+func TestPruningProtectForeignSnapshots(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: %s
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  %s
+  pruning:
+    protect_foreign_snapshots: true
+    %s
+    keep_sender:
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: last_n
+      count: 10
+`
+	periodic := `filesystems: {"<": true}
+  snapshotting:
+    type: periodic
+    prefix: zrepl_
+    interval: 10m`
+	manual := `filesystems: {"<": true}
+  snapshotting:
+    type: manual`
+	pull := "root_fs: zreplplatformtest\n  interval: manual"
+
+	type Case struct {
+		jobType, specific, managedPrefixes string
+		expectPrefixes                     []string // nil if invalid
+	}
+	cases := []Case{
+		{"push", periodic, "", []string{"zrepl_"}},
+		{"push", periodic, "managed_prefixes: [manual_]", []string{"zrepl_", "manual_"}},
+		{"push", manual, "", nil},
+		{"push", manual, "managed_prefixes: [manual_]", []string{"manual_"}},
+		{"pull", pull, "", nil},
+		{"pull", pull, "managed_prefixes: [zrepl_]", []string{"zrepl_"}},
+	}
+	for _, c := range cases {
+		conf, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, c.jobType, c.specific, c.managedPrefixes)))
+		require.NoError(t, err, "%#v", c)
+		jobs, err := JobsFromConfig(conf)
+		if c.expectPrefixes == nil {
+			if assert.Error(t, err, "%#v", c) {
+				assert.Contains(t, err.Error(), "`protect_foreign_snapshots` requires at least one managed snapshot prefix", "%#v", c)
+			}
+			continue
+		}
+		require.NoError(t, err, "%#v", c)
+		assert.Equal(t, c.expectPrefixes, jobs[0].(*ActiveSide).prunerFactory.ReceiverManagedPrefixes(), "%#v", c)
+	}
+}
+
+func TestSendOverrides(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: push
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  filesystems: {"<": true}
+  send:
+    encrypted: true
+  send_overrides:
+  %s
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: last_n
+      count: 10
+`
+	type Case struct {
+		overrides string
+		valid     bool
+	}
+	cases := []Case{
+		{`- {filesystems: {"pool/media<": true}, send: {encrypted: true, compressed: true}}`, true},
+		{`- {filesystems: {"pool/media<": true}, send: {encrypted: false}}`, true},
+		{`- {filesystems: {"pool/media@snap": true}}`, false},
+		{`- {filesystems: {"pool/media<": true}, send: {encryption_root_change: nope}}`, false},
+	}
+	for _, c := range cases {
+		conf, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, c.overrides)))
+		require.NoError(t, err, c.overrides)
+		_, err = JobsFromConfig(conf)
+		if c.valid {
+			assert.NoError(t, err, c.overrides)
+		} else {
+			t.Logf("error: %s", err)
+			assert.Error(t, err, c.overrides)
+		}
+	}
+}
+
+func TestRootFSTemplate(t *testing.T) {
+	sink := `
+jobs:
+- name: foo
+  type: sink
+  serve:
+    type: local
+    listener_name: foo
+  root_fs: %q
+`
+	pull := `
+jobs:
+- name: foo
+  type: pull
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  root_fs: %q
+  interval: manual
+  pruning:
+    keep_sender:
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: last_n
+      count: 10
+`
+	type Case struct {
+		tmpl, rootFS string
+		valid        bool
+	}
+	cases := []Case{
+		{sink, "backups/{client_identity}/data", true},
+		{sink, "backups/{client_identity.1}/{client_identity}", true},
+		{sink, "backups/{client_identity.1}", false},
+		{sink, "{client_identity}", false},
+		{sink, "backups/{client_identity", false},
+		{pull, "backups/{client_identity}", false},
+	}
+	for _, c := range cases {
+		conf, err := config.ParseConfigBytes([]byte(fmt.Sprintf(c.tmpl, c.rootFS)))
+		require.NoError(t, err, c.rootFS)
+		jobs, err := JobsFromConfig(conf)
+		if !c.valid {
+			t.Logf("error: %s", err)
+			assert.Error(t, err, c.rootFS)
+			continue
+		}
+		require.NoError(t, err, c.rootFS)
+		rfs, ok := jobs[0].OwnedDatasetSubtreeRoot()
+		require.True(t, ok)
+		assert.Equal(t, "backups", rfs.ToString())
+	}
+}
+
+func TestZFSPriority(t *testing.T) {
+	tmpl := `
+global:
+  zfs_priority:
+    nice: 10
+    ionice_class: idle
+jobs:
+- name: inherits
+  type: sink
+  serve:
+    type: local
+    listener_name: inherits
+  root_fs: "backups/inherits"
+- name: overrides
+  type: sink
+  serve:
+    type: local
+    listener_name: overrides
+  root_fs: "backups/overrides"
+  zfs_priority:
+    ionice_class: %s
+    all_commands: true
+`
+	conf, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, "best-effort")))
+	require.NoError(t, err)
+	jobs, err := JobsFromConfig(conf)
+	require.NoError(t, err)
+	assert.Equal(t, &zfscmd.Priority{Nice: 10, IONiceClass: "idle", IONiceLevel: 4}, jobs[0].(*PassiveSide).zfsPriority)
+	assert.Equal(t, &zfscmd.Priority{IONiceClass: "best-effort", IONiceLevel: 4, AllCommands: true}, jobs[1].(*PassiveSide).zfsPriority)
+
+	conf, err = config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, "background")))
+	require.NoError(t, err)
+	_, err = JobsFromConfig(conf)
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package job
+
+import (
+	"context"
+
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/util/semaphore"
+)
+
+// concurrentJobsLimiter limits the number of jobs whose invocations
+// (replication and pruning, or pruning for snap jobs) or periodic snapshotting
+// run concurrently, see config.Global.MaxConcurrentJobs.
+//
+// A nil *concurrentJobsLimiter does not limit concurrency.
+type concurrentJobsLimiter struct {
+	sem *semaphore.S
+}
+
+// newConcurrentJobsLimiter returns nil if max is 0.
+func newConcurrentJobsLimiter(max int) *concurrentJobsLimiter {
+	if max == 0 {
+		return nil
+	}
+	return &concurrentJobsLimiter{semaphore.New(int64(max))}
+}
+
+// acquire blocks until the invocation may run or ctx is done.
+// onQueued is called before blocking if the invocation has to wait for other jobs.
+// The returned guard must be released when the invocation is done.
+func (l *concurrentJobsLimiter) acquire(ctx context.Context, onQueued func()) (*semaphore.AcquireGuard, error) {
+	if l == nil {
+		return nil, nil
+	}
+	if g := l.sem.TryAcquire(); g != nil {
+		return g, nil
+	}
+	onQueued()
+	return l.sem.Acquire(ctx)
+}
+
+// snapperLimiter returns the limiter for periodic snapshotting, nil if l is nil.
+func (l *concurrentJobsLimiter) snapperLimiter() snapper.Limiter {
+	if l == nil {
+		return nil
+	}
+	return snapperLimiter{l}
+}
+
+type snapperLimiter struct {
+	l *concurrentJobsLimiter
+}
+
+var _ snapper.Limiter = snapperLimiter{}
+
+func (s snapperLimiter) Acquire(ctx context.Context, onQueued func()) (release func(), err error) {
+	guard, err := s.l.acquire(ctx, onQueued)
+	if err != nil {
+		return nil, err
+	}
+	return guard.Release, nil
+}
@@ -0,0 +1,58 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+)
+
+func TestConcurrentJobsLimiter(t *testing.T) {
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	notQueued := func() { t.Fatal("must not be queued") }
+
+	var unlimited *concurrentJobsLimiter = newConcurrentJobsLimiter(0)
+	require.Nil(t, unlimited)
+	g, err := unlimited.acquire(ctx, notQueued)
+	require.NoError(t, err)
+	g.Release() // must be safe on nil guard
+
+	l := newConcurrentJobsLimiter(1)
+	first, err := l.acquire(ctx, notQueued)
+	require.NoError(t, err)
+
+	queued := make(chan struct{})
+	acquired := make(chan struct{})
+	go func() {
+		ctx, end := trace.WithTaskFromStack(ctx)
+		defer end()
+		g, err := l.acquire(ctx, func() { close(queued) })
+		assert.NoError(t, err)
+		close(acquired)
+		g.Release()
+	}()
+
+	<-queued
+	select {
+	case <-acquired:
+		t.Fatal("second invocation must wait for the first one")
+	case <-time.After(50 * time.Millisecond):
+	}
+	first.Release()
+	<-acquired
+
+	// acquisition is cancellable
+	first, err = l.acquire(ctx, notQueued)
+	require.NoError(t, err)
+	defer first.Release()
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = l.acquire(cctx, func() {})
+	assert.Error(t, err)
+}
@@ -44,6 +44,8 @@ const (
 type Status struct {
 	Type        Type
 	JobSpecific interface{}
+	// set by the daemon, not by the job
+	Paused bool
 }
 
 func (s *Status) MarshalJSON() ([]byte, error) {
@@ -59,6 +61,9 @@ func (s *Status) MarshalJSON() ([]byte, error) {
 		"type":         typeJson,
 		string(s.Type): jobJSON,
 	}
+	if s.Paused {
+		m["paused"] = json.RawMessage("true")
+	}
 	return json.Marshal(m)
 }
 
@@ -74,6 +79,11 @@ func (s *Status) UnmarshalJSON(in []byte) (err error) {
 	if err := json.Unmarshal(tJSON, &s.Type); err != nil {
 		return err
 	}
+	if pJSON, ok := m["paused"]; ok {
+		if err := json.Unmarshal(pJSON, &s.Paused); err != nil {
+			return err
+		}
+	}
 	key := string(s.Type)
 	jobJSON, ok := m[key]
 	if !ok {
@@ -0,0 +1,113 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+)
+
+// jobInvocations tracks the running invocation of a job
+// so that the jobs which depend on it (config depends_on) can wait for it.
+type jobInvocations struct {
+	mtx     sync.Mutex
+	running *jobInvocation // nil if no invocation is running
+}
+
+type jobInvocation struct {
+	done   chan struct{} // closed when the invocation is done
+	failed bool          // valid once done is closed
+}
+
+// jobDependencies connects a job's invocations with those of the jobs listed in its depends_on.
+//
+// A nil *jobDependencies neither tracks nor waits.
+type jobDependencies struct {
+	self *jobInvocations
+	deps []namedJobInvocations
+}
+
+type namedJobInvocations struct {
+	name string
+	*jobInvocations
+}
+
+// jobDependenciesFromConfig returns the jobDependencies of each job in c, by job name.
+// The config's validation ensures that the dependencies exist and have no cycles.
+func jobDependenciesFromConfig(c *config.Config) map[string]*jobDependencies {
+	invocations := make(map[string]*jobInvocations, len(c.Jobs))
+	for _, j := range c.Jobs {
+		invocations[j.Name()] = &jobInvocations{}
+	}
+	deps := make(map[string]*jobDependencies, len(c.Jobs))
+	for _, j := range c.Jobs {
+		d := &jobDependencies{self: invocations[j.Name()]}
+		for _, dep := range j.DependsOn() {
+			d.deps = append(d.deps, namedJobInvocations{dep, invocations[dep]})
+		}
+		deps[j.Name()] = d
+	}
+	return deps
+}
+
+// begin marks an invocation of the job as running.
+// The returned function must be called when the invocation is done.
+func (d *jobDependencies) begin() (end func(errs []hooks.JobError)) {
+	if d == nil {
+		return func([]hooks.JobError) {}
+	}
+	inv := &jobInvocation{done: make(chan struct{})}
+	d.self.mtx.Lock()
+	d.self.running = inv
+	d.self.mtx.Unlock()
+	return func(errs []hooks.JobError) {
+		d.self.mtx.Lock()
+		if d.self.running == inv {
+			d.self.running = nil
+		}
+		d.self.mtx.Unlock()
+		inv.failed = len(errs) > 0
+		close(inv.done)
+	}
+}
+
+// wait blocks until the running invocations of all dependencies are done.
+// onWait is called before waiting for a dependency.
+// It fails if one of these invocations failed or if ctx is done before.
+func (d *jobDependencies) wait(ctx context.Context, onWait func(dep string)) error {
+	if d == nil {
+		return nil
+	}
+	for _, dep := range d.deps {
+		dep.mtx.Lock()
+		inv := dep.running
+		dep.mtx.Unlock()
+		if inv == nil {
+			continue
+		}
+		onWait(dep.name)
+		select {
+		case <-inv.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if inv.failed {
+			return fmt.Errorf("invocation of job %q failed", dep.name)
+		}
+	}
+	return nil
+}
+
+// waitForDependencies is jobDependencies.wait for use within an invocation:
+// a failure is returned as the invocation's error.
+func waitForDependencies(ctx context.Context, d *jobDependencies, onWait func(dep string)) []hooks.JobError {
+	if err := d.wait(ctx, onWait); err != nil {
+		if ctx.Err() != nil {
+			return []hooks.JobError{cancelledError(ctx)}
+		}
+		return []hooks.JobError{{Phase: "depends_on", Err: err.Error()}}
+	}
+	return nil
+}
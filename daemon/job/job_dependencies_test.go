@@ -0,0 +1,70 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+)
+
+func TestJobDependencies(t *testing.T) {
+	conf := &config.Config{Jobs: []config.JobEnum{
+		{Ret: &config.SnapJob{Name: "a"}},
+		{Ret: &config.SnapJob{Name: "b", DependsOn: []string{"a"}}},
+	}}
+	deps := jobDependenciesFromConfig(conf)
+	a, b := deps["a"], deps["b"]
+	ctx := context.Background()
+
+	notWaiting := func(dep string) { t.Fatalf("must not wait for %q", dep) }
+
+	// a is not running
+	require.NoError(t, b.wait(ctx, notWaiting))
+	// a does not wait for b
+	endB := b.begin()
+	require.NoError(t, a.wait(ctx, notWaiting))
+	endB(nil)
+
+	// b waits for the running invocation of a
+	endA := a.begin()
+	waited := make(chan error)
+	go func() {
+		waited <- b.wait(ctx, func(dep string) { assert.Equal(t, "a", dep) })
+	}()
+	select {
+	case <-waited:
+		t.Fatal("must wait for a")
+	case <-time.After(50 * time.Millisecond):
+	}
+	endA(nil)
+	require.NoError(t, <-waited)
+
+	// a failed
+	endA = a.begin()
+	waiting := make(chan struct{})
+	go func() {
+		waited <- b.wait(ctx, func(string) { close(waiting) })
+	}()
+	<-waiting
+	endA([]hooks.JobError{{Phase: "prune", Err: "some error"}})
+	assert.EqualError(t, <-waited, `invocation of job "a" failed`)
+
+	// only the running invocation counts
+	require.NoError(t, b.wait(ctx, notWaiting))
+
+	// ctx done while waiting
+	endA = a.begin()
+	defer endA(nil)
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Equal(t, context.Canceled, b.wait(ctx, func(string) {}))
+
+	var none *jobDependencies
+	none.begin()(nil)
+	require.NoError(t, none.wait(ctx, notWaiting))
+}
@@ -0,0 +1,24 @@
+package job
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusJSONPaused(t *testing.T) {
+	for _, paused := range []bool{false, true} {
+		s := &Status{Type: TypeSnap, JobSpecific: &SnapJobStatus{}, Paused: paused}
+		buf, err := json.Marshal(s)
+		require.NoError(t, err)
+		assert.Equal(t, paused, strings.Contains(string(buf), `"paused":true`))
+
+		var d Status
+		require.NoError(t, json.Unmarshal(buf, &d))
+		assert.Equal(t, paused, d.Paused)
+		assert.Equal(t, TypeSnap, d.Type)
+	}
+}
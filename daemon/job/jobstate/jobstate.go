@@ -0,0 +1,144 @@
+// Package jobstate persists small pieces of per-job state, e.g. timestamps or baselines,
+// across daemon restarts.
+//
+// Each job has its own directory below the daemon's state directory (global.state_dir),
+// and each piece of state is a JSON file in that directory named after its key:
+//
+//	STATE_DIR/jobs/JOBNAME/KEY.json
+//
+// Writes are atomic (see package atomicfile), so a crash leaves either the previous or the new value.
+package jobstate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/util/atomicfile"
+)
+
+const jobsDirName = "jobs"
+
+// Store is the persisted state of one job. It is safe for concurrent use.
+// There must be at most one Store per job and state directory.
+type Store struct {
+	dir string
+	mtx sync.Mutex
+}
+
+// New returns the Store of jobName in stateDir.
+// The directory is created on the first Store.
+func New(stateDir, jobName string) *Store {
+	return &Store{dir: filepath.Join(stateDir, jobsDirName, escapeJobName(jobName))}
+}
+
+// RemoveStaleTempFiles removes the temporary files that a crash during Store left behind.
+// They do not affect Load, this only reclaims the space.
+func (s *Store) RemoveStaleTempFiles() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if err := atomicfile.RemoveStaleTempFiles(s.dir); err != nil {
+		return errors.Wrapf(err, "cannot clean up state directory %q", s.dir)
+	}
+	return nil
+}
+
+// escapeJobName maps job names to distinct directory names,
+// escaping characters that are not safe in a path component, including a leading dot.
+func escapeJobName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-':
+			b.WriteByte(c)
+		case c == '.' && i > 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+var keyRE = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+func (s *Store) path(key string) (string, error) {
+	if !keyRE.MatchString(key) {
+		return "", fmt.Errorf("invalid job state key %q", key)
+	}
+	return filepath.Join(s.dir, key+".json"), nil
+}
+
+// Load decodes the value stored under key into v.
+// It returns false if nothing is stored under key.
+func (s *Store) Load(key string, v interface{}) (found bool, err error) {
+	p, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	content, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return false, errors.Wrapf(err, "cannot parse %q", p)
+	}
+	return true, nil
+}
+
+// Store atomically replaces the value stored under key with the JSON encoding of v.
+func (s *Store) Store(key string, v interface{}) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return atomicfile.WriteFile(p, content, 0600)
+}
+
+// Delete removes the value stored under key. Deleting a nonexistent key is not an error.
+func (s *Store) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+type contextKey int
+
+const contextKeyStore contextKey = iota
+
+func Context(ctx context.Context, s *Store) context.Context {
+	return context.WithValue(ctx, contextKeyStore, s)
+}
+
+// FromContext returns the Store of the job that ctx belongs to,
+// or nil if ctx was not derived from a context returned by Context.
+func FromContext(ctx context.Context) *Store {
+	s, _ := ctx.Value(contextKeyStore).(*Store)
+	return s
+}
@@ -0,0 +1,136 @@
+package jobstate
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testState struct {
+	Counter int
+	Padding string
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-jobstate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := New(dir, "job")
+	var v testState
+	found, err := s.Load("counter", &v)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, s.Store("counter", testState{Counter: 1}))
+	found, err = s.Load("counter", &v)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 1, v.Counter)
+	assert.FileExists(t, filepath.Join(dir, "jobs", "job", "counter.json"))
+
+	// a new Store, e.g. after a restart, sees the persisted state
+	found, err = New(dir, "job").Load("counter", &v)
+	require.NoError(t, err)
+	assert.True(t, found)
+	found, err = New(dir, "otherjob").Load("counter", &v)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, s.Delete("counter"))
+	require.NoError(t, s.Delete("counter"))
+	found, err = s.Load("counter", &v)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	for _, key := range []string{"", "../counter", "Counter", "a.b", "a/b"} {
+		assert.Error(t, s.Store(key, v), "%q", key)
+	}
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "jobs", "job", "garbage.json"), []byte("{"), 0600))
+	_, err = s.Load("garbage", &v)
+	assert.Error(t, err)
+}
+
+func TestStoreConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-jobstate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := New(dir, "job")
+	require.NoError(t, s.Store("counter", testState{}))
+
+	// readers must always observe a complete value, also through a second Store
+	// that does not share the mutex (e.g. an external reader)
+	padding := fmt.Sprintf("%01000d", 0)
+	reader := New(dir, "job")
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				assert.NoError(t, s.Store("counter", testState{Counter: w*100 + i, Padding: padding}))
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				var v testState
+				found, err := reader.Load("counter", &v)
+				assert.NoError(t, err)
+				assert.True(t, found)
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "jobs", "job"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files are left behind")
+}
+
+func TestRemoveStaleTempFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-jobstate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := New(dir, "job")
+	require.NoError(t, s.RemoveStaleTempFiles(), "the directory does not exist yet")
+	require.NoError(t, s.Store("counter", testState{Counter: 1}))
+	stale := filepath.Join(dir, "jobs", "job", "counter.json.tmp123")
+	require.NoError(t, ioutil.WriteFile(stale, []byte("{\"Coun"), 0600))
+
+	var v testState
+	found, err := s.Load("counter", &v)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 1, v.Counter)
+
+	require.NoError(t, s.RemoveStaleTempFiles())
+	_, err = os.Stat(stale)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestEscapeJobName(t *testing.T) {
+	assert.Equal(t, "prod_to_backups-1", escapeJobName("prod_to_backups-1"))
+	assert.Equal(t, "a.b", escapeJobName("a.b"))
+	assert.Equal(t, "%2E", escapeJobName("."))
+	assert.Equal(t, "%2E.", escapeJobName(".."))
+	assert.Equal(t, "a%2Fb", escapeJobName("a/b"))
+	assert.Equal(t, "a%25b", escapeJobName("a%b"))
+	assert.Equal(t, "a%20b", escapeJobName("a b"))
+}
+
+func TestContext(t *testing.T) {
+	assert.Nil(t, FromContext(context.Background()))
+	s := New("/var/lib/zrepl", "job")
+	assert.Equal(t, s, FromContext(Context(context.Background(), s)))
+}
@@ -0,0 +1,51 @@
+package job
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/rpc"
+	"github.com/zrepl/zrepl/transport/local"
+)
+
+// localDirectTarget is registered by a passive job with the local listener it serves
+// (see local.LocalListener.SetDirect), for active jobs that connect to it with `direct` enabled.
+type localDirectTarget struct {
+	handler        rpc.Handler
+	ctxInterceptor rpc.HandlerContextInterceptor
+
+	recvFeatures     *endpoint.RecvFeaturesAdvertiser // nil unless sink
+	peerRecvFeatures *endpoint.PeerRecvFeatures       // nil unless source
+}
+
+// directClient returns nil unless c wraps a local connecter with `direct` enabled.
+func (c activeSideConnecter) directClient() *rpc.DirectClient {
+	lc, ok := c.Connecter.(*local.LocalConnecter)
+	if !ok || !lc.Direct() {
+		return nil
+	}
+	lookup := func(ctx context.Context) (rpc.Handler, rpc.HandlerContextInterceptor, error) {
+		h, err := lc.DirectHandler()
+		if err != nil {
+			return nil, nil, err
+		}
+		t, ok := h.(*localDirectTarget)
+		if !ok {
+			return nil, nil, fmt.Errorf("implementation error: unexpected direct handler type %T", h)
+		}
+		// there is no version handshake, exchange the pool features like it would
+		if c.peerRecvFeatures != nil && t.recvFeatures != nil {
+			if features, ok := t.recvFeatures.AdvertisedRecvFeatures(); ok {
+				c.peerRecvFeatures.Observe(c.clientIdentity, features)
+			}
+		}
+		if t.peerRecvFeatures != nil && c.recvFeatures != nil {
+			if features, ok := c.recvFeatures.AdvertisedRecvFeatures(); ok {
+				t.peerRecvFeatures.Observe(lc.ClientIdentity(), features)
+			}
+		}
+		return t.handler, t.ctxInterceptor, nil
+	}
+	return rpc.NewDirectClient(lc.ClientIdentity(), lookup)
+}
@@ -0,0 +1,113 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/runnow"
+)
+
+// ManualRunStatus is the status of an invocation requested through `zrepl run`.
+type ManualRunStatus struct {
+	ID uint64
+	// the phase the invocation is restricted to, empty for the full pipeline
+	Scope     runnow.Scope `json:",omitempty"`
+	StartedAt time.Time
+	// nil while the invocation is running
+	FinishedAt *time.Time `json:",omitempty"`
+	// the current phase while running, e.g. snapshot, replication, prune_sender
+	Phase string
+	// phases that were not run because of Scope, in pipeline order
+	Skipped []string         `json:",omitempty"`
+	Errors  []hooks.JobError `json:",omitempty"`
+}
+
+// manualRunTracker tracks the latest manual invocation of a job.
+// The zero value is ready to use.
+type manualRunTracker struct {
+	mtx    sync.Mutex
+	status *ManualRunStatus
+}
+
+func (t *manualRunTracker) start(req runnow.Request) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.status = &ManualRunStatus{ID: req.ID, Scope: req.Scope, StartedAt: time.Now()}
+}
+
+// setPhase is a no-op unless a manual invocation is running.
+func (t *manualRunTracker) setPhase(phase string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.status == nil || t.status.FinishedAt != nil {
+		return
+	}
+	t.status.Phase = phase
+}
+
+// skip records phases that are not run because of the invocation's scope.
+// It is a no-op unless a manual invocation is running.
+func (t *manualRunTracker) skip(phases ...string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.status == nil || t.status.FinishedAt != nil {
+		return
+	}
+	t.status.Skipped = append(t.status.Skipped, phases...)
+}
+
+func (t *manualRunTracker) finish(errs []hooks.JobError) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	now := time.Now()
+	t.status.FinishedAt = &now
+	t.status.Phase = ""
+	t.status.Errors = errs
+}
+
+// report returns a copy of the status of the latest manual invocation, or nil.
+func (t *manualRunTracker) report() *ManualRunStatus {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.status == nil {
+		return nil
+	}
+	s := *t.status
+	s.Skipped = append([]string(nil), t.status.Skipped...)
+	s.Errors = append([]hooks.JobError(nil), t.status.Errors...)
+	return &s
+}
+
+// CheckRunNowScope returns an error if j cannot run an invocation that is restricted to scope.
+func CheckRunNowScope(j Job, scope runnow.Scope) error {
+	switch j := j.(type) {
+	case *SnapJob:
+		if scope == runnow.ScopeReplicate {
+			return errors.New("snap jobs do not replicate")
+		}
+	case *ActiveSide:
+		if _, ok := j.mode.(*modePull); ok && scope == runnow.ScopeSnapshot {
+			return errors.New("pull jobs do not take snapshots")
+		}
+	}
+	return nil
+}
+
+func cancelledError(ctx context.Context) hooks.JobError {
+	return hooks.JobError{Phase: "invocation", Err: "cancelled: " + ctx.Err().Error()}
+}
+
+// ManualRun returns the status of the latest manual invocation of the job, or nil.
+func (s *Status) ManualRun() *ManualRunStatus {
+	switch st := s.JobSpecific.(type) {
+	case *ActiveSideStatus:
+		return st.ManualRun
+	case *SnapJobStatus:
+		return st.ManualRun
+	default:
+		return nil
+	}
+}
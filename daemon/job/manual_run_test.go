@@ -0,0 +1,68 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/runnow"
+)
+
+func TestManualRunTracker(t *testing.T) {
+	var tr manualRunTracker
+	assert.Nil(t, tr.report())
+
+	tr.setPhase("replication") // no-op, nothing running
+	assert.Nil(t, tr.report())
+
+	tr.start(runnow.Request{ID: 1, Scope: runnow.ScopePrune})
+	tr.skip("snapshot")
+	tr.skip("replication")
+	tr.setPhase("prune_sender")
+	r := tr.report()
+	require.NotNil(t, r)
+	assert.Equal(t, uint64(1), r.ID)
+	assert.Equal(t, runnow.ScopePrune, r.Scope)
+	assert.Equal(t, "prune_sender", r.Phase)
+	assert.Equal(t, []string{"snapshot", "replication"}, r.Skipped)
+	assert.Nil(t, r.FinishedAt)
+
+	errs := []hooks.JobError{{Phase: "replication", Filesystem: "pool/a", Err: "failed"}}
+	tr.finish(errs)
+	tr.setPhase("prune_receiver") // no-op, already finished
+	tr.skip("prune_receiver")     // no-op, already finished
+	r = tr.report()
+	require.NotNil(t, r)
+	assert.NotNil(t, r.FinishedAt)
+	assert.Equal(t, "", r.Phase)
+	assert.Equal(t, errs, r.Errors)
+	assert.Equal(t, []string{"snapshot", "replication"}, r.Skipped)
+
+	// the report is a copy
+	r.Errors[0].Err = "modified"
+	assert.Equal(t, "failed", tr.report().Errors[0].Err)
+}
+
+func TestStatusManualRun(t *testing.T) {
+	mr := &ManualRunStatus{ID: 3}
+	assert.Equal(t, mr, (&Status{Type: TypePush, JobSpecific: &ActiveSideStatus{ManualRun: mr}}).ManualRun())
+	assert.Equal(t, mr, (&Status{Type: TypeSnap, JobSpecific: &SnapJobStatus{ManualRun: mr}}).ManualRun())
+	assert.Nil(t, (&Status{Type: TypeSink, JobSpecific: &PassiveStatus{}}).ManualRun())
+}
+
+func TestCheckRunNowScope(t *testing.T) {
+	scopes := []runnow.Scope{runnow.ScopeAll, runnow.ScopeSnapshot, runnow.ScopeReplicate, runnow.ScopePrune}
+	check := func(j Job) (invalid []runnow.Scope) {
+		for _, s := range scopes {
+			if CheckRunNowScope(j, s) != nil {
+				invalid = append(invalid, s)
+			}
+		}
+		return invalid
+	}
+	assert.Equal(t, []runnow.Scope{runnow.ScopeReplicate}, check(&SnapJob{}))
+	assert.Nil(t, check(&ActiveSide{mode: &modePush{}}))
+	assert.Equal(t, []runnow.Scope{runnow.ScopeSnapshot}, check(&ActiveSide{mode: &modePull{}}))
+}
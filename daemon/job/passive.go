@@ -16,13 +16,17 @@ import (
 	"github.com/zrepl/zrepl/rpc"
 	"github.com/zrepl/zrepl/transport"
 	"github.com/zrepl/zrepl/transport/fromconfig"
+	"github.com/zrepl/zrepl/transport/local"
 	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
 type PassiveSide struct {
 	mode   passiveMode
 	name   endpoint.JobID
 	listen transport.AuthenticatedListenerFactory
+
+	zfsPriority *zfscmd.Priority // nil means unchanged
 }
 
 type passiveMode interface {
@@ -69,8 +73,9 @@ func modeSourceFromConfig(g *config.Global, in *config.SourceJob, jobID endpoint
 	if err != nil {
 		return nil, errors.Wrap(err, "send options")
 	}
+	m.senderConfig.PeerRecvFeatures = endpoint.NewPeerRecvFeatures()
 
-	if m.snapper, err = snapper.FromConfig(g, m.senderConfig.FSF, in.Snapshotting); err != nil {
+	if m.snapper, err = snapper.FromConfig(g, jobID.String(), m.senderConfig.FSF, in.Snapshotting, minFreeSpaceFromConfig(in.MinFreeSpace)); err != nil {
 		return nil, errors.Wrap(err, "cannot build snapper")
 	}
 
@@ -100,6 +105,11 @@ func passiveSideFromConfig(g *config.Global, in *config.PassiveJob, configJob in
 		return nil, errors.Wrap(err, "invalid job name")
 	}
 
+	s.zfsPriority, err = zfsPriorityFromConfig(g, in.ZFSPriority)
+	if err != nil {
+		return nil, err
+	}
+
 	switch v := configJob.(type) {
 	case *config.SinkJob:
 		s.mode, err = modeSinkFromConfig(g, v, s.name) // shadow
@@ -153,6 +163,7 @@ func (*PassiveSide) RegisterMetrics(registerer prometheus.Registerer) {}
 func (j *PassiveSide) Run(ctx context.Context) {
 	ctx, endTask := trace.WithTaskAndSpan(ctx, "passive-side-job", j.Name())
 	defer endTask()
+	ctx = zfscmd.WithPriority(ctx, j.zfsPriority)
 	log := GetLogger(ctx)
 	defer log.Info("job exiting")
 	{
@@ -172,6 +183,7 @@ func (j *PassiveSide) Run(ctx context.Context) {
 		// the handlerCtx is clean => need to inherit logging and tracing config from job context
 		handlerCtx = logging.WithInherit(handlerCtx, ctx)
 		handlerCtx = trace.WithInherit(handlerCtx, ctx)
+		handlerCtx = zfscmd.WithInherit(handlerCtx, ctx)
 
 		handlerCtx, endTask := trace.WithTaskAndSpan(handlerCtx, "handler", fmt.Sprintf("job=%q client=%q method=%q", j.Name(), info.ClientIdentity(), info.FullMethod()))
 		defer endTask()
@@ -187,5 +199,23 @@ func (j *PassiveSide) Run(ctx context.Context) {
 		return
 	}
 
-	server.Serve(ctx, listener)
+	pl := passiveSideListener{AuthenticatedListener: listener}
+	switch m := j.mode.(type) {
+	case *modeSink:
+		pl.recvFeatures = endpoint.NewRecvFeaturesAdvertiser(m.receiverConfig.RootWithoutClientComponent)
+	case *modeSource:
+		pl.peerRecvFeatures = m.senderConfig.PeerRecvFeatures
+	}
+
+	if ll, ok := listener.(*local.LocalListener); ok {
+		unset := ll.SetDirect(&localDirectTarget{
+			handler:          handler,
+			ctxInterceptor:   ctxInterceptor,
+			recvFeatures:     pl.recvFeatures,
+			peerRecvFeatures: pl.peerRecvFeatures,
+		})
+		defer unset()
+	}
+
+	server.Serve(ctx, pl)
 }
@@ -0,0 +1,42 @@
+package job
+
+import (
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+	"github.com/zrepl/zrepl/transport"
+)
+
+// passiveSideListener hooks a passive job into the version handshake of its connections:
+// it advertises the receiving pool's features if the job receives (sink),
+// and records those of each client if the job sends (source).
+type passiveSideListener struct {
+	transport.AuthenticatedListener
+
+	recvFeatures     *endpoint.RecvFeaturesAdvertiser // nil unless sink
+	peerRecvFeatures *endpoint.PeerRecvFeatures       // nil unless source
+}
+
+var _ versionhandshake.RecvFeaturesAdvertiser = passiveSideListener{}
+var _ versionhandshake.RecvFeaturesObserver = passiveSideListener{}
+var _ transport.CompressionRequester = passiveSideListener{}
+
+func (l passiveSideListener) AdvertisedRecvFeatures() ([]string, bool) {
+	if l.recvFeatures == nil {
+		return nil, false
+	}
+	return l.recvFeatures.AdvertisedRecvFeatures()
+}
+
+func (l passiveSideListener) ObservePeerRecvFeatures(clientIdentity string, features []string) {
+	if l.peerRecvFeatures != nil {
+		l.peerRecvFeatures.Observe(clientIdentity, features)
+	}
+}
+
+// RequestedCompression forwards to the wrapped listener, the handshake negotiates compression based on it.
+func (l passiveSideListener) RequestedCompression() transport.Compression {
+	if cr, ok := l.AuthenticatedListener.(transport.CompressionRequester); ok {
+		return cr.RequestedCompression()
+	}
+	return transport.CompressionNone
+}
@@ -0,0 +1,66 @@
+package pause
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/zrepl/zrepl/daemon/job/jobstate"
+)
+
+type contextKey int
+
+const contextKeyPause contextKey = iota
+
+// stateKey is the jobstate key under which a paused job is recorded.
+const stateKey = "paused"
+
+// IsPaused returns whether the job that ctx belongs to is paused.
+// Returns false if ctx was not derived from a context returned by Context.
+func IsPaused(ctx context.Context) bool {
+	p, ok := ctx.Value(contextKeyPause).(*int32)
+	if !ok {
+		return false
+	}
+	return atomic.LoadInt32(p) != 0
+}
+
+// Func pauses (paused == true) or resumes (paused == false) the job.
+// The pause state is only changed if it could be persisted.
+type Func func(paused bool) error
+
+// Context returns a context whose job is paused or resumed by the returned Func.
+//
+// If ctx carries a jobstate.Store (see jobstate.Context), the pause state is persisted in it,
+// so that a paused job stays paused across daemon restarts.
+// If the persisted state cannot be loaded, the job starts paused and the error is returned.
+func Context(ctx context.Context) (context.Context, Func, error) {
+	store := jobstate.FromContext(ctx)
+	var p int32
+	var loadErr error
+	if store != nil {
+		var paused bool
+		if _, loadErr = store.Load(stateKey, &paused); loadErr != nil || paused {
+			p = 1
+		}
+	}
+	f := func(paused bool) error {
+		if store != nil {
+			var err error
+			if paused {
+				err = store.Store(stateKey, true)
+			} else {
+				err = store.Delete(stateKey)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		var v int32
+		if paused {
+			v = 1
+		}
+		atomic.StoreInt32(&p, v)
+		return nil
+	}
+	return context.WithValue(ctx, contextKeyPause, &p), f, loadErr
+}
@@ -0,0 +1,57 @@
+package pause
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/job/jobstate"
+)
+
+func TestPause(t *testing.T) {
+	assert.False(t, IsPaused(context.Background()))
+
+	ctx, f, err := Context(context.Background())
+	require.NoError(t, err)
+	assert.False(t, IsPaused(ctx))
+	require.NoError(t, f(true))
+	assert.True(t, IsPaused(ctx))
+	require.NoError(t, f(false))
+	assert.False(t, IsPaused(ctx))
+}
+
+func TestPausePersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-pause")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	jobCtx := func() context.Context {
+		return jobstate.Context(context.Background(), jobstate.New(dir, "job"))
+	}
+
+	ctx, f, err := Context(jobCtx())
+	require.NoError(t, err)
+	assert.False(t, IsPaused(ctx))
+	require.NoError(t, f(true))
+
+	// e.g. after a daemon restart
+	ctx, f, err = Context(jobCtx())
+	require.NoError(t, err)
+	assert.True(t, IsPaused(ctx))
+	require.NoError(t, f(false))
+	assert.NoFileExists(t, filepath.Join(dir, "jobs", "job", stateKey+".json"))
+
+	ctx, _, err = Context(jobCtx())
+	require.NoError(t, err)
+	assert.False(t, IsPaused(ctx))
+
+	// a job whose state cannot be loaded must not run unattended
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "jobs", "job", stateKey+".json"), []byte("garbage"), 0600))
+	ctx, _, err = Context(jobCtx())
+	assert.Error(t, err)
+	assert.True(t, IsPaused(ctx))
+}
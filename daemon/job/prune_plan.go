@@ -0,0 +1,30 @@
+package job
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/endpoint"
+)
+
+// LocalPruner returns the pruner that j uses for the snapshots on the local side.
+// It is intended for inspecting the pruning decisions through pruner.Pruner.Plan
+// without running the job.
+//
+// Only push jobs (sender side) and snap jobs prune local snapshots
+// based on local information.
+func LocalPruner(ctx context.Context, j Job) (*pruner.Pruner, error) {
+	switch j := j.(type) {
+	case *ActiveSide:
+		m, ok := j.mode.(*modePush)
+		if !ok {
+			break
+		}
+		sender := endpoint.NewSender(*m.senderConfig)
+		return j.prunerFactory.BuildSenderPruner(ctx, sender, sender), nil
+	case *SnapJob:
+		return j.buildPruner(ctx), nil
+	}
+	return nil, fmt.Errorf("job %q: only push and snap jobs prune local snapshots based on local information", j.Name())
+}
@@ -0,0 +1,64 @@
+package job
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/replication/logic"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+// ReplicationPlanFilesystem is the outcome of planning the replication of one filesystem.
+type ReplicationPlanFilesystem struct {
+	Filesystem string
+	// Non-empty if planning failed for this filesystem, Steps is empty then.
+	Error string `json:",omitempty"`
+	// The steps in the order in which they would be executed.
+	// Empty if the filesystem is up to date.
+	Steps []*report.StepInfo
+}
+
+// PlanReplication connects to the peer of push or pull job j and computes
+// the replication plan that an invocation of j would execute, without replicating.
+// It is intended for inspecting the planner's decisions, e.g. incremental bases
+// and size estimates, without running the job.
+//
+// Planning only reads state on both sides; size estimates are computed through dry-run sends.
+func PlanReplication(ctx context.Context, j Job) ([]ReplicationPlanFilesystem, error) {
+	a, ok := j.(*ActiveSide)
+	if !ok {
+		return nil, fmt.Errorf("job %q: only push and pull jobs replicate", j.Name())
+	}
+
+	a.mode.ConnectEndpoints(ctx, a.connecter)
+	defer a.mode.DisconnectEndpoints()
+	sender, receiver := a.mode.SenderReceiver()
+
+	planner := logic.NewPlanner(nil, nil, sender, receiver, a.mode.PlannerPolicy())
+	if err := planner.WaitForConnectivity(ctx); err != nil {
+		return nil, err
+	}
+	fss, err := planner.Plan(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list filesystems")
+	}
+
+	out := make([]ReplicationPlanFilesystem, 0, len(fss))
+	for _, fs := range fss {
+		p := ReplicationPlanFilesystem{
+			Filesystem: fs.ReportInfo().Name,
+			Steps:      []*report.StepInfo{},
+		}
+		steps, err := fs.PlanFS(ctx)
+		if err != nil {
+			p.Error = err.Error()
+		}
+		for _, s := range steps {
+			p.Steps = append(p.Steps, s.ReportInfo())
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
@@ -0,0 +1,182 @@
+package job
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+// replicationWindow restricts the time ranges during which an active job may replicate.
+type replicationWindow struct {
+	ranges       []replicationWindowRange
+	pauseOnClose bool
+}
+
+type replicationWindowRange struct {
+	start, end time.Duration         // offset from midnight, end <= start means that the range extends into the next day
+	weekdays   map[time.Weekday]bool // weekday on which the range starts, nil means every day
+}
+
+var replicationWindowWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func parseReplicationWindowTimeOfDay(in string) (time.Duration, error) {
+	t, err := time.Parse("15:04", in)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expecting HH:MM", in)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// replicationWindowFromConfig returns nil if in is nil, i.e., replication is always allowed.
+func replicationWindowFromConfig(in *config.ReplicationWindow) (*replicationWindow, error) {
+	if in == nil {
+		return nil, nil
+	}
+	w := &replicationWindow{}
+
+	switch in.OnClose {
+	case "continue":
+		w.pauseOnClose = false
+	case "pause":
+		w.pauseOnClose = true
+	default:
+		return nil, fmt.Errorf("invalid value for on_close: %q, must be one of continue, pause", in.OnClose)
+	}
+
+	if len(in.Ranges) == 0 {
+		return nil, errors.New("ranges must not be empty")
+	}
+	for i, r := range in.Ranges {
+		var wr replicationWindowRange
+		var err error
+		if wr.start, err = parseReplicationWindowTimeOfDay(r.Start); err != nil {
+			return nil, errors.Wrapf(err, "range #%d: start", i+1)
+		}
+		if wr.end, err = parseReplicationWindowTimeOfDay(r.End); err != nil {
+			return nil, errors.Wrapf(err, "range #%d: end", i+1)
+		}
+		if len(r.Weekdays) > 0 {
+			wr.weekdays = make(map[time.Weekday]bool, len(r.Weekdays))
+			for _, d := range r.Weekdays {
+				wd, ok := replicationWindowWeekdays[strings.ToLower(d)]
+				if !ok {
+					return nil, fmt.Errorf("range #%d: invalid weekday %q, must be one of sun, mon, tue, wed, thu, fri, sat", i+1, d)
+				}
+				wr.weekdays[wd] = true
+			}
+		}
+		w.ranges = append(w.ranges, wr)
+	}
+	return w, nil
+}
+
+// occurrence returns the instance of r that starts on the day of t.
+// ok is false if r does not apply to the day of t.
+func (r replicationWindowRange) occurrence(t time.Time) (start, end time.Time, ok bool) {
+	if r.weekdays != nil && !r.weekdays[t.Weekday()] {
+		return time.Time{}, time.Time{}, false
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	start = midnight.Add(r.start)
+	end = midnight.Add(r.end)
+	if r.end <= r.start {
+		end = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()).Add(r.end)
+	}
+	return start, end, true
+}
+
+// containingRangeEnd returns the latest end of all range occurrences that contain t.
+func (w *replicationWindow) containingRangeEnd(t time.Time) (end time.Time, ok bool) {
+	for _, r := range w.ranges {
+		for _, day := range []time.Time{t.AddDate(0, 0, -1), t} {
+			s, e, applies := r.occurrence(day)
+			if applies && !t.Before(s) && t.Before(e) && e.After(end) {
+				end, ok = e, true
+			}
+		}
+	}
+	return end, ok
+}
+
+func (w *replicationWindow) Contains(t time.Time) bool {
+	_, ok := w.containingRangeEnd(t)
+	return ok
+}
+
+// NextOpen returns t if t is within the window, otherwise the next time at which the window opens.
+func (w *replicationWindow) NextOpen(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	var next time.Time
+	for _, r := range w.ranges {
+		for d := 0; d <= 7; d++ {
+			s, _, applies := r.occurrence(t.AddDate(0, 0, d))
+			if applies && s.After(t) && (next.IsZero() || s.Before(next)) {
+				next = s
+			}
+		}
+	}
+	return next
+}
+
+// NextClose returns the time at which the window that contains t closes, taking adjacent and overlapping ranges into account.
+// It returns the zero time if t is not within the window or the window never closes.
+func (w *replicationWindow) NextClose(t time.Time) time.Time {
+	end, ok := w.containingRangeEnd(t)
+	if !ok {
+		return time.Time{}
+	}
+	for i := 0; i < 8*len(w.ranges); i++ {
+		next, ok := w.containingRangeEnd(end)
+		if !ok {
+			return end
+		}
+		end = next
+	}
+	return time.Time{} // ranges cover the entire week
+}
+
+// ReplicationWindowOpening is a time range during which a replication window is open.
+// Close is the zero time if the window never closes.
+type ReplicationWindowOpening struct {
+	Open, Close time.Time
+}
+
+// NextReplicationWindowOpenings returns up to n openings of the replication window in,
+// starting with the one that contains t, if any.
+func NextReplicationWindowOpenings(in *config.ReplicationWindow, t time.Time, n int) ([]ReplicationWindowOpening, error) {
+	w, err := replicationWindowFromConfig(in)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, nil
+	}
+	var openings []ReplicationWindowOpening
+	for len(openings) < n {
+		open := w.NextOpen(t)
+		if open.IsZero() {
+			break
+		}
+		o := ReplicationWindowOpening{Open: open, Close: w.NextClose(open)}
+		openings = append(openings, o)
+		if o.Close.IsZero() {
+			break
+		}
+		t = o.Close
+	}
+	return openings, nil
+}
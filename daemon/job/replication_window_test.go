@@ -0,0 +1,114 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestReplicationWindow(t *testing.T) {
+	w, err := replicationWindowFromConfig(&config.ReplicationWindow{
+		OnClose: "pause",
+		Ranges: []config.ReplicationWindowRange{
+			{Start: "22:00", End: "06:00"},
+			{Start: "12:00", End: "13:00", Weekdays: []string{"sat", "sun"}},
+		},
+	})
+	require.NoError(t, err)
+	assert.True(t, w.pauseOnClose)
+
+	at := func(day int, hhmm string) time.Time {
+		tod, err := parseReplicationWindowTimeOfDay(hhmm)
+		require.NoError(t, err)
+		// 2021-01-04 is a Monday
+		return time.Date(2021, 1, 4+day, 0, 0, 0, 0, time.UTC).Add(tod)
+	}
+	const mon, fri, sat = 0, 4, 5
+
+	assert.True(t, w.Contains(at(mon, "23:00")))
+	assert.True(t, w.Contains(at(mon, "05:59")))
+	assert.False(t, w.Contains(at(mon, "06:00")))
+	assert.False(t, w.Contains(at(mon, "12:30")))
+	assert.True(t, w.Contains(at(sat, "12:30")))
+
+	assert.Equal(t, at(mon, "23:00"), w.NextOpen(at(mon, "23:00")))
+	assert.Equal(t, at(mon, "22:00"), w.NextOpen(at(mon, "08:00")))
+	assert.Equal(t, at(sat, "12:00"), w.NextOpen(at(sat, "08:00")))
+	assert.Equal(t, at(fri, "22:00"), w.NextOpen(at(fri, "13:00")))
+
+	assert.Equal(t, at(mon+1, "06:00"), w.NextClose(at(mon, "23:00")))
+	assert.Equal(t, at(sat, "13:00"), w.NextClose(at(sat, "12:30")))
+	assert.True(t, w.NextClose(at(mon, "12:00")).IsZero())
+}
+
+func TestReplicationWindowAdjacentRanges(t *testing.T) {
+	w, err := replicationWindowFromConfig(&config.ReplicationWindow{
+		OnClose: "continue",
+		Ranges: []config.ReplicationWindowRange{
+			{Start: "20:00", End: "00:00"},
+			{Start: "00:00", End: "04:00"},
+		},
+	})
+	require.NoError(t, err)
+	now := time.Date(2021, 1, 4, 21, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2021, 1, 5, 4, 0, 0, 0, time.UTC), w.NextClose(now))
+
+	always, err := replicationWindowFromConfig(&config.ReplicationWindow{
+		OnClose: "pause",
+		Ranges:  []config.ReplicationWindowRange{{Start: "00:00", End: "00:00"}},
+	})
+	require.NoError(t, err)
+	assert.True(t, always.Contains(now))
+	assert.True(t, always.NextClose(now).IsZero())
+}
+
+func TestReplicationWindowFromConfigErrors(t *testing.T) {
+	for _, c := range []config.ReplicationWindow{
+		{OnClose: "continue"},
+		{OnClose: "stop", Ranges: []config.ReplicationWindowRange{{Start: "01:00", End: "02:00"}}},
+		{OnClose: "continue", Ranges: []config.ReplicationWindowRange{{Start: "25:00", End: "02:00"}}},
+		{OnClose: "continue", Ranges: []config.ReplicationWindowRange{{Start: "01:00", End: "02:00", Weekdays: []string{"monday"}}}},
+	} {
+		c := c
+		_, err := replicationWindowFromConfig(&c)
+		assert.Error(t, err, "%#v", c)
+	}
+	w, err := replicationWindowFromConfig(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, w)
+}
+
+func TestNextReplicationWindowOpenings(t *testing.T) {
+	in := &config.ReplicationWindow{
+		OnClose: "continue",
+		Ranges: []config.ReplicationWindowRange{
+			{Start: "22:00", End: "02:00"},
+			{Start: "02:00", End: "04:00", Weekdays: []string{"tue"}},
+		},
+	}
+	// 2021-01-04 is a Monday
+	day := func(d, h int) time.Time { return time.Date(2021, 1, 4+d, h, 0, 0, 0, time.UTC) }
+
+	openings, err := NextReplicationWindowOpenings(in, day(0, 23), 3)
+	require.NoError(t, err)
+	assert.Equal(t, []ReplicationWindowOpening{
+		{day(0, 23), day(1, 4)}, // contains t, extended by the adjacent Tuesday range
+		{day(1, 22), day(2, 2)},
+		{day(2, 22), day(3, 2)},
+	}, openings)
+
+	openings, err = NextReplicationWindowOpenings(&config.ReplicationWindow{
+		OnClose: "continue",
+		Ranges:  []config.ReplicationWindowRange{{Start: "00:00", End: "00:00"}},
+	}, day(0, 12), 5)
+	require.NoError(t, err)
+	assert.Equal(t, []ReplicationWindowOpening{{Open: day(0, 12)}}, openings)
+
+	openings, err = NextReplicationWindowOpenings(nil, day(0, 12), 5)
+	require.NoError(t, err)
+	assert.Nil(t, openings)
+}
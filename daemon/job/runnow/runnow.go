@@ -0,0 +1,74 @@
+// Package runnow implements out-of-band invocations of a job's full pipeline,
+// e.g. snapshot, replicate and prune for a push job.
+package runnow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+type contextKey int
+
+const contextKeyRunNow contextKey = iota
+
+// Scope restricts an invocation to a single phase of the job's pipeline.
+type Scope string
+
+const (
+	ScopeAll       Scope = ""
+	ScopeSnapshot  Scope = "snapshot"
+	ScopeReplicate Scope = "replicate"
+	ScopePrune     Scope = "prune"
+)
+
+func ParseScope(s string) (Scope, error) {
+	switch sc := Scope(s); sc {
+	case ScopeAll, ScopeSnapshot, ScopeReplicate, ScopePrune:
+		return sc, nil
+	default:
+		return ScopeAll, fmt.Errorf("invalid scope %q, must be one of snapshot, replicate, prune", s)
+	}
+}
+
+// Includes returns true if phase, which must not be ScopeAll, is part of an invocation with scope s.
+func (s Scope) Includes(phase Scope) bool {
+	return s == ScopeAll || s == phase
+}
+
+// Request is a requested invocation.
+type Request struct {
+	ID    uint64
+	Scope Scope
+}
+
+// Wait returns a channel that receives each requested invocation.
+func Wait(ctx context.Context) <-chan Request {
+	wc, ok := ctx.Value(contextKeyRunNow).(chan Request)
+	if !ok {
+		wc = make(chan Request)
+	}
+	return wc
+}
+
+// Func requests an invocation and returns its ID.
+// The request fails with Busy unless the job is idle.
+type Func func(scope Scope) (id uint64, err error)
+
+var Busy = errors.New("job is busy, try again when the current invocation has finished")
+
+func Context(ctx context.Context) (context.Context, Func) {
+	wc := make(chan Request)
+	var lastID uint64
+	f := func(scope Scope) (uint64, error) {
+		id := atomic.AddUint64(&lastID, 1)
+		select {
+		case wc <- Request{ID: id, Scope: scope}:
+			return id, nil
+		default:
+			return 0, Busy
+		}
+	}
+	return context.WithValue(ctx, contextKeyRunNow, wc), f
+}
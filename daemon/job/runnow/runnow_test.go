@@ -0,0 +1,44 @@
+package runnow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunNow(t *testing.T) {
+	ctx, f := Context(context.Background())
+
+	// nobody is waiting => busy
+	_, err := f(ScopeAll)
+	assert.Equal(t, Busy, err)
+
+	received := make(chan Request)
+	go func() { received <- <-Wait(ctx) }()
+	var id uint64
+	for {
+		id, err = f(ScopePrune)
+		if err == nil {
+			break
+		}
+		require.Equal(t, Busy, err)
+	}
+	assert.Equal(t, Request{ID: id, Scope: ScopePrune}, <-received)
+	assert.NotZero(t, id)
+}
+
+func TestScope(t *testing.T) {
+	for _, s := range []string{"", "snapshot", "replicate", "prune"} {
+		sc, err := ParseScope(s)
+		assert.NoError(t, err)
+		assert.Equal(t, Scope(s), sc)
+	}
+	_, err := ParseScope("replication")
+	assert.Error(t, err)
+
+	assert.True(t, ScopeAll.Includes(ScopePrune))
+	assert.True(t, ScopePrune.Includes(ScopePrune))
+	assert.False(t, ScopePrune.Includes(ScopeSnapshot))
+}
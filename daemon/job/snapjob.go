@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -14,12 +15,16 @@ import (
 
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/pause"
+	"github.com/zrepl/zrepl/daemon/job/runnow"
 	"github.com/zrepl/zrepl/daemon/job/wakeup"
 	"github.com/zrepl/zrepl/daemon/pruner"
 	"github.com/zrepl/zrepl/daemon/snapper"
 	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/replication/logic/pdu"
 	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
 type SnapJob struct {
@@ -31,25 +36,38 @@ type SnapJob struct {
 
 	promPruneSecs *prometheus.HistogramVec // labels: prune_side
 
-	prunerMtx sync.Mutex
-	pruner    *pruner.Pruner
+	concurrentJobs *concurrentJobsLimiter
+	dependencies   *jobDependencies
+
+	zfsPriority *zfscmd.Priority // nil means unchanged
+
+	prunerMtx   sync.Mutex
+	pruner      *pruner.Pruner
+	queuedSince time.Time // non-zero while waiting for other jobs, see concurrentJobsLimiter
+
+	manualRun manualRunTracker
 }
 
 func (j *SnapJob) Name() string { return j.name.String() }
 
 func (j *SnapJob) Type() Type { return TypeSnap }
 
-func snapJobFromConfig(g *config.Global, in *config.SnapJob) (j *SnapJob, err error) {
-	j = &SnapJob{}
+func snapJobFromConfig(g *config.Global, in *config.SnapJob, concurrentJobs *concurrentJobsLimiter, dependencies *jobDependencies, metricLabels *MetricLabels) (j *SnapJob, err error) {
+	j = &SnapJob{concurrentJobs: concurrentJobs, dependencies: dependencies}
 	fsf, err := filters.DatasetMapFilterFromConfig(in.Filesystems)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build filesystem filter")
 	}
 	j.fsfilter = fsf
 
-	if j.snapper, err = snapper.FromConfig(g, fsf, in.Snapshotting); err != nil {
+	if j.snapper, err = snapper.FromConfig(g, in.Name, fsf, in.Snapshotting, minFreeSpaceFromConfig(in.MinFreeSpace)); err != nil {
 		return nil, errors.Wrap(err, "cannot build snapper")
 	}
+	j.snapper.Limit(concurrentJobs.snapperLimiter())
+	j.zfsPriority, err = zfsPriorityFromConfig(g, in.ZFSPriority)
+	if err != nil {
+		return nil, err
+	}
 	j.name, err = endpoint.MakeJobID(in.Name)
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid job name")
@@ -59,7 +77,7 @@ func snapJobFromConfig(g *config.Global, in *config.SnapJob) (j *SnapJob, err er
 		Subsystem:   "pruning",
 		Name:        "time",
 		Help:        "seconds spent in pruner",
-		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
+		ConstLabels: metricLabels.ConstLabels(j.name.String()),
 	}, []string{"prune_side"})
 	j.prunerFactory, err = pruner.NewLocalPrunerFactory(in.Pruning, j.promPruneSecs)
 	if err != nil {
@@ -75,6 +93,10 @@ func (j *SnapJob) RegisterMetrics(registerer prometheus.Registerer) {
 type SnapJobStatus struct {
 	Pruning      *pruner.Report
 	Snapshotting *snapper.Report // may be nil
+	// non-nil while pruning waits for other jobs because global.max_concurrent_jobs is reached
+	QueuedSince *time.Time `json:",omitempty"`
+	// the latest invocation requested through `zrepl run`, nil if there was none
+	ManualRun *ManualRunStatus `json:",omitempty"`
 }
 
 func (j *SnapJob) Status() *Status {
@@ -84,8 +106,13 @@ func (j *SnapJob) Status() *Status {
 	if j.pruner != nil {
 		s.Pruning = j.pruner.Report()
 	}
+	if !j.queuedSince.IsZero() {
+		queuedSince := j.queuedSince
+		s.QueuedSince = &queuedSince
+	}
 	j.prunerMtx.Unlock()
 	s.Snapshotting = j.snapper.Report()
+	s.ManualRun = j.manualRun.report()
 	return &Status{Type: t, JobSpecific: s}
 }
 
@@ -98,6 +125,7 @@ func (j *SnapJob) SenderConfig() *endpoint.SenderConfig { return nil }
 func (j *SnapJob) Run(ctx context.Context) {
 	ctx, endTask := trace.WithTaskAndSpan(ctx, "snap-job", j.Name())
 	defer endTask()
+	ctx = zfscmd.WithPriority(ctx, j.zfsPriority)
 	log := GetLogger(ctx)
 
 	defer log.Info("job exiting")
@@ -120,6 +148,16 @@ outer:
 
 		case <-wakeup.Wait(ctx):
 		case <-periodicDone:
+		case req := <-runnow.Wait(ctx):
+			invocationCount++
+			invocationCtx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("invocation-%d", invocationCount))
+			j.runNow(invocationCtx, req)
+			endSpan()
+			continue
+		}
+		if pause.IsPaused(ctx) {
+			log.Info("job is paused, skipping invocation")
+			continue
 		}
 		invocationCount++
 
@@ -172,20 +210,76 @@ func (h alwaysUpToDateReplicationCursorHistory) ListFilesystems(ctx context.Cont
 	return h.target.ListFilesystems(ctx, req)
 }
 
-func (j *SnapJob) doPrune(ctx context.Context) {
-	ctx, endSpan := trace.WithSpan(ctx, "snap-job-do-prune")
-	defer endSpan()
-	log := GetLogger(ctx)
+var _ pruner.BookmarkTarget = (*endpoint.Sender)(nil)
+
+func (j *SnapJob) buildPruner(ctx context.Context) *pruner.Pruner {
 	sender := endpoint.NewSender(endpoint.SenderConfig{
 		JobID: j.name,
 		FSF:   j.fsfilter,
 		// FIXME encryption setting is irrelevant for SnapJob because the endpoint is only used as pruner.Target
-		Encrypt: &nodefault.Bool{B: true},
+		SendOptions: endpoint.SendOptions{Encrypt: &nodefault.Bool{B: true}},
+	})
+	return j.prunerFactory.BuildLocalPruner(ctx, sender, alwaysUpToDateReplicationCursorHistory{sender})
+}
+
+// runNow runs the invocation that was requested through `zrepl run`:
+// snapshots are taken out of band before pruning, unless the request's scope excludes either phase.
+func (j *SnapJob) runNow(ctx context.Context, req runnow.Request) {
+	log := GetLogger(ctx).WithField("manual_run", req.ID).WithField("scope", req.Scope)
+	log.Info("start manual invocation")
+	j.manualRun.start(req)
+	var errs []hooks.JobError
+	if req.Scope.Includes(runnow.ScopeSnapshot) {
+		j.manualRun.setPhase("snapshot")
+		if err := j.snapper.SnapshotNow(ctx); err != nil {
+			GetLogger(ctx).WithError(err).Error("snapshotting failed, continuing with pruning")
+			errs = append(errs, hooks.JobError{Phase: "snapshot", Err: err.Error()})
+		}
+	} else {
+		j.manualRun.skip("snapshot")
+	}
+	if req.Scope.Includes(runnow.ScopePrune) {
+		j.manualRun.setPhase("prune")
+		errs = append(errs, j.doPrune(ctx)...)
+	} else {
+		j.manualRun.skip("prune")
+	}
+	j.manualRun.finish(errs)
+	log.WithField("error_count", len(errs)).Info("finished manual invocation")
+}
+
+// doPrune returns the pruning errors.
+func (j *SnapJob) doPrune(ctx context.Context) (errs []hooks.JobError) {
+	ctx, endSpan := trace.WithSpan(ctx, "snap-job-do-prune")
+	defer endSpan()
+	log := GetLogger(ctx)
+	endInvocation := j.dependencies.begin()
+	defer func() { endInvocation(errs) }()
+	if errs := waitForDependencies(ctx, j.dependencies, func(dep string) {
+		log.WithField("depends_on", dep).Info("waiting for the running invocation of a job this job depends on")
+	}); len(errs) > 0 {
+		log.WithField("reason", errs[0].Err).Error("skipping pruning")
+		return errs
+	}
+	guard, err := j.concurrentJobs.acquire(ctx, func() {
+		log.Info("max_concurrent_jobs reached, waiting for other jobs before pruning")
+		j.prunerMtx.Lock()
+		j.queuedSince = time.Now()
+		j.prunerMtx.Unlock()
 	})
 	j.prunerMtx.Lock()
-	j.pruner = j.prunerFactory.BuildLocalPruner(ctx, sender, alwaysUpToDateReplicationCursorHistory{sender})
+	j.queuedSince = time.Time{}
+	j.prunerMtx.Unlock()
+	if err != nil {
+		log.WithError(err).Info("cancelled while waiting for other jobs")
+		return []hooks.JobError{cancelledError(ctx)}
+	}
+	defer guard.Release()
+	j.prunerMtx.Lock()
+	j.pruner = j.buildPruner(ctx)
 	j.prunerMtx.Unlock()
 	log.Info("start pruning")
 	j.pruner.Prune()
 	log.Info("finished pruning")
+	return prunerErrors("prune", j.pruner.Report())
 }
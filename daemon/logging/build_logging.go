@@ -16,7 +16,9 @@ import (
 	"github.com/zrepl/zrepl/tlsconf"
 )
 
-func OutletsFromConfig(in config.LoggingOutletEnumList) (*logger.Outlets, error) {
+// OutletsFromConfig builds the outlets in in.
+// If dedup is enabled, each outlet is wrapped in a DedupOutlet.
+func OutletsFromConfig(in config.LoggingOutletEnumList, dedup *config.LoggingDedup) (*logger.Outlets, error) {
 
 	outlets := logger.NewOutlets()
 
@@ -43,6 +45,9 @@ func OutletsFromConfig(in config.LoggingOutletEnumList) (*logger.Outlets, error)
 			stdoutOutlets++
 		}
 
+		if dedup != nil && dedup.Enabled {
+			outlet = NewDedupOutlet(outlet, dedup.Interval)
+		}
 		outlets.Add(outlet, minLevel)
 
 	}
@@ -58,6 +63,18 @@ func OutletsFromConfig(in config.LoggingOutletEnumList) (*logger.Outlets, error)
 
 }
 
+// CloseOutlets closes the outlets built by OutletsFromConfig that buffer entries,
+// i.e., writes the summaries of the entries suppressed by DedupOutlets.
+func CloseOutlets(outlets *logger.Outlets) {
+	for _, l := range logger.AllLevels {
+		for _, o := range outlets.Get(l) {
+			if d, ok := o.(*DedupOutlet); ok {
+				d.Close() // outlets are added for several levels, Close is idempotent
+			}
+		}
+	}
+}
+
 type Subsystem string
 
 const (
@@ -258,6 +275,9 @@ func parseStdoutOutlet(in *config.StdoutLoggingOutlet, formatter EntryFormatter)
 }
 
 func parseTCPOutlet(in *config.TCPLoggingOutlet, formatter EntryFormatter) (out *TCPOutlet, err error) {
+	if in.BufferSize < 0 {
+		return nil, errors.New("buffer_size must not be negative")
+	}
 	var tlsConfig *tls.Config
 	if in.TLS != nil {
 		tlsConfig, err = func(m *config.TCPLoggingOutletTLS, host string) (*tls.Config, error) {
@@ -289,7 +309,7 @@ func parseTCPOutlet(in *config.TCPLoggingOutlet, formatter EntryFormatter) (out
 	}
 
 	formatter.SetMetadataFlags(MetadataAll)
-	return NewTCPOutlet(formatter, in.Net, in.Address, tlsConfig, in.RetryInterval), nil
+	return NewTCPOutlet(formatter, in.Net, in.Address, tlsConfig, in.RetryInterval, in.BufferSize), nil
 
 }
 
@@ -0,0 +1,175 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zrepl/zrepl/logger"
+)
+
+// DedupOutlet collapses repeated identical entries before passing them to the wrapped outlet.
+//
+// Entries are identical if level, message and fields match, ignoring the span field.
+// Repetitions are tracked per job so that interleaved output of concurrent jobs does not defeat it.
+// The first entry is always written; identical entries that follow are suppressed
+// until a different entry of the same job arrives or interval has passed since the entry was last written.
+// At that point, the suppressed entries are summarized by a copy of the entry with a "(repeated M times)" suffix.
+// A timer writes the summary once interval has passed even if no further entry arrives, and Close writes
+// the pending summaries right away.
+// Distinct entries are never delayed.
+type DedupOutlet struct {
+	outlet   logger.Outlet
+	interval time.Duration
+	now      func() time.Time
+
+	mtx        sync.Mutex
+	last       map[string]*dedupState // by job field value
+	flushTimer *time.Timer            // armed while summaries are pending, see flushDue
+	closed     bool
+}
+
+type dedupState struct {
+	key        string
+	entry      logger.Entry
+	written    time.Time
+	suppressed int
+}
+
+func NewDedupOutlet(outlet logger.Outlet, interval time.Duration) *DedupOutlet {
+	return &DedupOutlet{
+		outlet:   outlet,
+		interval: interval,
+		now:      time.Now,
+		last:     make(map[string]*dedupState),
+	}
+}
+
+func dedupKey(e logger.Entry) string {
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		if k == SpanField {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\x00%s", e.Level, e.Message)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\x00%s=%v", k, e.Fields[k])
+	}
+	return b.String()
+}
+
+func repeatedEntry(e logger.Entry, times int, at time.Time) logger.Entry {
+	e.Message = fmt.Sprintf("%s (repeated %d times)", e.Message, times)
+	e.Time = at
+	return e
+}
+
+func (o *DedupOutlet) WriteEntry(entry logger.Entry) error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	if o.closed {
+		return o.outlet.WriteEntry(entry)
+	}
+
+	job := fmt.Sprint(entry.Fields[JobField])
+	key := dedupKey(entry)
+	now := o.now()
+	last := o.last[job]
+
+	if last != nil && last.key == key {
+		last.suppressed++
+		if now.Sub(last.written) < o.interval {
+			o.armFlush(last.written.Add(o.interval).Sub(now))
+			return nil
+		}
+		n := last.suppressed
+		last.suppressed = 0
+		last.written = now
+		return o.outlet.WriteEntry(repeatedEntry(entry, n, entry.Time))
+	}
+
+	var err error
+	if last != nil && last.suppressed > 0 {
+		err = o.outlet.WriteEntry(repeatedEntry(last.entry, last.suppressed, now))
+	}
+	o.last[job] = &dedupState{key: key, entry: entry, written: now}
+	if werr := o.outlet.WriteEntry(entry); werr != nil {
+		err = werr
+	}
+	return err
+}
+
+// armFlush makes flushDue run after d unless it is already scheduled.
+// Must be called with o.mtx held.
+func (o *DedupOutlet) armFlush(d time.Duration) {
+	if o.flushTimer != nil || o.closed {
+		return
+	}
+	o.flushTimer = time.AfterFunc(d, o.flushDue)
+}
+
+// flushDue writes the summaries of the jobs whose entry was last written at least interval ago,
+// and schedules itself again for the summaries that are not yet due.
+func (o *DedupOutlet) flushDue() {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	o.flushTimer = nil
+	if o.closed {
+		return
+	}
+	now := o.now()
+	var next time.Duration
+	for _, last := range o.last {
+		if last.suppressed == 0 {
+			continue
+		}
+		if due := last.written.Add(o.interval).Sub(now); due > 0 {
+			if next == 0 || due < next {
+				next = due
+			}
+			continue
+		}
+		o.writeSummary(last, now)
+	}
+	if next > 0 {
+		o.armFlush(next)
+	}
+}
+
+// writeSummary must be called with o.mtx held.
+func (o *DedupOutlet) writeSummary(last *dedupState, now time.Time) error {
+	n := last.suppressed
+	last.suppressed = 0
+	last.written = now
+	return o.outlet.WriteEntry(repeatedEntry(last.entry, n, now))
+}
+
+// Close writes the pending summaries and stops the timer.
+// Entries written after Close are passed through without deduplication.
+func (o *DedupOutlet) Close() error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	if o.flushTimer != nil {
+		o.flushTimer.Stop()
+		o.flushTimer = nil
+	}
+	var err error
+	for _, last := range o.last {
+		if last.suppressed > 0 {
+			if werr := o.writeSummary(last, o.now()); werr != nil {
+				err = werr
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zrepl/zrepl/logger"
+)
+
+type recordingOutlet struct {
+	entries []logger.Entry
+}
+
+func (o *recordingOutlet) WriteEntry(e logger.Entry) error {
+	o.entries = append(o.entries, e)
+	return nil
+}
+
+func (o *recordingOutlet) messages() []string {
+	var msgs []string
+	for _, e := range o.entries {
+		msgs = append(msgs, e.Message)
+	}
+	return msgs
+}
+
+func TestDedupOutlet(t *testing.T) {
+	rec := &recordingOutlet{}
+	o := NewDedupOutlet(rec, time.Minute)
+	defer o.Close()
+	now := time.Unix(0, 0)
+	o.now = func() time.Time { return now }
+
+	entry := func(job, msg, span string) logger.Entry {
+		return logger.Entry{Level: logger.Error, Message: msg, Fields: logger.Fields{JobField: job, SpanField: span}}
+	}
+
+	// the span field is ignored, other jobs' entries don't interrupt the repetition
+	assert.NoError(t, o.WriteEntry(entry("a", "pool unavailable", "1")))
+	now = now.Add(time.Second)
+	assert.NoError(t, o.WriteEntry(entry("a", "pool unavailable", "2")))
+	assert.NoError(t, o.WriteEntry(entry("b", "replication done", "3")))
+	now = now.Add(time.Second)
+	assert.NoError(t, o.WriteEntry(entry("a", "pool unavailable", "4")))
+	assert.Equal(t, []string{"pool unavailable", "replication done"}, rec.messages())
+
+	// a distinct entry flushes the summary and is written immediately
+	assert.NoError(t, o.WriteEntry(entry("a", "pool available again", "5")))
+	assert.Equal(t, []string{"pool unavailable", "replication done", "pool unavailable (repeated 2 times)", "pool available again"}, rec.messages())
+	assert.Equal(t, logger.Error, rec.entries[2].Level)
+
+	// sustained repetitions are summarized once per interval
+	rec.entries = nil
+	for i := 0; i < 70; i++ {
+		assert.NoError(t, o.WriteEntry(entry("a", "pool unavailable", "6")))
+		now = now.Add(time.Second)
+	}
+	assert.Equal(t, []string{"pool unavailable", "pool unavailable (repeated 60 times)"}, rec.messages())
+}
+
+func TestDedupOutletFlush(t *testing.T) {
+	rec := &recordingOutlet{}
+	o := NewDedupOutlet(rec, time.Minute)
+	defer o.Close()
+	now := time.Unix(0, 0)
+	o.now = func() time.Time { return now }
+	entry := func(job string) logger.Entry {
+		return logger.Entry{Level: logger.Error, Message: "pool unavailable", Fields: logger.Fields{JobField: job}}
+	}
+
+	assert.NoError(t, o.WriteEntry(entry("a")))
+	assert.NoError(t, o.WriteEntry(entry("a")))
+	now = now.Add(30 * time.Second)
+	assert.NoError(t, o.WriteEntry(entry("b")))
+	assert.NoError(t, o.WriteEntry(entry("b")))
+	o.mtx.Lock()
+	assert.NotNil(t, o.flushTimer, "a summary is pending")
+	o.mtx.Unlock()
+
+	// the summary of job a is due without another entry, the one of job b is not yet
+	now = now.Add(30 * time.Second)
+	o.flushDue()
+	assert.Equal(t, []string{"pool unavailable", "pool unavailable", "pool unavailable (repeated 1 times)"}, rec.messages())
+	assert.Equal(t, "a", rec.entries[2].Fields[JobField])
+	o.mtx.Lock()
+	assert.NotNil(t, o.flushTimer, "rescheduled for job b")
+	o.mtx.Unlock()
+
+	// Close writes the pending summaries, later entries are passed through
+	assert.NoError(t, o.Close())
+	assert.Len(t, rec.entries, 4)
+	assert.Equal(t, "b", rec.entries[3].Fields[JobField])
+	assert.NoError(t, o.WriteEntry(entry("b")))
+	assert.Len(t, rec.entries, 5)
+}
+
+func TestDedupKeyFields(t *testing.T) {
+	a := logger.Entry{Level: logger.Error, Message: "m", Fields: logger.Fields{"fs": "pool/a"}}
+	b := logger.Entry{Level: logger.Error, Message: "m", Fields: logger.Fields{"fs": "pool/b"}}
+	c := logger.Entry{Level: logger.Warn, Message: "m", Fields: logger.Fields{"fs": "pool/a"}}
+	assert.NotEqual(t, dedupKey(a), dedupKey(b))
+	assert.NotEqual(t, dedupKey(a), dedupKey(c))
+	assert.Equal(t, dedupKey(a), dedupKey(logger.Entry{Level: logger.Error, Message: "m", Fields: logger.Fields{"fs": "pool/a", SpanField: "x"}}))
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"fmt"
 	"io"
 	"log/syslog"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -40,12 +42,21 @@ func (h WriterOutlet) WriteEntry(entry logger.Entry) error {
 type TCPOutlet struct {
 	formatter EntryFormatter
 	// Specifies how much time must pass between a connection error and a reconnection attempt
-	// Log entries written to the outlet during this time interval are silently dropped.
-	connect   func(ctx context.Context) (net.Conn, error)
-	entryChan chan *bytes.Buffer
+	// Log entries written to the outlet during this time interval are retained in the
+	// buffer if bufferSize > 0 and dropped otherwise.
+	connect func(ctx context.Context) (net.Conn, error)
+
+	// 0 means that entries are dropped if the connection is broken or not fast enough,
+	// and WriteEntry returns an error in that case
+	bufferSize int
+
+	mtx     sync.Mutex
+	entries [][]byte // FIFO, protected by mtx
+	dropped uint64   // number of entries dropped since the last marker was sent, protected by mtx
+	notify  chan struct{}
 }
 
-func NewTCPOutlet(formatter EntryFormatter, network, address string, tlsConfig *tls.Config, retryInterval time.Duration) *TCPOutlet {
+func NewTCPOutlet(formatter EntryFormatter, network, address string, tlsConfig *tls.Config, retryInterval time.Duration, bufferSize int) *TCPOutlet {
 
 	connect := func(ctx context.Context) (conn net.Conn, err error) {
 		deadl, ok := ctx.Deadline()
@@ -63,29 +74,86 @@ func NewTCPOutlet(formatter EntryFormatter, network, address string, tlsConfig *
 		return
 	}
 
-	entryChan := make(chan *bytes.Buffer, 1) // allow one message in flight while previous is in io.Copy()
-
-	o := &TCPOutlet{
-		formatter: formatter,
-		connect:   connect,
-		entryChan: entryChan,
-	}
-
+	o := newTCPOutlet(formatter, connect, bufferSize)
 	go o.outLoop(retryInterval)
-
 	return o
 }
 
+func newTCPOutlet(formatter EntryFormatter, connect func(ctx context.Context) (net.Conn, error), bufferSize int) *TCPOutlet {
+	return &TCPOutlet{
+		formatter:  formatter,
+		connect:    connect,
+		bufferSize: bufferSize,
+		notify:     make(chan struct{}, 1),
+	}
+}
+
 // FIXME: use this method
 func (h *TCPOutlet) Close() {
-	close(h.entryChan)
+	close(h.notify)
+}
+
+func (h *TCPOutlet) capacity() int {
+	if h.bufferSize == 0 {
+		return 1 // allow one message in flight while previous is in io.Copy()
+	}
+	return h.bufferSize
+}
+
+// pop removes the oldest entry from the buffer.
+// dropped is the number of entries that were dropped since the last successful call to droppedSent.
+func (h *TCPOutlet) pop() (entry []byte, dropped uint64, ok bool) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if len(h.entries) == 0 {
+		return nil, h.dropped, false
+	}
+	entry = h.entries[0]
+	h.entries[0] = nil
+	h.entries = h.entries[1:]
+	return entry, h.dropped, true
+}
+
+// pushFront returns an entry that could not be written to the buffer.
+// If the buffer was filled up in the meantime, the entry is dropped.
+func (h *TCPOutlet) pushFront(entry []byte) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.bufferSize == 0 {
+		return // same behavior as if the entry had been dropped in WriteEntry
+	}
+	if len(h.entries) >= h.capacity() {
+		h.dropped++
+		return
+	}
+	h.entries = append([][]byte{entry}, h.entries...)
+}
+
+func (h *TCPOutlet) droppedSent(n uint64) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.dropped -= n
+}
+
+func (h *TCPOutlet) droppedMarker(n uint64) []byte {
+	e := logger.Entry{
+		Level:   logger.Warn,
+		Message: fmt.Sprintf("dropped %d log lines because the connection to the log collector was unavailable or too slow", n),
+		Time:    time.Now(),
+		Fields:  logger.Fields{},
+	}
+	ebytes, err := h.formatter.Format(&e)
+	if err != nil {
+		return []byte(e.Message + "\n")
+	}
+	return append(ebytes, '\n')
 }
 
 func (h *TCPOutlet) outLoop(retryInterval time.Duration) {
 
 	var retry time.Time
 	var conn net.Conn
-	for msg := range h.entryChan {
+	write := func(msg []byte) error {
 		var err error
 		for conn == nil {
 			time.Sleep(time.Until(retry))
@@ -95,17 +163,40 @@ func (h *TCPOutlet) outLoop(retryInterval time.Duration) {
 			if err != nil {
 				retry = time.Now().Add(retryInterval)
 				conn = nil
+				if h.bufferSize > 0 {
+					return err // keep the entry in the buffer until the next attempt
+				}
 			}
 		}
 		err = conn.SetWriteDeadline(time.Now().Add(retryInterval))
 		if err == nil {
-			_, err = io.Copy(conn, msg)
+			_, err = io.Copy(conn, bytes.NewReader(msg))
 		}
 		if err != nil {
 			retry = time.Now().Add(retryInterval)
 			conn.Close()
 			conn = nil
 		}
+		return err
+	}
+
+	for range h.notify {
+		for {
+			msg, dropped, ok := h.pop()
+			if !ok {
+				break
+			}
+			if dropped > 0 && h.bufferSize > 0 {
+				if err := write(h.droppedMarker(dropped)); err != nil {
+					h.pushFront(msg)
+					continue
+				}
+				h.droppedSent(dropped)
+			}
+			if err := write(msg); err != nil {
+				h.pushFront(msg)
+			}
+		}
 	}
 }
 
@@ -115,17 +206,27 @@ func (h *TCPOutlet) WriteEntry(e logger.Entry) error {
 	if err != nil {
 		return err
 	}
+	ebytes = append(ebytes, '\n')
 
-	buf := new(bytes.Buffer)
-	buf.Write(ebytes)
-	buf.WriteString("\n")
+	h.mtx.Lock()
+	if len(h.entries) >= h.capacity() {
+		if h.bufferSize == 0 {
+			h.mtx.Unlock()
+			return errors.New("connection broken or not fast enough")
+		}
+		// drop the oldest entry
+		h.entries[0] = nil
+		h.entries = h.entries[1:]
+		h.dropped++
+	}
+	h.entries = append(h.entries, ebytes)
+	h.mtx.Unlock()
 
 	select {
-	case h.entryChan <- buf:
-		return nil
-	default:
-		return errors.New("connection broken or not fast enough")
+	case h.notify <- struct{}{}:
+	default: // outLoop has yet to drain the buffer
 	}
+	return nil
 }
 
 type SyslogOutlet struct {
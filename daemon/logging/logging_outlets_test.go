@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/logger"
+)
+
+func TestTCPOutletBuffersDuringOutage(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	var available int32
+	connect := func(ctx context.Context) (net.Conn, error) {
+		if atomic.LoadInt32(&available) == 0 {
+			return nil, fmt.Errorf("collector unavailable")
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", l.Addr().String())
+	}
+
+	o := newTCPOutlet(NoFormatter{}, connect, 3)
+	defer o.Close()
+	go o.outLoop(10 * time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, o.WriteEntry(logger.Entry{Message: fmt.Sprintf("line %d", i)}))
+	}
+	time.Sleep(50 * time.Millisecond) // a few failed connection attempts
+
+	atomic.StoreInt32(&available, 1)
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	r := bufio.NewScanner(conn)
+
+	var lines []string
+	for len(lines) < 4 && r.Scan() {
+		lines = append(lines, r.Text())
+	}
+	require.NoError(t, r.Err())
+	// the oldest lines were dropped, the first line sent is the marker
+	assert.Contains(t, lines[0], "dropped 2 log lines")
+	assert.Equal(t, []string{"line 2", "line 3", "line 4"}, lines[1:])
+
+	// no marker once the backlog is sent
+	require.NoError(t, o.WriteEntry(logger.Entry{Message: "line 5"}))
+	require.True(t, r.Scan())
+	assert.Equal(t, "line 5", r.Text())
+}
+
+func TestTCPOutletWithoutBufferDropsWithError(t *testing.T) {
+	connect := func(ctx context.Context) (net.Conn, error) {
+		return nil, fmt.Errorf("collector unavailable")
+	}
+	o := newTCPOutlet(NoFormatter{}, connect, 0)
+	// outLoop is not running, so the single slot fills up
+	require.NoError(t, o.WriteEntry(logger.Entry{Message: "line 0"}))
+	assert.Error(t, o.WriteEntry(logger.Entry{Message: "line 1"}))
+}
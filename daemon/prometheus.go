@@ -2,9 +2,14 @@ package daemon
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
@@ -14,6 +19,7 @@ import (
 	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/logger"
 	"github.com/zrepl/zrepl/rpc/dataconn/frameconn"
+	"github.com/zrepl/zrepl/tlsconf"
 	"github.com/zrepl/zrepl/util/tcpsock"
 	"github.com/zrepl/zrepl/zfs"
 )
@@ -21,17 +27,100 @@ import (
 type prometheusJob struct {
 	listen   string
 	freeBind bool
+
+	basicAuth *prometheusBasicAuth // nil if disabled
+	tlsConfig *tls.Config          // nil if disabled
+	clientCNs map[string]struct{}  // nil if any client certificate signed by the CA is accepted
+}
+
+type prometheusBasicAuth struct {
+	user, password string
 }
 
 func newPrometheusJobFromConfig(in *config.PrometheusMonitoring) (*prometheusJob, error) {
 	if _, _, err := net.SplitHostPort(in.Listen); err != nil {
 		return nil, err
 	}
-	return &prometheusJob{in.Listen, in.ListenFreeBind}, nil
+	j := &prometheusJob{listen: in.Listen, freeBind: in.ListenFreeBind}
+
+	if in.BasicAuth != nil {
+		pw, err := ioutil.ReadFile(in.BasicAuth.PasswordFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read basic_auth password file")
+		}
+		password := strings.TrimRight(string(pw), "\r\n")
+		if in.BasicAuth.User == "" || password == "" {
+			return nil, errors.New("basic_auth user and password must not be empty")
+		}
+		j.basicAuth = &prometheusBasicAuth{in.BasicAuth.User, password}
+	}
+
+	if in.TLS != nil {
+		cert, err := tlsconf.LoadX509KeyPair(in.TLS.Cert, in.TLS.Key)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse tls cert/key pair")
+		}
+		j.tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		}
+		if in.TLS.Ca != "" {
+			ca, err := tlsconf.ParseCAFile(in.TLS.Ca)
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot parse tls ca file")
+			}
+			j.tlsConfig.ClientCAs = ca
+			j.tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		if len(in.TLS.ClientCNs) > 0 {
+			if in.TLS.Ca == "" {
+				return nil, errors.New("tls client_cns requires ca to be set")
+			}
+			j.clientCNs = make(map[string]struct{}, len(in.TLS.ClientCNs))
+			for _, cn := range in.TLS.ClientCNs {
+				j.clientCNs[cn] = struct{}{}
+			}
+		}
+	}
+
+	return j, nil
+}
+
+// authHandler wraps next with the access checks configured for the job.
+// Requests without valid basic auth credentials get 401,
+// requests with a client certificate whose CN is not in clientCNs get 403.
+func (j *prometheusJob) authHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if j.clientCNs != nil {
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+				http.Error(w, "client certificate required", http.StatusForbidden)
+				return
+			}
+			if _, ok := j.clientCNs[r.TLS.PeerCertificates[0].Subject.CommonName]; !ok {
+				http.Error(w, "client common name not authorized", http.StatusForbidden)
+				return
+			}
+		}
+		if j.basicAuth != nil {
+			user, password, ok := r.BasicAuth()
+			userOk := subtle.ConstantTimeCompare([]byte(user), []byte(j.basicAuth.user)) == 1
+			passwordOk := subtle.ConstantTimeCompare([]byte(password), []byte(j.basicAuth.password)) == 1
+			if !ok || !userOk || !passwordOk {
+				w.Header().Set("WWW-Authenticate", `Basic realm="zrepl"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 var prom struct {
 	taskLogEntries *prometheus.CounterVec
+
+	configLoadedTimestamp prometheus.Gauge
+	configInfo            *prometheus.GaugeVec
+	configReloadErrors    prometheus.Counter
+	configJobs            prometheus.Gauge
 }
 
 func init() {
@@ -42,6 +131,40 @@ func init() {
 		Help:      "number of log entries per job task and level",
 	}, []string{"zrepl_job", "level"})
 	prometheus.MustRegister(prom.taskLogEntries)
+
+	prom.configLoadedTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "zrepl",
+		Subsystem: "config",
+		Name:      "loaded_timestamp_seconds",
+		Help:      "unix timestamp at which the daemon's currently active config was loaded",
+	})
+	prom.configReloadErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "zrepl",
+		Subsystem: "config",
+		Name:      "reload_errors_total",
+		Help:      "number of failed attempts to load a new config into the running daemon",
+	})
+	prom.configJobs = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "zrepl",
+		Subsystem: "config",
+		Name:      "jobs",
+		Help:      "number of jobs in the daemon's currently active config",
+	})
+	prom.configInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "zrepl",
+		Subsystem: "config",
+		Name:      "info",
+		Help:      "always 1, labelled by the hash of the daemon's currently active config",
+	}, []string{"hash"})
+	prometheus.MustRegister(prom.configLoadedTimestamp, prom.configReloadErrors, prom.configJobs, prom.configInfo)
+}
+
+// prometheusConfigLoaded updates the config metrics after the daemon has successfully built its jobs from a config.
+func prometheusConfigLoaded(conf *config.Config, info ControlConfigInfo) {
+	prom.configLoadedTimestamp.Set(float64(info.LoadedAt.UnixNano()) / 1e9)
+	prom.configJobs.Set(float64(len(conf.Jobs)))
+	prom.configInfo.Reset()
+	prom.configInfo.WithLabelValues(info.Hash).Set(1)
 }
 
 func (j *prometheusJob) Name() string { return jobNamePrometheus }
@@ -66,18 +189,23 @@ func (j *prometheusJob) Run(ctx context.Context) {
 
 	log := job.GetLogger(ctx)
 
-	l, err := tcpsock.Listen(j.listen, j.freeBind)
+	tcpListener, err := tcpsock.Listen(j.listen, j.freeBind)
 	if err != nil {
 		log.WithError(err).Error("cannot listen")
 		return
 	}
 	go func() {
 		<-ctx.Done()
-		l.Close()
+		tcpListener.Close()
 	}()
 
+	var l net.Listener = tcpListener
+	if j.tlsConfig != nil {
+		l = tls.NewListener(l, j.tlsConfig)
+	}
+
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/metrics", j.authHandler(promhttp.Handler()))
 
 	err = http.Serve(l, mux)
 	if err != nil && ctx.Err() == nil {
@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusJobAuthHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	withCN := func(r *http.Request, cn string) *http.Request {
+		r.TLS = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: cn}}},
+		}
+		return r
+	}
+
+	type tc struct {
+		name   string
+		job    prometheusJob
+		req    func() *http.Request
+		expect int
+	}
+	basicAuth := &prometheusBasicAuth{"prom", "secret"}
+	clientCNs := map[string]struct{}{"scraper": {}}
+	tcs := []tc{
+		{
+			name:   "no_auth",
+			job:    prometheusJob{},
+			req:    func() *http.Request { return httptest.NewRequest("GET", "/metrics", nil) },
+			expect: http.StatusOK,
+		},
+		{
+			name:   "basic_auth_missing",
+			job:    prometheusJob{basicAuth: basicAuth},
+			req:    func() *http.Request { return httptest.NewRequest("GET", "/metrics", nil) },
+			expect: http.StatusUnauthorized,
+		},
+		{
+			name: "basic_auth_wrong_password",
+			job:  prometheusJob{basicAuth: basicAuth},
+			req: func() *http.Request {
+				r := httptest.NewRequest("GET", "/metrics", nil)
+				r.SetBasicAuth("prom", "wrong")
+				return r
+			},
+			expect: http.StatusUnauthorized,
+		},
+		{
+			name: "basic_auth_ok",
+			job:  prometheusJob{basicAuth: basicAuth},
+			req: func() *http.Request {
+				r := httptest.NewRequest("GET", "/metrics", nil)
+				r.SetBasicAuth("prom", "secret")
+				return r
+			},
+			expect: http.StatusOK,
+		},
+		{
+			name:   "client_cn_missing_cert",
+			job:    prometheusJob{clientCNs: clientCNs},
+			req:    func() *http.Request { return httptest.NewRequest("GET", "/metrics", nil) },
+			expect: http.StatusForbidden,
+		},
+		{
+			name:   "client_cn_not_authorized",
+			job:    prometheusJob{clientCNs: clientCNs},
+			req:    func() *http.Request { return withCN(httptest.NewRequest("GET", "/metrics", nil), "other") },
+			expect: http.StatusForbidden,
+		},
+		{
+			name:   "client_cn_ok",
+			job:    prometheusJob{clientCNs: clientCNs},
+			req:    func() *http.Request { return withCN(httptest.NewRequest("GET", "/metrics", nil), "scraper") },
+			expect: http.StatusOK,
+		},
+	}
+
+	for _, c := range tcs {
+		t.Run(c.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c.job.authHandler(ok).ServeHTTP(rec, c.req())
+			assert.Equal(t, c.expect, rec.Code)
+		})
+	}
+}
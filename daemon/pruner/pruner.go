@@ -3,6 +3,7 @@ package pruner
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -32,6 +33,27 @@ type Target interface {
 	DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error)
 }
 
+// BookmarkTarget is implemented by Targets that support keep rules with convert_to_bookmark.
+type BookmarkTarget interface {
+	Target
+	// BookmarkSnapshot idempotently creates a bookmark of snapshot snap that has the same name as snap.
+	BookmarkSnapshot(ctx context.Context, fs string, snap *pdu.FilesystemVersion) error
+	// PrunableBookmarks returns the bookmarks of fs that keep rules with convert_to_bookmark may destroy,
+	// i.e., all bookmarks except those that zrepl uses for replication.
+	PrunableBookmarks(ctx context.Context, fs string) ([]*pdu.FilesystemVersion, error)
+	// DestroyBookmarks destroys bookmarks returned by PrunableBookmarks.
+	DestroyBookmarks(ctx context.Context, fs string, bookmarks []*pdu.FilesystemVersion) error
+}
+
+// PinTarget is implemented by Targets that know which snapshots are pinned
+// through `zrepl snapshots pin`, which the pruner then excludes from its plan.
+// Other targets report pinned snapshots in their DestroySnapshotsRes, see pdu.DestroySnapshotResErrorPinned.
+type PinTarget interface {
+	Target
+	// PinnedSnapshots returns the GUIDs of the pinned snapshots of fs.
+	PinnedSnapshots(ctx context.Context, fs string) (map[uint64]bool, error)
+}
+
 type Logger = logger.Logger
 
 type contextKey int
@@ -50,9 +72,14 @@ type args struct {
 	target                         Target
 	receiver                       History
 	rules                          []pruning.KeepRule
+	convertToBookmark              []bookmarkMatcher // per rule, see convertToBookmarkFromConfig
 	retryWait                      time.Duration
 	considerSnapAtCursorReplicated bool
+	allowDestroyLast               bool
 	promPruneSecs                  prometheus.Observer
+	concurrency                    int
+	// snapshots whose name doesn't start with any of these prefixes are ignored, nil to consider all snapshots
+	managedPrefixes []string
 }
 
 type Pruner struct {
@@ -71,16 +98,24 @@ type Pruner struct {
 
 type PrunerFactory struct {
 	senderRules                    []pruning.KeepRule
+	senderConvertToBookmark        []bookmarkMatcher
 	receiverRules                  []pruning.KeepRule
 	retryWait                      time.Duration
 	considerSnapAtCursorReplicated bool
+	allowDestroyLast               bool
 	promPruneSecs                  *prometheus.HistogramVec
+	concurrency                    int
+	// nil unless protect_foreign_snapshots is set
+	receiverManagedPrefixes []string
 }
 
 type LocalPrunerFactory struct {
-	keepRules     []pruning.KeepRule
-	retryWait     time.Duration
-	promPruneSecs *prometheus.HistogramVec
+	keepRules         []pruning.KeepRule
+	convertToBookmark []bookmarkMatcher
+	retryWait         time.Duration
+	allowDestroyLast  bool
+	promPruneSecs     *prometheus.HistogramVec
+	concurrency       int
 }
 
 func NewLocalPrunerFactory(in config.PruningLocal, promPruneSecs *prometheus.HistogramVec) (*LocalPrunerFactory, error) {
@@ -95,10 +130,16 @@ func NewLocalPrunerFactory(in config.PruningLocal, promPruneSecs *prometheus.His
 			return nil, fmt.Errorf("single-site pruner cannot support `not_replicated` keep rule")
 		}
 	}
+	if in.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be positive, got %d", in.Concurrency)
+	}
 	f := &LocalPrunerFactory{
-		keepRules:     rules,
-		retryWait:     envconst.Duration("ZREPL_PRUNER_RETRY_INTERVAL", 10*time.Second),
-		promPruneSecs: promPruneSecs,
+		keepRules:         rules,
+		convertToBookmark: convertToBookmarkFromConfig(in.Keep),
+		retryWait:         envconst.Duration("ZREPL_PRUNER_RETRY_INTERVAL", 10*time.Second),
+		allowDestroyLast:  in.AllowDestroyLast,
+		promPruneSecs:     promPruneSecs,
+		concurrency:       in.Concurrency,
 	}
 	return f, nil
 }
@@ -114,6 +155,30 @@ func NewPrunerFactory(in config.PruningSenderReceiver, promPruneSecs *prometheus
 		return nil, errors.Wrap(err, "cannot build sender pruning rules")
 	}
 
+	for i, convert := range convertToBookmarkFromConfig(in.KeepReceiver) {
+		if convert != nil {
+			// the receiver cannot receive incremental streams on top of a bookmark
+			return nil, fmt.Errorf("cannot build receiver pruning rules: rule #%d: `convert_to_bookmark` is only supported on the sender side", i)
+		}
+	}
+
+	if in.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be positive, got %d", in.Concurrency)
+	}
+
+	var receiverManagedPrefixes []string
+	if in.ProtectForeignSnapshots {
+		if len(in.ManagedPrefixes) == 0 {
+			return nil, fmt.Errorf("`protect_foreign_snapshots` requires at least one managed snapshot prefix: set `managed_prefixes` or use periodic snapshotting in a push job")
+		}
+		for _, p := range in.ManagedPrefixes {
+			if p == "" {
+				return nil, fmt.Errorf("`managed_prefixes` must not contain an empty prefix")
+			}
+		}
+		receiverManagedPrefixes = in.ManagedPrefixes
+	}
+
 	considerSnapAtCursorReplicated := false
 	for _, r := range in.KeepSender {
 		knr, ok := r.Ret.(*config.PruneKeepNotReplicated)
@@ -124,14 +189,71 @@ func NewPrunerFactory(in config.PruningSenderReceiver, promPruneSecs *prometheus
 	}
 	f := &PrunerFactory{
 		senderRules:                    keepRulesSender,
+		senderConvertToBookmark:        convertToBookmarkFromConfig(in.KeepSender),
 		receiverRules:                  keepRulesReceiver,
 		retryWait:                      envconst.Duration("ZREPL_PRUNER_RETRY_INTERVAL", 10*time.Second),
 		considerSnapAtCursorReplicated: considerSnapAtCursorReplicated,
+		allowDestroyLast:               in.AllowDestroyLast,
 		promPruneSecs:                  promPruneSecs,
+		concurrency:                    in.Concurrency,
+		receiverManagedPrefixes:        receiverManagedPrefixes,
 	}
 	return f, nil
 }
 
+// SenderConvertsToBookmarks returns true if any sender keep rule has convert_to_bookmark set.
+func (f *PrunerFactory) SenderConvertsToBookmarks() bool {
+	for _, convert := range f.senderConvertToBookmark {
+		if convert != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ReceiverManagedPrefixes returns the snapshot prefixes that the receiver keep rules are limited to,
+// or nil if protect_foreign_snapshots is not set.
+func (f *PrunerFactory) ReceiverManagedPrefixes() []string {
+	return f.receiverManagedPrefixes
+}
+
+// bookmarkMatcher returns true if a bookmark with the given name is matched by the regex of a keep rule.
+type bookmarkMatcher func(name string) bool
+
+// convertToBookmarkFromConfig returns a bookmarkMatcher for each rule that has convert_to_bookmark set
+// and nil for all other rules.
+// The regexes have already been validated by pruning.RulesFromConfig.
+func convertToBookmarkFromConfig(in []config.PruningEnum) []bookmarkMatcher {
+	matchRegex := func(regex string, negate bool) bookmarkMatcher {
+		re := regexp.MustCompile(regex)
+		return func(name string) bool {
+			return re.MatchString(name) != negate
+		}
+	}
+	convert := make([]bookmarkMatcher, len(in))
+	for i, r := range in {
+		switch v := r.Ret.(type) {
+		case *config.PruneKeepLastN:
+			if v.ConvertToBookmark {
+				convert[i] = matchRegex(v.Regex, false)
+			}
+		case *config.PruneKeepRegex:
+			if v.ConvertToBookmark {
+				convert[i] = matchRegex(v.Regex, v.Negate)
+			}
+		case *config.PruneGrid:
+			if v.ConvertToBookmark {
+				convert[i] = matchRegex(v.Regex, false)
+			}
+		case *config.PruneKeepAnchored:
+			if v.ConvertToBookmark {
+				convert[i] = matchRegex(v.Regex, false)
+			}
+		}
+	}
+	return convert
+}
+
 func (f *PrunerFactory) BuildSenderPruner(ctx context.Context, target Target, receiver History) *Pruner {
 	p := &Pruner{
 		args: args{
@@ -139,9 +261,13 @@ func (f *PrunerFactory) BuildSenderPruner(ctx context.Context, target Target, re
 			target,
 			receiver,
 			f.senderRules,
+			f.senderConvertToBookmark,
 			f.retryWait,
 			f.considerSnapAtCursorReplicated,
+			f.allowDestroyLast,
 			f.promPruneSecs.WithLabelValues("sender"),
+			f.concurrency,
+			nil,
 		},
 		state: Plan,
 	}
@@ -155,9 +281,13 @@ func (f *PrunerFactory) BuildReceiverPruner(ctx context.Context, target Target,
 			target,
 			receiver,
 			f.receiverRules,
+			nil, // rejected in NewPrunerFactory
 			f.retryWait,
 			false, // senseless here anyways
+			f.allowDestroyLast,
 			f.promPruneSecs.WithLabelValues("receiver"),
+			f.concurrency,
+			f.receiverManagedPrefixes,
 		},
 		state: Plan,
 	}
@@ -171,9 +301,13 @@ func (f *LocalPrunerFactory) BuildLocalPruner(ctx context.Context, target Target
 			target,
 			receiver,
 			f.keepRules,
+			f.convertToBookmark,
 			f.retryWait,
 			false, // considerSnapAtCursorReplicated is not relevant for local pruning
+			f.allowDestroyLast,
 			f.promPruneSecs.WithLabelValues("local"),
+			f.concurrency,
+			nil,
 		},
 		state: Plan,
 	}
@@ -220,8 +354,12 @@ type Report struct {
 type FSReport struct {
 	Filesystem                string
 	SnapshotList, DestroyList []SnapshotReport
-	SkipReason                FSSkipReason
-	LastError                 string
+	// ConvertList contains the snapshots that are destroyed after a bookmark of them has been created
+	ConvertList []SnapshotReport `json:",omitempty"`
+	// BookmarkDestroyList contains the bookmarks that the rules with convert_to_bookmark no longer keep
+	BookmarkDestroyList []SnapshotReport `json:",omitempty"`
+	SkipReason          FSSkipReason
+	LastError           string
 }
 
 type SnapshotReport struct {
@@ -247,6 +385,74 @@ func (p *Pruner) Report() *Report {
 	return &r
 }
 
+type FSPlan struct {
+	Filesystem string
+	SkipReason FSSkipReason
+	Error      string
+	Snapshots  []SnapshotPlan
+}
+
+type SnapshotPlan struct {
+	SnapshotReport
+	Guid    uint64
+	Destroy bool
+	// ConvertToBookmark is true if the snapshot is only kept by rules with convert_to_bookmark
+	ConvertToBookmark bool
+	// Pinned is true if the snapshot is not destroyed because it is pinned, Destroy is false then
+	Pinned bool
+	// KeptAsLast is true if no keep rule keeps the snapshot but it is the last snapshot of the filesystem,
+	// which is not destroyed unless allow_destroy_last is set
+	KeptAsLast bool
+	// indices of the keep rules that keep the snapshot
+	KeptBy []int
+}
+
+// Plan only runs the planning phase of p and returns the decisions of the keep rules.
+// No snapshots are destroyed and the state of p is not modified.
+func (p *Pruner) Plan() ([]FSPlan, error) {
+	pfss, err := plan(&p.args)
+	if err != nil {
+		return nil, err
+	}
+	plans := make([]FSPlan, len(pfss))
+	for i, pfs := range pfss {
+		fp := FSPlan{
+			Filesystem: pfs.path,
+			SkipReason: pfs.skipReason,
+		}
+		if pfs.planErr != nil {
+			fp.Error = pfs.planErr.Error()
+			if pfs.planErrContext != "" {
+				fp.Error = fmt.Sprintf("%s: %s", pfs.planErrContext, fp.Error)
+			}
+		}
+		// the decisions of the keep rules are overridden by pins and the allow_destroy_last interlock,
+		// which only edit the destroy and convert lists
+		destroyed := make(map[pruning.Snapshot]bool, len(pfs.destroyList))
+		for _, s := range pfs.destroyList {
+			destroyed[s] = true
+		}
+		converted := make(map[pruning.Snapshot]bool, len(pfs.convertList))
+		for _, s := range pfs.convertList {
+			converted[s] = true
+		}
+		for _, d := range pfs.decisions {
+			snap := d.Snapshot.(snapshot)
+			fp.Snapshots = append(fp.Snapshots, SnapshotPlan{
+				SnapshotReport:    snap.Report(),
+				Guid:              snap.fsv.GetGuid(),
+				Destroy:           destroyed[snap],
+				ConvertToBookmark: converted[snap],
+				Pinned:            pfs.pinned[snap.fsv.GetGuid()],
+				KeptAsLast:        d.Destroy && !destroyed[snap] && !pfs.pinned[snap.fsv.GetGuid()],
+				KeptBy:            d.KeptBy,
+			})
+		}
+		plans[i] = fp
+	}
+	return plans, nil
+}
+
 func (p *Pruner) State() State {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
@@ -267,9 +473,19 @@ type fs struct {
 	// snapshots presented by target
 	// (type snapshot)
 	snaps []pruning.Snapshot
-	// destroy list returned by pruning.PruneSnapshots(snaps)
+	// decisions returned by pruning.Decide(snaps)
+	decisions []pruning.Decision
+	// snapshots of decisions that are to be destroyed
 	// (type snapshot)
 	destroyList []pruning.Snapshot
+	// snapshots of decisions that are to be converted to bookmarks
+	// (type snapshot)
+	convertList []pruning.Snapshot
+	// bookmarks that the rules with convert_to_bookmark no longer keep
+	// (type snapshot)
+	bookmarkDestroyList []pruning.Snapshot
+	// GUIDs of the pinned snapshots that the keep rules would destroy or convert
+	pinned map[uint64]bool
 
 	mtx sync.RWMutex
 
@@ -316,6 +532,20 @@ func (f *fs) Report() FSReport {
 		r.DestroyList[i] = snap.(snapshot).Report()
 	}
 
+	if len(f.convertList) > 0 {
+		r.ConvertList = make([]SnapshotReport, len(f.convertList))
+		for i, snap := range f.convertList {
+			r.ConvertList[i] = snap.(snapshot).Report()
+		}
+	}
+
+	if len(f.bookmarkDestroyList) > 0 {
+		r.BookmarkDestroyList = make([]SnapshotReport, len(f.bookmarkDestroyList))
+		for i, bm := range f.bookmarkDestroyList {
+			r.BookmarkDestroyList[i] = bm.(snapshot).Report()
+		}
+	}
+
 	return r
 }
 
@@ -341,17 +571,15 @@ func (s snapshot) Replicated() bool { return s.replicated }
 
 func (s snapshot) Date() time.Time { return s.date }
 
-func doOneAttempt(a *args, u updater) {
+// plan lists the filesystems and snapshots of the prune target and applies the keep rules.
+// Per-filesystem errors are reported through the planErr field of the returned fs.
+func plan(a *args) ([]*fs, error) {
 
 	ctx, target, receiver := a.ctx, a.target, a.receiver
 
 	sfssres, err := receiver.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
 	if err != nil {
-		u(func(p *Pruner) {
-			p.state = PlanErr
-			p.err = err
-		})
-		return
+		return nil, err
 	}
 	sfss := make(map[string]*pdu.Filesystem)
 	for _, sfs := range sfssres.GetFilesystems() {
@@ -360,11 +588,7 @@ func doOneAttempt(a *args, u updater) {
 
 	tfssres, err := target.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
 	if err != nil {
-		u(func(p *Pruner) {
-			p.state = PlanErr
-			p.err = err
-		})
-		return
+		return nil, err
 	}
 	tfss := tfssres.GetFilesystems()
 
@@ -440,15 +664,19 @@ tfss_loop:
 			if tfsv.Type != pdu.FilesystemVersion_Snapshot {
 				continue
 			}
+			// note that we cannot use CreateTXG because target and receiver could be on different pools
+			atCursor := tfsv.Guid == rc.GetGuid()
+			preCursor = preCursor && !atCursor
+			if a.isForeign(tfsv.Name) {
+				l.WithField("snap", tfsv.Name).Debug("ignoring foreign snapshot")
+				continue
+			}
 			creation, err := tfsv.CreationAsTime()
 			if err != nil {
 				err := fmt.Errorf("%s: %s", tfsv.RelName(), err)
 				pfsPlanErrAndLog(err, "fs version with invalid creation date")
 				continue tfss_loop
 			}
-			// note that we cannot use CreateTXG because target and receiver could be on different pools
-			atCursor := tfsv.Guid == rc.GetGuid()
-			preCursor = preCursor && !atCursor
 			pfs.snaps = append(pfs.snaps, snapshot{
 				replicated: preCursor || (a.considerSnapAtCursorReplicated && atCursor),
 				date:       creation,
@@ -461,7 +689,196 @@ tfss_loop:
 		}
 
 		// Apply prune rules
-		pfs.destroyList = pruning.PruneSnapshots(pfs.snaps, a.rules)
+		pfs.decisions = pruning.Decide(pfs.snaps, a.rules)
+		for _, d := range pfs.decisions {
+			if d.Destroy {
+				pfs.destroyList = append(pfs.destroyList, d.Snapshot)
+			} else if convertsToBookmark(d.KeptBy, a.convertToBookmark) {
+				pfs.convertList = append(pfs.convertList, d.Snapshot)
+			}
+		}
+		if pt, ok := target.(PinTarget); ok && len(pfs.destroyList)+len(pfs.convertList) > 0 {
+			pinned, err := pt.PinnedSnapshots(ctx, tfs.Path)
+			if err != nil {
+				pfs.destroyList, pfs.convertList = nil, nil
+				pfsPlanErrAndLog(err, "cannot determine pinned snapshots")
+				continue tfss_loop
+			}
+			for _, s := range pfs.removePinned(pinned) {
+				l.WithField("snap", s.Name()).Info("not destroying pinned snapshot")
+			}
+		}
+		if !a.allowDestroyLast {
+			if kept := pfs.keepLastSnapshot(); kept != nil {
+				l.WithField("snap", kept.Name()).
+					Error("keep rules would destroy all snapshots of the filesystem, keeping the latest one (set `allow_destroy_last: true` in the job's pruning config to allow this)")
+			}
+		}
+		if bt, ok := target.(BookmarkTarget); ok && a.convertsToBookmarks() {
+			// the rules with convert_to_bookmark also thin out the bookmarks created by earlier conversions
+			bookmarks, err := bt.PrunableBookmarks(ctx, tfs.Path)
+			if err != nil {
+				pfs.destroyList, pfs.convertList = nil, nil
+				pfsPlanErrAndLog(err, "cannot list bookmarks")
+				continue tfss_loop
+			}
+			pfs.bookmarkDestroyList, err = a.destroyedBookmarks(pfs.snaps, bookmarks)
+			if err != nil {
+				pfs.destroyList, pfs.convertList = nil, nil
+				pfsPlanErrAndLog(err, "bookmark with invalid creation date")
+				continue tfss_loop
+			}
+		}
+	}
+
+	return pfss, nil
+}
+
+// isForeign returns true if the snapshot with the given name must not be considered by the keep rules,
+// i.e., if a.managedPrefixes is set and name starts with none of them.
+func (a *args) isForeign(name string) bool {
+	if a.managedPrefixes == nil {
+		return false
+	}
+	for _, p := range a.managedPrefixes {
+		if strings.HasPrefix(name, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// keepLastSnapshot removes the latest snapshot from the destroy and convert lists
+// if all snapshots of the filesystem would be destroyed, and returns it.
+// This is a safety net against keep rules that don't keep any snapshot,
+// which would destroy the incremental replication base.
+// pfs.snaps must be sorted from older to newer.
+func (pfs *fs) keepLastSnapshot() pruning.Snapshot {
+	if len(pfs.snaps) == 0 || len(pfs.destroyList)+len(pfs.convertList) < len(pfs.snaps) {
+		return nil
+	}
+	last := pfs.snaps[len(pfs.snaps)-1]
+	remove := func(l []pruning.Snapshot) []pruning.Snapshot {
+		out := l[:0]
+		for _, s := range l {
+			if s != last {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	pfs.destroyList = remove(pfs.destroyList)
+	pfs.convertList = remove(pfs.convertList)
+	return last
+}
+
+// removePinned removes the snapshots whose GUID is in pinned from the destroy and convert lists
+// and returns them.
+func (pfs *fs) removePinned(pinned map[uint64]bool) (removed []pruning.Snapshot) {
+	remove := func(l []pruning.Snapshot) []pruning.Snapshot {
+		out := l[:0]
+		for _, s := range l {
+			guid := s.(snapshot).fsv.GetGuid()
+			if pinned[guid] {
+				if pfs.pinned == nil {
+					pfs.pinned = make(map[uint64]bool)
+				}
+				pfs.pinned[guid] = true
+				removed = append(removed, s)
+			} else {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	pfs.destroyList = remove(pfs.destroyList)
+	pfs.convertList = remove(pfs.convertList)
+	return removed
+}
+
+// convertsToBookmarks returns true if any rule has convert_to_bookmark set.
+func (a *args) convertsToBookmarks() bool {
+	for _, m := range a.convertToBookmark {
+		if m != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// destroyedBookmarks evaluates the rules with convert_to_bookmark on snaps and bookmarks together,
+// so that the bookmarks take the place of the snapshots they were converted from.
+// It returns the bookmarks that are matched by the regex of at least one of these rules but kept by none of them.
+// Bookmarks that no such rule matches, e.g. those created manually with a different name, are never destroyed.
+func (a *args) destroyedBookmarks(snaps []pruning.Snapshot, bookmarks []*pdu.FilesystemVersion) ([]pruning.Snapshot, error) {
+	var rules []pruning.KeepRule
+	var matchers []bookmarkMatcher
+	for i, m := range a.convertToBookmark {
+		if m != nil {
+			rules = append(rules, a.rules[i])
+			matchers = append(matchers, m)
+		}
+	}
+	matched := func(name string) bool {
+		for _, m := range matchers {
+			if m(name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	candidates := make([]pruning.Snapshot, 0, len(snaps)+len(bookmarks))
+	candidates = append(candidates, snaps...)
+	isBookmark := make(map[pruning.Snapshot]bool, len(bookmarks))
+	for _, bm := range bookmarks {
+		if bm.Type != pdu.FilesystemVersion_Bookmark || a.isForeign(bm.Name) || !matched(bm.Name) {
+			continue
+		}
+		creation, err := bm.CreationAsTime()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", bm.RelName(), err)
+		}
+		// only replicated snapshots are converted
+		s := snapshot{replicated: true, date: creation, fsv: bm}
+		candidates = append(candidates, s)
+		isBookmark[s] = true
+	}
+	if len(isBookmark) == 0 {
+		return nil, nil
+	}
+
+	var destroy []pruning.Snapshot
+	for _, d := range pruning.Decide(candidates, rules) {
+		if d.Destroy && isBookmark[d.Snapshot] {
+			destroy = append(destroy, d.Snapshot)
+		}
+	}
+	return destroy, nil
+}
+
+// convertsToBookmark returns true if all rules in keptBy have convert_to_bookmark set.
+func convertsToBookmark(keptBy []int, convertToBookmark []bookmarkMatcher) bool {
+	if len(keptBy) == 0 || len(convertToBookmark) == 0 {
+		return false
+	}
+	for _, r := range keptBy {
+		if convertToBookmark[r] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func doOneAttempt(a *args, u updater) {
+
+	pfss, err := plan(a)
+	if err != nil {
+		u(func(p *Pruner) {
+			p.state = PlanErr
+			p.err = err
+		})
+		return
 	}
 
 	u(func(pruner *Pruner) {
@@ -472,16 +889,30 @@ tfss_loop:
 		pruner.state = Exec
 	})
 
-	for {
-		var pfs *fs
-		u(func(pruner *Pruner) {
-			pfs = pruner.execQueue.Pop()
-		})
-		if pfs == nil {
-			break
-		}
-		doOneAttemptExec(a, u, pfs)
+	// each worker destroys the snapshots of one filesystem at a time,
+	// the destroys within a filesystem are batched by the target
+	concurrency := a.concurrency
+	if concurrency < 1 {
+		concurrency = 1
 	}
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var pfs *fs
+				u(func(pruner *Pruner) {
+					pfs = pruner.execQueue.Pop()
+				})
+				if pfs == nil {
+					return
+				}
+				doOneAttemptExec(a, u, pfs)
+			}
+		}()
+	}
+	wg.Wait()
 
 	var rep *Report
 	{
@@ -512,7 +943,7 @@ tfss_loop:
 // attempts to exec pfs, puts it back into the queue with the result
 func doOneAttemptExec(a *args, u updater, pfs *fs) {
 
-	destroyList := make([]*pdu.FilesystemVersion, len(pfs.destroyList))
+	destroyList := make([]*pdu.FilesystemVersion, len(pfs.destroyList), len(pfs.destroyList)+len(pfs.convertList))
 	for i := range destroyList {
 		destroyList[i] = pfs.destroyList[i].(snapshot).fsv
 		GetLogger(a.ctx).
@@ -520,6 +951,50 @@ func doOneAttemptExec(a *args, u updater, pfs *fs) {
 			WithField("destroy_snap", destroyList[i].Name).
 			Debug("policy destroys snapshot")
 	}
+
+	// snapshots to be converted are only destroyed once their bookmark exists
+	var bookmarkErr error
+	if len(pfs.convertList) > 0 {
+		bt, ok := a.target.(BookmarkTarget)
+		if !ok {
+			bookmarkErr = fmt.Errorf("prune target does not support `convert_to_bookmark`")
+		}
+		for i := 0; ok && i < len(pfs.convertList); i++ {
+			fsv := pfs.convertList[i].(snapshot).fsv
+			GetLogger(a.ctx).
+				WithField("fs", pfs.path).
+				WithField("convert_snap", fsv.Name).
+				Debug("policy converts snapshot to bookmark")
+			if err := bt.BookmarkSnapshot(a.ctx, pfs.path, fsv); err != nil {
+				bookmarkErr = errors.Wrapf(err, "cannot convert %s to bookmark", fsv.RelName())
+				GetLogger(a.ctx).WithField("fs", pfs.path).WithError(bookmarkErr).Error("target could not create bookmark")
+				continue
+			}
+			destroyList = append(destroyList, fsv)
+		}
+	}
+	if len(pfs.bookmarkDestroyList) > 0 && bookmarkErr == nil {
+		// plan only fills bookmarkDestroyList for BookmarkTargets
+		bt := a.target.(BookmarkTarget)
+		bookmarks := make([]*pdu.FilesystemVersion, len(pfs.bookmarkDestroyList))
+		for i := range bookmarks {
+			bookmarks[i] = pfs.bookmarkDestroyList[i].(snapshot).fsv
+			GetLogger(a.ctx).
+				WithField("fs", pfs.path).
+				WithField("destroy_bookmark", bookmarks[i].Name).
+				Debug("policy destroys bookmark")
+		}
+		if err := bt.DestroyBookmarks(a.ctx, pfs.path, bookmarks); err != nil {
+			bookmarkErr = errors.Wrap(err, "cannot destroy bookmarks")
+			GetLogger(a.ctx).WithField("fs", pfs.path).WithError(bookmarkErr).Error("target could not destroy bookmarks")
+		}
+	}
+	if len(pfs.convertList) > 0 && len(destroyList) == 0 {
+		u(func(pruner *Pruner) {
+			pruner.execQueue.Put(pfs, bookmarkErr, bookmarkErr == nil)
+		})
+		return
+	}
 	req := pdu.DestroySnapshotsReq{
 		Filesystem: pfs.path,
 		Snapshots:  destroyList,
@@ -539,15 +1014,30 @@ func doOneAttemptExec(a *args, u updater, pfs *fs) {
 	}
 	err = nil
 	destroyFails := make([]*pdu.DestroySnapshotRes, 0)
+	var pinned map[uint64]bool
 	for _, reqDestroy := range destroyList {
 		res, ok := destroyResults[reqDestroy.Name]
 		if !ok {
 			err = fmt.Errorf("missing destroy-result for %s", reqDestroy.RelName())
 			break
+		} else if res.IsPinned() {
+			// targets without PinTarget only learn about pins here
+			if pinned == nil {
+				pinned = make(map[uint64]bool)
+			}
+			pinned[reqDestroy.GetGuid()] = true
 		} else if res.Error != "" {
 			destroyFails = append(destroyFails, res)
 		}
 	}
+	if len(pinned) > 0 {
+		pfs.mtx.Lock()
+		removed := pfs.removePinned(pinned)
+		pfs.mtx.Unlock()
+		for _, s := range removed {
+			GetLogger(a.ctx).WithField("fs", pfs.path).WithField("snap", s.Name()).Info("target did not destroy pinned snapshot")
+		}
+	}
 	if err == nil && len(destroyFails) > 0 {
 		names := make([]string, len(destroyFails))
 		pairs := make([]string, len(destroyFails))
@@ -566,11 +1056,12 @@ func doOneAttemptExec(a *args, u updater, pfs *fs) {
 			err = fmt.Errorf("destroys failed: %s", strings.Join(pairs, ", "))
 		}
 	}
-	u(func(pruner *Pruner) {
-		pruner.execQueue.Put(pfs, err, err == nil)
-	})
 	if err != nil {
 		GetLogger(a.ctx).WithError(err).Error("target could not destroy snapshots")
-		return
+	} else {
+		err = bookmarkErr
 	}
+	u(func(pruner *Pruner) {
+		pruner.execQueue.Put(pfs, err, err == nil)
+	})
 }
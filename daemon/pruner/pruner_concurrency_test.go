@@ -0,0 +1,156 @@
+package pruner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/pruning"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+// concurrencyTestTarget is both the prune target and its history.
+// Each filesystem has a cursor on its latest snapshot, and snapshot "fail" cannot be destroyed.
+type concurrencyTestTarget struct {
+	snaps map[string][]*pdu.FilesystemVersion
+
+	mtx                   sync.Mutex
+	inFlight, maxInFlight int
+	destroyed             map[string][]string
+}
+
+func newConcurrencyTestTarget(nfs, nsnaps int) *concurrencyTestTarget {
+	t := &concurrencyTestTarget{snaps: make(map[string][]*pdu.FilesystemVersion), destroyed: make(map[string][]string)}
+	creation := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < nfs; i++ {
+		fs := fmt.Sprintf("pool/fs%d", i)
+		for j := 0; j < nsnaps; j++ {
+			name := fmt.Sprintf("zrepl_%03d", j)
+			if i == 0 && j == 0 {
+				name = "fail"
+			}
+			t.snaps[fs] = append(t.snaps[fs], &pdu.FilesystemVersion{
+				Type:      pdu.FilesystemVersion_Snapshot,
+				Name:      name,
+				Guid:      uint64(i*nsnaps + j + 1),
+				CreateTXG: uint64(j + 1),
+				Creation:  creation.Add(time.Duration(j) * time.Minute).Format(time.RFC3339),
+			})
+		}
+	}
+	return t
+}
+
+func (t *concurrencyTestTarget) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error) {
+	res := &pdu.ListFilesystemRes{}
+	for fs := range t.snaps {
+		res.Filesystems = append(res.Filesystems, &pdu.Filesystem{Path: fs})
+	}
+	return res, nil
+}
+
+func (t *concurrencyTestTarget) ListFilesystemVersions(ctx context.Context, req *pdu.ListFilesystemVersionsReq) (*pdu.ListFilesystemVersionsRes, error) {
+	return &pdu.ListFilesystemVersionsRes{Versions: t.snaps[req.Filesystem]}, nil
+}
+
+func (t *concurrencyTestTarget) ReplicationCursor(ctx context.Context, req *pdu.ReplicationCursorReq) (*pdu.ReplicationCursorRes, error) {
+	snaps := t.snaps[req.Filesystem]
+	return &pdu.ReplicationCursorRes{Result: &pdu.ReplicationCursorRes_Guid{Guid: snaps[len(snaps)-1].Guid}}, nil
+}
+
+func (t *concurrencyTestTarget) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error) {
+	t.mtx.Lock()
+	t.inFlight++
+	if t.inFlight > t.maxInFlight {
+		t.maxInFlight = t.inFlight
+	}
+	t.mtx.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	res := &pdu.DestroySnapshotsRes{}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.inFlight--
+	for _, s := range req.Snapshots {
+		r := &pdu.DestroySnapshotRes{Snapshot: s}
+		if s.Name == "fail" {
+			r.Error = "dataset is busy"
+		} else {
+			t.destroyed[req.Filesystem] = append(t.destroyed[req.Filesystem], s.Name)
+		}
+		res.Results = append(res.Results, r)
+	}
+	return res, nil
+}
+
+func TestPrunerConcurrency(t *testing.T) {
+	for _, concurrency := range []int{1, 4} {
+		t.Run(fmt.Sprintf("concurrency=%d", concurrency), func(t *testing.T) {
+			target := newConcurrencyTestTarget(8, 5)
+			keepLast, err := pruning.NewKeepLastN(2, "")
+			require.NoError(t, err)
+			p := &Pruner{
+				args: args{
+					ctx:         context.WithValue(context.Background(), contextKeyPruneSide, "local"),
+					target:      target,
+					receiver:    target,
+					rules:       []pruning.KeepRule{keepLast},
+					concurrency: concurrency,
+				},
+				state: Plan,
+			}
+			p.Prune()
+
+			assert.Equal(t, ExecErr, p.State())
+			assert.True(t, target.maxInFlight <= concurrency)
+			if concurrency > 1 {
+				assert.True(t, target.maxInFlight > 1, "filesystems are pruned in parallel")
+			}
+
+			rep := p.Report()
+			assert.Empty(t, rep.Pending)
+			require.Len(t, rep.Completed, 8)
+			for _, fsr := range rep.Completed {
+				if fsr.Filesystem == "pool/fs0" {
+					// the other snapshots of the batch are destroyed nonetheless
+					assert.Contains(t, fsr.LastError, "fail")
+					assert.Equal(t, []string{"zrepl_001", "zrepl_002"}, target.destroyed[fsr.Filesystem])
+				} else {
+					assert.Empty(t, fsr.LastError)
+					assert.Equal(t, []string{"zrepl_000", "zrepl_001", "zrepl_002"}, target.destroyed[fsr.Filesystem])
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPrunerConcurrency(b *testing.B) {
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			keepLast, err := pruning.NewKeepLastN(1, "")
+			if err != nil {
+				b.Fatal(err)
+			}
+			for i := 0; i < b.N; i++ {
+				target := newConcurrencyTestTarget(32, 100)
+				p := &Pruner{
+					args: args{
+						ctx:         context.WithValue(context.Background(), contextKeyPruneSide, "local"),
+						target:      target,
+						receiver:    target,
+						rules:       []pruning.KeepRule{keepLast},
+						concurrency: concurrency,
+					},
+					state: Plan,
+				}
+				p.Prune()
+			}
+		})
+	}
+}
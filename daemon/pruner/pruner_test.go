@@ -0,0 +1,226 @@
+package pruner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/pruning"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+func TestKeepLastSnapshot(t *testing.T) {
+	snap := func(name string) snapshot {
+		return snapshot{date: time.Now(), fsv: &pdu.FilesystemVersion{Name: name}}
+	}
+	a, b, c := snap("a"), snap("b"), snap("c")
+
+	pfs := &fs{
+		snaps:       []pruning.Snapshot{a, b, c},
+		destroyList: []pruning.Snapshot{a, c},
+		convertList: []pruning.Snapshot{b},
+	}
+	assert.Equal(t, c, pfs.keepLastSnapshot())
+	assert.Equal(t, []pruning.Snapshot{a}, pfs.destroyList)
+	assert.Equal(t, []pruning.Snapshot{b}, pfs.convertList)
+
+	pfs = &fs{
+		snaps:       []pruning.Snapshot{a, b, c},
+		destroyList: []pruning.Snapshot{a, b},
+	}
+	assert.Nil(t, pfs.keepLastSnapshot())
+	assert.Equal(t, []pruning.Snapshot{a, b}, pfs.destroyList)
+
+	pfs = &fs{}
+	assert.Nil(t, pfs.keepLastSnapshot())
+}
+
+func TestRemovePinned(t *testing.T) {
+	snap := func(name string, guid uint64) snapshot {
+		return snapshot{date: time.Now(), fsv: &pdu.FilesystemVersion{Name: name, Guid: guid}}
+	}
+	a, b, c := snap("a", 1), snap("b", 2), snap("c", 3)
+
+	pfs := &fs{
+		snaps:       []pruning.Snapshot{a, b, c},
+		destroyList: []pruning.Snapshot{a, b},
+		convertList: []pruning.Snapshot{c},
+	}
+	removed := pfs.removePinned(map[uint64]bool{2: true, 3: true})
+	assert.Equal(t, []pruning.Snapshot{b, c}, removed)
+	assert.Equal(t, []pruning.Snapshot{a}, pfs.destroyList)
+	assert.Empty(t, pfs.convertList)
+	assert.Equal(t, map[uint64]bool{2: true, 3: true}, pfs.pinned)
+	// pinned snapshots count as kept for the safety net
+	assert.Nil(t, pfs.keepLastSnapshot())
+
+	pfs = &fs{destroyList: []pruning.Snapshot{a}}
+	assert.Empty(t, pfs.removePinned(nil))
+	assert.Equal(t, []pruning.Snapshot{a}, pfs.destroyList)
+	assert.Nil(t, pfs.pinned)
+}
+
+func TestPlanKeepsLastSnapshot(t *testing.T) {
+	target := newConcurrencyTestTarget(2, 3)
+	nothing, err := pruning.NewKeepLastN(1, "^nomatch$")
+	require.NoError(t, err)
+	p := &Pruner{
+		args: args{
+			ctx:         context.WithValue(context.Background(), contextKeyPruneSide, "sender"),
+			target:      target,
+			receiver:    target,
+			rules:       []pruning.KeepRule{nothing},
+			concurrency: 1,
+		},
+		state: Plan,
+	}
+	plans, err := p.Plan()
+	require.NoError(t, err)
+	require.Len(t, plans, 2)
+	for _, plan := range plans {
+		var destroy, keptAsLast []bool
+		for _, s := range plan.Snapshots {
+			assert.Empty(t, s.KeptBy)
+			destroy = append(destroy, s.Destroy)
+			keptAsLast = append(keptAsLast, s.KeptAsLast)
+		}
+		// the allow_destroy_last interlock overrides the decision of the keep rule
+		assert.Equal(t, []bool{true, true, false}, destroy, plan.Filesystem)
+		assert.Equal(t, []bool{false, false, true}, keptAsLast, plan.Filesystem)
+	}
+
+	p.args.allowDestroyLast = true
+	plans, err = p.Plan()
+	require.NoError(t, err)
+	for _, plan := range plans {
+		for _, s := range plan.Snapshots {
+			assert.True(t, s.Destroy, "%s@%s", plan.Filesystem, s.Name)
+		}
+	}
+}
+
+// pinningTestTarget reports snapshot "fail" as pinned, like endpoints do for remote pruners.
+type pinningTestTarget struct {
+	*concurrencyTestTarget
+}
+
+func (t pinningTestTarget) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error) {
+	res := &pdu.DestroySnapshotsRes{}
+	for _, s := range req.Snapshots {
+		r := &pdu.DestroySnapshotRes{Snapshot: s}
+		if s.Name == "fail" {
+			r.Error = pdu.DestroySnapshotResErrorPinned
+		} else {
+			t.destroyed[req.Filesystem] = append(t.destroyed[req.Filesystem], s.Name)
+		}
+		res.Results = append(res.Results, r)
+	}
+	return res, nil
+}
+
+func TestPruneTargetReportsPinnedSnapshot(t *testing.T) {
+	target := pinningTestTarget{newConcurrencyTestTarget(1, 3)}
+	keepLast, err := pruning.NewKeepLastN(1, "")
+	require.NoError(t, err)
+	p := &Pruner{
+		args: args{
+			ctx:         context.WithValue(context.Background(), contextKeyPruneSide, "receiver"),
+			target:      target,
+			receiver:    target,
+			rules:       []pruning.KeepRule{keepLast},
+			concurrency: 1,
+		},
+		state: Plan,
+	}
+	p.Prune()
+
+	assert.Equal(t, Done, p.State())
+	rep := p.Report()
+	require.Len(t, rep.Completed, 1)
+	fsr := rep.Completed[0]
+	assert.Empty(t, fsr.LastError)
+	assert.Equal(t, []string{"zrepl_001"}, target.destroyed["pool/fs0"])
+	require.Len(t, fsr.DestroyList, 1)
+	assert.Equal(t, "zrepl_001", fsr.DestroyList[0].Name)
+}
+
+func TestDestroyedBookmarks(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	version := func(typ pdu.FilesystemVersion_VersionType, name string, hours int) *pdu.FilesystemVersion {
+		return &pdu.FilesystemVersion{Type: typ, Name: name, Creation: base.Add(time.Duration(hours) * time.Hour).Format(time.RFC3339)}
+	}
+	newest := version(pdu.FilesystemVersion_Snapshot, "zrepl_4", 4)
+	a := &args{
+		rules: []pruning.KeepRule{
+			pruning.MustKeepLastN(2, "^zrepl_"),
+			pruning.MustKeepLastN(1, ""),
+		},
+	}
+	a.convertToBookmark = convertToBookmarkFromConfig([]config.PruningEnum{
+		{Ret: &config.PruneKeepLastN{Count: 2, Regex: "^zrepl_", ConvertToBookmark: true}},
+		{Ret: &config.PruneKeepLastN{Count: 1}},
+	})
+	destroy, err := a.destroyedBookmarks(
+		[]pruning.Snapshot{snapshot{date: base.Add(4 * time.Hour), fsv: newest}},
+		[]*pdu.FilesystemVersion{
+			version(pdu.FilesystemVersion_Bookmark, "zrepl_1", 1),
+			version(pdu.FilesystemVersion_Bookmark, "zrepl_2", 2),
+			version(pdu.FilesystemVersion_Bookmark, "zrepl_3", 3),
+			version(pdu.FilesystemVersion_Bookmark, "manual", 0),
+		})
+	require.NoError(t, err)
+	var names []string
+	for _, s := range destroy {
+		names = append(names, s.Name())
+	}
+	// the snapshot and the latest bookmark fill the rule, the bookmark that doesn't match its regex is never destroyed
+	assert.ElementsMatch(t, []string{"zrepl_1", "zrepl_2"}, names)
+
+	a.convertToBookmark = convertToBookmarkFromConfig([]config.PruningEnum{
+		{Ret: &config.PruneKeepLastN{Count: 2, Regex: "^zrepl_"}},
+		{Ret: &config.PruneKeepLastN{Count: 1}},
+	})
+	assert.False(t, a.convertsToBookmarks())
+}
+
+func TestProtectForeignSnapshots(t *testing.T) {
+	_, err := NewPrunerFactory(config.PruningSenderReceiver{Concurrency: 1, ProtectForeignSnapshots: true}, nil)
+	assert.Error(t, err, "cannot classify snapshots without managed prefixes")
+	_, err = NewPrunerFactory(config.PruningSenderReceiver{Concurrency: 1, ProtectForeignSnapshots: true, ManagedPrefixes: []string{""}}, nil)
+	assert.Error(t, err)
+	f, err := NewPrunerFactory(config.PruningSenderReceiver{Concurrency: 1, ManagedPrefixes: []string{"zrepl_"}}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, f.receiverManagedPrefixes, "managed_prefixes has no effect without protect_foreign_snapshots")
+
+	// snapshot "fail" of pool/fs0 is foreign
+	target := newConcurrencyTestTarget(1, 5)
+	keepLast, err := pruning.NewKeepLastN(2, "")
+	require.NoError(t, err)
+	p := &Pruner{
+		args: args{
+			ctx:             context.WithValue(context.Background(), contextKeyPruneSide, "receiver"),
+			target:          target,
+			receiver:        target,
+			rules:           []pruning.KeepRule{keepLast},
+			concurrency:     1,
+			managedPrefixes: []string{"other_", "zrepl_"},
+		},
+		state: Plan,
+	}
+	plans, err := p.Plan()
+	require.NoError(t, err)
+	require.Len(t, plans, 1)
+	var names, destroy []string
+	for _, s := range plans[0].Snapshots {
+		names = append(names, s.Name)
+		if s.Destroy {
+			destroy = append(destroy, s.Name)
+		}
+	}
+	assert.Equal(t, []string{"zrepl_001", "zrepl_002", "zrepl_003", "zrepl_004"}, names)
+	assert.Equal(t, []string{"zrepl_001", "zrepl_002"}, destroy)
+}
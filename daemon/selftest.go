@@ -0,0 +1,146 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// selfTest checks on startup that the zfs binary can be used for the jobs in conf, so that
+// misconfigurations are reported immediately instead of on the first snapshot or replication.
+// It logs the detected ZFS version and missing optional features, and returns an error
+// listing all problems that would make jobs fail.
+func selfTest(ctx context.Context, conf *config.Config, log logger.Logger) error {
+	if _, err := exec.LookPath(zfs.ZFS_BINARY); err != nil {
+		return errors.Errorf("zfs binary %q is not executable or not in the daemon's $PATH (%q): %s", zfs.ZFS_BINARY, os.Getenv("PATH"), err)
+	}
+
+	if v, err := zfs.ZFSGetVersion(ctx); err != nil {
+		log.WithError(err).Warn("cannot detect ZFS version")
+	} else {
+		log.WithField("version", v.String()).Info("detected ZFS version")
+	}
+
+	var problems []string
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	resumeSend, err := zfs.ResumeSendSupported(ctx)
+	if err != nil {
+		problemf("%s", err)
+	}
+	resumeRecv, err := zfs.ResumeRecvSupported(ctx, nil)
+	if err != nil {
+		problemf("%s", err)
+	}
+	if !resumeSend || !resumeRecv {
+		log.Warn("zfs binary does not support resumable send & recv, interrupted replications will restart from the beginning")
+	}
+	encryption, err := zfs.EncryptionCLISupported(ctx)
+	if err != nil {
+		problemf("%s", err)
+	}
+
+	checkSnapshotPerm, username, groups := selfTestDelegationCheck(log)
+
+	for _, jobConf := range conf.Jobs {
+		var fsfConf config.FilesystemsFilter
+		var snapshotting config.SnapshottingEnum
+		var sendOptions []*config.SendOptions
+		switch c := jobConf.Ret.(type) {
+		case *config.PushJob:
+			fsfConf, snapshotting = c.Filesystems, c.Snapshotting
+			sendOptions = append(sendOptions, c.Send)
+			for _, o := range c.SendOverrides {
+				sendOptions = append(sendOptions, o.Send)
+			}
+		case *config.SourceJob:
+			fsfConf, snapshotting = c.Filesystems, c.Snapshotting
+			sendOptions = append(sendOptions, c.Send)
+			for _, o := range c.SendOverrides {
+				sendOptions = append(sendOptions, o.Send)
+			}
+		case *config.SnapJob:
+			fsfConf, snapshotting = c.Filesystems, c.Snapshotting
+		default:
+			continue
+		}
+
+		for _, o := range sendOptions {
+			if o != nil && (o.Encrypted || o.Raw) && !encryption {
+				problemf("job %q: uses encrypted or raw send but the zfs binary does not support native encryption (requires OpenZFS 0.8 or newer)", jobConf.Name())
+				break
+			}
+		}
+
+		fsf, err := filters.DatasetMapFilterFromConfig(fsfConf)
+		if err != nil {
+			problemf("job %q: cannot build filesystem filter: %s", jobConf.Name(), err)
+			continue
+		}
+		fss, err := zfs.ZFSListMapping(ctx, fsf)
+		if err != nil {
+			problemf("job %q: cannot list filesystems, make sure the daemon user can run `zfs list`: %s", jobConf.Name(), err)
+			continue
+		}
+
+		if _, ok := snapshotting.Ret.(*config.SnapshottingPeriodic); !ok || !checkSnapshotPerm {
+			continue
+		}
+		var denied []string
+		for _, fs := range fss {
+			perms, err := zfs.ZFSGetAllowedPermissions(ctx, fs.ToString())
+			if err != nil {
+				problemf("job %q: %s", jobConf.Name(), err)
+				break
+			}
+			if !perms.Allows(username, groups, "snapshot") {
+				denied = append(denied, fs.ToString())
+			}
+		}
+		if len(denied) > 0 {
+			problemf("job %q: user %q is not allowed to create snapshots of %s, delegate the permission with `zfs allow %s snapshot FILESYSTEM`",
+				jobConf.Name(), username, strings.Join(denied, ", "), username)
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// selfTestDelegationCheck returns whether the permissions delegated with `zfs allow`
+// must be checked, and if so, the name and the groups of the daemon user.
+func selfTestDelegationCheck(log logger.Logger) (check bool, username string, groups []string) {
+	if os.Geteuid() == 0 {
+		return false, "", nil
+	}
+	u, err := user.Current()
+	if err != nil {
+		log.WithError(err).Warn("cannot determine daemon user, not checking delegated zfs permissions")
+		return false, "", nil
+	}
+	gids, err := u.GroupIds()
+	if err != nil {
+		log.WithError(err).Warn("cannot determine groups of daemon user, not checking delegated zfs permissions")
+		return false, "", nil
+	}
+	for _, gid := range gids {
+		if g, err := user.LookupGroupId(gid); err == nil {
+			groups = append(groups, g.Name)
+		}
+	}
+	return true, u.Username, groups
+}
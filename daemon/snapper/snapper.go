@@ -13,6 +13,7 @@ import (
 
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/pause"
 	"github.com/zrepl/zrepl/daemon/logging"
 	"github.com/zrepl/zrepl/logger"
 	"github.com/zrepl/zrepl/util/envconst"
@@ -27,13 +28,15 @@ const (
 	SnapStarted
 	SnapDone
 	SnapError
+	SnapSkipped
+	SnapDestroyed
 )
 
 // All fields protected by Snapper.mtx
 type snapProgress struct {
 	state SnapState
 
-	// SnapStarted, SnapDone, SnapError
+	// SnapStarted, SnapDone, SnapError, SnapDestroyed, SnapSkipped (latest existing snapshot)
 	name     string
 	startAt  time.Time
 	hookPlan *hooks.Plan
@@ -46,13 +49,26 @@ type snapProgress struct {
 }
 
 type args struct {
-	ctx            context.Context
-	prefix         string
-	interval       time.Duration
-	fsf            zfs.DatasetFilter
-	snapshotsTaken chan<- struct{}
-	hooks          *hooks.List
-	dryRun         bool
+	ctx             context.Context
+	prefix          string
+	formatTimestamp timestampFormatter
+	interval        time.Duration
+	fsf             zfs.DatasetFilter
+	snapshotsTaken  chan<- struct{}
+	hooks           *hooks.List
+	minFreeSpace    *zfs.MinFreeSpace // nil means no check
+	userProperties  userProperties
+	skipIfUnchanged bool
+	batchSize       int
+	batchDelay      time.Duration
+	skipDestroyed   bool
+	catchUp         bool
+	startupDelay    time.Duration
+	dryRun          bool
+	// see Snapper.Defer
+	deferred bool
+	// see Snapper.Limit, nil means unlimited
+	limiter Limiter
 }
 
 type Snapper struct {
@@ -72,6 +88,9 @@ type Snapper struct {
 
 	// valid for state Err
 	err error
+
+	// set when snapshots became due while deferred, cleared by SnapshotDue
+	due bool
 }
 
 //go:generate stringer -type=State
@@ -109,7 +128,7 @@ func getLogger(ctx context.Context) Logger {
 	return logging.GetLogger(ctx, logging.SubsysSnapshot)
 }
 
-func PeriodicFromConfig(g *config.Global, fsf zfs.DatasetFilter, in *config.SnapshottingPeriodic) (*Snapper, error) {
+func PeriodicFromConfig(g *config.Global, jobName string, fsf zfs.DatasetFilter, in *config.SnapshottingPeriodic, minFreeSpace *zfs.MinFreeSpace) (*Snapper, error) {
 	if in.Prefix == "" {
 		return nil, errors.New("prefix must not be empty")
 	}
@@ -117,18 +136,46 @@ func PeriodicFromConfig(g *config.Global, fsf zfs.DatasetFilter, in *config.Snap
 		return nil, errors.New("interval must be positive")
 	}
 
+	formatTimestamp, err := timestampFormatterFromConfig(in.TimestampFormat)
+	if err != nil {
+		return nil, err
+	}
+	if err := zfs.SnapshotNameCheck(in.Prefix + formatTimestamp(time.Now())); err != nil {
+		return nil, errors.Wrap(err, "prefix and timestamp_format produce invalid snapshot names")
+	}
+
+	userProps, err := userPropertiesFromConfig(jobName, in.UserProperties)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `user_properties`")
+	}
+
+	if _, err := batchThrottleFromConfig(in.BatchSize, in.BatchDelay); err != nil {
+		return nil, err
+	}
+
 	hookList, err := hooks.ListFromConfig(&in.Hooks)
 	if err != nil {
 		return nil, errors.Wrap(err, "hook config error")
 	}
 
 	args := args{
-		prefix:   in.Prefix,
-		interval: in.Interval,
-		fsf:      fsf,
-		hooks:    hookList,
+		prefix:          in.Prefix,
+		formatTimestamp: formatTimestamp,
+		interval:        in.Interval,
+		fsf:             fsf,
+		hooks:           hookList,
+		minFreeSpace:    minFreeSpace,
+		userProperties:  userProps,
+		skipIfUnchanged: in.SkipIfUnchanged,
+		batchSize:       in.BatchSize,
+		batchDelay:      in.BatchDelay,
+		skipDestroyed:   in.SkipDestroyedFilesystems,
+		catchUp:         in.CatchUp,
 		// ctx and log is set in Run()
 	}
+	if g != nil {
+		args.startupDelay = g.StartupDelay
+	}
 
 	return &Snapper{state: SyncUp, args: args}, nil
 }
@@ -165,6 +212,103 @@ func (s *Snapper) Run(ctx context.Context, snapshotsTaken chan<- struct{}) {
 
 }
 
+// SnapshotNow takes snapshots of all matched filesystems out of band, including hooks,
+// and blocks until they have been taken.
+// It does not affect the periodic schedule and does not notify the callback channel
+// passed to Run.
+// Snapshots are taken even if skip_if_unchanged is configured because they were requested explicitly.
+func (s *Snapper) SnapshotNow(ctx context.Context) error {
+	_, err := s.snapshotOutOfBand(ctx, false)
+	return err
+}
+
+// SnapshotDue takes the snapshots that became due since the last call if the snapper is deferred,
+// and is a no-op otherwise, see Defer.
+// Unlike SnapshotNow, skip_if_unchanged applies because the snapshots are part of the schedule.
+func (s *Snapper) SnapshotDue(ctx context.Context) error {
+	s.mtx.Lock()
+	due := s.due
+	s.due = false
+	s.mtx.Unlock()
+	if !due {
+		return nil
+	}
+	oneOff, err := s.snapshotOutOfBand(ctx, s.args.skipIfUnchanged)
+	if oneOff != nil {
+		// make the round visible in the report
+		s.mtx.Lock()
+		s.plan = oneOff.plan
+		s.err = oneOff.err
+		s.mtx.Unlock()
+	}
+	return err
+}
+
+// Defer makes the snapper wake up the job when snapshots are due instead of taking them,
+// the job takes them through SnapshotDue.
+// Must be called before Run.
+func (s *Snapper) Defer() {
+	s.args.deferred = true
+}
+
+// Limiter limits the number of jobs that take periodic snapshots concurrently, see Snapper.Limit.
+type Limiter interface {
+	// Acquire blocks until snapshots may be taken or ctx is done.
+	// onQueued is called before blocking if snapshots have to wait for other jobs.
+	// The returned func must be called once the snapshots have been taken.
+	Acquire(ctx context.Context, onQueued func()) (release func(), err error)
+}
+
+// Limit makes the periodic schedule acquire l before taking snapshots.
+// Snapshots taken through SnapshotNow and SnapshotDue are not limited because
+// the job takes them within its invocation, which is limited already.
+// Must be called before Run.
+func (s *Snapper) Limit(l Limiter) {
+	s.args.limiter = l
+}
+
+// snapshotOutOfBand takes snapshots of all matched filesystems in a one-off snapper
+// that does not share state with the periodic schedule.
+// The returned snapper is nil if the filesystems could not be listed.
+func (s *Snapper) snapshotOutOfBand(ctx context.Context, skipIfUnchanged bool) (*Snapper, error) {
+	a := args{
+		ctx:             ctx,
+		prefix:          s.args.prefix,
+		formatTimestamp: s.args.formatTimestamp,
+		interval:        s.args.interval,
+		fsf:             s.args.fsf,
+		hooks:           s.args.hooks,
+		minFreeSpace:    s.args.minFreeSpace,
+		userProperties:  s.args.userProperties,
+		skipIfUnchanged: skipIfUnchanged,
+		batchSize:       s.args.batchSize,
+		batchDelay:      s.args.batchDelay,
+		skipDestroyed:   s.args.skipDestroyed,
+		dryRun:          s.args.dryRun,
+		// snapshotsTaken: nil
+	}
+	fss, err := listFSes(ctx, a.fsf)
+	if err != nil {
+		return nil, err
+	}
+	oneOff := &Snapper{args: a, state: Snapshotting, plan: make(map[*zfs.DatasetPath]*snapProgress, len(fss))}
+	for _, fs := range fss {
+		oneOff.plan[fs] = &snapProgress{state: SnapPending}
+	}
+	u := func(f func(*Snapper)) State {
+		oneOff.mtx.Lock()
+		defer oneOff.mtx.Unlock()
+		if f != nil {
+			f(oneOff)
+		}
+		return oneOff.state
+	}
+	snapshot(a, u)
+	oneOff.mtx.Lock()
+	defer oneOff.mtx.Unlock()
+	return oneOff, oneOff.err
+}
+
 func onErr(err error, u updater) state {
 	return u(func(s *Snapper) {
 		s.err = err
@@ -189,6 +333,8 @@ func onMainCtxDone(ctx context.Context, u updater) state {
 }
 
 func syncUp(a args, u updater) state {
+	// syncUp only runs when the job starts
+	notBefore := time.Now().Add(a.startupDelay)
 	u(func(snapper *Snapper) {
 		snapper.lastInvocation = time.Now()
 	})
@@ -196,10 +342,15 @@ func syncUp(a args, u updater) state {
 	if err != nil {
 		return onErr(err, u)
 	}
-	syncPoint, err := findSyncPoint(a.ctx, fss, a.prefix, a.interval)
+	syncPoint, err := findSyncPoint(a.ctx, fss, a.prefix, a.interval, a.catchUp)
 	if err != nil {
 		return onErr(err, u)
 	}
+	if syncPoint.Before(notBefore) {
+		getLogger(a.ctx).WithField("startup_delay", a.startupDelay).WithField("syncPoint", notBefore).
+			Info("postponing first snapshots until global.startup_delay has elapsed")
+		syncPoint = notBefore
+	}
 	u(func(s *Snapper) {
 		s.sleepUntil = syncPoint
 	})
@@ -219,6 +370,22 @@ func plan(a args, u updater) state {
 	u(func(snapper *Snapper) {
 		snapper.lastInvocation = time.Now()
 	})
+	if pause.IsPaused(a.ctx) {
+		getLogger(a.ctx).Info("job is paused, skipping snapshots")
+		return u(func(s *Snapper) {
+			s.state = Waiting
+		}).sf()
+	}
+	if a.deferred {
+		getLogger(a.ctx).Debug("snapshots are due, waking up job to take them after replication")
+		u(func(s *Snapper) {
+			s.due = true
+		})
+		notifySnapshotsTaken(a)
+		return u(func(s *Snapper) {
+			s.state = Waiting
+		}).sf()
+	}
 	fss, err := listFSes(a.ctx, a.fsf)
 	if err != nil {
 		return onErr(err, u)
@@ -237,6 +404,16 @@ func plan(a args, u updater) state {
 
 func snapshot(a args, u updater) state {
 
+	if a.limiter != nil {
+		release, err := a.limiter.Acquire(a.ctx, func() {
+			getLogger(a.ctx).Info("max_concurrent_jobs reached, waiting for other jobs before taking snapshots")
+		})
+		if err != nil {
+			return onMainCtxDone(a.ctx, u)
+		}
+		defer release()
+	}
+
 	var plan map[*zfs.DatasetPath]*snapProgress
 	u(func(snapper *Snapper) {
 		plan = snapper.plan
@@ -247,10 +424,16 @@ func snapshot(a args, u updater) state {
 		hookMatchCount[h] = 0
 	}
 
+	throttle := batchThrottle{size: a.batchSize, delay: a.batchDelay} // validated in PeriodicFromConfig
+
 	anyFsHadErr := false
 	// TODO channel programs -> allow a little jitter?
 	for fs, progress := range plan {
-		suffix := time.Now().In(time.UTC).Format("20060102_150405_000")
+		if err := throttle.wait(a.ctx); err != nil {
+			return onMainCtxDone(a.ctx, u)
+		}
+
+		suffix := a.formatTimestamp(time.Now())
 		snapname := fmt.Sprintf("%s%s", a.prefix, suffix)
 
 		ctx := logging.WithInjectedField(a.ctx, "fs", fs.ToString())
@@ -261,11 +444,15 @@ func snapshot(a args, u updater) state {
 			hooks.EnvSnapshot: snapname,
 		}
 
+		fsDestroyed := false
 		jobCallback := hooks.NewCallbackHookForFilesystem("snapshot", fs, func(ctx context.Context) (err error) {
 			l := getLogger(ctx)
 			l.Debug("create snapshot")
-			err = zfs.ZFSSnapshot(ctx, fs, snapname, false) // TODO propagate context to ZFSSnapshot
-			if err != nil {
+			err = zfs.ZFSSnapshot(ctx, fs, snapname, false, a.userProperties.expand(suffix)) // TODO propagate context to ZFSSnapshot
+			if _, ok := err.(*zfs.DatasetDoesNotExist); ok && a.skipDestroyed {
+				fsDestroyed = true
+				l.WithError(err).Warn("filesystem was destroyed before it could be snapshotted, skipping")
+			} else if err != nil {
 				l.WithError(err).Error("cannot create snapshot")
 			}
 			return
@@ -274,6 +461,11 @@ func snapshot(a args, u updater) state {
 		fsHadErr := false
 		var planReport hooks.PlanReport
 		var plan *hooks.Plan
+		if err := zfs.CheckPoolFreeSpace(ctx, fs.ToString(), a.minFreeSpace); err != nil {
+			getLogger(ctx).WithError(err).Error("skipping snapshot")
+			fsHadErr = true
+			goto updateFSState
+		}
 		{
 			filteredHooks, err := a.hooks.CopyFilteredForFilesystem(fs)
 			if err != nil {
@@ -285,6 +477,21 @@ func snapshot(a args, u updater) state {
 			for _, h := range filteredHooks {
 				hookMatchCount[h] = hookMatchCount[h] + 1
 			}
+			if a.skipIfUnchanged {
+				latest, unchanged, err := unchangedSinceLatestSnapshot(ctx, fs, a.prefix)
+				if err != nil {
+					getLogger(ctx).WithError(err).Warn("cannot determine whether filesystem changed since latest snapshot, taking snapshot")
+				} else if unchanged {
+					getLogger(ctx).WithField("latest", latest).Info("skipping snapshot: filesystem unchanged since latest snapshot")
+					u(func(snapper *Snapper) {
+						progress.name = latest
+						progress.startAt = time.Now()
+						progress.doneAt = progress.startAt
+						progress.state = SnapSkipped
+					})
+					continue
+				}
+			}
 
 			var planErr error
 			plan, planErr = hooks.NewPlan(&filteredHooks, hooks.PhaseSnapshot, jobCallback, hookEnvExtra)
@@ -305,7 +512,11 @@ func snapshot(a args, u updater) state {
 			plan.Run(ctx, a.dryRun)
 			planReport = plan.Report()
 			fsHadErr = planReport.HadError() // not just fatal errors
-			if fsHadErr {
+			if fsDestroyed {
+				// hooks that failed because the filesystem is gone are not an error either
+				fsHadErr = false
+				getLogger(ctx).WithField("report", planReport.String()).Info("end run job plan, filesystem was destroyed")
+			} else if fsHadErr {
 				getLogger(ctx).WithField("report", planReport.String()).Error("end run job plan with error")
 			} else {
 				getLogger(ctx).WithField("report", planReport.String()).Info("end run job plan successful")
@@ -319,18 +530,14 @@ func snapshot(a args, u updater) state {
 			progress.state = SnapDone
 			if fsHadErr {
 				progress.state = SnapError
+			} else if fsDestroyed {
+				progress.state = SnapDestroyed
 			}
 			progress.runResults = planReport
 		})
 	}
 
-	select {
-	case a.snapshotsTaken <- struct{}{}:
-	default:
-		if a.snapshotsTaken != nil {
-			getLogger(a.ctx).Warn("callback channel is full, discarding snapshot update event")
-		}
-	}
+	notifySnapshotsTaken(a)
 
 	for h, mc := range hookMatchCount {
 		if mc == 0 {
@@ -356,6 +563,16 @@ func snapshot(a args, u updater) state {
 	}).sf()
 }
 
+func notifySnapshotsTaken(a args) {
+	select {
+	case a.snapshotsTaken <- struct{}{}:
+	default:
+		if a.snapshotsTaken != nil {
+			getLogger(a.ctx).Warn("callback channel is full, discarding snapshot update event")
+		}
+	}
+}
+
 func wait(a args, u updater) state {
 	var sleepUntil time.Time
 	u(func(snapper *Snapper) {
@@ -390,7 +607,7 @@ func listFSes(ctx context.Context, mf zfs.DatasetFilter) (fss []*zfs.DatasetPath
 var syncUpWarnNoSnapshotUntilSyncupMinDuration = envconst.Duration("ZREPL_SNAPPER_SYNCUP_WARN_MIN_DURATION", 1*time.Second)
 
 // see docs/snapshotting.rst
-func findSyncPoint(ctx context.Context, fss []*zfs.DatasetPath, prefix string, interval time.Duration) (syncPoint time.Time, err error) {
+func findSyncPoint(ctx context.Context, fss []*zfs.DatasetPath, prefix string, interval time.Duration, catchUp bool) (syncPoint time.Time, err error) {
 
 	const (
 		prioHasVersions int = iota
@@ -415,7 +632,7 @@ func findSyncPoint(ctx context.Context, fss []*zfs.DatasetPath, prefix string, i
 	getLogger(ctx).Debug("examine filesystem state to find sync point")
 	for _, d := range fss {
 		ctx := logging.WithInjectedField(ctx, "fs", d.ToString())
-		syncPoint, err := findSyncPointFSNextOptimalSnapshotTime(ctx, now, interval, prefix, d)
+		syncPoint, err := findSyncPointFSNextOptimalSnapshotTime(ctx, now, interval, catchUp, prefix, d)
 		if err == findSyncPointFSNoFilesystemVersionsErr {
 			snaptimes = append(snaptimes, snapTime{
 				ds:   d,
@@ -469,7 +686,7 @@ func findSyncPoint(ctx context.Context, fss []*zfs.DatasetPath, prefix string, i
 
 var findSyncPointFSNoFilesystemVersionsErr = fmt.Errorf("no filesystem versions")
 
-func findSyncPointFSNextOptimalSnapshotTime(ctx context.Context, now time.Time, interval time.Duration, prefix string, d *zfs.DatasetPath) (time.Time, error) {
+func findSyncPointFSNextOptimalSnapshotTime(ctx context.Context, now time.Time, interval time.Duration, catchUp bool, prefix string, d *zfs.DatasetPath) (time.Time, error) {
 
 	fsvs, err := zfs.ZFSListFilesystemVersions(ctx, d, zfs.ListFilesystemVersionsOptions{
 		Types:           zfs.Snapshots,
@@ -495,5 +712,5 @@ func findSyncPointFSNextOptimalSnapshotTime(ctx context.Context, now time.Time,
 		return time.Time{}, fmt.Errorf("snapshot %q is from the future: creation=%q now=%q", latest.ToAbsPath(d), latest.Creation, now)
 	}
 
-	return latest.Creation.Add(interval), nil
+	return nextSnapshotTime(latest.Creation, now, interval, catchUp), nil
 }
@@ -24,6 +24,40 @@ func (s *PeriodicOrManual) Run(ctx context.Context, wakeUpCommon chan<- struct{}
 	}
 }
 
+// SnapshotNow takes snapshots out of band, see Snapper.SnapshotNow.
+// It is a no-op if manual.
+func (s *PeriodicOrManual) SnapshotNow(ctx context.Context) error {
+	if s.s != nil {
+		return s.s.SnapshotNow(ctx)
+	}
+	return nil
+}
+
+// SnapshotDue takes the snapshots that are due, see Snapper.SnapshotDue.
+// It is a no-op if manual.
+func (s *PeriodicOrManual) SnapshotDue(ctx context.Context) error {
+	if s.s != nil {
+		return s.s.SnapshotDue(ctx)
+	}
+	return nil
+}
+
+// Defer defers periodic snapshots to the job, see Snapper.Defer.
+// It is a no-op if manual.
+func (s *PeriodicOrManual) Defer() {
+	if s.s != nil {
+		s.s.Defer()
+	}
+}
+
+// Limit limits periodic snapshotting, see Snapper.Limit.
+// It is a no-op if manual.
+func (s *PeriodicOrManual) Limit(l Limiter) {
+	if s.s != nil {
+		s.s.Limit(l)
+	}
+}
+
 // Returns nil if manual
 func (s *PeriodicOrManual) Report() *Report {
 	if s.s != nil {
@@ -32,10 +66,10 @@ func (s *PeriodicOrManual) Report() *Report {
 	return nil
 }
 
-func FromConfig(g *config.Global, fsf zfs.DatasetFilter, in config.SnapshottingEnum) (*PeriodicOrManual, error) {
+func FromConfig(g *config.Global, jobName string, fsf zfs.DatasetFilter, in config.SnapshottingEnum, minFreeSpace *zfs.MinFreeSpace) (*PeriodicOrManual, error) {
 	switch v := in.Ret.(type) {
 	case *config.SnapshottingPeriodic:
-		snapper, err := PeriodicFromConfig(g, fsf, v)
+		snapper, err := PeriodicFromConfig(g, jobName, fsf, v, minFreeSpace)
 		if err != nil {
 			return nil, err
 		}
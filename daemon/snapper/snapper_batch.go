@@ -0,0 +1,48 @@
+package snapper
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// batchThrottle pauses between batches of snapshots within one snapshotting run
+// to spread the load of many `zfs snapshot` invocations over time.
+type batchThrottle struct {
+	size  int // 0 means no throttling
+	delay time.Duration
+	taken int
+}
+
+func batchThrottleFromConfig(size int, delay time.Duration) (batchThrottle, error) {
+	if size < 0 {
+		return batchThrottle{}, errors.New("batch_size must not be negative")
+	}
+	if delay < 0 {
+		return batchThrottle{}, errors.New("batch_delay must not be negative")
+	}
+	return batchThrottle{size: size, delay: delay}, nil
+}
+
+// wait must be called before taking each snapshot.
+// It blocks for the configured delay if a batch is complete.
+func (b *batchThrottle) wait(ctx context.Context) error {
+	defer func() { b.taken++ }()
+	if b.size == 0 || b.taken == 0 || b.taken%b.size != 0 {
+		return nil
+	}
+	getLogger(ctx).
+		WithField("batch_size", b.size).
+		WithField("batch_delay", b.delay).
+		WithField("taken", b.taken).
+		Debug("batch of snapshots complete, pausing")
+	t := time.NewTimer(b.delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
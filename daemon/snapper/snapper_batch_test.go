@@ -0,0 +1,51 @@
+package snapper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchThrottleFromConfig(t *testing.T) {
+	_, err := batchThrottleFromConfig(0, time.Second)
+	assert.NoError(t, err)
+	_, err = batchThrottleFromConfig(10, 0)
+	assert.NoError(t, err)
+	_, err = batchThrottleFromConfig(-1, time.Second)
+	assert.Error(t, err)
+	_, err = batchThrottleFromConfig(10, -time.Second)
+	assert.Error(t, err)
+}
+
+func TestBatchThrottleWait(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	ctx := context.Background()
+
+	b, err := batchThrottleFromConfig(2, delay)
+	require.NoError(t, err)
+	var paused []bool
+	for i := 0; i < 5; i++ {
+		begin := time.Now()
+		require.NoError(t, b.wait(ctx))
+		paused = append(paused, time.Since(begin) >= delay)
+	}
+	assert.Equal(t, []bool{false, false, true, false, true}, paused)
+
+	unthrottled, err := batchThrottleFromConfig(0, time.Hour)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, unthrottled.wait(ctx))
+	}
+}
+
+func TestBatchThrottleWaitCanceled(t *testing.T) {
+	b, err := batchThrottleFromConfig(1, time.Hour)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, b.wait(ctx))
+	cancel()
+	assert.Equal(t, context.Canceled, b.wait(ctx))
+}
@@ -0,0 +1,17 @@
+package snapper
+
+import "time"
+
+// nextSnapshotTime returns when the filesystem whose latest snapshot was taken at latest
+// is due for its next snapshot.
+// With catchUp, an overdue snapshot is due immediately (i.e., at a time <= now).
+// Without catchUp, overdue snapshots are skipped and the next snapshot is aligned
+// to the next multiple of interval after latest.
+func nextSnapshotTime(latest, now time.Time, interval time.Duration, catchUp bool) time.Time {
+	next := latest.Add(interval)
+	if catchUp || !next.Before(now) {
+		return next
+	}
+	elapsed := now.Sub(latest) / interval
+	return latest.Add((elapsed + 1) * interval)
+}
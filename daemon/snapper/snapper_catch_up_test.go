@@ -0,0 +1,24 @@
+package snapper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextSnapshotTime(t *testing.T) {
+	latest := time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return latest.Add(d) }
+	const interval = 10 * time.Minute
+
+	// not overdue: catch_up does not matter
+	assert.Equal(t, at(10*time.Minute), nextSnapshotTime(latest, at(5*time.Minute), interval, true))
+	assert.Equal(t, at(10*time.Minute), nextSnapshotTime(latest, at(5*time.Minute), interval, false))
+	assert.Equal(t, at(10*time.Minute), nextSnapshotTime(latest, at(10*time.Minute), interval, false))
+
+	// overdue
+	assert.Equal(t, at(10*time.Minute), nextSnapshotTime(latest, at(95*time.Minute), interval, true))
+	assert.Equal(t, at(100*time.Minute), nextSnapshotTime(latest, at(95*time.Minute), interval, false))
+	assert.Equal(t, at(110*time.Minute), nextSnapshotTime(latest, at(100*time.Minute), interval, false))
+}
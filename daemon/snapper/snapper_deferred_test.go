@@ -0,0 +1,40 @@
+package snapper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanDeferredWakesUpJob(t *testing.T) {
+	ctx := context.Background()
+	wakeUp := make(chan struct{}, 1)
+	s := &Snapper{state: Planning}
+	s.Defer()
+	s.args.ctx = ctx
+	s.args.snapshotsTaken = wakeUp
+	u := func(f func(*Snapper)) State {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		if f != nil {
+			f(s)
+		}
+		return s.state
+	}
+
+	plan(s.args, u)
+
+	assert.Equal(t, Waiting, s.state)
+	assert.True(t, s.due)
+	assert.Empty(t, s.plan, "snapshots must not be planned until the job takes them")
+	require.Len(t, wakeUp, 1)
+}
+
+func TestSnapshotDueNoopUnlessDue(t *testing.T) {
+	s := &Snapper{state: Waiting}
+	s.Defer()
+	// would fail listing filesystems if it tried to snapshot
+	assert.NoError(t, s.SnapshotDue(context.Background()))
+}
@@ -0,0 +1,64 @@
+package snapper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+type testLimiter struct {
+	err                error
+	acquired, released int
+}
+
+func (l *testLimiter) Acquire(ctx context.Context, onQueued func()) (func(), error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	l.acquired++
+	return func() { l.released++ }, nil
+}
+
+func testSnapshottingSnapper(l Limiter) (*Snapper, updater) {
+	s := &Snapper{state: Snapshotting, plan: map[*zfs.DatasetPath]*snapProgress{}}
+	s.Limit(l)
+	s.args.ctx = context.Background()
+	s.args.hooks = &hooks.List{}
+	u := func(f func(*Snapper)) State {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		if f != nil {
+			f(s)
+		}
+		return s.state
+	}
+	return s, u
+}
+
+func TestSnapshotAcquiresLimiter(t *testing.T) {
+	l := &testLimiter{}
+	s, u := testSnapshottingSnapper(l)
+
+	snapshot(s.args, u)
+
+	assert.Equal(t, Waiting, s.state)
+	assert.Equal(t, 1, l.acquired)
+	assert.Equal(t, 1, l.released)
+}
+
+func TestSnapshotStopsWhenCancelledWhileLimited(t *testing.T) {
+	l := &testLimiter{err: context.Canceled}
+	s, u := testSnapshottingSnapper(l)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.args.ctx = ctx
+
+	snapshot(s.args, u)
+
+	assert.Equal(t, Stopped, s.state)
+	assert.Equal(t, 0, l.released)
+}
@@ -23,13 +23,14 @@ type ReportFilesystem struct {
 	Path  string
 	State SnapState
 
-	// Valid in SnapStarted and later
+	// Valid in SnapStarted and later.
+	// In SnapSkipped, the name of the latest snapshot, which is still current.
 	SnapName      string
 	StartAt       time.Time
 	Hooks         string
 	HooksHadError bool
 
-	// Valid in SnapDone | SnapError
+	// Valid in SnapDone | SnapError | SnapSkipped | SnapDestroyed
 	DoneAt time.Time
 }
 
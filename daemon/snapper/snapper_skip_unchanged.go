@@ -0,0 +1,56 @@
+package snapper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// unchangedSinceLatestSnapshot determines whether fs has been written to since
+// its latest snapshot whose name starts with prefix.
+//
+// If fs has no such snapshot, it is considered changed.
+// Returns the name of the latest snapshot (without fs) if unchanged.
+//
+// We use the `written@snapshot` property instead of plain `written` because the latter
+// refers to the latest snapshot of any name, e.g. one taken by another tool.
+func unchangedSinceLatestSnapshot(ctx context.Context, fs *zfs.DatasetPath, prefix string) (latest string, unchanged bool, err error) {
+	fsvs, err := zfs.ZFSListFilesystemVersions(ctx, fs, zfs.ListFilesystemVersionsOptions{
+		Types:           zfs.Snapshots,
+		ShortnamePrefix: prefix,
+	})
+	if err != nil {
+		return "", false, errors.Wrap(err, "list filesystem versions")
+	}
+	if len(fsvs) == 0 {
+		return "", false, nil
+	}
+	sort.SliceStable(fsvs, func(i, j int) bool {
+		return fsvs[i].CreateTXG < fsvs[j].CreateTXG
+	})
+	latest = fsvs[len(fsvs)-1].Name
+
+	prop := fmt.Sprintf("written@%s", latest)
+	props, err := zfs.ZFSGet(ctx, fs, []string{prop})
+	if err != nil {
+		return "", false, errors.Wrapf(err, "get property %q", prop)
+	}
+	written, err := parseWritten(props.Get(prop))
+	if err != nil {
+		return "", false, errors.Wrapf(err, "property %q", prop)
+	}
+	return latest, written == 0, nil
+}
+
+func parseWritten(value string) (uint64, error) {
+	written, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse value %q as number of bytes", value)
+	}
+	return written, nil
+}
@@ -0,0 +1,33 @@
+package snapper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWritten(t *testing.T) {
+	type tc struct {
+		value   string
+		written uint64
+		err     bool
+	}
+	tcs := []tc{
+		{"0", 0, false},
+		{"4096", 4096, false},
+		{"18446744073709551615", 18446744073709551615, false},
+		{"-", 0, true},
+		{"", 0, true},
+		{"1K", 0, true},
+		{"-1", 0, true},
+	}
+	for _, c := range tcs {
+		written, err := parseWritten(c.value)
+		if c.err {
+			assert.Error(t, err, c.value)
+		} else {
+			assert.NoError(t, err, c.value)
+			assert.Equal(t, c.written, written, c.value)
+		}
+	}
+}
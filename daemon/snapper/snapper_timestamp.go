@@ -0,0 +1,30 @@
+package snapper
+
+import (
+	"fmt"
+	"time"
+)
+
+// timestampFormatter produces the part of the snapshot name that follows the prefix.
+type timestampFormatter func(t time.Time) string
+
+// All formats produce names of equal length that sort lexicographically by time.
+// dense-ms fills in the digits that are always 000 in dense, so that names
+// of both formats can be mixed within a filesystem.
+var timestampFormatters = map[string]timestampFormatter{
+	"dense": func(t time.Time) string {
+		return t.In(time.UTC).Format("20060102_150405_000")
+	},
+	"dense-ms": func(t time.Time) string {
+		t = t.In(time.UTC)
+		return fmt.Sprintf("%s_%03d", t.Format("20060102_150405"), t.Nanosecond()/int(time.Millisecond))
+	},
+}
+
+func timestampFormatterFromConfig(in string) (timestampFormatter, error) {
+	f, ok := timestampFormatters[in]
+	if !ok {
+		return nil, fmt.Errorf("invalid timestamp_format %q, must be one of dense, dense-ms", in)
+	}
+	return f, nil
+}
@@ -0,0 +1,82 @@
+package snapper
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/pruning"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestTimestampFormatters(t *testing.T) {
+	base := time.Date(2038, 1, 19, 3, 14, 7, 0, time.UTC)
+
+	dense, err := timestampFormatterFromConfig("dense")
+	require.NoError(t, err)
+	denseMs, err := timestampFormatterFromConfig("dense-ms")
+	require.NoError(t, err)
+	_, err = timestampFormatterFromConfig("rfc3339")
+	assert.Error(t, err)
+
+	assert.Equal(t, "20380119_031407_000", dense(base))
+	assert.Equal(t, "20380119_031407_000", denseMs(base))
+	assert.Equal(t, "20380119_031407_042", denseMs(base.Add(42*time.Millisecond+999*time.Microsecond)))
+	assert.Equal(t, "20380119_031407_999", denseMs(base.Add(999*time.Millisecond)))
+	// formatting is in UTC regardless of the input's location
+	assert.Equal(t, "20380119_031407_123", denseMs(base.Add(123*time.Millisecond).In(time.FixedZone("UTC+5", 5*60*60))))
+
+	// names sort lexicographically by time
+	var times []time.Time
+	var names []string
+	for _, d := range []time.Duration{0, 1 * time.Millisecond, 999 * time.Millisecond, time.Second, time.Second + 500*time.Millisecond, time.Hour} {
+		ts := base.Add(d)
+		times = append(times, ts)
+		names = append(names, "zrepl_"+denseMs(ts))
+		// a dense name has the same length and sorts like the dense-ms name of the start of its second
+		assert.Equal(t, denseMs(ts.Truncate(time.Second)), dense(ts))
+	}
+	assert.True(t, sort.StringsAreSorted(names), "%v", names)
+
+	for _, n := range names {
+		assert.Nil(t, zfs.EntityNamecheck("pool/fs@"+n, zfs.EntityTypeSnapshot), n)
+	}
+
+	// typical prune rule regexes match the extended timestamp
+	rule, err := pruning.RuleFromConfig(config.PruningEnum{Ret: &config.PruneKeepRegex{Regex: `^zrepl_\d{8}_\d{6}_\d{3}$`}})
+	require.NoError(t, err)
+	var snaps []pruning.Snapshot
+	for i, n := range names {
+		snaps = append(snaps, stubSnap{n, times[i]})
+	}
+	assert.Empty(t, rule.KeepRule(snaps))
+}
+
+type stubSnap struct {
+	name string
+	date time.Time
+}
+
+func (s stubSnap) Name() string     { return s.name }
+func (s stubSnap) Replicated() bool { return true }
+func (s stubSnap) Date() time.Time  { return s.date }
+
+func TestPeriodicFromConfigRejectsInvalidSnapshotNames(t *testing.T) {
+	conf := func(prefix string) *config.SnapshottingPeriodic {
+		return &config.SnapshottingPeriodic{
+			Prefix:          prefix,
+			Interval:        time.Minute,
+			TimestampFormat: "dense",
+		}
+	}
+	_, err := PeriodicFromConfig(nil, "job", nil, conf("zrepl_"), nil)
+	assert.NoError(t, err)
+	for _, prefix := range []string{"zrepl ", "zrepl@", "zrepl/", "zrepl\n"} {
+		_, err := PeriodicFromConfig(nil, "job", nil, conf(prefix), nil)
+		assert.Error(t, err, "%q", prefix)
+	}
+}
@@ -0,0 +1,46 @@
+package snapper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	zfsprop "github.com/zrepl/zrepl/zfs/property"
+)
+
+// userProperties are the user properties set on each snapshot.
+// Values are templates in which {job} and {timestamp} are expanded.
+type userProperties struct {
+	templates map[zfsprop.Property]string
+	jobName   string
+}
+
+var userPropertyTemplateVarRE = regexp.MustCompile(`\{[^{}]*\}`)
+
+func userPropertiesFromConfig(jobName string, in map[zfsprop.Property]string) (userProperties, error) {
+	for p, v := range in {
+		if err := p.ValidateUserProperty(); err != nil {
+			return userProperties{}, err
+		}
+		for _, tv := range userPropertyTemplateVarRE.FindAllString(v, -1) {
+			if tv != "{job}" && tv != "{timestamp}" {
+				return userProperties{}, fmt.Errorf("value of %q: unknown template variable %s, must be one of {job}, {timestamp}", p, tv)
+			}
+		}
+	}
+	return userProperties{templates: in, jobName: jobName}, nil
+}
+
+// expand returns the user properties for a snapshot whose name ends in timestamp.
+// Returns nil if no user properties are configured.
+func (u userProperties) expand(timestamp string) map[zfsprop.Property]string {
+	if len(u.templates) == 0 {
+		return nil
+	}
+	r := strings.NewReplacer("{job}", u.jobName, "{timestamp}", timestamp)
+	props := make(map[zfsprop.Property]string, len(u.templates))
+	for p, v := range u.templates {
+		props[p] = r.Replace(v)
+	}
+	return props
+}
@@ -0,0 +1,44 @@
+package snapper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	zfsprop "github.com/zrepl/zrepl/zfs/property"
+)
+
+func TestUserPropertiesExpand(t *testing.T) {
+	u, err := userPropertiesFromConfig("prod_push", map[zfsprop.Property]string{
+		"myorg:job":     "{job}",
+		"myorg:created": "at {timestamp} by {job}",
+		"myorg:static":  "value",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[zfsprop.Property]string{
+		"myorg:job":     "prod_push",
+		"myorg:created": "at 20201015_120000_000 by prod_push",
+		"myorg:static":  "value",
+	}, u.expand("20201015_120000_000"))
+}
+
+func TestUserPropertiesEmpty(t *testing.T) {
+	u, err := userPropertiesFromConfig("job", nil)
+	require.NoError(t, err)
+	assert.Nil(t, u.expand("ts"))
+}
+
+func TestUserPropertiesFromConfigErrors(t *testing.T) {
+	for _, in := range []map[zfsprop.Property]string{
+		{"nocolon": "v"},
+		{":property": "v"},
+		{"module:": "v"},
+		{"zrepl:foo": "v"},
+		{"my org:foo": "v"},
+		{"myorg:foo": "{jobname}"},
+	} {
+		_, err := userPropertiesFromConfig("job", in)
+		assert.Error(t, err, "%v", in)
+	}
+}
@@ -26,6 +26,19 @@ type SenderConfig struct {
 	FSF   zfs.DatasetFilter
 	JobID JobID
 
+	SendOptions
+
+	// Overrides replace SendOptions for the filesystems they match.
+	// The first matching override applies.
+	Overrides []SendOptionsOverride
+
+	// PeerRecvFeatures are the pool features advertised by the receiving peers,
+	// nil if they are not known. See SendOptions.NegotiateFeatures.
+	PeerRecvFeatures *PeerRecvFeatures
+}
+
+// SendOptions control the flags and checks of `zfs send`.
+type SendOptions struct {
 	Encrypt              *nodefault.Bool
 	SendRaw              bool
 	SendProperties       bool
@@ -34,12 +47,38 @@ type SenderConfig struct {
 	SendCompressed       bool
 	SendEmbeddedData     bool
 	SendSaved            bool
+
+	EncryptionRootChange EncryptionRootChange
+
+	// NegotiateFeatures drops the send flags whose streams the receiving peer's pool cannot receive,
+	// see recvFeatureFlags.
+	NegotiateFeatures bool
+}
+
+func (o *SendOptions) Validate() error {
+	if err := o.Encrypt.ValidateNoDefault(); err != nil {
+		return errors.Wrap(err, "`Encrypt` field invalid")
+	}
+	return nil
+}
+
+type SendOptionsOverride struct {
+	FSF zfs.DatasetFilter
+	SendOptions
 }
 
 func (c *SenderConfig) Validate() error {
 	c.JobID.MustValidate()
-	if err := c.Encrypt.ValidateNoDefault(); err != nil {
-		return errors.Wrap(err, "`Encrypt` field invalid")
+	if err := c.SendOptions.Validate(); err != nil {
+		return err
+	}
+	for i := range c.Overrides {
+		if c.Overrides[i].FSF == nil {
+			return fmt.Errorf("override #%d: `FSF` must not be nil", i)
+		}
+		if err := c.Overrides[i].SendOptions.Validate(); err != nil {
+			return errors.Wrapf(err, "override #%d", i)
+		}
 	}
 	if _, err := StepHoldTag(c.JobID); err != nil {
 		return fmt.Errorf("JobID cannot be used for hold tag: %s", err)
@@ -47,6 +86,29 @@ func (c *SenderConfig) Validate() error {
 	return nil
 }
 
+// sendOptions returns the send options for fs, i.e., those of the first override matching fs, or the default ones.
+func (c *SenderConfig) sendOptions(fs *zfs.DatasetPath) (*SendOptions, error) {
+	for i := range c.Overrides {
+		pass, err := c.Overrides[i].FSF.Filter(fs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot match send options override #%d", i)
+		}
+		if pass {
+			return &c.Overrides[i].SendOptions, nil
+		}
+	}
+	return &c.SendOptions, nil
+}
+
+// sendsEncrypted returns true if the sends of fs are encrypted (send -w), taking send_overrides into account.
+func (c *SenderConfig) sendsEncrypted(fs *zfs.DatasetPath) (bool, error) {
+	opts, err := c.sendOptions(fs)
+	if err != nil {
+		return false, err
+	}
+	return opts.Encrypt.B, nil
+}
+
 // Sender implements replication.ReplicationEndpoint for a sending side
 type Sender struct {
 	pdu.UnsafeReplicationServer // prefer compilation errors over default 'method X not implemented' impl
@@ -151,21 +213,26 @@ func sendArgsFromPDUAndValidateExistsAndGetVersion(ctx context.Context, fs strin
 func (s *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.ReadCloser, error) {
 	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
 
-	_, err := s.filterCheckFS(r.Filesystem)
+	dp, err := s.filterCheckFS(r.Filesystem)
 	if err != nil {
 		return nil, nil, err
 	}
+	opts, err := s.config.sendOptions(dp)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts = s.negotiateRecvFeatures(ctx, opts)
 	switch r.Encrypted {
 	case pdu.Tri_DontCare:
-		// use s.encrypt setting
+		// use opts.Encrypt setting
 		// ok, fallthrough outer
 	case pdu.Tri_False:
-		if s.config.Encrypt.B {
+		if opts.Encrypt.B {
 			return nil, nil, errors.New("only encrypted sends allowed (send -w + encryption!= off), but unencrypted send requested")
 		}
 		// fallthrough outer
 	case pdu.Tri_True:
-		if !s.config.Encrypt.B {
+		if !opts.Encrypt.B {
 			return nil, nil, errors.New("only unencrypted sends allowed, but encrypted send requested")
 		}
 		// fallthrough outer
@@ -174,22 +241,27 @@ func (s *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Rea
 	}
 
 	sendArgsUnvalidated := zfs.ZFSSendArgsUnvalidated{
-		FS:   r.Filesystem,
-		From: uncheckedSendArgsFromPDU(r.GetFrom()), // validated by zfs.ZFSSendDry / zfs.ZFSSend
-		To:   uncheckedSendArgsFromPDU(r.GetTo()),   // validated by zfs.ZFSSendDry / zfs.ZFSSend
+		FS:            r.Filesystem,
+		From:          uncheckedSendArgsFromPDU(r.GetFrom()), // validated by zfs.ZFSSendDry / zfs.ZFSSend
+		To:            uncheckedSendArgsFromPDU(r.GetTo()),   // validated by zfs.ZFSSendDry / zfs.ZFSSend
+		Intermediates: r.GetIntermediates(),
 		ZFSSendFlags: zfs.ZFSSendFlags{
 			ResumeToken:      r.ResumeToken, // nil or not nil, depending on decoding success
-			Encrypted:        s.config.Encrypt,
-			Properties:       s.config.SendProperties,
-			BackupProperties: s.config.SendBackupProperties,
-			Raw:              s.config.SendRaw,
-			LargeBlocks:      s.config.SendLargeBlocks,
-			Compressed:       s.config.SendCompressed,
-			EmbeddedData:     s.config.SendEmbeddedData,
-			Saved:            s.config.SendSaved,
+			Encrypted:        opts.Encrypt,
+			Properties:       opts.SendProperties,
+			BackupProperties: opts.SendBackupProperties,
+			Raw:              opts.SendRaw,
+			LargeBlocks:      opts.SendLargeBlocks,
+			Compressed:       opts.SendCompressed,
+			EmbeddedData:     opts.SendEmbeddedData,
+			Saved:            opts.SendSaved,
 		},
 	}
 
+	if r.GetIntermediates() && r.GetReplicationConfig().GetProtection().GetIncremental() == pdu.ReplicationGuaranteeKind_GuaranteeResumability {
+		return nil, nil, errors.New("sending intermediate snapshots is incompatible with incremental protection guarantee_resumability")
+	}
+
 	sendArgs, err := sendArgsUnvalidated.Validate(ctx)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "validate send arguments")
@@ -200,6 +272,11 @@ func (s *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Rea
 		return nil, nil, errors.Wrap(err, "zfs send dry failed")
 	}
 
+	// checked in dry runs as well so that the error surfaces during replication planning
+	if err := s.checkEncryptionRootForIncrementalRawSend(ctx, sendArgs, opts.EncryptionRootChange); err != nil {
+		return nil, nil, err
+	}
+
 	// From now on, assume that sendArgs has been validated by ZFSSendDry
 	// (because validation involves shelling out, it's actually a little expensive)
 
@@ -208,8 +285,9 @@ func (s *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Rea
 		expSize = si.SizeEstimate
 	}
 	res := &pdu.SendRes{
-		ExpectedSize:    expSize,
-		UsedResumeToken: r.ResumeToken != "",
+		ExpectedSize:      expSize,
+		UsedResumeToken:   r.ResumeToken != "",
+		UsedIntermediates: sendArgs.Intermediates,
 	}
 
 	if r.DryRun {
@@ -360,6 +438,14 @@ func (p *Sender) SendCompleted(ctx context.Context, r *pdu.SendCompletedReq) (*p
 	}
 	abstractionsCacheSingleton.TryBatchDestroy(ctx, p.jobId, fs, destroyTypes, keep, nil)
 
+	if raw, err := p.config.sendsEncrypted(fsp); err != nil {
+		getLogger(ctx).WithField("fs", fs).WithError(err).Warn("cannot determine send options of completed send")
+	} else if raw {
+		if err := p.recordEncryptionRoot(ctx, fs); err != nil {
+			getLogger(ctx).WithField("fs", fs).WithError(err).Warn("cannot record encryptionroot of completed raw send")
+		}
+	}
+
 	return &pdu.SendCompletedRes{}, nil
 
 }
@@ -374,6 +460,84 @@ func (p *Sender) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshots
 	return doDestroySnapshots(ctx, dp, req.Snapshots)
 }
 
+// BookmarkSnapshot implements pruner.BookmarkTarget.
+func (p *Sender) BookmarkSnapshot(ctx context.Context, fs string, snap *pdu.FilesystemVersion) error {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+
+	dp, err := p.filterCheckFS(fs)
+	if err != nil {
+		return err
+	}
+	if snap.Type != pdu.FilesystemVersion_Snapshot {
+		return fmt.Errorf("version %q is not a snapshot", snap.Name)
+	}
+	v, err := sendArgsFromPDUAndValidateExistsAndGetVersion(ctx, dp.ToString(), snap)
+	if err != nil {
+		return err
+	}
+	_, err = zfs.ZFSBookmark(ctx, dp.ToString(), v, v.Name)
+	return err
+}
+
+// PrunableBookmarks implements pruner.BookmarkTarget.
+// It omits the bookmarks that are replication cursors of any job.
+func (p *Sender) PrunableBookmarks(ctx context.Context, fs string) ([]*pdu.FilesystemVersion, error) {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+
+	dp, err := p.filterCheckFS(fs)
+	if err != nil {
+		return nil, err
+	}
+	bookmarks, err := zfs.ZFSListFilesystemVersions(ctx, dp, zfs.ListFilesystemVersionsOptions{Types: zfs.Bookmarks})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*pdu.FilesystemVersion, 0, len(bookmarks))
+	for i := range bookmarks {
+		if isAbstractionBookmark(dp, bookmarks[i]) {
+			continue
+		}
+		res = append(res, pdu.FilesystemVersionFromZFS(&bookmarks[i]))
+	}
+	return res, nil
+}
+
+// DestroyBookmarks implements pruner.BookmarkTarget.
+func (p *Sender) DestroyBookmarks(ctx context.Context, fs string, bookmarks []*pdu.FilesystemVersion) error {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+
+	dp, err := p.filterCheckFS(fs)
+	if err != nil {
+		return err
+	}
+	for _, bm := range bookmarks {
+		if bm.Type != pdu.FilesystemVersion_Bookmark {
+			return fmt.Errorf("version %q is not a bookmark", bm.Name)
+		}
+		v, err := bm.ZFSFilesystemVersion()
+		if err != nil {
+			return err
+		}
+		if isAbstractionBookmark(dp, *v) {
+			return fmt.Errorf("refusing to destroy replication cursor %q", bm.RelName())
+		}
+		if err := zfs.ZFSDestroyFilesystemVersion(ctx, dp, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isAbstractionBookmark returns true if v is a bookmark that zrepl uses for replication.
+func isAbstractionBookmark(fs *zfs.DatasetPath, v zfs.FilesystemVersion) bool {
+	for t := range AbstractionTypesAll {
+		if e := t.BookmarkExtractor(); e != nil && e(fs, v) != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Sender) Ping(ctx context.Context, req *pdu.PingReq) (*pdu.PingRes, error) {
 	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
 
@@ -436,14 +600,52 @@ type ReceiverConfig struct {
 
 	RootWithoutClientComponent *zfs.DatasetPath
 	AppendClientIdentity       bool
+	// If not nil, the client root is the expanded template instead of
+	// RootWithoutClientComponent with the client identity appended.
+	// RootWithoutClientComponent must be the template's prefix and AppendClientIdentity must be set.
+	ClientRootTemplate *ClientRootTemplate
 
 	InheritProperties  []zfsprop.Property
 	OverrideProperties map[zfsprop.Property]string
+	// If true, properties that zfs recv cannot set on a filesystem are excluded
+	// from subsequent receives into it, and a receive that failed only because
+	// of such properties succeeds if the snapshot was received.
+	ExcludeRejectedProperties bool
+
+	// Receive with zfs recv -F if the target filesystem has snapshots
+	// that are not in the incremental stream's history.
+	// Unless ForceRollbackAllowDivergence is set, the receive is refused
+	// if it would destroy any snapshots.
+	ForceRollback                bool
+	ForceRollbackAllowDivergence bool
+
+	// If false, missing parent filesystems of the target filesystem are not
+	// created as placeholders but the receive fails.
+	CreateParents bool
+	// If true, the receive fails if the target filesystem does not exist.
+	// Implies that no parent filesystems are created.
+	RequireExists bool
+
+	// If not nil, refuse to receive if the pool's free space is below the threshold.
+	MinFreeSpace *zfs.MinFreeSpace
+
+	// Encryption of placeholders created below encrypted parents.
+	// If PlaceholderCreationEncryptionPropertyInherit, filesystems created by
+	// a full receive are also verified to be encrypted if their parent is.
+	PlaceholderEncryption zfs.PlaceholderCreationEncryptionProperty
+
+	// Whether received filesystems are mounted, see zfs.RecvMountBehavior.
+	Mount zfs.RecvMountBehavior
 }
 
 func (c *ReceiverConfig) copyIn() {
 	c.RootWithoutClientComponent = c.RootWithoutClientComponent.Copy()
 
+	if c.MinFreeSpace != nil {
+		m := *c.MinFreeSpace
+		c.MinFreeSpace = &m
+	}
+
 	pInherit := make([]zfsprop.Property, len(c.InheritProperties))
 	copy(pInherit, c.InheritProperties)
 	c.InheritProperties = pInherit
@@ -465,16 +667,40 @@ func (c *ReceiverConfig) Validate() error {
 		}
 	}
 
-	for prop := range c.OverrideProperties {
+	for prop, value := range c.OverrideProperties {
 		err := prop.Validate()
 		if err != nil {
 			return errors.Wrapf(err, "override property %q", prop)
 		}
+		if err := prop.ValidateValue(value); err != nil {
+			return errors.Wrap(err, "override property")
+		}
+	}
+
+	// contradictory combinations that zfs recv or zrepl's checks would otherwise reject at receive time
+	for _, prop := range c.InheritProperties {
+		if _, ok := c.OverrideProperties[prop]; ok {
+			return errors.Errorf("property %q is both inherited and overridden", prop)
+		}
+	}
+	if _, ok := c.OverrideProperties[zfsprop.Property(zfs.PlaceholderPropertyName)]; ok {
+		return errors.Errorf("override property %q conflicts with zrepl's placeholder management", zfs.PlaceholderPropertyName)
+	}
+	if c.PlaceholderEncryption == zfs.PlaceholderCreationEncryptionPropertyInherit && c.OverrideProperties["encryption"] == "off" {
+		return errors.New("override property \"encryption\" = \"off\" conflicts with placeholder encryption \"inherit\", which requires filesystems below encrypted parents to be encrypted")
 	}
 
 	if c.RootWithoutClientComponent.Length() <= 0 {
 		return errors.New("RootWithoutClientComponent must not be an empty dataset path")
 	}
+	if c.ClientRootTemplate != nil {
+		if !c.AppendClientIdentity {
+			return errors.New("ClientRootTemplate requires AppendClientIdentity")
+		}
+		if !c.ClientRootTemplate.Prefix().Equal(c.RootWithoutClientComponent) {
+			return errors.New("RootWithoutClientComponent must be the prefix of ClientRootTemplate")
+		}
+	}
 	return nil
 }
 
@@ -485,6 +711,8 @@ type Receiver struct {
 	conf ReceiverConfig // validated
 
 	recvParentCreationMtx *chainlock.L
+
+	rejectedProperties *rejectedProperties
 }
 
 func NewReceiver(config ReceiverConfig) *Receiver {
@@ -495,6 +723,7 @@ func NewReceiver(config ReceiverConfig) *Receiver {
 	return &Receiver{
 		conf:                  config,
 		recvParentCreationMtx: chainlock.New(),
+		rejectedProperties:    newRejectedProperties(),
 	}
 }
 
@@ -516,9 +745,9 @@ func clientRoot(rootFS *zfs.DatasetPath, clientIdentity string) (*zfs.DatasetPat
 	return clientRoot, nil
 }
 
-func (s *Receiver) clientRootFromCtx(ctx context.Context) *zfs.DatasetPath {
+func (s *Receiver) clientRootFromCtx(ctx context.Context) (*zfs.DatasetPath, error) {
 	if !s.conf.AppendClientIdentity {
-		return s.conf.RootWithoutClientComponent.Copy()
+		return s.conf.RootWithoutClientComponent.Copy(), nil
 	}
 
 	clientIdentity, ok := ctx.Value(ClientIdentityKey).(string)
@@ -526,11 +755,16 @@ func (s *Receiver) clientRootFromCtx(ctx context.Context) *zfs.DatasetPath {
 		panic("ClientIdentityKey context value must be set")
 	}
 
+	if s.conf.ClientRootTemplate != nil {
+		// the components used by the template are not validated by the transport
+		return s.conf.ClientRootTemplate.Expand(clientIdentity)
+	}
+
 	clientRoot, err := clientRoot(s.conf.RootWithoutClientComponent, clientIdentity)
 	if err != nil {
 		panic(fmt.Sprintf("ClientIdentityContextKey must have been validated before invoking Receiver: %s", err))
 	}
-	return clientRoot
+	return clientRoot, nil
 }
 
 type subroot struct {
@@ -567,7 +801,10 @@ func (s *Receiver) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemR
 		return nil, errors.New("root_fs does not exist")
 	}
 
-	root := s.clientRootFromCtx(ctx)
+	root, err := s.clientRootFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
 	filtered, err := zfs.ZFSListMapping(ctx, subroot{root})
 	if err != nil {
 		return nil, err
@@ -619,7 +856,10 @@ func (s *Receiver) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemR
 func (s *Receiver) ListFilesystemVersions(ctx context.Context, req *pdu.ListFilesystemVersionsReq) (*pdu.ListFilesystemVersionsRes, error) {
 	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
 
-	root := s.clientRootFromCtx(ctx)
+	root, err := s.clientRootFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
 	lp, err := subroot{root}.MapToLocal(req.GetFilesystem())
 	if err != nil {
 		return nil, err
@@ -668,13 +908,77 @@ func (s *Receiver) Send(ctx context.Context, req *pdu.SendReq) (*pdu.SendRes, io
 	return nil, nil, fmt.Errorf("receiver does not implement Send()")
 }
 
+// checkForceRollback determines which snapshots of lp `zfs recv -F` of the
+// send stream starting with streamBegin would destroy.
+// Each of them is logged, and unless ForceRollbackAllowDivergence is set,
+// an error is returned if there are any.
+func (s *Receiver) checkForceRollback(ctx context.Context, lp *zfs.DatasetPath, streamBegin []byte) error {
+	log := getLogger(ctx).WithField("local_fs", lp.ToString())
+
+	begin, err := zfs.ParseSendStreamBeginRecord(streamBegin)
+	if err != nil {
+		return errors.Wrap(err, "force_rollback: cannot decode send stream")
+	}
+	snaps, err := zfs.ZFSListFilesystemVersions(ctx, lp, zfs.ListFilesystemVersionsOptions{Types: zfs.Snapshots})
+	if err != nil {
+		return errors.Wrap(err, "force_rollback: cannot list receive-side snapshots")
+	}
+	destroyed, err := forceRollbackDestroyedSnapshots(begin, snaps)
+	if err != nil {
+		return errors.Wrap(err, "force_rollback")
+	}
+	if len(destroyed) == 0 {
+		return nil
+	}
+
+	for _, v := range destroyed {
+		log.WithField("snapshot", v.FullPath(lp.ToString())).
+			WithField("guid", v.Guid).
+			Warn("force_rollback: receive-side snapshot does not exist on the sending side and would be destroyed by zfs recv -F")
+	}
+	if !s.conf.ForceRollbackAllowDivergence {
+		return errors.Errorf("force_rollback: receive-side filesystem %q has diverged from the sending side (%d snapshot(s) would be destroyed, see receive-side log), refusing to receive without allow_divergence", lp.ToString(), len(destroyed))
+	}
+	log.WithField("count", len(destroyed)).Warn("force_rollback: allow_divergence is set, receiving with zfs recv -F")
+	return nil
+}
+
+// forceRollbackDestroyedSnapshots returns the subset of the receive-side snapshots snaps
+// that `zfs recv -F` of a stream with the given begin record destroys.
+func forceRollbackDestroyedSnapshots(begin *zfs.SendStreamBeginRecord, snaps []zfs.FilesystemVersion) ([]zfs.FilesystemVersion, error) {
+	if !begin.IsIncremental() {
+		// a full recv -F replaces the entire filesystem
+		return snaps, nil
+	}
+	var from *zfs.FilesystemVersion
+	for i := range snaps {
+		if snaps[i].Guid == begin.FromGUID {
+			from = &snaps[i]
+			break
+		}
+	}
+	if from == nil {
+		return nil, errors.Errorf("incremental source (guid %d) of stream to %q does not exist on receive-side", begin.FromGUID, begin.ToName)
+	}
+	var destroyed []zfs.FilesystemVersion
+	for _, v := range snaps {
+		if v.CreateTXG > from.CreateTXG {
+			destroyed = append(destroyed, v)
+		}
+	}
+	return destroyed, nil
+}
+
 func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.ReadCloser) (*pdu.ReceiveRes, error) {
 	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
 
 	getLogger(ctx).Debug("incoming Receive")
 	defer receive.Close()
 
-	root := s.clientRootFromCtx(ctx)
+	root, err := s.clientRootFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
 	lp, err := subroot{root}.MapToLocal(req.Filesystem)
 	if err != nil {
 		return nil, errors.Wrap(err, "`Filesystem` invalid")
@@ -688,6 +992,11 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 		return nil, errors.New("`To` must be a snapshot")
 	}
 
+	if err := zfs.CheckPoolFreeSpace(ctx, lp.ToString(), s.conf.MinFreeSpace); err != nil {
+		getLogger(ctx).WithError(err).Error("refusing to receive")
+		return nil, err
+	}
+
 	// create placeholder parent filesystems as appropriate
 	//
 	// Manipulating the ZFS dataset hierarchy must happen exclusively.
@@ -730,9 +1039,18 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 					getLogger(ctx).WithError(visitErr).Error("placeholders are only created automatically below root_fs")
 					return false
 				}
+				if !s.conf.CreateParents || s.conf.RequireExists {
+					reason := "recv.create_parents is disabled"
+					if s.conf.RequireExists {
+						reason = "recv.require_exists is enabled"
+					}
+					visitErr = fmt.Errorf("parent filesystem %q of %q does not exist and must be created manually (%s)", v.Path.ToString(), lp.ToString(), reason)
+					getLogger(ctx).WithError(visitErr).Error("refusing to create placeholder filesystem")
+					return false
+				}
 				l := getLogger(ctx).WithField("placeholder_fs", v.Path)
 				l.Debug("create placeholder filesystem")
-				err := zfs.ZFSCreatePlaceholderFilesystem(ctx, v.Path, v.Parent.Path)
+				err := zfs.ZFSCreatePlaceholderFilesystem(ctx, v.Path, v.Parent.Path, s.conf.PlaceholderEncryption)
 				if err != nil {
 					l.WithError(err).Error("cannot create placeholder filesystem")
 					visitErr = err
@@ -760,8 +1078,16 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 	}
 	log.WithField("placeholder_state", fmt.Sprintf("%#v", ph)).Debug("placeholder state")
 
+	if s.conf.RequireExists && !ph.FSExists {
+		return nil, fmt.Errorf("target filesystem %q does not exist and must be created manually (recv.require_exists is enabled)", lp.ToString())
+	}
+
 	recvOpts.InheritProperties = s.conf.InheritProperties
 	recvOpts.OverrideProperties = s.conf.OverrideProperties
+	if s.conf.ExcludeRejectedProperties {
+		recvOpts.InheritProperties = s.rejectedProperties.inherit(lp.ToString(), s.conf.InheritProperties, s.conf.OverrideProperties)
+	}
+	recvOpts.Mount = s.conf.Mount
 
 	if ph.FSExists && ph.IsPlaceholder {
 		recvOpts.RollbackAndForceRecv = true
@@ -798,10 +1124,38 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 		panic(peek.Len())
 	}
 
+	if s.conf.ForceRollback && ph.FSExists && !ph.IsPlaceholder {
+		if err := s.checkForceRollback(ctx, lp, peek.Bytes()); err != nil {
+			return nil, err
+		}
+		recvOpts.ForceRecv = true
+	}
+
+	if recvOpts.Mount != zfs.RecvMountKeep {
+		// zfs recv rejects canmount for volumes
+		if begin, err := zfs.ParseSendStreamBeginRecord(peek.Bytes()); err != nil {
+			log.WithError(err).Warn("cannot parse send stream begin record, assuming filesystem stream")
+		} else {
+			recvOpts.IsVolume = begin.IsVolume
+		}
+	}
+
 	log.WithField("opts", fmt.Sprintf("%#v", recvOpts)).Debug("start receive command")
 
 	snapFullPath := to.FullPath(lp.ToString())
-	if err := zfs.ZFSRecv(ctx, lp.ToString(), to, chainedio.NewChainedReader(&peek, receive), recvOpts); err != nil {
+	recvErr := zfs.ZFSRecv(ctx, lp.ToString(), to, chainedio.NewChainedReader(&peek, receive), recvOpts)
+	if propErr, ok := recvErr.(*zfs.RecvPropertiesRejectedErr); ok && s.conf.ExcludeRejectedProperties {
+		added := s.rejectedProperties.add(lp.ToString(), propErr.Properties)
+		log.WithField("properties", propErr.Properties).
+			WithField("newly_excluded", added).
+			WithField("zfs_output", propErr.Msg).
+			Warn("zfs receive could not set properties, excluding them from subsequent receives into this filesystem")
+		if _, err := to.ValidateExistsAndGetVersion(ctx, lp.ToString()); err == nil {
+			log.WithField("snap", snapFullPath).Warn("snapshot was received without the rejected properties")
+			recvErr = nil
+		}
+	}
+	if err := recvErr; err != nil {
 
 		// best-effort rollback of placeholder state if the recv didn't start
 		_, resumableStatePresent := err.(*zfs.RecvFailedWithResumeTokenErr)
@@ -882,6 +1236,14 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 		return nil, errors.Wrap(err, msg)
 	}
 
+	if s.conf.PlaceholderEncryption == zfs.PlaceholderCreationEncryptionPropertyInherit && (!ph.FSExists || ph.IsPlaceholder) {
+		if err := zfs.ZFSCheckEncryptionInherited(ctx, lp); err != nil {
+			log.WithError(err).Error("received filesystem does not inherit encryption from its parent (recv.placeholder.encryption is inherit)")
+			log.Error("aborting recv request, but keeping received filesystem for inspection")
+			return nil, err
+		}
+	}
+
 	replicationGuaranteeOptions, err := replicationGuaranteeOptionsFromPDU(req.GetReplicationConfig().Protection)
 	if err != nil {
 		return nil, err
@@ -921,7 +1283,10 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 func (s *Receiver) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error) {
 	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
 
-	root := s.clientRootFromCtx(ctx)
+	root, err := s.clientRootFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
 	lp, err := subroot{root}.MapToLocal(req.Filesystem)
 	if err != nil {
 		return nil, err
@@ -935,26 +1300,38 @@ func (p *Receiver) SendCompleted(ctx context.Context, _ *pdu.SendCompletedReq) (
 	return &pdu.SendCompletedRes{}, nil
 }
 
+// doDestroySnapshots does not destroy pinned snapshots, see pdu.DestroySnapshotResErrorPinned.
+// Pins are checked here rather than by the pruner because pruners of remote targets cannot list them.
 func doDestroySnapshots(ctx context.Context, lp *zfs.DatasetPath, snaps []*pdu.FilesystemVersion) (*pdu.DestroySnapshotsRes, error) {
-	reqs := make([]*zfs.DestroySnapOp, len(snaps))
-	ress := make([]*pdu.DestroySnapshotRes, len(snaps))
-	errs := make([]error, len(snaps))
-	for i, fsv := range snaps {
+	for _, fsv := range snaps {
 		if fsv.Type != pdu.FilesystemVersion_Snapshot {
 			return nil, fmt.Errorf("version %q is not a snapshot", fsv.Name)
 		}
+	}
+	pinned, err := PinnedSnapshots(ctx, lp)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot determine pinned snapshots")
+	}
+	reqs := make([]*zfs.DestroySnapOp, 0, len(snaps))
+	ress := make([]*pdu.DestroySnapshotRes, len(snaps))
+	errs := make([]error, len(snaps))
+	for i, fsv := range snaps {
 		ress[i] = &pdu.DestroySnapshotRes{
 			Snapshot: fsv,
 			// Error set after batch operation
 		}
-		reqs[i] = &zfs.DestroySnapOp{
+		if pinned[fsv.GetGuid()] {
+			ress[i].Error = pdu.DestroySnapshotResErrorPinned
+			continue
+		}
+		reqs = append(reqs, &zfs.DestroySnapOp{
 			Filesystem: lp.ToString(),
 			Name:       fsv.Name,
 			ErrOut:     &errs[i],
-		}
+		})
 	}
 	zfs.ZFSDestroyFilesystemVersions(ctx, reqs)
-	for i := range reqs {
+	for i := range snaps {
 		if errs[i] != nil {
 			if de, ok := errs[i].(*zfs.DestroySnapshotsError); ok && len(de.Reason) == 1 {
 				ress[i].Error = de.Reason[0]
@@ -0,0 +1,368 @@
+package endpoint
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+type ArchiveReceiverConfig struct {
+	JobID JobID
+	// Command is invoked once per send stream with the stream on stdin.
+	Command string
+	// Manifest is the path of the JSON lines file that records the archived streams.
+	Manifest string
+	// Timeout for a single invocation of Command, 0 means no timeout.
+	Timeout time.Duration
+}
+
+func (c *ArchiveReceiverConfig) Validate() error {
+	c.JobID.MustValidate()
+	if !filepath.IsAbs(c.Command) {
+		return fmt.Errorf("command must be an absolute path, got %q", c.Command)
+	}
+	if !filepath.IsAbs(c.Manifest) {
+		return fmt.Errorf("manifest must be an absolute path, got %q", c.Manifest)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	return nil
+}
+
+// ArchiveManifestEntry describes one archived send stream.
+// The manifest contains one JSON-encoded entry per line, in the order in which the streams were archived.
+type ArchiveManifestEntry struct {
+	Filesystem string
+	// Object is the name under which the command stored the stream, see ArchiveObjectName.
+	Object string
+	// Snapshot is the name of the snapshot the stream creates on receive (without filesystem).
+	Snapshot  string
+	GUID      uint64
+	CreateTXG uint64
+	// Creation of the snapshot in RFC 3339 format, as reported by the sender.
+	Creation string
+	// FromSnapshot and FromGUID identify the incremental base, they are empty for full streams.
+	FromSnapshot string `json:",omitempty"`
+	FromGUID     uint64 `json:",omitempty"`
+	Bytes        int64
+	ArchivedAt   time.Time
+}
+
+// ArchiveObjectName returns the object name of the stream from fromGUID (0 for a full stream)
+// to toGUID of filesystem fs.
+// The name only depends on the GUIDs, so retrying a stream yields the same object.
+func ArchiveObjectName(fs string, fromGUID, toGUID uint64) string {
+	if fromGUID == 0 {
+		return fmt.Sprintf("%s/full-%d", fs, toGUID)
+	}
+	return fmt.Sprintf("%s/incremental-%d-%d", fs, fromGUID, toGUID)
+}
+
+// ArchiveReceiver implements the receiving side of a push job that archives
+// the send streams through an external command instead of receiving them into ZFS,
+// e.g. to upload them to object storage.
+//
+// The archived snapshots are those recorded in the manifest, which enables
+// incremental sends on top of them. Archived streams cannot be destroyed through zrepl.
+type ArchiveReceiver struct {
+	conf ArchiveReceiverConfig
+
+	manifestMtx sync.Mutex
+}
+
+func NewArchiveReceiver(conf ArchiveReceiverConfig) *ArchiveReceiver {
+	if err := conf.Validate(); err != nil {
+		panic(err)
+	}
+	return &ArchiveReceiver{conf: conf}
+}
+
+// ReadArchiveManifest parses the manifest at path.
+// A manifest that does not exist is empty.
+func ReadArchiveManifest(path string) ([]ArchiveManifestEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []ArchiveManifestEntry
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var e ArchiveManifestEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, errors.Wrapf(err, "manifest %q line %d", path, line)
+		}
+		entries = append(entries, e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrapf(err, "read manifest %q", path)
+	}
+	return entries, nil
+}
+
+func (r *ArchiveReceiver) readManifest() ([]ArchiveManifestEntry, error) {
+	r.manifestMtx.Lock()
+	defer r.manifestMtx.Unlock()
+	return ReadArchiveManifest(r.conf.Manifest)
+}
+
+func (r *ArchiveReceiver) appendManifest(e ArchiveManifestEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	r.manifestMtx.Lock()
+	defer r.manifestMtx.Unlock()
+	f, err := os.OpenFile(r.conf.Manifest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (r *ArchiveReceiver) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error) {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+	entries, err := r.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	fss := make([]*pdu.Filesystem, 0)
+	for _, e := range entries {
+		if seen[e.Filesystem] {
+			continue
+		}
+		seen[e.Filesystem] = true
+		fss = append(fss, &pdu.Filesystem{Path: e.Filesystem})
+	}
+	return &pdu.ListFilesystemRes{Filesystems: fss}, nil
+}
+
+func (r *ArchiveReceiver) ListFilesystemVersions(ctx context.Context, req *pdu.ListFilesystemVersionsReq) (*pdu.ListFilesystemVersionsRes, error) {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+	entries, err := r.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]*pdu.FilesystemVersion, 0)
+	for _, e := range entries {
+		if e.Filesystem != req.GetFilesystem() {
+			continue
+		}
+		versions = append(versions, &pdu.FilesystemVersion{
+			Type:      pdu.FilesystemVersion_Snapshot,
+			Name:      e.Snapshot,
+			Guid:      e.GUID,
+			CreateTXG: e.CreateTXG,
+			Creation:  e.Creation,
+		})
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].CreateTXG < versions[j].CreateTXG
+	})
+	return &pdu.ListFilesystemVersionsRes{Versions: versions}, nil
+}
+
+func (r *ArchiveReceiver) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error) {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+	res := &pdu.DestroySnapshotsRes{Results: make([]*pdu.DestroySnapshotRes, len(req.GetSnapshots()))}
+	for i, s := range req.GetSnapshots() {
+		res.Results[i] = &pdu.DestroySnapshotRes{
+			Snapshot: s,
+			Error:    "archived streams cannot be destroyed by zrepl, use keep_receiver rules that keep all snapshots",
+		}
+	}
+	return res, nil
+}
+
+func (r *ArchiveReceiver) WaitForConnectivity(ctx context.Context) error {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+	if _, err := os.Stat(r.conf.Command); err != nil {
+		return errors.Wrap(err, "archive command")
+	}
+	if _, err := os.Stat(filepath.Dir(r.conf.Manifest)); err != nil {
+		return errors.Wrap(err, "archive manifest directory")
+	}
+	return nil
+}
+
+// archiveStreamPeekSize is large enough for the DRR_BEGIN record at the start of a send stream.
+const archiveStreamPeekSize = 512
+
+func (r *ArchiveReceiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.ReadCloser) (*pdu.ReceiveRes, error) {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+	defer receive.Close()
+
+	fs := req.GetFilesystem()
+	to := req.GetTo()
+	if to == nil || to.GetType() != pdu.FilesystemVersion_Snapshot {
+		return nil, fmt.Errorf("receive request must specify a snapshot as `To`")
+	}
+	log := getLogger(ctx).WithField("fs", fs).WithField("to", to.GetName())
+
+	stream := bufio.NewReaderSize(receive, archiveStreamPeekSize)
+	head, err := stream.Peek(archiveStreamPeekSize)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "read send stream")
+	}
+	begin, err := zfs.ParseSendStreamBeginRecord(head)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode send stream")
+	}
+	if begin.ToGUID != to.GetGuid() {
+		return nil, fmt.Errorf("send stream is for guid %d, but request is for snapshot %q with guid %d", begin.ToGUID, to.GetName(), to.GetGuid())
+	}
+
+	entry := ArchiveManifestEntry{
+		Filesystem: fs,
+		Object:     ArchiveObjectName(fs, begin.FromGUID, begin.ToGUID),
+		Snapshot:   to.GetName(),
+		GUID:       to.GetGuid(),
+		CreateTXG:  to.GetCreateTXG(),
+		Creation:   to.GetCreation(),
+	}
+	if begin.IsIncremental() {
+		entries, err := r.readManifest()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.Filesystem == fs && e.GUID == begin.FromGUID {
+				entry.FromSnapshot, entry.FromGUID = e.Snapshot, e.GUID
+			}
+		}
+		if entry.FromGUID == 0 {
+			return nil, fmt.Errorf("incremental base with guid %d of send stream is not in the archive manifest", begin.FromGUID)
+		}
+	}
+
+	log.WithField("object", entry.Object).Info("archive send stream")
+	n, err := r.runCommand(ctx, entry, stream)
+	if err != nil {
+		return nil, err
+	}
+	entry.Bytes = n
+	entry.ArchivedAt = time.Now()
+	if err := r.appendManifest(entry); err != nil {
+		return nil, errors.Wrap(err, "stream was archived but cannot be recorded in manifest")
+	}
+	log.WithField("object", entry.Object).WithField("bytes", n).Info("archived send stream")
+	return &pdu.ReceiveRes{}, nil
+}
+
+// archiveCommandCopyGracePeriod is how long runCommand waits for the remaining stream after the command exited.
+const archiveCommandCopyGracePeriod = time.Second
+
+// runCommand pipes stream into the archive command and returns the number of bytes written.
+// An error is returned if the command fails or does not consume the entire stream,
+// or if reading the stream fails, in which case the command is killed.
+func (r *ArchiveReceiver) runCommand(ctx context.Context, entry ArchiveManifestEntry, stream io.Reader) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if r.conf.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.conf.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, r.conf.Command)
+	cmd.Env = append(os.Environ(),
+		"ZREPL_JOB_NAME="+r.conf.JobID.String(),
+		"ZREPL_ARCHIVE_OBJECT="+entry.Object,
+		"ZREPL_ARCHIVE_FS="+entry.Filesystem,
+		"ZREPL_ARCHIVE_SNAPSHOT="+entry.Snapshot,
+		fmt.Sprintf("ZREPL_ARCHIVE_GUID=%d", entry.GUID),
+		"ZREPL_ARCHIVE_FROM_SNAPSHOT="+entry.FromSnapshot,
+		fmt.Sprintf("ZREPL_ARCHIVE_FROM_GUID=%d", entry.FromGUID),
+		"ZREPL_ARCHIVE_MANIFEST="+r.conf.Manifest,
+	)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// We keep our own copy of the pipe's read end open so that we can check
+	// after the command has exited whether it left bytes unread in the pipe buffer.
+	// exec's StdinPipe doesn't allow that, and small streams fit entirely into the buffer.
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer stdinR.Close()
+	cmd.Stdin = stdinR
+	if err := cmd.Start(); err != nil {
+		stdinW.Close()
+		return 0, errors.Wrap(err, "cannot start archive command")
+	}
+
+	var n int64
+	var copyErr error
+	copyDone := make(chan struct{})
+	go func() {
+		defer close(copyDone)
+		n, copyErr = io.Copy(stdinW, stream)
+		stdinW.Close()
+		if copyErr != nil {
+			cancel() // kill the command so that it cannot store a truncated stream
+		}
+	}()
+	waitErr := cmd.Wait()
+
+	// The command consumed the entire stream iff the pipe is at EOF, i.e., the stream
+	// has been copied completely and nothing is left in the pipe buffer.
+	// If the copy is still in progress (the grace period covers the copy goroutine's
+	// return after closing the pipe), the command exited before EOF.
+	consumed := false
+	select {
+	case <-copyDone:
+		k, readErr := stdinR.Read(make([]byte, 1)) // doesn't block, the write end is closed
+		consumed = k == 0 && readErr == io.EOF
+	case <-time.After(archiveCommandCopyGracePeriod):
+	}
+	stdinR.Close() // unblocks the copy if the pipe buffer is full
+	<-copyDone
+
+	out := bytes.TrimSpace(output.Bytes())
+	if len(out) > 1024 {
+		out = out[len(out)-1024:]
+	}
+	if copyErr != nil {
+		return n, errors.Wrapf(copyErr, "archive command did not consume the entire stream or reading it failed (output: %q)", out)
+	}
+	if waitErr != nil {
+		return n, errors.Wrapf(waitErr, "archive command failed (output: %q)", out)
+	}
+	if !consumed {
+		return n, errors.Errorf("archive command exited without consuming the entire stream (output: %q)", out)
+	}
+	return n, nil
+}
@@ -0,0 +1,138 @@
+package endpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+// fakeSendStream returns a send stream that starts with a DRR_BEGIN record followed by payload.
+func fakeSendStream(toGUID, fromGUID uint64, payload string) []byte {
+	rec := make([]byte, 56+256)
+	binary.LittleEndian.PutUint32(rec[0:4], 0)
+	binary.LittleEndian.PutUint64(rec[8:16], 0x2F5bacbac)
+	binary.LittleEndian.PutUint64(rec[40:48], toGUID)
+	binary.LittleEndian.PutUint64(rec[48:56], fromGUID)
+	copy(rec[56:], "pool/fs@snap")
+	return append(rec, payload...)
+}
+
+func TestArchiveReceiver(t *testing.T) {
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	dir, err := ioutil.TempDir("", "zrepl-archive-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	objects := filepath.Join(dir, "objects")
+	require.NoError(t, os.Mkdir(objects, 0700))
+	command := filepath.Join(dir, "archive.sh")
+	// fails for snapshots named "fail", reads nothing for snapshots named "noread"
+	script := `#!/bin/sh
+set -e
+case "$ZREPL_ARCHIVE_SNAPSHOT" in
+fail) echo "upload failed" >&2; exit 1;;
+noread) exit 0;;
+esac
+f="` + objects + `/$(echo "$ZREPL_ARCHIVE_OBJECT" | tr / _)"
+cat > "$f"
+echo "$ZREPL_JOB_NAME $ZREPL_ARCHIVE_FS $ZREPL_ARCHIVE_SNAPSHOT $ZREPL_ARCHIVE_GUID $ZREPL_ARCHIVE_FROM_SNAPSHOT $ZREPL_ARCHIVE_FROM_GUID" > "$f.env"
+`
+	require.NoError(t, ioutil.WriteFile(command, []byte(script), 0700))
+
+	manifest := filepath.Join(dir, "manifest.jsonl")
+	r := NewArchiveReceiver(ArchiveReceiverConfig{
+		JobID:    MustMakeJobID("archive-job"),
+		Command:  command,
+		Manifest: manifest,
+	})
+	require.NoError(t, r.WaitForConnectivity(ctx))
+
+	receive := func(to *pdu.FilesystemVersion, stream []byte) error {
+		_, err := r.Receive(ctx, &pdu.ReceiveReq{Filesystem: "pool/fs", To: to}, ioutil.NopCloser(bytes.NewReader(stream)))
+		return err
+	}
+	snap := func(name string, guid, txg uint64) *pdu.FilesystemVersion {
+		return &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, Name: name, Guid: guid, CreateTXG: txg, Creation: "2020-01-01T00:00:00Z"}
+	}
+
+	fss, err := r.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
+	require.NoError(t, err)
+	assert.Empty(t, fss.GetFilesystems())
+
+	// incremental stream without base in manifest
+	err = receive(snap("b", 2, 20), fakeSendStream(2, 1, "data"))
+	assert.Error(t, err)
+
+	// stream does not match request
+	err = receive(snap("a", 1, 10), fakeSendStream(3, 0, "data"))
+	assert.Error(t, err)
+
+	require.NoError(t, receive(snap("a", 1, 10), fakeSendStream(1, 0, "full")))
+	require.NoError(t, receive(snap("b", 2, 20), fakeSendStream(2, 1, "incremental")))
+
+	full, err := ioutil.ReadFile(filepath.Join(objects, "pool_fs_full-1"))
+	require.NoError(t, err)
+	assert.Equal(t, fakeSendStream(1, 0, "full"), full)
+	env, err := ioutil.ReadFile(filepath.Join(objects, "pool_fs_incremental-1-2.env"))
+	require.NoError(t, err)
+	assert.Equal(t, "archive-job pool/fs b 2 a 1\n", string(env))
+
+	// failing command and command that does not consume the stream are not recorded
+	err = receive(snap("fail", 3, 30), fakeSendStream(3, 2, "x"))
+	assert.Error(t, err)
+	err = receive(snap("noread", 4, 40), fakeSendStream(4, 2, string(make([]byte, 1<<20))))
+	assert.Error(t, err)
+	// the stream fits into the pipe buffer
+	err = receive(snap("noread", 4, 40), fakeSendStream(4, 2, "x"))
+	assert.Error(t, err)
+
+	entries, err := ReadArchiveManifest(manifest)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "pool/fs/full-1", entries[0].Object)
+	assert.Equal(t, uint64(0), entries[0].FromGUID)
+	assert.Equal(t, int64(len(fakeSendStream(1, 0, "full"))), entries[0].Bytes)
+	assert.Equal(t, "pool/fs/incremental-1-2", entries[1].Object)
+	assert.Equal(t, "a", entries[1].FromSnapshot)
+	assert.Equal(t, uint64(1), entries[1].FromGUID)
+
+	fss, err = r.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
+	require.NoError(t, err)
+	require.Len(t, fss.GetFilesystems(), 1)
+	assert.Equal(t, "pool/fs", fss.GetFilesystems()[0].GetPath())
+
+	vs, err := r.ListFilesystemVersions(ctx, &pdu.ListFilesystemVersionsReq{Filesystem: "pool/fs"})
+	require.NoError(t, err)
+	require.Len(t, vs.GetVersions(), 2)
+	assert.Equal(t, "a", vs.GetVersions()[0].GetName())
+	assert.Equal(t, uint64(2), vs.GetVersions()[1].GetGuid())
+
+	res, err := r.DestroySnapshots(ctx, &pdu.DestroySnapshotsReq{Filesystem: "pool/fs", Snapshots: vs.GetVersions()[:1]})
+	require.NoError(t, err)
+	require.Len(t, res.GetResults(), 1)
+	assert.NotEmpty(t, res.GetResults()[0].GetError())
+}
+
+func TestArchiveReceiverConfigValidate(t *testing.T) {
+	valid := ArchiveReceiverConfig{JobID: MustMakeJobID("j"), Command: "/bin/true", Manifest: "/var/lib/zrepl/manifest.jsonl"}
+	assert.NoError(t, valid.Validate())
+
+	c := valid
+	c.Command = "archive.sh"
+	assert.Error(t, c.Validate())
+	c = valid
+	c.Manifest = "manifest.jsonl"
+	assert.Error(t, c.Validate())
+}
@@ -0,0 +1,121 @@
+package endpoint
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// ClientRootTemplate is a receiver root filesystem with placeholders for the client identity,
+// e.g. "backups/{client_identity}/data".
+//
+// The placeholders are
+//
+//	{client_identity}    the client identity
+//	{client_identity.N}  the N-th (starting at 1) component of the client identity,
+//	                     where components are separated by '.' or '-'
+//
+// {client_identity} must be a path component on its own so that the client roots
+// of distinct clients are disjoint.
+type ClientRootTemplate struct {
+	template   string
+	components []string
+	prefix     *zfs.DatasetPath
+}
+
+var clientRootTemplatePlaceholderRE = regexp.MustCompile(`\{([^{}]*)\}`)
+
+var clientRootTemplateComponentRE = regexp.MustCompile(`^client_identity\.([1-9][0-9]*)$`)
+
+const clientRootTemplateIdentityPlaceholder = "{client_identity}"
+
+// IsClientRootTemplate returns whether s contains placeholders.
+// Braces are not valid in ZFS dataset names, so s cannot be a plain root filesystem.
+func IsClientRootTemplate(s string) bool {
+	return strings.ContainsAny(s, "{}")
+}
+
+func ParseClientRootTemplate(s string) (*ClientRootTemplate, error) {
+	t := &ClientRootTemplate{template: s, components: strings.Split(s, "/")}
+
+	identityComponent := false
+	var prefix []string
+	for i, c := range t.components {
+		if strings.ContainsAny(clientRootTemplatePlaceholderRE.ReplaceAllString(c, ""), "{}") {
+			return nil, errors.Errorf("template %q: unbalanced braces in path component %q", s, c)
+		}
+		for _, m := range clientRootTemplatePlaceholderRE.FindAllStringSubmatch(c, -1) {
+			if m[1] != "client_identity" && !clientRootTemplateComponentRE.MatchString(m[1]) {
+				return nil, errors.Errorf("template %q: unknown placeholder %q, expecting {client_identity} or {client_identity.N}", s, m[0])
+			}
+		}
+		if c == clientRootTemplateIdentityPlaceholder {
+			identityComponent = true
+		}
+		if !IsClientRootTemplate(c) && len(prefix) == i {
+			prefix = append(prefix, c)
+		}
+	}
+	if !identityComponent {
+		return nil, errors.Errorf("template %q must contain %s as a path component on its own, otherwise distinct clients could share a filesystem", s, clientRootTemplateIdentityPlaceholder)
+	}
+	if len(prefix) == 0 {
+		return nil, errors.Errorf("template %q must start with a pool name", s)
+	}
+	var err error
+	t.prefix, err = zfs.NewDatasetPath(strings.Join(prefix, "/"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "template %q", s)
+	}
+	// validate the literal parts by expanding with an identity that has many components
+	if _, err := t.Expand(strings.Repeat("a.", 64) + "a"); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *ClientRootTemplate) String() string { return t.template }
+
+// Prefix returns the path components before the first placeholder,
+// i.e., the part of the client root that is shared by all clients.
+func (t *ClientRootTemplate) Prefix() *zfs.DatasetPath { return t.prefix.Copy() }
+
+// Expand returns the client root filesystem of clientIdentity.
+func (t *ClientRootTemplate) Expand(clientIdentity string) (*zfs.DatasetPath, error) {
+	if err := zfs.ComponentNamecheck(clientIdentity); err != nil {
+		return nil, errors.Wrapf(err, "invalid client identity %q", clientIdentity)
+	}
+	identityComponents := strings.FieldsFunc(clientIdentity, func(r rune) bool { return r == '.' || r == '-' })
+
+	var expandErr error
+	expanded := make([]string, len(t.components))
+	for i, c := range t.components {
+		expanded[i] = clientRootTemplatePlaceholderRE.ReplaceAllStringFunc(c, func(p string) string {
+			if p == clientRootTemplateIdentityPlaceholder {
+				return clientIdentity
+			}
+			n, _ := strconv.Atoi(clientRootTemplateComponentRE.FindStringSubmatch(p[1 : len(p)-1])[1]) // validated by ParseClientRootTemplate
+			if n > len(identityComponents) {
+				expandErr = fmt.Errorf("client identity %q has only %d components, template %q uses %s", clientIdentity, len(identityComponents), t.template, p)
+				return ""
+			}
+			return identityComponents[n-1]
+		})
+		if expandErr != nil {
+			return nil, expandErr
+		}
+		if err := zfs.ComponentNamecheck(expanded[i]); err != nil {
+			return nil, errors.Wrapf(err, "template %q expands to invalid path component %q for client identity %q", t.template, expanded[i], clientIdentity)
+		}
+	}
+	p, err := zfs.NewDatasetPath(strings.Join(expanded, "/"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "template %q expands to invalid dataset path for client identity %q", t.template, clientIdentity)
+	}
+	return p, nil
+}
@@ -0,0 +1,49 @@
+package endpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientRootTemplate(t *testing.T) {
+	tmpl, err := ParseClientRootTemplate("pool/backups/{client_identity.2}/{client_identity}/data")
+	require.NoError(t, err)
+	assert.Equal(t, "pool/backups", tmpl.Prefix().ToString())
+
+	p, err := tmpl.Expand("web1.acme")
+	require.NoError(t, err)
+	assert.Equal(t, "pool/backups/acme/web1.acme/data", p.ToString())
+
+	p, err = tmpl.Expand("db-acme")
+	require.NoError(t, err)
+	assert.Equal(t, "pool/backups/acme/db-acme/data", p.ToString())
+
+	_, err = tmpl.Expand("standalone")
+	assert.Error(t, err)
+	_, err = tmpl.Expand("a/b")
+	assert.Error(t, err)
+
+	tmpl, err = ParseClientRootTemplate("pool/{client_identity.1}-clients/{client_identity}")
+	require.NoError(t, err)
+	p, err = tmpl.Expand("acme.web1")
+	require.NoError(t, err)
+	assert.Equal(t, "pool/acme-clients/acme.web1", p.ToString())
+
+	invalid := []string{
+		"pool/backups",                               // no placeholder
+		"pool/{client_identity.1}",                   // clients could collide
+		"pool/x{client_identity}",                    // clients could collide
+		"{client_identity}",                          // no pool
+		"pool/{client_identity}/{hostname}",          // unknown placeholder
+		"pool/{client_identity.0}/{client_identity}", // components start at 1
+		"pool/{client_identity}}",
+		"pool/{client_identity}/in@valid",
+		"pool//{client_identity}",
+	}
+	for _, s := range invalid {
+		_, err := ParseClientRootTemplate(s)
+		assert.Error(t, err, s)
+	}
+}
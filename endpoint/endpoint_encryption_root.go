@@ -0,0 +1,147 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// EncryptionRootChange determines how the Sender handles incremental raw sends
+// of filesystems whose encryptionroot changed since the last raw send
+// that was confirmed by the receiver.
+//
+// Raw incremental sends across an encryptionroot change (e.g. `zfs change-key -i`
+// or making a child filesystem its own encryption root) are rejected by zfs recv
+// with an error message that does not hint at the cause.
+// Key rotations that keep the encryptionroot (`zfs change-key` without `-i`) are
+// transferred by raw sends and do not require special handling.
+type EncryptionRootChange int
+
+const (
+	// Fail the send with a diagnostic that explains how to re-initialize replication.
+	EncryptionRootChangeFail EncryptionRootChange = iota
+	// Attempt the send anyway, leaving error handling to zfs send / recv.
+	EncryptionRootChangeIgnore
+)
+
+func EncryptionRootChangeFromString(s string) (EncryptionRootChange, error) {
+	switch s {
+	case "fail":
+		return EncryptionRootChangeFail, nil
+	case "ignore":
+		return EncryptionRootChangeIgnore, nil
+	default:
+		return 0, fmt.Errorf("invalid encryption root change handling %q, must be one of 'fail', 'ignore'", s)
+	}
+}
+
+var userPropertyNameRE = regexp.MustCompile(`^[a-z0-9_.:-]+$`)
+
+// LastRawSendEncryptionRootProperty returns the name of the user property
+// in which the Sender records the encryptionroot of a filesystem at the time
+// of the last raw send of job jobid that was confirmed by the receiver.
+func LastRawSendEncryptionRootProperty(jobid JobID) (string, error) {
+	return lastRawSendEncryptionRootPropertyImpl(jobid.String())
+}
+
+func lastRawSendEncryptionRootPropertyImpl(jobid string) (string, error) {
+	// user property names must be lower-case
+	p := fmt.Sprintf("zrepl:last_raw_send_encryptionroot:j_%s", strings.ToLower(jobid))
+	if !userPropertyNameRE.MatchString(p) {
+		return "", fmt.Errorf("job id %q cannot be used in user property name: must only contain lower-case alphanumeric chars and any in %q", jobid, "-_.:")
+	}
+	if len(p) > zfs.MaxDatasetNameLen {
+		return "", fmt.Errorf("user property name %q is too long", p)
+	}
+	return p, nil
+}
+
+type EncryptionRootChangedError struct {
+	Filesystem string
+	JobID      JobID
+	Recorded   string // encryptionroot at the time of the last confirmed raw send
+	Current    string
+}
+
+func (e *EncryptionRootChangedError) Error() string {
+	return fmt.Sprintf("encryptionroot of filesystem %q changed from %q to %q since the last raw send of job %q: "+
+		"ZFS does not support incremental raw sends across encryptionroot changes; "+
+		"either restore the previous encryptionroot, or re-initialize replication by renaming or destroying the filesystem on the receiving side "+
+		"(the next replication will then be a full send), "+
+		"or set `send.encryption_root_change: ignore` to attempt incremental sends anyway",
+		e.Filesystem, e.Recorded, e.Current, e.JobID)
+}
+
+func checkEncryptionRootUnchanged(fs string, jobID JobID, recorded, current string) error {
+	if recorded == "" || recorded == current {
+		return nil // nothing recorded yet (first send or upgrade from older zrepl)
+	}
+	return &EncryptionRootChangedError{
+		Filesystem: fs,
+		JobID:      jobID,
+		Recorded:   recorded,
+		Current:    current,
+	}
+}
+
+// precondition: sendArgs have been validated
+func (s *Sender) checkEncryptionRootForIncrementalRawSend(ctx context.Context, sendArgs zfs.ZFSSendArgsValidated, change EncryptionRootChange) error {
+	if !sendArgs.Encrypted.B || sendArgs.From == nil {
+		return nil
+	}
+	if change == EncryptionRootChangeIgnore {
+		return nil
+	}
+	prop, err := LastRawSendEncryptionRootProperty(s.jobId)
+	if err != nil {
+		getLogger(ctx).WithError(err).Warn("cannot track encryptionroot changes")
+		return nil
+	}
+	props, err := zfs.ZFSGetRawLocalSource(ctx, sendArgs.FS, []string{prop})
+	if err != nil {
+		return errors.Wrap(err, "cannot get recorded encryptionroot")
+	}
+	recorded := props.Get(prop)
+	if recorded == "" {
+		return nil
+	}
+	current, err := zfs.ZFSGetEncryptionRoot(ctx, sendArgs.FS)
+	if err != nil {
+		return err
+	}
+	return checkEncryptionRootUnchanged(sendArgs.FS, s.jobId, recorded, current)
+}
+
+// recordEncryptionRoot records the current encryptionroot of fs for the checks in future incremental raw sends.
+// Must only be called after the receiver has confirmed a raw send.
+func (s *Sender) recordEncryptionRoot(ctx context.Context, fs string) error {
+	prop, err := LastRawSendEncryptionRootProperty(s.jobId)
+	if err != nil {
+		getLogger(ctx).WithError(err).Warn("cannot track encryptionroot changes")
+		return nil
+	}
+	current, err := zfs.ZFSGetEncryptionRoot(ctx, fs)
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		return nil
+	}
+	props, err := zfs.ZFSGetRawLocalSource(ctx, fs, []string{prop})
+	if err != nil {
+		return errors.Wrap(err, "cannot get recorded encryptionroot")
+	}
+	if props.Get(prop) == current {
+		return nil
+	}
+	dp, err := zfs.NewDatasetPath(fs)
+	if err != nil {
+		return err
+	}
+	return zfs.ZFSSet(ctx, dp, map[string]string{prop: current})
+}
@@ -0,0 +1,43 @@
+package endpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastRawSendEncryptionRootProperty(t *testing.T) {
+	p, err := LastRawSendEncryptionRootProperty(MustMakeJobID("Prod-Backup_1.x"))
+	require.NoError(t, err)
+	assert.Equal(t, "zrepl:last_raw_send_encryptionroot:j_prod-backup_1.x", p)
+
+	_, err = LastRawSendEncryptionRootProperty(MustMakeJobID("with space"))
+	assert.Error(t, err)
+}
+
+func TestCheckEncryptionRootUnchanged(t *testing.T) {
+	jobID := MustMakeJobID("push")
+
+	assert.NoError(t, checkEncryptionRootUnchanged("pool/a/b", jobID, "", "pool/a"), "nothing recorded")
+	assert.NoError(t, checkEncryptionRootUnchanged("pool/a/b", jobID, "pool/a", "pool/a"))
+
+	err := checkEncryptionRootUnchanged("pool/a/b", jobID, "pool/a", "pool/a/b")
+	require.Error(t, err)
+	erce, ok := err.(*EncryptionRootChangedError)
+	require.True(t, ok)
+	assert.Equal(t, "pool/a", erce.Recorded)
+	assert.Equal(t, "pool/a/b", erce.Current)
+	assert.Contains(t, err.Error(), "encryption_root_change")
+}
+
+func TestEncryptionRootChangeFromString(t *testing.T) {
+	v, err := EncryptionRootChangeFromString("fail")
+	require.NoError(t, err)
+	assert.Equal(t, EncryptionRootChangeFail, v)
+	v, err = EncryptionRootChangeFromString("ignore")
+	require.NoError(t, err)
+	assert.Equal(t, EncryptionRootChangeIgnore, v)
+	_, err = EncryptionRootChangeFromString("reinit")
+	assert.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package endpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestForceRollbackDestroyedSnapshots(t *testing.T) {
+
+	snap := func(name string, guid, txg uint64) zfs.FilesystemVersion {
+		return zfs.FilesystemVersion{Type: zfs.Snapshot, Name: name, Guid: guid, CreateTXG: txg}
+	}
+	snaps := []zfs.FilesystemVersion{
+		snap("a", 1, 10),
+		snap("b", 2, 20),
+		snap("c", 3, 30),
+	}
+
+	t.Run("full", func(t *testing.T) {
+		d, err := forceRollbackDestroyedSnapshots(&zfs.SendStreamBeginRecord{ToGUID: 4}, snaps)
+		require.NoError(t, err)
+		assert.Equal(t, snaps, d)
+	})
+
+	t.Run("incremental_from_latest", func(t *testing.T) {
+		d, err := forceRollbackDestroyedSnapshots(&zfs.SendStreamBeginRecord{ToGUID: 4, FromGUID: 3}, snaps)
+		require.NoError(t, err)
+		assert.Empty(t, d)
+	})
+
+	t.Run("incremental_diverged", func(t *testing.T) {
+		d, err := forceRollbackDestroyedSnapshots(&zfs.SendStreamBeginRecord{ToGUID: 4, FromGUID: 1}, snaps)
+		require.NoError(t, err)
+		assert.Equal(t, []zfs.FilesystemVersion{snaps[1], snaps[2]}, d)
+	})
+
+	t.Run("incremental_source_missing", func(t *testing.T) {
+		_, err := forceRollbackDestroyedSnapshots(&zfs.SendStreamBeginRecord{ToGUID: 4, FromGUID: 23}, snaps)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,91 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// A pin is a user hold that an operator places through `zrepl snapshots pin`
+// to exclude a snapshot from pruning. Unlike step holds and last-received-holds,
+// zrepl never releases pins on its own.
+
+var pinHoldTagRE = regexp.MustCompile("^zrepl_PIN_J_(.+)")
+
+func PinHoldTag(jobID JobID) (string, error) {
+	t := fmt.Sprintf("zrepl_PIN_J_%s", jobID.String())
+	if err := zfs.ValidHoldTag(t); err != nil {
+		return "", err
+	}
+	return t, nil
+}
+
+// err != nil always means that the hold is not a pin
+func ParsePinHoldTag(tag string) (JobID, error) {
+	match := pinHoldTagRE.FindStringSubmatch(tag)
+	if match == nil {
+		return JobID{}, fmt.Errorf("parse hold tag: match regex %q", pinHoldTagRE)
+	}
+	jobID, err := MakeJobID(match[1])
+	if err != nil {
+		return JobID{}, errors.Wrap(err, "parse hold tag: invalid job id field")
+	}
+	return jobID, nil
+}
+
+// PinnedSnapshots returns the GUIDs of the snapshots of fs that are pinned by any job.
+// Only snapshots with user holds are inspected with `zfs holds`.
+func PinnedSnapshots(ctx context.Context, fs *zfs.DatasetPath) (map[uint64]bool, error) {
+	versions, err := zfs.ZFSListFilesystemVersions(ctx, fs, zfs.ListFilesystemVersionsOptions{Types: zfs.Snapshots})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list snapshots")
+	}
+	pinned := make(map[uint64]bool)
+	for _, v := range versions {
+		if !v.UserRefs.Valid || v.UserRefs.Value == 0 {
+			continue
+		}
+		tags, err := zfs.ZFSHolds(ctx, fs.ToString(), v.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot list holds of snapshot %q", v.Name)
+		}
+		for _, tag := range tags {
+			if _, err := ParsePinHoldTag(tag); err == nil {
+				pinned[v.Guid] = true
+				break
+			}
+		}
+	}
+	return pinned, nil
+}
+
+// PinnedSnapshots implements pruner.PinTarget.
+func (p *Sender) PinnedSnapshots(ctx context.Context, fs string) (map[uint64]bool, error) {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+
+	dp, err := p.filterCheckFS(fs)
+	if err != nil {
+		return nil, err
+	}
+	return PinnedSnapshots(ctx, dp)
+}
+
+// PinnedSnapshots implements pruner.PinTarget.
+func (s *Receiver) PinnedSnapshots(ctx context.Context, fs string) (map[uint64]bool, error) {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+
+	root, err := s.clientRootFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lp, err := subroot{root}.MapToLocal(fs)
+	if err != nil {
+		return nil, err
+	}
+	return PinnedSnapshots(ctx, lp)
+}
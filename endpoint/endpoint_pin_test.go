@@ -0,0 +1,25 @@
+package endpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinHoldTag(t *testing.T) {
+	jobID := MustMakeJobID("prod-push")
+
+	tag, err := PinHoldTag(jobID)
+	require.NoError(t, err)
+	parsed, err := ParsePinHoldTag(tag)
+	require.NoError(t, err)
+	assert.Equal(t, jobID, parsed)
+
+	stepTag, err := StepHoldTag(jobID)
+	require.NoError(t, err)
+	_, err = ParsePinHoldTag(stepTag)
+	assert.Error(t, err)
+	_, err = ParsePinHoldTag("backup")
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zrepl/zrepl/util/envconst"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// Send flags that produce streams which only pools with a particular feature can receive.
+// Receiving sides advertise which of these features their pool supports during the
+// connection handshake, and senders with SendOptions.NegotiateFeatures drop the flags
+// that the peer's pool does not support.
+//
+// Flags that change what is sent rather than how, e.g. raw sends of encrypted data,
+// are never dropped.
+var recvFeatureFlags = []struct {
+	feature  string
+	zfsFlag  string
+	sendFlag func(o *SendOptions) *bool
+}{
+	{"large_blocks", "-L", func(o *SendOptions) *bool { return &o.SendLargeBlocks }},
+	{"embedded_data", "-e", func(o *SendOptions) *bool { return &o.SendEmbeddedData }},
+}
+
+func negotiatedRecvFeatures() []string {
+	features := make([]string, len(recvFeatureFlags))
+	for i, f := range recvFeatureFlags {
+		features[i] = f.feature
+	}
+	return features
+}
+
+// withoutUnsupportedRecvFeatures returns a copy of o without the send flags whose pool feature is not in supported,
+// and a description of each dropped flag.
+func (o SendOptions) withoutUnsupportedRecvFeatures(supported []string) (_ SendOptions, dropped []string) {
+	sup := make(map[string]bool, len(supported))
+	for _, f := range supported {
+		sup[f] = true
+	}
+	for _, f := range recvFeatureFlags {
+		flag := f.sendFlag(&o)
+		if *flag && !sup[f.feature] {
+			*flag = false
+			dropped = append(dropped, fmt.Sprintf("%s (feature@%s)", f.zfsFlag, f.feature))
+		}
+	}
+	return o, dropped
+}
+
+// PeerRecvFeatures records the pool features that the receiving peers of a sending job
+// advertised during the handshake, by client identity.
+type PeerRecvFeatures struct {
+	mtx      sync.Mutex
+	byClient map[string][]string
+}
+
+func NewPeerRecvFeatures() *PeerRecvFeatures {
+	return &PeerRecvFeatures{byClient: make(map[string][]string)}
+}
+
+// Observe records the features advertised by the peer with clientIdentity, replacing earlier ones.
+func (p *PeerRecvFeatures) Observe(clientIdentity string, features []string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.byClient[clientIdentity] = append([]string(nil), features...)
+}
+
+// Get returns false if the peer with clientIdentity did not advertise its features (yet).
+func (p *PeerRecvFeatures) Get(clientIdentity string) (features []string, ok bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	features, ok = p.byClient[clientIdentity]
+	return features, ok
+}
+
+// negotiateRecvFeatures returns opts without the send flags that the requesting peer's pool does not support.
+// If the peer did not advertise its features, opts is returned unchanged.
+func (s *Sender) negotiateRecvFeatures(ctx context.Context, opts *SendOptions) *SendOptions {
+	if !opts.NegotiateFeatures || s.config.PeerRecvFeatures == nil {
+		return opts
+	}
+	log := getLogger(ctx)
+	clientIdentity, _ := ctx.Value(ClientIdentityKey).(string)
+	supported, ok := s.config.PeerRecvFeatures.Get(clientIdentity)
+	if !ok {
+		log.Debug("receiving side did not advertise its pool features, sending with the configured flags")
+		return opts
+	}
+	negotiated, dropped := opts.withoutUnsupportedRecvFeatures(supported)
+	if len(dropped) == 0 {
+		return opts
+	}
+	log.WithField("dropped_flags", dropped).
+		Warn("receiving pool does not support the features required by some send flags, sending without them (negotiate_features)")
+	return &negotiated
+}
+
+var recvFeaturesRecheckInterval = envconst.Duration("ZREPL_RECV_FEATURES_RECHECK_INTERVAL", 1*time.Minute)
+
+// RecvFeaturesAdvertiser determines the features of the pool that a receiving job receives into,
+// for advertising them to the sending peer (see versionhandshake.RecvFeaturesAdvertiser).
+// The result is cached because the handshake of every connection asks for it.
+type RecvFeaturesAdvertiser struct {
+	fs *zfs.DatasetPath
+
+	mtx       sync.Mutex
+	features  []string
+	ok        bool
+	checkedAt time.Time
+}
+
+func NewRecvFeaturesAdvertiser(fs *zfs.DatasetPath) *RecvFeaturesAdvertiser {
+	return &RecvFeaturesAdvertiser{fs: fs}
+}
+
+// AdvertisedRecvFeatures returns false if the features cannot be determined, e.g. because the pool is not imported.
+func (a *RecvFeaturesAdvertiser) AdvertisedRecvFeatures() ([]string, bool) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if !a.checkedAt.IsZero() && time.Since(a.checkedAt) < recvFeaturesRecheckInterval {
+		return a.features, a.ok
+	}
+	a.features, a.ok = nil, false
+	a.checkedAt = time.Now()
+
+	pool, err := a.fs.Pool()
+	if err != nil {
+		return nil, false
+	}
+	// the handshake has its own deadline, don't stall it
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	supported, err := zfs.ZPoolGetFeatures(ctx, pool, negotiatedRecvFeatures())
+	if err != nil {
+		return nil, false
+	}
+	a.features = []string{}
+	for _, f := range negotiatedRecvFeatures() {
+		if supported[f] {
+			a.features = append(a.features, f)
+		}
+	}
+	a.ok = true
+	return a.features, a.ok
+}
@@ -0,0 +1,56 @@
+package endpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zrepl/zrepl/util/nodefault"
+)
+
+func TestSendOptionsWithoutUnsupportedRecvFeatures(t *testing.T) {
+	o := SendOptions{
+		Encrypt:          &nodefault.Bool{B: true},
+		SendRaw:          true,
+		SendLargeBlocks:  true,
+		SendEmbeddedData: true,
+	}
+
+	n, dropped := o.withoutUnsupportedRecvFeatures([]string{"large_blocks", "embedded_data"})
+	assert.Empty(t, dropped)
+	assert.Equal(t, o, n)
+
+	n, dropped = o.withoutUnsupportedRecvFeatures([]string{"embedded_data"})
+	assert.Equal(t, []string{"-L (feature@large_blocks)"}, dropped)
+	assert.False(t, n.SendLargeBlocks)
+	assert.True(t, n.SendEmbeddedData)
+	assert.True(t, n.SendRaw, "raw sends are never dropped")
+	assert.True(t, o.SendLargeBlocks, "original must not be modified")
+
+	n, dropped = o.withoutUnsupportedRecvFeatures(nil)
+	assert.Len(t, dropped, 2)
+	assert.False(t, n.SendLargeBlocks)
+	assert.False(t, n.SendEmbeddedData)
+}
+
+func TestSenderNegotiateRecvFeatures(t *testing.T) {
+	peers := NewPeerRecvFeatures()
+	peers.Observe("client1", []string{"embedded_data"})
+	s := &Sender{config: SenderConfig{PeerRecvFeatures: peers}}
+
+	opts := &SendOptions{SendLargeBlocks: true, SendEmbeddedData: true, NegotiateFeatures: true}
+
+	ctx := context.WithValue(context.Background(), ClientIdentityKey, "client1")
+	n := s.negotiateRecvFeatures(ctx, opts)
+	assert.False(t, n.SendLargeBlocks)
+	assert.True(t, n.SendEmbeddedData)
+	assert.True(t, opts.SendLargeBlocks, "config must not be modified")
+
+	ctx = context.WithValue(context.Background(), ClientIdentityKey, "client2")
+	assert.Same(t, opts, s.negotiateRecvFeatures(ctx, opts), "unknown peer")
+
+	opts.NegotiateFeatures = false
+	ctx = context.WithValue(context.Background(), ClientIdentityKey, "client1")
+	assert.Same(t, opts, s.negotiateRecvFeatures(ctx, opts), "negotiation disabled")
+}
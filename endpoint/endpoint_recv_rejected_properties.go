@@ -0,0 +1,59 @@
+package endpoint
+
+import (
+	"sync"
+
+	zfsprop "github.com/zrepl/zrepl/zfs/property"
+)
+
+// rejectedProperties remembers, per local filesystem, the properties that zfs recv
+// could not set so that subsequent receives exclude them (zfs recv -x),
+// see ReceiverConfig.ExcludeRejectedProperties.
+// The properties are only kept in memory, a daemon restart learns them again from the next failed receive.
+type rejectedProperties struct {
+	mtx  sync.Mutex
+	byFS map[string][]zfsprop.Property
+}
+
+func newRejectedProperties() *rejectedProperties {
+	return &rejectedProperties{byFS: make(map[string][]zfsprop.Property)}
+}
+
+// add returns the properties that were not yet known to be rejected for fs.
+func (r *rejectedProperties) add(fs string, props []zfsprop.Property) (added []zfsprop.Property) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+outer:
+	for _, p := range props {
+		for _, known := range r.byFS[fs] {
+			if known == p {
+				continue outer
+			}
+		}
+		r.byFS[fs] = append(r.byFS[fs], p)
+		added = append(added, p)
+	}
+	return added
+}
+
+// inherit returns a copy of inherit with the rejected properties of fs appended.
+// Properties in override are not excluded because zfs recv does not accept -x and -o for the same property.
+func (r *rejectedProperties) inherit(fs string, inherit []zfsprop.Property, override map[zfsprop.Property]string) []zfsprop.Property {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	out := make([]zfsprop.Property, len(inherit), len(inherit)+len(r.byFS[fs]))
+	copy(out, inherit)
+outer:
+	for _, p := range r.byFS[fs] {
+		if _, ok := override[p]; ok {
+			continue
+		}
+		for _, i := range inherit {
+			if i == p {
+				continue outer
+			}
+		}
+		out = append(out, p)
+	}
+	return out
+}
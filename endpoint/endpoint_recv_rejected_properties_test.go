@@ -0,0 +1,28 @@
+package endpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	zfsprop "github.com/zrepl/zrepl/zfs/property"
+)
+
+func TestRejectedProperties(t *testing.T) {
+	r := newRejectedProperties()
+	inherit := []zfsprop.Property{"mountpoint"}
+	override := map[zfsprop.Property]string{"compression": "lz4"}
+
+	assert.Equal(t, inherit, r.inherit("pool/a", inherit, override))
+
+	added := r.add("pool/a", []zfsprop.Property{"special_small_blocks", "compression", "mountpoint"})
+	assert.Equal(t, []zfsprop.Property{"special_small_blocks", "compression", "mountpoint"}, added)
+	assert.Empty(t, r.add("pool/a", []zfsprop.Property{"special_small_blocks"}), "already known")
+
+	// overridden properties are not excluded, inherited ones are not duplicated
+	got := r.inherit("pool/a", inherit, override)
+	assert.Equal(t, []zfsprop.Property{"mountpoint", "special_small_blocks"}, got)
+	assert.Equal(t, []zfsprop.Property{"mountpoint"}, inherit, "input must not be modified")
+
+	assert.Equal(t, inherit, r.inherit("pool/b", inherit, override), "other filesystems are unaffected")
+}
@@ -0,0 +1,66 @@
+package endpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/util/nodefault"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// prefixFilter passes the dataset prefix and its children
+type prefixFilter string
+
+func (f prefixFilter) Filter(p *zfs.DatasetPath) (bool, error) {
+	prefix, err := zfs.NewDatasetPath(string(f))
+	if err != nil {
+		return false, err
+	}
+	return p.HasPrefix(prefix), nil
+}
+
+func TestSenderConfigSendOptions(t *testing.T) {
+	c := SenderConfig{
+		FSF:         zfs.NoFilter(),
+		JobID:       MustMakeJobID("j"),
+		SendOptions: SendOptions{Encrypt: &nodefault.Bool{B: false}, SendCompressed: true},
+		Overrides: []SendOptionsOverride{
+			{FSF: prefixFilter("pool/media"), SendOptions: SendOptions{Encrypt: &nodefault.Bool{B: false}, SendLargeBlocks: true}},
+			{FSF: prefixFilter("pool"), SendOptions: SendOptions{Encrypt: &nodefault.Bool{B: true}}},
+		},
+	}
+	require.NoError(t, c.Validate())
+
+	optsFor := func(fs string) *SendOptions {
+		dp, err := zfs.NewDatasetPath(fs)
+		require.NoError(t, err)
+		o, err := c.sendOptions(dp)
+		require.NoError(t, err)
+		return o
+	}
+	assert.True(t, optsFor("other/fs").SendCompressed)
+	assert.False(t, optsFor("pool/media/movies").SendCompressed)
+	assert.True(t, optsFor("pool/media/movies").SendLargeBlocks)
+	assert.True(t, optsFor("pool/media2").Encrypt.B, "first matching override applies")
+
+	// SendCompleted records the encryptionroot per filesystem
+	encrypted := func(fs string) bool {
+		dp, err := zfs.NewDatasetPath(fs)
+		require.NoError(t, err)
+		e, err := c.sendsEncrypted(dp)
+		require.NoError(t, err)
+		return e
+	}
+	assert.True(t, encrypted("pool/media2"), "override enables encryption")
+	assert.False(t, encrypted("pool/media/movies"), "override disables encryption")
+	assert.False(t, encrypted("other/fs"), "job-level send options")
+	c.SendOptions.Encrypt = &nodefault.Bool{B: true}
+	assert.True(t, encrypted("other/fs"), "job-level send options")
+	assert.False(t, encrypted("pool/media/movies"), "override disables encryption")
+	c.SendOptions.Encrypt = &nodefault.Bool{B: false}
+
+	c.Overrides[1].Encrypt = nil
+	assert.Error(t, c.Validate())
+}
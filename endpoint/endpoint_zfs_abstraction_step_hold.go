@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -81,3 +83,46 @@ func StepHoldExtractor(fs *zfs.DatasetPath, v zfs.FilesystemVersion, holdTag str
 	}
 	return nil
 }
+
+// ReleaseStepHoldsPlacedBefore releases the step holds of jobID on the filesystems matched by fsFilter
+// that were placed before cutoff, i.e., the sending side's resumable state of transfers that
+// were interrupted before cutoff and not completed since.
+// It returns the released step holds.
+func ReleaseStepHoldsPlacedBefore(ctx context.Context, fsFilter zfs.DatasetFilter, jobID JobID, cutoff time.Time) (released []Abstraction, _ error) {
+	q := ListZFSHoldsAndBookmarksQuery{
+		FS:          ListZFSHoldsAndBookmarksQueryFilesystemFilter{Filter: fsFilter},
+		What:        AbstractionTypeSet{AbstractionStepHold: true},
+		JobID:       &jobID,
+		Concurrency: 1,
+	}
+	abs, absErrs, err := ListAbstractions(ctx, q)
+	if err != nil {
+		return nil, errors.Wrap(err, "list step holds")
+	}
+	if len(absErrs) > 0 {
+		return nil, ListAbstractionsErrors(absErrs)
+	}
+	var errs []string
+	for _, a := range abs {
+		h := a.(*holdBasedAbstraction)
+		holds, err := zfs.ZFSHoldsWithTimestamps(ctx, h.FS, h.GetName())
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		for _, info := range holds {
+			if info.Tag != h.Tag || !info.Created.Before(cutoff) {
+				continue
+			}
+			if err := h.Destroy(ctx); err != nil {
+				errs = append(errs, err.Error())
+				break
+			}
+			released = append(released, h)
+		}
+	}
+	if len(errs) > 0 {
+		return released, errors.Errorf("cannot release step holds: %s", strings.Join(errs, "; "))
+	}
+	return released, nil
+}
@@ -12,6 +12,7 @@ func init() {
 	cli.AddSubcommand(daemon.DaemonCmd)
 	cli.AddSubcommand(status.Subcommand)
 	cli.AddSubcommand(client.SignalCmd)
+	cli.AddSubcommand(client.RunCmd)
 	cli.AddSubcommand(client.StdinserverCmd)
 	cli.AddSubcommand(client.ConfigcheckCmd)
 	cli.AddSubcommand(client.VersionCmd)
@@ -19,6 +20,8 @@ func init() {
 	cli.AddSubcommand(client.TestCmd)
 	cli.AddSubcommand(client.MigrateCmd)
 	cli.AddSubcommand(client.ZFSAbstractionsCmd)
+	cli.AddSubcommand(client.SnapshotsCmd)
+	cli.AddSubcommand(client.StateCmd)
 }
 
 func main() {
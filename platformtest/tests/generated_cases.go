@@ -14,8 +14,10 @@ var Cases = []Case{BatchDestroy,
 	ListFilesystemVersionsUserrefs,
 	ListFilesystemVersionsZeroExistIsNotAnError,
 	ListFilesystemsNoFilter,
+	PlaceholderEncryptionInheritAndOff,
 	ReceiveForceIntoEncryptedErr,
 	ReceiveForceRollbackWorksUnencrypted,
+	ReceiveMountNoautoAndNever,
 	ReplicationFailingInitialParentProhibitsChildReplication,
 	ReplicationIncrementalCleansUpStaleAbstractionsWithCacheOnSecondReplication,
 	ReplicationIncrementalCleansUpStaleAbstractionsWithoutCacheOnSecondReplication,
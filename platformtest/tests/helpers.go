@@ -50,7 +50,7 @@ func mustSnapshot(ctx *platformtest.Context, snap string) {
 	if len(comps) != 2 {
 		panic(comps)
 	}
-	err := zfs.ZFSSnapshot(ctx, mustDatasetPath(comps[0]), comps[1], false)
+	err := zfs.ZFSSnapshot(ctx, mustDatasetPath(comps[0]), comps[1], false, nil)
 	if err != nil {
 		panic(err)
 	}
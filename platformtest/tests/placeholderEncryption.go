@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"fmt"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/platformtest"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func PlaceholderEncryptionInheritAndOff(ctx *platformtest.Context) {
+
+	supported, err := zfs.EncryptionCLISupported(ctx)
+	require.NoError(ctx, err, "encryption feature test failed")
+	if !supported {
+		ctx.SkipNow()
+		return
+	}
+
+	platformtest.Run(ctx, platformtest.PanicErr, ctx.RootDataset, `
+		DESTROYROOT
+		CREATEROOT
+		+  "enc" encrypted
+		+  "plain"
+	`)
+
+	type tc struct {
+		parent          string
+		encryption      zfs.PlaceholderCreationEncryptionProperty
+		expectEncrypted bool
+		expectCheckErr  bool
+	}
+	tcs := []tc{
+		{"enc", zfs.PlaceholderCreationEncryptionPropertyInherit, true, false},
+		{"enc", zfs.PlaceholderCreationEncryptionPropertyOff, false, true},
+		{"plain", zfs.PlaceholderCreationEncryptionPropertyInherit, false, false},
+		{"plain", zfs.PlaceholderCreationEncryptionPropertyOff, false, false},
+	}
+	for i, c := range tcs {
+		parent := mustDatasetPath(fmt.Sprintf("%s/%s", ctx.RootDataset, c.parent))
+		ph := mustDatasetPath(fmt.Sprintf("%s/%s/ph%d", ctx.RootDataset, c.parent, i))
+		err := zfs.ZFSCreatePlaceholderFilesystem(ctx, ph, parent, c.encryption)
+		require.NoError(ctx, err, "%#v", c)
+
+		encrypted, err := zfs.ZFSGetEncryptionEnabled(ctx, ph.ToString())
+		require.NoError(ctx, err)
+		require.Equal(ctx, c.expectEncrypted, encrypted, "%#v", c)
+
+		state, err := zfs.ZFSGetFilesystemPlaceholderState(ctx, ph)
+		require.NoError(ctx, err)
+		require.True(ctx, state.IsPlaceholder)
+
+		err = zfs.ZFSCheckEncryptionInherited(ctx, ph)
+		if c.expectCheckErr {
+			require.IsType(ctx, &zfs.UnencryptedBelowEncryptedParentError{}, err, "%#v", c)
+		} else {
+			require.NoError(ctx, err, "%#v", c)
+		}
+	}
+
+	// children of an inherited placeholder inherit the encryptionroot as well
+	nested := mustDatasetPath(fmt.Sprintf("%s/enc/ph0/nested", ctx.RootDataset))
+	err = zfs.ZFSCreatePlaceholderFilesystem(ctx, nested, mustDatasetPath(fmt.Sprintf("%s/enc/ph0", ctx.RootDataset)), zfs.PlaceholderCreationEncryptionPropertyInherit)
+	require.NoError(ctx, err)
+	root, err := zfs.ZFSGetEncryptionRoot(ctx, nested.ToString())
+	require.NoError(ctx, err)
+	require.Equal(ctx, fmt.Sprintf("%s/enc", ctx.RootDataset), root)
+}
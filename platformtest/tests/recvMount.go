@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"fmt"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/platformtest"
+	"github.com/zrepl/zrepl/util/nodefault"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func ReceiveMountNoautoAndNever(ctx *platformtest.Context) {
+	platformtest.Run(ctx, platformtest.PanicErr, ctx.RootDataset, `
+		DESTROYROOT
+		CREATEROOT
+		+  "sender"
+		+  "sender@1"
+	`)
+
+	sfs := fmt.Sprintf("%s/sender", ctx.RootDataset)
+	sfsSnap1 := sendArgVersion(ctx, sfs, "@1")
+
+	for _, c := range []struct {
+		name     string
+		mount    zfs.RecvMountBehavior
+		canmount string
+	}{
+		{"noauto", zfs.RecvMountNoauto, "noauto"},
+		{"never", zfs.RecvMountNever, "off"},
+	} {
+		rfs := fmt.Sprintf("%s/receiver-%s", ctx.RootDataset, c.name)
+
+		sendArgs, err := zfs.ZFSSendArgsUnvalidated{
+			FS:   sfs,
+			From: nil,
+			To:   &sfsSnap1,
+			ZFSSendFlags: zfs.ZFSSendFlags{
+				Encrypted:   &nodefault.Bool{B: false},
+				ResumeToken: "",
+			},
+		}.Validate(ctx)
+		require.NoError(ctx, err)
+
+		sendStream, err := zfs.ZFSSend(ctx, sendArgs)
+		require.NoError(ctx, err)
+
+		err = zfs.ZFSRecv(ctx, rfs, &zfs.ZFSSendArgVersion{RelName: "@1", GUID: sfsSnap1.GUID}, sendStream, zfs.RecvOptions{Mount: c.mount})
+		require.NoError(ctx, err)
+
+		props, err := zfs.ZFSGet(ctx, mustDatasetPath(rfs), []string{"canmount", "mounted"})
+		require.NoError(ctx, err)
+		assert.Equal(ctx, c.canmount, props.Get("canmount"), c.name)
+		assert.Equal(ctx, "no", props.Get("mounted"), c.name)
+	}
+}
@@ -0,0 +1,188 @@
+package pruning
+
+import (
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+type anchoredPeriod int
+
+const (
+	anchoredMonthly anchoredPeriod = iota
+	anchoredYearly
+)
+
+// KeepAnchored keeps, for each of the count most recent calendar anchors
+// (e.g. midnight on the 1st of each month), the snapshot nearest to that anchor.
+//
+// Each snapshot that matches the regex is assigned to the anchor nearest to it,
+// i.e., the boundary between two adjacent anchors is the midpoint between them.
+// Among the snapshots assigned to an anchor, the one nearest to the anchor is kept
+// (the older one if two are equally near).
+// Anchors without snapshots keep nothing: count refers to anchors, not to kept snapshots.
+//
+// Like the grid rule, the date of the youngest matching snapshot is used as 'now'.
+// In addition to the count most recent anchors at or before 'now', the upcoming anchor
+// is considered, so that a snapshot taken shortly before it is not destroyed before
+// the anchor has passed.
+type KeepAnchored struct {
+	period anchoredPeriod
+	month  time.Month
+	day    int
+	hour   int
+	loc    *time.Location
+	count  int
+	re     *regexp.Regexp
+}
+
+func NewKeepAnchored(in *config.PruneKeepAnchored) (*KeepAnchored, error) {
+	var period anchoredPeriod
+	switch in.Period {
+	case "monthly":
+		period = anchoredMonthly
+	case "yearly":
+		period = anchoredYearly
+	default:
+		return nil, errors.Errorf("invalid period %q, must be one of monthly, yearly", in.Period)
+	}
+	if in.Month < 1 || in.Month > 12 {
+		return nil, errors.Errorf("month must be in [1, 12], got %d", in.Month)
+	}
+	if in.Day < 1 || in.Day > 31 {
+		return nil, errors.Errorf("day must be in [1, 31], got %d", in.Day)
+	}
+	if in.Hour < 0 || in.Hour > 23 {
+		return nil, errors.Errorf("hour must be in [0, 23], got %d", in.Hour)
+	}
+	if in.Count <= 0 {
+		return nil, errors.Errorf("count must be positive, got %d", in.Count)
+	}
+	loc, err := time.LoadLocation(in.TimeZone)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid time_zone %q", in.TimeZone)
+	}
+	re, err := regexp.Compile(in.Regex)
+	if err != nil {
+		return nil, errors.Errorf("invalid regex %q: %s", in.Regex, err)
+	}
+	return &KeepAnchored{
+		period: period,
+		month:  time.Month(in.Month),
+		day:    in.Day,
+		hour:   in.Hour,
+		loc:    loc,
+		count:  in.Count,
+		re:     re,
+	}, nil
+}
+
+// anchor returns the anchor time of period index i.
+// For monthly anchors, i counts months since year 0, for yearly anchors, it is the year.
+// The day is clamped to the last day of the month, e.g. day 31 in February.
+func (k *KeepAnchored) anchor(i int) time.Time {
+	year, month := i, k.month
+	if k.period == anchoredMonthly {
+		year, month = i/12, time.Month(i%12+1)
+	}
+	day := k.day
+	if last := time.Date(year, month+1, 0, 0, 0, 0, 0, k.loc).Day(); day > last {
+		day = last
+	}
+	return time.Date(year, month, day, k.hour, 0, 0, 0, k.loc)
+}
+
+// periodIndex returns the index of the period that contains t, see anchor.
+func (k *KeepAnchored) periodIndex(t time.Time) int {
+	t = t.In(k.loc)
+	if k.period == anchoredMonthly {
+		return t.Year()*12 + int(t.Month()) - 1
+	}
+	return t.Year()
+}
+
+func (k *KeepAnchored) KeepRule(snaps []Snapshot) (destroyList []Snapshot) {
+
+	matching, notMatching := partitionSnapList(snaps, func(snapshot Snapshot) bool {
+		return k.re.MatchString(snapshot.Name())
+	})
+	// snaps that don't match the regex are not kept by this rule
+	destroyList = append(destroyList, notMatching...)
+
+	if len(matching) == 0 {
+		return destroyList
+	}
+
+	now := matching[0].Date()
+	for _, s := range matching {
+		if s.Date().After(now) {
+			now = s.Date()
+		}
+	}
+
+	// upcoming is the index of the first anchor after now
+	upcoming := k.periodIndex(now)
+	if !k.anchor(upcoming).After(now) {
+		upcoming++
+	}
+	first := upcoming - k.count
+
+	// anchors[j] is the anchor with index first+j,
+	// bucket j is [lower[j], lower[j+1]), the last bucket is unbounded
+	anchors := make([]time.Time, k.count+1)
+	lower := make([]time.Time, k.count+1)
+	for j := range anchors {
+		anchors[j] = k.anchor(first + j)
+		prev := k.anchor(first + j - 1)
+		lower[j] = prev.Add(anchors[j].Sub(prev) / 2)
+	}
+
+	distance := func(s Snapshot, anchor time.Time) time.Duration {
+		d := s.Date().Sub(anchor)
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	nearerThan := func(a, b Snapshot, anchor time.Time) bool {
+		da, db := distance(a, anchor), distance(b, anchor)
+		if da != db {
+			return da < db
+		}
+		if !a.Date().Equal(b.Date()) {
+			return a.Date().Before(b.Date())
+		}
+		return a.Name() < b.Name()
+	}
+
+	keep := make([]Snapshot, len(anchors))
+	for _, s := range matching {
+		// index of the last bucket whose lower bound is <= s.Date()
+		j := sort.Search(len(lower), func(j int) bool {
+			return lower[j].After(s.Date())
+		}) - 1
+		if j < 0 {
+			continue // older than the oldest anchor's bucket
+		}
+		if keep[j] == nil || nearerThan(s, keep[j], anchors[j]) {
+			keep[j] = s
+		}
+	}
+
+	kept := make(map[Snapshot]bool, len(keep))
+	for _, s := range keep {
+		if s != nil {
+			kept[s] = true
+		}
+	}
+	for _, s := range matching {
+		if !kept[s] {
+			destroyList = append(destroyList, s)
+		}
+	}
+	return destroyList
+}
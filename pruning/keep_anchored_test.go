@@ -0,0 +1,177 @@
+package pruning
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func mustKeepAnchored(in config.PruneKeepAnchored) *KeepAnchored {
+	if in.TimeZone == "" {
+		in.TimeZone = "UTC"
+	}
+	if in.Month == 0 {
+		in.Month = 1
+	}
+	if in.Day == 0 {
+		in.Day = 1
+	}
+	k, err := NewKeepAnchored(&in)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func TestKeepAnchored(t *testing.T) {
+
+	d := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	daily := func(from, to time.Time) []Snapshot {
+		var snaps []Snapshot
+		for t := from; !t.After(to); t = t.Add(24 * time.Hour) {
+			snaps = append(snaps, stubSnap{name: t.Format("2006-01-02_15:04"), date: t})
+		}
+		return snaps
+	}
+	destroyAllBut := func(snaps []Snapshot, keep ...string) map[string]bool {
+		m := make(map[string]bool, len(snaps))
+		for _, s := range snaps {
+			m[s.Name()] = true
+		}
+		for _, k := range keep {
+			delete(m, k)
+		}
+		return m
+	}
+
+	// daily snapshots at 23:00 from 2020-01-15 to 2020-05-10
+	dailyLate := daily(d(2020, 1, 15, 23, 0), d(2020, 5, 10, 23, 0))
+
+	// monthly snapshots with a gap in March
+	gap := []Snapshot{
+		stubSnap{name: "jan", date: d(2020, 1, 1, 0, 5)},
+		stubSnap{name: "feb", date: d(2020, 2, 1, 0, 5)},
+		stubSnap{name: "apr1", date: d(2020, 4, 1, 0, 5)},
+		stubSnap{name: "apr2", date: d(2020, 4, 2, 0, 5)},
+		stubSnap{name: "mar_late", date: d(2020, 3, 20, 0, 0)},
+	}
+
+	tcs := map[string]testCase{
+		"empty_input": {
+			inputs:     []Snapshot{},
+			rules:      []KeepRule{mustKeepAnchored(config.PruneKeepAnchored{Period: "monthly", Count: 3})},
+			expDestroy: map[string]bool{},
+		},
+		"monthly_nearest_to_midnight": {
+			inputs: dailyLate,
+			rules:  []KeepRule{mustKeepAnchored(config.PruneKeepAnchored{Period: "monthly", Count: 3})},
+			// anchors 2020-03-01, 2020-04-01, 2020-05-01 and the upcoming 2020-06-01,
+			// which keeps nothing because the youngest snapshot is nearer to 2020-05-01;
+			// 2020-02-01 is out of count
+			expDestroy: destroyAllBut(dailyLate, "2020-02-29_23:00", "2020-03-31_23:00", "2020-04-30_23:00"),
+		},
+		"monthly_count_covers_all": {
+			inputs:     dailyLate,
+			rules:      []KeepRule{mustKeepAnchored(config.PruneKeepAnchored{Period: "monthly", Count: 10})},
+			expDestroy: destroyAllBut(dailyLate, "2020-01-15_23:00", "2020-01-31_23:00", "2020-02-29_23:00", "2020-03-31_23:00", "2020-04-30_23:00"),
+		},
+		"monthly_day_and_hour": {
+			inputs:     dailyLate,
+			rules:      []KeepRule{mustKeepAnchored(config.PruneKeepAnchored{Period: "monthly", Day: 15, Hour: 12, Count: 2})},
+			expDestroy: destroyAllBut(dailyLate, "2020-03-15_23:00", "2020-04-15_23:00", "2020-05-10_23:00"),
+		},
+		"monthly_day_clamped_to_end_of_month": {
+			inputs:     dailyLate,
+			rules:      []KeepRule{mustKeepAnchored(config.PruneKeepAnchored{Period: "monthly", Day: 31, Hour: 23, Count: 3})},
+			expDestroy: destroyAllBut(dailyLate, "2020-02-29_23:00", "2020-03-31_23:00", "2020-04-30_23:00"),
+		},
+		"monthly_gap": {
+			inputs: gap,
+			rules:  []KeepRule{mustKeepAnchored(config.PruneKeepAnchored{Period: "monthly", Count: 4})},
+			// mar_late is nearer to the April anchor than to the March anchor but not the nearest to it,
+			// so the March anchor keeps nothing; apr2 is not nearest to any anchor
+			expDestroy: map[string]bool{"mar_late": true, "apr2": true},
+		},
+		"yearly": {
+			inputs: []Snapshot{
+				stubSnap{name: "2018", date: d(2018, 7, 1, 0, 0)},
+				stubSnap{name: "2019a", date: d(2019, 6, 30, 0, 0)},
+				stubSnap{name: "2019b", date: d(2019, 7, 2, 0, 0)},
+				stubSnap{name: "2020", date: d(2020, 6, 1, 0, 0)},
+			},
+			rules:      []KeepRule{mustKeepAnchored(config.PruneKeepAnchored{Period: "yearly", Month: 7, Count: 1})},
+			expDestroy: map[string]bool{"2018": true, "2019b": true},
+		},
+		"tie_keeps_older": {
+			inputs: []Snapshot{
+				stubSnap{name: "before", date: d(2020, 1, 31, 23, 0)},
+				stubSnap{name: "after", date: d(2020, 2, 1, 1, 0)},
+				stubSnap{name: "youngest", date: d(2020, 2, 10, 0, 0)},
+			},
+			rules:      []KeepRule{mustKeepAnchored(config.PruneKeepAnchored{Period: "monthly", Count: 1})},
+			expDestroy: map[string]bool{"after": true, "youngest": true},
+		},
+		"upcoming_anchor_protects_snapshot_before_it": {
+			inputs: []Snapshot{
+				stubSnap{name: "jan", date: d(2020, 1, 1, 0, 5)},
+				stubSnap{name: "jan_mid", date: d(2020, 1, 14, 0, 0)},
+				stubSnap{name: "before_feb", date: d(2020, 1, 31, 23, 50)},
+			},
+			rules:      []KeepRule{mustKeepAnchored(config.PruneKeepAnchored{Period: "monthly", Count: 1})},
+			expDestroy: map[string]bool{"jan_mid": true},
+		},
+		"regex": {
+			inputs: []Snapshot{
+				stubSnap{name: "zrepl_a", date: d(2020, 1, 1, 0, 0)},
+				stubSnap{name: "manual_b", date: d(2020, 2, 1, 0, 0)},
+				stubSnap{name: "zrepl_c", date: d(2020, 1, 20, 0, 0)},
+			},
+			rules:      []KeepRule{mustKeepAnchored(config.PruneKeepAnchored{Period: "monthly", Count: 1, Regex: "^zrepl_"})},
+			expDestroy: map[string]bool{"manual_b": true},
+		},
+	}
+
+	testTable(tcs, t)
+}
+
+func TestKeepAnchoredTimeZone(t *testing.T) {
+	// midnight in UTC+2 is 22:00 UTC the day before
+	k := mustKeepAnchored(config.PruneKeepAnchored{Period: "monthly", Count: 1, TimeZone: "Etc/GMT-2"})
+	snaps := []Snapshot{
+		stubSnap{name: "utc_midnight", date: time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+		stubSnap{name: "local_midnight", date: time.Date(2020, 1, 31, 22, 0, 0, 0, time.UTC)},
+		stubSnap{name: "youngest", date: time.Date(2020, 2, 10, 0, 0, 0, 0, time.UTC)},
+	}
+	destroy := snapshotList(k.KeepRule(snaps)).NameList()
+	assert.Equal(t, []string{"utc_midnight", "youngest"}, destroy)
+}
+
+func TestNewKeepAnchoredValidation(t *testing.T) {
+	valid := config.PruneKeepAnchored{Period: "monthly", Month: 1, Day: 1, TimeZone: "UTC", Count: 1}
+	_, err := NewKeepAnchored(&valid)
+	require.NoError(t, err)
+
+	invalid := map[string]func(c *config.PruneKeepAnchored){
+		"period":    func(c *config.PruneKeepAnchored) { c.Period = "weekly" },
+		"month":     func(c *config.PruneKeepAnchored) { c.Month = 13 },
+		"day_zero":  func(c *config.PruneKeepAnchored) { c.Day = 0 },
+		"day_32":    func(c *config.PruneKeepAnchored) { c.Day = 32 },
+		"hour":      func(c *config.PruneKeepAnchored) { c.Hour = 24 },
+		"count":     func(c *config.PruneKeepAnchored) { c.Count = 0 },
+		"time_zone": func(c *config.PruneKeepAnchored) { c.TimeZone = "Nowhere/Nothing" },
+		"regex":     func(c *config.PruneKeepAnchored) { c.Regex = "(" },
+	}
+	for name, mod := range invalid {
+		c := valid
+		mod(&c)
+		_, err := NewKeepAnchored(&c)
+		assert.Error(t, err, name)
+	}
+}
@@ -114,3 +114,16 @@ func (p *KeepGrid) KeepRule(snaps []Snapshot) (destroyList []Snapshot) {
 	}
 	return destroyList
 }
+
+// Explain returns the snapshots that do not match the regex and how the matching snapshots
+// fit into the retention grid. The entries of the explanation are elements of snaps.
+func (p *KeepGrid) Explain(snaps []Snapshot) (notMatching []Snapshot, grid retentiongrid.Explanation) {
+	matching, notMatching := partitionSnapList(snaps, func(snapshot Snapshot) bool {
+		return p.re.MatchString(snapshot.Name())
+	})
+	entrySlice := make([]retentiongrid.Entry, len(matching))
+	for i := range matching {
+		entrySlice[i] = matching[i]
+	}
+	return notMatching, p.retentionGrid.Explain(entrySlice)
+}
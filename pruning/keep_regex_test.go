@@ -30,3 +30,18 @@ func TestKeepRegexNegation(t *testing.T) {
 	assert.True(t, destroyNeg.ContainsName("zrepl_foobar"))
 
 }
+
+func TestKeepRegexCaseInsensitive(t *testing.T) {
+	// mixed-case prefixes from different tools are matched using the (?i) flag
+	r := MustKeepRegex("(?i)^zrepl_", false)
+
+	snaps := []Snapshot{
+		stubSnap{name: "zrepl_1"},
+		stubSnap{name: "ZREPL_2"},
+		stubSnap{name: "Zrepl_3"},
+		stubSnap{name: "manual_4"},
+	}
+
+	destroy := snapshotList(r.KeepRule(snaps))
+	assert.Equal(t, []string{"manual_4"}, destroy.NameList())
+}
@@ -21,27 +21,50 @@ type Snapshot interface {
 
 // The returned snapshot list is guaranteed to only contains elements of input parameter snaps
 func PruneSnapshots(snaps []Snapshot, keepRules []KeepRule) []Snapshot {
-
-	if len(keepRules) == 0 {
-		return []Snapshot{}
+	remove := make([]Snapshot, 0, len(snaps))
+	for _, d := range Decide(snaps, keepRules) {
+		if d.Destroy {
+			remove = append(remove, d.Snapshot)
+		}
 	}
+	return remove
+}
 
-	remCount := make(map[Snapshot]int, len(snaps))
-	for _, r := range keepRules {
-		ruleRems := r.KeepRule(snaps)
-		for _, ruleRem := range ruleRems {
-			remCount[ruleRem]++
+// Decision is the outcome of pruning for a single snapshot.
+type Decision struct {
+	Snapshot Snapshot
+	// Destroy is true iff no keep rule keeps the snapshot.
+	Destroy bool
+	// KeptBy contains the indices of the keep rules that keep the snapshot.
+	KeptBy []int
+}
+
+// Decide evaluates keepRules for each snapshot in snaps.
+// The returned decisions are in the order of snaps.
+// If keepRules is empty, all snapshots are kept.
+func Decide(snaps []Snapshot, keepRules []KeepRule) []Decision {
+
+	ruleRems := make([]map[Snapshot]bool, len(keepRules))
+	for i, r := range keepRules {
+		ruleRems[i] = make(map[Snapshot]bool)
+		for _, ruleRem := range r.KeepRule(snaps) {
+			ruleRems[i][ruleRem] = true
 		}
 	}
 
-	remove := make([]Snapshot, 0, len(snaps))
-	for snap, rc := range remCount {
-		if rc == len(keepRules) {
-			remove = append(remove, snap)
+	decisions := make([]Decision, len(snaps))
+	for i, snap := range snaps {
+		d := Decision{Snapshot: snap}
+		for r := range keepRules {
+			if !ruleRems[r][snap] {
+				d.KeptBy = append(d.KeptBy, r)
+			}
 		}
+		d.Destroy = len(keepRules) > 0 && len(d.KeptBy) == 0
+		decisions[i] = d
 	}
 
-	return remove
+	return decisions
 }
 
 func RulesFromConfig(in []config.PruningEnum) (rules []KeepRule, err error) {
@@ -65,6 +88,8 @@ func RuleFromConfig(in config.PruningEnum) (KeepRule, error) {
 		return NewKeepRegex(v.Regex, v.Negate)
 	case *config.PruneGrid:
 		return NewKeepGrid(v)
+	case *config.PruneKeepAnchored:
+		return NewKeepAnchored(v)
 	default:
 		return nil, fmt.Errorf("unknown keep rule type %T", v)
 	}
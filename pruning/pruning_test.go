@@ -147,3 +147,33 @@ func TestPruneSnapshots(t *testing.T) {
 
 	testTable(tcs, t)
 }
+
+func TestDecide(t *testing.T) {
+
+	snaps := []Snapshot{
+		stubSnap{name: "foo_123"},
+		stubSnap{name: "bar_123"},
+		stubSnap{name: "baz_123"},
+	}
+	rules := []KeepRule{
+		MustKeepRegex("foo_", false),
+		MustKeepRegex("^(foo|bar)_", false),
+	}
+
+	ds := Decide(snaps, rules)
+	require.Len(t, ds, len(snaps))
+	for i := range snaps {
+		assert.Equal(t, snaps[i], ds[i].Snapshot)
+	}
+	assert.False(t, ds[0].Destroy)
+	assert.Equal(t, []int{0, 1}, ds[0].KeptBy)
+	assert.False(t, ds[1].Destroy)
+	assert.Equal(t, []int{1}, ds[1].KeptBy)
+	assert.True(t, ds[2].Destroy)
+	assert.Empty(t, ds[2].KeptBy)
+
+	for _, d := range Decide(snaps, nil) {
+		assert.False(t, d.Destroy)
+		assert.Empty(t, d.KeptBy)
+	}
+}
@@ -45,6 +45,38 @@ func (g Grid) FitEntries(entries []Entry) (keep, remove []Entry) {
 	return g.fitEntriesWithNow(now, entries)
 }
 
+// Bucket is the time window of an Interval of the grid and the entries that fall into it.
+type Bucket struct {
+	// YoungerThan is exclusive, OlderThanOrEq inclusive.
+	YoungerThan, OlderThanOrEq time.Time
+	KeepCount                  int
+	// Keep and Remove are sorted youngest-to-oldest.
+	Keep, Remove []Entry
+}
+
+// Explanation describes how FitEntries fits entries into the grid.
+type Explanation struct {
+	// Now is the date of the youngest entry, the grid's first bucket ends at it.
+	Now time.Time
+	// Future entries are younger than Now and kept unconditionally.
+	Future []Entry
+	// Buckets has one element per Interval of the grid, youngest first.
+	Buckets []Bucket
+	// TooOld entries are older than the oldest bucket and removed unconditionally.
+	TooOld []Entry
+}
+
+// Explain returns the bucket assignment that FitEntries bases its decision on.
+func (g Grid) Explain(entries []Entry) Explanation {
+	if len(entries) == 0 {
+		return Explanation{}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date().After(entries[j].Date())
+	})
+	return g.explainWithNow(entries[0].Date(), entries)
+}
+
 type bucket struct {
 	keepCount     int
 	youngerThan   time.Time
@@ -103,6 +135,17 @@ func (b *bucket) RemoveYoungerSnapsExceedingKeepCount() (removed []Entry) {
 }
 
 func (g Grid) fitEntriesWithNow(now time.Time, entries []Entry) (keep, remove []Entry) {
+	e := g.explainWithNow(now, entries)
+	keep = append(make([]Entry, 0), e.Future...)
+	remove = append(make([]Entry, 0), e.TooOld...)
+	for _, b := range e.Buckets {
+		remove = append(remove, b.Remove...)
+		keep = append(keep, b.Keep...)
+	}
+	return
+}
+
+func (g Grid) explainWithNow(now time.Time, entries []Entry) Explanation {
 
 	buckets := make([]bucket, len(g.intervals))
 
@@ -111,32 +154,37 @@ func (g Grid) fitEntriesWithNow(now time.Time, entries []Entry) (keep, remove []
 		buckets[i] = makeBucketFromInterval(buckets[i-1].youngerThan, g.intervals[i])
 	}
 
-	keep = make([]Entry, 0)
-	remove = make([]Entry, 0)
+	e := Explanation{Now: now}
 
 assignEntriesToBuckets:
 	for ei := 0; ei < len(entries); ei++ {
-		e := entries[ei]
+		en := entries[ei]
 		// unconditionally keep entries that are in the future
-		if now.Before(e.Date()) {
-			keep = append(keep, e)
+		if now.Before(en.Date()) {
+			e.Future = append(e.Future, en)
 			continue assignEntriesToBuckets
 		}
 		// add to matching bucket, if any
 		for bi := range buckets {
-			if buckets[bi].AddIfContains(e) {
+			if buckets[bi].AddIfContains(en) {
 				continue assignEntriesToBuckets
 			}
 		}
 		// unconditionally remove entries older than the oldest bucket
-		remove = append(remove, e)
+		e.TooOld = append(e.TooOld, en)
 	}
 
 	// now apply the `KeepCount` per bucket
-	for _, b := range buckets {
+	e.Buckets = make([]Bucket, len(buckets))
+	for i, b := range buckets {
 		destroy := b.RemoveYoungerSnapsExceedingKeepCount()
-		remove = append(remove, destroy...)
-		keep = append(keep, b.entries...)
+		e.Buckets[i] = Bucket{
+			YoungerThan:   b.youngerThan,
+			OlderThanOrEq: b.olderThanOrEq,
+			KeepCount:     b.keepCount,
+			Keep:          b.entries[len(destroy):],
+			Remove:        destroy,
+		}
 	}
-	return
+	return e
 }
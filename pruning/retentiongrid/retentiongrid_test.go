@@ -183,3 +183,30 @@ func TestComplex(t *testing.T) {
 	validateRetentionGridFitEntries(t, now, snaps, keep, remove)
 
 }
+
+func TestExplain(t *testing.T) {
+	g := gridFromString("1m,-1|1m,1|1m,2")
+	relt := func(secs int64) time.Time { return time.Unix(secs, 0) }
+	a := testSnap{"a", true, relt(0)}
+	b := testSnap{"b", false, relt(-61)}
+	c := testSnap{"c", true, relt(-62)}
+	d := testSnap{"d", true, relt(-150)}
+	e := testSnap{"e", false, relt(-200)}
+	snaps := []Entry{e, c, a, d, b}
+
+	x := g.Explain(snaps)
+	assert.Equal(t, relt(0), x.Now)
+	assert.Empty(t, x.Future)
+	assert.Equal(t, []Entry{e}, x.TooOld)
+	assert.Equal(t, []Bucket{
+		{YoungerThan: relt(-60), OlderThanOrEq: relt(0), KeepCount: -1, Keep: []Entry{a}},
+		{YoungerThan: relt(-120), OlderThanOrEq: relt(-60), KeepCount: 1, Keep: []Entry{c}, Remove: []Entry{b}},
+		{YoungerThan: relt(-180), OlderThanOrEq: relt(-120), KeepCount: 2, Keep: []Entry{d}},
+	}, x.Buckets)
+
+	keep, remove := g.FitEntries(snaps)
+	assert.ElementsMatch(t, []Entry{a, c, d}, keep)
+	assert.ElementsMatch(t, []Entry{b, e}, remove)
+
+	assert.Equal(t, Explanation{}, g.Explain(nil))
+}
@@ -128,3 +128,26 @@ func TestIncrementalPath_BookmarkSupport(t *testing.T) {
 	})
 
 }
+
+func TestIncrementalPath_BookmarkOfPrunedSnapshot(t *testing.T) {
+	l := fsvlist
+
+	// the sender pruned the most recent common snapshot but kept a bookmark of it
+	doTest(l("@a,1", "@b,2"), l("#a,1", "#b,2", "@c,3", "@d,4"), func(path []*FilesystemVersion, conflict error) {
+		assert.Nil(t, conflict)
+		assert.Equal(t, l("#b,2", "@c,3", "@d,4"), path)
+	})
+
+	// a more recent bookmark is preferred over an older common snapshot
+	doTest(l("@a,1", "@b,2"), l("@a,1", "#b,2", "@c,3"), func(path []*FilesystemVersion, conflict error) {
+		assert.Nil(t, conflict)
+		assert.Equal(t, l("#b,2", "@c,3"), path)
+	})
+
+	// neither the snapshot nor a bookmark of it exists on the sender: a full send is required
+	doTest(l("@a,1", "@b,2"), l("@c,3", "#d,4", "@e,5"), func(path []*FilesystemVersion, conflict error) {
+		assert.Nil(t, path)
+		_, ok := conflict.(*ConflictNoCommonAncestor)
+		assert.True(t, ok, "%T", conflict)
+	})
+}
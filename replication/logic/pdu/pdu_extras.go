@@ -7,6 +7,14 @@ import (
 	"github.com/zrepl/zrepl/zfs"
 )
 
+// DestroySnapshotResErrorPinned is the Error of a DestroySnapshotRes for a snapshot
+// that was not destroyed because it is pinned through `zrepl snapshots pin`.
+const DestroySnapshotResErrorPinned = "snapshot is pinned"
+
+func (r *DestroySnapshotRes) IsPinned() bool {
+	return r.GetError() == DestroySnapshotResErrorPinned
+}
+
 func (v *FilesystemVersion) GetRelName() string {
 	zv, err := v.ZFSFilesystemVersion()
 	if err != nil {
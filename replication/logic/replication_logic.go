@@ -19,6 +19,7 @@ import (
 	"github.com/zrepl/zrepl/replication/report"
 	"github.com/zrepl/zrepl/util/bytecounter"
 	"github.com/zrepl/zrepl/util/chainlock"
+	"github.com/zrepl/zrepl/util/envconst"
 	"github.com/zrepl/zrepl/util/semaphore"
 	"github.com/zrepl/zrepl/zfs"
 )
@@ -157,6 +158,8 @@ type Step struct {
 	from, to    *pdu.FilesystemVersion // from may be nil, indicating full send
 	encrypt     tri
 	resumeToken string // empty means no resume token shall be used
+	// send all snapshots between from and to in a single stream (zfs send -I), from must be a snapshot
+	intermediates bool
 
 	expectedSize int64 // 0 means no size estimate present / possible
 
@@ -164,6 +167,8 @@ type Step struct {
 	// => concurrent read of that pointer from Step.ReportInfo must be protected
 	byteCounter    bytecounter.ReadCloser
 	byteCounterMtx chainlock.L
+	// transferStartAt is set together with byteCounter
+	transferStartAt time.Time
 }
 
 func (s *Step) TargetEquals(other driver.Step) bool {
@@ -194,6 +199,7 @@ func (s *Step) ReportInfo() *report.StepInfo {
 	if s.byteCounter != nil {
 		byteCounter = s.byteCounter.Count()
 	}
+	transferStartAt := s.transferStartAt
 	s.byteCounterMtx.Unlock()
 
 	from := ""
@@ -218,6 +224,7 @@ func (s *Step) ReportInfo() *report.StepInfo {
 		Encrypted:       encrypted,
 		BytesExpected:   s.expectedSize,
 		BytesReplicated: byteCounter,
+		StartAt:         transferStartAt,
 	}
 }
 
@@ -252,6 +259,10 @@ func resolveConflict(conflict error) (path []*pdu.FilesystemVersion, msg string)
 			return []*pdu.FilesystemVersion{mostRecentSnap}, fmt.Sprintf("start replication at most recent snapshot %s", mostRecentSnap.RelName())
 		}
 	}
+	if _, ok := conflict.(*ConflictNoCommonAncestor); ok {
+		// neither the receiver's snapshots nor bookmarks of them exist on the sender
+		return nil, "the sender has neither snapshots of the receiver nor bookmarks of them: incremental replication is impossible, and a full send requires that the receiving filesystem be destroyed or renamed"
+	}
 	return nil, "no automated way to handle conflict type"
 }
 
@@ -442,16 +453,7 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 
 		steps = make([]*Step, 0, len(remainingSFSVs)) // shadow
 		steps = append(steps, resumeStep)
-		for i := 0; i < len(remainingSFSVs)-1; i++ {
-			steps = append(steps, &Step{
-				parent:   fs,
-				sender:   fs.sender,
-				receiver: fs.receiver,
-				from:     remainingSFSVs[i],
-				to:       remainingSFSVs[i+1],
-				encrypt:  fs.policy.EncryptedSend,
-			})
-		}
+		steps = append(steps, fs.incrementalSteps(remainingSFSVs)...)
 	} else { // resumeToken == nil
 		path, conflict := IncrementalPath(rfsvs, sfsvs)
 		if conflict != nil {
@@ -481,17 +483,7 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 				encrypt: fs.policy.EncryptedSend,
 			})
 		} else {
-			for i := 0; i < len(path)-1; i++ {
-				steps = append(steps, &Step{
-					parent:   fs,
-					sender:   fs.sender,
-					receiver: fs.receiver,
-
-					from:    path[i],
-					to:      path[i+1],
-					encrypt: fs.policy.EncryptedSend,
-				})
-			}
+			steps = append(steps, fs.incrementalSteps(path)...)
 		}
 	}
 
@@ -542,6 +534,41 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 	return steps, nil
 }
 
+// incrementalSteps returns the steps that replicate the versions in path, which must be an incremental path.
+//
+// If the policy mandates SendIntermediates, the snapshots in path are sent in a single step with zfs send -I.
+// Because zfs send -I cannot send from a bookmark, a bookmark at the start of path gets its own step.
+func (fs *Filesystem) incrementalSteps(path []*pdu.FilesystemVersion) []*Step {
+	steps := make([]*Step, 0, len(path))
+	step := func(from, to *pdu.FilesystemVersion, intermediates bool) {
+		steps = append(steps, &Step{
+			parent:   fs,
+			sender:   fs.sender,
+			receiver: fs.receiver,
+
+			from:          from,
+			to:            to,
+			encrypt:       fs.policy.EncryptedSend,
+			intermediates: intermediates,
+		})
+	}
+	i := 0
+	if fs.policy.SendIntermediates && len(path) > 2 {
+		if path[0].Type != pdu.FilesystemVersion_Snapshot {
+			step(path[0], path[1], false)
+			i = 1
+		}
+		if len(path)-i > 2 {
+			step(path[i], path[len(path)-1], true)
+			return steps
+		}
+	}
+	for ; i < len(path)-1; i++ {
+		step(path[i], path[i+1], false)
+	}
+	return steps
+}
+
 func (s *Step) updateSizeEstimate(ctx context.Context) error {
 
 	log := getLogger(ctx)
@@ -559,6 +586,12 @@ func (s *Step) updateSizeEstimate(ctx context.Context) error {
 		log.Error(err.Error())
 		return err
 	}
+	if s.intermediates && !sres.GetUsedIntermediates() {
+		// older senders ignore SendReq.Intermediates
+		err := fmt.Errorf("sender does not support sending intermediate snapshots in a single stream, upgrade the sender or use replication.intermediates: steps")
+		log.Error(err.Error())
+		return err
+	}
 	s.expectedSize = sres.GetExpectedSize()
 	return nil
 }
@@ -571,6 +604,7 @@ func (s *Step) buildSendRequest(dryRun bool) (sr *pdu.SendReq) {
 		To:                s.to,
 		Encrypted:         s.encrypt.ToPDU(),
 		ResumeToken:       s.resumeToken,
+		Intermediates:     s.intermediates,
 		DryRun:            dryRun,
 		ReplicationConfig: s.parent.policy.ReplicationConfig,
 	}
@@ -600,12 +634,21 @@ func (s *Step) doReplication(ctx context.Context) error {
 		return err
 	}
 	defer stream.Close()
+	if s.intermediates && !sres.GetUsedIntermediates() {
+		// the stream would only contain s.to, the receiver would lose the intermediate snapshots
+		err := fmt.Errorf("sender does not support sending intermediate snapshots in a single stream, upgrade the sender or use replication.intermediates: steps")
+		log.Error(err.Error())
+		return err
+	}
 
 	// Install a byte counter to track progress + for status report
 	byteCountingStream := bytecounter.NewReadCloser(stream)
 	s.byteCounterMtx.Lock()
 	s.byteCounter = byteCountingStream
+	s.transferStartAt = time.Now()
 	s.byteCounterMtx.Unlock()
+	stopProgressLog := s.logProgressPeriodically(log)
+	defer stopProgressLog()
 	defer func() {
 		defer s.byteCounterMtx.Lock().Unlock()
 		if s.parent.promBytesReplicated != nil {
@@ -647,6 +690,42 @@ func (s *Step) doReplication(ctx context.Context) error {
 	return err
 }
 
+var progressLogInterval = envconst.Duration("ZREPL_REPLICATION_PROGRESS_LOG_INTERVAL", 1*time.Minute)
+
+// logProgressPeriodically logs the bytes transferred so far and the ETA of the step
+// until the returned function is called.
+func (s *Step) logProgressPeriodically(log logger.Logger) (stop func()) {
+	if progressLogInterval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(progressLogInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-t.C:
+				info := s.ReportInfo()
+				l := log.WithField("bytes_replicated", info.BytesReplicated).
+					WithField("bytes_expected", info.BytesExpected)
+				if eta, ok := info.ETA(now); ok {
+					l = l.WithField("eta", eta.Round(time.Second).String())
+				}
+				l.Info("replication progress")
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
 func (s *Step) String() string {
 	if s.from == nil { // FIXME: ZFS semantics are that to is nil on non-incremental send
 		return fmt.Sprintf("%s%s (full)", s.parent.Path, s.to.RelName())
@@ -12,12 +12,32 @@ type PlannerPolicy struct {
 	EncryptedSend             tri // all sends must be encrypted (send -w, and encryption!=off)
 	ReplicationConfig         *pdu.ReplicationConfig
 	SizeEstimationConcurrency int `validate:"gte=1"`
+	// send the snapshots between two versions in a single step using zfs send -I
+	SendIntermediates bool
 }
 
 var validate = validator.New()
 
 func (p PlannerPolicy) Validate() error {
-	return validate.Struct(p)
+	if err := validate.Struct(p); err != nil {
+		return err
+	}
+	if p.SendIntermediates && p.ReplicationConfig.GetProtection().GetIncremental() == pdu.ReplicationGuaranteeKind_GuaranteeResumability {
+		// step holds only protect the From and To version, not the intermediate snapshots that a resume token may refer to
+		return errors.New("sending intermediate snapshots in a single stream is incompatible with incremental protection guarantee_resumability")
+	}
+	return nil
+}
+
+func SendIntermediatesFromConfig(in *config.Replication) (bool, error) {
+	switch in.Intermediates {
+	case "steps":
+		return false, nil
+	case "single_stream":
+		return true, nil
+	default:
+		return false, errors.Errorf("field 'intermediates': %q is not in {steps,single_stream}", in.Intermediates)
+	}
 }
 
 func ReplicationConfigFromConfig(in *config.Replication) (*pdu.ReplicationConfig, error) {
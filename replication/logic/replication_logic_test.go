@@ -0,0 +1,120 @@
+package logic
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+func snap(name string) *pdu.FilesystemVersion {
+	return &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, Name: name, Creation: pdu.FilesystemVersionCreation(time.Unix(0, 0))}
+}
+
+func bookmark(name string) *pdu.FilesystemVersion {
+	return &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Bookmark, Name: name, Creation: pdu.FilesystemVersionCreation(time.Unix(0, 0))}
+}
+
+type stepSpec struct {
+	from, to      string
+	intermediates bool
+}
+
+func stepSpecs(steps []*Step) []stepSpec {
+	specs := make([]stepSpec, len(steps))
+	for i, s := range steps {
+		specs[i] = stepSpec{s.from.RelName(), s.to.RelName(), s.intermediates}
+	}
+	return specs
+}
+
+func TestIncrementalSteps(t *testing.T) {
+	tcs := []struct {
+		name              string
+		sendIntermediates bool
+		path              []*pdu.FilesystemVersion
+		expect            []stepSpec
+	}{
+		{
+			name:   "one step per snapshot without SendIntermediates",
+			path:   []*pdu.FilesystemVersion{snap("a"), snap("b"), snap("c")},
+			expect: []stepSpec{{"@a", "@b", false}, {"@b", "@c", false}},
+		},
+		{
+			name:              "single stream",
+			sendIntermediates: true,
+			path:              []*pdu.FilesystemVersion{snap("a"), snap("b"), snap("c")},
+			expect:            []stepSpec{{"@a", "@c", true}},
+		},
+		{
+			name:              "a single step needs no intermediates",
+			sendIntermediates: true,
+			path:              []*pdu.FilesystemVersion{snap("a"), snap("b")},
+			expect:            []stepSpec{{"@a", "@b", false}},
+		},
+		{
+			name:              "zfs send -I cannot send from a bookmark",
+			sendIntermediates: true,
+			path:              []*pdu.FilesystemVersion{bookmark("a"), snap("b"), snap("c"), snap("d")},
+			expect:            []stepSpec{{"#a", "@b", false}, {"@b", "@d", true}},
+		},
+		{
+			name:              "bookmark followed by a single step",
+			sendIntermediates: true,
+			path:              []*pdu.FilesystemVersion{bookmark("a"), snap("b"), snap("c")},
+			expect:            []stepSpec{{"#a", "@b", false}, {"@b", "@c", false}},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := &Filesystem{policy: PlannerPolicy{SendIntermediates: tc.sendIntermediates}}
+			assert.Equal(t, tc.expect, stepSpecs(fs.incrementalSteps(tc.path)))
+		})
+	}
+}
+
+// ignoresIntermediatesSender behaves like a sender that predates SendReq.Intermediates.
+type ignoresIntermediatesSender struct {
+	Sender
+}
+
+func (ignoresIntermediatesSender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.ReadCloser, error) {
+	var stream io.ReadCloser
+	if !r.GetDryRun() {
+		stream = ioutil.NopCloser(strings.NewReader("stream"))
+	}
+	return &pdu.SendRes{}, stream, nil
+}
+
+type failingReceiver struct {
+	Receiver
+	t *testing.T
+}
+
+func (r failingReceiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.ReadCloser) (*pdu.ReceiveRes, error) {
+	r.t.Fatal("stream without intermediate snapshots must not be received")
+	return nil, nil
+}
+
+func TestStepRequiresUsedIntermediates(t *testing.T) {
+	fs := &Filesystem{
+		sender:   ignoresIntermediatesSender{},
+		receiver: failingReceiver{t: t},
+		policy:   PlannerPolicy{SendIntermediates: true},
+		Path:     "pool/data",
+	}
+	steps := fs.incrementalSteps([]*pdu.FilesystemVersion{snap("a"), snap("b"), snap("c")})
+	require.Len(t, steps, 1)
+
+	err := steps[0].updateSizeEstimate(context.Background())
+	assert.Error(t, err)
+	err = steps[0].doReplication(context.Background())
+	assert.Error(t, err)
+}
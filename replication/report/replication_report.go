@@ -99,6 +99,8 @@ type StepInfo struct {
 	Encrypted       EncryptedEnum
 	BytesExpected   int64
 	BytesReplicated int64
+	// the time the transfer of the send stream started, zero if it has not started yet
+	StartAt time.Time
 }
 
 func (a *AttemptReport) BytesSum() (expected, replicated int64, containsInvalidSizeEstimates bool) {
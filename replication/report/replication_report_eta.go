@@ -0,0 +1,63 @@
+package report
+
+import "time"
+
+// The ETAs extrapolate the average transfer rate since the start of the transfer,
+// measured by counting the bytes of the send stream, to the bytes that remain
+// according to the size estimates.
+// They are not available before the first bytes were transferred or if a size estimate is missing.
+
+func eta(expected, replicated int64, startAt, now time.Time) (time.Duration, bool) {
+	if expected <= 0 || replicated <= 0 || startAt.IsZero() {
+		return 0, false
+	}
+	elapsed := now.Sub(startAt)
+	if elapsed <= 0 {
+		return 0, false
+	}
+	remaining := expected - replicated
+	if remaining <= 0 {
+		return 0, true
+	}
+	bytesPerSecond := float64(replicated) / elapsed.Seconds()
+	return time.Duration(float64(remaining) / bytesPerSecond * float64(time.Second)), true
+}
+
+func (s *StepInfo) ETA(now time.Time) (time.Duration, bool) {
+	return eta(s.BytesExpected, s.BytesReplicated, s.StartAt, now)
+}
+
+// transferStartAt returns the start of the first step that started transferring.
+func (f *FilesystemReport) transferStartAt() (startAt time.Time) {
+	for _, step := range f.Steps {
+		if !step.Info.StartAt.IsZero() && (startAt.IsZero() || step.Info.StartAt.Before(startAt)) {
+			startAt = step.Info.StartAt
+		}
+	}
+	return startAt
+}
+
+// ETA returns the time remaining until all steps of f are replicated.
+func (f *FilesystemReport) ETA(now time.Time) (time.Duration, bool) {
+	expected, replicated, containsInvalidSizeEstimates := f.BytesSum()
+	if containsInvalidSizeEstimates {
+		return 0, false
+	}
+	return eta(expected, replicated, f.transferStartAt(), now)
+}
+
+// ETA returns the time remaining until all filesystems of a are replicated.
+// Filesystems are replicated in parallel, so the rate is that of all filesystems together.
+func (a *AttemptReport) ETA(now time.Time) (time.Duration, bool) {
+	expected, replicated, containsInvalidSizeEstimates := a.BytesSum()
+	if containsInvalidSizeEstimates {
+		return 0, false
+	}
+	var startAt time.Time
+	for _, fs := range a.Filesystems {
+		if s := fs.transferStartAt(); !s.IsZero() && (startAt.IsZero() || s.Before(startAt)) {
+			startAt = s
+		}
+	}
+	return eta(expected, replicated, startAt, now)
+}
@@ -0,0 +1,58 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETA(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	step := func(expected, replicated int64, startedAgo time.Duration) *StepReport {
+		info := &StepInfo{BytesExpected: expected, BytesReplicated: replicated}
+		if startedAgo > 0 {
+			info.StartAt = now.Add(-startedAgo)
+		}
+		return &StepReport{Info: info}
+	}
+
+	// 100 bytes in 10s => 10 bytes/s, 300 bytes remaining
+	s := step(400, 100, 10*time.Second)
+	eta, ok := s.Info.ETA(now)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, eta)
+
+	_, ok = step(400, 0, 10*time.Second).Info.ETA(now)
+	assert.False(t, ok, "nothing transferred yet")
+	_, ok = step(0, 100, 10*time.Second).Info.ETA(now)
+	assert.False(t, ok, "no size estimate")
+	_, ok = step(400, 100, 0).Info.ETA(now)
+	assert.False(t, ok, "not started")
+
+	eta, ok = step(100, 150, 10*time.Second).Info.ETA(now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), eta, "estimate exceeded")
+
+	// the filesystem's rate is measured since its first step started
+	fs := &FilesystemReport{Steps: []*StepReport{
+		step(100, 100, 20*time.Second),
+		step(300, 100, 5*time.Second),
+		step(200, 0, 0),
+	}}
+	eta, ok = fs.ETA(now)
+	assert.True(t, ok)
+	assert.Equal(t, 40*time.Second, eta) // 200 bytes in 20s, 400 bytes remaining
+
+	fs.Steps = append(fs.Steps, step(0, 0, 0))
+	_, ok = fs.ETA(now)
+	assert.False(t, ok, "step without size estimate")
+
+	a := &AttemptReport{Filesystems: []*FilesystemReport{
+		{Steps: []*StepReport{step(200, 100, 10*time.Second)}},
+		{Steps: []*StepReport{step(200, 100, 5*time.Second)}},
+	}}
+	eta, ok = a.ETA(now)
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Second, eta) // 200 bytes in 10s, 200 bytes remaining
+}
@@ -0,0 +1,226 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/replication/logic"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+// DirectHandlerLookup returns the Handler that a DirectClient invokes,
+// and the HandlerContextInterceptor that the serving side would have applied to it.
+type DirectHandlerLookup func(ctx context.Context) (Handler, HandlerContextInterceptor, error)
+
+// DirectClient implements the active side of a replication setup where both sides run
+// in the same process: it invokes the passive side's Handler directly instead of
+// going through a transport, the version handshake and the control / data connections.
+// Send streams are handed to the receiving Handler as they are, without framing.
+//
+// It satisfies the same interfaces as Client.
+type DirectClient struct {
+	lookup         DirectHandlerLookup
+	clientIdentity string
+}
+
+var _ logic.Endpoint = &DirectClient{}
+var _ logic.Sender = &DirectClient{}
+var _ logic.Receiver = &DirectClient{}
+
+// The Handler is invoked as if the client with clientIdentity had connected to it.
+func NewDirectClient(clientIdentity string, lookup DirectHandlerLookup) *DirectClient {
+	return &DirectClient{lookup: lookup, clientIdentity: clientIdentity}
+}
+
+func (c *DirectClient) Close() {}
+
+func (c *DirectClient) ResetConnectBackoff() {}
+
+type directInterceptorData struct {
+	method         string
+	clientIdentity string
+}
+
+func (d directInterceptorData) FullMethod() string     { return "direct://" + d.method }
+func (d directInterceptorData) ClientIdentity() string { return d.clientIdentity }
+
+// invoke calls f with the Handler, within its HandlerContextInterceptor.
+// Like the rpc server does, f gets a context that is not derived from ctx
+// (the interceptor inherits logging and tracing from the serving job) but is cancelled with it.
+func (c *DirectClient) invoke(ctx context.Context, method string, f func(ctx context.Context, h Handler)) error {
+	h, interceptor, err := c.lookup(ctx)
+	if err != nil {
+		return err
+	}
+	handlerCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if dl, ok := ctx.Deadline(); ok {
+		handlerCtx, cancel = context.WithDeadline(handlerCtx, dl)
+		defer cancel()
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-done:
+		}
+	}()
+	handlerCtx = context.WithValue(handlerCtx, endpoint.ClientIdentityKey, c.clientIdentity)
+	interceptor(handlerCtx, directInterceptorData{method, c.clientIdentity}, func(ctx context.Context) {
+		f(ctx, h)
+	})
+	return nil
+}
+
+func (c *DirectClient) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.ReadCloser, error) {
+	ctx, endSpan := trace.WithSpan(ctx, "rpc.direct.Send")
+	defer endSpan()
+
+	type sendResult struct {
+		res    *pdu.SendRes
+		stream io.ReadCloser
+		err    error
+	}
+	results := make(chan sendResult, 1)
+	go func() {
+		invokeErr := c.invoke(ctx, "Send", func(ctx context.Context, h Handler) {
+			res, stream, err := h.Send(ctx, r)
+			if err != nil || stream == nil {
+				results <- sendResult{res, stream, err}
+				return
+			}
+			// the send stream is tied to the handler's context, keep it alive until the stream is closed
+			ds := &directSendStream{ReadCloser: stream, closed: make(chan struct{})}
+			results <- sendResult{res, ds, nil}
+			select {
+			case <-ds.closed:
+			case <-ctx.Done():
+			}
+		})
+		if invokeErr != nil {
+			results <- sendResult{nil, nil, invokeErr}
+		}
+	}()
+	res := <-results
+	return res.res, res.stream, res.err
+}
+
+type directSendStream struct {
+	io.ReadCloser
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (s *directSendStream) Close() error {
+	err := s.ReadCloser.Close()
+	s.closeOnce.Do(func() { close(s.closed) })
+	return err
+}
+
+func (c *DirectClient) Receive(ctx context.Context, req *pdu.ReceiveReq, stream io.ReadCloser) (res *pdu.ReceiveRes, err error) {
+	ctx, endSpan := trace.WithSpan(ctx, "rpc.direct.Receive")
+	defer endSpan()
+
+	invokeErr := c.invoke(ctx, "Receive", func(ctx context.Context, h Handler) {
+		res, err = h.Receive(ctx, req, stream)
+	})
+	if invokeErr != nil {
+		return nil, invokeErr
+	}
+	return res, err
+}
+
+func (c *DirectClient) ListFilesystems(ctx context.Context, in *pdu.ListFilesystemReq) (res *pdu.ListFilesystemRes, err error) {
+	ctx, endSpan := trace.WithSpan(ctx, "rpc.direct.ListFilesystems")
+	defer endSpan()
+
+	invokeErr := c.invoke(ctx, "ListFilesystems", func(ctx context.Context, h Handler) {
+		res, err = h.ListFilesystems(ctx, in)
+	})
+	if invokeErr != nil {
+		return nil, invokeErr
+	}
+	return res, err
+}
+
+func (c *DirectClient) ListFilesystemVersions(ctx context.Context, in *pdu.ListFilesystemVersionsReq) (res *pdu.ListFilesystemVersionsRes, err error) {
+	ctx, endSpan := trace.WithSpan(ctx, "rpc.direct.ListFilesystemVersions")
+	defer endSpan()
+
+	invokeErr := c.invoke(ctx, "ListFilesystemVersions", func(ctx context.Context, h Handler) {
+		res, err = h.ListFilesystemVersions(ctx, in)
+	})
+	if invokeErr != nil {
+		return nil, invokeErr
+	}
+	return res, err
+}
+
+func (c *DirectClient) DestroySnapshots(ctx context.Context, in *pdu.DestroySnapshotsReq) (res *pdu.DestroySnapshotsRes, err error) {
+	ctx, endSpan := trace.WithSpan(ctx, "rpc.direct.DestroySnapshots")
+	defer endSpan()
+
+	invokeErr := c.invoke(ctx, "DestroySnapshots", func(ctx context.Context, h Handler) {
+		res, err = h.DestroySnapshots(ctx, in)
+	})
+	if invokeErr != nil {
+		return nil, invokeErr
+	}
+	return res, err
+}
+
+func (c *DirectClient) ReplicationCursor(ctx context.Context, in *pdu.ReplicationCursorReq) (res *pdu.ReplicationCursorRes, err error) {
+	ctx, endSpan := trace.WithSpan(ctx, "rpc.direct.ReplicationCursor")
+	defer endSpan()
+
+	invokeErr := c.invoke(ctx, "ReplicationCursor", func(ctx context.Context, h Handler) {
+		res, err = h.ReplicationCursor(ctx, in)
+	})
+	if invokeErr != nil {
+		return nil, invokeErr
+	}
+	return res, err
+}
+
+func (c *DirectClient) SendCompleted(ctx context.Context, in *pdu.SendCompletedReq) (res *pdu.SendCompletedRes, err error) {
+	ctx, endSpan := trace.WithSpan(ctx, "rpc.direct.SendCompleted")
+	defer endSpan()
+
+	invokeErr := c.invoke(ctx, "SendCompleted", func(ctx context.Context, h Handler) {
+		res, err = h.SendCompleted(ctx, in)
+	})
+	if invokeErr != nil {
+		return nil, invokeErr
+	}
+	return res, err
+}
+
+// WaitForConnectivity fails immediately if the Handler cannot be looked up,
+// there is no connection that could become ready later.
+func (c *DirectClient) WaitForConnectivity(ctx context.Context) error {
+	ctx, endSpan := trace.WithSpan(ctx, "rpc.direct.WaitForConnectivity")
+	defer endSpan()
+
+	req := pdu.PingReq{Message: "direct"}
+	var res *pdu.PingRes
+	var err error
+	invokeErr := c.invoke(ctx, "Ping", func(ctx context.Context, h Handler) {
+		res, err = h.Ping(ctx, &req)
+	})
+	if invokeErr != nil {
+		return invokeErr
+	}
+	if err != nil {
+		return err
+	}
+	if res.GetEcho() != req.GetMessage() {
+		return errors.New("pilot message not echoed correctly")
+	}
+	return nil
+}
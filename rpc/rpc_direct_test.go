@@ -0,0 +1,159 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/transport/local"
+)
+
+// directTestHandler sends sendSize zero bytes and discards received streams.
+type directTestHandler struct {
+	pdu.UnimplementedReplicationServer
+	sendSize int64
+}
+
+func (h *directTestHandler) Ping(ctx context.Context, r *pdu.PingReq) (*pdu.PingRes, error) {
+	return &pdu.PingRes{Echo: r.GetMessage()}, nil
+}
+
+func (h *directTestHandler) PingDataconn(ctx context.Context, r *pdu.PingReq) (*pdu.PingRes, error) {
+	return &pdu.PingRes{Echo: r.GetMessage()}, nil
+}
+
+// ctxReader fails reads once ctx is done, like the stdout of a zfs send that was started with ctx.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+func (h *directTestHandler) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.ReadCloser, error) {
+	stream := ctxReader{ctx, io.LimitReader(zeroReader{}, h.sendSize)}
+	return &pdu.SendRes{}, ioutil.NopCloser(stream), nil
+}
+
+func (h *directTestHandler) Receive(ctx context.Context, r *pdu.ReceiveReq, receive io.ReadCloser) (*pdu.ReceiveRes, error) {
+	if ctx.Value(endpoint.ClientIdentityKey) == nil {
+		return nil, fmt.Errorf("client identity not set")
+	}
+	if _, err := io.Copy(ioutil.Discard, receive); err != nil {
+		return nil, err
+	}
+	return &pdu.ReceiveRes{}, nil
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func directTestInterceptor(ctx context.Context, _ HandlerContextInterceptorData, handler func(ctx context.Context)) {
+	handler(ctx)
+}
+
+func TestDirectClientSendStreamOutlivesCall(t *testing.T) {
+	ctx, endTask := trace.WithTaskFromStack(context.Background())
+	defer endTask()
+
+	h := &directTestHandler{sendSize: 1 << 20}
+	c := NewDirectClient("test", func(ctx context.Context) (Handler, HandlerContextInterceptor, error) {
+		return h, directTestInterceptor, nil
+	})
+
+	require.NoError(t, c.WaitForConnectivity(ctx))
+
+	_, stream, err := c.Send(ctx, &pdu.SendReq{})
+	require.NoError(t, err)
+	n, err := io.Copy(ioutil.Discard, stream)
+	require.NoError(t, err)
+	assert.Equal(t, h.sendSize, n)
+	require.NoError(t, stream.Close())
+
+	_, err = c.Receive(ctx, &pdu.ReceiveReq{}, ioutil.NopCloser(bytes.NewReader(make([]byte, 1024))))
+	require.NoError(t, err)
+}
+
+func TestDirectClientLookupError(t *testing.T) {
+	ctx, endTask := trace.WithTaskFromStack(context.Background())
+	defer endTask()
+
+	c := NewDirectClient("test", func(ctx context.Context) (Handler, HandlerContextInterceptor, error) {
+		return nil, nil, fmt.Errorf("not reachable")
+	})
+	assert.EqualError(t, c.WaitForConnectivity(ctx), "not reachable")
+	_, _, err := c.Send(ctx, &pdu.SendReq{})
+	assert.EqualError(t, err, "not reachable")
+}
+
+const benchmarkStreamSize = 64 << 20
+
+func benchmarkReceive(b *testing.B, c interface {
+	Receive(ctx context.Context, req *pdu.ReceiveReq, stream io.ReadCloser) (*pdu.ReceiveRes, error)
+}) {
+	ctx, endTask := trace.WithTaskFromStack(context.Background())
+	defer endTask()
+
+	b.SetBytes(benchmarkStreamSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stream := ioutil.NopCloser(io.LimitReader(zeroReader{}, benchmarkStreamSize))
+		if _, err := c.Receive(ctx, &pdu.ReceiveReq{}, stream); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkReceiveLocalTransport streams through the local transport (socketpair, version handshake, dataconn framing).
+func BenchmarkReceiveLocalTransport(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	loggers := Loggers{
+		General: logger.NewNullLogger(),
+		Control: logger.NewNullLogger(),
+		Data:    logger.NewNullLogger(),
+	}
+	server := NewServer(&directTestHandler{}, loggers, directTestInterceptor)
+	go server.Serve(ctx, local.GetLocalListener("rpc-benchmark"))
+
+	cn, err := local.LocalConnecterFromConfig(&config.LocalConnect{
+		ListenerName:   "rpc-benchmark",
+		ClientIdentity: "benchmark",
+	})
+	require.NoError(b, err)
+	client := NewClient(cn, loggers)
+	defer client.Close()
+
+	benchmarkReceive(b, client)
+}
+
+// BenchmarkReceiveDirect streams through a DirectClient, the fast path of local connects with `direct` enabled.
+func BenchmarkReceiveDirect(b *testing.B) {
+	client := NewDirectClient("benchmark", func(ctx context.Context) (Handler, HandlerContextInterceptor, error) {
+		return &directTestHandler{}, directTestInterceptor, nil
+	})
+	benchmarkReceive(b, client)
+}
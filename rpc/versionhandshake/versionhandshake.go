@@ -150,26 +150,37 @@ func (m *HandshakeMessage) DecodeReader(r io.Reader, maxLen int) error {
 	return nil
 }
 
+// current protocol version is hardcoded here
+const currentProtocolVersion = 5
+
 func DoHandshakeCurrentVersion(conn net.Conn, deadline time.Time) *HandshakeError {
-	// current protocol version is hardcoded here
-	return DoHandshakeVersion(conn, deadline, 5)
+	_, _, err := doHandshake(conn, deadline, currentProtocolVersion, nil)
+	return err
 }
 
 const HandshakeMessageMaxLen = 16 * 4096
 
 func DoHandshakeVersion(conn net.Conn, deadline time.Time, version int) (rErr *HandshakeError) {
+	_, _, err := doHandshake(conn, deadline, version, nil)
+	return err
+}
+
+// doHandshake sends our extensions to the peer and returns the peer's extensions.
+// It also sends our wall-clock time and returns the clock skew derived from the peer's, if the peer sent it.
+func doHandshake(conn net.Conn, deadline time.Time, version int, extensions []string) (theirExtensions []string, skew *ClockSkew, rErr *HandshakeError) {
+	sentAt := time.Now()
 	ours := HandshakeMessage{
 		ProtocolVersion: version,
-		Extensions:      nil,
+		Extensions:      append(append([]string(nil), extensions...), clockExtension(sentAt)),
 	}
 	hsb, err := ours.Encode()
 	if err != nil {
-		return hsErr("could not encode protocol banner: %s", err)
+		return nil, nil, hsErr("could not encode protocol banner: %s", err)
 	}
 
 	err = conn.SetDeadline(deadline)
 	if err != nil {
-		return hsErr("could not set deadline for protocol banner handshake: %s", err)
+		return nil, nil, hsErr("could not set deadline for protocol banner handshake: %s", err)
 	}
 	defer func() {
 		if rErr != nil {
@@ -182,19 +193,20 @@ func DoHandshakeVersion(conn net.Conn, deadline time.Time, version int) (rErr *H
 	}()
 	_, err = io.Copy(conn, bytes.NewBuffer(hsb))
 	if err != nil {
-		return hsErr("could not send protocol banner: %s", err)
+		return nil, nil, hsErr("could not send protocol banner: %s", err)
 	}
 
 	theirs := HandshakeMessage{}
-	if err := theirs.DecodeReader(conn, HandshakeMessageMaxLen); err != nil {
-		return hsErr("could not decode protocol banner: %s", err)
+	err = theirs.DecodeReader(conn, HandshakeMessageMaxLen)
+	receivedAt := time.Now()
+	if err != nil {
+		return nil, nil, hsErr("could not decode protocol banner: %s", err)
 	}
 
 	if theirs.ProtocolVersion != ours.ProtocolVersion {
-		return hsErr("protocol versions do not match: ours is %d, theirs is %d",
+		return nil, nil, hsErr("protocol versions do not match: ours is %d, theirs is %d",
 			ours.ProtocolVersion, theirs.ProtocolVersion)
 	}
-	// ignore extensions, we don't use them
 
-	return nil
+	return theirs.Extensions, clockSkewFromExtensions(theirs.Extensions, sentAt, receivedAt), nil
 }
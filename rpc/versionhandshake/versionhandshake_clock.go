@@ -0,0 +1,59 @@
+package versionhandshake
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const extensionClockPrefix = "time="
+
+// ClockSkew is the difference between the peer's wall clock and ours,
+// measured during the handshake.
+type ClockSkew struct {
+	// positive if the peer's clock is ahead of ours
+	Skew time.Duration
+	// half the round trip of the handshake, the measurement is accurate up to ±Uncertainty
+	Uncertainty time.Duration
+	MeasuredAt  time.Time
+}
+
+// Exceeds returns whether the skew exceeds threshold, even if the measurement was off by Uncertainty.
+func (s ClockSkew) Exceeds(threshold time.Duration) bool {
+	abs := s.Skew
+	if abs < 0 {
+		abs = -abs
+	}
+	return abs-s.Uncertainty > threshold
+}
+
+// A ClockSkewObserver is a Connecter that wants to know the clock skew to the peer
+// measured during the handshake of each connection.
+// Peers that predate the measurement do not send their time, and ObserveClockSkew is not called.
+type ClockSkewObserver interface {
+	ObserveClockSkew(ClockSkew)
+}
+
+func clockExtension(now time.Time) string {
+	return extensionClockPrefix + strconv.FormatInt(now.UnixNano(), 10)
+}
+
+func clockSkewFromExtensions(theirExtensions []string, sentAt, receivedAt time.Time) *ClockSkew {
+	for _, ext := range theirExtensions {
+		if !strings.HasPrefix(ext, extensionClockPrefix) {
+			continue
+		}
+		ns, err := strconv.ParseInt(strings.TrimPrefix(ext, extensionClockPrefix), 10, 64)
+		if err != nil {
+			return nil
+		}
+		// both sides send their banner right after connecting, assume the peer's time is from halfway through
+		uncertainty := receivedAt.Sub(sentAt) / 2
+		return &ClockSkew{
+			Skew:        time.Unix(0, ns).Sub(sentAt.Add(uncertainty)),
+			Uncertainty: uncertainty,
+			MeasuredAt:  receivedAt,
+		}
+	}
+	return nil
+}
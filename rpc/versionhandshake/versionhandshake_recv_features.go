@@ -0,0 +1,56 @@
+package versionhandshake
+
+import (
+	"strings"
+)
+
+const extensionRecvFeaturesPrefix = "recv_features="
+
+// A RecvFeaturesAdvertiser is a Connecter or AuthenticatedListener of a receiving side
+// that tells the peer which features its receiving pool supports, see RecvFeaturesObserver.
+// ok is false if the features are unknown, the peer then learns nothing.
+type RecvFeaturesAdvertiser interface {
+	AdvertisedRecvFeatures() (features []string, ok bool)
+}
+
+// A RecvFeaturesObserver is a Connecter or AuthenticatedListener of a sending side
+// that wants to know the features advertised by the peer's RecvFeaturesAdvertiser.
+// clientIdentity is the identity of the connection's client if the observer is an AuthenticatedListener,
+// and empty if it is a Connecter.
+// Peers that do not advertise their features, e.g. because they predate the advertisement, are not observed.
+type RecvFeaturesObserver interface {
+	ObservePeerRecvFeatures(clientIdentity string, features []string)
+}
+
+// recvFeaturesExtensions returns the handshake extensions that advertise the features of i
+// if it is a RecvFeaturesAdvertiser.
+func recvFeaturesExtensions(i interface{}) []string {
+	a, ok := i.(RecvFeaturesAdvertiser)
+	if !ok {
+		return nil
+	}
+	features, ok := a.AdvertisedRecvFeatures()
+	if !ok {
+		return nil
+	}
+	for _, f := range features {
+		if f == "" || strings.ContainsAny(f, ",\n") {
+			return nil // cannot be encoded, advertise nothing rather than something wrong
+		}
+	}
+	return []string{extensionRecvFeaturesPrefix + strings.Join(features, ",")}
+}
+
+func recvFeaturesFromExtensions(theirExtensions []string) (features []string, ok bool) {
+	for _, ext := range theirExtensions {
+		if !strings.HasPrefix(ext, extensionRecvFeaturesPrefix) {
+			continue
+		}
+		list := strings.TrimPrefix(ext, extensionRecvFeaturesPrefix)
+		if list == "" {
+			return []string{}, true
+		}
+		return strings.Split(list, ","), true
+	}
+	return nil, false
+}
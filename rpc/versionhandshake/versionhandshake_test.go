@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/zrepl/zrepl/transport"
 	"github.com/zrepl/zrepl/util/socketpair"
 )
 
@@ -117,3 +118,170 @@ func TestDoHandshakeCurrentVersion(t *testing.T) {
 	assert.Nil(t, <-srvErrCh)
 
 }
+
+type testCompressionRequester transport.Compression
+
+func (r testCompressionRequester) RequestedCompression() transport.Compression {
+	return transport.Compression(r)
+}
+
+func TestNegotiateCompression(t *testing.T) {
+	zstd := transport.CompressionZstd
+	none := transport.CompressionNone
+
+	type tc struct {
+		ours   interface{}
+		theirs interface{}
+		expect transport.Compression
+	}
+	tcs := []tc{
+		{struct{}{}, struct{}{}, none},
+		{testCompressionRequester(zstd), struct{}{}, none},
+		{struct{}{}, testCompressionRequester(zstd), none},
+		{testCompressionRequester(none), testCompressionRequester(zstd), none},
+		{testCompressionRequester(zstd), testCompressionRequester(zstd), zstd},
+	}
+	for i, c := range tcs {
+		ours, _ := requestedCompression(c.ours)
+		_, theirExts := requestedCompression(c.theirs)
+		assert.Equal(t, c.expect, negotiateCompression(ours, theirExts), "case %d", i)
+	}
+}
+
+func TestDoHandshakeAndNegotiateCompression(t *testing.T) {
+	srv, client, err := socketpair.SocketPair()
+	require.NoError(t, err)
+	defer srv.Close()
+	defer client.Close()
+
+	type res struct {
+		w   transport.Wire
+		err *HandshakeError
+	}
+	srvResCh := make(chan res)
+	go func() {
+		w, err := doHandshakeAndNegotiateCompression(srv, time.Now().Add(2*time.Second), testCompressionRequester(transport.CompressionZstd), "")
+		srvResCh <- res{w, err}
+	}()
+	clientW, hsErr := doHandshakeAndNegotiateCompression(client, time.Now().Add(2*time.Second), testCompressionRequester(transport.CompressionZstd), "")
+	require.Nil(t, hsErr)
+	srvRes := <-srvResCh
+	require.Nil(t, srvRes.err)
+
+	assert.NotEqual(t, transport.Wire(client), clientW, "connection should be wrapped for compression")
+
+	msg := []byte("hello compressed world")
+	go func() {
+		_, err := clientW.Write(msg)
+		assert.NoError(t, err)
+	}()
+	buf := make([]byte, len(msg))
+	_, err = io.ReadFull(srvRes.w, buf)
+	require.NoError(t, err)
+	assert.Equal(t, msg, buf)
+}
+
+type testClockSkewObserver struct {
+	observed []ClockSkew
+}
+
+func (o *testClockSkewObserver) ObserveClockSkew(s ClockSkew) { o.observed = append(o.observed, s) }
+
+func TestDoHandshakeObservesClockSkew(t *testing.T) {
+	srv, client, err := socketpair.SocketPair()
+	require.NoError(t, err)
+	defer srv.Close()
+	defer client.Close()
+
+	srvErrCh := make(chan *HandshakeError)
+	go func() {
+		_, err := doHandshakeAndNegotiateCompression(srv, time.Now().Add(2*time.Second), struct{}{}, "")
+		srvErrCh <- err
+	}()
+	var o testClockSkewObserver
+	_, hsErr := doHandshakeAndNegotiateCompression(client, time.Now().Add(2*time.Second), &o, "")
+	require.Nil(t, hsErr)
+	require.Nil(t, <-srvErrCh)
+
+	require.Len(t, o.observed, 1)
+	assert.False(t, o.observed[0].Exceeds(time.Second), "same clock: %#v", o.observed[0])
+}
+
+func TestClockSkewFromExtensions(t *testing.T) {
+	sentAt := time.Unix(1000, 0)
+	receivedAt := sentAt.Add(2 * time.Second)
+
+	skew := clockSkewFromExtensions([]string{"compression=zstd", clockExtension(sentAt.Add(61 * time.Second))}, sentAt, receivedAt)
+	require.NotNil(t, skew)
+	assert.Equal(t, 60*time.Second, skew.Skew)
+	assert.Equal(t, time.Second, skew.Uncertainty)
+	assert.Equal(t, receivedAt, skew.MeasuredAt)
+	assert.True(t, skew.Exceeds(58*time.Second))
+	assert.False(t, skew.Exceeds(59*time.Second), "within uncertainty")
+
+	skew = clockSkewFromExtensions([]string{clockExtension(sentAt.Add(-59 * time.Second))}, sentAt, receivedAt)
+	require.NotNil(t, skew)
+	assert.Equal(t, -60*time.Second, skew.Skew)
+	assert.True(t, skew.Exceeds(30*time.Second))
+
+	assert.Nil(t, clockSkewFromExtensions(nil, sentAt, receivedAt), "peer predates the measurement")
+	assert.Nil(t, clockSkewFromExtensions([]string{"time=yesterday"}, sentAt, receivedAt))
+}
+
+type testRecvFeaturesAdvertiser []string
+
+func (a testRecvFeaturesAdvertiser) AdvertisedRecvFeatures() ([]string, bool) {
+	if a == nil {
+		return nil, false
+	}
+	return a, true
+}
+
+type testRecvFeaturesObserver struct {
+	clientIdentity string
+	features       []string
+	observed       bool
+}
+
+func (o *testRecvFeaturesObserver) ObservePeerRecvFeatures(clientIdentity string, features []string) {
+	o.clientIdentity, o.features, o.observed = clientIdentity, features, true
+}
+
+func TestDoHandshakeObservesRecvFeatures(t *testing.T) {
+	tcs := []struct {
+		advertised testRecvFeaturesAdvertiser
+		observed   bool
+	}{
+		{testRecvFeaturesAdvertiser{"large_blocks", "embedded_data"}, true},
+		{testRecvFeaturesAdvertiser{}, true},
+		{nil, false},
+	}
+	for _, tc := range tcs {
+		srv, client, err := socketpair.SocketPair()
+		require.NoError(t, err)
+
+		srvErrCh := make(chan *HandshakeError)
+		var o testRecvFeaturesObserver
+		go func() {
+			_, err := doHandshakeAndNegotiateCompression(srv, time.Now().Add(2*time.Second), &o, "client1")
+			srvErrCh <- err
+		}()
+		_, hsErr := doHandshakeAndNegotiateCompression(client, time.Now().Add(2*time.Second), tc.advertised, "")
+		require.Nil(t, hsErr)
+		require.Nil(t, <-srvErrCh)
+		srv.Close()
+		client.Close()
+
+		assert.Equal(t, tc.observed, o.observed, "%v", tc.advertised)
+		if tc.observed {
+			assert.Equal(t, "client1", o.clientIdentity)
+			assert.Equal(t, []string(tc.advertised), o.features)
+		}
+	}
+}
+
+func TestRecvFeaturesExtensionsRejectsUnencodable(t *testing.T) {
+	assert.Nil(t, recvFeaturesExtensions(testRecvFeaturesAdvertiser{"a,b"}))
+	assert.Nil(t, recvFeaturesExtensions(testRecvFeaturesAdvertiser{""}))
+	assert.Nil(t, recvFeaturesExtensions(struct{}{}))
+}
@@ -6,8 +6,58 @@ import (
 	"time"
 
 	"github.com/zrepl/zrepl/transport"
+	"github.com/zrepl/zrepl/transport/compression"
 )
 
+const extensionCompressionPrefix = "compression="
+
+// requestedCompression returns the compression requested by the Connecter or AuthenticatedListener i
+// and the handshake extensions that advertise it to the peer.
+func requestedCompression(i interface{}) (c transport.Compression, extensions []string) {
+	cr, ok := i.(transport.CompressionRequester)
+	if !ok || cr.RequestedCompression() == transport.CompressionNone {
+		return transport.CompressionNone, nil
+	}
+	c = cr.RequestedCompression()
+	return c, []string{extensionCompressionPrefix + string(c)}
+}
+
+// negotiateCompression returns ours if the peer requested it as well, CompressionNone otherwise.
+func negotiateCompression(ours transport.Compression, theirExtensions []string) transport.Compression {
+	if ours == transport.CompressionNone {
+		return transport.CompressionNone
+	}
+	for _, ext := range theirExtensions {
+		if ext == extensionCompressionPrefix+string(ours) {
+			return ours
+		}
+	}
+	return transport.CompressionNone
+}
+
+// clientIdentity is the identity of conn's client if requester is an AuthenticatedListener, see RecvFeaturesObserver.
+func doHandshakeAndNegotiateCompression(conn transport.Wire, deadline time.Time, requester interface{}, clientIdentity string) (transport.Wire, *HandshakeError) {
+	ours, exts := requestedCompression(requester)
+	exts = append(exts, recvFeaturesExtensions(requester)...)
+	theirs, skew, hsErr := doHandshake(conn, deadline, currentProtocolVersion, exts)
+	if hsErr != nil {
+		return nil, hsErr
+	}
+	if o, ok := requester.(ClockSkewObserver); ok && skew != nil {
+		o.ObserveClockSkew(*skew)
+	}
+	if o, ok := requester.(RecvFeaturesObserver); ok {
+		if features, ok := recvFeaturesFromExtensions(theirs); ok {
+			o.ObservePeerRecvFeatures(clientIdentity, features)
+		}
+	}
+	wrapped, err := compression.Wrap(conn, negotiateCompression(ours, theirs))
+	if err != nil {
+		return nil, &HandshakeError{msg: "cannot set up negotiated compression: " + err.Error()}
+	}
+	return wrapped, nil
+}
+
 type HandshakeConnecter struct {
 	connecter transport.Connecter
 	timeout   time.Duration
@@ -22,11 +72,12 @@ func (c HandshakeConnecter) Connect(ctx context.Context) (transport.Wire, error)
 	if !ok {
 		dl = time.Now().Add(c.timeout)
 	}
-	if err := DoHandshakeCurrentVersion(conn, dl); err != nil {
+	wrapped, hsErr := doHandshakeAndNegotiateCompression(conn, dl, c.connecter, "")
+	if hsErr != nil {
 		conn.Close()
-		return nil, err
+		return nil, hsErr
 	}
-	return conn, nil
+	return wrapped, nil
 }
 
 func Connecter(connecter transport.Connecter, timeout time.Duration) HandshakeConnecter {
@@ -55,12 +106,16 @@ func (l HandshakeListener) Accept(ctx context.Context) (*transport.AuthConn, err
 	if !ok {
 		dl = time.Now().Add(l.timeout) // shadowing
 	}
-	if err := DoHandshakeCurrentVersion(conn, dl); err != nil {
-		err.isAcceptError = true
+	wrapped, hsErr := doHandshakeAndNegotiateCompression(conn, dl, l.l, conn.ClientIdentity())
+	if hsErr != nil {
+		hsErr.isAcceptError = true
 		conn.Close()
-		return nil, err
+		return nil, hsErr
+	}
+	if wrapped == transport.Wire(conn) {
+		return conn, nil
 	}
-	return conn, nil
+	return transport.NewAuthConn(wrapped, conn.ClientIdentity()), nil
 }
 
 func Listener(l transport.AuthenticatedListener, timeout time.Duration) transport.AuthenticatedListener {
@@ -9,6 +9,9 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -24,16 +27,100 @@ func ParseCAFile(certfile string) (*x509.CertPool, error) {
 	return pool, nil
 }
 
+// ParseCAFilesAndDirs adds the PEM-encoded certificates in all paths to a new pool.
+// A path is either a file, which must contain at least one certificate,
+// or a directory, whose regular files are loaded in lexical order.
+// Files in a directory that do not contain certificates are skipped,
+// but every directory must yield at least one certificate.
+func ParseCAFilesAndDirs(paths []string) (*x509.CertPool, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no CA files specified")
+	}
+	pool := x509.NewCertPool()
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			pem, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("%s: PEM parsing error", path)
+			}
+			continue
+		}
+		entries, err := ioutil.ReadDir(path) // sorted by name
+		if err != nil {
+			return nil, err
+		}
+		var found bool
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			p := filepath.Join(path, e.Name())
+			if fi, err := os.Stat(p); err != nil || !fi.Mode().IsRegular() { // follows symlinks, e.g. by c_rehash
+				continue
+			}
+			pem, err := ioutil.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			if pool.AppendCertsFromPEM(pem) {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: directory does not contain any PEM-encoded certificates", path)
+		}
+	}
+	return pool, nil
+}
+
+// LoadX509KeyPair is like tls.LoadX509KeyPair, but key may also be the
+// PEM-encoded private key itself instead of a path to it
+// (e.g. if it was resolved from a ${file:/path} reference in the config).
+func LoadX509KeyPair(certFile, key string) (tls.Certificate, error) {
+	if !strings.HasPrefix(key, "-----BEGIN") {
+		return tls.LoadX509KeyPair(certFile, key)
+	}
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, []byte(key))
+}
+
+// KeyPairFiles returns the files read by LoadX509KeyPair(certFile, key).
+func KeyPairFiles(certFile, key string) []string {
+	if strings.HasPrefix(key, "-----BEGIN") {
+		return []string{certFile}
+	}
+	return []string{certFile, key}
+}
+
 type ClientAuthListener struct {
 	l                *net.TCPListener
-	c                *tls.Config
 	handshakeTimeout time.Duration
+	allowRemote      func(addr *net.TCPAddr) bool
 }
 
-func NewClientAuthListener(
-	l *net.TCPListener, ca *x509.CertPool, serverCert tls.Certificate,
-	handshakeTimeout time.Duration) *ClientAuthListener {
+// NewClientAuthListener returns a listener that closes connections from remote addresses
+// for which allowRemote returns false before the TLS handshake.
+// A nil allowRemote allows all remote addresses.
+func NewClientAuthListener(l *net.TCPListener, handshakeTimeout time.Duration, allowRemote func(addr *net.TCPAddr) bool) *ClientAuthListener {
+	return &ClientAuthListener{
+		l,
+		handshakeTimeout,
+		allowRemote,
+	}
+}
 
+// ClientAuthServer returns the tls.Config for a ClientAuthListener.
+func ClientAuthServer(ca *x509.CertPool, serverCert tls.Certificate) *tls.Config {
 	if ca == nil {
 		panic(ca)
 	}
@@ -41,35 +128,34 @@ func NewClientAuthListener(
 		panic(serverCert)
 	}
 
-	tlsConf := &tls.Config{
+	return &tls.Config{
 		Certificates:             []tls.Certificate{serverCert},
 		ClientCAs:                ca,
 		ClientAuth:               tls.RequireAndVerifyClientCert,
 		PreferServerCipherSuites: true,
 		KeyLogWriter:             keylogFromEnv(),
 	}
-	return &ClientAuthListener{
-		l,
-		tlsConf,
-		handshakeTimeout,
-	}
 }
 
 // Accept() accepts a connection from the *net.TCPListener passed to the constructor
-// and sets up the TLS connection, including handshake and peer CommonName validation
-// within the specified handshakeTimeout.
+// and sets up the TLS connection using the config returned by getConf (see ClientAuthServer),
+// including handshake and peer CommonName validation within the specified handshakeTimeout.
 //
 // It returns both the raw TCP connection (tcpConn) and the TLS connection (tlsConn) on top of it.
 // Access to the raw tcpConn might be necessary if CloseWrite semantics are desired:
 // tlsConn.CloseWrite does NOT call tcpConn.CloseWrite, hence we provide access to tcpConn to
 // allow the caller to do this by themselves.
-func (l *ClientAuthListener) Accept() (tcpConn *net.TCPConn, tlsConn *tls.Conn, clientCN string, err error) {
+func (l *ClientAuthListener) Accept(getConf func() *tls.Config) (tcpConn *net.TCPConn, tlsConn *tls.Conn, clientCN string, err error) {
 	tcpConn, err = l.l.AcceptTCP()
 	if err != nil {
 		return nil, nil, "", err
 	}
+	if remote := tcpConn.RemoteAddr().(*net.TCPAddr); l.allowRemote != nil && !l.allowRemote(remote) {
+		tcpConn.Close() // TODO log error
+		return nil, nil, "", fmt.Errorf("connection from %s rejected before TLS handshake", remote.IP)
+	}
 
-	tlsConn = tls.Server(tcpConn, l.c)
+	tlsConn = tls.Server(tcpConn, getConf())
 	var (
 		cn        string
 		peerCerts []*x509.Certificate
@@ -124,15 +210,23 @@ func ClientAuthClient(serverName string, rootCA *x509.CertPool, clientCert tls.C
 	return tlsConfig, nil
 }
 
+var keylog struct {
+	once sync.Once
+	w    io.Writer
+}
+
+// keylogFromEnv returns the key log writer for ZREPL_KEYLOG_FILE, which is opened only once
+// so that rebuilding a tls.Config does not truncate it.
 func keylogFromEnv() io.Writer {
-	var keyLog io.Writer = nil
-	if outfile := os.Getenv("ZREPL_KEYLOG_FILE"); outfile != "" {
-		fmt.Fprintf(os.Stderr, "writing to key log %s\n", outfile)
-		var err error
-		keyLog, err = os.OpenFile(outfile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
-		if err != nil {
-			panic(err)
+	keylog.once.Do(func() {
+		if outfile := os.Getenv("ZREPL_KEYLOG_FILE"); outfile != "" {
+			fmt.Fprintf(os.Stderr, "writing to key log %s\n", outfile)
+			f, err := os.OpenFile(outfile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+			if err != nil {
+				panic(err)
+			}
+			keylog.w = f
 		}
-	}
-	return keyLog
+	})
+	return keylog.w
 }
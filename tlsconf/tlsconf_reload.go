@@ -0,0 +1,135 @@
+package tlsconf
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zrepl/zrepl/util/envconst"
+)
+
+// reloadRequests is incremented by RequestReload.
+var reloadRequests uint64
+
+// RequestReload makes every ReloadingConfig rebuild its tls.Config on the next call to Get,
+// regardless of whether its files have changed (e.g. on SIGHUP).
+func RequestReload() {
+	atomic.AddUint64(&reloadRequests, 1)
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// ReloadingConfig is a tls.Config built from certificate, key and CA files
+// that is rebuilt when the files change or after RequestReload.
+//
+// The files are checked for changes (modification time and size) at most every
+// ZREPL_TLS_RELOAD_CHECK_INTERVAL on calls to Get.
+// If rebuilding fails, e.g. because the new certificate does not match the new key,
+// Get keeps returning the previous tls.Config until the files change again.
+type ReloadingConfig struct {
+	paths         []string
+	build         func() (*tls.Config, error)
+	checkInterval time.Duration
+
+	mtx            sync.Mutex
+	conf           *tls.Config
+	stamps         map[string]fileStamp
+	lastCheck      time.Time
+	reloadRequests uint64
+}
+
+// NewReloadingConfig builds the initial tls.Config using build and returns its error, if any.
+// paths are the files that build reads; directories are checked including the files they contain.
+func NewReloadingConfig(paths []string, build func() (*tls.Config, error)) (*ReloadingConfig, error) {
+	c := &ReloadingConfig{
+		paths:          paths,
+		build:          build,
+		checkInterval:  envconst.Duration("ZREPL_TLS_RELOAD_CHECK_INTERVAL", 10*time.Second),
+		stamps:         fileStamps(paths),
+		lastCheck:      time.Now(),
+		reloadRequests: atomic.LoadUint64(&reloadRequests),
+	}
+	conf, err := build()
+	if err != nil {
+		return nil, err
+	}
+	c.conf = conf
+	return c, nil
+}
+
+// Get returns the current tls.Config, rebuilding it first if the files changed or a reload was requested.
+// If it was rebuilt, reloaded is true.
+// If rebuilding failed, err is the error and conf is the previous tls.Config.
+func (c *ReloadingConfig) Get() (conf *tls.Config, reloaded bool, err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	now := time.Now()
+	requests := atomic.LoadUint64(&reloadRequests)
+	requested := requests != c.reloadRequests
+	if !requested && now.Sub(c.lastCheck) < c.checkInterval {
+		return c.conf, false, nil
+	}
+	c.lastCheck = now
+	c.reloadRequests = requests
+
+	stamps := fileStamps(c.paths)
+	if !requested && stampsEqual(stamps, c.stamps) {
+		return c.conf, false, nil
+	}
+	c.stamps = stamps // don't retry a failed build until the files change again
+
+	newConf, err := c.build()
+	if err != nil {
+		return c.conf, false, err
+	}
+	c.conf = newConf
+	return c.conf, true, nil
+}
+
+// fileStamps returns the stamps of paths and, for directories, of the files they contain.
+// Paths that cannot be stat'ed are omitted, so that their re-appearance counts as a change.
+func fileStamps(paths []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(paths))
+	var add func(path string, recurse bool)
+	add = func(path string, recurse bool) {
+		fi, err := os.Stat(path) // follows symlinks, e.g. the ones updated by cert-manager
+		if err != nil {
+			return
+		}
+		stamps[path] = fileStamp{fi.ModTime(), fi.Size()}
+		if !fi.IsDir() || !recurse {
+			return
+		}
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return
+		}
+		for _, e := range entries {
+			add(filepath.Join(path, e.Name()), false)
+		}
+	}
+	for _, p := range paths {
+		add(p, true)
+	}
+	return stamps
+}
+
+func stampsEqual(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for p, s := range a {
+		if bs, ok := b[p]; !ok || !bs.modTime.Equal(s.modTime) || bs.size != s.size {
+			return false
+		}
+	}
+	return true
+}
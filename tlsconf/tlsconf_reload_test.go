@@ -0,0 +1,119 @@
+package tlsconf
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func selfSignedKeyPairPEM(t *testing.T, cn string) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestReloadingConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-tlsconf")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writePair := func(cn string, mtime time.Time) {
+		certPEM, keyPEM := selfSignedKeyPairPEM(t, cn)
+		require.NoError(t, ioutil.WriteFile(certFile, certPEM, 0600))
+		require.NoError(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+		// the files are rewritten within the mtime granularity of some file systems
+		require.NoError(t, os.Chtimes(certFile, mtime, mtime))
+		require.NoError(t, os.Chtimes(keyFile, mtime, mtime))
+	}
+	t0 := time.Now().Add(-time.Hour)
+	writePair("old", t0)
+
+	builds := 0
+	c, err := NewReloadingConfig(KeyPairFiles(certFile, keyFile), func() (*tls.Config, error) {
+		builds++
+		cert, err := LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	})
+	require.NoError(t, err)
+	c.checkInterval = 0
+
+	cn := func(conf *tls.Config) string {
+		leaf, err := x509.ParseCertificate(conf.Certificates[0].Certificate[0])
+		require.NoError(t, err)
+		return leaf.Subject.CommonName
+	}
+
+	conf, reloaded, err := c.Get()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+	assert.Equal(t, "old", cn(conf))
+	assert.Equal(t, 1, builds)
+
+	writePair("new", t0.Add(time.Minute))
+	conf, reloaded, err = c.Get()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "new", cn(conf))
+
+	// a certificate that does not match the key is not swapped in, and not retried until the files change
+	otherCert, _ := selfSignedKeyPairPEM(t, "mismatch")
+	require.NoError(t, ioutil.WriteFile(certFile, otherCert, 0600))
+	require.NoError(t, os.Chtimes(certFile, t0.Add(2*time.Minute), t0.Add(2*time.Minute)))
+	conf, reloaded, err = c.Get()
+	assert.Error(t, err)
+	assert.False(t, reloaded)
+	assert.Equal(t, "new", cn(conf))
+	builds = 0
+	_, _, err = c.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, builds)
+
+	// a requested reload rebuilds even if the files did not change
+	writePair("requested", t0.Add(3*time.Minute))
+	_, reloaded, err = c.Get()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	builds = 0
+	RequestReload()
+	conf, reloaded, err = c.Get()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, 1, builds)
+	assert.Equal(t, "requested", cn(conf))
+
+	// no checks within the check interval
+	c.checkInterval = time.Hour
+	writePair("unchecked", t0.Add(4*time.Minute))
+	conf, reloaded, err = c.Get()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+	assert.Equal(t, "requested", cn(conf))
+}
@@ -0,0 +1,74 @@
+package tlsconf
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func selfSignedCAPEM(t *testing.T, cn string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestParseCAFilesAndDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-tlsconf")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(name string, content []byte) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0700))
+		require.NoError(t, ioutil.WriteFile(p, content, 0600))
+		return p
+	}
+	oldCA := write("old-ca.crt", selfSignedCAPEM(t, "old"))
+	write("cas.d/new-ca.pem", selfSignedCAPEM(t, "new"))
+	write("cas.d/other-ca.pem", selfSignedCAPEM(t, "other"))
+	write("cas.d/README", []byte("not a certificate"))
+	garbage := write("garbage.crt", []byte("not a certificate"))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "empty.d"), 0700))
+	write("empty.d/README", []byte("not a certificate"))
+
+	subjects := func(paths ...string) int {
+		pool, err := ParseCAFilesAndDirs(paths)
+		require.NoError(t, err)
+		return len(pool.Subjects()) //nolint:staticcheck
+	}
+	assert.Equal(t, 1, subjects(oldCA))
+	assert.Equal(t, 2, subjects(filepath.Join(dir, "cas.d")))
+	assert.Equal(t, 3, subjects(oldCA, filepath.Join(dir, "cas.d")))
+
+	_, err = ParseCAFilesAndDirs(nil)
+	assert.Error(t, err)
+	_, err = ParseCAFilesAndDirs([]string{garbage})
+	assert.Error(t, err)
+	_, err = ParseCAFilesAndDirs([]string{filepath.Join(dir, "empty.d")})
+	assert.Error(t, err)
+	_, err = ParseCAFilesAndDirs([]string{filepath.Join(dir, "nonexistent")})
+	assert.Error(t, err)
+}
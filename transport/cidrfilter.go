@@ -0,0 +1,74 @@
+package transport
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// CIDRFilter decides whether a listener accepts connections from a remote address,
+// before any authentication takes place.
+// A nil *CIDRFilter allows all addresses.
+type CIDRFilter struct {
+	allow, deny []*net.IPNet
+}
+
+// CIDRFilterFromConfig returns a filter that rejects the addresses matched by deny and,
+// if allow is not empty, the addresses not matched by allow.
+// Entries are CIDRs (e.g. 192.168.0.0/16, fe80::/10) or single IP addresses.
+// If both allow and deny are empty, CIDRFilterFromConfig returns nil.
+func CIDRFilterFromConfig(allow, deny []string) (*CIDRFilter, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+	var f CIDRFilter
+	var err error
+	if f.allow, err = parseCIDRs(allow); err != nil {
+		return nil, errors.Wrap(err, "field `allow_cidrs`")
+	}
+	if f.deny, err = parseCIDRs(deny); err != nil {
+		return nil, errors.Wrap(err, "field `deny_cidrs`")
+	}
+	return &f, nil
+}
+
+func parseCIDRs(in []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, len(in))
+	for i, s := range in {
+		if _, n, err := net.ParseCIDR(s); err == nil {
+			nets[i] = n
+			continue
+		}
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, errors.Errorf("entry #%d %q is neither a CIDR nor an IP address", i, s)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		nets[i] = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+	return nets, nil
+}
+
+// Allowed returns false if ip is matched by a deny entry, or if there are allow entries but none matches ip.
+func (f *CIDRFilter) Allowed(ip net.IP) bool {
+	if f == nil {
+		return true
+	}
+	for _, n := range f.deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, n := range f.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package transport
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIDRFilter(t *testing.T) {
+	f, err := CIDRFilterFromConfig(nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, f)
+	assert.True(t, f.Allowed(net.ParseIP("10.0.0.1")))
+
+	f, err = CIDRFilterFromConfig([]string{"10.0.0.0/8", "fd00::/8", "192.168.1.1"}, []string{"10.1.0.0/16"})
+	require.NoError(t, err)
+	allowed := map[string]bool{
+		"10.0.0.1":        true,
+		"10.1.2.3":        false, // denied takes precedence
+		"192.168.1.1":     true,
+		"192.168.1.2":     false,
+		"::ffff:10.0.0.1": true, // IPv4-mapped IPv6 address
+		"fd00::1":         true,
+		"fe80::1":         false,
+	}
+	for ip, expect := range allowed {
+		assert.Equal(t, expect, f.Allowed(net.ParseIP(ip)), ip)
+	}
+
+	f, err = CIDRFilterFromConfig(nil, []string{"0.0.0.0/0"})
+	require.NoError(t, err)
+	assert.False(t, f.Allowed(net.ParseIP("10.0.0.1")))
+	assert.True(t, f.Allowed(net.ParseIP("fe80::1")))
+
+	_, err = CIDRFilterFromConfig([]string{"10.0.0.0/33"}, nil)
+	assert.Error(t, err)
+	_, err = CIDRFilterFromConfig(nil, []string{"example.com"})
+	assert.Error(t, err)
+}
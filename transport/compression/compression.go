@@ -0,0 +1,91 @@
+// Package compression implements transport-level compression of a transport.Wire.
+//
+// Compression is negotiated by package rpc/versionhandshake, see transport.CompressionRequester.
+package compression
+
+import (
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/transport"
+	"github.com/zrepl/zrepl/util/envconst"
+)
+
+// Wrap returns a Wire that transparently compresses all data written to w
+// and decompresses all data read from w using algorithm c.
+//
+// The peer must wrap its side of the connection with the same algorithm.
+// The returned Wire does not implement timeoutconn.SyscallConner since
+// vectored IO would bypass compression.
+func Wrap(w transport.Wire, c transport.Compression) (transport.Wire, error) {
+	switch c {
+	case transport.CompressionNone:
+		return w, nil
+	case transport.CompressionZstd:
+		return newZstdWire(w)
+	default:
+		return nil, errors.Errorf("unknown compression %q", c)
+	}
+}
+
+type zstdWire struct {
+	transport.Wire
+
+	writeMtx sync.Mutex
+	enc      *zstd.Encoder
+
+	readMtx sync.Mutex
+	dec     *zstd.Decoder
+}
+
+var zstdLevel = zstd.EncoderLevelFromZstd(envconst.Int("ZREPL_TRANSPORT_COMPRESSION_ZSTD_LEVEL", 3))
+
+func newZstdWire(w transport.Wire) (*zstdWire, error) {
+	enc, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstdLevel), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create zstd encoder")
+	}
+	dec, err := zstd.NewReader(w, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		enc.Close()
+		return nil, errors.Wrap(err, "cannot create zstd decoder")
+	}
+	return &zstdWire{Wire: w, enc: enc, dec: dec}, nil
+}
+
+func (w *zstdWire) Read(p []byte) (int, error) {
+	w.readMtx.Lock()
+	defer w.readMtx.Unlock()
+	return w.dec.Read(p)
+}
+
+// Write flushes the compressed data to the underlying Wire before returning
+// because the protocols on top of Wire are request-response-based.
+func (w *zstdWire) Write(p []byte) (int, error) {
+	w.writeMtx.Lock()
+	defer w.writeMtx.Unlock()
+	n, err := w.enc.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, w.enc.Flush()
+}
+
+func (w *zstdWire) CloseWrite() error {
+	w.writeMtx.Lock()
+	defer w.writeMtx.Unlock()
+	if err := w.enc.Close(); err != nil {
+		return err
+	}
+	return w.Wire.CloseWrite()
+}
+
+func (w *zstdWire) Close() error {
+	err := w.Wire.Close() // unblocks pending Read calls
+	w.readMtx.Lock()
+	defer w.readMtx.Unlock()
+	w.dec.Close()
+	return err
+}
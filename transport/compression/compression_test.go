@@ -0,0 +1,101 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/transport"
+	"github.com/zrepl/zrepl/util/socketpair"
+)
+
+func wrappedPair(t testing.TB, c transport.Compression) (a, b transport.Wire) {
+	ua, ub, err := socketpair.SocketPair()
+	require.NoError(t, err)
+	a, err = Wrap(ua, c)
+	require.NoError(t, err)
+	b, err = Wrap(ub, c)
+	require.NoError(t, err)
+	return a, b
+}
+
+func TestZstdWireRequestResponse(t *testing.T) {
+	a, b := wrappedPair(t, transport.CompressionZstd)
+	defer a.Close()
+	defer b.Close()
+
+	// each Write must be readable by the peer without further writes
+	for i := 0; i < 10; i++ {
+		req := bytes.Repeat([]byte{byte(i)}, 100*i+1)
+		_, err := a.Write(req)
+		require.NoError(t, err)
+		buf := make([]byte, len(req))
+		_, err = io.ReadFull(b, buf)
+		require.NoError(t, err)
+		assert.Equal(t, req, buf)
+
+		_, err = b.Write(buf)
+		require.NoError(t, err)
+		_, err = io.ReadFull(a, buf)
+		require.NoError(t, err)
+		assert.Equal(t, req, buf)
+	}
+}
+
+func TestZstdWireCloseWrite(t *testing.T) {
+	a, b := wrappedPair(t, transport.CompressionZstd)
+	defer a.Close()
+	defer b.Close()
+
+	msg := []byte("last message")
+	_, err := a.Write(msg)
+	require.NoError(t, err)
+	require.NoError(t, a.CloseWrite())
+
+	all, err := ioutil.ReadAll(b)
+	require.NoError(t, err)
+	assert.Equal(t, msg, all)
+
+	// read direction is unaffected by CloseWrite
+	_, err = b.Write(msg)
+	require.NoError(t, err)
+	buf := make([]byte, len(msg))
+	_, err = io.ReadFull(a, buf)
+	require.NoError(t, err)
+	assert.Equal(t, msg, buf)
+}
+
+func benchmarkWire(b *testing.B, c transport.Compression, compressible bool) {
+	w, r := wrappedPair(b, c)
+	defer w.Close()
+	defer r.Close()
+
+	chunk := make([]byte, 1<<16)
+	if !compressible {
+		rand.New(rand.NewSource(1)).Read(chunk)
+	}
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, r)
+	}()
+	b.SetBytes(int64(len(chunk)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := w.Write(chunk); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWireNoneCompressible(b *testing.B) { benchmarkWire(b, transport.CompressionNone, true) }
+func BenchmarkWireNoneIncompressible(b *testing.B) {
+	benchmarkWire(b, transport.CompressionNone, false)
+}
+func BenchmarkWireZstdCompressible(b *testing.B) { benchmarkWire(b, transport.CompressionZstd, true) }
+func BenchmarkWireZstdIncompressible(b *testing.B) {
+	benchmarkWire(b, transport.CompressionZstd, false)
+}
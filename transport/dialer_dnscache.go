@@ -0,0 +1,97 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type ipAddrResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// CachingDialer dials a TCP host:port address and caches the resolved addresses of the host for a TTL.
+// If resolving the host fails after the TTL has expired, the addresses of the last successful
+// resolution are used, so that a transient DNS failure does not prevent connecting.
+// A TTL of 0 disables caching: the address is resolved on every dial as by net.Dialer.
+type CachingDialer struct {
+	dialer     net.Dialer
+	address    string
+	host, port string
+	ttl        time.Duration
+
+	resolver ipAddrResolver
+	now      func() time.Time
+
+	mtx        sync.Mutex
+	addrs      []net.IPAddr
+	resolvedAt time.Time
+}
+
+func NewCachingDialer(dialer net.Dialer, address string, ttl time.Duration) (*CachingDialer, error) {
+	if ttl < 0 {
+		return nil, errors.Errorf("dns cache ttl must be 0 or positive, got %s", ttl)
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	return &CachingDialer{
+		dialer:   dialer,
+		address:  address,
+		host:     host,
+		port:     port,
+		ttl:      ttl,
+		resolver: net.DefaultResolver,
+		now:      time.Now,
+	}, nil
+}
+
+func (d *CachingDialer) DialContext(ctx context.Context) (net.Conn, error) {
+	if d.ttl == 0 || net.ParseIP(d.host) != nil {
+		return d.dialer.DialContext(ctx, "tcp", d.address)
+	}
+	addrs, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		var conn net.Conn
+		conn, err = d.dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr.String(), d.port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+func (d *CachingDialer) resolve(ctx context.Context) ([]net.IPAddr, error) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	now := d.now()
+	if d.addrs != nil && now.Sub(d.resolvedAt) < d.ttl {
+		return d.addrs, nil
+	}
+
+	addrs, err := d.resolver.LookupIPAddr(ctx, d.host)
+	if err == nil && len(addrs) == 0 {
+		err = errors.Errorf("no addresses found for host %q", d.host)
+	}
+	if err != nil {
+		if d.addrs == nil {
+			return nil, err
+		}
+		GetLogger(ctx).
+			WithError(err).
+			WithField("host", d.host).
+			WithField("resolved_at", d.resolvedAt).
+			Warn("cannot resolve host, using addresses of last successful resolution")
+		return d.addrs, nil
+	}
+	d.addrs, d.resolvedAt = addrs, now
+	return addrs, nil
+}
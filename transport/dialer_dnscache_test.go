@@ -0,0 +1,107 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver struct {
+	calls int
+	addrs []net.IPAddr
+	err   error
+}
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.calls++
+	return r.addrs, r.err
+}
+
+func TestCachingDialer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+
+	d, err := NewCachingDialer(net.Dialer{Timeout: time.Second}, net.JoinHostPort("backup.example.com", port), time.Minute)
+	require.NoError(t, err)
+	r := &fakeResolver{err: errors.New("temporary failure in name resolution")}
+	d.resolver = r
+	now := time.Unix(1000, 0)
+	d.now = func() time.Time { return now }
+	ctx := context.Background()
+	dial := func() error {
+		conn, err := d.DialContext(ctx)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+
+	// no last-good address
+	assert.Error(t, dial())
+	assert.Equal(t, 1, r.calls)
+
+	// unreachable address first, then the listener
+	r.addrs, r.err = []net.IPAddr{{IP: net.ParseIP("127.0.0.2")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+	assert.NoError(t, dial())
+	assert.Equal(t, 2, r.calls)
+
+	// cached within ttl
+	now = now.Add(59 * time.Second)
+	assert.NoError(t, dial())
+	assert.Equal(t, 2, r.calls)
+
+	// resolution fails after ttl => last-good addresses
+	now = now.Add(time.Second)
+	r.addrs, r.err = nil, errors.New("temporary failure in name resolution")
+	assert.NoError(t, dial())
+	assert.Equal(t, 3, r.calls)
+	now = now.Add(time.Second)
+	assert.NoError(t, dial())
+	assert.Equal(t, 4, r.calls) // the failure is not cached
+
+	// resolution succeeds again
+	r.addrs, r.err = []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+	assert.NoError(t, dial())
+	assert.Equal(t, 5, r.calls)
+	assert.Equal(t, now, d.resolvedAt)
+}
+
+func TestCachingDialerBypass(t *testing.T) {
+	r := &fakeResolver{err: errors.New("must not be called")}
+
+	// ttl 0
+	d, err := NewCachingDialer(net.Dialer{Timeout: time.Second}, "127.0.0.1:1", 0)
+	require.NoError(t, err)
+	d.resolver = r
+	_, _ = d.DialContext(context.Background())
+	assert.Equal(t, 0, r.calls)
+
+	// ip literal
+	d, err = NewCachingDialer(net.Dialer{Timeout: time.Second}, "[::1]:1", time.Minute)
+	require.NoError(t, err)
+	d.resolver = r
+	_, _ = d.DialContext(context.Background())
+	assert.Equal(t, 0, r.calls)
+
+	_, err = NewCachingDialer(net.Dialer{}, "backup.example.com:22", -time.Second)
+	assert.Error(t, err)
+	_, err = NewCachingDialer(net.Dialer{}, "backup.example.com", time.Minute)
+	assert.Error(t, err)
+}
@@ -51,6 +51,8 @@ func ConnecterFromConfig(g *config.Global, in config.ConnectEnum) (transport.Con
 		connecter, err = tls.TLSConnecterFromConfig(v)
 	case *config.LocalConnect:
 		connecter, err = local.LocalConnecterFromConfig(v)
+	case *config.ArchiveConnect:
+		return nil, errors.New("`archive` is not a transport, it can only be used as the `connect` of push jobs")
 	default:
 		panic(fmt.Sprintf("implementation error: unknown connecter type %T", v))
 	}
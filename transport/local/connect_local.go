@@ -13,6 +13,7 @@ type LocalConnecter struct {
 	listenerName   string
 	clientIdentity string
 	dialTimeout    time.Duration
+	direct         bool
 }
 
 func LocalConnecterFromConfig(in *config.LocalConnect) (*LocalConnecter, error) {
@@ -29,6 +30,7 @@ func LocalConnecterFromConfig(in *config.LocalConnect) (*LocalConnecter, error)
 		listenerName:   in.ListenerName,
 		clientIdentity: in.ClientIdentity,
 		dialTimeout:    in.DialTimeout,
+		direct:         in.Direct,
 	}
 	return cn, nil
 }
@@ -46,3 +48,19 @@ func (c *LocalConnecter) Connect(dialCtx context.Context) (transport.Wire, error
 	}
 	return w, err
 }
+
+// Direct returns true if the connecting job should invoke the handler registered
+// with the listener in-process (see LocalListener.SetDirect) instead of calling Connect.
+func (c *LocalConnecter) Direct() bool { return c.direct }
+
+func (c *LocalConnecter) ClientIdentity() string { return c.clientIdentity }
+
+// DirectHandler returns the handler registered with the listener.
+// It fails like Connect does if no job serves the listener.
+func (c *LocalConnecter) DirectHandler() (interface{}, error) {
+	h := GetLocalListener(c.listenerName).Direct()
+	if h == nil {
+		return nil, fmt.Errorf("local listener %q not reachable", c.listenerName)
+	}
+	return h, nil
+}
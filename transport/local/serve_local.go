@@ -47,6 +47,9 @@ type connectResult struct {
 
 type LocalListener struct {
 	connects chan connectRequest
+
+	directMtx sync.Mutex
+	direct    interface{}
 }
 
 func newLocalListener() *LocalListener {
@@ -83,6 +86,30 @@ func (l *LocalListener) Connect(dialCtx context.Context, clientIdentity string)
 	return conn, err
 }
 
+// SetDirect registers a handler that LocalConnecters with `direct` enabled
+// invoke in-process instead of connecting to the listener.
+// The type of h is up to the caller, the local transport only passes it through.
+// The returned function unregisters h.
+func (l *LocalListener) SetDirect(h interface{}) (unset func()) {
+	l.directMtx.Lock()
+	defer l.directMtx.Unlock()
+	l.direct = h
+	return func() {
+		l.directMtx.Lock()
+		defer l.directMtx.Unlock()
+		if l.direct == h {
+			l.direct = nil
+		}
+	}
+}
+
+// Direct returns the handler registered through SetDirect, or nil.
+func (l *LocalListener) Direct() interface{} {
+	l.directMtx.Lock()
+	defer l.directMtx.Unlock()
+	return l.direct
+}
+
 type localAddr struct {
 	S string
 }
@@ -9,20 +9,32 @@ import (
 )
 
 type TCPConnecter struct {
-	Address string
-	dialer  net.Dialer
+	Address     string
+	dialer      *transport.CachingDialer
+	compression transport.Compression
 }
 
 func TCPConnecterFromConfig(in *config.TCPConnect) (*TCPConnecter, error) {
 	dialer := net.Dialer{
 		Timeout: in.DialTimeout,
 	}
+	cachingDialer, err := transport.NewCachingDialer(dialer, in.Address, in.DNSCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	compression, err := transport.CompressionFromConfig(in.Compression)
+	if err != nil {
+		return nil, err
+	}
 
-	return &TCPConnecter{in.Address, dialer}, nil
+	return &TCPConnecter{in.Address, cachingDialer, compression}, nil
 }
 
+func (c *TCPConnecter) RequestedCompression() transport.Compression { return c.compression }
+
 func (c *TCPConnecter) Connect(dialCtx context.Context) (transport.Wire, error) {
-	conn, err := c.dialer.DialContext(dialCtx, "tcp", c.Address)
+	conn, err := c.dialer.DialContext(dialCtx)
 	if err != nil {
 		return nil, err
 	}
@@ -16,21 +16,33 @@ func TCPListenerFactoryFromConfig(c *config.Global, in *config.TCPServe) (transp
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot parse client IP map")
 	}
+	compression, err := transport.CompressionFromConfig(in.Compression)
+	if err != nil {
+		return nil, err
+	}
+	cidrFilter, err := transport.CIDRFilterFromConfig(in.AllowCIDRs, in.DenyCIDRs)
+	if err != nil {
+		return nil, err
+	}
 	lf := func() (transport.AuthenticatedListener, error) {
 		l, err := tcpsock.Listen(in.Listen, in.ListenFreeBind)
 		if err != nil {
 			return nil, err
 		}
-		return &TCPAuthListener{l, clientMap}, nil
+		return &TCPAuthListener{l, clientMap, cidrFilter, compression}, nil
 	}
 	return lf, nil
 }
 
 type TCPAuthListener struct {
 	*net.TCPListener
-	clientMap *ipMap
+	clientMap   *ipMap
+	cidrFilter  *transport.CIDRFilter
+	compression transport.Compression
 }
 
+func (f *TCPAuthListener) RequestedCompression() transport.Compression { return f.compression }
+
 func (f *TCPAuthListener) Accept(ctx context.Context) (*transport.AuthConn, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -46,6 +58,10 @@ func (f *TCPAuthListener) Accept(ctx context.Context) (*transport.AuthConn, erro
 		IP:   nc.RemoteAddr().(*net.TCPAddr).IP,
 		Zone: nc.RemoteAddr().(*net.TCPAddr).Zone,
 	}
+	if !f.cidrFilter.Allowed(clientAddr.IP) {
+		nc.Close()
+		return nil, errors.Errorf("connection from %s rejected by allow_cidrs/deny_cidrs", clientAddr)
+	}
 	clientIdent, err := f.clientMap.Get(clientAddr)
 	if err != nil {
 		transport.GetLogger(ctx).WithField("ipaddr", clientAddr).Error("client IP not in client map")
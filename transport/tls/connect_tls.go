@@ -13,44 +13,63 @@ import (
 )
 
 type TLSConnecter struct {
-	Address   string
-	dialer    net.Dialer
-	tlsConfig *tls.Config
+	Address     string
+	dialer      *transport.CachingDialer
+	tlsConfig   *tlsconf.ReloadingConfig
+	compression transport.Compression
 }
 
 func TLSConnecterFromConfig(in *config.TLSConnect) (*TLSConnecter, error) {
 	dialer := net.Dialer{
 		Timeout: in.DialTimeout,
 	}
-
-	if fakeCertificateLoading {
-		return &TLSConnecter{in.Address, dialer, nil}, nil
+	cachingDialer, err := transport.NewCachingDialer(dialer, in.Address, in.DNSCacheTTL)
+	if err != nil {
+		return nil, err
 	}
 
-	ca, err := tlsconf.ParseCAFile(in.Ca)
+	compression, err := transport.CompressionFromConfig(in.Compression)
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot parse ca file")
+		return nil, err
 	}
 
-	cert, err := tls.LoadX509KeyPair(in.Cert, in.Key)
-	if err != nil {
-		return nil, errors.Wrap(err, "cannot parse cert/key pair")
+	if fakeCertificateLoading {
+		return &TLSConnecter{in.Address, cachingDialer, nil, compression}, nil
 	}
 
-	tlsConfig, err := tlsconf.ClientAuthClient(in.ServerCN, ca, cert)
+	paths := append(tlsconf.KeyPairFiles(in.Cert, in.Key), in.Ca...)
+	tlsConfig, err := tlsconf.NewReloadingConfig(paths, func() (*tls.Config, error) {
+		ca, err := tlsconf.ParseCAFilesAndDirs(in.Ca)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse ca file")
+		}
+
+		cert, err := tlsconf.LoadX509KeyPair(in.Cert, in.Key)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse cert/key pair")
+		}
+
+		tlsConfig, err := tlsconf.ClientAuthClient(in.ServerCN, ca, cert)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot build tls config")
+		}
+		return tlsConfig, nil
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot build tls config")
+		return nil, err
 	}
 
-	return &TLSConnecter{in.Address, dialer, tlsConfig}, nil
+	return &TLSConnecter{in.Address, cachingDialer, tlsConfig, compression}, nil
 }
 
+func (c *TLSConnecter) RequestedCompression() transport.Compression { return c.compression }
+
 func (c *TLSConnecter) Connect(dialCtx context.Context) (transport.Wire, error) {
-	conn, err := c.dialer.DialContext(dialCtx, "tcp", c.Address)
+	conn, err := c.dialer.DialContext(dialCtx)
 	if err != nil {
 		return nil, err
 	}
 	tcpConn := conn.(*net.TCPConn)
-	tlsConn := tls.Client(conn, c.tlsConfig)
+	tlsConn := tls.Client(conn, currentTLSConfig(dialCtx, c.tlsConfig))
 	return newWireAdaptor(tlsConn, tcpConn), nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
 	"time"
 
 	"github.com/pkg/errors"
@@ -21,22 +22,39 @@ func TLSListenerFactoryFromConfig(c *config.Global, in *config.TLSServe) (transp
 	address := in.Listen
 	handshakeTimeout := in.HandshakeTimeout
 
-	if in.Ca == "" || in.Cert == "" || in.Key == "" {
+	if len(in.Ca) == 0 || in.Cert == "" || in.Key == "" {
 		return nil, errors.New("fields 'ca', 'cert' and 'key'must be specified")
 	}
 
+	compression, err := transport.CompressionFromConfig(in.Compression)
+	if err != nil {
+		return nil, err
+	}
+
 	if fakeCertificateLoading {
 		return func() (transport.AuthenticatedListener, error) { return nil, nil }, nil
 	}
 
-	clientCA, err := tlsconf.ParseCAFile(in.Ca)
+	paths := append(tlsconf.KeyPairFiles(in.Cert, in.Key), in.Ca...)
+	tlsConfig, err := tlsconf.NewReloadingConfig(paths, func() (*tls.Config, error) {
+		clientCA, err := tlsconf.ParseCAFilesAndDirs(in.Ca)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse ca file")
+		}
+
+		serverCert, err := tlsconf.LoadX509KeyPair(in.Cert, in.Key)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse cert/key pair")
+		}
+		return tlsconf.ClientAuthServer(clientCA, serverCert), nil
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot parse ca file")
+		return nil, err
 	}
 
-	serverCert, err := tls.LoadX509KeyPair(in.Cert, in.Key)
+	cidrFilter, err := transport.CIDRFilterFromConfig(in.AllowCIDRs, in.DenyCIDRs)
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot parse cert/key pair")
+		return nil, err
 	}
 
 	clientCNs := make(map[string]struct{}, len(in.ClientCNs))
@@ -53,8 +71,10 @@ func TLSListenerFactoryFromConfig(c *config.Global, in *config.TLSServe) (transp
 		if err != nil {
 			return nil, err
 		}
-		tl := tlsconf.NewClientAuthListener(l, clientCA, serverCert, handshakeTimeout)
-		return &tlsAuthListener{tl, clientCNs}, nil
+		tl := tlsconf.NewClientAuthListener(l, handshakeTimeout, func(addr *net.TCPAddr) bool {
+			return cidrFilter.Allowed(addr.IP)
+		})
+		return &tlsAuthListener{tl, tlsConfig, clientCNs, compression}, nil
 	}
 
 	return lf, nil
@@ -62,11 +82,17 @@ func TLSListenerFactoryFromConfig(c *config.Global, in *config.TLSServe) (transp
 
 type tlsAuthListener struct {
 	*tlsconf.ClientAuthListener
-	clientCNs map[string]struct{}
+	tlsConfig   *tlsconf.ReloadingConfig
+	clientCNs   map[string]struct{}
+	compression transport.Compression
 }
 
+func (l tlsAuthListener) RequestedCompression() transport.Compression { return l.compression }
+
 func (l tlsAuthListener) Accept(ctx context.Context) (*transport.AuthConn, error) {
-	tcpConn, tlsConn, cn, err := l.ClientAuthListener.Accept()
+	tcpConn, tlsConn, cn, err := l.ClientAuthListener.Accept(func() *tls.Config {
+		return currentTLSConfig(ctx, l.tlsConfig)
+	})
 	if err != nil {
 		return nil, err
 	}
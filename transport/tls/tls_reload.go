@@ -0,0 +1,20 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/zrepl/zrepl/tlsconf"
+	"github.com/zrepl/zrepl/transport"
+)
+
+// currentTLSConfig returns the tls.Config to use for a new connection and logs the outcome of reloads.
+func currentTLSConfig(ctx context.Context, c *tlsconf.ReloadingConfig) *tls.Config {
+	conf, reloaded, err := c.Get()
+	if err != nil {
+		transport.GetLogger(ctx).WithError(err).Error("cannot reload TLS certificates, continuing with the previous ones")
+	} else if reloaded {
+		transport.GetLogger(ctx).Info("reloaded TLS certificates")
+	}
+	return conf
+}
@@ -56,6 +56,31 @@ type Connecter interface {
 	Connect(ctx context.Context) (Wire, error)
 }
 
+// Compression is a transport-level compression algorithm.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionZstd Compression = "zstd"
+)
+
+func CompressionFromConfig(in string) (Compression, error) {
+	switch c := Compression(in); c {
+	case CompressionNone, CompressionZstd:
+		return c, nil
+	default:
+		return "", errors.Errorf("unknown compression %q", in)
+	}
+}
+
+// A CompressionRequester is a Connecter or AuthenticatedListener that was configured
+// to request transport-level compression for its connections.
+// Compression is negotiated during the protocol version handshake (see package rpc/versionhandshake)
+// and only enabled if both sides request the same algorithm.
+type CompressionRequester interface {
+	RequestedCompression() Compression
+}
+
 // A client identity must be a single component in a ZFS filesystem path
 func ValidateClientIdentity(in string) error {
 	err := zfs.ComponentNamecheck(in)
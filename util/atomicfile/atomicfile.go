@@ -0,0 +1,85 @@
+// Package atomicfile replaces files such that readers and a crash at any point
+// observe either the previous or the new content, never a partially written file.
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TempSuffix is part of the name of the temporary files that WriteFile creates next to path.
+// They are only left behind if the process crashes during WriteFile, see RemoveStaleTempFiles.
+const TempSuffix = ".tmp"
+
+// WriteFile atomically replaces the file at path with content.
+// Missing parent directories are created with mode 0700.
+//
+// The content is written to a temporary file in the same directory, synced,
+// and renamed to path; the directory is synced afterwards so that the rename is durable.
+func WriteFile(path string, content []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, "cannot create directory")
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+TempSuffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after successful rename
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return errors.Wrapf(err, "cannot sync directory %q", dir)
+	}
+	return nil
+}
+
+// RemoveStaleTempFiles removes the temporary files that WriteFile left behind in dir
+// when the process crashed. It must not be called concurrently with WriteFile in dir.
+// A nonexistent dir is not an error.
+func RemoveStaleTempFiles(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.Contains(e.Name(), TempSuffix) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-atomicfile")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "sub", "state.json")
+	require.NoError(t, WriteFile(p, []byte("a"), 0600))
+	require.NoError(t, WriteFile(p, []byte("b"), 0600))
+	content, err := ioutil.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, "b", string(content))
+	stat, err := os.Stat(p)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+
+	entries, err := ioutil.ReadDir(filepath.Dir(p))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files are left behind")
+}
+
+func TestRemoveStaleTempFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-atomicfile")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, RemoveStaleTempFiles(filepath.Join(dir, "nonexistent")))
+
+	// simulate a crash between creating the temporary file and the rename
+	p := filepath.Join(dir, "state.json")
+	require.NoError(t, WriteFile(p, []byte("old"), 0600))
+	require.NoError(t, ioutil.WriteFile(p+TempSuffix+"123456", []byte("partial"), 0600))
+
+	content, err := ioutil.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(content))
+
+	require.NoError(t, RemoveStaleTempFiles(dir))
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "state.json", entries[0].Name())
+}
@@ -32,6 +32,14 @@ func (s *S) Acquire(ctx context.Context) (*AcquireGuard, error) {
 	return &AcquireGuard{s, false}, nil
 }
 
+// TryAcquire returns nil if the semaphore cannot be acquired without blocking.
+func (s *S) TryAcquire() *AcquireGuard {
+	if !s.ws.TryAcquire(1) {
+		return nil
+	}
+	return &AcquireGuard{s, false}
+}
+
 func (g *AcquireGuard) Release() {
 	if g == nil || g.released {
 		return
@@ -50,3 +50,14 @@ func TestSemaphore(t *testing.T) {
 	assert.True(t, acquisitions.afterT == numGoroutines-concurrentSemaphore)
 
 }
+
+func TestSemaphoreTryAcquire(t *testing.T) {
+	sem := New(1)
+	g := sem.TryAcquire()
+	require.NotNil(t, g)
+	assert.Nil(t, sem.TryAcquire())
+	g.Release()
+	g = sem.TryAcquire()
+	assert.NotNil(t, g)
+	g.Release()
+}
@@ -0,0 +1,161 @@
+package zfs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// ZFSAllowedPermissions are the permissions delegated with `zfs allow`
+// that apply to a filesystem, including those delegated on its ancestors.
+type ZFSAllowedPermissions struct {
+	fs string
+	// in the order of `zfs allow` output, i.e., fs first, then its ancestors
+	sections []*zfsAllowSection
+}
+
+type zfsAllowSection struct {
+	fs                string
+	sets              map[string][]string
+	local, descendent []zfsAllowEntry
+}
+
+type zfsAllowEntry struct {
+	kind  string // user, group or everyone
+	name  string // empty for everyone
+	perms []string
+}
+
+// ZFSGetAllowedPermissions runs `zfs allow fs`.
+func ZFSGetAllowedPermissions(ctx context.Context, fs string) (*ZFSAllowedPermissions, error) {
+	if err := validateZFSFilesystem(fs); err != nil {
+		return nil, err
+	}
+	output, err := zfscmd.CommandContext(ctx, ZFS_BINARY, "allow", fs).CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrapf(err, "zfs allow %q failed: %q", fs, strings.TrimSpace(string(output)))
+	}
+	return parseZFSAllowOutput(fs, string(output))
+}
+
+var zfsAllowSectionHeaderRE = regexp.MustCompile(`^---- Permissions on (\S+) -*$`)
+
+func parseZFSAllowOutput(fs string, output string) (*ZFSAllowedPermissions, error) {
+	p := &ZFSAllowedPermissions{fs: fs}
+	var section *zfsAllowSection
+	var block string
+	s := bufio.NewScanner(strings.NewReader(output))
+	for s.Scan() {
+		line := s.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if m := zfsAllowSectionHeaderRE.FindStringSubmatch(line); m != nil {
+			section = &zfsAllowSection{fs: m[1], sets: make(map[string][]string)}
+			p.sections = append(p.sections, section)
+			continue
+		}
+		if section == nil {
+			return nil, fmt.Errorf("unexpected zfs allow output before first section: %q", line)
+		}
+		if !strings.HasPrefix(line, "\t") {
+			block = strings.TrimSuffix(line, ":")
+			continue
+		}
+		fields := strings.Fields(line)
+		switch block {
+		case "Permission sets":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("unexpected zfs allow permission set: %q", line)
+			}
+			section.sets[fields[0]] = strings.Split(fields[1], ",")
+		case "Local permissions", "Descendent permissions", "Local+Descendent permissions":
+			var e zfsAllowEntry
+			switch {
+			case len(fields) == 2 && fields[0] == "everyone":
+				e = zfsAllowEntry{kind: fields[0], perms: strings.Split(fields[1], ",")}
+			case len(fields) == 3 && (fields[0] == "user" || fields[0] == "group"):
+				e = zfsAllowEntry{kind: fields[0], name: fields[1], perms: strings.Split(fields[2], ",")}
+			default:
+				return nil, fmt.Errorf("unexpected zfs allow permission: %q", line)
+			}
+			if block != "Descendent permissions" {
+				section.local = append(section.local, e)
+			}
+			if block != "Local permissions" {
+				section.descendent = append(section.descendent, e)
+			}
+		default:
+			// e.g. "Create time permissions", which only apply to datasets created by the user
+		}
+	}
+	return p, s.Err()
+}
+
+// Allows returns whether perm is delegated on the filesystem to user, one of groups or everyone,
+// either directly or through a permission set.
+func (p *ZFSAllowedPermissions) Allows(user string, groups []string, perm string) bool {
+	for _, s := range p.sections {
+		entries := s.descendent
+		if s.fs == p.fs {
+			entries = s.local
+		}
+		for _, e := range entries {
+			if !e.appliesTo(user, groups) {
+				continue
+			}
+			for _, ep := range e.perms {
+				if p.permIncludes(ep, perm, make(map[string]bool)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (e zfsAllowEntry) appliesTo(user string, groups []string) bool {
+	switch e.kind {
+	case "everyone":
+		return true
+	case "user":
+		return e.name == user
+	case "group":
+		for _, g := range groups {
+			if e.name == g {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// permIncludes resolves permission sets (@name) using the definition closest to the filesystem.
+func (p *ZFSAllowedPermissions) permIncludes(have, want string, visited map[string]bool) bool {
+	if have == want {
+		return true
+	}
+	if !strings.HasPrefix(have, "@") || visited[have] {
+		return false
+	}
+	visited[have] = true
+	for _, s := range p.sections {
+		set, ok := s.sets[have]
+		if !ok {
+			continue
+		}
+		for _, sp := range set {
+			if p.permIncludes(sp, want, visited) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package zfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseZFSAllowOutput(t *testing.T) {
+	output := `---- Permissions on pool/data/home ----------------------------------
+Permission sets:
+	@backup hold,@snap
+Create time permissions:
+	destroy,mount
+Local permissions:
+	user alice snapshot
+Descendent permissions:
+	user carol snapshot
+---- Permissions on pool/data ----------------------------------------
+Permission sets:
+	@snap snapshot
+Local permissions:
+	user dave snapshot
+Local+Descendent permissions:
+	user bob @backup
+	group staff send
+	everyone userprop
+`
+	p, err := parseZFSAllowOutput("pool/data/home", output)
+	require.NoError(t, err)
+
+	assert.True(t, p.Allows("alice", nil, "snapshot"))
+	// descendent permissions of the filesystem itself don't apply to it
+	assert.False(t, p.Allows("carol", nil, "snapshot"))
+	// local permissions of an ancestor don't apply to its descendents
+	assert.False(t, p.Allows("dave", nil, "snapshot"))
+	// nested permission sets
+	assert.True(t, p.Allows("bob", nil, "snapshot"))
+	assert.True(t, p.Allows("bob", nil, "hold"))
+	assert.False(t, p.Allows("bob", nil, "destroy"))
+	assert.True(t, p.Allows("eve", []string{"wheel", "staff"}, "send"))
+	assert.False(t, p.Allows("eve", []string{"wheel"}, "send"))
+	assert.True(t, p.Allows("eve", nil, "userprop"))
+
+	p, err = parseZFSAllowOutput("pool/other", "")
+	require.NoError(t, err)
+	assert.False(t, p.Allows("alice", nil, "snapshot"))
+
+	_, err = parseZFSAllowOutput("pool", "Local permissions:\n\tuser alice snapshot\n")
+	assert.Error(t, err)
+}
@@ -70,3 +70,72 @@ func ZFSGetEncryptionEnabled(ctx context.Context, fs string) (enabled bool, err
 		return true, nil
 	}
 }
+
+// ZFSGetEncryptionRoot returns the value of the `encryptionroot` property of fs.
+// Returns "", nil if fs is not encrypted or encryption is not supported.
+func ZFSGetEncryptionRoot(ctx context.Context, fs string) (root string, err error) {
+	defer func(e *error) {
+		if *e != nil {
+			*e = fmt.Errorf("zfs get encryptionroot fs=%q: %s", fs, *e)
+		}
+	}(&err)
+	if supp, err := EncryptionCLISupported(ctx); err != nil {
+		return "", err
+	} else if !supp {
+		return "", nil
+	}
+
+	if err := validateZFSFilesystem(fs); err != nil {
+		return "", err
+	}
+
+	props, err := zfsGet(ctx, fs, []string{"encryptionroot"}, SourceAny)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot get `encryptionroot` property")
+	}
+	val := props.Get("encryptionroot")
+	if val == "-" {
+		return "", nil
+	}
+	return val, nil
+}
+
+// UnencryptedBelowEncryptedParentError is returned by ZFSCheckEncryptionInherited
+// if a filesystem is not encrypted although its parent is.
+type UnencryptedBelowEncryptedParentError struct {
+	FS, Parent string
+}
+
+func (e *UnencryptedBelowEncryptedParentError) Error() string {
+	return fmt.Sprintf("filesystem %q is not encrypted although its parent %q is encrypted", e.FS, e.Parent)
+}
+
+func checkEncryptionInherited(fs, parent string, fsEncrypted, parentEncrypted bool) error {
+	if parentEncrypted && !fsEncrypted {
+		return &UnencryptedBelowEncryptedParentError{FS: fs, Parent: parent}
+	}
+	return nil
+}
+
+// ZFSCheckEncryptionInherited returns an *UnencryptedBelowEncryptedParentError
+// if fs is not encrypted but its parent is.
+// Filesystems that are encrypted with their own encryptionroot pass the check.
+// Returns nil if fs is a pool's root filesystem or encryption is not supported.
+func ZFSCheckEncryptionInherited(ctx context.Context, fs *DatasetPath) error {
+	if fs.Length() <= 1 {
+		return nil
+	}
+	parent := &DatasetPath{comps: fs.comps[:len(fs.comps)-1]}
+	parentEncrypted, err := ZFSGetEncryptionEnabled(ctx, parent.ToString())
+	if err != nil {
+		return err
+	}
+	if !parentEncrypted {
+		return nil
+	}
+	fsEncrypted, err := ZFSGetEncryptionEnabled(ctx, fs.ToString())
+	if err != nil {
+		return err
+	}
+	return checkEncryptionInherited(fs.ToString(), parent.ToString(), fsEncrypted, parentEncrypted)
+}
@@ -0,0 +1,42 @@
+package zfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckEncryptionInherited(t *testing.T) {
+	type tc struct {
+		fsEncrypted, parentEncrypted bool
+		expectErr                    bool
+	}
+	tcs := []tc{
+		{fsEncrypted: false, parentEncrypted: false, expectErr: false},
+		// e.g. received raw with its own encryptionroot below an unencrypted parent
+		{fsEncrypted: true, parentEncrypted: false, expectErr: false},
+		{fsEncrypted: true, parentEncrypted: true, expectErr: false},
+		// e.g. placeholder created with encryption=off or received with -o encryption=off
+		{fsEncrypted: false, parentEncrypted: true, expectErr: true},
+	}
+	for _, c := range tcs {
+		err := checkEncryptionInherited("pool/enc/child", "pool/enc", c.fsEncrypted, c.parentEncrypted)
+		if !c.expectErr {
+			assert.NoError(t, err, "%#v", c)
+			continue
+		}
+		assert.IsType(t, &UnencryptedBelowEncryptedParentError{}, err, "%#v", c)
+		assert.Contains(t, err.Error(), `"pool/enc/child"`)
+	}
+}
+
+func TestPlaceholderCreationEncryptionPropertyFromString(t *testing.T) {
+	v, err := PlaceholderCreationEncryptionPropertyFromString("off")
+	assert.NoError(t, err)
+	assert.Equal(t, PlaceholderCreationEncryptionPropertyOff, v)
+	v, err = PlaceholderCreationEncryptionPropertyFromString("inherit")
+	assert.NoError(t, err)
+	assert.Equal(t, PlaceholderCreationEncryptionPropertyInherit, v)
+	_, err = PlaceholderCreationEncryptionPropertyFromString("on")
+	assert.Error(t, err)
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -55,6 +56,48 @@ success:
 }
 
 func ZFSHolds(ctx context.Context, fs, snap string) ([]string, error) {
+	holds, err := zfsHolds(ctx, fs, snap)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, len(holds))
+	for i, h := range holds {
+		tags[i] = h[1]
+	}
+	return tags, nil
+}
+
+type ZFSHoldInfo struct {
+	Tag     string
+	Created time.Time
+}
+
+// ZFSHoldsWithTimestamps is ZFSHolds with the time at which each hold was placed.
+func ZFSHoldsWithTimestamps(ctx context.Context, fs, snap string) ([]ZFSHoldInfo, error) {
+	holds, err := zfsHolds(ctx, fs, snap)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ZFSHoldInfo, len(holds))
+	for i, h := range holds {
+		created, err := parseZFSHoldTimestamp(h[2])
+		if err != nil {
+			return nil, errors.Wrapf(err, "zfs holds: hold %q on %s", h[1], h[0])
+		}
+		out[i] = ZFSHoldInfo{Tag: h[1], Created: created}
+	}
+	return out, nil
+}
+
+// `zfs holds` prints the timestamp with strftime format "%a %b %e %H:%M %Y" in local time
+const zfsHoldTimestampLayout = "Mon Jan _2 15:04 2006"
+
+func parseZFSHoldTimestamp(s string) (time.Time, error) {
+	return time.ParseInLocation(zfsHoldTimestampLayout, strings.TrimSpace(s), time.Local)
+}
+
+// zfsHolds returns the name, tag and timestamp column of each line of `zfs holds -H`.
+func zfsHolds(ctx context.Context, fs, snap string) ([][3]string, error) {
 	if err := validateZFSFilesystem(fs); err != nil {
 		return nil, errors.Wrap(err, "`fs` is not a valid filesystem path")
 	}
@@ -67,7 +110,7 @@ func ZFSHolds(ctx context.Context, fs, snap string) ([]string, error) {
 		return nil, &ZFSError{output, errors.Wrap(err, "zfs holds failed")}
 	}
 	scan := bufio.NewScanner(bytes.NewReader(output))
-	var tags []string
+	var holds [][3]string
 	for scan.Scan() {
 		// NAME              TAG  TIMESTAMP
 		comps := strings.SplitN(scan.Text(), "\t", 3)
@@ -77,9 +120,9 @@ func ZFSHolds(ctx context.Context, fs, snap string) ([]string, error) {
 		if comps[0] != dp {
 			return nil, fmt.Errorf("zfs holds: unexpected output: expecting %q as first component, got %q\n%s", dp, comps[0], output)
 		}
-		tags = append(tags, comps[1])
+		holds = append(holds, [3]string{comps[0], comps[1], comps[2]})
 	}
-	return tags, nil
+	return holds, nil
 }
 
 // Idempotent: if the hold doesn't exist, this is not an error
@@ -0,0 +1,22 @@
+package zfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseZFSHoldTimestamp(t *testing.T) {
+	ts, err := parseZFSHoldTimestamp("Thu Jan  2 10:04 2020")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 1, 2, 10, 4, 0, 0, time.Local), ts)
+
+	ts, err = parseZFSHoldTimestamp("Sat Dec 12 23:59 2020\n")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 12, 12, 23, 59, 0, 0, time.Local), ts)
+
+	_, err = parseZFSHoldTimestamp("1577959440")
+	assert.Error(t, err)
+}
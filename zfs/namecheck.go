@@ -191,3 +191,32 @@ func isASCII(s string) bool {
 	}
 	return true
 }
+
+// SnapshotNameCheck checks whether name can be used as the snapshot name part
+// (i.e., the part after '@') of snapshots that zrepl creates.
+// It is stricter than ZFS in that it rejects spaces, which ZFS accepts
+// but which are a common source of trouble in hooks and scripts.
+//
+// The total length of the snapshot name including the filesystem is checked by
+// EntityNamecheck.
+func SnapshotNameCheck(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("snapshot name must not be empty")
+	}
+	for _, r := range name {
+		switch {
+		case r == '@' || r == '#' || r == '/':
+			return fmt.Errorf("snapshot name %q must not contain %q", name, r)
+		case r == ' ':
+			return fmt.Errorf("snapshot name %q must not contain spaces", name)
+		case r == '%':
+			return fmt.Errorf("snapshot name %q must not contain %q (reserved for ZFS-internal use)", name, r)
+		case unicode.IsControl(r):
+			return fmt.Errorf("snapshot name %q must not contain control characters", name)
+		}
+	}
+	if err := ComponentNamecheck(name); err != nil {
+		return fmt.Errorf("snapshot name %q: %s", name, err)
+	}
+	return nil
+}
@@ -62,3 +62,38 @@ func TestEntityNamecheck(t *testing.T) {
 	}
 
 }
+
+func TestSnapshotNameCheck(t *testing.T) {
+
+	type testcase struct {
+		input string
+		ok    bool
+	}
+
+	tcs := []testcase{
+		{"zrepl_20200101_120000_000", true},
+		{"auto-2020.01.01:12", true},
+		{"", false},
+		{"foo@bar", false},
+		{"foo#bar", false},
+		{"foo/bar", false},
+		{"foo bar", false},
+		{"foo\tbar", false},
+		{"foo\x7fbar", false},
+		{"for%idden", false},
+		{"bår", false},
+		{".", false},
+		{"..", false},
+		{strings.Repeat("a", MaxDatasetNameLen+1), false},
+	}
+
+	for idx := range tcs {
+		t.Run(tcs[idx].input, func(t *testing.T) {
+			tc := tcs[idx]
+			err := SnapshotNameCheck(tc.input)
+			if !((err == nil && tc.ok) || (err != nil && !tc.ok)) {
+				t.Errorf("expecting ok=%v but got err=%v", tc.ok, err)
+			}
+		})
+	}
+}
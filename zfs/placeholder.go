@@ -5,6 +5,7 @@ import (
 	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -41,12 +42,18 @@ func computeLegacyHashBasedPlaceholderPropertyValue(p *DatasetPath) string {
 }
 
 // the caller asserts that placeholderPropertyValue is sourceLocal
+//
+// zrepl only ever writes placeholderPropertyOn or the legacy hash value,
+// but the property might have been set by other tools or by hand using
+// a different boolean representation, e.g. `yes` or `true`.
 func isLocalPlaceholderPropertyValuePlaceholder(p *DatasetPath, placeholderPropertyValue string) (isPlaceholder bool) {
 	legacy := computeLegacyHashBasedPlaceholderPropertyValue(p)
-	switch placeholderPropertyValue {
-	case legacy:
+	v := strings.TrimSpace(placeholderPropertyValue)
+	if v == legacy {
 		return true
-	case placeholderPropertyOn:
+	}
+	switch strings.ToLower(v) {
+	case placeholderPropertyOn, "yes", "true", "1":
 		return true
 	default:
 		return false
@@ -80,7 +87,29 @@ func ZFSGetFilesystemPlaceholderState(ctx context.Context, p *DatasetPath) (stat
 	return state, nil
 }
 
-func ZFSCreatePlaceholderFilesystem(ctx context.Context, fs *DatasetPath, parent *DatasetPath) (err error) {
+// PlaceholderCreationEncryptionProperty determines the `encryption` property
+// of placeholder filesystems created below an encrypted parent.
+type PlaceholderCreationEncryptionProperty int
+
+const (
+	// Create placeholders with encryption=off.
+	PlaceholderCreationEncryptionPropertyOff PlaceholderCreationEncryptionProperty = iota
+	// Create placeholders without specifying encryption, i.e., they inherit the parent's encryptionroot.
+	PlaceholderCreationEncryptionPropertyInherit
+)
+
+func PlaceholderCreationEncryptionPropertyFromString(s string) (PlaceholderCreationEncryptionProperty, error) {
+	switch s {
+	case "off":
+		return PlaceholderCreationEncryptionPropertyOff, nil
+	case "inherit":
+		return PlaceholderCreationEncryptionPropertyInherit, nil
+	default:
+		return 0, fmt.Errorf("invalid placeholder encryption %q, must be one of 'off', 'inherit'", s)
+	}
+}
+
+func ZFSCreatePlaceholderFilesystem(ctx context.Context, fs *DatasetPath, parent *DatasetPath, encryption PlaceholderCreationEncryptionProperty) (err error) {
 	if fs.Length() == 1 {
 		return fmt.Errorf("cannot create %q: pools cannot be created with zfs create", fs.ToString())
 	}
@@ -90,9 +119,11 @@ func ZFSCreatePlaceholderFilesystem(ctx context.Context, fs *DatasetPath, parent
 		"-o", fmt.Sprintf("%s=%s", PlaceholderPropertyName, placeholderPropertyOn),
 		"-o", "mountpoint=none",
 	}
-	if parentEncrypted, err := ZFSGetEncryptionEnabled(ctx, parent.ToString()); err != nil {
+	parentEncrypted, err := ZFSGetEncryptionEnabled(ctx, parent.ToString())
+	if err != nil {
 		return errors.Wrap(err, "cannot determine encryption support")
-	} else if parentEncrypted {
+	}
+	if parentEncrypted && encryption == PlaceholderCreationEncryptionPropertyOff {
 		cmdline = append(cmdline, "-o", "encryption=off")
 	}
 	cmdline = append(cmdline, fs.ToString())
@@ -100,13 +131,16 @@ func ZFSCreatePlaceholderFilesystem(ctx context.Context, fs *DatasetPath, parent
 
 	stdio, err := cmd.CombinedOutput()
 	if err != nil {
-		err = &ZFSError{
+		return &ZFSError{
 			Stderr:  stdio,
 			WaitErr: err,
 		}
 	}
 
-	return
+	if parentEncrypted && encryption == PlaceholderCreationEncryptionPropertyInherit {
+		return ZFSCheckEncryptionInherited(ctx, fs)
+	}
+	return nil
 }
 
 func ZFSSetPlaceholder(ctx context.Context, p *DatasetPath, isPlaceholder bool) error {
@@ -0,0 +1,35 @@
+package zfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsLocalPlaceholderPropertyValuePlaceholder(t *testing.T) {
+	p, err := NewDatasetPath("pool/foo/bar")
+	require.NoError(t, err)
+	other, err := NewDatasetPath("pool/renamed/bar")
+	require.NoError(t, err)
+
+	tcs := map[string]bool{
+		"on":    true,
+		"ON":    true,
+		" on\n": true,
+		"yes":   true,
+		"true":  true,
+		"1":     true,
+		"off":   false,
+		"no":    false,
+		"false": false,
+		"0":     false,
+		"":      false, // not set locally, e.g. inherited
+		"-":     false,
+		computeLegacyHashBasedPlaceholderPropertyValue(p):     true,
+		computeLegacyHashBasedPlaceholderPropertyValue(other): false, // legacy value of a renamed dataset
+	}
+	for value, expect := range tcs {
+		assert.Equal(t, expect, isLocalPlaceholderPropertyValuePlaceholder(p, value), "%q", value)
+	}
+}
@@ -0,0 +1,49 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// ZPoolGetFeatures returns which of the given pool features (e.g. `large_blocks`, without the `feature@` prefix)
+// are enabled or active on pool.
+// Features that the pool's ZFS version does not know are reported as unsupported.
+func ZPoolGetFeatures(ctx context.Context, pool string, features []string) (map[string]bool, error) {
+	props := make([]string, len(features))
+	for i, f := range features {
+		props[i] = "feature@" + f
+	}
+	output, err := zfscmd.CommandContext(ctx, "zpool", "get", "-H", "-p", "-o", "property,value", strings.Join(props, ","), pool).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get features of pool %q", pool)
+	}
+	return parseZPoolGetFeatures(string(output), features)
+}
+
+func parseZPoolGetFeatures(output string, features []string) (map[string]bool, error) {
+	supported := make(map[string]bool, len(features))
+	for _, f := range features {
+		supported[f] = false
+	}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("unexpected zpool get output line %q", line)
+		}
+		f := strings.TrimPrefix(fields[0], "feature@")
+		if _, ok := supported[f]; !ok {
+			continue
+		}
+		// unknown features are reported with value `-`
+		supported[f] = fields[1] == "enabled" || fields[1] == "active"
+	}
+	return supported, nil
+}
@@ -0,0 +1,27 @@
+package zfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseZPoolGetFeatures(t *testing.T) {
+	out := "feature@large_blocks\tactive\nfeature@embedded_data\tdisabled\nfeature@zstd_compress\t-\n"
+	f, err := parseZPoolGetFeatures(out, []string{"large_blocks", "embedded_data", "zstd_compress", "encryption"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{
+		"large_blocks":  true,
+		"embedded_data": false,
+		"zstd_compress": false,
+		"encryption":    false,
+	}, f)
+
+	f, err = parseZPoolGetFeatures("feature@large_blocks\tenabled\n", []string{"large_blocks"})
+	require.NoError(t, err)
+	assert.True(t, f["large_blocks"])
+
+	_, err = parseZPoolGetFeatures("garbage\n", []string{"large_blocks"})
+	assert.Error(t, err)
+}
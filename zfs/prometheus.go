@@ -7,6 +7,7 @@ var prom struct {
 	ZFSSnapshotDuration              *prometheus.HistogramVec
 	ZFSBookmarkDuration              *prometheus.HistogramVec
 	ZFSDestroyDuration               *prometheus.HistogramVec
+	PoolFreeBytes                    *prometheus.GaugeVec
 }
 
 func init() {
@@ -34,6 +35,11 @@ func init() {
 		Name:      "destroy_duration",
 		Help:      "Duration it took to destroy a dataset",
 	}, []string{"dataset_type", "filesystem"})
+	prom.PoolFreeBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "zrepl",
+		Name:      "pool_free_bytes",
+		Help:      "Available bytes in a pool, updated when a job checks its min_free_space",
+	}, []string{"pool"})
 }
 
 func PrometheusRegister(registry prometheus.Registerer) error {
@@ -49,5 +55,8 @@ func PrometheusRegister(registry prometheus.Registerer) error {
 	if err := registry.Register(prom.ZFSDestroyDuration); err != nil {
 		return err
 	}
+	if err := registry.Register(prom.PoolFreeBytes); err != nil {
+		return err
+	}
 	return nil
 }
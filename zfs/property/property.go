@@ -3,6 +3,7 @@ package property
 import (
 	"fmt"
 	"regexp"
+	"strings"
 )
 
 type Property string
@@ -31,3 +32,20 @@ func (p Property) Validate() error {
 	}
 	return nil
 }
+
+// ValidateUserProperty checks that p is a valid name for a user property,
+// i.e., of the form module:property, see zfsprops(8), section "User Properties".
+// Names in the zrepl: module are reserved for zrepl's own use.
+func (p Property) ValidateUserProperty() error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	module := strings.SplitN(string(p), ":", 2)
+	if len(module) != 2 || module[0] == "" || module[1] == "" {
+		return fmt.Errorf("user property name %q must be of the form module:property", p)
+	}
+	if module[0] == "zrepl" {
+		return fmt.Errorf("user property name %q: module %q is reserved for zrepl", p, module[0])
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package property
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// valueValidators checks the values of well-known native properties, see zfsprops(7).
+// Properties that are not listed, including all user properties, accept any value.
+// The checks only reject values that are certainly invalid: when in doubt, e.g. for
+// values introduced in newer OpenZFS versions, leave the final word to zfs.
+var valueValidators = map[Property]func(v string) error{
+	"aclinherit":         oneOf("discard", "noallow", "restricted", "passthrough", "passthrough-x"),
+	"acltype":            oneOf("off", "noacl", "nfsv4", "posix", "posixacl"),
+	"atime":              onOff,
+	"canmount":           oneOf("on", "off", "noauto"),
+	"checksum":           oneOf("on", "off", "fletcher2", "fletcher4", "sha256", "noparity", "sha512", "skein", "edonr", "blake3"),
+	"compression":        matches(`^(on|off|lzjb|zle|lz4|gzip|gzip-[1-9]|zstd|zstd-([1-9]|1[0-9])|zstd-fast|zstd-fast-[0-9]+)$`, "on, off, lzjb, zle, lz4, gzip, gzip-N, zstd, zstd-N, zstd-fast, zstd-fast-N"),
+	"copies":             oneOf("1", "2", "3"),
+	"devices":            onOff,
+	"dnodesize":          oneOf("legacy", "auto", "1k", "2k", "4k", "8k", "16k"),
+	"exec":               onOff,
+	"logbias":            oneOf("latency", "throughput"),
+	"mountpoint":         mountpoint,
+	"primarycache":       oneOf("all", "none", "metadata"),
+	"readonly":           onOff,
+	"recordsize":         recordsize,
+	"redundant_metadata": oneOf("all", "most", "some", "none"),
+	"relatime":           onOff,
+	"secondarycache":     oneOf("all", "none", "metadata"),
+	"setuid":             onOff,
+	"snapdir":            oneOf("hidden", "visible"),
+	"sync":               oneOf("standard", "always", "disabled"),
+	"volmode":            oneOf("default", "full", "geom", "dev", "none"),
+	"xattr":              oneOf("on", "off", "sa", "dir"),
+}
+
+// ValidateValue checks v against the constraints of p if p is a well-known native property.
+func (p Property) ValidateValue(v string) error {
+	validate, ok := valueValidators[p]
+	if !ok {
+		return nil
+	}
+	if err := validate(v); err != nil {
+		return fmt.Errorf("invalid value %q for property %q: %s", v, p, err)
+	}
+	return nil
+}
+
+func oneOf(values ...string) func(string) error {
+	return func(v string) error {
+		for _, a := range values {
+			if v == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+	}
+}
+
+var onOff = oneOf("on", "off")
+
+func matches(re string, desc string) func(string) error {
+	r := regexp.MustCompile(re)
+	return func(v string) error {
+		if !r.MatchString(v) {
+			return fmt.Errorf("must be one of %s", desc)
+		}
+		return nil
+	}
+}
+
+func mountpoint(v string) error {
+	if v == "none" || v == "legacy" || strings.HasPrefix(v, "/") {
+		return nil
+	}
+	return fmt.Errorf("must be an absolute path, none or legacy")
+}
+
+// recordsizeRE matches the size syntax of zfs(8), e.g. 131072, 128k, 128KB, 1MiB or 1m, after strings.ToUpper.
+var recordsizeRE = regexp.MustCompile(`^([0-9]+)(?:([KMGTPE])(?:I?B)?|B)?$`)
+
+var recordsizeUnitShift = map[string]uint{"": 0, "K": 10, "M": 20, "G": 30, "T": 40, "P": 50, "E": 60}
+
+func recordsize(v string) error {
+	const max = 16 << 20
+	invalid := fmt.Errorf("must be a power of two between 512 and 16M")
+	m := recordsizeRE.FindStringSubmatch(strings.ToUpper(v))
+	if m == nil {
+		return invalid
+	}
+	n, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return invalid
+	}
+	shift := recordsizeUnitShift[m[2]]
+	if n > max>>shift {
+		return invalid
+	}
+	n <<= shift
+	if n < 512 || n&(n-1) != 0 {
+		return invalid
+	}
+	return nil
+}
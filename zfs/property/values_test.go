@@ -0,0 +1,51 @@
+package property
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateValue(t *testing.T) {
+	valid := map[Property][]string{
+		"compression": {"on", "off", "lz4", "gzip", "gzip-9", "zstd", "zstd-19", "zstd-fast", "zstd-fast-1000", "zle", "lzjb"},
+		"atime":       {"on", "off"},
+		"canmount":    {"on", "off", "noauto"},
+		"readonly":    {"on", "off"},
+		"mountpoint":  {"none", "legacy", "/mnt/backup"},
+		"recordsize":  {"512", "512B", "512b", "128K", "128k", "128KB", "128kb", "128KiB", "1M", "1m", "1MB", "1MiB", "1mib", "16M", "131072"},
+		"copies":      {"1", "3"},
+		"xattr":       {"sa"},
+		// not well-known: permissive
+		"com.example:foo":   {"", "anything goes"},
+		"org.openzfs:x":     {"nope"},
+		"quota":             {"10G"},
+		"sharenfs":          {"rw=@10.0.0.0/8"},
+		"keylocation":       {"prompt"},
+		"special_small_blk": {"0"},
+	}
+	for p, vs := range valid {
+		for _, v := range vs {
+			assert.NoError(t, p.ValidateValue(v), "%s=%s", p, v)
+		}
+	}
+
+	invalid := map[Property][]string{
+		"compression": {"nope", "gzip-10", "zstd-20", "LZ4", ""},
+		"atime":       {"yes", "true", "1"},
+		"canmount":    {"auto"},
+		"readonly":    {"ON"},
+		"mountpoint":  {"relative/path", ""},
+		"recordsize":  {"100K", "256", "32M", "1G", "0G", "16E", "99999999999999999999", "abc", "", "128iB", "128KBB", "128 K", "1.5M"},
+		"copies":      {"0", "4"},
+		"sync":        {"sometimes"},
+	}
+	for p, vs := range invalid {
+		for _, v := range vs {
+			err := p.ValidateValue(v)
+			if assert.Error(t, err, "%s=%s", p, v) {
+				assert.Contains(t, err.Error(), string(p))
+			}
+		}
+	}
+}
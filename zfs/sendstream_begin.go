@@ -0,0 +1,76 @@
+package zfs
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// from OpenZFS include/sys/zfs_ioctl.h
+const (
+	sendStreamDRRBegin           = 0
+	sendStreamBackupMagic uint64 = 0x2F5bacbac
+	dmuObjsetTypeZVOL            = 3 // DMU_OST_ZVOL
+)
+
+// SendStreamBeginRecord is the subset of the DRR_BEGIN record
+// at the start of every (non-compound) zfs send stream that zrepl cares about.
+type SendStreamBeginRecord struct {
+	ToGUID   uint64
+	FromGUID uint64 // 0 for full streams
+	ToName   string
+	IsVolume bool // the stream contains a ZVOL
+}
+
+func (r *SendStreamBeginRecord) IsIncremental() bool { return r.FromGUID != 0 }
+
+// Layout of struct dmu_replay_record with drr_begin payload:
+//
+//	0  uint32 drr_type
+//	4  uint32 drr_payloadlen
+//	8  uint64 drr_magic
+//	16 uint64 drr_versioninfo
+//	24 uint64 drr_creation_time
+//	32 uint32 drr_type (dmu_objset_type)
+//	36 uint32 drr_flags
+//	40 uint64 drr_toguid
+//	48 uint64 drr_fromguid
+//	56 char   drr_toname[MAXNAMELEN]
+const (
+	sendStreamBeginToNameOffset = 56
+	sendStreamBeginToNameLen    = 256
+	sendStreamBeginMinLen       = sendStreamBeginToNameOffset + sendStreamBeginToNameLen
+)
+
+// ParseSendStreamBeginRecord decodes the DRR_BEGIN record from the first bytes of a send stream.
+// The byte order is detected using the stream's magic number.
+func ParseSendStreamBeginRecord(stream []byte) (*SendStreamBeginRecord, error) {
+	if len(stream) < sendStreamBeginMinLen {
+		return nil, fmt.Errorf("send stream too short for begin record: %d bytes", len(stream))
+	}
+	var bo binary.ByteOrder
+	switch sendStreamBackupMagic {
+	case binary.LittleEndian.Uint64(stream[8:16]):
+		bo = binary.LittleEndian
+	case binary.BigEndian.Uint64(stream[8:16]):
+		bo = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("send stream does not start with a begin record: invalid magic")
+	}
+	if t := bo.Uint32(stream[0:4]); t != sendStreamDRRBegin {
+		return nil, fmt.Errorf("send stream does not start with a begin record: record type %d", t)
+	}
+
+	name := stream[sendStreamBeginToNameOffset : sendStreamBeginToNameOffset+sendStreamBeginToNameLen]
+	for i, c := range name {
+		if c == 0 {
+			name = name[:i]
+			break
+		}
+	}
+	return &SendStreamBeginRecord{
+		ToGUID:   bo.Uint64(stream[40:48]),
+		FromGUID: bo.Uint64(stream[48:56]),
+		ToName:   string(name),
+		IsVolume: bo.Uint32(stream[32:36]) == dmuObjsetTypeZVOL,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package zfs
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSendStreamBeginRecord(t *testing.T) {
+
+	mkBegin := func(bo binary.ByteOrder, toguid, fromguid uint64, toname string) []byte {
+		b := make([]byte, sendStreamBeginMinLen+64)
+		bo.PutUint32(b[0:4], sendStreamDRRBegin)
+		bo.PutUint64(b[8:16], sendStreamBackupMagic)
+		bo.PutUint32(b[32:36], 2) // DMU_OST_ZFS
+		bo.PutUint64(b[40:48], toguid)
+		bo.PutUint64(b[48:56], fromguid)
+		copy(b[sendStreamBeginToNameOffset:], toname)
+		return b
+	}
+
+	for _, bo := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		t.Run(bo.String(), func(t *testing.T) {
+			r, err := ParseSendStreamBeginRecord(mkBegin(bo, 0x1234, 0, "pool/fs@a"))
+			require.NoError(t, err)
+			assert.Equal(t, uint64(0x1234), r.ToGUID)
+			assert.False(t, r.IsIncremental())
+			assert.Equal(t, "pool/fs@a", r.ToName)
+			assert.False(t, r.IsVolume)
+
+			r, err = ParseSendStreamBeginRecord(mkBegin(bo, 0x1234, 0x5678, "pool/fs@b"))
+			require.NoError(t, err)
+			assert.True(t, r.IsIncremental())
+			assert.Equal(t, uint64(0x5678), r.FromGUID)
+			assert.Equal(t, "pool/fs@b", r.ToName)
+		})
+	}
+
+	t.Run("volume", func(t *testing.T) {
+		b := mkBegin(binary.LittleEndian, 1, 0, "pool/vol@a")
+		binary.LittleEndian.PutUint32(b[32:36], dmuObjsetTypeZVOL)
+		r, err := ParseSendStreamBeginRecord(b)
+		require.NoError(t, err)
+		assert.True(t, r.IsVolume)
+	})
+
+	t.Run("too_short", func(t *testing.T) {
+		_, err := ParseSendStreamBeginRecord(mkBegin(binary.LittleEndian, 1, 2, "p@a")[:100])
+		assert.Error(t, err)
+	})
+
+	t.Run("bad_magic", func(t *testing.T) {
+		b := mkBegin(binary.LittleEndian, 1, 2, "p@a")
+		b[8] = 0
+		_, err := ParseSendStreamBeginRecord(b)
+		assert.Error(t, err)
+	})
+
+	t.Run("not_begin_record", func(t *testing.T) {
+		b := mkBegin(binary.LittleEndian, 1, 2, "p@a")
+		binary.LittleEndian.PutUint32(b[0:4], 1)
+		_, err := ParseSendStreamBeginRecord(b)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,89 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MinFreeSpace is a lower bound for the free space of a pool.
+// Only one of Bytes and Percent is non-zero.
+type MinFreeSpace struct {
+	Bytes   uint64
+	Percent float64 // of the pool's usable size, in (0, 100)
+}
+
+func (m MinFreeSpace) String() string {
+	if m.Percent != 0 {
+		return fmt.Sprintf("%v%%", m.Percent)
+	}
+	return fmt.Sprintf("%d bytes", m.Bytes)
+}
+
+// PoolSpace is the space accounting of a pool's root filesystem, as reported by zfs get.
+type PoolSpace struct {
+	Pool      string
+	Available uint64
+	Used      uint64
+}
+
+func (s PoolSpace) Size() uint64 { return s.Available + s.Used }
+
+func (s PoolSpace) Satisfies(m MinFreeSpace) bool {
+	if m.Percent != 0 {
+		size := s.Size()
+		if size == 0 {
+			return false
+		}
+		return float64(s.Available)/float64(size)*100 >= m.Percent
+	}
+	return s.Available >= m.Bytes
+}
+
+type InsufficientFreeSpaceError struct {
+	Space PoolSpace
+	Min   MinFreeSpace
+}
+
+func (e *InsufficientFreeSpaceError) Error() string {
+	return fmt.Sprintf("pool %q has insufficient free space: %d of %d bytes available, min_free_space is %s",
+		e.Space.Pool, e.Space.Available, e.Space.Size(), e.Min)
+}
+
+// ZFSGetPoolSpace returns the space accounting of the pool that contains dataset ds.
+// It also updates the zrepl_pool_free_bytes metric.
+func ZFSGetPoolSpace(ctx context.Context, ds string) (PoolSpace, error) {
+	pool := strings.SplitN(ds, "/", 2)[0]
+	props, err := zfsGet(ctx, pool, []string{"available", "used"}, SourceAny)
+	if err != nil {
+		return PoolSpace{}, errors.Wrapf(err, "cannot get free space of pool %q", pool)
+	}
+	s := PoolSpace{Pool: pool}
+	if s.Available, err = strconv.ParseUint(props.Get("available"), 10, 64); err != nil {
+		return PoolSpace{}, errors.Wrap(err, "cannot parse `available` property")
+	}
+	if s.Used, err = strconv.ParseUint(props.Get("used"), 10, 64); err != nil {
+		return PoolSpace{}, errors.Wrap(err, "cannot parse `used` property")
+	}
+	prom.PoolFreeBytes.WithLabelValues(pool).Set(float64(s.Available))
+	return s, nil
+}
+
+// CheckPoolFreeSpace returns an *InsufficientFreeSpaceError if the pool that contains
+// dataset ds does not satisfy min. It is a no-op if min is nil.
+func CheckPoolFreeSpace(ctx context.Context, ds string, min *MinFreeSpace) error {
+	if min == nil {
+		return nil
+	}
+	s, err := ZFSGetPoolSpace(ctx, ds)
+	if err != nil {
+		return err
+	}
+	if !s.Satisfies(*min) {
+		return &InsufficientFreeSpaceError{Space: s, Min: *min}
+	}
+	return nil
+}
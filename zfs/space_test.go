@@ -0,0 +1,22 @@
+package zfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolSpaceSatisfies(t *testing.T) {
+	s := PoolSpace{Pool: "pool", Available: 10 << 30, Used: 90 << 30}
+
+	assert.True(t, s.Satisfies(MinFreeSpace{Bytes: 10 << 30}))
+	assert.False(t, s.Satisfies(MinFreeSpace{Bytes: 10<<30 + 1}))
+	assert.True(t, s.Satisfies(MinFreeSpace{Percent: 10}))
+	assert.False(t, s.Satisfies(MinFreeSpace{Percent: 10.5}))
+
+	assert.False(t, PoolSpace{}.Satisfies(MinFreeSpace{Percent: 1}))
+
+	err := &InsufficientFreeSpaceError{Space: s, Min: MinFreeSpace{Percent: 20}}
+	assert.Contains(t, err.Error(), `pool "pool"`)
+	assert.Contains(t, err.Error(), "20%")
+}
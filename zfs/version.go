@@ -0,0 +1,53 @@
+package zfs
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// ZFSVersion is the output of `zfs version`.
+type ZFSVersion struct {
+	// e.g. "zfs-2.1.5-1"
+	Userland string
+	// e.g. "zfs-kmod-2.1.5-1", empty if the kernel module is not loaded
+	Kernel string
+}
+
+func (v ZFSVersion) String() string {
+	if v.Kernel == "" {
+		return v.Userland
+	}
+	return v.Userland + ", " + v.Kernel
+}
+
+// ZFSGetVersion runs `zfs version`, which is only supported by OpenZFS 0.8 and newer.
+func ZFSGetVersion(ctx context.Context) (ZFSVersion, error) {
+	output, err := zfscmd.CommandContext(ctx, ZFS_BINARY, "version").CombinedOutput()
+	if err != nil {
+		return ZFSVersion{}, errors.Wrapf(err, "zfs version failed (not supported before OpenZFS 0.8): %q", strings.TrimSpace(string(output)))
+	}
+	return parseZFSVersionOutput(string(output))
+}
+
+func parseZFSVersionOutput(output string) (ZFSVersion, error) {
+	var v ZFSVersion
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "zfs-kmod-"):
+			v.Kernel = line
+		case strings.HasPrefix(line, "zfs-") && v.Userland == "":
+			v.Userland = line
+		}
+	}
+	if v.Userland == "" {
+		return v, errors.Errorf("cannot parse zfs version output: %q", output)
+	}
+	return v, nil
+}
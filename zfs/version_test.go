@@ -0,0 +1,21 @@
+package zfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseZFSVersionOutput(t *testing.T) {
+	v, err := parseZFSVersionOutput("zfs-2.1.5-1ubuntu6~22.04.1\nzfs-kmod-2.1.5-1ubuntu6~22.04.1\n")
+	require.NoError(t, err)
+	assert.Equal(t, ZFSVersion{Userland: "zfs-2.1.5-1ubuntu6~22.04.1", Kernel: "zfs-kmod-2.1.5-1ubuntu6~22.04.1"}, v)
+
+	v, err = parseZFSVersionOutput("zfs-0.8.3-1\n")
+	require.NoError(t, err)
+	assert.Equal(t, "zfs-0.8.3-1", v.String())
+
+	_, err = parseZFSVersionOutput("unrecognized command 'version'\n")
+	assert.Error(t, err)
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -294,3 +295,39 @@ func TestExcessiveArgumentsResultInE2BIG(t *testing.T) {
 		t.Logf("output:\n%s", output)
 	}
 }
+
+// slowDestroyer models the fixed cost of a zfs destroy invocation,
+// which dominates the destroy of snapshots without much unique data.
+type slowDestroyer struct {
+	mockBatchDestroy
+	perInvocation time.Duration
+}
+
+func (d *slowDestroyer) Destroy(ctx context.Context, args []string) error {
+	time.Sleep(d.perInvocation)
+	return d.mockBatchDestroy.Destroy(ctx, args)
+}
+
+// Run against a real pool by replacing the destroyer with destroyerSingleton,
+// the mock only shows how the number of zfs invocations scales.
+func BenchmarkDestroyManySnapshots(b *testing.B) {
+	const nsnaps = 500
+	for _, commaUnsupported := range []bool{true, false} {
+		b.Run(fmt.Sprintf("commaUnsupported=%v", commaUnsupported), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				d := &slowDestroyer{mockBatchDestroy{commaUnsupported: commaUnsupported}, 50 * time.Microsecond}
+				var errs = make([]error, nsnaps)
+				reqs := make([]*DestroySnapOp, nsnaps)
+				for j := range reqs {
+					reqs[j] = &DestroySnapOp{"pool/fs", fmt.Sprintf("zrepl_%05d", j), &errs[j]}
+				}
+				doDestroy(context.Background(), reqs, d)
+				for _, err := range errs {
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
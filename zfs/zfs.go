@@ -525,6 +525,9 @@ func (v ZFSSendArgVersion) MustBeBookmark() {
 type ZFSSendArgsUnvalidated struct {
 	FS       string
 	From, To *ZFSSendArgVersion // From may be nil
+	// send all snapshots between From and To (send -I instead of -i),
+	// requires From to be a snapshot and is incompatible with ResumeToken
+	Intermediates bool
 	ZFSSendFlags
 }
 
@@ -606,6 +609,15 @@ func (a ZFSSendArgsUnvalidated) Validate(ctx context.Context) (v ZFSSendArgsVali
 		// fallthrough
 	}
 
+	if a.Intermediates {
+		if a.From == nil || !a.From.IsSnapshot() {
+			return v, newGenericValidationError(a, fmt.Errorf("`Intermediates` requires `From` to be a snapshot"))
+		}
+		if a.ResumeToken != "" {
+			return v, newGenericValidationError(a, fmt.Errorf("`Intermediates` cannot be combined with a resume token"))
+		}
+	}
+
 	if err := a.ZFSSendFlags.Validate(); err != nil {
 		return v, newGenericValidationError(a, errors.Wrap(err, "send flags invalid"))
 	}
@@ -711,6 +723,8 @@ func (a ZFSSendArgsValidated) buildSendCommandLine() ([]string, error) {
 
 	if fromV == "" { // Initial
 		flags = append(flags, toV)
+	} else if a.Intermediates {
+		flags = append(flags, "-I", fromV, toV)
 	} else {
 		flags = append(flags, "-i", fromV, toV)
 	}
@@ -920,20 +934,37 @@ var (
 )
 
 // see test cases for example output
+//
+// The output of `send -I` has one info line per intermediate snapshot,
+// they are combined into one DrySendInfo from the first From to the last To.
 func (s *DrySendInfo) unmarshalZFSOutput(output []byte) (err error) {
 	debug("DrySendInfo.unmarshalZFSOutput: output=%q", output)
 	lines := strings.Split(string(output), "\n")
+	matched := false
 	for _, l := range lines {
-		regexMatched, err := s.unmarshalInfoLine(l)
+		var li DrySendInfo
+		regexMatched, err := li.unmarshalInfoLine(l)
 		if err != nil {
 			return fmt.Errorf("line %q: %s", l, err)
 		}
 		if !regexMatched {
 			continue
 		}
-		return nil
+		if !matched {
+			*s = li
+			matched = true
+			continue
+		}
+		if li.Filesystem != s.Filesystem || li.Type != DrySendTypeIncremental {
+			return fmt.Errorf("line %q: unexpected info line after %q", l, s.To)
+		}
+		s.To = li.To
+		s.SizeEstimate += li.SizeEstimate
 	}
-	return fmt.Errorf("no match for info line (regex1 %s) (regex2 %s)", sendDryRunInfoLineRegexFull, sendDryRunInfoLineRegexIncremental)
+	if !matched {
+		return fmt.Errorf("no match for info line (regex1 %s) (regex2 %s)", sendDryRunInfoLineRegexFull, sendDryRunInfoLineRegexIncremental)
+	}
+	return nil
 }
 
 // unmarshal info line, looks like this:
@@ -1056,16 +1087,62 @@ type RecvOptions struct {
 	// Rollback to the oldest snapshot, destroy it, then perform `recv -F`.
 	// Note that this doesn't change property values, i.e. an existing local property value will be kept.
 	RollbackAndForceRecv bool
+	// Set -F flag without any prior rollback or snapshot destruction.
+	// The caller is responsible for checking which snapshots `recv -F` is going to destroy.
+	ForceRecv bool
 	// Set -s flag used for resumable send & recv
 	SavePartialRecvState bool
 
 	InheritProperties  []zfsprop.Property
 	OverrideProperties map[zfsprop.Property]string
+
+	Mount RecvMountBehavior
+	// The received dataset is a volume, which has no canmount property.
+	IsVolume bool
+}
+
+// RecvMountBehavior determines whether received filesystems are mounted.
+type RecvMountBehavior int
+
+const (
+	// ZFS default behavior: mount the received filesystem according to its properties.
+	RecvMountKeep RecvMountBehavior = iota
+	// Don't mount after receive (-u) and set canmount=noauto, i.e., the filesystem
+	// can be mounted manually with `zfs mount` but is not mounted on boot.
+	RecvMountNoauto
+	// Don't mount after receive (-u) and set canmount=off.
+	RecvMountNever
+)
+
+func RecvMountBehaviorFromString(s string) (RecvMountBehavior, error) {
+	switch s {
+	case "keep":
+		return RecvMountKeep, nil
+	case "noauto":
+		return RecvMountNoauto, nil
+	case "never":
+		return RecvMountNever, nil
+	default:
+		return 0, fmt.Errorf("invalid mount behavior %q, must be one of 'keep', 'noauto', 'never'", s)
+	}
+}
+
+// CanmountProperty returns the value of the `canmount` property that zfs recv sets for m,
+// or "" if the property is not set.
+func (m RecvMountBehavior) CanmountProperty() string {
+	switch m {
+	case RecvMountNoauto:
+		return "noauto"
+	case RecvMountNever:
+		return "off"
+	default:
+		return ""
+	}
 }
 
 func (opts RecvOptions) buildRecvFlags() []string {
 	args := make([]string, 0)
-	if opts.RollbackAndForceRecv {
+	if opts.RollbackAndForceRecv || opts.ForceRecv {
 		args = append(args, "-F")
 	}
 	if opts.SavePartialRecvState {
@@ -1081,10 +1158,29 @@ func (opts RecvOptions) buildRecvFlags() []string {
 			args = append(args, "-o", fmt.Sprintf("%s=%s", prop, value))
 		}
 	}
+	if canmount := opts.Mount.CanmountProperty(); canmount != "" {
+		args = append(args, "-u")
+		// an explicitly inherited or overridden canmount takes precedence
+		if !opts.IsVolume && !opts.setsCanmount() {
+			args = append(args, "-o", "canmount="+canmount)
+		}
+	}
 
 	return args
 }
 
+func (opts RecvOptions) setsCanmount() bool {
+	if _, ok := opts.OverrideProperties["canmount"]; ok {
+		return true
+	}
+	for _, prop := range opts.InheritProperties {
+		if prop == "canmount" {
+			return true
+		}
+	}
+	return false
+}
+
 const RecvStderrBufSiz = 1 << 15
 
 func ZFSRecv(ctx context.Context, fs string, v *ZFSSendArgVersion, stream io.ReadCloser, opts RecvOptions) (err error) {
@@ -1197,6 +1293,8 @@ func ZFSRecv(ctx context.Context, fs string, v *ZFSSendArgVersion, stream io.Rea
 				waitErrChan <- owErr
 			} else if readErr := tryRecvCannotReadFromStreamErr(stderr.Bytes()); readErr != nil {
 				waitErrChan <- readErr
+			} else if propErr := tryRecvPropertiesRejectedErr(stderr.Bytes()); propErr != nil {
+				waitErrChan <- propErr
 			} else {
 				waitErrChan <- &ZFSError{
 					Stderr:  stderr.Bytes(),
@@ -1290,6 +1388,36 @@ func tryRecvCannotReadFromStreamErr(stderr []byte) *RecvCannotReadFromStreamErr
 	return &RecvCannotReadFromStreamErr{Msg: string(m[1])}
 }
 
+// RecvPropertiesRejectedErr is returned by ZFSRecv if the receiving side could not set
+// some of the properties in the stream, e.g. because they are read-only or unknown in its ZFS version.
+// The snapshot itself may have been received.
+type RecvPropertiesRejectedErr struct {
+	Properties []zfsprop.Property
+	Msg        string
+}
+
+func (e *RecvPropertiesRejectedErr) Error() string {
+	return e.Msg
+}
+
+var reRecvPropertyRejected = regexp.MustCompile(`(?m)^cannot receive (\S+) property on \S+: .*$`)
+
+func tryRecvPropertiesRejectedErr(stderr []byte) *RecvPropertiesRejectedErr {
+	ms := reRecvPropertyRejected.FindAllSubmatch(stderr, -1)
+	if ms == nil {
+		return nil
+	}
+	e := &RecvPropertiesRejectedErr{Msg: strings.TrimSpace(string(stderr))}
+	seen := make(map[string]bool, len(ms))
+	for _, m := range ms {
+		if p := string(m[1]); !seen[p] {
+			seen[p] = true
+			e.Properties = append(e.Properties, zfsprop.Property(p))
+		}
+	}
+	return e
+}
+
 type ClearResumeTokenError struct {
 	ZFSOutput []byte
 	CmdError  error
@@ -1423,6 +1551,12 @@ func ZFSGetRawAnySource(ctx context.Context, path string, props []string) (*ZFSP
 	return zfsGet(ctx, path, props, SourceAny)
 }
 
+// ZFSGetRawLocalSource is like ZFSGetRawAnySource but only returns values whose source is `local`.
+// Values of properties inherited from a parent dataset are returned as "".
+func ZFSGetRawLocalSource(ctx context.Context, path string, props []string) (*ZFSProperties, error) {
+	return zfsGet(ctx, path, props, SourceLocal)
+}
+
 var zfsGetDatasetDoesNotExistRegexp = regexp.MustCompile(`^cannot open '([^)]+)': (dataset does not exist|no such pool or dataset)`) // verified in platformtest
 
 type DatasetDoesNotExist struct {
@@ -1670,7 +1804,8 @@ func ZFSDestroyIdempotent(ctx context.Context, path string) error {
 	return err
 }
 
-func ZFSSnapshot(ctx context.Context, fs *DatasetPath, name string, recursive bool) (err error) {
+// ZFSSnapshot creates snapshot fs@name with the user properties in props (may be nil).
+func ZFSSnapshot(ctx context.Context, fs *DatasetPath, name string, recursive bool, props map[zfsprop.Property]string) (err error) {
 
 	promTimer := prometheus.NewTimer(prom.ZFSSnapshotDuration.WithLabelValues(fs.ToString()))
 	defer promTimer.ObserveDuration()
@@ -1680,19 +1815,45 @@ func ZFSSnapshot(ctx context.Context, fs *DatasetPath, name string, recursive bo
 		return errors.Wrap(err, "zfs snapshot")
 	}
 
-	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, "snapshot", snapname)
+	args := []string{"snapshot"}
+	propNames := make([]string, 0, len(props))
+	for p := range props {
+		propNames = append(propNames, string(p))
+	}
+	sort.Strings(propNames)
+	for _, p := range propNames {
+		args = append(args, "-o", fmt.Sprintf("%s=%s", p, props[zfsprop.Property(p)]))
+	}
+	args = append(args, snapname)
+	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, args...)
 	stdio, err := cmd.CombinedOutput()
 	if err != nil {
 		err = &ZFSError{
 			Stderr:  stdio,
 			WaitErr: err,
 		}
+		if dsNotExistErr := trySnapshotDatasetDoesNotExist(fs.ToString(), snapname, stdio); dsNotExistErr != nil {
+			err = dsNotExistErr
+		}
 	}
 
 	return
 
 }
 
+var zfsSnapshotDatasetDoesNotExistRegexp = regexp.MustCompile(`^cannot create snapshot '([^']+)': (dataset does not exist|no such pool or dataset)`)
+
+// trySnapshotDatasetDoesNotExist detects that fs was destroyed before `zfs snapshot` could snapshot it.
+func trySnapshotDatasetDoesNotExist(fs, snapname string, stderr []byte) *DatasetDoesNotExist {
+	if ddne := tryDatasetDoesNotExist(fs, stderr); ddne != nil {
+		return ddne
+	}
+	if sm := zfsSnapshotDatasetDoesNotExistRegexp.FindSubmatch(stderr); sm != nil && string(sm[1]) == snapname {
+		return &DatasetDoesNotExist{fs}
+	}
+	return nil
+}
+
 var zfsBookmarkExistsRegex = regexp.MustCompile("^cannot create bookmark '[^']+': bookmark exists")
 
 type BookmarkExists struct {
@@ -1790,6 +1951,24 @@ func ZFSBookmark(ctx context.Context, fs string, v FilesystemVersion, bookmark s
 	return bm, nil
 }
 
+// ZFSRenameSnapshot renames the snapshot fs@from to fs@to.
+func ZFSRenameSnapshot(ctx context.Context, fs *DatasetPath, from, to string) error {
+	fromAbs := fmt.Sprintf("%s@%s", fs.ToString(), from)
+	toAbs := fmt.Sprintf("%s@%s", fs.ToString(), to)
+	if err := EntityNamecheck(toAbs, EntityTypeSnapshot); err != nil {
+		return errors.Wrap(err, "zfs rename")
+	}
+	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, "rename", fromAbs, toAbs)
+	stdio, err := cmd.CombinedOutput()
+	if err != nil {
+		return &ZFSError{
+			Stderr:  stdio,
+			WaitErr: err,
+		}
+	}
+	return nil
+}
+
 func ZFSRollback(ctx context.Context, fs *DatasetPath, snapshot FilesystemVersion, rollbackArgs ...string) (err error) {
 
 	snapabs := snapshot.ToAbsPath(fs)
@@ -166,6 +166,14 @@ incremental	p1 with/ spaces d1@1 with space	p1 with/ spaces d1@2 with space
 
 	fullZeroSized_0_7_12 := `
 full	p1 with/ spaces d1@2 with space
+`
+
+	// incremental send of intermediate snapshots
+	// $ sudo zfs send -nvP -I @1 zroot/test/a@3
+	incIntermediates := `
+incremental	zroot/test/a@1	zroot/test/a@2	1000
+incremental	zroot/test/a@2	zroot/test/a@3	2000
+size	3000
 `
 
 	fullWithSpaces := "\nfull\tpool1/otherjob/ds with spaces@blaffoo\t12912\nsize\t12912\n"
@@ -223,6 +231,16 @@ full	p1 with/ spaces d1@2 with space
 				SizeEstimate: 10511856,
 			},
 		},
+		{
+			name: "incIntermediates", in: incIntermediates,
+			exp: &DrySendInfo{
+				Type:         DrySendTypeIncremental,
+				Filesystem:   "zroot/test/a",
+				From:         "zroot/test/a@1",
+				To:           "zroot/test/a@3",
+				SizeEstimate: 3000,
+			},
+		},
 		{
 			name: "fullNoToken", in: fullNoToken,
 			exp: &DrySendInfo{
@@ -323,6 +341,35 @@ func TestTryRecvDestroyOrOverwriteEncryptedErr(t *testing.T) {
 	assert.EqualError(t, err, strings.TrimSpace(msg))
 }
 
+func TestTryRecvPropertiesRejectedErr(t *testing.T) {
+	msg := "cannot receive special_small_blocks property on pool/fs: invalid property\n" +
+		"cannot receive org.example:x property on pool/fs: permission denied\n" +
+		"cannot receive special_small_blocks property on pool/fs: invalid property\n"
+	err := tryRecvPropertiesRejectedErr([]byte(msg))
+	require.NotNil(t, err)
+	assert.Equal(t, []zfsprop.Property{"special_small_blocks", "org.example:x"}, err.Properties)
+	assert.EqualError(t, err, strings.TrimSpace(msg))
+
+	assert.Nil(t, tryRecvPropertiesRejectedErr([]byte("cannot receive incremental stream: destination pool/fs has been modified\n")))
+}
+
+func TestTrySnapshotDatasetDoesNotExist(t *testing.T) {
+	for _, msg := range []string{
+		"cannot create snapshot 'pool/gone@zrepl_1': dataset does not exist\n",
+		"cannot open 'pool/gone': dataset does not exist\n",
+	} {
+		err := trySnapshotDatasetDoesNotExist("pool/gone", "pool/gone@zrepl_1", []byte(msg))
+		require.NotNil(t, err, msg)
+		assert.Equal(t, "pool/gone", err.Path)
+	}
+	for _, msg := range []string{
+		"cannot create snapshot 'pool/other@zrepl_1': dataset does not exist\n",
+		"cannot create snapshot 'pool/gone@zrepl_1': out of space\n",
+	} {
+		assert.Nil(t, trySnapshotDatasetDoesNotExist("pool/gone", "pool/gone@zrepl_1", []byte(msg)), msg)
+	}
+}
+
 func TestZFSSendArgsBuildSendFlags(t *testing.T) {
 
 	type args = ZFSSendFlags
@@ -407,6 +454,25 @@ func TestZFSSendArgsBuildSendFlags(t *testing.T) {
 	}
 }
 
+func TestZFSSendArgsBuildSendCommandLineIntermediates(t *testing.T) {
+	a := ZFSSendArgsValidated{
+		ZFSSendArgsUnvalidated: ZFSSendArgsUnvalidated{
+			FS:           "pool/fs",
+			From:         &ZFSSendArgVersion{RelName: "@1", GUID: 1},
+			To:           &ZFSSendArgVersion{RelName: "@3", GUID: 3},
+			ZFSSendFlags: ZFSSendFlags{Encrypted: &nodefault.Bool{B: false}},
+		},
+	}
+	args, err := a.buildSendCommandLine()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-i", "pool/fs@1", "pool/fs@3"}, args)
+
+	a.Intermediates = true
+	args, err = a.buildSendCommandLine()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-I", "pool/fs@1", "pool/fs@3"}, args)
+}
+
 func TestZFSCommonRecvArgsBuild(t *testing.T) {
 	type RecvTest struct {
 		conf         RecvOptions
@@ -438,6 +504,31 @@ func TestZFSCommonRecvArgsBuild(t *testing.T) {
 			conf:         RecvOptions{InheritProperties: []zfsprop.Property{"abc", "123"}},
 			flagsInclude: []string{"-x", "abc", "123"}, flagsExclude: []string{"-o", "-F", "-s"},
 		},
+		"Mount noauto": {
+			conf:         RecvOptions{Mount: RecvMountNoauto},
+			flagsInclude: []string{"-u", "-o", "canmount=noauto"},
+			flagsExclude: []string{"-x", "-F", "-s"},
+		},
+		"Mount never": {
+			conf:         RecvOptions{Mount: RecvMountNever},
+			flagsInclude: []string{"-u", "-o", "canmount=off"},
+			flagsExclude: []string{"-x", "-F", "-s"},
+		},
+		"Mount noauto volume": {
+			conf:         RecvOptions{Mount: RecvMountNoauto, IsVolume: true},
+			flagsInclude: []string{"-u"},
+			flagsExclude: []string{"-o", "canmount=noauto"},
+		},
+		"Mount never with canmount override": {
+			conf:         RecvOptions{Mount: RecvMountNever, OverrideProperties: map[zfsprop.Property]string{"canmount": "on"}},
+			flagsInclude: []string{"-u", "-o", "canmount=on"},
+			flagsExclude: []string{"canmount=off"},
+		},
+		"Mount keep": {
+			conf:         RecvOptions{Mount: RecvMountKeep},
+			flagsInclude: []string{},
+			flagsExclude: []string{"-u", "-o"},
+		},
 	}
 
 	for testName, test := range recvTests {
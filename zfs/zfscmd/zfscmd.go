@@ -3,6 +3,7 @@
 // - logging start and end of command execution
 // - status report of active commands
 // - prometheus metrics of runtimes
+// - audit log of commands that create or destroy data
 package zfscmd
 
 import (
@@ -24,11 +25,15 @@ type Cmd struct {
 	mtx                                      sync.RWMutex
 	startedAt, waitStartedAt, waitReturnedAt time.Time
 	waitReturnEndSpanCb                      trace.DoneFunc
+
+	// the zfs command line without the nice(1) and ionice(1) wrappers of Priority
+	zfsArgs []string
 }
 
 func CommandContext(ctx context.Context, name string, arg ...string) *Cmd {
-	cmd := exec.CommandContext(ctx, name, arg...)
-	return &Cmd{cmd: cmd, ctx: ctx}
+	wrappedName, wrappedArgs := getPriority(ctx).wrap(ctx, name, arg)
+	cmd := exec.CommandContext(ctx, wrappedName, wrappedArgs...)
+	return &Cmd{cmd: cmd, zfsArgs: append([]string{name}, arg...), ctx: ctx}
 }
 
 // err.(*exec.ExitError).Stderr will NOT be set
@@ -133,6 +138,7 @@ func (c *Cmd) startPost(err error) {
 	startPostLogging(c, err, now)
 
 	if err != nil {
+		waitPostAudit(c, err, now)
 		c.waitReturnEndSpanCb()
 	}
 }
@@ -197,6 +203,7 @@ func (c *Cmd) waitPost(err error) {
 	waitPostReport(c, u, now)
 	waitPostLogging(c, u, err, now)
 	waitPostPrometheus(c, u, err, now)
+	waitPostAudit(c, err, now)
 
 	// must be last because c.ctx might be used by other waitPost calls
 	c.waitReturnEndSpanCb()
@@ -0,0 +1,113 @@
+package zfscmd
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditRecord is written for each zfs command that creates or destroys data,
+// see SetAuditWriter.
+type AuditRecord struct {
+	Time time.Time
+	// the name of the job that ran the command, empty if it was not run on behalf of a job
+	Job string `json:",omitempty"`
+	// one of the AuditOp* constants
+	Operation string
+	// the filesystem or volume that the command operates on
+	Dataset string
+	// the snapshots created or destroyed, or the snapshot to roll back to or receive
+	Snapshots []string `json:",omitempty"`
+	// "ok" or "error"
+	Result string
+	Error  string `json:",omitempty"`
+	// the zfs command line
+	Command []string
+}
+
+const (
+	AuditOpSnapshot  = "snapshot"
+	AuditOpDestroy   = "destroy"
+	AuditOpRollback  = "rollback"
+	AuditOpRecvForce = "recv_force"
+)
+
+var audit struct {
+	mtx sync.Mutex
+	w   io.Writer // nil if disabled
+}
+
+// SetAuditWriter enables the audit log: from now on, an AuditRecord is written to w
+// as a line of JSON for each command that snapshots, destroys or rolls back a dataset
+// or that receives with -F. Records are written regardless of the log level.
+// Pass nil to disable the audit log.
+func SetAuditWriter(w io.Writer) {
+	audit.mtx.Lock()
+	defer audit.mtx.Unlock()
+	audit.w = w
+}
+
+// auditRecordFor returns nil if the command is not audited.
+func auditRecordFor(zfsArgs []string) *AuditRecord {
+	if len(zfsArgs) < 3 {
+		return nil // e.g. `zfs destroy` without arguments to check for features
+	}
+	var op string
+	switch zfsArgs[1] {
+	case "snapshot":
+		op = AuditOpSnapshot
+	case "destroy":
+		op = AuditOpDestroy
+	case "rollback":
+		op = AuditOpRollback
+	case "recv", "receive":
+		for _, a := range zfsArgs[2 : len(zfsArgs)-1] {
+			if a == "-F" {
+				op = AuditOpRecvForce
+			}
+		}
+	}
+	if op == "" {
+		return nil
+	}
+	// zrepl always passes the dataset last: fs, fs@snap or fs@snap1,snap2,...
+	target := zfsArgs[len(zfsArgs)-1]
+	r := &AuditRecord{
+		Operation: op,
+		Dataset:   target,
+		Command:   zfsArgs,
+	}
+	if i := strings.IndexByte(target, '@'); i >= 0 {
+		r.Dataset = target[:i]
+		r.Snapshots = strings.Split(target[i+1:], ",")
+	}
+	return r
+}
+
+func waitPostAudit(c *Cmd, err error, now time.Time) {
+	audit.mtx.Lock()
+	defer audit.mtx.Unlock()
+	if audit.w == nil {
+		return
+	}
+	r := auditRecordFor(c.zfsArgs)
+	if r == nil {
+		return
+	}
+	r.Time = now
+	r.Job = getJobIDOrDefault(c.ctx, "")
+	r.Result = "ok"
+	if err != nil {
+		r.Result = "error"
+		r.Error = err.Error()
+	}
+	line, jsonErr := json.Marshal(r)
+	if jsonErr != nil {
+		panic(jsonErr) // AuditRecord always marshals
+	}
+	if _, writeErr := audit.w.Write(append(line, '\n')); writeErr != nil {
+		c.log().WithError(writeErr).WithField("record", string(line)).Error("cannot write audit record")
+	}
+}
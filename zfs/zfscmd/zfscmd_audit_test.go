@@ -0,0 +1,75 @@
+package zfscmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+)
+
+func TestAuditRecordFor(t *testing.T) {
+	tcs := []struct {
+		args      []string
+		op        string
+		dataset   string
+		snapshots []string
+	}{
+		{[]string{"zfs", "snapshot", "-o", "a=b", "pool/fs@s1"}, AuditOpSnapshot, "pool/fs", []string{"s1"}},
+		{[]string{"zfs", "destroy", "pool/fs@s1,s2"}, AuditOpDestroy, "pool/fs", []string{"s1", "s2"}},
+		{[]string{"zfs", "destroy", "pool/fs#b"}, AuditOpDestroy, "pool/fs#b", nil},
+		{[]string{"zfs", "rollback", "-r", "pool/fs@s1"}, AuditOpRollback, "pool/fs", []string{"s1"}},
+		{[]string{"zfs", "recv", "-F", "-s", "pool/fs@s1"}, AuditOpRecvForce, "pool/fs", []string{"s1"}},
+		{[]string{"zfs", "recv", "-s", "pool/fs@s1"}, "", "", nil},
+		{[]string{"zfs", "list", "-H", "pool/fs"}, "", "", nil},
+		{[]string{"zfs", "destroy"}, "", "", nil},
+	}
+	for _, tc := range tcs {
+		r := auditRecordFor(tc.args)
+		if tc.op == "" {
+			assert.Nil(t, r, "%v", tc.args)
+			continue
+		}
+		require.NotNil(t, r, "%v", tc.args)
+		assert.Equal(t, tc.op, r.Operation)
+		assert.Equal(t, tc.dataset, r.Dataset)
+		assert.Equal(t, tc.snapshots, r.Snapshots)
+	}
+}
+
+func TestAuditWriter(t *testing.T) {
+	var buf bytes.Buffer
+	SetAuditWriter(&buf)
+	defer SetAuditWriter(nil)
+
+	ctx, endTask := trace.WithTaskFromStack(context.Background())
+	defer endTask()
+	ctx = WithJobID(ctx, "myjob")
+	// `true` ignores its arguments, so it can pose as zfs
+	_, err := CommandContext(ctx, "true", "snapshot", "pool/fs@s1").CombinedOutput()
+	require.NoError(t, err)
+	_, err = CommandContext(ctx, "true", "list", "pool/fs").CombinedOutput()
+	require.NoError(t, err)
+	_, err = CommandContext(ctx, "false", "destroy", "pool/fs@s1").CombinedOutput()
+	require.Error(t, err)
+
+	dec := json.NewDecoder(&buf)
+	var r AuditRecord
+	require.NoError(t, dec.Decode(&r))
+	assert.Equal(t, "myjob", r.Job)
+	assert.Equal(t, AuditOpSnapshot, r.Operation)
+	assert.Equal(t, "ok", r.Result)
+	assert.False(t, r.Time.IsZero())
+
+	r = AuditRecord{}
+	require.NoError(t, dec.Decode(&r))
+	assert.Equal(t, AuditOpDestroy, r.Operation)
+	assert.Equal(t, "error", r.Result)
+	assert.NotEmpty(t, r.Error)
+
+	assert.False(t, dec.More(), "the list command must not be audited")
+}
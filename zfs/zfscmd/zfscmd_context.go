@@ -11,6 +11,7 @@ type contextKey int
 
 const (
 	contextKeyJobID contextKey = 1 + iota
+	contextKeyPriority
 )
 
 type Logger = logger.Logger
@@ -19,6 +20,17 @@ func WithJobID(ctx context.Context, jobID string) context.Context {
 	return context.WithValue(ctx, contextKeyJobID, jobID)
 }
 
+// WithInherit returns a copy of ctx with the job ID and priority of inheritFrom, if set.
+func WithInherit(ctx, inheritFrom context.Context) context.Context {
+	if jobID, ok := inheritFrom.Value(contextKeyJobID).(string); ok {
+		ctx = WithJobID(ctx, jobID)
+	}
+	if p := getPriority(inheritFrom); p != nil {
+		ctx = WithPriority(ctx, p)
+	}
+	return ctx
+}
+
 func getJobIDOrDefault(ctx context.Context, def string) string {
 	ret, ok := ctx.Value(contextKeyJobID).(string)
 	if !ok {
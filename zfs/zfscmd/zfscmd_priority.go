@@ -0,0 +1,105 @@
+package zfscmd
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// Priority is the CPU and I/O scheduling priority of the zfs commands of a job.
+// The commands are wrapped in nice(1) and ionice(1).
+type Priority struct {
+	// nice(1) adjustment, 0 means unchanged
+	Nice int
+	// ionice(1) scheduling class, empty means unchanged
+	IONiceClass string
+	// ionice(1) priority within IONiceClass, ignored for IONiceClassIdle
+	IONiceLevel int
+	// apply to all zfs commands, not just zfs send
+	AllCommands bool
+}
+
+const (
+	IONiceClassRealtime   = "realtime"
+	IONiceClassBestEffort = "best-effort"
+	IONiceClassIdle       = "idle"
+)
+
+var ioniceClassNumbers = map[string]string{
+	IONiceClassRealtime:   "1",
+	IONiceClassBestEffort: "2",
+	IONiceClassIdle:       "3",
+}
+
+func (p *Priority) Validate() error {
+	if p.Nice < -20 || p.Nice > 19 {
+		return fmt.Errorf("nice value must be in [-20, 19], got %d", p.Nice)
+	}
+	if p.IONiceClass == "" {
+		return nil
+	}
+	if _, ok := ioniceClassNumbers[p.IONiceClass]; !ok {
+		return fmt.Errorf("invalid ionice class %q, must be %q, %q or %q", p.IONiceClass, IONiceClassRealtime, IONiceClassBestEffort, IONiceClassIdle)
+	}
+	if p.IONiceLevel < 0 || p.IONiceLevel > 7 {
+		return fmt.Errorf("ionice level must be in [0, 7], got %d", p.IONiceLevel)
+	}
+	return nil
+}
+
+func WithPriority(ctx context.Context, p *Priority) context.Context {
+	return context.WithValue(ctx, contextKeyPriority, p)
+}
+
+func getPriority(ctx context.Context) *Priority {
+	p, _ := ctx.Value(contextKeyPriority).(*Priority)
+	return p
+}
+
+var priorityWrapperUnsupported struct {
+	once sync.Once
+	nice bool
+	// ionice(1) is Linux-specific
+	ionice bool
+}
+
+func priorityWrapperCheckSupport(ctx context.Context) {
+	priorityWrapperUnsupported.once.Do(func() {
+		if _, err := exec.LookPath("nice"); err != nil {
+			priorityWrapperUnsupported.nice = true
+			getLogger(ctx).WithError(err).Warn("nice(1) is not available, running zfs commands without nice")
+		}
+		if _, err := exec.LookPath("ionice"); err != nil || runtime.GOOS != "linux" {
+			priorityWrapperUnsupported.ionice = true
+			getLogger(ctx).WithField("goos", runtime.GOOS).Warn("ionice(1) is not available on this platform, running zfs commands without ionice")
+		}
+	})
+}
+
+// wrap returns the command line that runs name with args under priority p.
+func (p *Priority) wrap(ctx context.Context, name string, args []string) (string, []string) {
+	if p == nil || (!p.AllCommands && (len(args) == 0 || args[0] != "send")) {
+		return name, args
+	}
+	priorityWrapperCheckSupport(ctx)
+
+	var prefix []string
+	if p.Nice != 0 && !priorityWrapperUnsupported.nice {
+		prefix = append(prefix, "nice", "-n", strconv.Itoa(p.Nice))
+	}
+	if p.IONiceClass != "" && !priorityWrapperUnsupported.ionice {
+		prefix = append(prefix, "ionice", "-c", ioniceClassNumbers[p.IONiceClass])
+		if p.IONiceClass != IONiceClassIdle {
+			prefix = append(prefix, "-n", strconv.Itoa(p.IONiceLevel))
+		}
+	}
+	if len(prefix) == 0 {
+		return name, args
+	}
+	wrapped := append(prefix[1:], name)
+	wrapped = append(wrapped, args...)
+	return prefix[0], wrapped
+}
@@ -0,0 +1,70 @@
+package zfscmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityValidate(t *testing.T) {
+	valid := []Priority{
+		{},
+		{Nice: 19},
+		{Nice: -20},
+		{IONiceClass: IONiceClassBestEffort, IONiceLevel: 7},
+		{IONiceClass: IONiceClassIdle, IONiceLevel: 4},
+	}
+	for _, p := range valid {
+		assert.NoError(t, p.Validate(), "%#v", p)
+	}
+	invalid := []Priority{
+		{Nice: 20},
+		{Nice: -21},
+		{IONiceClass: "background"},
+		{IONiceClass: IONiceClassRealtime, IONiceLevel: 8},
+		{IONiceClass: IONiceClassBestEffort, IONiceLevel: -1},
+	}
+	for _, p := range invalid {
+		assert.Error(t, p.Validate(), "%#v", p)
+	}
+}
+
+func TestPriorityWrap(t *testing.T) {
+	// pretend that nice and ionice are available, independent of the test environment
+	priorityWrapperUnsupported.once.Do(func() {})
+
+	ctx := context.Background()
+	wrap := func(p *Priority, args ...string) []string {
+		name, args := p.wrap(ctx, "zfs", args)
+		return append([]string{name}, args...)
+	}
+
+	p := &Priority{Nice: 10, IONiceClass: IONiceClassBestEffort, IONiceLevel: 7}
+	assert.Equal(t, []string{"nice", "-n", "10", "ionice", "-c", "2", "-n", "7", "zfs", "send", "pool/fs@a"}, wrap(p, "send", "pool/fs@a"))
+	assert.Equal(t, []string{"zfs", "list"}, wrap(p, "list"), "only zfs send by default")
+
+	p = &Priority{IONiceClass: IONiceClassIdle, IONiceLevel: 4, AllCommands: true}
+	assert.Equal(t, []string{"ionice", "-c", "3", "zfs", "list"}, wrap(p, "list"))
+
+	p = &Priority{AllCommands: true}
+	assert.Equal(t, []string{"zfs", "send", "pool/fs@a"}, wrap(p, "send", "pool/fs@a"))
+	assert.Equal(t, []string{"zfs", "send", "pool/fs@a"}, wrap(nil, "send", "pool/fs@a"))
+
+	// the metrics and the report use the zfs command line
+	cmd := CommandContext(WithPriority(ctx, &Priority{Nice: 5}), "zfs", "send", "pool/fs@a")
+	assert.Equal(t, []string{"zfs", "send", "pool/fs@a"}, cmd.zfsArgs)
+	assert.Equal(t, "nice -n 5 zfs send pool/fs@a", cmd.String())
+}
+
+func TestWithInherit(t *testing.T) {
+	p := &Priority{Nice: 5}
+	from := WithPriority(WithJobID(context.Background(), "job"), p)
+	ctx := WithInherit(context.Background(), from)
+	assert.Equal(t, "job", getJobIDOrDefault(ctx, ""))
+	assert.Equal(t, p, getPriority(ctx))
+
+	ctx = WithInherit(context.Background(), context.Background())
+	assert.Equal(t, "", getJobIDOrDefault(ctx, ""))
+	assert.Nil(t, getPriority(ctx))
+}
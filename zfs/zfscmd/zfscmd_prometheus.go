@@ -16,27 +16,47 @@ var timeLabels = []string{"jobid", "zfsbinary", "zfsverb"}
 var timeBuckets = []float64{0.01, 0.1, 0.2, 0.5, 0.75, 1, 2, 5, 10, 60}
 
 func init() {
+	initMetrics()
+}
+
+// extra labels of the metrics whose values depend on the job, see SetJobLabels
+var jobLabels struct {
+	names  []string
+	values map[string][]string // by job id
+}
+
+// SetJobLabels adds the labels names to the metrics, with values[jobid] as values
+// for the commands of that job and empty values for other commands.
+// Must be called before RegisterMetrics.
+func SetJobLabels(names []string, values map[string][]string) {
+	jobLabels.names = names
+	jobLabels.values = values
+	initMetrics()
+}
+
+func initMetrics() {
+	labels := append(append([]string{}, timeLabels...), jobLabels.names...)
 	metrics.totaltime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace: "zrepl",
 		Subsystem: "zfscmd",
 		Name:      "runtime",
 		Help:      "number of seconds that the command took from start until wait returned",
 		Buckets:   timeBuckets,
-	}, timeLabels)
+	}, labels)
 	metrics.systemtime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace: "zrepl",
 		Subsystem: "zfscmd",
 		Name:      "systemtime",
 		Help:      "https://golang.org/pkg/os/#ProcessState.SystemTime",
 		Buckets:   timeBuckets,
-	}, timeLabels)
+	}, labels)
 	metrics.usertime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace: "zrepl",
 		Subsystem: "zfscmd",
 		Name:      "usertime",
 		Help:      "https://golang.org/pkg/os/#ProcessState.UserTime",
 		Buckets:   timeBuckets,
-	}, timeLabels)
+	}, labels)
 
 }
 
@@ -48,8 +68,8 @@ func RegisterMetrics(r prometheus.Registerer) {
 
 func waitPostPrometheus(c *Cmd, u usage, err error, now time.Time) {
 
-	if len(c.cmd.Args) < 2 {
-		getLogger(c.ctx).WithField("args", c.cmd.Args).
+	if len(c.zfsArgs) < 2 {
+		getLogger(c.ctx).WithField("args", c.zfsArgs).
 			Warn("prometheus: cannot turn zfs command into metric")
 		return
 	}
@@ -60,7 +80,12 @@ func waitPostPrometheus(c *Cmd, u usage, err error, now time.Time) {
 
 	jobid := getJobIDOrDefault(c.ctx, "_nojobid")
 
-	labelValues := []string{jobid, c.cmd.Args[0], c.cmd.Args[1]}
+	labelValues := []string{jobid, c.zfsArgs[0], c.zfsArgs[1]}
+	if values, ok := jobLabels.values[jobid]; ok {
+		labelValues = append(labelValues, values...)
+	} else {
+		labelValues = append(labelValues, make([]string, len(jobLabels.names))...)
+	}
 
 	metrics.totaltime.
 		WithLabelValues(labelValues...).
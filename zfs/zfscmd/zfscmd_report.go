@@ -2,6 +2,7 @@ package zfscmd
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
@@ -13,10 +14,17 @@ type Report struct {
 type ActiveCommand struct {
 	Path      string
 	Args      []string
+	String    string
 	StartedAt time.Time
+	// time elapsed since StartedAt at the time the report was created
+	Runtime time.Duration
+	// the name of the job that runs the command, empty if it was not started on behalf of a job
+	JobID string `json:",omitempty"`
 }
 
+// GetReport returns the currently active commands, sorted by start time.
 func GetReport() *Report {
+	now := time.Now()
 	active.mtx.RLock()
 	defer active.mtx.RUnlock()
 	var activeCommands []ActiveCommand
@@ -25,10 +33,16 @@ func GetReport() *Report {
 		activeCommands = append(activeCommands, ActiveCommand{
 			Path:      c.cmd.Path,
 			Args:      c.cmd.Args,
+			String:    c.String(),
 			StartedAt: c.startedAt,
+			Runtime:   now.Sub(c.startedAt),
+			JobID:     getJobIDOrDefault(c.ctx, ""),
 		})
 		c.mtx.RUnlock()
 	}
+	sort.Slice(activeCommands, func(i, j int) bool {
+		return activeCommands[i].StartedAt.Before(activeCommands[j].StartedAt)
+	})
 	return &Report{
 		Active: activeCommands,
 	}
@@ -0,0 +1,38 @@
+package zfscmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportActiveCommands(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	find := func(r *Report, s string) *ActiveCommand {
+		for _, c := range r.Active {
+			if c.String == s {
+				return &c
+			}
+		}
+		return nil
+	}
+
+	cmd := CommandContext(ctx, "sleep", "3600")
+	require.NoError(t, cmd.Start())
+	time.Sleep(10 * time.Millisecond)
+
+	ac := find(GetReport(), "sleep 3600")
+	require.NotNil(t, ac)
+	assert.Equal(t, []string{"sleep", "3600"}, ac.Args)
+	assert.False(t, ac.StartedAt.IsZero())
+	assert.True(t, ac.Runtime >= 10*time.Millisecond, "%s", ac.Runtime)
+
+	cancel()
+	_ = cmd.Wait()
+	assert.Nil(t, find(GetReport(), "sleep 3600"))
+}
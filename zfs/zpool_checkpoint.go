@@ -0,0 +1,75 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/util/envconst"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// ZPoolCheckpoint creates a checkpoint of pool (`zpool checkpoint`).
+// It fails if the pool already has a checkpoint.
+func ZPoolCheckpoint(ctx context.Context, pool string) error {
+	output, err := zfscmd.CommandContext(ctx, "zpool", "checkpoint", pool).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cannot checkpoint pool %q: %s: %s", pool, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+var zpoolCheckpointDiscardPollInterval = envconst.Duration("ZREPL_ZPOOL_CHECKPOINT_DISCARD_POLL_INTERVAL", 2*time.Second)
+
+// ZPoolCheckpointDiscard discards the checkpoint of pool (`zpool checkpoint -d`).
+// ZFS frees the checkpointed space in the background after the command returns,
+// and the pool cannot be checkpointed again until it is done, so ZPoolCheckpointDiscard
+// waits for the pool's checkpoint property to drop to zero.
+func ZPoolCheckpointDiscard(ctx context.Context, pool string) error {
+	output, err := zfscmd.CommandContext(ctx, "zpool", "checkpoint", "-d", pool).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cannot discard checkpoint of pool %q: %s: %s", pool, err, strings.TrimSpace(string(output)))
+	}
+	for {
+		space, err := zpoolCheckpointSpace(ctx, pool)
+		if err != nil {
+			return err
+		}
+		if space == 0 {
+			return nil
+		}
+		t := time.NewTimer(zpoolCheckpointDiscardPollInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return errors.Wrapf(ctx.Err(), "cannot wait for the checkpoint of pool %q to be discarded", pool)
+		case <-t.C:
+		}
+	}
+}
+
+// zpoolCheckpointSpace returns the space held by the checkpoint of pool, 0 if it has none.
+func zpoolCheckpointSpace(ctx context.Context, pool string) (uint64, error) {
+	output, err := zfscmd.CommandContext(ctx, "zpool", "get", "-H", "-p", "-o", "value", "checkpoint", pool).Output()
+	if err != nil {
+		return 0, errors.Wrapf(err, "cannot get checkpoint property of pool %q", pool)
+	}
+	return parseZPoolCheckpointSpace(string(output))
+}
+
+func parseZPoolCheckpointSpace(output string) (uint64, error) {
+	value := strings.TrimSpace(output)
+	// pools without checkpoint report `-`
+	if value == "-" {
+		return 0, nil
+	}
+	space, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected value of pool property checkpoint %q", value)
+	}
+	return space, nil
+}
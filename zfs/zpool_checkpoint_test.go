@@ -0,0 +1,22 @@
+package zfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseZPoolCheckpointSpace(t *testing.T) {
+	for out, exp := range map[string]uint64{
+		"-\n":       0,
+		"0\n":       0,
+		"1048576\n": 1048576,
+	} {
+		space, err := parseZPoolCheckpointSpace(out)
+		require.NoError(t, err, "%q", out)
+		assert.Equal(t, exp, space, "%q", out)
+	}
+	_, err := parseZPoolCheckpointSpace("1M\n")
+	assert.Error(t, err)
+}